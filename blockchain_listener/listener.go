@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"math/big"
+	"slices"
 	"time"
 
 	"github.com/ferreirogomes/tiquin/models"
@@ -156,9 +158,11 @@ func (l *BlockchainListener) backfillTransactions() {
 func (l *BlockchainListener) ProcessTransaction(signature solana.Signature) {
 	log.Printf("Fetching transaction details for %s...", signature.String())
 
+	maxVersion := uint64(0)
 	txResp, err := l.RPCClient.GetTransaction(context.Background(), signature, &rpc.GetTransactionOpts{
-		Commitment: rpc.CommitmentFinalized,
-		Encoding:   solana.EncodingJSONParsed,
+		Commitment:                     rpc.CommitmentFinalized,
+		Encoding:                       solana.EncodingBase64,
+		MaxSupportedTransactionVersion: &maxVersion,
 	})
 	if err != nil {
 		log.Printf("Failed to get transaction details for %s: %v", signature.String(), err)
@@ -169,13 +173,38 @@ func (l *BlockchainListener) ProcessTransaction(signature solana.Signature) {
 		return
 	}
 
-	// Parse the JSON-encoded transaction to find SPL token instructions
 	// The transaction meta contains pre/post token balances which is the most reliable source
 	if txResp.Meta == nil {
 		log.Printf("No meta for transaction %s, skipping.", signature.String())
 		return
 	}
 
+	slot := int64(txResp.Slot)
+	blockTime := time.Now()
+	if txResp.BlockTime != nil {
+		blockTime = txResp.BlockTime.Time()
+	}
+
+	// Movements booked by the API before the transaction finalized get their slot now
+	if err := l.DB.ConfirmLedgerEntries(signature.String(), slot, blockTime); err != nil {
+		log.Printf("Failed to confirm ledger entries for %s: %v", signature.String(), err)
+	}
+
+	// References tie the transaction back to the business object that originated it
+	l.recordReferences(signature, txResp, slot, blockTime)
+
+	// Movements of a transaction are journaled once, whether the API booked it
+	// or an earlier pass of the listener did
+	booked, err := l.DB.TransactionExists(signature.String())
+	if err != nil {
+		log.Printf("Error checking transaction idempotency: %v", err)
+		return
+	}
+	if booked {
+		log.Printf("Transaction %s already processed. Skipping.", signature.String())
+		return
+	}
+
 	// Use pre/post token balances to detect transfers and mints
 	preBalances := txResp.Meta.PreTokenBalances
 	postBalances := txResp.Meta.PostTokenBalances
@@ -185,50 +214,69 @@ func (l *BlockchainListener) ProcessTransaction(signature solana.Signature) {
 		return
 	}
 
-	// Build a map of accountIndex -> post balance
-	postMap := make(map[uint16]rpc.TokenBalance)
-	for _, pb := range postBalances {
-		postMap[pb.AccountIndex] = pb
-	}
-	preMap := make(map[uint16]rpc.TokenBalance)
-	for _, pb := range preBalances {
-		preMap[pb.AccountIndex] = pb
+	for _, c := range classifyBalanceChanges(preBalances, postBalances) {
+		amount := float64(c.amount) / 1e9
+		switch c.kind {
+		case mintedTo:
+			l.handleMintTo(signature, slot, blockTime, c.mint, c.mint, c.owner, amount)
+		case transferredIn:
+			l.handleTransfer(signature, slot, blockTime, c.mint, c.owner, amount)
+		case transferredOut:
+			l.handleTransferOut(signature, slot, blockTime, c.mint, c.owner, amount)
+		}
 	}
+}
 
-	// Detect MintTo: post balance exists but pre balance doesn't (new tokens created)
-	// Detect Transfer: both pre and post exist, with delta
-	for idx, post := range postMap {
-		pre, hasPre := preMap[idx]
-		mintAddr := post.Mint.String()
+// recordReferences records the business objects referenced by the memos of
+// a transaction. Only transactions paid by the FeePayer are trusted, as only
+// the backend can sign them.
+func (l *BlockchainListener) recordReferences(signature solana.Signature, txResp *rpc.GetTransactionResult, slot int64, blockTime time.Time) {
+	tx, err := txResp.Transaction.GetTransaction()
+	if err != nil {
+		log.Printf("Failed to decode transaction %s: %v", signature.String(), err)
+		return
+	}
 
-		postAmt := parseTokenAmount(post.UiTokenAmount.Amount)
-		var preAmt uint64
-		if hasPre {
-			preAmt = parseTokenAmount(pre.UiTokenAmount.Amount)
+	for _, ref := range memoReferences(tx, l.FeePayerPK.PublicKey()) {
+		err := l.DB.RecordTransactionReference(models.TransactionReference{
+			TransactionID:     signature.String(),
+			ReferenceType:     ref.Type,
+			ReferenceID:       ref.ID,
+			ExternalReference: ref.External,
+			Slot:              slot,
+			BlockTime:         blockTime,
+			CreatedAt:         time.Now(),
+		})
+		if err != nil {
+			log.Printf("Failed to record reference %s %s of %s: %v", ref.Type, ref.ID, signature.String(), err)
+			continue
 		}
+		log.Printf("Transaction %s linked to %s %s", signature.String(), ref.Type, ref.ID)
+	}
+}
 
-		if postAmt <= preAmt {
-			continue // No increase, skip (this account was debited or unchanged)
-		}
+// memoReferences decodes the reference memos of a transaction paid by
+// feePayer. Transactions paid by anyone else carry no trusted references.
+func memoReferences(tx *solana.Transaction, feePayer solana.PublicKey) []models.TxReference {
+	if len(tx.Message.AccountKeys) == 0 || !tx.Message.AccountKeys[0].Equals(feePayer) {
+		return nil
+	}
 
-		delta := postAmt - preAmt
-		owner := ""
-		if post.Owner != nil {
-			owner = post.Owner.String()
+	var refs []models.TxReference
+	for _, ix := range tx.Message.Instructions {
+		program, err := tx.Message.Program(ix.ProgramIDIndex)
+		if err != nil || !program.Equals(solana.MemoProgramID) {
+			continue
 		}
-
-		if !hasPre {
-			// MintTo: token account created and funded
-			l.handleMintTo(signature, mintAddr, post.Mint.String(), owner, float64(delta)/1e9)
-		} else {
-			// Transfer: existing account received tokens
-			l.handleTransfer(signature, mintAddr, owner, float64(delta)/1e9)
+		if ref, ok := models.ParseTxReference(string(ix.Data)); ok {
+			refs = append(refs, ref)
 		}
 	}
+	return refs
 }
 
 // handleMintTo processes a detected MintTo event from balance analysis.
-func (l *BlockchainListener) handleMintTo(signature solana.Signature, tokenAccountAddr, mintAddr, ownerPubKey string, amount float64) {
+func (l *BlockchainListener) handleMintTo(signature solana.Signature, slot int64, blockTime time.Time, tokenAccountAddr, mintAddr, ownerPubKey string, amount float64) {
 	log.Printf("'mintTo' event detected for mint %s, owner %s, amount %f", mintAddr, ownerPubKey, amount)
 
 	asset, foundAsset, err := l.DB.GetAssetByMintAddress(mintAddr)
@@ -251,17 +299,6 @@ func (l *BlockchainListener) handleMintTo(signature solana.Signature, tokenAccou
 		return
 	}
 
-	// Idempotency check
-	txExists, err := l.DB.TransactionExists(signature.String())
-	if err != nil {
-		log.Printf("Error checking transaction idempotency: %v", err)
-		return
-	}
-	if txExists {
-		log.Printf("Transaction %s already processed for MintTo. Skipping.", signature.String())
-		return
-	}
-
 	tokenRecord := models.Token{
 		ID:                  signature.String() + "-mint", // Deterministic ID
 		AssetID:             asset.ID,
@@ -276,13 +313,15 @@ func (l *BlockchainListener) handleMintTo(signature solana.Signature, tokenAccou
 	}
 	if err := l.DB.SaveToken(tokenRecord); err != nil {
 		log.Printf("Failed to save token record for MintTo %s: %v", signature.String(), err)
-	} else {
-		log.Printf("MintTo synced: asset %s, owner %s, amount %f, tx %s", asset.Symbol, ownerUser.ID, amount, signature.String())
+		return
 	}
+	log.Printf("MintTo synced: asset %s, owner %s, amount %f, tx %s", asset.Symbol, ownerUser.ID, amount, signature.String())
+
+	l.journal(asset.ID, ownerUser, amount, models.LedgerEntryMint, signature, slot, blockTime)
 }
 
 // handleTransfer processes a detected Transfer event from balance analysis.
-func (l *BlockchainListener) handleTransfer(signature solana.Signature, mintAddr, toOwnerPubKey string, amount float64) {
+func (l *BlockchainListener) handleTransfer(signature solana.Signature, slot int64, blockTime time.Time, mintAddr, toOwnerPubKey string, amount float64) {
 	log.Printf("'transfer' event detected for mint %s, to owner %s, amount %f", mintAddr, toOwnerPubKey, amount)
 
 	asset, foundAsset, err := l.DB.GetAssetByMintAddress(mintAddr)
@@ -305,20 +344,8 @@ func (l *BlockchainListener) handleTransfer(signature solana.Signature, mintAddr
 		return
 	}
 
-	// Idempotency check
-	txExists, err := l.DB.TransactionExists(signature.String())
-	if err != nil {
-		log.Printf("Error checking transaction idempotency: %v", err)
-		return
-	}
-	if txExists {
-		log.Printf("Transaction %s already processed for Transfer. Skipping.", signature.String())
-		return
-	}
-
-	// Record the transfer for the recipient
-	// Note: sender debit is handled in CompleteTransferTokenFromUser for internal transfers.
-	// For external/unknown senders, we just record the receipt.
+	// Record the transfer for the recipient; the sender's side is journaled
+	// by handleTransferOut
 	transferredTokenRecord := models.Token{
 		ID:            signature.String() + "-transfer",
 		AssetID:       asset.ID,
@@ -333,9 +360,135 @@ func (l *BlockchainListener) handleTransfer(signature solana.Signature, mintAddr
 
 	if err := l.DB.SaveToken(transferredTokenRecord); err != nil {
 		log.Printf("Failed to save token record for Transfer %s: %v", signature.String(), err)
-	} else {
-		log.Printf("Transfer synced: asset %s, to %s, amount %f, tx %s", asset.Symbol, toUser.ID, amount, signature.String())
+		return
+	}
+	log.Printf("Transfer synced: asset %s, to %s, amount %f, tx %s", asset.Symbol, toUser.ID, amount, signature.String())
+
+	l.journal(asset.ID, toUser, amount, models.LedgerEntryTransferIn, signature, slot, blockTime)
+}
+
+// handleTransferOut journals the sending side of a detected transfer, so the
+// ledger holds both legs of transfers the API did not book.
+func (l *BlockchainListener) handleTransferOut(signature solana.Signature, slot int64, blockTime time.Time, mintAddr, fromOwnerPubKey string, amount float64) {
+	asset, foundAsset, err := l.DB.GetAssetByMintAddress(mintAddr)
+	if err != nil {
+		log.Printf("Error fetching asset by MintAddress %s: %v", mintAddr, err)
+		return
+	}
+	if !foundAsset {
+		return
+	}
+
+	fromUser, foundFromUser, err := l.DB.GetUserBySolanaPubKey(fromOwnerPubKey)
+	if err != nil {
+		log.Printf("Error fetching sender user by SolanaPubKey %s: %v", fromOwnerPubKey, err)
+		return
+	}
+	if !foundFromUser {
+		log.Printf("Sender (pubkey %s) not found in internal DB for TxID %s. Skipping.", fromOwnerPubKey, signature.String())
+		return
+	}
+	log.Printf("Transfer out synced: asset %s, from %s, amount %f, tx %s", asset.Symbol, fromUser.ID, amount, signature.String())
+
+	l.journal(asset.ID, fromUser, -amount, models.LedgerEntryTransferOut, signature, slot, blockTime)
+}
+
+// journal appends a finalized movement to the ledger; debits are negative.
+func (l *BlockchainListener) journal(assetID string, owner models.User, amount float64, entryType string, signature solana.Signature, slot int64, blockTime time.Time) {
+	entry := models.LedgerEntry{
+		AssetID:       assetID,
+		OwnerID:       &owner.ID,
+		SolanaPubKey:  owner.SolanaPubKey,
+		Amount:        amount,
+		EntryType:     entryType,
+		TransactionID: signature.String(),
+		Slot:          &slot,
+		BlockTime:     blockTime,
+	}
+	if err := l.DB.RecordLedgerEntry(entry); err != nil {
+		log.Printf("Failed to journal %s for %s: %v", entryType, signature.String(), err)
+	}
+}
+
+// balanceChangeKind tells how a token account's balance moved in a transaction.
+type balanceChangeKind int
+
+const (
+	mintedTo       balanceChangeKind = iota // Token account created and funded
+	transferredIn                           // Existing account received tokens
+	transferredOut                          // Account debited of a mint another account received
+)
+
+// balanceChange is the movement of one token account in a transaction, in
+// atomic units.
+type balanceChange struct {
+	kind   balanceChangeKind
+	mint   string
+	owner  string
+	amount uint64
+}
+
+// classifyBalanceChanges derives the movements of a transaction from its
+// pre/post token balances, ordered by account index, credits first.
+// An account credited without a pre balance was created and funded by a
+// MintTo; with one, it received a transfer. Accounts debited of a mint that
+// another account received are the sending side of transfers; accounts closed
+// by the transaction have no post balance and were emptied. Debits without a
+// matching credit are burns and are left out.
+func classifyBalanceChanges(preBalances, postBalances []rpc.TokenBalance) []balanceChange {
+	preMap := make(map[uint16]rpc.TokenBalance, len(preBalances))
+	for _, pb := range preBalances {
+		preMap[pb.AccountIndex] = pb
+	}
+	postMap := make(map[uint16]rpc.TokenBalance, len(postBalances))
+	for _, pb := range postBalances {
+		postMap[pb.AccountIndex] = pb
+	}
+	ownerOf := func(b rpc.TokenBalance) string {
+		if b.Owner == nil {
+			return ""
+		}
+		return b.Owner.String()
+	}
+
+	var changes []balanceChange
+	credited := make(map[string]bool) // Mints some account received in this transaction
+	for _, idx := range slices.Sorted(maps.Keys(postMap)) {
+		post := postMap[idx]
+		pre, hasPre := preMap[idx]
+		postAmt := parseTokenAmount(post.UiTokenAmount.Amount)
+		var preAmt uint64
+		if hasPre {
+			preAmt = parseTokenAmount(pre.UiTokenAmount.Amount)
+		}
+		if postAmt <= preAmt {
+			continue // Debited or unchanged
+		}
+
+		kind := transferredIn
+		if !hasPre {
+			kind = mintedTo
+		}
+		credited[post.Mint.String()] = true
+		changes = append(changes, balanceChange{kind: kind, mint: post.Mint.String(), owner: ownerOf(post), amount: postAmt - preAmt})
+	}
+
+	for _, idx := range slices.Sorted(maps.Keys(preMap)) {
+		pre := preMap[idx]
+		if !credited[pre.Mint.String()] {
+			continue
+		}
+		preAmt := parseTokenAmount(pre.UiTokenAmount.Amount)
+		var postAmt uint64
+		if post, hasPost := postMap[idx]; hasPost {
+			postAmt = parseTokenAmount(post.UiTokenAmount.Amount)
+		}
+		if postAmt >= preAmt {
+			continue
+		}
+		changes = append(changes, balanceChange{kind: transferredOut, mint: pre.Mint.String(), owner: ownerOf(pre), amount: preAmt - postAmt})
 	}
+	return changes
 }
 
 // parseTokenAmount safely parses a token amount string to uint64.
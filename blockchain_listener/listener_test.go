@@ -0,0 +1,191 @@
+package blockchain_listener
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestClassifyBalanceChanges(t *testing.T) {
+	mint, otherMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	alice, bob, carol := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	balance := func(idx uint16, mint, owner solana.PublicKey, amount string) rpc.TokenBalance {
+		return rpc.TokenBalance{AccountIndex: idx, Mint: mint, Owner: &owner, UiTokenAmount: &rpc.UiTokenAmount{Amount: amount, Decimals: 9}}
+	}
+	change := func(kind balanceChangeKind, mint, owner solana.PublicKey, amount uint64) balanceChange {
+		return balanceChange{kind: kind, mint: mint.String(), owner: owner.String(), amount: amount}
+	}
+
+	tests := []struct {
+		name string
+		pre  []rpc.TokenBalance
+		post []rpc.TokenBalance
+		want []balanceChange
+	}{
+		{
+			name: "mint to a new account",
+			post: []rpc.TokenBalance{balance(1, mint, alice, "5000000000")},
+			want: []balanceChange{change(mintedTo, mint, alice, 5_000_000_000)},
+		},
+		{
+			name: "mint to an existing account has no sender",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "3000")},
+			want: []balanceChange{change(transferredIn, mint, alice, 2000)},
+		},
+		{
+			name: "transfer between existing accounts",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000"), balance(2, mint, bob, "500")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "400"), balance(2, mint, bob, "1100")},
+			want: []balanceChange{change(transferredIn, mint, bob, 600), change(transferredOut, mint, alice, 600)},
+		},
+		{
+			name: "transfer opening the recipient's account",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "700"), balance(2, mint, bob, "300")},
+			want: []balanceChange{change(mintedTo, mint, bob, 300), change(transferredOut, mint, alice, 300)},
+		},
+		{
+			name: "sender's account closed by the transfer",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000"), balance(2, mint, bob, "0")},
+			post: []rpc.TokenBalance{balance(2, mint, bob, "1000")},
+			want: []balanceChange{change(transferredIn, mint, bob, 1000), change(transferredOut, mint, alice, 1000)},
+		},
+		{
+			name: "one sender paying two recipients",
+			pre:  []rpc.TokenBalance{balance(3, mint, alice, "1000"), balance(1, mint, bob, "0"), balance(2, mint, carol, "0")},
+			post: []rpc.TokenBalance{balance(2, mint, carol, "200"), balance(3, mint, alice, "700"), balance(1, mint, bob, "100")},
+			want: []balanceChange{
+				change(transferredIn, mint, bob, 100),
+				change(transferredIn, mint, carol, 200),
+				change(transferredOut, mint, alice, 300),
+			},
+		},
+		{
+			name: "swap of two mints",
+			pre: []rpc.TokenBalance{
+				balance(1, mint, alice, "10"), balance(2, mint, bob, "0"),
+				balance(3, otherMint, alice, "0"), balance(4, otherMint, bob, "50"),
+			},
+			post: []rpc.TokenBalance{
+				balance(1, mint, alice, "0"), balance(2, mint, bob, "10"),
+				balance(3, otherMint, alice, "50"), balance(4, otherMint, bob, "0"),
+			},
+			want: []balanceChange{
+				change(transferredIn, mint, bob, 10),
+				change(transferredIn, otherMint, alice, 50),
+				change(transferredOut, mint, alice, 10),
+				change(transferredOut, otherMint, bob, 50),
+			},
+		},
+		{
+			name: "burn has no credit",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "400")},
+		},
+		{
+			name: "burn next to a mint of another asset",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "400"), balance(2, otherMint, bob, "5")},
+			want: []balanceChange{change(mintedTo, otherMint, bob, 5)},
+		},
+		{
+			name: "unchanged balances",
+			pre:  []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+			post: []rpc.TokenBalance{balance(1, mint, alice, "1000")},
+		},
+		{
+			name: "account without owner",
+			post: []rpc.TokenBalance{{AccountIndex: 1, Mint: mint, UiTokenAmount: &rpc.UiTokenAmount{Amount: "7"}}},
+			want: []balanceChange{{kind: mintedTo, mint: mint.String(), amount: 7}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyBalanceChanges(tt.pre, tt.post)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("classifyBalanceChanges() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTokenAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+	}{
+		{"0", 0},
+		{"1500000000", 1_500_000_000},
+		{"18446744073709551615", 18446744073709551615},
+		{"", 0},
+		{"not a number", 0},
+	}
+	for _, tt := range tests {
+		if got := parseTokenAmount(tt.in); got != tt.want {
+			t.Errorf("parseTokenAmount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMemoReferences(t *testing.T) {
+	feePayer, holder := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	memoOf := func(text string, signer solana.PublicKey) solana.Instruction { // As the backend writes them
+		return solana.NewInstruction(solana.MemoProgramID, solana.AccountMetaSlice{solana.Meta(signer).SIGNER()}, []byte(text))
+	}
+	transfer := system.NewTransferInstruction(1, feePayer, holder).Build()
+	external := "NF-2025-0042"
+	order := models.TxReference{Type: models.ReferenceOrder, ID: "o1", External: &external}
+	payout := models.TxReference{Type: models.ReferenceDistribution, ID: "d1"}
+
+	tests := []struct {
+		name  string
+		payer solana.PublicKey
+		ixs   []solana.Instruction
+		want  []models.TxReference
+	}{
+		{
+			name:  "reference with an external part",
+			payer: feePayer,
+			ixs:   []solana.Instruction{memoOf(order.Memo(), feePayer), transfer},
+			want:  []models.TxReference{order},
+		},
+		{
+			name:  "several references",
+			payer: feePayer,
+			ixs:   []solana.Instruction{transfer, memoOf(payout.Memo(), feePayer), memoOf(order.Memo(), feePayer)},
+			want:  []models.TxReference{payout, order},
+		},
+		{
+			name:  "memos that are not references",
+			payer: feePayer,
+			ixs:   []solana.Instruction{memoOf("thanks for lunch", feePayer), memoOf("tiquin:ref:order", feePayer), transfer},
+		},
+		{
+			name:  "reference outside the memo program",
+			payer: feePayer,
+			ixs:   []solana.Instruction{solana.NewInstruction(system.ProgramID, nil, []byte(order.Memo()))},
+		},
+		{
+			name:  "paid by someone else",
+			payer: holder,
+			ixs:   []solana.Instruction{memoOf(order.Memo(), holder), transfer},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := solana.NewTransaction(tt.ixs, solana.Hash{}, solana.TransactionPayer(tt.payer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := memoReferences(tx, feePayer); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("memoReferences() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
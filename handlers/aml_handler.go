@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AMLHandler handles HTTP requests related to watchlists and screening.
+type AMLHandler struct {
+	Service *services.AMLService
+}
+
+// NewAMLHandler creates a new AML handler instance.
+func NewAMLHandler(s *services.AMLService) *AMLHandler {
+	return &AMLHandler{Service: s}
+}
+
+// ImportWatchlist loads a sanctions, PEP or blocked-wallet list from a CSV
+// (Content-Type text/csv) or JSON body. Query parameters: type (required) and
+// replace=true to drop entries missing from this import.
+// POST /aml/watchlists/{list}/import
+func (h *AMLHandler) ImportWatchlist(w http.ResponseWriter, r *http.Request) {
+	format := "json"
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = "csv"
+	}
+
+	result, err := h.Service.ImportWatchlist(
+		chi.URLParam(r, "list"), r.URL.Query().Get("type"), format, r.Body, r.URL.Query().Get("replace") == "true",
+	)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetHits returns the review queue: hits with the given status (default open).
+// GET /aml/hits
+func (h *AMLHandler) GetHits(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.HitStatusOpen
+	}
+
+	hits, err := h.Service.ListHits(status)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hits)
+}
+
+// DecideHit records an analyst's decision (confirmed or dismissed) on a hit.
+// POST /aml/hits/{id}/decision
+func (h *AMLHandler) DecideHit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Decision string  `json:"decision"`
+		Analyst  string  `json:"analyst"`
+		Note     *string `json:"note,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hit, err := h.Service.DecideHit(chi.URLParam(r, "id"), req.Decision, req.Analyst, req.Note)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hit)
+}
+
+// GetScreenings returns the screening audit trail of a wallet.
+// GET /aml/screenings?solana_pub_key=...
+func (h *AMLHandler) GetScreenings(w http.ResponseWriter, r *http.Request) {
+	pubKey := r.URL.Query().Get("solana_pub_key")
+	if pubKey == "" {
+		http.Error(w, "solana_pub_key is required", http.StatusBadRequest)
+		return
+	}
+
+	screenings, err := h.Service.DB.GetScreeningsByPubKey(pubKey)
+	if err != nil {
+		http.Error(w, "Error fetching screenings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(screenings)
+}
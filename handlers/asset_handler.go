@@ -3,8 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ferreirogomes/tiquin/models"
 	"github.com/ferreirogomes/tiquin/services"
+	"github.com/ferreirogomes/tiquin/storage"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -19,14 +24,13 @@ func NewAssetHandler(s *services.TokenizationService) *AssetHandler {
 	return &AssetHandler{Service: s}
 }
 
-// CreateAsset creates a new asset and mints it on Solana.
+// CreateAsset creates a new asset and its token on Solana or the EVM chain.
 // POST /assets
 func (h *AssetHandler) CreateAsset(w http.ResponseWriter, r *http.Request) {
 	var requestBody struct {
-		Symbol             string  `json:"symbol"`
-		Name               string  `json:"name"`
-		TotalShares        float64 `json:"total_shares"`
+		services.CreateAssetInput
 		OwnerSolanaPubKey  string  `json:"owner_solana_pub_key"` // The initial token owner's public key
+		OwnerEVMAddress    string  `json:"owner_evm_address"`    // The initial token owner's address, for EVM assets
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -34,22 +38,114 @@ func (h *AssetHandler) CreateAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if requestBody.OwnerSolanaPubKey == "" {
+	owner := requestBody.OwnerSolanaPubKey
+	if requestBody.Chain == models.ChainEVM {
+		owner = requestBody.OwnerEVMAddress
+		if owner == "" {
+			http.Error(w, "owner_evm_address is required to deploy an EVM token", http.StatusBadRequest)
+			return
+		}
+	} else if owner == "" {
 		http.Error(w, "owner_solana_pub_key is required to create an on-chain mint", http.StatusBadRequest)
 		return
 	}
 
-	asset, err := h.Service.CreateAsset(requestBody.Symbol, requestBody.Name, requestBody.TotalShares, requestBody.OwnerSolanaPubKey)
+	requestBody.Owner = owner
+	asset, err := h.Service.CreateAsset(requestBody.CreateAssetInput)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", assetETag(asset))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(asset)
 }
 
+// ListAssets lists the asset catalog, ordered by symbol. q searches the
+// symbol, name and issuer; status, chain, asset_class and issuer_cnpj filter
+// exactly; limit (default 50, at most 200) and offset page through results.
+// GET /assets?q=petro&status=active&limit=50&offset=0
+func (h *AssetHandler) ListAssets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.AssetFilter{
+		Query:      query.Get("q"),
+		Status:     query.Get("status"),
+		Chain:      query.Get("chain"),
+		AssetClass: query.Get("asset_class"),
+		IssuerCNPJ: query.Get("issuer_cnpj"),
+	}
+	var err error
+	if raw := query.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "limit must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if raw := query.Get("offset"); raw != "" {
+		if filter.Offset, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "offset must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	assets, err := h.Service.ListAssets(filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+// UpdateAsset changes the mutable fields of an asset. The version being
+// updated is passed as the If-Match header, with the ETag of a previous
+// read, or as "version" in the body; a stale version gets 412.
+// PATCH /assets/{id}
+func (h *AssetHandler) UpdateAsset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		services.UpdateAssetInput
+		Version *int `json:"version"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Immutable fields such as symbol must not be silently ignored
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version := req.Version
+	if match := r.Header.Get("If-Match"); match != "" {
+		v, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+		if err != nil {
+			http.Error(w, "If-Match must be an ETag returned for this asset", http.StatusBadRequest)
+			return
+		}
+		version = &v
+	}
+	if version == nil {
+		http.Error(w, "If-Match header or version is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	asset, err := h.Service.UpdateAsset(chi.URLParam(r, "id"), *version, req.UpdateAssetInput)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", assetETag(asset))
+	json.NewEncoder(w).Encode(asset)
+}
+
+// assetETag is the entity tag of an asset's current version.
+func assetETag(asset models.Asset) string {
+	return strconv.Quote(strconv.Itoa(asset.Version))
+}
+
 
 // GetAssetByID retrieves an asset by ID.
 // GET /assets/{id}
@@ -71,5 +167,122 @@ func (h *AssetHandler) GetAssetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", assetETag(asset))
 	json.NewEncoder(w).Encode(asset)
 }
+
+// GetTokenMetadata serves the token metadata JSON referenced by the asset's
+// on-chain metadata. It is public, as wallets fetch it without an API key.
+// GET /assets/{id}/metadata.json
+func (h *AssetHandler) GetTokenMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.Service.GetTokenMetadata(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(metadata)
+}
+
+// GetCapTable returns the holdings of an asset aggregated per holder.
+// An optional as_of query parameter (RFC 3339) returns the cap table at that instant.
+// GET /assets/{id}/cap-table
+func (h *AssetHandler) GetCapTable(w http.ResponseWriter, r *http.Request) {
+	assetID := chi.URLParam(r, "id")
+	if assetID == "" {
+		http.Error(w, "Asset ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var asOf *time.Time
+	if raw := r.URL.Query().Get("as_of"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "as_of must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		asOf = &t
+	}
+
+	capTable, err := h.Service.GetCapTable(assetID, asOf)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(capTable)
+}
+
+// GetComplianceRules returns the compliance rule set of an asset.
+// GET /assets/{id}/compliance-rules
+func (h *AssetHandler) GetComplianceRules(w http.ResponseWriter, r *http.Request) {
+	ruleSet, err := h.Service.Compliance.GetRules(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ruleSet)
+}
+
+// SetComplianceRules replaces the compliance rule set of an asset.
+// PUT /assets/{id}/compliance-rules
+func (h *AssetHandler) SetComplianceRules(w http.ResponseWriter, r *http.Request) {
+	var rules models.ComplianceRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ruleSet, err := h.Service.Compliance.SetRules(chi.URLParam(r, "id"), rules)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ruleSet)
+}
+
+// TransitionAsset moves an asset to another lifecycle state, e.g.
+// {"status": "suspended", "reason": "...", "ordered_by": "CVM"} for a trading halt.
+// POST /assets/{id}/transitions
+func (h *AssetHandler) TransitionAsset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		services.AssetTransitionInput
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.Service.TransitionAsset(chi.URLParam(r, "id"), req.Status, req.AssetTransitionInput)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(change)
+}
+
+// GetAssetTransitions lists the lifecycle transitions of an asset, with the
+// outcome of freezing or thawing holder accounts.
+// GET /assets/{id}/transitions
+func (h *AssetHandler) GetAssetTransitions(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.Service.GetAssetStatusHistory(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BatchTransferHandler handles HTTP requests related to batch transfers and airdrops.
+type BatchTransferHandler struct {
+	Service *services.BatchTransferService
+}
+
+// NewBatchTransferHandler creates a new batch transfer handler instance.
+func NewBatchTransferHandler(s *services.BatchTransferService) *BatchTransferHandler {
+	return &BatchTransferHandler{Service: s}
+}
+
+// CreateTransferBatch validates and stores a batch of transfers or an airdrop.
+// Rows refused by compliance or screening are kept as rejected.
+// POST /assets/{id}/transfer-batches
+func (h *BatchTransferHandler) CreateTransferBatch(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateTransferBatchInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	batch, err := h.Service.CreateBatch(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetTransferBatchesByAssetID lists the transfer batches of an asset.
+// GET /assets/{id}/transfer-batches
+func (h *BatchTransferHandler) GetTransferBatchesByAssetID(w http.ResponseWriter, r *http.Request) {
+	batches, err := h.Service.DB.GetTransferBatchesByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching transfer batches", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+// GetTransferBatchByID retrieves a transfer batch with its number of rows per status.
+// GET /transfer-batches/{id}
+func (h *BatchTransferHandler) GetTransferBatchByID(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.Service.GetBatch(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetTransferBatchRows lists the rows of a transfer batch, optionally filtered by status.
+// GET /transfer-batches/{id}/rows?status=failed
+func (h *BatchTransferHandler) GetTransferBatchRows(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Service.GetRows(chi.URLParam(r, "id"), r.URL.Query().Get("status"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// ExecuteTransferBatch starts sending a transfer batch in the background.
+// Re-executing a partially completed batch retries its pending and failed rows.
+// POST /transfer-batches/{id}/execute
+func (h *BatchTransferHandler) ExecuteTransferBatch(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.Service.StartExecution(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
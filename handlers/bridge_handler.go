@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BridgeHandler handles HTTP requests related to the Solana-EVM bridge.
+type BridgeHandler struct {
+	Service *services.BridgeService
+}
+
+// NewBridgeHandler creates a new bridge handler instance.
+func NewBridgeHandler(s *services.BridgeService) *BridgeHandler {
+	return &BridgeHandler{Service: s}
+}
+
+// CreateBridge opens a bridge for an asset, creating its custody account and
+// deploying the wrapped token on the EVM chain.
+// POST /assets/{id}/bridge
+func (h *BridgeHandler) CreateBridge(w http.ResponseWriter, r *http.Request) {
+	bridge, err := h.Service.CreateBridge(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bridge)
+}
+
+// GetBridge retrieves the bridge of an asset.
+// GET /assets/{id}/bridge
+func (h *BridgeHandler) GetBridge(w http.ResponseWriter, r *http.Request) {
+	bridge, err := h.Service.GetBridge(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bridge)
+}
+
+// GetBridgeTransfersByAssetID lists the transfers across an asset's bridge.
+// GET /assets/{id}/bridge/transfers
+func (h *BridgeHandler) GetBridgeTransfersByAssetID(w http.ResponseWriter, r *http.Request) {
+	transfers, err := h.Service.DB.GetBridgeTransfersByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching bridge transfers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+// GetBridgeTransferByID retrieves a bridge transfer and its status.
+// GET /bridge-transfers/{id}
+func (h *BridgeHandler) GetBridgeTransferByID(w http.ResponseWriter, r *http.Request) {
+	transfer, err := h.Service.GetBridgeTransfer(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// GetBridgeTransfersBySourceTx looks up the transfers a lock transaction
+// started, so a holder can follow it with the transaction they sent.
+// GET /bridge-transfers?source_tx_id=...
+func (h *BridgeHandler) GetBridgeTransfersBySourceTx(w http.ResponseWriter, r *http.Request) {
+	txID := r.URL.Query().Get("source_tx_id")
+	if txID == "" {
+		http.Error(w, "source_tx_id is required", http.StatusBadRequest)
+		return
+	}
+
+	transfers, err := h.Service.DB.GetBridgeTransfersBySourceTxID(txID)
+	if err != nil {
+		http.Error(w, "Error fetching bridge transfers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+// RefundBridgeTransfer releases a rejected Solana lock from custody back to
+// its sender.
+// POST /bridge-transfers/{id}/refund
+func (h *BridgeHandler) RefundBridgeTransfer(w http.ResponseWriter, r *http.Request) {
+	transfer, err := h.Service.RefundBridgeTransfer(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
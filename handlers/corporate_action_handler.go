@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CorporateActionHandler handles HTTP requests related to splits and reverse splits.
+type CorporateActionHandler struct {
+	Service *services.CorporateActionService
+}
+
+// NewCorporateActionHandler creates a new corporate action handler instance.
+func NewCorporateActionHandler(s *services.CorporateActionService) *CorporateActionHandler {
+	return &CorporateActionHandler{Service: s}
+}
+
+// CreateCorporateAction schedules a split or reverse split of an asset.
+// POST /assets/{id}/corporate-actions
+func (h *CorporateActionHandler) CreateCorporateAction(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateCorporateActionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	action, err := h.Service.CreateCorporateAction(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(action)
+}
+
+// GetCorporateActionsByAssetID lists the corporate actions of an asset.
+// GET /assets/{id}/corporate-actions
+func (h *CorporateActionHandler) GetCorporateActionsByAssetID(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.Service.DB.GetCorporateActionsByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching corporate actions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
+
+// GetCorporateActionByID retrieves a corporate action with every holder's adjustment.
+// GET /corporate-actions/{id}
+func (h *CorporateActionHandler) GetCorporateActionByID(w http.ResponseWriter, r *http.Request) {
+	action, err := h.Service.GetCorporateAction(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(action)
+}
+
+// ExecuteCorporateAction starts a due corporate action in the background
+// instead of waiting for the scheduler. Re-executing a partially applied
+// action retries its pending and failed adjustments.
+// POST /corporate-actions/{id}/execute
+func (h *CorporateActionHandler) ExecuteCorporateAction(w http.ResponseWriter, r *http.Request) {
+	action, err := h.Service.StartExecution(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(action)
+}
+
+// PrepareApproval returns the transaction a holder signs to let the platform
+// burn their reverse split adjustment.
+// POST /corporate-actions/{id}/approvals/prepare
+func (h *CorporateActionHandler) PrepareApproval(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serializedTx, err := h.Service.PrepareApproval(chi.URLParam(r, "id"), req.UserID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"serialized_transaction": serializedTx})
+}
+
+// CompleteApproval sends the approval transaction signed by the holder.
+// POST /corporate-actions/{id}/approvals/complete
+func (h *CorporateActionHandler) CompleteApproval(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sig, err := h.Service.SolanaS.SendSignedTransaction(req.SignedTransaction)
+	if err != nil {
+		http.Error(w, "Failed to send approval: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"transaction_id": sig.String()})
+}
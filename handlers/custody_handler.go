@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CustodyHandler handles HTTP requests related to custodial wallets.
+type CustodyHandler struct {
+	Service *services.CustodyService
+}
+
+// NewCustodyHandler creates a new custody handler instance.
+func NewCustodyHandler(s *services.CustodyService) *CustodyHandler {
+	return &CustodyHandler{Service: s}
+}
+
+// WalletResponse describes a custodial wallet without its encrypted keys.
+type WalletResponse struct {
+	UserID             string     `json:"user_id"`
+	SolanaPubKey       string     `json:"solana_pub_key"`
+	EVMAddress         string     `json:"evm_address"`
+	Status             string     `json:"status"`
+	MaxTransferAmount  *float64   `json:"max_transfer_amount,omitempty"`
+	DailyTransferLimit *float64   `json:"daily_transfer_limit,omitempty"`
+	AuthorizationKey   *string    `json:"authorization_key,omitempty"`
+	ExportedAt         *time.Time `json:"exported_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+func newWalletResponse(wallet models.CustodialWallet) WalletResponse {
+	return WalletResponse{
+		UserID:             wallet.UserID,
+		SolanaPubKey:       wallet.SolanaPubKey,
+		EVMAddress:         wallet.EVMAddress,
+		Status:             wallet.Status,
+		MaxTransferAmount:  wallet.MaxTransferAmount,
+		DailyTransferLimit: wallet.DailyTransferLimit,
+		AuthorizationKey:   wallet.AuthorizationKey,
+		ExportedAt:         wallet.ExportedAt,
+		CreatedAt:          wallet.CreatedAt,
+	}
+}
+
+// GetWallet retrieves a user's custodial wallet and its limits.
+// GET /users/{id}/wallet
+func (h *CustodyHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	wallet, err := h.Service.GetWallet(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWalletResponse(wallet))
+}
+
+// SetWalletLimits replaces the transfer limits of a custodial wallet.
+// Omitted limits are removed.
+// PUT /users/{id}/wallet/limits
+func (h *CustodyHandler) SetWalletLimits(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MaxTransferAmount  *float64 `json:"max_transfer_amount,omitempty"`
+		DailyTransferLimit *float64 `json:"daily_transfer_limit,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := h.Service.SetLimits(chi.URLParam(r, "id"), req.MaxTransferAmount, req.DailyTransferLimit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWalletResponse(wallet))
+}
+
+// RequestWalletExport issues the message the user must sign with their
+// authorization key to export their wallet's keys.
+// POST /users/{id}/wallet/export/challenge
+func (h *CustodyHandler) RequestWalletExport(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.Service.RequestExport(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// ExportWallet returns a custodial wallet's private keys, given the export
+// challenge signed with the user's authorization key. The keys are erased
+// only once the export is confirmed.
+// POST /users/{id}/wallet/export
+func (h *CustodyHandler) ExportWallet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Signature string `json:"signature"` // Base58, by the authorization key over the challenge message
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.Service.ExportWallet(chi.URLParam(r, "id"), req.Signature)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(export)
+}
+
+// ConfirmWalletExport erases an exported wallet's keys and moves the user to
+// self-custody, given the export's confirmation message signed with the
+// exported Solana key.
+// POST /users/{id}/wallet/export/confirm
+func (h *CustodyHandler) ConfirmWalletExport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Signature string `json:"signature"` // Base58, by the exported Solana key over the confirmation message
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := h.Service.ConfirmExport(chi.URLParam(r, "id"), req.Signature)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWalletResponse(wallet))
+}
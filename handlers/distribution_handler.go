@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DistributionHandler handles HTTP requests related to dividend and income distributions.
+type DistributionHandler struct {
+	Service *services.DistributionService
+}
+
+// NewDistributionHandler creates a new distribution handler instance.
+func NewDistributionHandler(s *services.DistributionService) *DistributionHandler {
+	return &DistributionHandler{Service: s}
+}
+
+// CreateDistribution computes the pro-rata entitlements of a new distribution.
+// POST /assets/{id}/distributions
+func (h *DistributionHandler) CreateDistribution(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateDistributionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	distribution, err := h.Service.CreateDistribution(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(distribution)
+}
+
+// GetDistributionsByAssetID lists the distributions of an asset.
+// GET /assets/{id}/distributions
+func (h *DistributionHandler) GetDistributionsByAssetID(w http.ResponseWriter, r *http.Request) {
+	distributions, err := h.Service.DB.GetDistributionsByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching distributions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(distributions)
+}
+
+// GetDistributionByID retrieves a distribution with the status of every payout.
+// GET /distributions/{id}
+func (h *DistributionHandler) GetDistributionByID(w http.ResponseWriter, r *http.Request) {
+	distribution, err := h.Service.GetDistribution(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(distribution)
+}
+
+// ExecuteDistribution starts paying a distribution in the background.
+// Re-executing a partially paid distribution retries its pending and failed payouts.
+// POST /distributions/{id}/execute
+func (h *DistributionHandler) ExecuteDistribution(w http.ResponseWriter, r *http.Request) {
+	distribution, err := h.Service.StartExecution(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(distribution)
+}
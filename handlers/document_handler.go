@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DocumentHandler handles HTTP requests related to asset documents.
+type DocumentHandler struct {
+	Service *services.DocumentService
+}
+
+// NewDocumentHandler creates a new document handler instance.
+func NewDocumentHandler(s *services.DocumentService) *DocumentHandler {
+	return &DocumentHandler{Service: s}
+}
+
+// UploadDocument uploads a new version of an asset document. The body is the
+// file itself; query parameters: name (required), kind and file_name.
+// POST /assets/{id}/documents
+func (h *DocumentHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.Service.Upload(services.UploadDocumentInput{
+		AssetID:     chi.URLParam(r, "id"),
+		Name:        r.URL.Query().Get("name"),
+		Kind:        r.URL.Query().Get("kind"),
+		FileName:    r.URL.Query().Get("file_name"),
+		ContentType: r.Header.Get("Content-Type"),
+		Content:     r.Body,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// GetDocumentsByAssetID lists every version of an asset's documents, or only
+// the latest version of each with latest=true.
+// GET /assets/{id}/documents
+func (h *DocumentHandler) GetDocumentsByAssetID(w http.ResponseWriter, r *http.Request) {
+	assetID := chi.URLParam(r, "id")
+	getDocuments := h.Service.DB.GetDocumentsByAssetID
+	if r.URL.Query().Get("latest") == "true" {
+		getDocuments = h.Service.DB.GetLatestDocumentsByAssetID
+	}
+
+	docs, err := getDocuments(assetID)
+	if err != nil {
+		http.Error(w, "Error fetching documents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docs)
+}
+
+// GetDocumentByID retrieves a document version and its anchor.
+// GET /documents/{id}
+func (h *DocumentHandler) GetDocumentByID(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.Service.GetDocument(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// DownloadDocument downloads the file of a document version.
+// GET /documents/{id}/content
+func (h *DocumentHandler) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	doc, content, err := h.Service.Open(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.FileName))
+	w.Header().Set("X-Content-SHA256", doc.SHA256)
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending document %s: %v", doc.ID, err)
+	}
+}
+
+// AnchorDocument retries anchoring a document's hash on chain.
+// POST /documents/{id}/anchor
+func (h *DocumentHandler) AnchorDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.Service.Anchor(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// VerifyDocument checks a file, sent as the body, against a document version
+// and the hash anchored on chain.
+// POST /documents/{id}/verify
+func (h *DocumentHandler) VerifyDocument(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Service.Verify(chi.URLParam(r, "id"), r.Body)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
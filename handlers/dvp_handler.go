@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DvPHandler handles HTTP requests related to delivery-versus-payment settlements.
+type DvPHandler struct {
+	Service *services.DvPService
+}
+
+// NewDvPHandler creates a new DvP handler instance.
+func NewDvPHandler(s *services.DvPService) *DvPHandler {
+	return &DvPHandler{Service: s}
+}
+
+// CreateSettlement builds the DvP transaction both parties must sign. The
+// response carries it in the transaction field.
+// POST /dvp
+func (h *DvPHandler) CreateSettlement(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateDvPInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dvp, err := h.Service.CreateSettlement(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dvp)
+}
+
+// GetSettlementByID retrieves a DvP settlement with the signatures collected so far.
+// GET /dvp/{id}
+func (h *DvPHandler) GetSettlementByID(w http.ResponseWriter, r *http.Request) {
+	dvp, err := h.Service.GetSettlement(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dvp)
+}
+
+// AddSignature submits one party's signed copy of the DvP transaction.
+// POST /dvp/{id}/signatures
+func (h *DvPHandler) AddSignature(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID            string `json:"user_id"`
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dvp, err := h.Service.AddSignature(chi.URLParam(r, "id"), req.UserID, req.SignedTransaction)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dvp)
+}
+
+// ReconcileSettlement resolves a submitted settlement whose finalization was not observed.
+// POST /dvp/{id}/reconcile
+func (h *DvPHandler) ReconcileSettlement(w http.ResponseWriter, r *http.Request) {
+	dvp, err := h.Service.Reconcile(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dvp)
+}
+
+// GetSettlementsByAssetID lists the DvP settlements of an asset.
+// GET /assets/{id}/dvp
+func (h *DvPHandler) GetSettlementsByAssetID(w http.ResponseWriter, r *http.Request) {
+	settlements, err := h.Service.DB.GetDvPSettlementsByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching DvP settlements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlements)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// EscrowHandler handles HTTP requests related to escrows.
+type EscrowHandler struct {
+	Service *services.EscrowService
+}
+
+// NewEscrowHandler creates a new escrow handler instance.
+func NewEscrowHandler(s *services.EscrowService) *EscrowHandler {
+	return &EscrowHandler{Service: s}
+}
+
+// CreateEscrow opens an escrow and its token account. The response carries
+// the deposit transaction the depositor must sign.
+// POST /escrows
+func (h *EscrowHandler) CreateEscrow(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateEscrowInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escrow, err := h.Service.CreateEscrow(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// GetEscrowByID retrieves an escrow.
+// GET /escrows/{id}
+func (h *EscrowHandler) GetEscrowByID(w http.ResponseWriter, r *http.Request) {
+	escrow, err := h.Service.GetEscrow(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// PrepareDeposit rebuilds the deposit transaction when the previous one expired unsigned.
+// POST /escrows/{id}/deposit/prepare
+func (h *EscrowHandler) PrepareDeposit(w http.ResponseWriter, r *http.Request) {
+	escrow, err := h.Service.PrepareDeposit(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// Deposit submits the depositor's signed deposit transaction.
+// POST /escrows/{id}/deposit
+func (h *EscrowHandler) Deposit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escrow, err := h.Service.Deposit(chi.URLParam(r, "id"), req.SignedTransaction)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// ReleaseEscrow sends the escrowed tokens to the beneficiary.
+// POST /escrows/{id}/release
+func (h *EscrowHandler) ReleaseEscrow(w http.ResponseWriter, r *http.Request) {
+	escrow, err := h.Service.Release(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// RefundEscrow returns the escrowed tokens to the depositor.
+// POST /escrows/{id}/refund
+func (h *EscrowHandler) RefundEscrow(w http.ResponseWriter, r *http.Request) {
+	escrow, err := h.Service.Refund(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrow)
+}
+
+// GetEscrowsByUserID lists the escrows a user deposits into or benefits from.
+// GET /users/{id}/escrows
+func (h *EscrowHandler) GetEscrowsByUserID(w http.ResponseWriter, r *http.Request) {
+	escrows, err := h.Service.DB.GetEscrowsByUserID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching escrows", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escrows)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxWebhookBodyBytes bounds the size of provider callbacks.
+const maxWebhookBodyBytes = 1 << 20
+
+// KYCHandler handles HTTP requests related to user identity verification.
+type KYCHandler struct {
+	Service *services.KYCService
+}
+
+// NewKYCHandler creates a new KYC handler instance.
+func NewKYCHandler(s *services.KYCService) *KYCHandler {
+	return &KYCHandler{Service: s}
+}
+
+// GetKYCProfile returns the KYC status of a user with their verifications and documents.
+// GET /users/{id}/kyc
+func (h *KYCHandler) GetKYCProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.Service.GetProfile(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// StartVerification opens a verification of a user with the KYC provider.
+// POST /users/{id}/kyc/verifications
+func (h *KYCHandler) StartVerification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level int `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verification, err := h.Service.StartVerification(chi.URLParam(r, "id"), req.Level)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(verification)
+}
+
+// AddDocument stores a reference to a user's identity document.
+// POST /users/{id}/kyc/documents
+func (h *KYCHandler) AddDocument(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateKYCDocumentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.UserID = chi.URLParam(r, "id")
+
+	doc, err := h.Service.AddDocument(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// Webhook receives verification outcomes from the KYC provider. It is not
+// behind API key auth; the provider signs the body in X-KYC-Signature.
+// POST /kyc/webhooks/{provider}
+func (h *KYCHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verification, err := h.Service.HandleWebhook(chi.URLParam(r, "provider"), body, r.Header.Get("X-KYC-Signature"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MarketHandler handles HTTP requests related to the secondary market.
+type MarketHandler struct {
+	Service *services.MarketService
+}
+
+// NewMarketHandler creates a new market handler instance.
+func NewMarketHandler(s *services.MarketService) *MarketHandler {
+	return &MarketHandler{Service: s}
+}
+
+// ConfigureMarket sets the quote token and status of an asset's market.
+// PUT /assets/{id}/market
+func (h *MarketHandler) ConfigureMarket(w http.ResponseWriter, r *http.Request) {
+	var input services.ConfigureMarketInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	market, err := h.Service.ConfigureMarket(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(market)
+}
+
+// GetMarket retrieves the market of an asset.
+// GET /assets/{id}/market
+func (h *MarketHandler) GetMarket(w http.ResponseWriter, r *http.Request) {
+	market, err := h.Service.GetMarket(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(market)
+}
+
+// PlaceOrder stores a limit order and returns the delegate approval its owner
+// signs to activate it.
+// POST /assets/{id}/orders
+func (h *MarketHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var input services.PlaceOrderInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	placed, err := h.Service.PlaceOrder(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(placed)
+}
+
+// GetOrdersByAssetID lists the orders of an asset, optionally filtered by
+// user_id and status query parameters.
+// GET /assets/{id}/orders
+func (h *MarketHandler) GetOrdersByAssetID(w http.ResponseWriter, r *http.Request) {
+	var statuses []string
+	if status := r.URL.Query().Get("status"); status != "" {
+		statuses = append(statuses, status)
+	}
+	orders, err := h.Service.DB.GetOrdersByAssetID(chi.URLParam(r, "id"), r.URL.Query().Get("user_id"), statuses...)
+	if err != nil {
+		http.Error(w, "Error fetching orders", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+// GetOrderBook returns the bids and asks of an asset aggregated by price.
+// GET /assets/{id}/order-book
+func (h *MarketHandler) GetOrderBook(w http.ResponseWriter, r *http.Request) {
+	book, err := h.Service.GetOrderBook(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// GetTradesByAssetID lists the trades of an asset.
+// GET /assets/{id}/trades
+func (h *MarketHandler) GetTradesByAssetID(w http.ResponseWriter, r *http.Request) {
+	trades, err := h.Service.DB.GetTradesByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching trades", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trades)
+}
+
+// GetOrderByID retrieves an order.
+// GET /orders/{id}
+func (h *MarketHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
+	order, err := h.Service.GetOrder(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// ActivateOrder sends the approval prepared for an order once its owner
+// signed it, opens the order and matches it against the book.
+// POST /orders/{id}/activate
+func (h *MarketHandler) ActivateOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.Service.ActivateOrder(chi.URLParam(r, "id"), req.SignedTransaction)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// CancelOrder takes a live order off the book.
+// POST /orders/{id}/cancel
+func (h *MarketHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.Service.CancelOrder(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// ReconcileTrade resolves a trade whose swap confirmation was not observed.
+// POST /trades/{id}/reconcile
+func (h *MarketHandler) ReconcileTrade(w http.ResponseWriter, r *http.Request) {
+	trade, err := h.Service.ReconcileTrade(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trade)
+}
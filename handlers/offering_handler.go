@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OfferingHandler handles HTTP requests related to primary offerings and their subscriptions.
+type OfferingHandler struct {
+	Service *services.OfferingService
+}
+
+// NewOfferingHandler creates a new offering handler instance.
+func NewOfferingHandler(s *services.OfferingService) *OfferingHandler {
+	return &OfferingHandler{Service: s}
+}
+
+// CreateOffering opens a primary offering of an asset's tokens.
+// POST /assets/{id}/offerings
+func (h *OfferingHandler) CreateOffering(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateOfferingInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	offering, err := h.Service.CreateOffering(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(offering)
+}
+
+// GetOfferingsByAssetID lists the offerings of an asset.
+// GET /assets/{id}/offerings
+func (h *OfferingHandler) GetOfferingsByAssetID(w http.ResponseWriter, r *http.Request) {
+	offerings, err := h.Service.DB.GetOfferingsByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching offerings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offerings)
+}
+
+// GetOfferingByID retrieves an offering with its raise and issuance totals.
+// GET /offerings/{id}
+func (h *OfferingHandler) GetOfferingByID(w http.ResponseWriter, r *http.Request) {
+	offering, err := h.Service.GetOffering(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offering)
+}
+
+// CloseOffering closes an offering past its end date and delivers shares and
+// refunds in the background. Calling it again retries failed deliveries.
+// POST /offerings/{id}/close
+func (h *OfferingHandler) CloseOffering(w http.ResponseWriter, r *http.Request) {
+	offering, err := h.Service.StartClose(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(offering)
+}
+
+// Subscribe records an investor's subscription. The response carries the
+// payment transaction the investor must sign.
+// POST /offerings/{id}/subscriptions
+func (h *OfferingHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var input services.SubscribeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.OfferingID = chi.URLParam(r, "id")
+
+	subscription, err := h.Service.Subscribe(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// GetSubscriptionsByOfferingID lists the subscriptions of an offering.
+// GET /offerings/{id}/subscriptions
+func (h *OfferingHandler) GetSubscriptionsByOfferingID(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.Service.DB.GetSubscriptions(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// GetSubscriptionByID retrieves a subscription with its allocation and refund.
+// GET /subscriptions/{id}
+func (h *OfferingHandler) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.Service.GetSubscription(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// ConfirmPayment submits the investor's signed payment transaction.
+// POST /subscriptions/{id}/payment
+func (h *OfferingHandler) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.Service.ConfirmPayment(chi.URLParam(r, "id"), req.SignedTransaction)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// CancelSubscription withdraws a subscription that was not paid yet.
+// POST /subscriptions/{id}/cancel
+func (h *OfferingHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.Service.CancelSubscription(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ProposalHandler handles HTTP requests related to shareholder proposals and votes.
+type ProposalHandler struct {
+	Service *services.VotingService
+}
+
+// NewProposalHandler creates a new proposal handler instance.
+func NewProposalHandler(s *services.VotingService) *ProposalHandler {
+	return &ProposalHandler{Service: s}
+}
+
+// CreateProposal opens a new proposal on an asset.
+// POST /assets/{id}/proposals
+func (h *ProposalHandler) CreateProposal(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateProposalInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	proposal, err := h.Service.CreateProposal(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// GetProposalsByAssetID lists the proposals of an asset.
+// GET /assets/{id}/proposals
+func (h *ProposalHandler) GetProposalsByAssetID(w http.ResponseWriter, r *http.Request) {
+	proposals, err := h.Service.DB.GetProposalsByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching proposals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposals)
+}
+
+// GetProposalByID retrieves a proposal by ID.
+// GET /proposals/{id}
+func (h *ProposalHandler) GetProposalByID(w http.ResponseWriter, r *http.Request) {
+	proposal, err := h.Service.GetProposal(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// IssueBallot returns the message a holder must sign with their wallet to vote.
+// POST /proposals/{id}/ballots
+func (h *ProposalHandler) IssueBallot(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		VoterID string `json:"voter_id"`
+		Option  string `json:"option"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := h.Service.IssueBallot(chi.URLParam(r, "id"), requestBody.VoterID, requestBody.Option)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// CastVote records a vote from a ballot signed by the holder's wallet.
+// POST /proposals/{id}/votes
+func (h *ProposalHandler) CastVote(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		BallotID  string `json:"ballot_id"`
+		Signature string `json:"signature"` // Base58 signature of the ballot message
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vote, err := h.Service.CastVote(chi.URLParam(r, "id"), requestBody.BallotID, requestBody.Signature)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(vote)
+}
+
+// GetResults returns the tally of a proposal (provisional while voting is open).
+// GET /proposals/{id}/results
+func (h *ProposalHandler) GetResults(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Service.GetResults(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// FinalizeProposal tallies a closed proposal and anchors the result hash on
+// chain. The result has final false until the anchor is confirmed; call it
+// again to follow up.
+// POST /proposals/{id}/finalize
+func (h *ProposalHandler) FinalizeProposal(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Service.FinalizeProposal(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
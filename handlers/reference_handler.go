@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ReferenceHandler handles HTTP requests linking on-chain transactions to
+// the business objects that originated them.
+type ReferenceHandler struct {
+	DB *storage.DB
+}
+
+// NewReferenceHandler creates a new reference handler instance.
+func NewReferenceHandler(db *storage.DB) *ReferenceHandler {
+	return &ReferenceHandler{DB: db}
+}
+
+// GetTransactionReferences lists the references recorded for a transaction.
+// GET /transactions/{id}/references
+func (h *ReferenceHandler) GetTransactionReferences(w http.ResponseWriter, r *http.Request) {
+	refs, err := h.DB.GetTransactionReferences(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching transaction references", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
+
+// FindTransactions looks up the transactions of a business object (type and
+// id) or those carrying a caller-supplied reference (external).
+// GET /transaction-references?type=...&id=... or ?external=...
+func (h *ReferenceHandler) FindTransactions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	refType, refID, external := q.Get("type"), q.Get("id"), q.Get("external")
+
+	var refs []models.TransactionReference
+	var err error
+	switch {
+	case external != "":
+		refs, err = h.DB.GetTransactionsByExternalReference(external)
+	case refType != "" && refID != "":
+		refs, err = h.DB.GetTransactionsByReference(refType, refID)
+	default:
+		http.Error(w, "type and id, or external, are required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching transaction references", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
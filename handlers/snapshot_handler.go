@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SnapshotHandler handles HTTP requests related to holdings snapshots.
+type SnapshotHandler struct {
+	Service *services.SnapshotService
+}
+
+// NewSnapshotHandler creates a new snapshot handler instance.
+func NewSnapshotHandler(s *services.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{Service: s}
+}
+
+// CreateSnapshot materializes the holdings of an asset at a record date or slot.
+// POST /assets/{id}/snapshots
+func (h *SnapshotHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Name       string     `json:"name"`
+		RecordDate *time.Time `json:"record_date,omitempty"` // RFC 3339
+		Slot       *int64     `json:"slot,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := h.Service.CreateSnapshot(chi.URLParam(r, "id"), requestBody.Name, requestBody.RecordDate, requestBody.Slot)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// GetSnapshotsByAssetID lists the snapshots of an asset.
+// GET /assets/{id}/snapshots
+func (h *SnapshotHandler) GetSnapshotsByAssetID(w http.ResponseWriter, r *http.Request) {
+	assetID := chi.URLParam(r, "id")
+	if assetID == "" {
+		http.Error(w, "Asset ID is required", http.StatusBadRequest)
+		return
+	}
+
+	snapshots, err := h.Service.DB.GetSnapshotsByAssetID(assetID)
+	if err != nil {
+		http.Error(w, "Error fetching snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// GetSnapshotByID retrieves a snapshot with its holdings.
+// GET /snapshots/{id}
+func (h *SnapshotHandler) GetSnapshotByID(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.Service.GetSnapshot(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// ExportSnapshot downloads the holdings of a snapshot as CSV.
+// GET /snapshots/{id}/export.csv
+func (h *SnapshotHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.Service.GetSnapshot(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshot.Name+".csv"))
+	if err := h.Service.ExportCSV(snapshot, w); err != nil {
+		log.Printf("Error writing CSV export for snapshot %s: %v", snapshot.ID, err)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TaxHandler handles HTTP requests related to cost basis, rate tables and tax reports.
+type TaxHandler struct {
+	Service *services.TaxService
+}
+
+// NewTaxHandler creates a new tax handler instance.
+func NewTaxHandler(s *services.TaxService) *TaxHandler {
+	return &TaxHandler{Service: s}
+}
+
+// CreateRateTable registers a withholding and capital gains rate table
+// effective from a given instant.
+// POST /tax/rate-tables
+func (h *TaxHandler) CreateRateTable(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateRateTableInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	table, err := h.Service.CreateRateTable(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(table)
+}
+
+// GetRateTables lists the configured rate tables.
+// GET /tax/rate-tables
+func (h *TaxHandler) GetRateTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.Service.DB.GetTaxRateTables()
+	if err != nil {
+		http.Error(w, "Error fetching rate tables", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tables)
+}
+
+// AddTaxLot declares an acquisition made outside the platform.
+// POST /users/{id}/tax-lots
+func (h *TaxHandler) AddTaxLot(w http.ResponseWriter, r *http.Request) {
+	var input services.AddLotInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.OwnerID = chi.URLParam(r, "id")
+
+	lot, err := h.Service.AddLot(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(lot)
+}
+
+// GetTaxLots lists a user's acquisition lots with their remaining cost basis.
+// GET /users/{id}/tax-lots
+func (h *TaxHandler) GetTaxLots(w http.ResponseWriter, r *http.Request) {
+	lots, err := h.Service.DB.GetTaxLotsByOwnerID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching tax lots", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lots)
+}
+
+// GetMonthlyReport returns a user's realized gains, withholding and tax due
+// for a month (YYYY-MM).
+// GET /users/{id}/tax-reports/{month}
+func (h *TaxHandler) GetMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.GetMonthlyReport(chi.URLParam(r, "id"), chi.URLParam(r, "month"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
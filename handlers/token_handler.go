@@ -2,13 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt" // For fmt.Errorf
 	"net/http"
 
 	"github.com/ferreirogomes/tiquin/services"
 	"github.com/go-chi/chi/v5"
-
-	"github.com/gagliardetto/solana-go" // For PublicKey
 )
 
 type TokenHandler struct {
@@ -25,12 +22,14 @@ type PrepareTransferRequest struct {
 	FromUserID string  `json:"from_user_id"`
 	ToUserID   string  `json:"to_user_id"`
 	Amount     float64 `json:"amount"`
+	Reference  *string `json:"reference,omitempty"` // Optional external reference, carried in the transaction memo
 }
 
 // Response struct for transfer preparation
 type PrepareTransferResponse struct {
-	SerializedTransaction string `json:"serialized_transaction"` // Transaction in Base64 for signing
-	DestinationATA        string `json:"destination_ata"`        // Destination ATA address
+	SerializedTransaction string `json:"serialized_transaction"` // Base64 on Solana, unsigned hex EIP-1559 transaction on EVM
+	DestinationATA        string `json:"destination_ata"`        // Destination ATA, or the recipient's address on EVM
+	IntentID              string `json:"intent_id"`              // Passed back to complete the transfer; referenced in the transaction memo on Solana
 }
 
 // PrepareTransfer prepares a transfer transaction for user signing.
@@ -42,17 +41,18 @@ func (h *TokenHandler) PrepareTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	serializedTx, destATA, err := h.Service.PrepareTransferTokenFromUser(
-		req.AssetID, req.FromUserID, req.ToUserID, req.Amount,
+	prepared, err := h.Service.PrepareTransferTokenFromUser(
+		req.AssetID, req.FromUserID, req.ToUserID, req.Amount, req.Reference,
 	)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
 	resp := PrepareTransferResponse{
-		SerializedTransaction: serializedTx,
-		DestinationATA:        destATA.String(),
+		SerializedTransaction: prepared.SerializedTransaction,
+		DestinationATA:        prepared.Destination,
+		IntentID:              prepared.IntentID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -61,15 +61,17 @@ func (h *TokenHandler) PrepareTransfer(w http.ResponseWriter, r *http.Request) {
 
 // Request struct for completing the transfer
 type CompleteTransferRequest struct {
-	AssetID           string  `json:"asset_id"`
-	FromUserID        string  `json:"from_user_id"`
-	ToUserID          string  `json:"to_user_id"`
-	Amount            float64 `json:"amount"`
-	SignedTransaction string  `json:"signed_transaction"` // Transaction signed by the user (Base64)
-	DestinationATA    string  `json:"destination_ata"`    // Destination ATA, passed back by the frontend
+	AssetID           string   `json:"asset_id"`
+	FromUserID        string   `json:"from_user_id"`
+	ToUserID          string   `json:"to_user_id"`
+	Amount            float64  `json:"amount"`
+	IntentID          string   `json:"intent_id"`                // Returned by the prepare step; empty for custodial users
+	SignedTransaction string   `json:"signed_transaction"`       // Prepared transaction signed by the user (Base64, or raw hex on EVM); empty for custodial users
+	PricePerUnit      *float64 `json:"price_per_unit,omitempty"` // Sale price, when the transfer is a sale
 }
 
-// CompleteTransfer sends the signed transfer transaction to Solana.
+// CompleteTransfer sends the signed transfer transaction to the asset's chain.
+// Transfers from custodial users are prepared and signed by the backend in this step.
 // POST /tokens/transfer/complete
 func (h *TokenHandler) CompleteTransfer(w http.ResponseWriter, r *http.Request) {
 	var req CompleteTransferRequest
@@ -78,17 +80,11 @@ func (h *TokenHandler) CompleteTransfer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	destATA, err := solana.PublicKeyFromBase58(req.DestinationATA)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid destination ATA address: %v", err), http.StatusBadRequest)
-		return
-	}
-
 	token, err := h.Service.CompleteTransferTokenFromUser(
-		req.AssetID, req.FromUserID, req.ToUserID, req.Amount, req.SignedTransaction, destATA,
+		req.AssetID, req.FromUserID, req.ToUserID, req.Amount, req.IntentID, req.SignedTransaction, req.PricePerUnit,
 	)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
@@ -19,20 +20,29 @@ type UserHandler struct {
 	DB      *storage.DB
 	SolanaS *services.SolanaIntegrationService
 	TokenS  *services.TokenizationService
+	UserS   *services.UserService
 }
 
 // NewUserHandler creates a new user handler instance.
-func NewUserHandler(db *storage.DB, solanaS *services.SolanaIntegrationService, tokenS *services.TokenizationService) *UserHandler {
-	return &UserHandler{DB: db, SolanaS: solanaS, TokenS: tokenS}
+func NewUserHandler(
+	db *storage.DB, solanaS *services.SolanaIntegrationService, tokenS *services.TokenizationService, userS *services.UserService,
+) *UserHandler {
+	return &UserHandler{DB: db, SolanaS: solanaS, TokenS: tokenS, UserS: userS}
 }
 
 // CreateUser creates a new user.
 // POST /users
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var requestBody struct {
-		Name         *string `json:"name,omitempty"`
-		Email        *string `json:"email,omitempty"`
-		SolanaPubKey string  `json:"solana_pub_key"`
+		Name             *string `json:"name,omitempty"`
+		Email            *string `json:"email,omitempty"`
+		SolanaPubKey     string  `json:"solana_pub_key"`
+		Custody          string  `json:"custody,omitempty"`     // "self" (default) or "custodial"
+		EVMAddress       *string `json:"evm_address,omitempty"` // Wallet for EVM-issued assets
+		TaxID            *string `json:"tax_id,omitempty"`      // CPF or CNPJ
+		Jurisdiction     *string `json:"jurisdiction,omitempty"`
+		InvestorCategory *string `json:"investor_category,omitempty"`
+		AuthorizationKey string  `json:"authorization_key,omitempty"` // Custodial only: user-held key approving wallet exports
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -40,10 +50,30 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if requestBody.SolanaPubKey == "" {
+	custodial := requestBody.Custody == models.CustodyCustodial
+	switch {
+	case requestBody.Custody != "" && requestBody.Custody != models.CustodySelf && !custodial:
+		http.Error(w, "custody must be self or custodial", http.StatusBadRequest)
+		return
+	case custodial && (requestBody.SolanaPubKey != "" || requestBody.EVMAddress != nil):
+		http.Error(w, "custodial users get their wallet from the backend; omit solana_pub_key and evm_address", http.StatusBadRequest)
+		return
+	case !custodial && requestBody.SolanaPubKey == "":
 		http.Error(w, "solana_pub_key is required in Web3 standard", http.StatusBadRequest)
 		return
 	}
+	profile := models.User{
+		Name:             requestBody.Name,
+		Email:            requestBody.Email,
+		EVMAddress:       requestBody.EVMAddress,
+		TaxID:            requestBody.TaxID,
+		Jurisdiction:     requestBody.Jurisdiction,
+		InvestorCategory: requestBody.InvestorCategory,
+	}
+	if err := services.NormalizeUserProfile(&profile); err != nil {
+		writeServiceError(w, err)
+		return
+	}
 
 	// Verificar se usuário já existe
 	existingUser, found, err := h.DB.GetUserBySolanaPubKey(requestBody.SolanaPubKey)
@@ -51,7 +81,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error checking existing user", http.StatusInternalServerError)
 		return
 	}
-	if found {
+	if found && !custodial {
 		// In Web3, "Login" means connecting the wallet. If already exists, return the existing user.
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -59,20 +89,36 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := models.User{
-		ID:           uuid.New().String(),
-		Name:         requestBody.Name,
-		Email:        requestBody.Email,
-		SolanaPubKey: requestBody.SolanaPubKey,
-		CreatedAt:    time.Now(),
-	}
-
-	err = h.DB.SaveUser(user)
-	if err != nil {
+	user := profile
+	user.ID = uuid.New().String()
+	user.SolanaPubKey = requestBody.SolanaPubKey
+	user.Custody = models.CustodySelf
+	user.KYCStatus = models.KYCStatusUnverified
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	if custodial {
+		// The backend generates and keeps the user's keys
+		user, err = h.TokenS.Custody.CreateCustodialUser(user, requestBody.AuthorizationKey)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	} else if err = h.DB.SaveUser(user); err != nil {
+		if storage.IsUniqueViolation(err) {
+			writeServiceError(w, services.ErrUserTaken)
+			return
+		}
 		http.Error(w, "Error saving user to database", http.StatusInternalServerError)
 		return
 	}
 
+	// Screening never blocks onboarding; hits land in the review queue and
+	// block the user's movements until an analyst dismisses them.
+	if _, err := h.TokenS.AML.ScreenParty("user_created", nil, user); err != nil {
+		log.Printf("WARNING: failed to screen new user %s: %v", user.ID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
@@ -101,6 +147,57 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// UpdateUser corrects a user's profile. Omitted fields are left as they are
+// and an empty string clears a field.
+// PATCH /users/{id}
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var input services.UpdateUserInput
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserS.UpdateUser(chi.URLParam(r, "id"), input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ExportUserData returns everything held about a user (LGPD right of access).
+// GET /users/{id}/export
+func (h *UserHandler) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	export, err := h.UserS.ExportUserData(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="user-`+export.User.ID+`.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// EraseUser pseudonymizes a user's personal data (LGPD right of deletion),
+// keeping the records regulation requires to retain.
+// POST /users/{id}/erasure
+func (h *UserHandler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UserS.EraseUser(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
 // GetUserTokens retrieves all tokens for a user.
 // GET /users/{id}/tokens
 func (h *UserHandler) GetUserTokens(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// VestingHandler handles HTTP requests related to lock-ups and vesting schedules.
+type VestingHandler struct {
+	Service *services.VestingService
+}
+
+// NewVestingHandler creates a new vesting handler instance.
+func NewVestingHandler(s *services.VestingService) *VestingHandler {
+	return &VestingHandler{Service: s}
+}
+
+// CreateVestingSchedule attaches a lock-up or vesting schedule to a holder's allocation.
+// POST /assets/{id}/vesting-schedules
+func (h *VestingHandler) CreateVestingSchedule(w http.ResponseWriter, r *http.Request) {
+	var input services.CreateVestingScheduleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.AssetID = chi.URLParam(r, "id")
+
+	schedule, err := h.Service.CreateSchedule(input)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// GetVestingSchedulesByAssetID lists the vesting schedules of an asset.
+// GET /assets/{id}/vesting-schedules
+func (h *VestingHandler) GetVestingSchedulesByAssetID(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.Service.DB.GetVestingSchedulesByAssetID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Error fetching vesting schedules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// RevokeVestingSchedule lifts a schedule, releasing what it still locks.
+// POST /vesting-schedules/{id}/revoke
+func (h *VestingHandler) RevokeVestingSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.Service.RevokeSchedule(chi.URLParam(r, "id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// GetUserBalances returns a user's holdings split into locked and available.
+// An optional as_of query parameter (RFC 3339) projects the split to that instant.
+// GET /users/{id}/balances
+func (h *VestingHandler) GetUserBalances(w http.ResponseWriter, r *http.Request) {
+	at := time.Now()
+	if raw := r.URL.Query().Get("as_of"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "as_of must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+
+	balances, err := h.Service.GetBalances(chi.URLParam(r, "id"), at)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balances)
+}
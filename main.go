@@ -13,6 +13,7 @@ import (
 	"github.com/ferreirogomes/tiquin/blockchain_listener"
 	"github.com/ferreirogomes/tiquin/handlers"
 	apimiddleware "github.com/ferreirogomes/tiquin/middleware"
+	"github.com/ferreirogomes/tiquin/models"
 	"github.com/ferreirogomes/tiquin/services"
 	"github.com/ferreirogomes/tiquin/storage"
 
@@ -24,39 +25,270 @@ func main() {
 	dataSourceName := os.Getenv("DB_CONNECTION_STRING")
 	solanaRPCURL := os.Getenv("SOLANA_RPC_URL")
 	solanaFeePayerPrivateKey := os.Getenv("SOLANA_FEE_PAYER_PRIVATE_KEY")
+	kycWebhookSecret := os.Getenv("KYC_WEBHOOK_SECRET")
+	evmRPCURL := os.Getenv("EVM_RPC_URL")
+	evmPrivateKey := os.Getenv("EVM_PRIVATE_KEY")
+	publicBaseURL := os.Getenv("PUBLIC_BASE_URL")
+	documentStorageDir := os.Getenv("DOCUMENT_STORAGE_DIR")
+	custodyMasterKey := os.Getenv("CUSTODY_MASTER_KEY")
+	fieldEncryptionKeys := os.Getenv("FIELD_ENCRYPTION_KEYS")
+	fieldIndexKey := os.Getenv("FIELD_INDEX_KEY")
+	taxRegime := os.Getenv("TAX_REGIME")
+	if documentStorageDir == "" {
+		documentStorageDir = "data/documents"
+	}
 
-	db, err := storage.NewDB(dataSourceName)
+	fieldKeys, err := storage.NewStaticKeyProvider(fieldEncryptionKeys, fieldIndexKey)
+	if err != nil {
+		log.Fatalf("Fatal error loading the field encryption keys: %v", err)
+	}
+	db, err := storage.NewDB(dataSourceName, fieldKeys)
 	if err != nil {
 		log.Fatalf("Fatal error connecting to database and applying migrations: %v", err)
 	}
 	defer db.Close()
 
+	// "rotate-field-keys" re-encrypts personal data with the current key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-field-keys" {
+		rotated, err := db.RotateFieldKeys()
+		if err != nil {
+			log.Fatalf("Field key rotation stopped after %d rows: %v", rotated, err)
+		}
+		log.Printf("Field key rotation re-encrypted %d rows with key %s.", rotated, fieldKeys.CurrentKeyVersion())
+		return
+	}
+
+	// Personal data written before encryption must be sealed and indexed
+	// before lookups by blind index can be served
+	sealed, err := db.SealLegacyFields()
+	if err != nil {
+		log.Fatalf("Refusing to start with personal data not sealed after %d rows: %v", sealed, err)
+	}
+	if sealed > 0 {
+		log.Printf("Sealed %d rows of personal data written before encryption.", sealed)
+	}
+
 	solanaIntegrationService := services.NewSolanaIntegrationService(solanaRPCURL, solanaFeePayerPrivateKey)
 	tokenizationService := services.NewTokenizationService(db, solanaIntegrationService)
+	tokenizationService.MetadataBaseURL = publicBaseURL
+	if evmRPCURL != "" {
+		tokenizationService.EVM, err = services.NewEVMIntegrationService(evmRPCURL, evmPrivateKey)
+		if err != nil {
+			log.Fatalf("Fatal error connecting to the EVM chain: %v", err)
+		}
+	}
+	if taxRegime != "" {
+		if !services.IsTaxRegime(taxRegime) {
+			log.Fatalf("Fatal error: TAX_REGIME must be %s or %s", models.TaxRegimeExchange, models.TaxRegimeCapitalGains)
+		}
+		tokenizationService.Tax.Regime = taxRegime
+	}
+	tokenizationService.Custody, err = services.NewCustodyService(db, custodyMasterKey)
+	if err != nil {
+		log.Fatalf("Fatal error loading the custody master key: %v", err)
+	}
+	snapshotService := services.NewSnapshotService(db)
+	distributionService := services.NewDistributionService(db, solanaIntegrationService, snapshotService)
+	votingService := services.NewVotingService(db, solanaIntegrationService, snapshotService)
+	corporateActionService := services.NewCorporateActionService(db, solanaIntegrationService, snapshotService)
+	marketService := services.NewMarketService(db, solanaIntegrationService, tokenizationService)
+	dvpService := services.NewDvPService(db, solanaIntegrationService, tokenizationService)
+	offeringService := services.NewOfferingService(db, solanaIntegrationService, tokenizationService)
+	escrowService := services.NewEscrowService(db, solanaIntegrationService, tokenizationService)
+	bridgeService := services.NewBridgeService(db, solanaIntegrationService, tokenizationService)
+	batchTransferService := services.NewBatchTransferService(db, solanaIntegrationService, tokenizationService)
+	documentStore, err := services.NewLocalDocumentStore(documentStorageDir)
+	if err != nil {
+		log.Fatalf("Fatal error opening document storage: %v", err)
+	}
+	documentService := services.NewDocumentService(db, solanaIntegrationService, documentStore)
+	userService := services.NewUserService(db, tokenizationService.AML)
+	kycService := services.NewKYCService(db, &services.StubKYCProvider{WebhookSecret: kycWebhookSecret})
 
 	assetHandler := handlers.NewAssetHandler(tokenizationService)
 	tokenHandler := handlers.NewTokenHandler(tokenizationService)
-	userHandler := handlers.NewUserHandler(db, solanaIntegrationService, tokenizationService)
+	userHandler := handlers.NewUserHandler(db, solanaIntegrationService, tokenizationService, userService)
+	snapshotHandler := handlers.NewSnapshotHandler(snapshotService)
+	distributionHandler := handlers.NewDistributionHandler(distributionService)
+	proposalHandler := handlers.NewProposalHandler(votingService)
+	corporateActionHandler := handlers.NewCorporateActionHandler(corporateActionService)
+	kycHandler := handlers.NewKYCHandler(kycService)
+	amlHandler := handlers.NewAMLHandler(tokenizationService.AML)
+	vestingHandler := handlers.NewVestingHandler(tokenizationService.Vesting)
+	taxHandler := handlers.NewTaxHandler(tokenizationService.Tax)
+	marketHandler := handlers.NewMarketHandler(marketService)
+	dvpHandler := handlers.NewDvPHandler(dvpService)
+	offeringHandler := handlers.NewOfferingHandler(offeringService)
+	escrowHandler := handlers.NewEscrowHandler(escrowService)
+	bridgeHandler := handlers.NewBridgeHandler(bridgeService)
+	batchTransferHandler := handlers.NewBatchTransferHandler(batchTransferService)
+	documentHandler := handlers.NewDocumentHandler(documentService)
+	referenceHandler := handlers.NewReferenceHandler(db)
+	custodyHandler := handlers.NewCustodyHandler(tokenizationService.Custody)
 
 	// Initialize and start the blockchain listener in a separate goroutine
 	listener := blockchain_listener.NewBlockchainListener(solanaRPCURL, db, solanaFeePayerPrivateKey)
 	go listener.StartListening()
 	log.Println("Blockchain listener started.")
 
+	// Execute splits and reverse splits once their effective date passes
+	go corporateActionService.StartScheduler()
+	log.Println("Corporate action scheduler started.")
+
+	// Close offerings once their subscription period ends
+	go offeringService.StartScheduler()
+	log.Println("Offering scheduler started.")
+
+	// Settle escrows whose condition resolved or which expired
+	go escrowService.StartScheduler()
+	log.Println("Escrow scheduler started.")
+
+	// Mirror bridge locks between Solana and the EVM chain
+	go bridgeService.StartScheduler()
+	log.Println("Bridge scheduler started.")
+
+	// Finish transfer batches interrupted by a restart
+	batchTransferService.ResumeInterrupted()
+
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
 
-	// P4: Apply API key authentication to all routes
-	authMiddleware := apimiddleware.APIKeyAuth(db.DB)
+	// P4: Apply API key authentication to all routes; provider webhooks are signed instead
+	// and token metadata is fetched by wallets
+	authMiddleware := apimiddleware.APIKeyAuth(db.DB, "/kyc/webhooks/", "/assets/*/metadata.json")
 	r.Use(authMiddleware)
 
 	r.Route("/assets", func(r chi.Router) {
 		r.Post("/", assetHandler.CreateAsset)
+		r.Get("/", assetHandler.ListAssets)
 		r.Get("/{id}", assetHandler.GetAssetByID)
+		r.Patch("/{id}", assetHandler.UpdateAsset)
+		// URLFormat strips the ".json" extension before routing
+		r.Get("/{id}/metadata", assetHandler.GetTokenMetadata)
+		r.Get("/{id}/cap-table", assetHandler.GetCapTable)
+		r.Get("/{id}/compliance-rules", assetHandler.GetComplianceRules)
+		r.Put("/{id}/compliance-rules", assetHandler.SetComplianceRules)
+		r.Post("/{id}/transitions", assetHandler.TransitionAsset)
+		r.Get("/{id}/transitions", assetHandler.GetAssetTransitions)
+		r.Post("/{id}/snapshots", snapshotHandler.CreateSnapshot)
+		r.Get("/{id}/snapshots", snapshotHandler.GetSnapshotsByAssetID)
+		r.Post("/{id}/distributions", distributionHandler.CreateDistribution)
+		r.Get("/{id}/distributions", distributionHandler.GetDistributionsByAssetID)
+		r.Post("/{id}/proposals", proposalHandler.CreateProposal)
+		r.Get("/{id}/proposals", proposalHandler.GetProposalsByAssetID)
+		r.Post("/{id}/corporate-actions", corporateActionHandler.CreateCorporateAction)
+		r.Get("/{id}/corporate-actions", corporateActionHandler.GetCorporateActionsByAssetID)
+		r.Post("/{id}/vesting-schedules", vestingHandler.CreateVestingSchedule)
+		r.Get("/{id}/vesting-schedules", vestingHandler.GetVestingSchedulesByAssetID)
+		r.Put("/{id}/market", marketHandler.ConfigureMarket)
+		r.Get("/{id}/market", marketHandler.GetMarket)
+		r.Post("/{id}/orders", marketHandler.PlaceOrder)
+		r.Get("/{id}/orders", marketHandler.GetOrdersByAssetID)
+		r.Get("/{id}/order-book", marketHandler.GetOrderBook)
+		r.Get("/{id}/trades", marketHandler.GetTradesByAssetID)
+		r.Get("/{id}/dvp", dvpHandler.GetSettlementsByAssetID)
+		r.Post("/{id}/offerings", offeringHandler.CreateOffering)
+		r.Get("/{id}/offerings", offeringHandler.GetOfferingsByAssetID)
+		r.Post("/{id}/documents", documentHandler.UploadDocument)
+		r.Get("/{id}/documents", documentHandler.GetDocumentsByAssetID)
+		r.Post("/{id}/bridge", bridgeHandler.CreateBridge)
+		r.Get("/{id}/bridge", bridgeHandler.GetBridge)
+		r.Get("/{id}/bridge/transfers", bridgeHandler.GetBridgeTransfersByAssetID)
+		r.Post("/{id}/transfer-batches", batchTransferHandler.CreateTransferBatch)
+		r.Get("/{id}/transfer-batches", batchTransferHandler.GetTransferBatchesByAssetID)
+	})
+
+	r.Route("/snapshots", func(r chi.Router) {
+		r.Get("/{id}", snapshotHandler.GetSnapshotByID)
+		// URLFormat strips the ".csv" extension before routing
+		r.Get("/{id}/export", snapshotHandler.ExportSnapshot)
+	})
+
+	r.Route("/distributions", func(r chi.Router) {
+		r.Get("/{id}", distributionHandler.GetDistributionByID)
+		r.Post("/{id}/execute", distributionHandler.ExecuteDistribution)
 	})
 
+	r.Route("/proposals", func(r chi.Router) {
+		r.Get("/{id}", proposalHandler.GetProposalByID)
+		r.Post("/{id}/ballots", proposalHandler.IssueBallot)
+		r.Post("/{id}/votes", proposalHandler.CastVote)
+		r.Get("/{id}/results", proposalHandler.GetResults)
+		r.Post("/{id}/finalize", proposalHandler.FinalizeProposal)
+	})
+
+	r.Route("/corporate-actions", func(r chi.Router) {
+		r.Get("/{id}", corporateActionHandler.GetCorporateActionByID)
+		r.Post("/{id}/execute", corporateActionHandler.ExecuteCorporateAction)
+		r.Post("/{id}/approvals/prepare", corporateActionHandler.PrepareApproval)
+		r.Post("/{id}/approvals/complete", corporateActionHandler.CompleteApproval)
+	})
+
+	r.Route("/vesting-schedules", func(r chi.Router) {
+		r.Post("/{id}/revoke", vestingHandler.RevokeVestingSchedule)
+	})
+
+	r.Route("/orders", func(r chi.Router) {
+		r.Get("/{id}", marketHandler.GetOrderByID)
+		r.Post("/{id}/activate", marketHandler.ActivateOrder)
+		r.Post("/{id}/cancel", marketHandler.CancelOrder)
+	})
+
+	r.Post("/trades/{id}/reconcile", marketHandler.ReconcileTrade)
+
+	r.Route("/dvp", func(r chi.Router) {
+		r.Post("/", dvpHandler.CreateSettlement)
+		r.Get("/{id}", dvpHandler.GetSettlementByID)
+		r.Post("/{id}/signatures", dvpHandler.AddSignature)
+		r.Post("/{id}/reconcile", dvpHandler.ReconcileSettlement)
+	})
+
+	r.Route("/offerings", func(r chi.Router) {
+		r.Get("/{id}", offeringHandler.GetOfferingByID)
+		r.Post("/{id}/close", offeringHandler.CloseOffering)
+		r.Post("/{id}/subscriptions", offeringHandler.Subscribe)
+		r.Get("/{id}/subscriptions", offeringHandler.GetSubscriptionsByOfferingID)
+	})
+
+	r.Route("/subscriptions", func(r chi.Router) {
+		r.Get("/{id}", offeringHandler.GetSubscriptionByID)
+		r.Post("/{id}/payment", offeringHandler.ConfirmPayment)
+		r.Post("/{id}/cancel", offeringHandler.CancelSubscription)
+	})
+
+	r.Route("/escrows", func(r chi.Router) {
+		r.Post("/", escrowHandler.CreateEscrow)
+		r.Get("/{id}", escrowHandler.GetEscrowByID)
+		r.Post("/{id}/deposit/prepare", escrowHandler.PrepareDeposit)
+		r.Post("/{id}/deposit", escrowHandler.Deposit)
+		r.Post("/{id}/release", escrowHandler.ReleaseEscrow)
+		r.Post("/{id}/refund", escrowHandler.RefundEscrow)
+	})
+
+	r.Route("/documents", func(r chi.Router) {
+		r.Get("/{id}", documentHandler.GetDocumentByID)
+		r.Get("/{id}/content", documentHandler.DownloadDocument)
+		r.Post("/{id}/anchor", documentHandler.AnchorDocument)
+		r.Post("/{id}/verify", documentHandler.VerifyDocument)
+	})
+
+	r.Route("/bridge-transfers", func(r chi.Router) {
+		r.Get("/", bridgeHandler.GetBridgeTransfersBySourceTx)
+		r.Get("/{id}", bridgeHandler.GetBridgeTransferByID)
+		r.Post("/{id}/refund", bridgeHandler.RefundBridgeTransfer)
+	})
+
+	r.Route("/transfer-batches", func(r chi.Router) {
+		r.Get("/{id}", batchTransferHandler.GetTransferBatchByID)
+		r.Get("/{id}/rows", batchTransferHandler.GetTransferBatchRows)
+		r.Post("/{id}/execute", batchTransferHandler.ExecuteTransferBatch)
+	})
+
+	r.Get("/transactions/{id}/references", referenceHandler.GetTransactionReferences)
+	r.Get("/transaction-references", referenceHandler.FindTransactions)
+
 	r.Route("/tokens", func(r chi.Router) {
 		r.Post("/transfer/prepare", tokenHandler.PrepareTransfer)
 		r.Post("/transfer/complete", tokenHandler.CompleteTransfer)
@@ -67,7 +299,37 @@ func main() {
 	r.Route("/users", func(r chi.Router) {
 		r.Post("/", userHandler.CreateUser)
 		r.Get("/{id}", userHandler.GetUserByID)
+		r.Patch("/{id}", userHandler.UpdateUser)
+		r.Get("/{id}/export", userHandler.ExportUserData)
+		r.Post("/{id}/erasure", userHandler.EraseUser)
 		r.Get("/{id}/tokens", userHandler.GetUserTokens)
+		r.Get("/{id}/balances", vestingHandler.GetUserBalances)
+		r.Get("/{id}/kyc", kycHandler.GetKYCProfile)
+		r.Post("/{id}/kyc/verifications", kycHandler.StartVerification)
+		r.Post("/{id}/kyc/documents", kycHandler.AddDocument)
+		r.Post("/{id}/tax-lots", taxHandler.AddTaxLot)
+		r.Get("/{id}/tax-lots", taxHandler.GetTaxLots)
+		r.Get("/{id}/tax-reports/{month}", taxHandler.GetMonthlyReport)
+		r.Get("/{id}/escrows", escrowHandler.GetEscrowsByUserID)
+		r.Get("/{id}/wallet", custodyHandler.GetWallet)
+		r.Put("/{id}/wallet/limits", custodyHandler.SetWalletLimits)
+		r.Post("/{id}/wallet/export/challenge", custodyHandler.RequestWalletExport)
+		r.Post("/{id}/wallet/export", custodyHandler.ExportWallet)
+		r.Post("/{id}/wallet/export/confirm", custodyHandler.ConfirmWalletExport)
+	})
+
+	r.Route("/tax", func(r chi.Router) {
+		r.Post("/rate-tables", taxHandler.CreateRateTable)
+		r.Get("/rate-tables", taxHandler.GetRateTables)
+	})
+
+	r.Post("/kyc/webhooks/{provider}", kycHandler.Webhook)
+
+	r.Route("/aml", func(r chi.Router) {
+		r.Post("/watchlists/{list}/import", amlHandler.ImportWatchlist)
+		r.Get("/hits", amlHandler.GetHits)
+		r.Post("/hits/{id}/decision", amlHandler.DecideHit)
+		r.Get("/screenings", amlHandler.GetScreenings)
 	})
 
 	port := ":8080"
@@ -96,6 +358,10 @@ func main() {
 		// QW3: Stop the blockchain listener gracefully before server shuts down
 		log.Println("Stopping blockchain listener...")
 		listener.Stop()
+		corporateActionService.Stop()
+		offeringService.Stop()
+		escrowService.Stop()
+		bridgeService.Stop()
 
 		// Trigger graceful HTTP server shutdown
 		err := server.Shutdown(shutdownCtx)
@@ -5,16 +5,27 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
 
 // APIKeyAuth returns a middleware that validates API keys from the X-API-Key header.
-// Keys are stored as SHA-256 hashes in the api_keys table.
-func APIKeyAuth(db *sqlx.DB) func(http.Handler) http.Handler {
+// Keys are stored as SHA-256 hashes in the api_keys table. Requests under one of
+// the publicPrefixes (e.g., provider webhooks, which authenticate by signature)
+// are let through without a key. A prefix containing "*" is instead matched
+// against the whole path with path.Match, e.g., "/assets/*/metadata.json".
+func APIKeyAuth(db *sqlx.DB, publicPrefixes ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range publicPrefixes {
+				if isPublicPath(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
 			rawKey := r.Header.Get("X-API-Key")
 			if rawKey == "" {
 				// Also accept Bearer token format
@@ -58,6 +69,16 @@ func APIKeyAuth(db *sqlx.DB) func(http.Handler) http.Handler {
 	}
 }
 
+// isPublicPath reports whether a request path is covered by a public prefix
+// or pattern.
+func isPublicPath(requestPath, prefix string) bool {
+	if strings.Contains(prefix, "*") {
+		matched, _ := path.Match(prefix, requestPath)
+		return matched
+	}
+	return strings.HasPrefix(requestPath, prefix)
+}
+
 // hashAPIKey returns the SHA-256 hex hash of a raw API key string.
 func hashAPIKey(rawKey string) string {
 	h := sha256.Sum256([]byte(rawKey))
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Watchlist types.
+const (
+	WatchlistSanctions     = "sanctions"
+	WatchlistPEP           = "pep" // Politically exposed persons
+	WatchlistBlockedWallet = "blocked_wallet"
+)
+
+// Ways a screened party can match a watchlist entry.
+const (
+	MatchTypeName   = "name"
+	MatchTypeTaxID  = "tax_id"
+	MatchTypeWallet = "wallet"
+)
+
+// Screening hit statuses. Open and confirmed hits block movements; only an
+// analyst decision moves a hit out of open.
+const (
+	HitStatusOpen      = "open"
+	HitStatusConfirmed = "confirmed" // True match; the party stays blocked
+	HitStatusDismissed = "dismissed" // False positive
+)
+
+// Screening results.
+const (
+	ScreeningResultClear   = "clear"
+	ScreeningResultBlocked = "blocked"
+)
+
+// WatchlistEntry is one person, company or wallet on an imported list.
+type WatchlistEntry struct {
+	ID            string    `json:"id"`
+	ListName      string    `json:"list_name"` // e.g., "ofac-sdn", "un-consolidated", "coaf-pep"
+	ListType      string    `json:"list_type"`
+	Name          *string   `json:"name,omitempty"`
+	TaxID         *string   `json:"tax_id,omitempty"` // CPF or CNPJ, digits only
+	WalletAddress *string   `json:"wallet_address,omitempty"`
+	SourceRef     *string   `json:"source_ref,omitempty"` // ID of the entry in the source list
+	Fingerprint   string    `json:"fingerprint"`          // Stable across re-imports, so hits keep their decisions
+	ImportedAt    time.Time `json:"imported_at"`
+}
+
+// Screening is the audit record of one party being screened, at user
+// creation or for a movement.
+type Screening struct {
+	ID           string    `json:"id"`
+	Context      string    `json:"context"`             // "user_created", "transfer", "mint"...
+	Reference    *string   `json:"reference,omitempty"` // e.g., the asset of the movement
+	UserID       *string   `json:"user_id,omitempty"`
+	SolanaPubKey string    `json:"solana_pub_key"`
+	Result       string    `json:"result"`
+	HitCount     int       `json:"hit_count"` // Open or confirmed hits at screening time
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ScreeningHit is a potential match between a party and a watchlist entry,
+// waiting for or carrying an analyst decision.
+type ScreeningHit struct {
+	ID           string     `json:"id"`
+	EntryID      string     `json:"entry_id"`
+	UserID       *string    `json:"user_id,omitempty"`
+	SolanaPubKey string     `json:"solana_pub_key"`
+	MatchType    string     `json:"match_type"`
+	Score        float64    `json:"score"` // 1 for exact matches, name similarity otherwise
+	Status       string     `json:"status"`
+	DecidedBy    *string    `json:"decided_by,omitempty"`
+	DecisionNote *string    `json:"decision_note,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Entry *WatchlistEntry `json:"entry,omitempty"` // Filled for the review queue
+}
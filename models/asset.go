@@ -1,13 +1,108 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Chains an asset can be issued on.
+const (
+	ChainSolana = "solana" // SPL token
+	ChainEVM    = "evm"    // Permissioned ERC-20 contract, e.g., on Hyperledger Besu
+)
+
+// Classes an asset can belong to.
+const (
+	AssetClassEquity     = "equity"      // Shares
+	AssetClassDebt       = "debt"        // Bonds, debentures, notes
+	AssetClassFund       = "fund"        // Fund quotas
+	AssetClassReceivable = "receivable"  // Receivables, e.g., FIDC or CRI/CRA underlying
+	AssetClassRealEstate = "real_estate" // Real estate and real estate rights
+	AssetClassOther      = "other"
+)
+
+// Lifecycle states of an asset.
+const (
+	AssetStatusDraft     = "draft"     // Being set up: tokens may be minted but not transferred
+	AssetStatusIssued    = "issued"    // Primary distribution: tokens may be minted but not transferred
+	AssetStatusActive    = "active"    // Freely transferable, subject to the compliance rules
+	AssetStatusSuspended = "suspended" // Trading halt: no transfers or mints, holder accounts frozen
+	AssetStatusMatured   = "matured"   // Past maturity: no transfers or mints
+	AssetStatusRetired   = "retired"   // Terminal: no transfers or mints
+)
 
 // Asset represents a traditional share that will be tokenized.
 type Asset struct {
-	ID          string    `json:"id"`
-	Symbol      string    `json:"symbol"`       // e.g., "AAPL", "PETR4"
-	Name        string    `json:"name"`         // e.g., "Apple Inc.", "Petrobras S.A."
-	TotalShares float64   `json:"total_shares"` // Total number of shares in existence
-	MintAddress string    `json:"mint_address,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string     `json:"id"`
+	Symbol      string     `json:"symbol"`                 // e.g., "AAPL", "PETR4"
+	Name        string     `json:"name"`                   // e.g., "Apple Inc.", "Petrobras S.A."
+	TotalShares float64    `json:"total_shares"`           // Total number of shares in existence
+	Chain       string     `json:"chain"`                  // One of the Chain* constants
+	MintAddress string     `json:"mint_address,omitempty"` // SPL mint, or token contract address on EVM
+	Issuer      *string    `json:"issuer,omitempty"`       // Legal name of the issuer
+	IssuerCNPJ  *string    `json:"issuer_cnpj,omitempty"`  // 14 characters, without punctuation
+	ISIN        *string    `json:"isin,omitempty"`         // e.g., "BRPETRACNPR6"
+	AssetClass  *string    `json:"asset_class,omitempty"`  // One of the AssetClass* constants
+	Description *string    `json:"description,omitempty"`  // Published in the token metadata
+	Links       AssetLinks `json:"links"`                  // Public documents, published in the token metadata
+	Status      string     `json:"status"`                 // One of the AssetStatus* constants
+	Version     int        `json:"version"`                // Incremented on every update, served as the ETag
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AssetLink is a public document of an asset, such as its prospectus.
+type AssetLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// AssetLinks is stored as a JSONB array.
+type AssetLinks []AssetLink
+
+// Value stores the links as JSONB.
+func (l AssetLinks) Value() (driver.Value, error) {
+	if l == nil {
+		l = AssetLinks{}
+	}
+	return json.Marshal(l)
+}
+
+// Scan reads the links from a JSONB column.
+func (l *AssetLinks) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case nil:
+		*l = AssetLinks{}
+		return nil
+	default:
+		return errors.New("unsupported type for AssetLinks")
+	}
+}
+
+// Outcomes of freezing or thawing holder accounts after a status change.
+const (
+	FreezeStatusPending   = "pending"
+	FreezeStatusCompleted = "completed"
+	FreezeStatusFailed    = "failed"
+)
+
+// AssetStatusChange is one transition in the history of an asset's lifecycle.
+type AssetStatusChange struct {
+	ID               string    `json:"id"`
+	AssetID          string    `json:"asset_id"`
+	FromStatus       string    `json:"from_status"`
+	ToStatus         string    `json:"to_status"`
+	Reason           *string   `json:"reason,omitempty"`
+	OrderedBy        *string   `json:"ordered_by,omitempty"`    // e.g., the regulator that ordered a trading halt
+	FreezeStatus     *string   `json:"freeze_status,omitempty"` // Set when holder accounts are frozen or thawed on Solana
+	AccountsAffected int       `json:"accounts_affected"`       // Token accounts frozen or thawed
+	LastError        *string   `json:"last_error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
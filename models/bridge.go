@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Bridge transfer statuses.
+const (
+	BridgeTransferStatusPending   = "pending" // Lock observed, mirrored transfer not sent yet
+	BridgeTransferStatusSending   = "sending" // Mirrored transfer signed and recorded, not confirmed yet
+	BridgeTransferStatusCompleted = "completed"
+	BridgeTransferStatusRejected  = "rejected" // Tokens stay in custody for manual review
+	BridgeTransferStatusRefunded  = "refunded" // Rejected Solana lock released back to its sender
+)
+
+// Bridge links a Solana asset with a wrapped token on the EVM chain. Tokens
+// locked in the custody account on Solana are minted on the wrapped token,
+// and wrapped tokens sent back to the bridge are burned and released from
+// custody.
+type Bridge struct {
+	ID                  string    `json:"id"`
+	AssetID             string    `json:"asset_id"`
+	Mint                string    `json:"mint"`
+	CustodyAccount      string    `json:"custody_account"`                 // Solana token account holding locked tokens
+	EVMToken            string    `json:"evm_token"`                       // Wrapped token contract
+	EVMCustodyAddress   string    `json:"evm_custody_address"`             // Where holders send wrapped tokens to bridge them back
+	LastSolanaSignature *string   `json:"last_solana_signature,omitempty"` // Newest custody transaction scanned
+	LastEVMBlock        int64     `json:"last_evm_block"`                  // Newest EVM block scanned
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// BridgeTransfer is a lock observed on one chain and its mirrored mint or
+// release on the other. Each lock is recorded once, keyed by its source
+// transaction, and its mirror is recorded before it is sent so it is never
+// sent twice. A rejected Solana lock can be refunded: it then goes through
+// the same states, released from custody back to its sender.
+type BridgeTransfer struct {
+	ID                     string     `json:"id"`
+	BridgeID               string     `json:"bridge_id"`
+	AssetID                string     `json:"asset_id"`
+	SourceChain            string     `json:"source_chain"` // One of the Chain* constants
+	SourceTxID             string     `json:"source_tx_id"`
+	SourceIndex            int        `json:"source_index"` // Log index on EVM; 0 on Solana
+	Sender                 string     `json:"sender"`
+	Recipient              *string    `json:"recipient,omitempty"` // Address on the destination chain
+	UserID                 *string    `json:"user_id,omitempty"`
+	Amount                 float64    `json:"amount"`
+	Status                 string     `json:"status"`
+	Refund                 bool       `json:"refund"`                            // Mirrored back to the sender on the source chain instead
+	DestinationTransaction *string    `json:"destination_transaction,omitempty"` // Signed mirrored transfer, kept to rebroadcast it
+	DestinationTxID        *string    `json:"destination_tx_id,omitempty"`
+	LastError              *string    `json:"last_error,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+	CompletedAt            *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// CapTable is the ownership view of an asset, aggregated per holder.
+type CapTable struct {
+	AssetID           string          `json:"asset_id"`
+	Symbol            string          `json:"symbol"`
+	TotalShares       float64         `json:"total_shares"`
+	CirculatingSupply float64         `json:"circulating_supply"` // Sum of all holdings listed below
+	AsOf              *time.Time      `json:"as_of,omitempty"`    // Nil means current state
+	GeneratedAt       time.Time       `json:"generated_at"`
+	Holders           []CapTableEntry `json:"holders"`
+}
+
+// CapTableEntry is the aggregated holding of a single holder in a cap table.
+type CapTableEntry struct {
+	HolderID             string   `json:"holder_id,omitempty"` // Empty for unregistered on-chain holders
+	SolanaPubKey         string   `json:"solana_pub_key"`
+	Name                 *string  `json:"name,omitempty"`
+	Amount               float64  `json:"amount"`
+	OnChainAmount        *float64 `json:"on_chain_amount,omitempty"` // Only filled for current cap tables
+	PercentOfTotalShares float64  `json:"percent_of_total_shares"`
+	PercentOfCirculating float64  `json:"percent_of_circulating"`
+	Registered           bool     `json:"registered"` // False when the wallet is not a known user
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Investor categories, as defined by CVM Resolution 30.
+const (
+	InvestorCategoryRetail       = "retail"
+	InvestorCategoryQualified    = "qualified"
+	InvestorCategoryProfessional = "professional"
+)
+
+// ComplianceRules is the structured rule set enforced on an asset before any
+// transfer or mint is signed. Zero values disable the corresponding rule.
+type ComplianceRules struct {
+	AllowedJurisdictions []string        `json:"allowed_jurisdictions,omitempty"` // ISO 3166-1 alpha-2 codes of eligible recipients
+	InvestorCategories   []string        `json:"investor_categories,omitempty"`   // Eligible recipient investor categories
+	MaxHolders           int             `json:"max_holders,omitempty"`
+	MaxPercentPerHolder  float64         `json:"max_percent_per_holder,omitempty"` // Of total_shares, after the movement
+	MinTransferAmount    float64         `json:"min_transfer_amount,omitempty"`
+	TradingWindows       []TradingWindow `json:"trading_windows,omitempty"` // Transfers only allowed inside one of them
+	ExemptWallets        []string        `json:"exempt_wallets,omitempty"`  // e.g., the issuer treasury; skips recipient checks
+	RequireKYC           bool            `json:"require_kyc,omitempty"`     // Sender and recipient must hold a valid KYC verification
+	MinKYCLevel          int             `json:"min_kyc_level,omitempty"`
+}
+
+// TradingWindow is a recurring period, in a given time zone, in which
+// transfers are allowed.
+type TradingWindow struct {
+	Days     []string `json:"days"`               // "mon" ... "sun"; empty means every day
+	Start    string   `json:"start"`              // "HH:MM"
+	End      string   `json:"end"`                // "HH:MM", exclusive
+	Timezone string   `json:"timezone,omitempty"` // IANA name; defaults to America/Sao_Paulo
+}
+
+// Value stores the rules as JSONB.
+func (r ComplianceRules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan reads the rules from a JSONB column.
+func (r *ComplianceRules) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	case nil:
+		*r = ComplianceRules{}
+		return nil
+	default:
+		return errors.New("unsupported type for ComplianceRules")
+	}
+}
+
+// AssetRuleSet is the current, versioned rule set of an asset.
+type AssetRuleSet struct {
+	AssetID   string          `json:"asset_id"`
+	Rules     ComplianceRules `json:"rules"`
+	Version   int             `json:"version"` // Incremented on every update
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Corporate action types.
+const (
+	CorporateActionSplit        = "split"
+	CorporateActionReverseSplit = "reverse_split"
+)
+
+// Corporate action statuses.
+const (
+	CorporateActionStatusScheduled        = "scheduled"
+	CorporateActionStatusExecuting        = "executing"
+	CorporateActionStatusPartiallyApplied = "partially_applied" // Some adjustments pending or failed; can be re-executed
+	CorporateActionStatusCompleted        = "completed"
+)
+
+// Holding adjustment statuses.
+const (
+	AdjustmentStatusPending = "pending"
+	AdjustmentStatusSent    = "sent" // Sent to Solana, confirmation not yet observed
+	AdjustmentStatusApplied = "applied"
+	AdjustmentStatusFailed  = "failed"
+)
+
+// CorporateAction is a split or reverse split of an asset. Every holding is
+// multiplied by RatioTo/RatioFrom at the effective date (e.g. a 2-for-1 split
+// is RatioFrom=1, RatioTo=2; a 1-for-10 reverse split is RatioFrom=10, RatioTo=1).
+type CorporateAction struct {
+	ID              string              `json:"id"`
+	AssetID         string              `json:"asset_id"`
+	ActionType      string              `json:"action_type"`
+	RatioFrom       int64               `json:"ratio_from"`
+	RatioTo         int64               `json:"ratio_to"`
+	EffectiveDate   time.Time           `json:"effective_date"`
+	RoundingUnit    float64             `json:"rounding_unit"`      // Post-action holdings are rounded down to a multiple of this
+	CashInLieuPrice float64             `json:"cash_in_lieu_price"` // Paid per post-action share of fractional leftovers
+	Status          string              `json:"status"`
+	SnapshotID      *string             `json:"snapshot_id,omitempty"`   // Holdings the adjustments were computed from
+	SharesBefore    *float64            `json:"shares_before,omitempty"` // Asset total_shares before the action
+	SharesAfter     *float64            `json:"shares_after,omitempty"`  // Asset total_shares after the action
+	CreatedAt       time.Time           `json:"created_at"`
+	ExecutedAt      *time.Time          `json:"executed_at,omitempty"`
+	Adjustments     []HoldingAdjustment `json:"adjustments,omitempty"`
+}
+
+// HoldingAdjustment is the mint (positive delta) or burn (negative delta)
+// applied to one holder by a corporate action, with the cash-in-lieu owed for
+// the fractional leftover.
+type HoldingAdjustment struct {
+	ID               string    `json:"id"`
+	ActionID         string    `json:"action_id"`
+	OwnerID          *string   `json:"owner_id,omitempty"`
+	SolanaPubKey     string    `json:"solana_pub_key"`
+	HoldingBefore    float64   `json:"holding_before"`
+	HoldingAfter     float64   `json:"holding_after"`
+	DeltaAtomic      int64     `json:"delta_atomic"`
+	FractionalShares float64   `json:"fractional_shares"`   // Leftover below the rounding unit, cashed out
+	CashInLieuAmount float64   `json:"cash_in_lieu_amount"` // FractionalShares * CashInLieuPrice
+	Status           string    `json:"status"`
+	Attempts         int       `json:"attempts"`
+	LastError        *string   `json:"last_error,omitempty"`
+	TransactionID    *string   `json:"transaction_id,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
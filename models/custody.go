@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// User custody modes.
+const (
+	CustodySelf      = "self"      // The user holds their own keys and signs their transactions
+	CustodyCustodial = "custodial" // The backend holds the user's keys and signs on their behalf
+)
+
+// Custodial wallet statuses.
+const (
+	CustodialWalletActive        = "active"
+	CustodialWalletExportPending = "export_pending" // Keys handed over, erased once the user proves they hold them
+	CustodialWalletExported      = "exported"       // Keys handed over to the user and erased
+)
+
+// CustodialWallet holds a custodial user's keys, encrypted with a per-wallet
+// data key which is itself encrypted with the master key (envelope
+// encryption). The encrypted keys are erased once the user confirms the
+// export.
+type CustodialWallet struct {
+	UserID                   string     `json:"user_id"`
+	SolanaPubKey             string     `json:"solana_pub_key"`
+	EVMAddress               string     `json:"evm_address"`
+	MasterKeyID              string     `json:"master_key_id"` // Fingerprint of the master key that wraps the data key
+	EncryptedDataKey         []byte     `json:"encrypted_data_key"`
+	EncryptedSolanaKey       []byte     `json:"encrypted_solana_key"`
+	EncryptedEVMKey          []byte     `json:"encrypted_evm_key"`
+	Status                   string     `json:"status"`
+	MaxTransferAmount        *float64   `json:"max_transfer_amount,omitempty"`  // Per transfer, in token units
+	DailyTransferLimit       *float64   `json:"daily_transfer_limit,omitempty"` // Per asset over the last 24 hours
+	AuthorizationKey         *string    `json:"authorization_key,omitempty"`    // Base58 ed25519 key held by the user, authorizing exports
+	ExportChallenge          *string    `json:"export_challenge,omitempty"`     // Message the authorization key must sign to export
+	ExportChallengeExpiresAt *time.Time `json:"export_challenge_expires_at,omitempty"`
+	ExportedAt               *time.Time `json:"exported_at,omitempty"`
+	CreatedAt                time.Time  `json:"created_at"`
+	UpdatedAt                time.Time  `json:"updated_at"`
+}
+
+// CustodialSignature records a transfer signed with a custodial wallet, so
+// its transfer limits can be enforced.
+type CustodialSignature struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	AssetID       string    `json:"asset_id"`
+	Amount        float64   `json:"amount"`
+	TransactionID *string   `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WalletExportChallenge is the message a custodial user signs with their
+// authorization key to export their wallet's keys.
+type WalletExportChallenge struct {
+	UserID    string    `json:"user_id"`
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Distribution statuses.
+const (
+	DistributionStatusComputed      = "computed"       // Entitlements calculated, nothing paid yet
+	DistributionStatusExecuting     = "executing"      // Payout batches are being sent
+	DistributionStatusPartiallyPaid = "partially_paid" // Some payouts still pending or failed; can be re-executed
+	DistributionStatusCompleted     = "completed"
+)
+
+// Payout statuses.
+const (
+	PayoutStatusPending = "pending"
+	PayoutStatusSent    = "sent" // Sent to Solana, confirmation not yet observed
+	PayoutStatusPaid    = "paid"
+	PayoutStatusFailed  = "failed"
+)
+
+// Distribution is a pro-rata payment (dividend, income) to the holders of an
+// asset at a record date, paid in an SPL token such as a BRL stablecoin.
+type Distribution struct {
+	ID             string    `json:"id"`
+	AssetID        string    `json:"asset_id"`
+	SnapshotID     string    `json:"snapshot_id"` // Record-date snapshot the entitlements come from
+	Description    string    `json:"description"` // e.g., "June 2025 rental income"
+	PayoutMint     string    `json:"payout_mint"`
+	PayoutDecimals int       `json:"payout_decimals"`
+	TotalAmount    float64   `json:"total_amount"` // In payout token units
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Payouts        []Payout  `json:"payouts,omitempty"`
+}
+
+// Payout is the entitlement of one holder in a distribution.
+type Payout struct {
+	ID             string     `json:"id"`
+	DistributionID string     `json:"distribution_id"`
+	OwnerID        *string    `json:"owner_id,omitempty"`
+	SolanaPubKey   string     `json:"solana_pub_key"`
+	HoldingAmount  float64    `json:"holding_amount"` // Asset units held at the record date
+	AmountAtomic   int64      `json:"amount_atomic"`  // Payout in atomic units of the payout mint
+	Amount         float64    `json:"amount"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastError      *string    `json:"last_error,omitempty"`
+	TransactionID  *string    `json:"transaction_id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Document kinds.
+const (
+	DocumentKindProspectus = "prospectus"
+	DocumentKindBylaws     = "bylaws"
+	DocumentKindReport     = "report"
+	DocumentKindOther      = "other"
+)
+
+// Document is one version of a disclosure attached to an asset. Its SHA-256
+// hash is anchored on Solana with the Memo program, so the file can later be
+// shown not to have changed since it was published.
+type Document struct {
+	ID              string     `json:"id"`
+	AssetID         string     `json:"asset_id"`
+	Name            string     `json:"name"` // Versions of the same document share a name
+	Kind            string     `json:"kind"` // One of the DocumentKind* constants
+	Version         int        `json:"version"`
+	FileName        string     `json:"file_name"`
+	ContentType     string     `json:"content_type"`
+	Size            int64      `json:"size"`
+	SHA256          string     `json:"sha256"`      // Hex
+	StorageKey      string     `json:"storage_key"` // Location in the document store
+	AnchorSignature *string    `json:"anchor_signature,omitempty"`
+	AnchoredAt      *time.Time `json:"anchored_at,omitempty"`
+	LastError       *string    `json:"last_error,omitempty"` // Why anchoring failed, until it succeeds
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// DocumentVerification is the result of checking a file against a document.
+type DocumentVerification struct {
+	DocumentID      string  `json:"document_id"`
+	SHA256          string  `json:"sha256"`  // Hash of the file checked
+	Matches         bool    `json:"matches"` // The file is the registered one
+	AnchorSignature *string `json:"anchor_signature,omitempty"`
+	AnchorVerified  bool    `json:"anchor_verified"` // The anchor transaction carries the registered hash
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// DvP settlement statuses.
+const (
+	DvPStatusAwaitingSignatures = "awaiting_signatures"
+	DvPStatusSubmitted          = "submitted" // Sent to Solana, not finalized yet
+	DvPStatusSettled            = "settled"
+	DvPStatusFailed             = "failed"
+	DvPStatusExpired            = "expired" // Not fully signed before the blockhash expired
+)
+
+// DvPSettlement is a delivery-versus-payment exchange of an asset against a
+// payment token, carried by one transaction that both parties sign.
+type DvPSettlement struct {
+	ID              string     `json:"id"`
+	AssetID         string     `json:"asset_id"`
+	SellerID        string     `json:"seller_id"`
+	BuyerID         string     `json:"buyer_id"`
+	Quantity        float64    `json:"quantity"` // Asset units delivered
+	PaymentMint     string     `json:"payment_mint"`
+	PaymentDecimals int        `json:"payment_decimals"`
+	PaymentAmount   float64    `json:"payment_amount"` // Payment token units
+	Status          string     `json:"status"`
+	Transaction     string     `json:"transaction"` // Base64, with the signatures collected so far
+	SellerSignedAt  *time.Time `json:"seller_signed_at,omitempty"`
+	BuyerSignedAt   *time.Time `json:"buyer_signed_at,omitempty"`
+	TransactionID   *string    `json:"transaction_id,omitempty"`
+	LastError       *string    `json:"last_error,omitempty"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	SettledAt       *time.Time `json:"settled_at,omitempty"`
+}
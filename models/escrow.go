@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Escrow statuses.
+const (
+	EscrowStatusAwaitingDeposit = "awaiting_deposit"
+	EscrowStatusFunded          = "funded"
+	EscrowStatusReleasing       = "releasing" // Release sent, not confirmed yet
+	EscrowStatusReleased        = "released"
+	EscrowStatusRefunding       = "refunding" // Refund sent, not confirmed yet
+	EscrowStatusRefunded        = "refunded"
+	EscrowStatusExpired         = "expired" // Never funded before it expired
+)
+
+// Escrow release conditions.
+const (
+	EscrowConditionApproval = "approval" // Released or refunded by an explicit API call
+	EscrowConditionOffering = "offering" // Released when the offering succeeds, refunded when it fails
+)
+
+// Escrow holds a depositor's tokens in a backend-controlled token account
+// until its condition is met, then releases them to the beneficiary or
+// refunds them to the depositor.
+type Escrow struct {
+	ID                 string     `json:"id"`
+	AssetID            *string    `json:"asset_id,omitempty"` // Set when the mint is a registered asset
+	Mint               string     `json:"mint"`
+	Decimals           int        `json:"decimals"`
+	Amount             float64    `json:"amount"`
+	DepositorID        string     `json:"depositor_id"`
+	BeneficiaryID      string     `json:"beneficiary_id"`
+	EscrowAccount      string     `json:"escrow_account"` // Token account derived from the FeePayer and the escrow's seed
+	ConditionType      string     `json:"condition_type"`
+	ConditionReference *string    `json:"condition_reference,omitempty"` // e.g. the offering ID
+	Status             string     `json:"status"`
+	DepositTransaction string     `json:"deposit_transaction"` // Base64, for the depositor to sign
+	DepositTxID        *string    `json:"deposit_tx_id,omitempty"`
+	SettlementTxID     *string    `json:"settlement_tx_id,omitempty"` // Release or refund
+	LastError          *string    `json:"last_error,omitempty"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	FundedAt           *time.Time `json:"funded_at,omitempty"`
+	SettledAt          *time.Time `json:"settled_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
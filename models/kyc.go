@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// KYC statuses of a user. A verified status lapses into expired once
+// KYCExpiresAt passes and the user must re-verify.
+const (
+	KYCStatusUnverified = "unverified"
+	KYCStatusPending    = "pending"
+	KYCStatusVerified   = "verified"
+	KYCStatusRejected   = "rejected"
+	KYCStatusExpired    = "expired"
+)
+
+// KYCVerification is one verification request sent to a KYC provider.
+type KYCVerification struct {
+	ID                string     `json:"id"`
+	UserID            string     `json:"user_id"`
+	Provider          string     `json:"provider"`
+	ProviderReference string     `json:"provider_reference"` // The provider's ID for this verification
+	Level             int        `json:"level"`              // Requested level; 1 = identity, 2 = enhanced due diligence
+	Status            string     `json:"status"`
+	RedirectURL       *string    `json:"redirect_url,omitempty"` // Where the user completes the provider's flow
+	FailureReason     *string    `json:"failure_reason,omitempty"`
+	RequestedAt       time.Time  `json:"requested_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"` // Re-verification due date
+}
+
+// KYCDocument is a reference to an identity document held by the KYC
+// provider or document store; the document itself is never stored here.
+type KYCDocument struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	VerificationID *string   `json:"verification_id,omitempty"`
+	DocumentType   string    `json:"document_type"` // e.g., "cpf", "rg", "cnh", "passport", "proof_of_address"
+	Reference      string    `json:"reference"`     // Provider or storage reference
+	SHA256         *string   `json:"sha256,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// KYCProfile is the KYC state of a user with their verification history.
+type KYCProfile struct {
+	UserID        string            `json:"user_id"`
+	Status        string            `json:"status"`
+	Level         int               `json:"level"`
+	VerifiedAt    *time.Time        `json:"verified_at,omitempty"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"`
+	Verifications []KYCVerification `json:"verifications"`
+	Documents     []KYCDocument     `json:"documents"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Ledger entry types.
+const (
+	LedgerEntryOpeningBalance = "opening_balance"
+	LedgerEntryMint           = "mint"
+	LedgerEntryTransferIn     = "transfer_in"
+	LedgerEntryTransferOut    = "transfer_out"
+	LedgerEntrySplit          = "split_adjustment"
+	LedgerEntryOfferingIssue  = "offering_issue"
+	LedgerEntryEscrowDeposit  = "escrow_deposit"
+	LedgerEntryEscrowRelease  = "escrow_release"
+	LedgerEntryEscrowRefund   = "escrow_refund"
+)
+
+// LedgerEntry is an append-only credit (positive amount) or debit (negative
+// amount) of an asset for a wallet.
+type LedgerEntry struct {
+	ID            string    `json:"id"`
+	AssetID       string    `json:"asset_id"`
+	OwnerID       *string   `json:"owner_id,omitempty"`
+	SolanaPubKey  string    `json:"solana_pub_key"`
+	Amount        float64   `json:"amount"`
+	EntryType     string    `json:"entry_type"`
+	TransactionID string    `json:"transaction_id"`
+	Slot          *int64    `json:"slot,omitempty"` // Nil until seen finalized on chain
+	BlockTime     time.Time `json:"block_time"`
+	CreatedAt     time.Time `json:"created_at"`
+}
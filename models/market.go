@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// Market statuses.
+const (
+	MarketStatusOpen   = "open"
+	MarketStatusHalted = "halted" // No new orders are accepted nor matched
+)
+
+// Order sides.
+const (
+	OrderSideBuy  = "buy"
+	OrderSideSell = "sell"
+)
+
+// Order statuses.
+const (
+	OrderStatusPendingApproval = "pending_approval" // Waiting for the delegate approval signed by the user
+	OrderStatusOpen            = "open"
+	OrderStatusPartiallyFilled = "partially_filled"
+	OrderStatusFilled          = "filled"
+	OrderStatusCancelled       = "cancelled"
+)
+
+// Trade statuses.
+const (
+	TradeStatusPending = "pending"
+	TradeStatusSent    = "sent" // Sent to Solana, confirmation not yet observed
+	TradeStatusSettled = "settled"
+	TradeStatusFailed  = "failed"
+)
+
+// Market is the secondary market of an asset, quoted in an SPL token such as
+// a BRL stablecoin.
+type Market struct {
+	AssetID       string    `json:"asset_id"`
+	QuoteMint     string    `json:"quote_mint"`
+	QuoteDecimals int       `json:"quote_decimals"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Order is a limit order on an asset's book. Price is in quote token units per
+// asset unit.
+type Order struct {
+	ID                  string    `json:"id"`
+	AssetID             string    `json:"asset_id"`
+	UserID              string    `json:"user_id"`
+	Side                string    `json:"side"`
+	Price               float64   `json:"price"`
+	Quantity            float64   `json:"quantity"`
+	RemainingQuantity   float64   `json:"remaining_quantity"`
+	Status              string    `json:"status"`
+	ApprovalTransaction *string   `json:"approval_transaction,omitempty"` // Base64 delegate approval prepared for the owner to sign
+	ApprovalTxID        *string   `json:"approval_tx_id,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Trade is a fill between a buy and a sell order, settled on chain as one
+// transaction moving the asset to the buyer and the quote token to the seller.
+type Trade struct {
+	ID            string     `json:"id"`
+	AssetID       string     `json:"asset_id"`
+	BuyOrderID    string     `json:"buy_order_id"`
+	SellOrderID   string     `json:"sell_order_id"`
+	BuyerID       string     `json:"buyer_id"`
+	SellerID      string     `json:"seller_id"`
+	Price         float64    `json:"price"`
+	Quantity      float64    `json:"quantity"`
+	QuoteAtomic   int64      `json:"quote_atomic"` // Payment in atomic units of the quote mint
+	Status        string     `json:"status"`
+	LastError     *string    `json:"last_error,omitempty"`
+	TransactionID *string    `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SettledAt     *time.Time `json:"settled_at,omitempty"`
+}
+
+// PriceLevel aggregates the resting orders at one price.
+type PriceLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+// OrderBook is the aggregated view of an asset's resting orders, best prices first.
+type OrderBook struct {
+	AssetID string       `json:"asset_id"`
+	Bids    []PriceLevel `json:"bids"`
+	Asks    []PriceLevel `json:"asks"`
+}
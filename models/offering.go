@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Offering statuses.
+const (
+	OfferingStatusOpen      = "open"      // Accepting subscriptions between StartsAt and EndsAt
+	OfferingStatusClosing   = "closing"   // Allocations, mints or refunds still in progress; can be re-closed
+	OfferingStatusSucceeded = "succeeded" // Minimum raise reached, shares issued and excess refunded
+	OfferingStatusFailed    = "failed"    // Minimum raise not reached, every payment refunded
+)
+
+// Subscription statuses.
+const (
+	SubscriptionStatusPendingPayment = "pending_payment"
+	SubscriptionStatusPaid           = "paid"
+	SubscriptionStatusCancelled      = "cancelled" // Not paid when the offering closed
+	SubscriptionStatusAllocated      = "allocated" // Allocation computed, shares or refund not delivered yet
+	SubscriptionStatusIssued         = "issued"    // Shares minted and any excess refunded
+	SubscriptionStatusRefunded       = "refunded"  // Nothing allocated, payment returned
+)
+
+// Offering is a primary raise on an asset: investors subscribe an amount of
+// the payment token, paid to the platform treasury, and receive newly minted
+// shares at Price when the offering closes successfully.
+type Offering struct {
+	ID              string        `json:"id"`
+	AssetID         string        `json:"asset_id"`
+	PaymentMint     string        `json:"payment_mint"`
+	PaymentDecimals int           `json:"payment_decimals"`
+	Price           float64       `json:"price"` // Payment token units per share
+	MinRaise        float64       `json:"min_raise"`
+	MaxRaise        float64       `json:"max_raise"`
+	MinPerInvestor  float64       `json:"min_per_investor"`
+	MaxPerInvestor  float64       `json:"max_per_investor"` // 0 means no limit besides MaxRaise
+	StartsAt        time.Time     `json:"starts_at"`
+	EndsAt          time.Time     `json:"ends_at"`
+	Status          string        `json:"status"`
+	TotalPaid       float64       `json:"total_paid"`        // Confirmed payments
+	TotalAllocated  float64       `json:"total_allocated"`   // Set at close
+	SharesIssued    float64       `json:"shares_issued"`     // Set at close
+	Vesting         *VestingTerms `json:"vesting,omitempty"` // Restricts the shares allocated to each subscriber
+	ClosedAt        *time.Time    `json:"closed_at,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// Subscription is an investor's commitment to an offering.
+type Subscription struct {
+	ID                 string     `json:"id"`
+	OfferingID         string     `json:"offering_id"`
+	UserID             string     `json:"user_id"`
+	Amount             float64    `json:"amount"` // Payment token units committed
+	Status             string     `json:"status"`
+	PaymentTransaction string     `json:"payment_transaction,omitempty"` // Base64 payment to the treasury, for the investor to sign
+	PaymentTxID        *string    `json:"payment_tx_id,omitempty"`
+	AllocatedAmount    float64    `json:"allocated_amount"` // Part of Amount accepted at close
+	AllocatedShares    float64    `json:"allocated_shares"`
+	RefundAmount       float64    `json:"refund_amount"`
+	IssueTxID          *string    `json:"issue_tx_id,omitempty"`  // Set when the mint is sent
+	IssuedAt           *time.Time `json:"issued_at,omitempty"`    // Set once the mint is confirmed and booked
+	RefundTxID         *string    `json:"refund_tx_id,omitempty"` // Set when the refund is sent
+	RefundedAt         *time.Time `json:"refunded_at,omitempty"`  // Set once the refund is confirmed
+	LastError          *string    `json:"last_error,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Proposal statuses. A proposal is open for voting between OpensAt and
+// ClosesAt; it becomes finalized once its result is tallied and anchored.
+const (
+	ProposalStatusOpen       = "open"
+	ProposalStatusFinalizing = "finalizing" // Result tallied and claimed, anchor not confirmed yet
+	ProposalStatusFinalized  = "finalized"
+)
+
+// Proposal is a shareholder vote on an asset, weighted by holdings at the record date.
+type Proposal struct {
+	ID                string         `json:"id"`
+	AssetID           string         `json:"asset_id"`
+	SnapshotID        string         `json:"snapshot_id"` // Record-date snapshot the vote weights come from
+	Title             string         `json:"title"`
+	Description       string         `json:"description"`
+	Options           pq.StringArray `json:"options"`        // e.g., ["approve", "reject", "abstain"]
+	QuorumPercent     float64        `json:"quorum_percent"` // Minimum share of the record-date supply that must vote
+	OpensAt           time.Time      `json:"opens_at"`
+	ClosesAt          time.Time      `json:"closes_at"`
+	Status            string         `json:"status"`
+	ResultHash        *string        `json:"result_hash,omitempty"`        // SHA-256 of the canonical result
+	AnchorTxID        *string        `json:"anchor_tx_id,omitempty"`       // Memo transaction anchoring the result hash
+	AnchorTransaction *string        `json:"anchor_transaction,omitempty"` // Signed memo transaction, kept to rebroadcast it
+	AnchoredAt        *time.Time     `json:"anchored_at,omitempty"`        // When the current memo was signed
+	CreatedAt         time.Time      `json:"created_at"`
+}
+
+// BallotChallenge is the message a holder must sign with their wallet to vote.
+type BallotChallenge struct {
+	ID         string     `json:"id"`
+	ProposalID string     `json:"proposal_id"`
+	VoterID    string     `json:"voter_id"`
+	Option     string     `json:"option"`
+	Message    string     `json:"message"` // Exact bytes to sign (UTF-8)
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Vote is a ballot cast by a holder, with the wallet signature that proves it.
+type Vote struct {
+	ID           string    `json:"id"`
+	ProposalID   string    `json:"proposal_id"`
+	VoterID      string    `json:"voter_id"`
+	SolanaPubKey string    `json:"solana_pub_key"`
+	Option       string    `json:"option"`
+	Weight       float64   `json:"weight"`
+	Message      string    `json:"message"`
+	Signature    string    `json:"signature"` // Base58 ed25519 signature of Message
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ProposalResult is the tally of a proposal.
+type ProposalResult struct {
+	ProposalID    string             `json:"proposal_id"`
+	EligibleTotal float64            `json:"eligible_total"` // Total holdings at the record date
+	VotedTotal    float64            `json:"voted_total"`
+	TurnoutPct    float64            `json:"turnout_percent"`
+	QuorumReached bool               `json:"quorum_reached"`
+	Tally         map[string]float64 `json:"tally"` // Weight per option
+	VoteCount     int                `json:"vote_count"`
+	Winner        string             `json:"winner,omitempty"` // Empty on a tie or without quorum
+	Final         bool               `json:"final"`
+	ResultHash    string             `json:"result_hash,omitempty"`
+	AnchorTxID    string             `json:"anchor_tx_id,omitempty"`
+}
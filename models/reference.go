@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Types of business objects a transaction can reference.
+const (
+	ReferenceAsset           = "asset"            // Mint creation and initial supply
+	ReferenceTransferIntent  = "intent"           // A transfer prepared for a holder to sign
+	ReferenceOrder           = "order"            // Delegate approval for an order
+	ReferenceTrade           = "trade"            // Settlement of a matched trade
+	ReferenceSettlement      = "dvp"              // Delivery-versus-payment settlement
+	ReferenceDistribution    = "distribution"     // Payout batch
+	ReferenceCorporateAction = "corporate_action" // Split or reverse split
+	ReferenceOffering        = "offering"         // Allocation or refund batch
+	ReferenceSubscription    = "subscription"     // Subscription payment
+	ReferenceEscrow          = "escrow"           // Escrow deposit, release or refund
+	ReferenceBridge          = "bridge"           // Bridge custody account
+	ReferenceBridgeTransfer  = "bridge_transfer"  // Bridge release
+	ReferenceTransferBatch   = "transfer_batch"   // Batch transfer or airdrop
+)
+
+// referenceMemoPrefix starts every reference memo.
+const referenceMemoPrefix = "tiquin:ref:"
+
+// maxExternalReferenceLength bounds the caller-supplied part of a reference.
+const maxExternalReferenceLength = 64
+
+// TxReference ties a transaction built by the backend to the business object
+// that originated it. It travels on chain as an SPL Memo.
+type TxReference struct {
+	Type     string  `json:"type"` // One of the Reference* constants
+	ID       string  `json:"id"`
+	External *string `json:"external,omitempty"` // Caller's own reference, e.g., an invoice number
+}
+
+// Memo encodes the reference as "tiquin:ref:<type>:<id>[:<external>]".
+func (r TxReference) Memo() string {
+	memo := referenceMemoPrefix + r.Type + ":" + r.ID
+	if r.External != nil {
+		memo += ":" + *r.External
+	}
+	return memo
+}
+
+// ParseTxReference decodes a reference memo. It returns false for memos that
+// are not references.
+func ParseTxReference(memo string) (TxReference, bool) {
+	if !strings.HasPrefix(memo, referenceMemoPrefix) {
+		return TxReference{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(memo, referenceMemoPrefix), ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return TxReference{}, false
+	}
+	ref := TxReference{Type: parts[0], ID: parts[1]}
+	if len(parts) == 3 {
+		ref.External = &parts[2]
+	}
+	return ref, true
+}
+
+// ValidExternalReference reports whether a caller-supplied reference can be
+// carried in a memo.
+func ValidExternalReference(v string) bool {
+	if v == "" || len(v) > maxExternalReferenceLength {
+		return false
+	}
+	for _, r := range v {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// TransactionReference is a reference found by the listener on a finalized
+// transaction, linking it to its business object.
+type TransactionReference struct {
+	TransactionID     string    `json:"transaction_id"`
+	ReferenceType     string    `json:"reference_type"`
+	ReferenceID       string    `json:"reference_id"`
+	ExternalReference *string   `json:"external_reference,omitempty"`
+	Slot              int64     `json:"slot"`
+	BlockTime         time.Time `json:"block_time"`
+	CreatedAt         time.Time `json:"created_at"`
+}
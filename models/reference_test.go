@@ -0,0 +1,54 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTxReferenceMemo(t *testing.T) {
+	invoice, withColon := "NF-2025-0042", "PO:17:b"
+	tests := []struct {
+		ref  TxReference
+		memo string
+	}{
+		{TxReference{Type: ReferenceOrder, ID: "o1"}, "tiquin:ref:order:o1"},
+		{TxReference{Type: ReferenceTransferIntent, ID: "i1", External: &invoice}, "tiquin:ref:intent:i1:NF-2025-0042"},
+		{TxReference{Type: ReferenceEscrow, ID: "e1", External: &withColon}, "tiquin:ref:escrow:e1:PO:17:b"},
+	}
+	for _, tt := range tests {
+		if got := tt.ref.Memo(); got != tt.memo {
+			t.Errorf("Memo() = %q, want %q", got, tt.memo)
+		}
+		got, ok := ParseTxReference(tt.memo)
+		if !ok || !reflect.DeepEqual(got, tt.ref) {
+			t.Errorf("ParseTxReference(%q) = %+v, %v, want %+v", tt.memo, got, ok, tt.ref)
+		}
+	}
+
+	for _, memo := range []string{"", "hello", "tiquin:ref:", "tiquin:ref:order", "tiquin:ref:order:", "tiquin:ref::o1", "TIQUIN:REF:order:o1"} {
+		if ref, ok := ParseTxReference(memo); ok {
+			t.Errorf("ParseTxReference(%q) = %+v, want not a reference", memo, ref)
+		}
+	}
+}
+
+func TestValidExternalReference(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"NF-2025-0042", true},
+		{"PO:17 / lote B", true},
+		{strings.Repeat("x", maxExternalReferenceLength), true},
+		{strings.Repeat("x", maxExternalReferenceLength+1), false},
+		{"", false},
+		{"line\nbreak", false},
+		{"ação", false},
+	}
+	for _, tt := range tests {
+		if got := ValidExternalReference(tt.in); got != tt.want {
+			t.Errorf("ValidExternalReference(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Snapshot is a named, materialized view of an asset's holdings at a record
+// date (timestamp) or slot.
+type Snapshot struct {
+	ID          string            `json:"id"`
+	AssetID     string            `json:"asset_id"`
+	Name        string            `json:"name"` // e.g., "dividend-2025-06"
+	RecordDate  *time.Time        `json:"record_date,omitempty"`
+	Slot        *int64            `json:"slot,omitempty"`
+	TotalAmount float64           `json:"total_amount"`
+	HolderCount int               `json:"holder_count"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Holdings    []SnapshotHolding `json:"holdings,omitempty"`
+}
+
+// SnapshotHolding is the balance of one wallet in a snapshot.
+type SnapshotHolding struct {
+	SnapshotID   string  `json:"snapshot_id"`
+	OwnerID      *string `json:"owner_id,omitempty"`
+	SolanaPubKey string  `json:"solana_pub_key"`
+	Amount       float64 `json:"amount"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+// Cost basis methods. Brazilian individuals must use the average acquisition
+// cost (custo médio); FIFO is available for entities and reporting.
+const (
+	CostMethodAverage = "average"
+	CostMethodFIFO    = "fifo"
+)
+
+// Tax regimes of the built-in rate tables. Sales on a stock exchange have
+// IRRF withheld at source and, for individuals, an exemption on small monthly
+// sales; other sales are taxed as capital gains (ganho de capital) at the
+// progressive rates of Law 13.259/2016, with neither.
+const (
+	TaxRegimeExchange     = "exchange"
+	TaxRegimeCapitalGains = "capital_gains"
+)
+
+// RoundCents rounds a BRL amount to whole centavos.
+func RoundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// TaxLot is an acquisition of an asset by a holder, with what is left of it
+// after disposals.
+type TaxLot struct {
+	ID                string    `json:"id"`
+	AssetID           string    `json:"asset_id"`
+	OwnerID           string    `json:"owner_id"`
+	Quantity          float64   `json:"quantity"`
+	RemainingQuantity float64   `json:"remaining_quantity"`
+	TotalCost         float64   `json:"total_cost"` // BRL, including fees
+	RemainingCost     float64   `json:"remaining_cost"`
+	AcquiredAt        time.Time `json:"acquired_at"`
+	Source            string    `json:"source"`                   // "transfer", "manual", ...
+	TransactionID     *string   `json:"transaction_id,omitempty"` // Acquiring transaction, if on platform
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Disposal is a sale or priced transfer out of a holding, with the gain it
+// realized and the income tax withheld at source (IRRF).
+type Disposal struct {
+	ID                string    `json:"id"`
+	AssetID           string    `json:"asset_id"`
+	OwnerID           string    `json:"owner_id"`
+	Quantity          float64   `json:"quantity"`
+	UncoveredQuantity float64   `json:"uncovered_quantity"` // Part with no recorded lot; taken at zero cost
+	Proceeds          float64   `json:"proceeds"`
+	CostBasis         float64   `json:"cost_basis"`
+	Gain              float64   `json:"gain"` // Negative for a loss
+	CostMethod        string    `json:"cost_method"`
+	RateTableID       *string   `json:"rate_table_id,omitempty"` // Nil when the built-in table applied
+	WithholdingRate   float64   `json:"withholding_rate"`
+	WithholdingAmount float64   `json:"withholding_amount"`
+	TransactionID     string    `json:"transaction_id"`
+	DisposedAt        time.Time `json:"disposed_at"`
+}
+
+// GainBracket taxes the part of the monthly taxable gain up to UpTo (BRL) at
+// Rate. The last bracket has no UpTo.
+type GainBracket struct {
+	UpTo *float64 `json:"up_to,omitempty"`
+	Rate float64  `json:"rate"`
+}
+
+// GainBrackets is stored as JSONB.
+type GainBrackets []GainBracket
+
+// Value stores the brackets as JSONB.
+func (b GainBrackets) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan reads the brackets from a JSONB column.
+func (b *GainBrackets) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	default:
+		return errors.New("unsupported type for GainBrackets")
+	}
+}
+
+// TaxRateTable is a configurable set of rates, in force from EffectiveFrom
+// until a later table takes over.
+type TaxRateTable struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	EffectiveFrom    time.Time    `json:"effective_from"`
+	CostMethod       string       `json:"cost_method"`
+	WithholdingRate  float64      `json:"withholding_rate"`  // IRRF on the proceeds of each disposal
+	MonthlyExemption float64      `json:"monthly_exemption"` // Monthly sales at or below this are exempt; 0 disables
+	GainBrackets     GainBrackets `json:"gain_brackets"`     // Progressive rates on the monthly taxable gain
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+// MonthlyTaxReport summarizes an investor's disposals in a calendar month and
+// the income tax due on them (DARF).
+type MonthlyTaxReport struct {
+	UserID              string     `json:"user_id"`
+	Month               string     `json:"month"` // "YYYY-MM"
+	TotalSales          float64    `json:"total_sales"`
+	RealizedGains       float64    `json:"realized_gains"`
+	RealizedLosses      float64    `json:"realized_losses"`
+	NetResult           float64    `json:"net_result"`
+	Exempt              bool       `json:"exempt"` // Sales at or below the monthly exemption
+	LossCarryforwardIn  float64    `json:"loss_carryforward_in"`
+	TaxableGain         float64    `json:"taxable_gain"`
+	TaxDue              float64    `json:"tax_due"`
+	WithheldAtSource    float64    `json:"withheld_at_source"`
+	NetTaxPayable       float64    `json:"net_tax_payable"`
+	LossCarryforwardOut float64    `json:"loss_carryforward_out"`
+	Disposals           []Disposal `json:"disposals"`
+}
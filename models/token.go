@@ -8,10 +8,37 @@ type Token struct {
 	AssetID             string    `json:"asset_id"`             // ID of the asset this token belongs to
 	OwnerID             string    `json:"owner_id"`             // ID of the user who owns this token
 	Amount              float64   `json:"amount"`               // Fraction of the asset this token represents (e.g., 0.001 of a share)
-	SmartContractRules  string    `json:"smart_contract_rules"` // Simulates smart contract rules (e.g., "voting rights", "dividends")
+	SmartContractRules  string    `json:"smart_contract_rules"` // Descriptive label only; enforced rules are the asset's ComplianceRules
 	IsTradable          bool      `json:"is_tradable"`          // Indicates whether the token can be traded
 	MintAddress         string    `json:"mint_address"`
 	TokenAccountAddress string    `json:"token_account_address"`
 	TransactionID       string    `json:"transaction_id"`
 	CreatedAt           time.Time `json:"created_at"`
 }
+
+// Transfer intent statuses.
+const (
+	TransferIntentStatusPrepared = "prepared" // Awaiting the sender's signature
+	TransferIntentStatusSending  = "sending"  // Signed and claimed for sending
+	TransferIntentStatusSent     = "sent"
+	TransferIntentStatusFailed   = "failed"
+)
+
+// TransferIntent is a transfer prepared for its sender to sign. Completing
+// the transfer sends the prepared transaction once the sender signed it, and
+// nothing else.
+type TransferIntent struct {
+	ID            string    `json:"id"`
+	AssetID       string    `json:"asset_id"`
+	FromUserID    string    `json:"from_user_id"`
+	ToUserID      string    `json:"to_user_id"`
+	Amount        float64   `json:"amount"`
+	Destination   string    `json:"destination"` // Destination token account, or the recipient's address on EVM
+	Transaction   string    `json:"transaction"` // Base64 on Solana, unsigned hex EIP-1559 transaction on EVM
+	Status        string    `json:"status"`
+	TransactionID *string   `json:"transaction_id,omitempty"`
+	LastError     *string   `json:"last_error,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Transfer batch kinds.
+const (
+	TransferBatchKindTransfer = "transfer" // Moves tokens out of the FeePayer's treasury ATA
+	TransferBatchKindAirdrop  = "airdrop"  // Mints new tokens to the recipients
+)
+
+// Transfer batch statuses.
+const (
+	TransferBatchStatusPending            = "pending"             // Created, not executed yet
+	TransferBatchStatusExecuting          = "executing"           // Transactions are being sent
+	TransferBatchStatusPartiallyCompleted = "partially_completed" // Some rows still pending or failed; can be re-executed
+	TransferBatchStatusCompleted          = "completed"
+)
+
+// Batch transfer (row) statuses.
+const (
+	BatchTransferStatusPending   = "pending"
+	BatchTransferStatusRejected  = "rejected" // Refused by compliance or AML when the batch was created; never sent
+	BatchTransferStatusSent      = "sent"     // Sent to Solana, confirmation not yet observed
+	BatchTransferStatusConfirmed = "confirmed"
+	BatchTransferStatusFailed    = "failed"
+)
+
+// TransferBatch moves an asset's tokens to many recipients at once, packing
+// the transfers into as few transactions as fit.
+type TransferBatch struct {
+	ID          string         `json:"id"`
+	AssetID     string         `json:"asset_id"`
+	Kind        string         `json:"kind"` // One of the TransferBatchKind* constants
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	TotalAmount float64        `json:"total_amount"`
+	RowCount    int            `json:"row_count"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	RowStatuses map[string]int `json:"row_statuses,omitempty"` // Number of rows per status
+}
+
+// BatchTransfer is one row of a transfer batch: an amount for one recipient.
+type BatchTransfer struct {
+	ID            string     `json:"id"`
+	BatchID       string     `json:"batch_id"`
+	RowNumber     int        `json:"row_number"` // Position in the submitted batch, from 1
+	RecipientID   *string    `json:"recipient_id,omitempty"`
+	SolanaPubKey  string     `json:"solana_pub_key"`
+	AmountAtomic  int64      `json:"amount_atomic"`
+	Amount        float64    `json:"amount"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error,omitempty"`
+	TransactionID *string    `json:"transaction_id,omitempty"`
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -1,12 +1,67 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Data subject request kinds (LGPD art. 18).
+const (
+	DataSubjectRequestExport  = "export"
+	DataSubjectRequestErasure = "erasure"
+)
 
 // User represents an investor or token holder.
 type User struct {
-	ID           string    `json:"id"`
-	Name         *string   `json:"name,omitempty"`
-	Email        *string   `json:"email,omitempty"`
-	SolanaPubKey string    `json:"solana_pub_key"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               string     `json:"id"`
+	Name             *string    `json:"name,omitempty"`
+	Email            *string    `json:"email,omitempty"`
+	SolanaPubKey     string     `json:"solana_pub_key"`
+	EVMAddress       *string    `json:"evm_address,omitempty"`       // Wallet for assets issued on EVM chains
+	Custody          string     `json:"custody"`                     // One of the Custody* constants
+	TaxID            *string    `json:"tax_id,omitempty"`            // CPF or CNPJ, digits only
+	Jurisdiction     *string    `json:"jurisdiction,omitempty"`      // ISO 3166-1 alpha-2, e.g., "BR"
+	InvestorCategory *string    `json:"investor_category,omitempty"` // One of the InvestorCategory* constants
+	KYCStatus        string     `json:"kyc_status"`                  // One of the KYCStatus* constants
+	KYCLevel         int        `json:"kyc_level"`
+	KYCVerifiedAt    *time.Time `json:"kyc_verified_at,omitempty"`
+	KYCExpiresAt     *time.Time `json:"kyc_expires_at,omitempty"` // Re-verification due date
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ErasedAt         *time.Time `json:"erased_at,omitempty"` // Personal data pseudonymized on the user's request
+}
+
+// IsKYCVerified reports whether the user holds a verification of at least
+// minLevel that has not expired at t.
+func (u User) IsKYCVerified(minLevel int, t time.Time) bool {
+	if u.KYCStatus != KYCStatusVerified || u.KYCLevel < minLevel {
+		return false
+	}
+	return u.KYCExpiresAt == nil || t.Before(*u.KYCExpiresAt)
+}
+
+// DataSubjectRequest records a request a user made about their personal data.
+type DataSubjectRequest struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"` // One of the DataSubjectRequest* constants
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserDataExport is everything held about a user: their profile and, by
+// table, the rows of every record referring to them, with the records kept
+// after an erasure and why.
+type UserDataExport struct {
+	User        User                         `json:"user"`
+	Records     map[string][]json.RawMessage `json:"records"`
+	Retention   []RecordRetention            `json:"retention"`
+	GeneratedAt time.Time                    `json:"generated_at"`
+}
+
+// RecordRetention names records kept after a user is erased, and the legal
+// obligation that requires keeping them.
+type RecordRetention struct {
+	Tables     []string `json:"tables"`
+	LegalBasis string   `json:"legal_basis"`
+	Period     string   `json:"period"`
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Vesting schedule types.
+const (
+	VestingLockup  = "lockup"  // Everything unlocks at once, DurationMonths after the start
+	VestingLinear  = "linear"  // Vests continuously from the start; nothing is released before the cliff
+	VestingMonthly = "monthly" // Vests in equal monthly tranches from the start; nothing is released before the cliff
+)
+
+// VestingSchedule restricts part of a holder's allocation of an asset until it vests.
+type VestingSchedule struct {
+	ID             string     `json:"id"`
+	AssetID        string     `json:"asset_id"`
+	OwnerID        string     `json:"owner_id"`
+	TotalAmount    float64    `json:"total_amount"` // Amount of the holding under this schedule
+	ScheduleType   string     `json:"schedule_type"`
+	StartDate      time.Time  `json:"start_date"`
+	CliffMonths    int        `json:"cliff_months"` // Nothing vests before start + cliff
+	DurationMonths int        `json:"duration_months"`
+	Description    *string    `json:"description,omitempty"` // e.g., "Founders allocation"
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`  // Revoked schedules no longer lock anything
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// VestingTerms restrict shares when they are issued, by minting or by
+// offering allocation: a schedule with these terms is created for each
+// recipient over the amount they receive.
+type VestingTerms struct {
+	ScheduleType   string     `json:"schedule_type"`
+	StartDate      *time.Time `json:"start_date,omitempty"` // Defaults to the issue date
+	CliffMonths    int        `json:"cliff_months"`
+	DurationMonths int        `json:"duration_months"`
+	Description    *string    `json:"description,omitempty"`
+}
+
+// Schedule returns the schedule restricting amount of an asset issued to
+// ownerID at issuedAt.
+func (v VestingTerms) Schedule(id, assetID, ownerID string, amount float64, issuedAt time.Time) VestingSchedule {
+	start := issuedAt
+	if v.StartDate != nil {
+		start = *v.StartDate
+	}
+	return VestingSchedule{
+		ID:             id,
+		AssetID:        assetID,
+		OwnerID:        ownerID,
+		TotalAmount:    amount,
+		ScheduleType:   v.ScheduleType,
+		StartDate:      start,
+		CliffMonths:    v.CliffMonths,
+		DurationMonths: v.DurationMonths,
+		Description:    v.Description,
+		CreatedAt:      issuedAt,
+	}
+}
+
+// Value stores the terms as JSONB.
+func (v VestingTerms) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+// Scan reads the terms from a JSONB column.
+func (v *VestingTerms) Scan(src any) error {
+	switch b := src.(type) {
+	case []byte:
+		return json.Unmarshal(b, v)
+	case string:
+		return json.Unmarshal([]byte(b), v)
+	default:
+		return errors.New("unsupported type for VestingTerms")
+	}
+}
+
+// HoldingBalance is a holder's balance of an asset split into the locked
+// and transferable portions.
+type HoldingBalance struct {
+	AssetID   string            `json:"asset_id"`
+	Symbol    string            `json:"symbol"`
+	Total     float64           `json:"total"`
+	Locked    float64           `json:"locked"`
+	Available float64           `json:"available"`
+	AsOf      time.Time         `json:"as_of"`
+	Schedules []VestingSchedule `json:"schedules,omitempty"`
+}
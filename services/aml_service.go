@@ -0,0 +1,401 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+// nameMatchThreshold is the minimum Jaro-Winkler similarity between
+// normalized names for a name hit.
+const nameMatchThreshold = 0.9
+
+// RuleAMLScreening is the rule reported when a party of a movement has open
+// or confirmed screening hits.
+const RuleAMLScreening = "aml_screening"
+
+var (
+	// ErrScreeningHitNotFound is returned when the requested hit does not exist.
+	ErrScreeningHitNotFound = fmt.Errorf("screening hit %w", ErrNotFound)
+	// ErrHitAlreadyDecided is returned when a decision is posted for a decided hit.
+	ErrHitAlreadyDecided = fmt.Errorf("%w: screening hit was already decided", ErrConflict)
+)
+
+// accentFolder strips the diacritics found in Portuguese and Spanish names.
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// AMLService screens users and the parties of every movement against locally
+// imported sanctions, PEP and blocked-wallet lists.
+type AMLService struct {
+	DB *storage.DB
+}
+
+func NewAMLService(db *storage.DB) *AMLService {
+	return &AMLService{DB: db}
+}
+
+// ImportResult summarizes a watchlist import.
+type ImportResult struct {
+	ListName string `json:"list_name"`
+	Imported int    `json:"imported"`
+	Removed  int64  `json:"removed"`
+}
+
+// ImportWatchlist loads a list from CSV (header with any of name, tax_id,
+// wallet_address, source_ref) or from a JSON array of objects with the same
+// fields. With replace, entries missing from the import are dropped.
+func (s *AMLService) ImportWatchlist(listName, listType, format string, r io.Reader, replace bool) (ImportResult, error) {
+	if listName == "" {
+		return ImportResult{}, invalidf("list name is required")
+	}
+	switch listType {
+	case models.WatchlistSanctions, models.WatchlistPEP, models.WatchlistBlockedWallet:
+	default:
+		return ImportResult{}, invalidf("list type must be %s, %s or %s", models.WatchlistSanctions, models.WatchlistPEP, models.WatchlistBlockedWallet)
+	}
+
+	var rows []map[string]string
+	var err error
+	switch format {
+	case "csv":
+		rows, err = readCSVRows(r)
+	case "json":
+		err = json.NewDecoder(r).Decode(&rows)
+	default:
+		return ImportResult{}, invalidf("format must be csv or json")
+	}
+	if err != nil {
+		return ImportResult{}, invalidf("invalid %s watchlist: %v", format, err)
+	}
+
+	now := time.Now()
+	entries := make([]models.WatchlistEntry, 0, len(rows))
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		entry := models.WatchlistEntry{
+			ID:            uuid.New().String(),
+			ListName:      listName,
+			ListType:      listType,
+			Name:          optional(strings.TrimSpace(row["name"])),
+			WalletAddress: optional(strings.TrimSpace(row["wallet_address"])),
+			SourceRef:     optional(strings.TrimSpace(row["source_ref"])),
+			ImportedAt:    now,
+		}
+		if raw := strings.TrimSpace(row["tax_id"]); raw != "" {
+			taxID, ok := NormalizeTaxID(raw)
+			if !ok {
+				return ImportResult{}, invalidf("row %d: invalid tax_id %q", i+1, raw)
+			}
+			entry.TaxID = &taxID
+		}
+		if entry.Name == nil && entry.TaxID == nil && entry.WalletAddress == nil {
+			return ImportResult{}, invalidf("row %d: needs a name, tax_id or wallet_address", i+1)
+		}
+		entry.Fingerprint = fingerprint(entry)
+		if seen[entry.Fingerprint] {
+			continue
+		}
+		seen[entry.Fingerprint] = true
+		entries = append(entries, entry)
+	}
+
+	removed, err := s.DB.ImportWatchlist(listName, entries, replace)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to import watchlist: %w", err)
+	}
+	return ImportResult{ListName: listName, Imported: len(entries), Removed: removed}, nil
+}
+
+// ScreenParty matches a party against every watchlist, records new hits and
+// the screening itself. Unregistered parties only carry a SolanaPubKey or an
+// EVMAddress. Wallets match a party's Solana key exactly, and its EVM address
+// case-insensitively, as hex addresses are listed with or without checksum
+// casing.
+func (s *AMLService) ScreenParty(context string, reference *string, party models.User) (models.Screening, error) {
+	entries, err := s.DB.GetWatchlistEntries()
+	if err != nil {
+		return models.Screening{}, fmt.Errorf("error fetching watchlists: %w", err)
+	}
+
+	var userID *string
+	if party.ID != "" {
+		userID = &party.ID
+	}
+	now := time.Now()
+	var partyName string
+	if party.Name != nil {
+		partyName = normalizeName(*party.Name)
+	}
+
+	var hits []models.ScreeningHit
+	for _, e := range entries {
+		matchType, score := "", 0.0
+		switch {
+		case e.WalletAddress != nil && matchesWallet(*e.WalletAddress, party):
+			matchType, score = models.MatchTypeWallet, 1
+		case e.TaxID != nil && party.TaxID != nil && *e.TaxID == *party.TaxID:
+			matchType, score = models.MatchTypeTaxID, 1
+		case e.Name != nil && partyName != "":
+			if sim := jaroWinkler(partyName, normalizeName(*e.Name)); sim >= nameMatchThreshold {
+				matchType, score = models.MatchTypeName, sim
+			}
+		}
+		if matchType == "" {
+			continue
+		}
+		hits = append(hits, models.ScreeningHit{
+			ID:           uuid.New().String(),
+			EntryID:      e.ID,
+			UserID:       userID,
+			SolanaPubKey: party.SolanaPubKey,
+			MatchType:    matchType,
+			Score:        score,
+			Status:       models.HitStatusOpen,
+			CreatedAt:    now,
+		})
+	}
+
+	screening, err := s.DB.RecordScreening(models.Screening{
+		ID:           uuid.New().String(),
+		Context:      context,
+		Reference:    reference,
+		UserID:       userID,
+		SolanaPubKey: party.SolanaPubKey,
+		CreatedAt:    now,
+	}, hits)
+	if err != nil {
+		return models.Screening{}, fmt.Errorf("failed to record screening: %w", err)
+	}
+	return screening, nil
+}
+
+// ScreenMovement screens every party of a movement and returns a
+// *ComplianceError when any of them has open or confirmed hits.
+func (s *AMLService) ScreenMovement(context, reference string, parties ...models.User) error {
+	for _, p := range parties {
+		screening, err := s.ScreenParty(context, &reference, p)
+		if err != nil {
+			return err
+		}
+		if screening.Result == models.ScreeningResultBlocked {
+			return violation(RuleAMLScreening, "wallet %s has %d unresolved or confirmed screening hits", partyWallet(p), screening.HitCount)
+		}
+	}
+	return nil
+}
+
+// matchesWallet reports whether a listed wallet address is one of a party's wallets.
+func matchesWallet(address string, party models.User) bool {
+	if party.SolanaPubKey != "" && address == party.SolanaPubKey {
+		return true
+	}
+	return party.EVMAddress != nil && *party.EVMAddress != "" && strings.EqualFold(address, *party.EVMAddress)
+}
+
+// partyWallet returns the wallet a party is identified by in violations.
+func partyWallet(p models.User) string {
+	if p.SolanaPubKey == "" && p.EVMAddress != nil {
+		return *p.EVMAddress
+	}
+	return p.SolanaPubKey
+}
+
+// ListHits returns the hits with a status, each with its watchlist entry.
+func (s *AMLService) ListHits(status string) ([]models.ScreeningHit, error) {
+	hits, err := s.DB.GetScreeningHits(status)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching hits: %w", err)
+	}
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.EntryID
+	}
+	entries, err := s.DB.GetWatchlistEntriesByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching watchlist entries: %w", err)
+	}
+	byID := make(map[string]*models.WatchlistEntry, len(entries))
+	for i := range entries {
+		byID[entries[i].ID] = &entries[i]
+	}
+	for i := range hits {
+		hits[i].Entry = byID[hits[i].EntryID] // Nil when the entry was dropped from its list
+	}
+	return hits, nil
+}
+
+// DecideHit records an analyst's decision on an open hit.
+func (s *AMLService) DecideHit(id, decision, analyst string, note *string) (models.ScreeningHit, error) {
+	if decision != models.HitStatusConfirmed && decision != models.HitStatusDismissed {
+		return models.ScreeningHit{}, invalidf("decision must be %s or %s", models.HitStatusConfirmed, models.HitStatusDismissed)
+	}
+	if analyst == "" {
+		return models.ScreeningHit{}, invalidf("analyst is required")
+	}
+	if _, found, err := s.DB.GetScreeningHit(id); err != nil {
+		return models.ScreeningHit{}, fmt.Errorf("error fetching hit: %w", err)
+	} else if !found {
+		return models.ScreeningHit{}, ErrScreeningHitNotFound
+	}
+
+	decided, err := s.DB.DecideScreeningHit(id, decision, analyst, note)
+	if err != nil {
+		return models.ScreeningHit{}, fmt.Errorf("failed to record decision: %w", err)
+	}
+	if !decided {
+		return models.ScreeningHit{}, ErrHitAlreadyDecided
+	}
+	hit, _, err := s.DB.GetScreeningHit(id)
+	if err != nil {
+		return models.ScreeningHit{}, fmt.Errorf("error fetching hit: %w", err)
+	}
+	return hit, nil
+}
+
+// NormalizeTaxID strips formatting from a CPF or CNPJ and checks its length.
+func NormalizeTaxID(raw string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		if r == '.' || r == '-' || r == '/' || r == ' ' {
+			return -1
+		}
+		return 'x'
+	}, raw)
+	if strings.ContainsRune(digits, 'x') || (len(digits) != 11 && len(digits) != 14) {
+		return "", false
+	}
+	return digits, true
+}
+
+// readCSVRows reads a CSV with a header line into one map per row.
+func readCSVRows(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			row[col] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// fingerprint identifies an entry by content, so re-importing a list keeps
+// the IDs (and the hits) of unchanged entries.
+func fingerprint(e models.WatchlistEntry) string {
+	deref := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	var name string
+	if e.Name != nil {
+		name = normalizeName(*e.Name)
+	}
+	sum := sha256.Sum256([]byte(name + "|" + deref(e.TaxID) + "|" + deref(e.WalletAddress)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeName lowercases, strips accents and punctuation, and sorts the
+// words so "SILVA, Joao" and "João Silva" compare equal.
+func normalizeName(name string) string {
+	name = accentFolder.Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 to 1.
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+	window := max(len(s1), len(s2))/2 - 1
+	window = max(window, 0)
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		for j := max(0, i-window); j < min(len(s2), i+window+1); j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[k] {
+			k++
+		}
+		if s1[i] != s2[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s1), len(s2)) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// optional returns nil for an empty string.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "martha", 1},
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.8133},
+		{"jones", "johnson", 0.8323},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+		{"", "", 0},
+		{"joão", "joao", 0.8667}, // Compared by rune, not byte
+	}
+	for _, tt := range tests {
+		got := jaroWinkler(tt.a, tt.b)
+		if math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+		if back := jaroWinkler(tt.b, tt.a); math.Abs(back-got) > 1e-9 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, not symmetric with %.4f", tt.b, tt.a, back, got)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"João da Silva", "da joao silva"},
+		{"SILVA, João da", "da joao silva"},
+		{"  Ana-Maria   Conceição ", "ana conceicao maria"},
+		{"Empresa 123 S.A.", "123 a empresa s"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.name); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameMatchThreshold(t *testing.T) {
+	tests := []struct {
+		listed, party string
+		match         bool
+	}{
+		{"João da Silva", "SILVA, Joao da", true},
+		{"Joao da Silva", "Joao de Silva", true},
+		{"Joao da Silva", "Maria Souza", false},
+	}
+	for _, tt := range tests {
+		sim := jaroWinkler(normalizeName(tt.party), normalizeName(tt.listed))
+		if (sim >= nameMatchThreshold) != tt.match {
+			t.Errorf("%q against %q scored %.4f, match = %v", tt.party, tt.listed, sim, tt.match)
+		}
+	}
+}
+
+func TestMatchesWallet(t *testing.T) {
+	evm := "0x52908400098527886E0F7030069857D2E4169EE7"
+	tests := []struct {
+		name    string
+		address string
+		party   models.User
+		want    bool
+	}{
+		{"solana key", "So11111111111111111111111111111111111111112", models.User{SolanaPubKey: "So11111111111111111111111111111111111111112"}, true},
+		{"solana key is case-sensitive", "so11111111111111111111111111111111111111112", models.User{SolanaPubKey: "So11111111111111111111111111111111111111112"}, false},
+		{"evm address as stored", evm, models.User{EVMAddress: &evm}, true},
+		{"evm address in lower case", "0x52908400098527886e0f7030069857d2e4169ee7", models.User{EVMAddress: &evm}, true},
+		{"other evm address", "0x8617e340b3d01fa5f11f306f4090fd50e238070d", models.User{EVMAddress: &evm}, false},
+		{"party without wallets", "", models.User{}, false},
+		{"empty evm address", "", models.User{EVMAddress: new(string)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesWallet(tt.address, tt.party); got != tt.want {
+				t.Fatalf("matchesWallet(%q) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
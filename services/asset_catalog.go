@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+)
+
+const (
+	// defaultAssetPageSize is the page size of asset listings that set none.
+	defaultAssetPageSize = 50
+	// maxAssetPageSize bounds the page size of asset listings.
+	maxAssetPageSize = 200
+	// maxAssetSymbolLength is the length of the assets.symbol column.
+	maxAssetSymbolLength = 10
+)
+
+var assetClasses = []string{
+	models.AssetClassEquity, models.AssetClassDebt, models.AssetClassFund,
+	models.AssetClassReceivable, models.AssetClassRealEstate, models.AssetClassOther,
+}
+
+var (
+	// ErrAssetSymbolTaken is returned when another asset already uses the symbol.
+	ErrAssetSymbolTaken = fmt.Errorf("%w: asset symbol is already in use", ErrConflict)
+	// ErrAssetISINTaken is returned when another asset already has the ISIN.
+	ErrAssetISINTaken = fmt.Errorf("%w: ISIN is already assigned to another asset", ErrConflict)
+	// ErrAssetModified is returned when an update is based on a stale version of the asset.
+	ErrAssetModified = fmt.Errorf("%w: asset was modified since the given version; fetch it and retry", ErrPreconditionFailed)
+)
+
+// UpdateAssetInput holds the fields of an asset that can change after it is
+// created; the symbol and name are written on chain and cannot. Omitted
+// fields are left as they are, and an empty string clears a field.
+type UpdateAssetInput struct {
+	Issuer      *string            `json:"issuer"`
+	IssuerCNPJ  *string            `json:"issuer_cnpj"`
+	ISIN        *string            `json:"isin"`
+	AssetClass  *string            `json:"asset_class"`
+	Description *string            `json:"description"`
+	Links       *models.AssetLinks `json:"links"`
+}
+
+// ListAssets lists the assets matching a filter, a page at a time.
+func (s *TokenizationService) ListAssets(f storage.AssetFilter) ([]models.Asset, error) {
+	f, err := normalizeAssetFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return s.DB.ListAssets(f)
+}
+
+// normalizeAssetFilter validates a listing filter, puts its CNPJ in
+// canonical form and applies the default page size.
+func normalizeAssetFilter(f storage.AssetFilter) (storage.AssetFilter, error) {
+	if f.AssetClass != "" && !slices.Contains(assetClasses, f.AssetClass) {
+		return f, invalidf("asset_class must be one of %v", assetClasses)
+	}
+	if f.IssuerCNPJ != "" {
+		cnpj, ok := NormalizeCNPJ(f.IssuerCNPJ)
+		if !ok {
+			return f, invalidf("invalid issuer_cnpj %q", f.IssuerCNPJ)
+		}
+		f.IssuerCNPJ = cnpj
+	}
+	if f.Limit < 0 || f.Limit > maxAssetPageSize || f.Offset < 0 {
+		return f, invalidf("limit must be between 1 and %d and offset cannot be negative", maxAssetPageSize)
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultAssetPageSize
+	}
+	return f, nil
+}
+
+// UpdateAsset changes the mutable fields of an asset, provided it is still
+// at `version`. The token metadata JSON served to wallets reflects the change.
+func (s *TokenizationService) UpdateAsset(id string, version int, in UpdateAssetInput) (models.Asset, error) {
+	asset, found, err := s.DB.GetAsset(id)
+	if err != nil {
+		return models.Asset{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.Asset{}, ErrAssetNotFound
+	}
+	if asset.Version != version {
+		return models.Asset{}, ErrAssetModified
+	}
+
+	patchString(&asset.Issuer, in.Issuer)
+	patchString(&asset.IssuerCNPJ, in.IssuerCNPJ)
+	patchString(&asset.ISIN, in.ISIN)
+	patchString(&asset.AssetClass, in.AssetClass)
+	patchString(&asset.Description, in.Description)
+	if in.Links != nil {
+		asset.Links = *in.Links
+	}
+	if err := normalizeAssetDetails(&asset); err != nil {
+		return models.Asset{}, err
+	}
+
+	updated, ok, err := s.DB.UpdateAsset(asset)
+	if err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.Asset{}, ErrAssetISINTaken
+		}
+		return models.Asset{}, fmt.Errorf("failed to update asset: %w", err)
+	}
+	if !ok {
+		return models.Asset{}, ErrAssetModified
+	}
+	return updated, nil
+}
+
+// patchString applies an optional update to a nullable field: nil leaves it
+// as it is and an empty string clears it.
+func patchString(field **string, value *string) {
+	switch {
+	case value == nil:
+	case *value == "":
+		*field = nil
+	default:
+		*field = value
+	}
+}
+
+// normalizeAssetDetails validates the descriptive fields of an asset and
+// puts its identifiers in canonical form.
+func normalizeAssetDetails(asset *models.Asset) error {
+	if asset.ISIN != nil {
+		isin, ok := NormalizeISIN(*asset.ISIN)
+		if !ok {
+			return invalidf("invalid ISIN %q", *asset.ISIN)
+		}
+		asset.ISIN = &isin
+	}
+	if asset.IssuerCNPJ != nil {
+		cnpj, ok := NormalizeCNPJ(*asset.IssuerCNPJ)
+		if !ok {
+			return invalidf("invalid issuer_cnpj %q", *asset.IssuerCNPJ)
+		}
+		asset.IssuerCNPJ = &cnpj
+	}
+	if asset.AssetClass != nil && !slices.Contains(assetClasses, *asset.AssetClass) {
+		return invalidf("asset_class must be one of %v", assetClasses)
+	}
+	for _, link := range asset.Links {
+		if link.Name == "" || !strings.HasPrefix(link.URL, "https://") {
+			return invalidf("links need a name and an https URL")
+		}
+	}
+	return nil
+}
+
+// saveAsset stores a new asset, reporting which identifier is taken when
+// another asset already uses its symbol or ISIN.
+func (s *TokenizationService) saveAsset(asset models.Asset) error {
+	err := s.DB.SaveAsset(asset)
+	if err == nil || !storage.IsUniqueViolation(err) {
+		return err
+	}
+	if _, taken, lookupErr := s.DB.GetAssetBySymbol(asset.Symbol); lookupErr == nil && taken {
+		return ErrAssetSymbolTaken
+	}
+	return ErrAssetISINTaken
+}
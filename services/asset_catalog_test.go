@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+)
+
+func TestNormalizeCNPJ(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"11.222.333/0001-81", "11222333000181", true},
+		{"11222333000181", "11222333000181", true},
+		{" 33.000.167/0001-01 ", "33000167000101", true},
+		{"12.ABC.345/01DE-35", "12ABC34501DE35", true},
+		{"12abc34501de35", "12ABC34501DE35", true},
+		{"11.222.333/0001-82", "", false},
+		{"00.000.000/0000-00", "", false},
+		{"1122233300018", "", false},
+		{"112223330001812", "", false},
+		{"12ABC34501DEA5", "", false}, // Letters only in the base, never in the check digits
+		{"11_222_333_0001_81", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeCNPJ(tt.raw)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeCNPJ(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPatchString(t *testing.T) {
+	original, updated, empty := "original", "updated", ""
+	tests := []struct {
+		name  string
+		value *string
+		want  *string
+	}{
+		{"absent leaves the field", nil, &original},
+		{"empty clears the field", &empty, nil},
+		{"value replaces the field", &updated, &updated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := &original
+			patchString(&field, tt.value)
+			if !reflect.DeepEqual(field, tt.want) {
+				t.Fatalf("patchString() left %v, want %v", field, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAssetDetails(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	prospectus := models.AssetLink{Name: "Prospecto", URL: "https://example.com/prospecto.pdf"}
+
+	tests := []struct {
+		name    string
+		asset   models.Asset
+		want    models.Asset
+		invalid bool
+	}{
+		{name: "no details", asset: models.Asset{Symbol: "TQN"}, want: models.Asset{Symbol: "TQN"}},
+		{
+			name:  "identifiers are put in canonical form",
+			asset: models.Asset{ISIN: ptr(" brpetracnpr6 "), IssuerCNPJ: ptr("33.000.167/0001-01"), AssetClass: ptr(models.AssetClassEquity), Links: models.AssetLinks{prospectus}},
+			want:  models.Asset{ISIN: ptr("BRPETRACNPR6"), IssuerCNPJ: ptr("33000167000101"), AssetClass: ptr(models.AssetClassEquity), Links: models.AssetLinks{prospectus}},
+		},
+		{name: "invalid ISIN", asset: models.Asset{ISIN: ptr("BRPETRACNPR7")}, invalid: true},
+		{name: "invalid CNPJ", asset: models.Asset{IssuerCNPJ: ptr("33.000.167/0001-02")}, invalid: true},
+		{name: "unknown asset class", asset: models.Asset{AssetClass: ptr("crypto")}, invalid: true},
+		{name: "link without a name", asset: models.Asset{Links: models.AssetLinks{{URL: prospectus.URL}}}, invalid: true},
+		{name: "link over http", asset: models.Asset{Links: models.AssetLinks{{Name: "Prospecto", URL: "http://example.com/prospecto.pdf"}}}, invalid: true},
+		{name: "link to a local file", asset: models.Asset{Links: models.AssetLinks{prospectus, {Name: "Ata", URL: "file:///etc/passwd"}}}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asset := tt.asset
+			err := normalizeAssetDetails(&asset)
+			if tt.invalid {
+				var validation *ValidationError
+				if !errors.As(err, &validation) {
+					t.Fatalf("normalizeAssetDetails() error = %v, want a *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAssetDetails() error = %v", err)
+			}
+			if !reflect.DeepEqual(asset, tt.want) {
+				t.Fatalf("normalizeAssetDetails() = %+v, want %+v", asset, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAssetFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  storage.AssetFilter
+		want    storage.AssetFilter
+		invalid bool
+	}{
+		{name: "first page by default", want: storage.AssetFilter{Limit: defaultAssetPageSize}},
+		{
+			name:   "CNPJ is put in canonical form",
+			filter: storage.AssetFilter{AssetClass: models.AssetClassFund, IssuerCNPJ: "11.222.333/0001-81", Limit: 10, Offset: 20},
+			want:   storage.AssetFilter{AssetClass: models.AssetClassFund, IssuerCNPJ: "11222333000181", Limit: 10, Offset: 20},
+		},
+		{name: "largest page", filter: storage.AssetFilter{Limit: maxAssetPageSize}, want: storage.AssetFilter{Limit: maxAssetPageSize}},
+		{name: "page too large", filter: storage.AssetFilter{Limit: maxAssetPageSize + 1}, invalid: true},
+		{name: "negative limit", filter: storage.AssetFilter{Limit: -1}, invalid: true},
+		{name: "negative offset", filter: storage.AssetFilter{Offset: -1}, invalid: true},
+		{name: "unknown asset class", filter: storage.AssetFilter{AssetClass: "crypto"}, invalid: true},
+		{name: "invalid CNPJ", filter: storage.AssetFilter{IssuerCNPJ: "11222333000182"}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeAssetFilter(tt.filter)
+			if tt.invalid {
+				var validation *ValidationError
+				if !errors.As(err, &validation) {
+					t.Fatalf("normalizeAssetFilter() error = %v, want a *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAssetFilter() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("normalizeAssetFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// assetTransitions lists the states each lifecycle state can move to.
+// Suspending a suspended asset freezes the accounts opened since, or left
+// unfrozen by a failed freeze.
+var assetTransitions = map[string][]string{
+	models.AssetStatusDraft:     {models.AssetStatusIssued, models.AssetStatusRetired},
+	models.AssetStatusIssued:    {models.AssetStatusActive, models.AssetStatusSuspended, models.AssetStatusRetired},
+	models.AssetStatusActive:    {models.AssetStatusSuspended, models.AssetStatusMatured},
+	models.AssetStatusSuspended: {models.AssetStatusActive, models.AssetStatusSuspended, models.AssetStatusRetired},
+	models.AssetStatusMatured:   {models.AssetStatusRetired},
+}
+
+// checkAssetStatus refuses the movements an asset's lifecycle state does not
+// allow. Mints are allowed until the asset is suspended, matures or is
+// retired; transfers only while it is active.
+func checkAssetStatus(asset models.Asset, mint bool) error {
+	switch asset.Status {
+	case models.AssetStatusActive:
+		return nil
+	case models.AssetStatusDraft, models.AssetStatusIssued:
+		if mint {
+			return nil
+		}
+		return violation(RuleAssetStatus, "asset %s is %s; transfers open once it is active", asset.Symbol, asset.Status)
+	default:
+		return violation(RuleAssetStatus, "asset %s is %s", asset.Symbol, asset.Status)
+	}
+}
+
+// AssetTransitionInput justifies a lifecycle transition.
+type AssetTransitionInput struct {
+	Reason    *string `json:"reason,omitempty"`
+	OrderedBy *string `json:"ordered_by,omitempty"` // e.g., the regulator ordering a trading halt
+}
+
+// TransitionAsset moves an asset to another lifecycle state and records the
+// change. Suspending a Solana asset freezes every token account of its mint,
+// and reactivating it thaws them, in the background; the outcome is
+// recorded on the returned change. EVM tokens cannot freeze holders, so
+// suspension is only enforced by this API for them.
+func (s *TokenizationService) TransitionAsset(assetID, to string, in AssetTransitionInput) (models.AssetStatusChange, error) {
+	asset, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return models.AssetStatusChange{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.AssetStatusChange{}, ErrAssetNotFound
+	}
+	if err := checkTransition(asset, to, in); err != nil {
+		return models.AssetStatusChange{}, err
+	}
+
+	now := time.Now()
+	change := models.AssetStatusChange{
+		ID:         uuid.New().String(),
+		AssetID:    asset.ID,
+		FromStatus: asset.Status,
+		ToStatus:   to,
+		Reason:     in.Reason,
+		OrderedBy:  in.OrderedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	freeze := to == models.AssetStatusSuspended
+	thaw := asset.Status == models.AssetStatusSuspended && to == models.AssetStatusActive
+	if asset.Chain == models.ChainSolana && (freeze || thaw) {
+		pending := models.FreezeStatusPending
+		change.FreezeStatus = &pending
+	}
+
+	ok, err := s.DB.TransitionAssetStatus(change)
+	if err != nil {
+		return models.AssetStatusChange{}, fmt.Errorf("failed to change asset status: %w", err)
+	}
+	if !ok {
+		return models.AssetStatusChange{}, fmt.Errorf("%w: asset %s changed status concurrently", ErrConflict, asset.Symbol)
+	}
+	log.Printf("Asset %s moved from %s to %s", asset.ID, change.FromStatus, change.ToStatus)
+
+	if change.FreezeStatus != nil {
+		go s.syncFrozenAccounts(asset, change, freeze)
+	}
+	return change, nil
+}
+
+// checkTransition refuses unknown states, the transitions assetTransitions
+// does not list and suspensions without a reason.
+func checkTransition(asset models.Asset, to string, in AssetTransitionInput) error {
+	if _, known := assetTransitions[to]; !known && to != models.AssetStatusRetired {
+		return invalidf("unknown asset status %q", to)
+	}
+	if !slices.Contains(assetTransitions[asset.Status], to) {
+		return fmt.Errorf("%w: asset %s cannot move from %s to %s", ErrConflict, asset.Symbol, asset.Status, to)
+	}
+	if to == models.AssetStatusSuspended && (in.Reason == nil || *in.Reason == "") {
+		return invalidf("a reason is required to suspend an asset")
+	}
+	return nil
+}
+
+// GetAssetStatusHistory lists the lifecycle transitions of an asset, oldest first.
+func (s *TokenizationService) GetAssetStatusHistory(assetID string) ([]models.AssetStatusChange, error) {
+	if _, found, err := s.DB.GetAsset(assetID); err != nil {
+		return nil, fmt.Errorf("error fetching asset: %w", err)
+	} else if !found {
+		return nil, ErrAssetNotFound
+	}
+	return s.DB.GetAssetStatusChanges(assetID)
+}
+
+// syncFrozenAccounts freezes, or thaws, every token account of the asset's
+// mint that is not already in that state. Runs are serialized, so a thaw
+// ordered during a freeze sees every account the freeze reached.
+func (s *TokenizationService) syncFrozenAccounts(asset models.Asset, change models.AssetStatusChange, frozen bool) {
+	s.freezeMu.Lock()
+	defer s.freezeMu.Unlock()
+
+	var targets []solana.PublicKey
+	var accounts map[solana.PublicKey]bool
+	mint, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		err = fmt.Errorf("invalid mint address %q: %w", asset.MintAddress, err)
+	} else {
+		accounts, err = s.SolanaS.GetTokenAccounts(mint)
+	}
+	if err == nil {
+		for account, isFrozen := range accounts {
+			if isFrozen != frozen {
+				targets = append(targets, account)
+			}
+		}
+		solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceAsset, ID: asset.ID})
+		_, err = solanaS.SetTokenAccountsFrozen(mint, targets, frozen)
+	}
+
+	status := models.FreezeStatusCompleted
+	var lastError *string
+	if err != nil {
+		log.Printf("Asset %s: failed to update token accounts after moving to %s: %v", asset.ID, change.ToStatus, err)
+		status = models.FreezeStatusFailed
+		reason := err.Error()
+		lastError = &reason
+	}
+	if err := s.DB.UpdateAssetStatusChangeFreeze(change.ID, status, len(targets), lastError); err != nil {
+		log.Printf("Asset %s: failed to record freeze outcome of status change %s: %v", asset.ID, change.ID, err)
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	reason, empty := "CVM ordered a trading halt", ""
+	assetIn := func(status string) models.Asset {
+		return models.Asset{ID: "a1", Symbol: "TQN", Status: status}
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		reason  *string
+		wantErr error // nil when allowed, unless invalid
+		invalid bool  // refused with a *ValidationError
+	}{
+		{name: "draft is issued", from: models.AssetStatusDraft, to: models.AssetStatusIssued},
+		{name: "draft is discarded", from: models.AssetStatusDraft, to: models.AssetStatusRetired},
+		{name: "issued opens for trading", from: models.AssetStatusIssued, to: models.AssetStatusActive},
+		{name: "issued is suspended", from: models.AssetStatusIssued, to: models.AssetStatusSuspended, reason: &reason},
+		{name: "active is suspended", from: models.AssetStatusActive, to: models.AssetStatusSuspended, reason: &reason},
+		{name: "active matures", from: models.AssetStatusActive, to: models.AssetStatusMatured},
+		{name: "suspended is reactivated", from: models.AssetStatusSuspended, to: models.AssetStatusActive},
+		{name: "suspended is suspended again", from: models.AssetStatusSuspended, to: models.AssetStatusSuspended, reason: &reason},
+		{name: "suspended is retired", from: models.AssetStatusSuspended, to: models.AssetStatusRetired},
+		{name: "matured is retired", from: models.AssetStatusMatured, to: models.AssetStatusRetired},
+		{name: "draft skips issuance", from: models.AssetStatusDraft, to: models.AssetStatusActive, wantErr: ErrConflict},
+		{name: "active goes back to issued", from: models.AssetStatusActive, to: models.AssetStatusIssued, wantErr: ErrConflict},
+		{name: "active is retired before maturing", from: models.AssetStatusActive, to: models.AssetStatusRetired, wantErr: ErrConflict},
+		{name: "active is activated again", from: models.AssetStatusActive, to: models.AssetStatusActive, wantErr: ErrConflict},
+		{name: "matured is reactivated", from: models.AssetStatusMatured, to: models.AssetStatusActive, wantErr: ErrConflict},
+		{name: "retired is terminal", from: models.AssetStatusRetired, to: models.AssetStatusActive, wantErr: ErrConflict},
+		{name: "retired is retired again", from: models.AssetStatusRetired, to: models.AssetStatusRetired, wantErr: ErrConflict},
+		{name: "unknown status", from: models.AssetStatusActive, to: "delisted", invalid: true},
+		{name: "suspension without a reason", from: models.AssetStatusActive, to: models.AssetStatusSuspended, invalid: true},
+		{name: "suspension with an empty reason", from: models.AssetStatusActive, to: models.AssetStatusSuspended, reason: &empty, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransition(assetIn(tt.from), tt.to, AssetTransitionInput{Reason: tt.reason})
+			var validation *ValidationError
+			switch {
+			case tt.invalid:
+				if !errors.As(err, &validation) {
+					t.Fatalf("checkTransition(%s -> %s) error = %v, want a *ValidationError", tt.from, tt.to, err)
+				}
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("checkTransition(%s -> %s) error = %v", tt.from, tt.to, err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("checkTransition(%s -> %s) error = %v, want %v", tt.from, tt.to, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAssetStatus(t *testing.T) {
+	tests := []struct {
+		status       string
+		wantMint     bool
+		wantTransfer bool
+	}{
+		{models.AssetStatusDraft, true, false},
+		{models.AssetStatusIssued, true, false},
+		{models.AssetStatusActive, true, true},
+		{models.AssetStatusSuspended, false, false},
+		{models.AssetStatusMatured, false, false},
+		{models.AssetStatusRetired, false, false},
+	}
+	for _, tt := range tests {
+		asset := models.Asset{Symbol: "TQN", Status: tt.status}
+		for _, mint := range []bool{true, false} {
+			want := tt.wantTransfer
+			if mint {
+				want = tt.wantMint
+			}
+			err := checkAssetStatus(asset, mint)
+			var violation *ComplianceError
+			if want && err != nil {
+				t.Errorf("checkAssetStatus(%s, mint=%v) error = %v", tt.status, mint, err)
+			}
+			if !want && (!errors.As(err, &violation) || violation.Rule != RuleAssetStatus) {
+				t.Errorf("checkAssetStatus(%s, mint=%v) error = %v, want rule %s", tt.status, mint, err, RuleAssetStatus)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// TokenMetadata is the off-chain JSON the on-chain token metadata URI points
+// to, in the Metaplex fungible token standard read by wallets like Solflare.
+type TokenMetadata struct {
+	Name        string              `json:"name"`
+	Symbol      string              `json:"symbol"`
+	Description string              `json:"description"`
+	Attributes  []MetadataAttribute `json:"attributes"`
+	Properties  MetadataProperties  `json:"properties"`
+}
+
+// MetadataAttribute is a trait wallets list under the token.
+type MetadataAttribute struct {
+	TraitType string `json:"trait_type"`
+	Value     any    `json:"value"`
+}
+
+// MetadataProperties carries the asset's issuer details and documents.
+type MetadataProperties struct {
+	Issuer     *string           `json:"issuer,omitempty"`
+	IssuerCNPJ *string           `json:"issuer_cnpj,omitempty"`
+	ISIN       *string           `json:"isin,omitempty"`
+	Chain      string            `json:"chain"`
+	Address    string            `json:"address"`
+	Documents  models.AssetLinks `json:"documents"`
+}
+
+// metadataURI is the public URL of an asset's token metadata JSON.
+func (s *TokenizationService) metadataURI(assetID string) string {
+	if s.MetadataBaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(s.MetadataBaseURL, "/") + "/assets/" + assetID + "/metadata.json"
+}
+
+// GetTokenMetadata builds the token metadata JSON of an asset.
+func (s *TokenizationService) GetTokenMetadata(assetID string) (TokenMetadata, error) {
+	asset, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return TokenMetadata{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return TokenMetadata{}, ErrAssetNotFound
+	}
+	return tokenMetadata(asset), nil
+}
+
+// tokenMetadata describes an asset in the token metadata JSON. Without a
+// description of its own, the asset is described by its name and issuer.
+func tokenMetadata(asset models.Asset) TokenMetadata {
+	description := "Tokenized shares of " + asset.Name
+	if asset.Issuer != nil {
+		description += ", issued by " + *asset.Issuer
+	}
+	if asset.Description != nil {
+		description = *asset.Description
+	}
+	attributes := []MetadataAttribute{{TraitType: "Total shares", Value: asset.TotalShares}}
+	if asset.AssetClass != nil {
+		attributes = append(attributes, MetadataAttribute{TraitType: "Asset class", Value: *asset.AssetClass})
+	}
+	if asset.ISIN != nil {
+		attributes = append(attributes, MetadataAttribute{TraitType: "ISIN", Value: *asset.ISIN})
+	}
+	if asset.Issuer != nil {
+		attributes = append(attributes, MetadataAttribute{TraitType: "Issuer", Value: *asset.Issuer})
+	}
+	documents := asset.Links
+	if documents == nil {
+		documents = models.AssetLinks{}
+	}
+
+	return TokenMetadata{
+		Name:        asset.Name,
+		Symbol:      asset.Symbol,
+		Description: description,
+		Attributes:  attributes,
+		Properties: MetadataProperties{
+			Issuer:     asset.Issuer,
+			IssuerCNPJ: asset.IssuerCNPJ,
+			ISIN:       asset.ISIN,
+			Chain:      asset.Chain,
+			Address:    asset.MintAddress,
+			Documents:  documents,
+		},
+	}
+}
+
+// NormalizeISIN upper-cases an ISIN and checks its format and check digit.
+func NormalizeISIN(raw string) (string, bool) {
+	isin := strings.ToUpper(strings.TrimSpace(raw))
+	if len(isin) != 12 {
+		return "", false
+	}
+	// Letters expand to two digits (A=10 ... Z=35); the result must pass Luhn
+	var digits []int
+	for i, r := range isin {
+		switch {
+		case r >= '0' && r <= '9' && i >= 2:
+			digits = append(digits, int(r-'0'))
+		case r >= 'A' && r <= 'Z' && i < 11:
+			v := int(r-'A') + 10
+			digits = append(digits, v/10, v%10)
+		default:
+			return "", false
+		}
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	if sum%10 != 0 {
+		return "", false
+	}
+	return isin, true
+}
+
+// NormalizeCNPJ strips the punctuation of a CNPJ, upper-cases it and checks
+// its two check digits. Alphanumeric CNPJs are accepted: each character
+// weighs its ASCII code minus 48, so digits keep their value.
+func NormalizeCNPJ(raw string) (string, bool) {
+	cnpj := strings.ToUpper(strings.NewReplacer(".", "", "/", "", "-", "", " ", "").Replace(raw))
+	if len(cnpj) != 14 || strings.Trim(cnpj, "0") == "" {
+		return "", false
+	}
+	values := make([]int, 14)
+	for i, r := range cnpj {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'A' && r <= 'Z' && i < 12:
+		default:
+			return "", false
+		}
+		values[i] = int(r - '0')
+	}
+	for n := 12; n <= 13; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += values[i] * (2 + (n-1-i)%8)
+		}
+		check := 11 - sum%11
+		if check >= 10 {
+			check = 0
+		}
+		if values[n] != check {
+			return "", false
+		}
+	}
+	return cnpj, true
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBatchRows bounds the number of recipients in one transfer batch.
+	maxBatchRows = 10000
+	// batchSendConcurrency is how many batch transactions are in flight at once.
+	batchSendConcurrency = 4
+	// maxBatchTransferAttempts is how many times a row is retried before it needs manual review.
+	maxBatchTransferAttempts = 5
+)
+
+var (
+	// ErrTransferBatchNotFound is returned when the requested transfer batch does not exist.
+	ErrTransferBatchNotFound = fmt.Errorf("transfer batch %w", ErrNotFound)
+	// ErrTransferBatchNotExecutable is returned when a transfer batch is running or already completed.
+	ErrTransferBatchNotExecutable = fmt.Errorf("%w: transfer batch is already executing or completed", ErrConflict)
+)
+
+// BatchTransferService moves an asset's tokens to many recipients at once,
+// from the FeePayer's treasury ATA or by minting (airdrops). Transfers and
+// ATA creations are packed into as few transactions as fit, sent a few at a
+// time, and tracked per row so an interrupted batch resumes where it stopped.
+type BatchTransferService struct {
+	DB           *storage.DB
+	SolanaS      *SolanaIntegrationService
+	Tokenization *TokenizationService // Compliance and AML
+}
+
+func NewBatchTransferService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *BatchTransferService {
+	return &BatchTransferService{DB: db, SolanaS: solanaS, Tokenization: tokenization}
+}
+
+// BatchTransferRow is one recipient of a new transfer batch, identified by
+// user ID or by wallet.
+type BatchTransferRow struct {
+	RecipientID  string  `json:"recipient_id,omitempty"`
+	SolanaPubKey string  `json:"solana_pub_key,omitempty"`
+	Amount       float64 `json:"amount"`
+}
+
+// CreateTransferBatchInput describes a new transfer batch.
+type CreateTransferBatchInput struct {
+	AssetID     string             `json:"-"`
+	Kind        string             `json:"kind"` // "transfer" (default) or "airdrop"
+	Description string             `json:"description"`
+	Rows        []BatchTransferRow `json:"rows"`
+}
+
+// CreateBatch validates the rows and stores the batch for execution. Rows
+// with invalid input fail the whole batch; rows refused by the asset's
+// compliance rules or by AML screening are stored as rejected and skipped.
+func (s *BatchTransferService) CreateBatch(in CreateTransferBatchInput) (models.TransferBatch, error) {
+	if in.Kind == "" {
+		in.Kind = models.TransferBatchKindTransfer
+	}
+	if in.Kind != models.TransferBatchKindTransfer && in.Kind != models.TransferBatchKindAirdrop {
+		return models.TransferBatch{}, invalidf("kind must be transfer or airdrop")
+	}
+	if len(in.Rows) == 0 || len(in.Rows) > maxBatchRows {
+		return models.TransferBatch{}, invalidf("a batch needs between 1 and %d rows", maxBatchRows)
+	}
+
+	asset, found, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return models.TransferBatch{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.TransferBatch{}, ErrAssetNotFound
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.TransferBatch{}, err
+	}
+
+	now := time.Now()
+	batch := models.TransferBatch{
+		ID:          uuid.New().String(),
+		AssetID:     asset.ID,
+		Kind:        in.Kind,
+		Description: in.Description,
+		Status:      models.TransferBatchStatusPending,
+		RowCount:    len(in.Rows),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		RowStatuses: make(map[string]int),
+	}
+
+	rows := make([]models.BatchTransfer, len(in.Rows))
+	for i, r := range in.Rows {
+		recipient, err := s.recipient(r)
+		if err != nil {
+			return models.TransferBatch{}, invalidf("row %d: %v", i+1, err)
+		}
+		if r.Amount <= 0 {
+			return models.TransferBatch{}, invalidf("row %d: amount must be positive", i+1)
+		}
+		row := models.BatchTransfer{
+			ID:           uuid.New().String(),
+			BatchID:      batch.ID,
+			RowNumber:    i + 1,
+			SolanaPubKey: recipient.SolanaPubKey,
+			AmountAtomic: int64(toAtomic(r.Amount, 9)),
+			Amount:       r.Amount,
+			Status:       models.BatchTransferStatusPending,
+			UpdatedAt:    now,
+		}
+		if recipient.ID != "" {
+			row.RecipientID = &recipient.ID
+		}
+
+		// Tokens come from the treasury or are minted, so only the recipient is checked
+		err = s.Tokenization.Compliance.Check(Movement{Asset: asset, To: recipient, Amount: r.Amount, Timestamp: now})
+		if err == nil {
+			err = s.Tokenization.AML.ScreenMovement("batch_transfer", batch.ID, recipient)
+		}
+		var complianceErr *ComplianceError
+		switch {
+		case errors.As(err, &complianceErr):
+			reason := err.Error()
+			row.Status = models.BatchTransferStatusRejected
+			row.LastError = &reason
+		case err != nil:
+			return models.TransferBatch{}, fmt.Errorf("row %d: %w", i+1, err)
+		default:
+			batch.TotalAmount += r.Amount
+		}
+		batch.RowStatuses[row.Status]++
+		rows[i] = row
+	}
+
+	if err := s.DB.CreateTransferBatch(batch, rows); err != nil {
+		return models.TransferBatch{}, err
+	}
+	return batch, nil
+}
+
+// recipient resolves the user a row pays. Wallets without a user are paid
+// as unregistered recipients.
+func (s *BatchTransferService) recipient(r BatchTransferRow) (models.User, error) {
+	if r.RecipientID != "" {
+		user, found, err := s.DB.GetUser(r.RecipientID)
+		if err != nil {
+			return models.User{}, fmt.Errorf("error fetching user: %w", err)
+		}
+		if !found {
+			return models.User{}, fmt.Errorf("user %s not found", r.RecipientID)
+		}
+		if _, err := solana.PublicKeyFromBase58(user.SolanaPubKey); err != nil {
+			return models.User{}, fmt.Errorf("user %s has no valid Solana public key", r.RecipientID)
+		}
+		return user, nil
+	}
+
+	if _, err := solana.PublicKeyFromBase58(r.SolanaPubKey); err != nil {
+		return models.User{}, errors.New("recipient_id or a valid solana_pub_key is required")
+	}
+	user, found, err := s.DB.GetUserBySolanaPubKey(r.SolanaPubKey)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found {
+		user = models.User{SolanaPubKey: r.SolanaPubKey}
+	}
+	return user, nil
+}
+
+// GetBatch retrieves a transfer batch with its number of rows per status.
+func (s *BatchTransferService) GetBatch(id string) (models.TransferBatch, error) {
+	batch, found, err := s.DB.GetTransferBatch(id)
+	if err != nil {
+		return models.TransferBatch{}, fmt.Errorf("error fetching transfer batch: %w", err)
+	}
+	if !found {
+		return models.TransferBatch{}, ErrTransferBatchNotFound
+	}
+	return batch, nil
+}
+
+// GetRows lists the rows of a transfer batch, optionally only those in a status.
+func (s *BatchTransferService) GetRows(id, status string) ([]models.BatchTransfer, error) {
+	if _, err := s.GetBatch(id); err != nil {
+		return nil, err
+	}
+	var statuses []string
+	if status != "" {
+		statuses = append(statuses, status)
+	}
+	return s.DB.GetBatchTransfers(id, statuses...)
+}
+
+// StartExecution claims a transfer batch for execution and sends it in the
+// background. Executing a partially completed batch retries the rows that
+// are still pending or failed.
+func (s *BatchTransferService) StartExecution(id string) (models.TransferBatch, error) {
+	batch, err := s.GetBatch(id)
+	if err != nil {
+		return models.TransferBatch{}, err
+	}
+
+	asset, _, err := s.DB.GetAsset(batch.AssetID)
+	if err != nil {
+		return models.TransferBatch{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if err := checkAssetStatus(asset, true); err != nil {
+		return models.TransferBatch{}, err
+	}
+	if batch.Kind == models.TransferBatchKindTransfer {
+		if err := s.checkTreasury(asset, batch); err != nil {
+			return models.TransferBatch{}, err
+		}
+	}
+
+	claimed, err := s.DB.ClaimTransferBatch(id, models.TransferBatchStatusExecuting,
+		models.TransferBatchStatusPending, models.TransferBatchStatusPartiallyCompleted)
+	if err != nil {
+		return models.TransferBatch{}, fmt.Errorf("failed to claim transfer batch: %w", err)
+	}
+	if !claimed {
+		return models.TransferBatch{}, ErrTransferBatchNotExecutable
+	}
+	batch.Status = models.TransferBatchStatusExecuting
+
+	go s.execute(batch)
+	return batch, nil
+}
+
+// ResumeInterrupted resumes the batches that were executing when the
+// process stopped. Sent rows are reconciled against the chain first, so no
+// row is paid twice.
+func (s *BatchTransferService) ResumeInterrupted() {
+	batches, err := s.DB.GetTransferBatchesByStatus(models.TransferBatchStatusExecuting)
+	if err != nil {
+		log.Printf("Failed to load interrupted transfer batches: %v", err)
+		return
+	}
+	for _, batch := range batches {
+		log.Printf("Resuming transfer batch %s", batch.ID)
+		go s.execute(batch)
+	}
+}
+
+// checkTreasury verifies the FeePayer's treasury holds enough tokens for
+// the rows still to be sent.
+func (s *BatchTransferService) checkTreasury(asset models.Asset, batch models.TransferBatch) error {
+	rows, err := s.DB.GetBatchTransfers(batch.ID, models.BatchTransferStatusPending, models.BatchTransferStatusFailed)
+	if err != nil {
+		return fmt.Errorf("error fetching batch rows: %w", err)
+	}
+	var needed uint64
+	for _, r := range rows {
+		needed += uint64(r.AmountAtomic)
+	}
+
+	balance, err := s.SolanaS.GetOwnerTokenBalance(s.SolanaS.FeePayer.PublicKey(), solana.MustPublicKeyFromBase58(asset.MintAddress))
+	if err != nil {
+		return fmt.Errorf("failed to check treasury balance: %w", err)
+	}
+	if balance < needed {
+		return invalidf("treasury holds %g tokens, the batch needs %g", fromAtomic(balance, 9), fromAtomic(needed, 9))
+	}
+	return nil
+}
+
+// execute reconciles previously sent rows, packs the remaining ones into
+// transactions, sends them with bounded concurrency and settles the batch.
+func (s *BatchTransferService) execute(batch models.TransferBatch) {
+	defer s.settle(batch.ID)
+
+	if err := s.reconcileSentRows(batch.ID); err != nil {
+		log.Printf("Transfer batch %s: failed to reconcile sent rows: %v", batch.ID, err)
+	}
+
+	asset, _, err := s.DB.GetAsset(batch.AssetID)
+	if err != nil {
+		log.Printf("Transfer batch %s: failed to load asset: %v", batch.ID, err)
+		return
+	}
+	pending, err := s.DB.GetBatchTransfers(batch.ID, models.BatchTransferStatusPending, models.BatchTransferStatusFailed)
+	if err != nil {
+		log.Printf("Transfer batch %s: failed to load rows: %v", batch.ID, err)
+		return
+	}
+
+	var rows []models.BatchTransfer
+	var transfers []TokenTransfer
+	for _, r := range pending {
+		if r.Attempts >= maxBatchTransferAttempts {
+			continue // Needs manual review
+		}
+		rows = append(rows, r)
+		transfers = append(transfers, TokenTransfer{Owner: solana.MustPublicKeyFromBase58(r.SolanaPubKey), Amount: uint64(r.AmountAtomic)})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceTransferBatch, ID: batch.ID})
+	packs, err := solanaS.PackTokenTransfers(
+		solana.MustPublicKeyFromBase58(asset.MintAddress), transfers, batch.Kind == models.TransferBatchKindAirdrop,
+	)
+	if err != nil {
+		log.Printf("Transfer batch %s: failed to pack %d rows: %v", batch.ID, len(rows), err)
+		return
+	}
+	log.Printf("Transfer batch %s: sending %d rows in %d transactions", batch.ID, len(rows), len(packs))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, batchSendConcurrency)
+	for _, pack := range packs {
+		ids := make([]string, len(pack.Indices))
+		for i, idx := range pack.Indices {
+			ids[i] = rows[idx].ID
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			s.sendPack(batch.ID, solanaS, pack, ids)
+		}()
+	}
+	wg.Wait()
+}
+
+// sendPack signs one packed transaction, records it on its rows before
+// sending, and waits for its confirmation.
+func (s *BatchTransferService) sendPack(batchID string, solanaS *SolanaIntegrationService, pack PackedTransfers, ids []string) {
+	signedTx, sig, err := solanaS.SignPackedTransfers(pack)
+	if err != nil {
+		log.Printf("Transfer batch %s: failed to sign %d rows: %v", batchID, len(ids), err)
+		if err := s.DB.MarkBatchTransfersFailed(ids, err.Error(), true); err != nil {
+			log.Printf("Transfer batch %s: failed to record row failure: %v", batchID, err)
+		}
+		return
+	}
+	// Recorded before sending: a crash in between leaves the rows sent, and
+	// reconciliation retries them only once the transaction can no longer land
+	if err := s.DB.MarkBatchTransfersSent(ids, sig.String()); err != nil {
+		log.Printf("Transfer batch %s: failed to record tx %s, not sending it: %v", batchID, sig, err)
+		return
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Transfer batch %s: tx %s not sent: %v; will reconcile on next execution", batchID, sig, err)
+		return
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		log.Printf("Transfer batch %s: tx %s failed: %v", batchID, sig, err)
+		if err := s.DB.MarkBatchTransfersFailed(ids, err.Error(), false); err != nil {
+			log.Printf("Transfer batch %s: failed to record row failure: %v", batchID, err)
+		}
+	case err != nil:
+		log.Printf("Transfer batch %s: could not check tx %s: %v; will reconcile on next execution", batchID, sig, err)
+	case confirmed:
+		if err := s.DB.MarkBatchTransfersConfirmed(ids); err != nil {
+			log.Printf("Transfer batch %s: failed to mark rows confirmed for tx %s: %v", batchID, sig, err)
+		}
+	default:
+		log.Printf("Transfer batch %s: tx %s not confirmed yet; will reconcile on next execution", batchID, sig)
+	}
+}
+
+// reconcileSentRows resolves rows whose transaction was recorded but whose
+// confirmation was not observed.
+func (s *BatchTransferService) reconcileSentRows(batchID string) error {
+	sent, err := s.DB.GetBatchTransfers(batchID, models.BatchTransferStatusSent)
+	if err != nil {
+		return err
+	}
+
+	byTx := make(map[string][]string)
+	sentAt := make(map[string]time.Time)
+	for _, r := range sent {
+		if r.TransactionID != nil {
+			byTx[*r.TransactionID] = append(byTx[*r.TransactionID], r.ID)
+			sentAt[*r.TransactionID] = r.UpdatedAt
+		}
+	}
+	for txID, ids := range byTx {
+		confirmed, err := s.SolanaS.GetTransactionConfirmation(solana.MustSignatureFromBase58(txID))
+		switch {
+		case errors.Is(err, ErrTransactionFailed):
+			if err := s.DB.MarkBatchTransfersFailed(ids, err.Error(), false); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case confirmed:
+			if err := s.DB.MarkBatchTransfersConfirmed(ids); err != nil {
+				return err
+			}
+		case time.Since(sentAt[txID]) > payoutExpiry:
+			// Never landed and the blockhash has expired: safe to retry
+			if err := s.DB.MarkBatchTransfersFailed(ids, "transaction "+txID+" was not confirmed", false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// settle sets the final status of a transfer batch after an execution run.
+func (s *BatchTransferService) settle(batchID string) {
+	status := models.TransferBatchStatusCompleted
+	open, err := s.DB.GetBatchTransfers(batchID,
+		models.BatchTransferStatusPending, models.BatchTransferStatusSent, models.BatchTransferStatusFailed)
+	if err != nil || len(open) > 0 {
+		status = models.TransferBatchStatusPartiallyCompleted
+	}
+	if err := s.DB.UpdateTransferBatchStatus(batchID, status); err != nil {
+		log.Printf("Transfer batch %s: failed to update status: %v", batchID, err)
+		return
+	}
+	log.Printf("Transfer batch %s execution finished with status %s", batchID, status)
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// bridgePollInterval is how often the bridge scans both chains for locks.
+	bridgePollInterval = 15 * time.Second
+	// bridgeLogRange is the most EVM blocks read in one log query.
+	bridgeLogRange = 5000
+	// bridgeConfirmations is how many EVM blocks must be built on top of a
+	// lock before it is released on Solana, so a reorg cannot undo it after.
+	bridgeConfirmations = 12
+)
+
+var (
+	// ErrBridgeNotFound is returned when the asset has no bridge.
+	ErrBridgeNotFound = fmt.Errorf("bridge %w", ErrNotFound)
+	// ErrBridgeExists is returned when bridging an asset that already has a bridge.
+	ErrBridgeExists = fmt.Errorf("%w: asset already has a bridge", ErrConflict)
+	// ErrBridgeTransferNotFound is returned when the requested bridge transfer does not exist.
+	ErrBridgeTransferNotFound = fmt.Errorf("bridge transfer %w", ErrNotFound)
+	// ErrBridgeTransferNotRefundable is returned when refunding a transfer that is not a rejected Solana lock.
+	ErrBridgeTransferNotRefundable = fmt.Errorf("%w: only rejected Solana locks can be refunded", ErrConflict)
+)
+
+// BridgeService moves Solana assets to and from the EVM chain. Holders lock
+// tokens by sending them to the bridge's custody account on Solana with
+// their EVM address as memo, and the bridge mints the same amount of the
+// wrapped token to them on the EVM chain. Wrapped tokens sent to the
+// bridge's EVM address are burned and released from custody to the sender's
+// Solana wallet. Holders can only bridge to their own registered wallets;
+// rejected Solana locks stay in custody until refunded.
+type BridgeService struct {
+	DB            *storage.DB
+	SolanaS       *SolanaIntegrationService
+	Tokenization  *TokenizationService // EVM integration
+	Confirmations uint64               // EVM blocks built on top of a lock before it is released
+	stopCh        chan struct{}
+}
+
+func NewBridgeService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *BridgeService {
+	return &BridgeService{
+		DB: db, SolanaS: solanaS, Tokenization: tokenization,
+		Confirmations: bridgeConfirmations, stopCh: make(chan struct{}),
+	}
+}
+
+// CreateBridge opens a bridge for a Solana asset: it creates the custody
+// account and deploys the wrapped token, with the bridge's EVM address
+// verified so holders can send wrapped tokens back to it.
+func (s *BridgeService) CreateBridge(assetID string) (models.Bridge, error) {
+	evm, err := s.Tokenization.evm()
+	if err != nil {
+		return models.Bridge{}, err
+	}
+	asset, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return models.Bridge{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found || asset.MintAddress == "" {
+		return models.Bridge{}, ErrAssetNotFound
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.Bridge{}, err
+	}
+	if _, found, err := s.DB.GetBridgeByAssetID(asset.ID); err != nil {
+		return models.Bridge{}, fmt.Errorf("error fetching bridge: %w", err)
+	} else if found {
+		return models.Bridge{}, ErrBridgeExists
+	}
+
+	bridge := models.Bridge{
+		ID:                uuid.New().String(),
+		AssetID:           asset.ID,
+		Mint:              asset.MintAddress,
+		EVMCustodyAddress: evm.Address().Hex(),
+		CreatedAt:         time.Now(),
+	}
+	// Seeds are at most 32 characters
+	seed := "bridge" + strings.ReplaceAll(bridge.ID, "-", "")[:26]
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceBridge, ID: bridge.ID})
+	custody, err := solanaS.CreateEscrowAccount(seed, solana.MustPublicKeyFromBase58(asset.MintAddress))
+	if err != nil {
+		return models.Bridge{}, fmt.Errorf("failed to create custody account: %w", err)
+	}
+	bridge.CustodyAccount = custody.String()
+
+	head, err := evm.BlockNumber()
+	if err != nil {
+		return models.Bridge{}, fmt.Errorf("failed to read EVM block number: %w", err)
+	}
+	bridge.LastEVMBlock = int64(head)
+	token, err := evm.DeployToken(asset.Name, asset.Symbol)
+	if err != nil {
+		return models.Bridge{}, fmt.Errorf("failed to deploy wrapped token: %w", err)
+	}
+	if err := evm.SetVerified(token, evm.Address(), true); err != nil {
+		return models.Bridge{}, fmt.Errorf("failed to verify bridge custody address: %w", err)
+	}
+	bridge.EVMToken = token.Hex()
+
+	if err := s.DB.SaveBridge(bridge); err != nil {
+		return models.Bridge{}, fmt.Errorf("failed to save bridge: %w", err)
+	}
+	log.Printf("Bridge %s opened for asset %s: custody %s, wrapped token %s", bridge.ID, asset.ID, custody, token)
+	return bridge, nil
+}
+
+// GetBridge returns the bridge of an asset.
+func (s *BridgeService) GetBridge(assetID string) (models.Bridge, error) {
+	bridge, found, err := s.DB.GetBridgeByAssetID(assetID)
+	if err != nil {
+		return models.Bridge{}, fmt.Errorf("error fetching bridge: %w", err)
+	}
+	if !found {
+		return models.Bridge{}, ErrBridgeNotFound
+	}
+	return bridge, nil
+}
+
+// GetBridgeTransfer returns a bridge transfer.
+func (s *BridgeService) GetBridgeTransfer(id string) (models.BridgeTransfer, error) {
+	transfer, found, err := s.DB.GetBridgeTransfer(id)
+	if err != nil {
+		return models.BridgeTransfer{}, fmt.Errorf("error fetching bridge transfer: %w", err)
+	}
+	if !found {
+		return models.BridgeTransfer{}, ErrBridgeTransferNotFound
+	}
+	return transfer, nil
+}
+
+// RefundBridgeTransfer releases a rejected Solana lock from custody back to
+// its sender. The refund is mirrored by the scheduler like any other lock.
+func (s *BridgeService) RefundBridgeTransfer(id string) (models.BridgeTransfer, error) {
+	transfer, err := s.GetBridgeTransfer(id)
+	if err != nil {
+		return models.BridgeTransfer{}, err
+	}
+	if transfer.SourceChain != models.ChainSolana || transfer.Status != models.BridgeTransferStatusRejected {
+		return models.BridgeTransfer{}, ErrBridgeTransferNotRefundable
+	}
+	refunded, err := s.DB.RefundBridgeTransfer(id)
+	if err != nil {
+		return models.BridgeTransfer{}, fmt.Errorf("failed to refund bridge transfer: %w", err)
+	}
+	if !refunded {
+		return models.BridgeTransfer{}, ErrBridgeTransferNotRefundable // Refunded concurrently
+	}
+	log.Printf("Bridge lock %s:%s of %f will be refunded to %s", transfer.SourceChain, transfer.SourceTxID, transfer.Amount, transfer.Sender)
+	return s.GetBridgeTransfer(id)
+}
+
+// Stop signals the scheduler to shut down.
+func (s *BridgeService) Stop() {
+	close(s.stopCh)
+}
+
+// StartScheduler periodically scans every bridge for new locks on both
+// chains, mirrors them and reconciles unconfirmed mirrors. Blocks until
+// Stop() is called.
+func (s *BridgeService) StartScheduler() {
+	ticker := time.NewTicker(bridgePollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processBridges()
+		select {
+		case <-s.stopCh:
+			log.Println("Bridge scheduler stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processBridges runs one pass of the scheduler.
+func (s *BridgeService) processBridges() {
+	if s.Tokenization.EVM == nil {
+		return
+	}
+	bridges, err := s.DB.GetBridges()
+	if err != nil {
+		log.Printf("Bridge scheduler: failed to load bridges: %v", err)
+		return
+	}
+	for _, bridge := range bridges {
+		if err := s.scanSolana(bridge); err != nil {
+			log.Printf("Bridge scheduler: failed to scan Solana for bridge %s: %v", bridge.ID, err)
+		}
+		if err := s.scanEVM(bridge); err != nil {
+			log.Printf("Bridge scheduler: failed to scan EVM for bridge %s: %v", bridge.ID, err)
+		}
+	}
+
+	transfers, err := s.DB.GetActiveBridgeTransfers()
+	if err != nil {
+		log.Printf("Bridge scheduler: failed to load transfers: %v", err)
+		return
+	}
+	for _, transfer := range transfers {
+		if transfer.Status == models.BridgeTransferStatusPending {
+			err = s.mirror(transfer)
+		} else {
+			err = s.reconcile(transfer)
+		}
+		if err != nil {
+			log.Printf("Bridge scheduler: transfer %s: %v", transfer.ID, err)
+		}
+	}
+}
+
+// scanSolana records the deposits into the custody account made since the
+// last scan as locks.
+func (s *BridgeService) scanSolana(bridge models.Bridge) error {
+	var until solana.Signature
+	if bridge.LastSolanaSignature != nil {
+		until = solana.MustSignatureFromBase58(*bridge.LastSolanaSignature)
+	}
+	deposits, newest, err := s.SolanaS.GetTokenDeposits(solana.MustPublicKeyFromBase58(bridge.CustodyAccount), until)
+	if err != nil {
+		return err
+	}
+	for _, deposit := range deposits {
+		if err := s.recordSolanaLock(bridge, deposit); err != nil {
+			return fmt.Errorf("failed to record lock %s: %w", deposit.Signature, err)
+		}
+	}
+	if newest != until {
+		return s.DB.AdvanceBridgeSolanaCursor(bridge.ID, newest.String())
+	}
+	return nil
+}
+
+// recordSolanaLock records a deposit into custody. It is mirrored to the EVM
+// address in its memo, which must be the sender's registered one; otherwise
+// the tokens stay in custody for review.
+func (s *BridgeService) recordSolanaLock(bridge models.Bridge, deposit TokenDeposit) error {
+	transfer := s.newTransfer(bridge, models.ChainSolana, deposit.Signature.String(), 0, deposit.Sender.String(), deposit.Amount)
+
+	user, found, err := s.DB.GetUserBySolanaPubKey(deposit.Sender.String())
+	if err != nil {
+		return fmt.Errorf("error fetching sender: %w", err)
+	}
+	recipient, ok := NormalizeEVMAddress(strings.TrimSpace(deposit.Memo))
+	switch {
+	case !found:
+		reject(&transfer, "sender is not a registered user")
+	case !ok:
+		reject(&transfer, "memo must be the destination EVM address")
+	case user.EVMAddress == nil || *user.EVMAddress != recipient:
+		transfer.UserID = &user.ID
+		reject(&transfer, "destination must be the sender's registered evm_address")
+	default:
+		transfer.UserID = &user.ID
+		transfer.Recipient = &recipient
+	}
+	return s.record(transfer)
+}
+
+// scanEVM records the wrapped token transfers to the bridge's EVM address
+// made since the last scan as locks, once they have enough confirmations,
+// and burns the tokens received.
+func (s *BridgeService) scanEVM(bridge models.Bridge) error {
+	evm := s.Tokenization.EVM
+	token, custody := common.HexToAddress(bridge.EVMToken), common.HexToAddress(bridge.EVMCustodyAddress)
+
+	head, err := evm.BlockNumber()
+	if err != nil {
+		return fmt.Errorf("failed to read EVM block number: %w", err)
+	}
+	safe, ok := confirmedHead(head, s.Confirmations)
+	for from := uint64(bridge.LastEVMBlock) + 1; ok && from <= safe; from += bridgeLogRange {
+		to := min(from+bridgeLogRange-1, safe)
+		transfers, err := evm.GetTransfersTo(token, custody, from, to)
+		if err != nil {
+			return err
+		}
+		for _, t := range transfers {
+			if err := s.recordEVMLock(bridge, t); err != nil {
+				return fmt.Errorf("failed to record lock %s: %w", t.TxHash, err)
+			}
+		}
+		if err := s.DB.AdvanceBridgeEVMCursor(bridge.ID, int64(to)); err != nil {
+			return err
+		}
+	}
+
+	// Tokens sent back are recorded; take them out of the wrapped supply so
+	// it always equals what custody holds for it
+	held, err := evm.GetTokenBalance(token, custody)
+	if err != nil {
+		return err
+	}
+	if held > 0 {
+		if _, err := evm.BurnTokens(token, custody, held); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// confirmedHead returns the newest block with `depth` blocks built on top of
+// it, and false when the chain is not that long yet.
+func confirmedHead(head, depth uint64) (uint64, bool) {
+	if head < depth {
+		return 0, false
+	}
+	return head - depth, true
+}
+
+// recordEVMLock records wrapped tokens sent to the bridge. They are released
+// from custody to the sender's registered Solana wallet; senders without a
+// user record are left for review.
+func (s *BridgeService) recordEVMLock(bridge models.Bridge, t TokenTransferLog) error {
+	transfer := s.newTransfer(bridge, models.ChainEVM, t.TxHash.Hex(), int(t.LogIndex), t.From.Hex(), t.Amount)
+
+	user, found, err := s.DB.GetUserByEVMAddress(t.From.Hex())
+	if err != nil {
+		return fmt.Errorf("error fetching sender: %w", err)
+	}
+	if found {
+		transfer.UserID = &user.ID
+		transfer.Recipient = &user.SolanaPubKey
+	} else {
+		reject(&transfer, "sender is not a registered user")
+	}
+	return s.record(transfer)
+}
+
+func (s *BridgeService) newTransfer(bridge models.Bridge, chain, txID string, index int, sender string, amount uint64) models.BridgeTransfer {
+	now := time.Now()
+	return models.BridgeTransfer{
+		ID:          uuid.New().String(),
+		BridgeID:    bridge.ID,
+		AssetID:     bridge.AssetID,
+		SourceChain: chain,
+		SourceTxID:  txID,
+		SourceIndex: index,
+		Sender:      sender,
+		Amount:      fromAtomic(amount, 9),
+		Status:      models.BridgeTransferStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func reject(transfer *models.BridgeTransfer, reason string) {
+	transfer.Status = models.BridgeTransferStatusRejected
+	transfer.LastError = &reason
+}
+
+// record saves a lock unless it was already recorded by an earlier scan.
+func (s *BridgeService) record(transfer models.BridgeTransfer) error {
+	recorded, err := s.DB.RecordBridgeTransfer(transfer)
+	if err != nil {
+		return err
+	}
+	if recorded {
+		log.Printf("Bridge lock %s:%s of %f recorded (%s)", transfer.SourceChain, transfer.SourceTxID, transfer.Amount, transfer.Status)
+	}
+	return nil
+}
+
+// mirror signs the mint or release of a pending transfer, records it and
+// only then sends it, so a crash between the two never sends it twice.
+func (s *BridgeService) mirror(transfer models.BridgeTransfer) error {
+	evm := s.Tokenization.EVM
+	bridge, err := s.GetBridge(transfer.AssetID)
+	if err != nil {
+		return err
+	}
+	amount := toAtomic(transfer.Amount, 9)
+
+	var signedTx, txID string
+	if mintsWrapped(transfer) {
+		token, recipient := common.HexToAddress(bridge.EVMToken), common.HexToAddress(*transfer.Recipient)
+		if err := evm.SetVerified(token, recipient, true); err != nil {
+			return s.fail(transfer, fmt.Errorf("failed to verify recipient: %w", err))
+		}
+		raw, hash, err := evm.SignMint(token, recipient, amount)
+		if err != nil {
+			return s.fail(transfer, err)
+		}
+		signedTx, txID = raw, hash.Hex()
+	} else {
+		solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceBridgeTransfer, ID: transfer.ID})
+		signed, sig, err := solanaS.SignTransferFromEscrow(
+			solana.MustPublicKeyFromBase58(bridge.Mint), solana.MustPublicKeyFromBase58(bridge.CustodyAccount),
+			solana.MustPublicKeyFromBase58(*transfer.Recipient), amount,
+		)
+		if err != nil {
+			return s.fail(transfer, err)
+		}
+		signedTx, txID = signed, sig.String()
+	}
+
+	claimed, err := s.DB.MarkBridgeTransferSigned(transfer.ID, signedTx, txID)
+	if err != nil {
+		return fmt.Errorf("failed to record mirrored transfer: %w", err)
+	}
+	if !claimed {
+		return nil // Mirrored concurrently
+	}
+	transfer.Status = models.BridgeTransferStatusSending
+	transfer.DestinationTransaction = &signedTx
+	transfer.DestinationTxID = &txID
+	transfer.UpdatedAt = time.Now()
+	return s.reconcile(transfer)
+}
+
+// fail records why a pending transfer could not be mirrored; it is retried
+// on the next pass.
+func (s *BridgeService) fail(transfer models.BridgeTransfer, err error) error {
+	if recordErr := s.DB.FailBridgeTransfer(transfer.ID, err.Error()); recordErr != nil {
+		log.Printf("Bridge transfer %s: failed to record failure: %v", transfer.ID, recordErr)
+	}
+	return err
+}
+
+// mintsWrapped reports whether a transfer is mirrored by minting wrapped
+// tokens; the others are released from custody on Solana.
+func mintsWrapped(transfer models.BridgeTransfer) bool {
+	return transfer.SourceChain == models.ChainSolana && !transfer.Refund
+}
+
+// mirrorState is what checking a mirrored transfer on its destination chain
+// found.
+type mirrorState int
+
+const (
+	mirrorInFlight  mirrorState = iota // Sent and may still land
+	mirrorConfirmed                    // Landed
+	mirrorDropped                      // Failed or can no longer land: safe to sign a new one
+)
+
+// mirrorStatus classifies the confirmation of a mirrored transfer, with the
+// reason it was dropped.
+func mirrorStatus(confirmed bool, err error) (mirrorState, string, error) {
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		return mirrorDropped, err.Error(), nil
+	case err != nil:
+		return mirrorInFlight, "", err
+	case confirmed:
+		return mirrorConfirmed, "", nil
+	}
+	return mirrorInFlight, "", nil
+}
+
+// reconcile completes a transfer whose mirror was confirmed, (re)sends it
+// while it can still land, and returns it to pending once it can no longer
+// land so a new one is signed.
+func (s *BridgeService) reconcile(transfer models.BridgeTransfer) error {
+	check := s.checkRelease
+	if mintsWrapped(transfer) {
+		check = s.checkMint
+	}
+	state, reason, err := check(transfer)
+	switch {
+	case err != nil:
+		return err
+	case state == mirrorConfirmed:
+		return s.complete(transfer)
+	case state == mirrorDropped:
+		return s.DB.ResetBridgeTransfer(transfer.ID, reason)
+	}
+	return nil
+}
+
+// checkMint (re)sends a mint of wrapped tokens until it is mined. It is
+// dropped once it reverted or another transaction took its nonce, as it can
+// then never be mined.
+func (s *BridgeService) checkMint(transfer models.BridgeTransfer) (mirrorState, string, error) {
+	evm := s.Tokenization.EVM
+	txID, signedTx := *transfer.DestinationTxID, *transfer.DestinationTransaction
+	hash := common.HexToHash(txID)
+
+	if state, reason, err := mirrorStatus(evm.GetTransactionConfirmation(hash)); err != nil || state != mirrorInFlight {
+		return state, reason, err
+	}
+	_, err := evm.SendSignedTransaction(signedTx, evm.Address())
+	if errors.Is(err, ErrNonceUsed) {
+		// Another transaction took the nonce, unless it was this one
+		if state, reason, err := mirrorStatus(evm.GetTransactionConfirmation(hash)); err != nil || state != mirrorInFlight {
+			return state, reason, err
+		}
+		return mirrorDropped, "mint " + txID + " was replaced", nil
+	}
+	if err != nil {
+		return mirrorInFlight, "", fmt.Errorf("failed to send mint %s: %w", txID, err)
+	}
+	return mirrorStatus(evm.GetTransactionConfirmation(hash))
+}
+
+// checkRelease (re)sends a release from custody until it is confirmed. It
+// is dropped once it failed or its blockhash expired, as it can then never
+// land.
+func (s *BridgeService) checkRelease(transfer models.BridgeTransfer) (mirrorState, string, error) {
+	txID, signedTx := *transfer.DestinationTxID, *transfer.DestinationTransaction
+	sig, err := solana.SignatureFromBase58(txID)
+	if err != nil {
+		return mirrorInFlight, "", fmt.Errorf("invalid release signature %s: %w", txID, err)
+	}
+
+	if state, reason, err := mirrorStatus(s.SolanaS.GetTransactionConfirmation(sig)); err != nil || state != mirrorInFlight {
+		return state, reason, err
+	}
+	if time.Since(transfer.UpdatedAt) > payoutExpiry {
+		// Never landed and the blockhash has expired: safe to sign a new one
+		return mirrorDropped, "release " + txID + " was not confirmed", nil
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Bridge transfer %s: release %s not sent yet: %v", transfer.ID, txID, err)
+	}
+	return mirrorInFlight, "", nil
+}
+
+func (s *BridgeService) complete(transfer models.BridgeTransfer) error {
+	if err := s.DB.CompleteBridgeTransfer(transfer.ID); err != nil {
+		return fmt.Errorf("failed to complete transfer: %w", err)
+	}
+	log.Printf("Bridge transfer %s completed | TxID: %s", transfer.ID, *transfer.DestinationTxID)
+	return nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestConfirmedHead(t *testing.T) {
+	tests := []struct {
+		head, depth, want uint64
+		ok                bool
+	}{
+		{head: 100, depth: 12, want: 88, ok: true},
+		{head: 12, depth: 12, want: 0, ok: true},
+		{head: 11, depth: 12, ok: false},
+		{head: 100, depth: 0, want: 100, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := confirmedHead(tt.head, tt.depth)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("confirmedHead(%d, %d) = %d, %v, want %d, %v", tt.head, tt.depth, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMintsWrapped(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer models.BridgeTransfer
+		want     bool
+	}{
+		{"solana lock", models.BridgeTransfer{SourceChain: models.ChainSolana}, true},
+		{"evm lock", models.BridgeTransfer{SourceChain: models.ChainEVM}, false},
+		{"refunded solana lock", models.BridgeTransfer{SourceChain: models.ChainSolana, Refund: true}, false},
+	}
+	for _, tt := range tests {
+		if got := mintsWrapped(tt.transfer); got != tt.want {
+			t.Errorf("mintsWrapped(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sendingTransfer returns a transfer whose mirror was signed and recorded.
+func sendingTransfer(signedTx, txID string, updatedAt time.Time) models.BridgeTransfer {
+	return models.BridgeTransfer{
+		ID:                     "0b5f3a0e-9a43-4a7e-9d56-7f8e1f2f6a11",
+		Status:                 models.BridgeTransferStatusSending,
+		DestinationTransaction: &signedTx,
+		DestinationTxID:        &txID,
+		UpdatedAt:              updatedAt,
+	}
+}
+
+func TestCheckMint(t *testing.T) {
+	holder, unverified := newHolder(t), newHolder(t)
+	evm := newTestEVM(t, holder)
+	token := deployVerified(t, evm, holder)
+	bridge := &BridgeService{Tokenization: &TokenizationService{EVM: evm}}
+	mined := func(t *testing.T) uint64 {
+		t.Helper()
+		return balanceOf(t, evm, token, addressOf(holder))
+	}
+
+	t.Run("rebroadcast mints once", func(t *testing.T) {
+		before := mined(t)
+		raw, hash, err := evm.SignMint(token, addressOf(holder), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transfer := sendingTransfer(raw, hash.Hex(), time.Now())
+
+		// Sent but not mined yet: the next pass sends it again
+		commit := evm.commit
+		evm.commit = nil
+		for range 2 {
+			if state, _, err := bridge.checkMint(transfer); err != nil || state != mirrorInFlight {
+				t.Fatalf("checkMint() before mining = %v, %v, want in flight", state, err)
+			}
+		}
+		evm.commit = commit
+		evm.commit()
+
+		for range 2 {
+			if state, _, err := bridge.checkMint(transfer); err != nil || state != mirrorConfirmed {
+				t.Fatalf("checkMint() after mining = %v, %v, want confirmed", state, err)
+			}
+		}
+		if got := mined(t) - before; got != 100 {
+			t.Fatalf("minted %d, want 100", got)
+		}
+	})
+
+	t.Run("replaced mint is dropped", func(t *testing.T) {
+		before := mined(t)
+		raw, hash, err := evm.SignMint(token, addressOf(holder), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Another transaction takes the nonce before the mint is sent
+		if _, err := evm.MintTokens(token, addressOf(holder), 5); err != nil {
+			t.Fatal(err)
+		}
+
+		state, reason, err := bridge.checkMint(sendingTransfer(raw, hash.Hex(), time.Now()))
+		if err != nil || state != mirrorDropped || !strings.Contains(reason, "replaced") {
+			t.Fatalf("checkMint() = %v, %q, %v, want dropped as replaced", state, reason, err)
+		}
+		if got := mined(t) - before; got != 5 {
+			t.Fatalf("minted %d, want only the 5 of the other transaction", got)
+		}
+	})
+
+	t.Run("reverted mint is dropped", func(t *testing.T) {
+		tx := signAs(t, evm, evm.Key, token, "mint", addressOf(unverified), new(big.Int).SetUint64(100))
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		state, reason, err := bridge.checkMint(sendingTransfer(hexutil.Encode(raw), tx.Hash().Hex(), time.Now()))
+		if err != nil || state != mirrorDropped || !strings.Contains(reason, "reverted") {
+			t.Fatalf("checkMint() = %v, %q, %v, want dropped as reverted", state, reason, err)
+		}
+		if got := balanceOf(t, evm, token, addressOf(unverified)); got != 0 {
+			t.Fatalf("unverified holder received %d", got)
+		}
+	})
+}
+
+// fakeReleaseRPC answers getSignatureStatuses with `status` (unknown when
+// nil) and accepts sendTransaction, counting the transactions sent.
+func fakeReleaseRPC(t *testing.T, status map[string]any, sent *int) *rpc.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "getSignatureStatuses":
+			result = map[string]any{"context": map[string]any{"slot": 1}, "value": []any{status}}
+		case "sendTransaction":
+			*sent++
+			result = solana.Signature{}.String()
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return rpc.New(server.URL)
+}
+
+func TestCheckRelease(t *testing.T) {
+	custody, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1, custody.PublicKey(), solana.NewWallet().PublicKey()).Build()},
+		solana.Hash{}, solana.TransactionPayer(custody.PublicKey()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Sign(func(solana.PublicKey) *solana.PrivateKey { return &custody }); err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := tx.ToBase64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txID := tx.Signatures[0].String()
+	statusOf := func(confirmation string, txErr any) map[string]any {
+		return map[string]any{"slot": 1, "confirmations": nil, "err": txErr, "confirmationStatus": confirmation}
+	}
+	failure := map[string]any{"InstructionError": []any{0, map[string]any{"Custom": 1}}}
+
+	tests := []struct {
+		name     string
+		status   map[string]any
+		age      time.Duration
+		want     mirrorState
+		wantSent int
+	}{
+		{name: "finalized", status: statusOf("finalized", nil), want: mirrorConfirmed},
+		{name: "confirmed", status: statusOf("confirmed", nil), want: mirrorConfirmed},
+		{name: "failed", status: statusOf("confirmed", failure), want: mirrorDropped},
+		{name: "processed is resent", status: statusOf("processed", nil), want: mirrorInFlight, wantSent: 1},
+		{name: "unknown is resent", want: mirrorInFlight, wantSent: 1},
+		{name: "unknown after its blockhash expired", age: payoutExpiry + time.Minute, want: mirrorDropped},
+		{name: "confirmed after its blockhash expired", status: statusOf("confirmed", nil), age: payoutExpiry + time.Minute, want: mirrorConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := 0
+			bridge := &BridgeService{SolanaS: &SolanaIntegrationService{RPCClient: fakeReleaseRPC(t, tt.status, &sent)}}
+			state, reason, err := bridge.checkRelease(sendingTransfer(signedTx, txID, time.Now().Add(-tt.age)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != tt.want {
+				t.Fatalf("checkRelease() = %v, want %v", state, tt.want)
+			}
+			if (state == mirrorDropped) != (reason != "") {
+				t.Errorf("checkRelease() gave reason %q for state %v", reason, state)
+			}
+			if sent != tt.wantSent {
+				t.Errorf("release sent %d times, want %d", sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// GetCapTable aggregates the holdings of an asset per holder.
+// Without asOf, the internal records are reconciled against the current
+// on-chain token accounts so that wallets unknown to the platform show up as
+// unregistered holders. With asOf, holdings are rebuilt from the ledger
+// entries finalized up to that instant, since on-chain balances cannot be
+// read in the past.
+func (s *TokenizationService) GetCapTable(assetID string, asOf *time.Time) (models.CapTable, error) {
+	asset, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return models.CapTable{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.CapTable{}, ErrAssetNotFound
+	}
+
+	var holders []models.CapTableEntry
+	if asOf != nil {
+		holders, err = s.DB.GetHoldingsAt(asset.ID, *asOf)
+	} else {
+		holders, err = s.DB.GetHoldingsByAssetID(asset.ID)
+	}
+	if err != nil {
+		return models.CapTable{}, fmt.Errorf("error aggregating holdings: %w", err)
+	}
+
+	if asOf == nil && asset.MintAddress != "" && asset.Chain != models.ChainEVM {
+		holders, err = s.mergeOnChainHolders(asset, holders)
+		if err != nil {
+			return models.CapTable{}, err
+		}
+	}
+
+	circulating := rankHolders(holders, asset.TotalShares)
+	return models.CapTable{
+		AssetID:           asset.ID,
+		Symbol:            asset.Symbol,
+		TotalShares:       asset.TotalShares,
+		CirculatingSupply: circulating,
+		AsOf:              asOf,
+		GeneratedAt:       time.Now(),
+		Holders:           holders,
+	}, nil
+}
+
+// rankHolders fills in the ownership percentages of each holder, sorts them
+// from the largest holding down and returns the circulating supply.
+// Percentages are left at zero when there is nothing to divide by.
+func rankHolders(holders []models.CapTableEntry, totalShares float64) float64 {
+	var circulating float64
+	for _, h := range holders {
+		circulating += h.Amount
+	}
+	for i := range holders {
+		if totalShares > 0 {
+			holders[i].PercentOfTotalShares = holders[i].Amount / totalShares * 100
+		}
+		if circulating > 0 {
+			holders[i].PercentOfCirculating = holders[i].Amount / circulating * 100
+		}
+	}
+	sort.SliceStable(holders, func(i, j int) bool { return holders[i].Amount > holders[j].Amount })
+	return circulating
+}
+
+// mergeOnChainHolders attaches the on-chain balance to registered holders and
+// appends wallets holding the mint that have no user record.
+func (s *TokenizationService) mergeOnChainHolders(asset models.Asset, holders []models.CapTableEntry) ([]models.CapTableEntry, error) {
+	mintAddress, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Mint address: %w", err)
+	}
+	onChain, err := s.SolanaS.GetTokenHolders(mintAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read on-chain holders: %w", err)
+	}
+
+	byPubKey := make(map[string]int, len(holders))
+	for i, h := range holders {
+		byPubKey[h.SolanaPubKey] = i
+	}
+
+	for owner, atomic := range onChain {
+		amount := float64(atomic) / 1e9
+		if i, ok := byPubKey[owner]; ok {
+			holders[i].OnChainAmount = &amount
+			continue
+		}
+
+		// The wallet may belong to a user without internal token records yet
+		user, foundUser, err := s.DB.GetUserBySolanaPubKey(owner)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching holder %s: %w", owner, err)
+		}
+		entry := models.CapTableEntry{
+			SolanaPubKey:  owner,
+			Amount:        amount,
+			OnChainAmount: &amount,
+			Registered:    foundUser,
+		}
+		if foundUser {
+			entry.HolderID = user.ID
+			entry.Name = user.Name
+		} else {
+			log.Printf("Unregistered on-chain holder %s for asset %s", owner, asset.Symbol)
+		}
+		holders = append(holders, entry)
+	}
+	return holders, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestRankHolders(t *testing.T) {
+	holders := func(amounts ...float64) []models.CapTableEntry {
+		entries := make([]models.CapTableEntry, len(amounts))
+		for i, amount := range amounts {
+			entries[i] = models.CapTableEntry{SolanaPubKey: string(rune('a' + i)), Amount: amount}
+		}
+		return entries
+	}
+	type share struct {
+		pubKey                 string
+		ofTotal, ofCirculating float64
+	}
+
+	tests := []struct {
+		name            string
+		holders         []models.CapTableEntry
+		totalShares     float64
+		wantCirculating float64
+		want            []share
+	}{
+		{
+			name:            "sorted by holding",
+			holders:         holders(100, 500, 200),
+			totalShares:     1000,
+			wantCirculating: 800,
+			want:            []share{{"b", 50, 62.5}, {"c", 20, 25}, {"a", 10, 12.5}},
+		},
+		{
+			name:            "ties keep their order",
+			holders:         holders(250, 250),
+			totalShares:     1000,
+			wantCirculating: 500,
+			want:            []share{{"a", 25, 50}, {"b", 25, 50}},
+		},
+		{
+			name:            "no total shares",
+			holders:         holders(30, 10),
+			wantCirculating: 40,
+			want:            []share{{"a", 0, 75}, {"b", 0, 25}},
+		},
+		{
+			name:        "nothing in circulation",
+			holders:     holders(0, 0),
+			totalShares: 1000,
+			want:        []share{{"a", 0, 0}, {"b", 0, 0}},
+		},
+		{name: "no holders", totalShares: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			circulating := rankHolders(tt.holders, tt.totalShares)
+			if circulating != tt.wantCirculating {
+				t.Errorf("rankHolders() = %v, want %v", circulating, tt.wantCirculating)
+			}
+			if len(tt.holders) != len(tt.want) {
+				t.Fatalf("got %d holders, want %d", len(tt.holders), len(tt.want))
+			}
+			for i, h := range tt.holders {
+				got := share{h.SolanaPubKey, h.PercentOfTotalShares, h.PercentOfCirculating}
+				if got != tt.want[i] {
+					t.Errorf("holder %d = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Trading windows must resolve time zones on hosts without zoneinfo
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+)
+
+// Names of the rules reported in a ComplianceError.
+const (
+	RuleAllowedJurisdictions = "allowed_jurisdictions"
+	RuleInvestorCategories   = "investor_categories"
+	RuleMaxHolders           = "max_holders"
+	RuleMaxPercentPerHolder  = "max_percent_per_holder"
+	RuleMinTransferAmount    = "min_transfer_amount"
+	RuleTradingWindows       = "trading_windows"
+	RuleRequireKYC           = "require_kyc"
+	RuleAssetStatus          = "asset_status"
+)
+
+// defaultTradingTimezone is used by trading windows that do not set one.
+const defaultTradingTimezone = "America/Sao_Paulo"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ComplianceService stores per-asset rule sets and evaluates them
+// deterministically before a transfer or mint is signed.
+type ComplianceService struct {
+	DB *storage.DB
+}
+
+func NewComplianceService(db *storage.DB) *ComplianceService {
+	return &ComplianceService{DB: db}
+}
+
+// Movement is a transfer or mint to be checked against an asset's rules.
+// From is nil for mints.
+type Movement struct {
+	Asset     models.Asset
+	From      *models.User
+	To        models.User // Only SolanaPubKey is set for unregistered recipients
+	Amount    float64
+	Timestamp time.Time
+}
+
+// SetRules validates and stores the rule set of an asset.
+func (s *ComplianceService) SetRules(assetID string, rules models.ComplianceRules) (models.AssetRuleSet, error) {
+	if err := validateRules(rules); err != nil {
+		return models.AssetRuleSet{}, err
+	}
+	_, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return models.AssetRuleSet{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.AssetRuleSet{}, ErrAssetNotFound
+	}
+
+	for i, j := range rules.AllowedJurisdictions {
+		rules.AllowedJurisdictions[i] = strings.ToUpper(j)
+	}
+	ruleSet, err := s.DB.SaveRuleSet(assetID, rules)
+	if err != nil {
+		return models.AssetRuleSet{}, fmt.Errorf("failed to save rule set: %w", err)
+	}
+	return ruleSet, nil
+}
+
+// GetRules returns the rule set of an asset; assets without one get an empty,
+// permissive rule set at version 0.
+func (s *ComplianceService) GetRules(assetID string) (models.AssetRuleSet, error) {
+	ruleSet, found, err := s.DB.GetRuleSet(assetID)
+	if err != nil {
+		return models.AssetRuleSet{}, fmt.Errorf("error fetching rule set: %w", err)
+	}
+	if !found {
+		return models.AssetRuleSet{AssetID: assetID}, nil
+	}
+	return ruleSet, nil
+}
+
+// Check evaluates every rule of the asset against a movement and returns a
+// *ComplianceError naming the first rule that fails.
+func (s *ComplianceService) Check(m Movement) error {
+	ruleSet, err := s.GetRules(m.Asset.ID)
+	if err != nil {
+		return err
+	}
+	rules := ruleSet.Rules
+
+	if err := checkAssetStatus(m.Asset, m.From == nil); err != nil {
+		return err
+	}
+
+	if m.From != nil {
+		if rules.MinTransferAmount > 0 && m.Amount < rules.MinTransferAmount {
+			return violation(RuleMinTransferAmount, "amount %g is below the minimum of %g", m.Amount, rules.MinTransferAmount)
+		}
+		if len(rules.TradingWindows) > 0 && !inTradingWindow(rules.TradingWindows, m.Timestamp) {
+			return violation(RuleTradingWindows, "transfers are not allowed at %s", m.Timestamp.Format(time.RFC3339))
+		}
+		if rules.RequireKYC && !m.From.IsKYCVerified(rules.MinKYCLevel, m.Timestamp) {
+			return violation(RuleRequireKYC, "sender has no valid KYC verification of level %d or above", max(rules.MinKYCLevel, 1))
+		}
+	}
+
+	if slices.Contains(rules.ExemptWallets, m.To.SolanaPubKey) {
+		return nil
+	}
+
+	if rules.RequireKYC && !m.To.IsKYCVerified(rules.MinKYCLevel, m.Timestamp) {
+		return violation(RuleRequireKYC, "recipient has no valid KYC verification of level %d or above", max(rules.MinKYCLevel, 1))
+	}
+	if len(rules.AllowedJurisdictions) > 0 {
+		if m.To.Jurisdiction == nil || !slices.Contains(rules.AllowedJurisdictions, strings.ToUpper(*m.To.Jurisdiction)) {
+			return violation(RuleAllowedJurisdictions, "recipient jurisdiction is not in %v", rules.AllowedJurisdictions)
+		}
+	}
+	if len(rules.InvestorCategories) > 0 {
+		if m.To.InvestorCategory == nil || !slices.Contains(rules.InvestorCategories, *m.To.InvestorCategory) {
+			return violation(RuleInvestorCategories, "recipient investor category is not in %v", rules.InvestorCategories)
+		}
+	}
+
+	if rules.MaxHolders == 0 && rules.MaxPercentPerHolder == 0 {
+		return nil
+	}
+	holdings, err := s.DB.GetHoldingsByAssetID(m.Asset.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching holdings: %w", err)
+	}
+	return checkConcentration(rules, m, holdings)
+}
+
+// checkConcentration enforces the holder cap and the per-holder limit on the
+// cap table as it would be after the movement.
+func checkConcentration(rules models.ComplianceRules, m Movement, holdings []models.CapTableEntry) error {
+	var recipientHolding, senderHolding float64
+	holders := 0
+	for _, h := range holdings {
+		if h.Amount <= 0 {
+			continue
+		}
+		holders++
+		switch {
+		case h.SolanaPubKey == m.To.SolanaPubKey:
+			recipientHolding = h.Amount
+		case m.From != nil && h.SolanaPubKey == m.From.SolanaPubKey:
+			senderHolding = h.Amount
+		}
+	}
+
+	if rules.MaxHolders > 0 {
+		after := holders
+		if recipientHolding == 0 {
+			after++
+		}
+		if m.From != nil && senderHolding > 0 && senderHolding <= m.Amount {
+			after-- // Sender exits the cap table
+		}
+		if after > rules.MaxHolders {
+			return violation(RuleMaxHolders, "asset would have %d holders, above the limit of %d", after, rules.MaxHolders)
+		}
+	}
+	if rules.MaxPercentPerHolder > 0 && m.Asset.TotalShares > 0 {
+		percent := (recipientHolding + m.Amount) / m.Asset.TotalShares * 100
+		if percent > rules.MaxPercentPerHolder {
+			return violation(RuleMaxPercentPerHolder, "recipient would hold %.4f%% of the asset, above the limit of %g%%", percent, rules.MaxPercentPerHolder)
+		}
+	}
+	return nil
+}
+
+// inTradingWindow reports whether t falls inside any of the windows.
+func inTradingWindow(windows []models.TradingWindow, t time.Time) bool {
+	for _, w := range windows {
+		tz := w.Timezone
+		if tz == "" {
+			tz = defaultTradingTimezone
+		}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			continue // Rejected by validateRules; never matches
+		}
+		local := t.In(loc)
+		if len(w.Days) > 0 && !slices.ContainsFunc(w.Days, func(d string) bool { return weekdays[d] == local.Weekday() }) {
+			continue
+		}
+		now := local.Format("15:04")
+		if now >= w.Start && now < w.End {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRules rejects rule sets that could never be evaluated consistently.
+func validateRules(rules models.ComplianceRules) error {
+	if rules.MaxHolders < 0 {
+		return invalidf("max_holders cannot be negative")
+	}
+	if rules.MaxPercentPerHolder < 0 || rules.MaxPercentPerHolder > 100 {
+		return invalidf("max_percent_per_holder must be between 0 and 100")
+	}
+	if rules.MinKYCLevel < 0 || rules.MinKYCLevel > 2 {
+		return invalidf("min_kyc_level must be between 0 and 2")
+	}
+	if rules.MinTransferAmount < 0 {
+		return invalidf("min_transfer_amount cannot be negative")
+	}
+	for _, j := range rules.AllowedJurisdictions {
+		if len(j) != 2 {
+			return invalidf("invalid jurisdiction %q: expected an ISO 3166-1 alpha-2 code", j)
+		}
+	}
+	for _, c := range rules.InvestorCategories {
+		if !IsInvestorCategory(c) {
+			return invalidf("invalid investor category %q", c)
+		}
+	}
+	for _, w := range rules.TradingWindows {
+		for _, d := range w.Days {
+			if _, ok := weekdays[d]; !ok {
+				return invalidf("invalid trading window day %q", d)
+			}
+		}
+		start, errStart := time.Parse("15:04", w.Start)
+		end, errEnd := time.Parse("15:04", w.End)
+		if errStart != nil || errEnd != nil || !start.Before(end) {
+			return invalidf("trading window needs start and end as HH:MM with start before end")
+		}
+		if w.Timezone != "" {
+			if _, err := time.LoadLocation(w.Timezone); err != nil {
+				return invalidf("invalid trading window timezone %q", w.Timezone)
+			}
+		}
+	}
+	return nil
+}
+
+// IsInvestorCategory reports whether c is a known investor category.
+func IsInvestorCategory(c string) bool {
+	switch c {
+	case models.InvestorCategoryRetail, models.InvestorCategoryQualified, models.InvestorCategoryProfessional:
+		return true
+	}
+	return false
+}
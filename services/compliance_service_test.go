@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestValidateRules(t *testing.T) {
+	weekdays := models.TradingWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "10:00", End: "17:00"}
+	window := func(start, end, timezone string, days ...string) []models.TradingWindow {
+		return []models.TradingWindow{{Days: days, Start: start, End: end, Timezone: timezone}}
+	}
+
+	tests := []struct {
+		name    string
+		rules   models.ComplianceRules
+		wantErr bool
+	}{
+		{name: "empty rule set", rules: models.ComplianceRules{}},
+		{
+			name: "every rule",
+			rules: models.ComplianceRules{
+				AllowedJurisdictions: []string{"BR", "pt"},
+				InvestorCategories:   []string{models.InvestorCategoryQualified, models.InvestorCategoryProfessional},
+				MaxHolders:           100, MaxPercentPerHolder: 100, MinTransferAmount: 0.5,
+				TradingWindows: []models.TradingWindow{weekdays}, RequireKYC: true, MinKYCLevel: 2,
+			},
+		},
+		{name: "window in another timezone", rules: models.ComplianceRules{TradingWindows: window("09:30", "16:00", "America/New_York")}},
+		{name: "negative max holders", rules: models.ComplianceRules{MaxHolders: -1}, wantErr: true},
+		{name: "negative percent", rules: models.ComplianceRules{MaxPercentPerHolder: -1}, wantErr: true},
+		{name: "percent above 100", rules: models.ComplianceRules{MaxPercentPerHolder: 100.01}, wantErr: true},
+		{name: "negative kyc level", rules: models.ComplianceRules{MinKYCLevel: -1}, wantErr: true},
+		{name: "unknown kyc level", rules: models.ComplianceRules{MinKYCLevel: 3}, wantErr: true},
+		{name: "negative minimum", rules: models.ComplianceRules{MinTransferAmount: -0.1}, wantErr: true},
+		{name: "alpha-3 jurisdiction", rules: models.ComplianceRules{AllowedJurisdictions: []string{"BRA"}}, wantErr: true},
+		{name: "unknown investor category", rules: models.ComplianceRules{InvestorCategories: []string{"vip"}}, wantErr: true},
+		{name: "unknown day", rules: models.ComplianceRules{TradingWindows: window("10:00", "17:00", "", "monday")}, wantErr: true},
+		{name: "window ending at its start", rules: models.ComplianceRules{TradingWindows: window("10:00", "10:00", "")}, wantErr: true},
+		{name: "window ending before its start", rules: models.ComplianceRules{TradingWindows: window("17:00", "10:00", "")}, wantErr: true},
+		{name: "window without end", rules: models.ComplianceRules{TradingWindows: window("10:00", "", "")}, wantErr: true},
+		{name: "hour out of range", rules: models.ComplianceRules{TradingWindows: window("10:00", "25:00", "")}, wantErr: true},
+		{name: "unknown timezone", rules: models.ComplianceRules{TradingWindows: window("10:00", "17:00", "America/Atlantis")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRules(tt.rules)
+			var validation *ValidationError
+			if tt.wantErr && !errors.As(err, &validation) {
+				t.Fatalf("validateRules() error = %v, want a *ValidationError", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("validateRules() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestInTradingWindow(t *testing.T) {
+	saoPaulo, err := time.LoadLocation(defaultTradingTimezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day int, clock string) time.Time { // March 2025; the 10th is a Monday
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2025, 3, day, c.Hour(), c.Minute(), 0, 0, saoPaulo)
+	}
+	weekdays := []models.TradingWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "10:00", End: "17:00"}}
+
+	tests := []struct {
+		name    string
+		windows []models.TradingWindow
+		t       time.Time
+		want    bool
+	}{
+		{"opening minute", weekdays, at(10, "10:00"), true},
+		{"before opening", weekdays, at(10, "09:59"), false},
+		{"last minute", weekdays, at(10, "16:59"), true},
+		{"closing minute is excluded", weekdays, at(10, "17:00"), false},
+		{"saturday", weekdays, at(15, "12:00"), false},
+		{"given in UTC", weekdays, time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC), true},
+		{"UTC time before the local opening", weekdays, time.Date(2025, 3, 10, 12, 59, 0, 0, time.UTC), false},
+		{
+			name:    "local day differs from the UTC day",
+			windows: []models.TradingWindow{{Days: []string{"sun"}, Start: "21:00", End: "23:00"}},
+			t:       time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC), // Sunday 22:00 in São Paulo
+			want:    true,
+		},
+		{
+			name:    "every day without days",
+			windows: []models.TradingWindow{{Start: "10:00", End: "17:00"}},
+			t:       at(15, "12:00"),
+			want:    true,
+		},
+		{
+			name:    "window in its own timezone",
+			windows: []models.TradingWindow{{Start: "09:30", End: "16:00", Timezone: "America/New_York"}},
+			t:       time.Date(2025, 3, 10, 13, 30, 0, 0, time.UTC), // 09:30 in New York
+			want:    true,
+		},
+		{
+			name:    "any window matches",
+			windows: append([]models.TradingWindow{{Days: []string{"sat"}, Start: "09:00", End: "12:00"}}, weekdays...),
+			t:       at(15, "11:00"),
+			want:    true,
+		},
+		{
+			name:    "unknown timezone never matches",
+			windows: []models.TradingWindow{{Start: "00:00", End: "23:59", Timezone: "America/Atlantis"}},
+			t:       at(10, "12:00"),
+			want:    false,
+		},
+		{"no windows", nil, at(10, "12:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inTradingWindow(tt.windows, tt.t); got != tt.want {
+				t.Fatalf("inTradingWindow(%s) = %v, want %v", tt.t.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckConcentration(t *testing.T) {
+	asset := models.Asset{ID: "a1", TotalShares: 1000}
+	holdings := []models.CapTableEntry{
+		{SolanaPubKey: "alice", Amount: 600},
+		{SolanaPubKey: "bob", Amount: 300},
+		{SolanaPubKey: "carol", Amount: 100},
+		{SolanaPubKey: "dave", Amount: 0}, // Sold out; not a holder
+	}
+	transfer := func(from, to string, amount float64) Movement {
+		return Movement{Asset: asset, From: &models.User{SolanaPubKey: from}, To: models.User{SolanaPubKey: to}, Amount: amount}
+	}
+	mint := func(to string, amount float64) Movement {
+		return Movement{Asset: asset, To: models.User{SolanaPubKey: to}, Amount: amount}
+	}
+
+	tests := []struct {
+		name     string
+		rules    models.ComplianceRules
+		movement Movement
+		wantRule string // Empty when allowed
+	}{
+		{name: "no limits", movement: mint("erin", 5000)},
+		{name: "new holder up to the cap", rules: models.ComplianceRules{MaxHolders: 4}, movement: transfer("alice", "erin", 10)},
+		{name: "new holder over the cap", rules: models.ComplianceRules{MaxHolders: 3}, movement: transfer("alice", "erin", 10), wantRule: RuleMaxHolders},
+		{name: "existing holder at the cap", rules: models.ComplianceRules{MaxHolders: 3}, movement: transfer("alice", "bob", 10)},
+		{name: "sold out holder counts as new", rules: models.ComplianceRules{MaxHolders: 3}, movement: transfer("alice", "dave", 10), wantRule: RuleMaxHolders},
+		{name: "sender leaving makes room", rules: models.ComplianceRules{MaxHolders: 3}, movement: transfer("carol", "erin", 100)},
+		{name: "sender keeping a balance does not", rules: models.ComplianceRules{MaxHolders: 3}, movement: transfer("carol", "erin", 99), wantRule: RuleMaxHolders},
+		{name: "mint to a new holder over the cap", rules: models.ComplianceRules{MaxHolders: 3}, movement: mint("erin", 1), wantRule: RuleMaxHolders},
+		{name: "holding up to the limit", rules: models.ComplianceRules{MaxPercentPerHolder: 40}, movement: mint("bob", 100)},
+		{name: "holding over the limit", rules: models.ComplianceRules{MaxPercentPerHolder: 40}, movement: mint("bob", 100.5), wantRule: RuleMaxPercentPerHolder},
+		{name: "new holder up to the limit", rules: models.ComplianceRules{MaxPercentPerHolder: 40}, movement: transfer("alice", "erin", 400)},
+		{name: "new holder over the limit", rules: models.ComplianceRules{MaxPercentPerHolder: 40}, movement: transfer("alice", "erin", 401), wantRule: RuleMaxPercentPerHolder},
+		{
+			name:     "limit skipped without total shares",
+			rules:    models.ComplianceRules{MaxPercentPerHolder: 40},
+			movement: Movement{Asset: models.Asset{ID: "a1"}, To: models.User{SolanaPubKey: "bob"}, Amount: 1000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConcentration(tt.rules, tt.movement, holdings)
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("checkConcentration() error = %v", err)
+				}
+				return
+			}
+			var violation *ComplianceError
+			if !errors.As(err, &violation) || violation.Rule != tt.wantRule {
+				t.Fatalf("checkConcentration() error = %v, want rule %s", err, tt.wantRule)
+			}
+		})
+	}
+}
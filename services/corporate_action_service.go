@@ -0,0 +1,538 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// corporateActionPollInterval is how often the scheduler looks for actions
+// whose effective date has passed.
+const corporateActionPollInterval = time.Minute
+
+var (
+	// ErrCorporateActionNotFound is returned when the requested corporate action does not exist.
+	ErrCorporateActionNotFound = fmt.Errorf("corporate action %w", ErrNotFound)
+	// ErrCorporateActionNotExecutable is returned when an action is running or already completed.
+	ErrCorporateActionNotExecutable = fmt.Errorf("%w: corporate action is already executing or completed", ErrConflict)
+	// ErrCorporateActionNotDue is returned when an action is executed before its effective date.
+	ErrCorporateActionNotDue = fmt.Errorf("%w: corporate action has not reached its effective date", ErrConflict)
+	// ErrNoBurnRequired is returned when an approval is requested for a holder with nothing to burn.
+	ErrNoBurnRequired = fmt.Errorf("%w: holder has no burn pending for this corporate action", ErrConflict)
+)
+
+// CorporateActionService schedules and executes splits and reverse splits.
+// Splits mint the extra tokens to every holder; reverse splits burn from each
+// holder's ATA, which requires the holder to have approved the FeePayer as
+// delegate beforehand (see PrepareApproval).
+type CorporateActionService struct {
+	DB        *storage.DB
+	SolanaS   *SolanaIntegrationService
+	Snapshots *SnapshotService
+	stopCh    chan struct{}
+}
+
+func NewCorporateActionService(db *storage.DB, solanaS *SolanaIntegrationService, snapshots *SnapshotService) *CorporateActionService {
+	return &CorporateActionService{DB: db, SolanaS: solanaS, Snapshots: snapshots, stopCh: make(chan struct{})}
+}
+
+// CreateCorporateActionInput describes a new split or reverse split.
+type CreateCorporateActionInput struct {
+	AssetID         string    `json:"-"`
+	ActionType      string    `json:"action_type"`
+	RatioFrom       int64     `json:"ratio_from"`
+	RatioTo         int64     `json:"ratio_to"`
+	EffectiveDate   time.Time `json:"effective_date"`
+	RoundingUnit    float64   `json:"rounding_unit,omitempty"` // Defaults to one atomic unit (1e-9)
+	CashInLieuPrice float64   `json:"cash_in_lieu_price,omitempty"`
+}
+
+// CreateCorporateAction validates and schedules a corporate action.
+func (s *CorporateActionService) CreateCorporateAction(in CreateCorporateActionInput) (models.CorporateAction, error) {
+	if in.RatioFrom <= 0 || in.RatioTo <= 0 {
+		return models.CorporateAction{}, invalidf("ratio_from and ratio_to must be positive")
+	}
+	switch in.ActionType {
+	case models.CorporateActionSplit:
+		if in.RatioTo <= in.RatioFrom {
+			return models.CorporateAction{}, invalidf("a split requires ratio_to greater than ratio_from")
+		}
+	case models.CorporateActionReverseSplit:
+		if in.RatioTo >= in.RatioFrom {
+			return models.CorporateAction{}, invalidf("a reverse split requires ratio_to less than ratio_from")
+		}
+	default:
+		return models.CorporateAction{}, invalidf("action_type must be %q or %q", models.CorporateActionSplit, models.CorporateActionReverseSplit)
+	}
+	if in.EffectiveDate.IsZero() {
+		return models.CorporateAction{}, invalidf("effective_date is required")
+	}
+	if in.RoundingUnit == 0 {
+		in.RoundingUnit = 1e-9
+	}
+	if toAtomic(in.RoundingUnit, 9) == 0 {
+		return models.CorporateAction{}, invalidf("rounding_unit must be at least 0.000000001")
+	}
+	if in.CashInLieuPrice < 0 {
+		return models.CorporateAction{}, invalidf("cash_in_lieu_price cannot be negative")
+	}
+
+	asset, found, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.CorporateAction{}, ErrAssetNotFound
+	}
+	if asset.MintAddress == "" {
+		return models.CorporateAction{}, invalidf("asset %s has not been tokenized", asset.ID)
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.CorporateAction{}, err
+	}
+
+	action := models.CorporateAction{
+		ID:              uuid.New().String(),
+		AssetID:         asset.ID,
+		ActionType:      in.ActionType,
+		RatioFrom:       in.RatioFrom,
+		RatioTo:         in.RatioTo,
+		EffectiveDate:   in.EffectiveDate,
+		RoundingUnit:    in.RoundingUnit,
+		CashInLieuPrice: in.CashInLieuPrice,
+		Status:          models.CorporateActionStatusScheduled,
+		CreatedAt:       time.Now(),
+	}
+	if err := s.DB.SaveCorporateAction(action); err != nil {
+		return models.CorporateAction{}, fmt.Errorf("failed to save corporate action: %w", err)
+	}
+	return action, nil
+}
+
+// GetCorporateAction returns a corporate action together with its adjustments.
+func (s *CorporateActionService) GetCorporateAction(id string) (models.CorporateAction, error) {
+	action, found, err := s.DB.GetCorporateAction(id)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("error fetching corporate action: %w", err)
+	}
+	if !found {
+		return models.CorporateAction{}, ErrCorporateActionNotFound
+	}
+	action.Adjustments, err = s.DB.GetAdjustments(action.ID)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("error fetching adjustments: %w", err)
+	}
+	return action, nil
+}
+
+// StartExecution claims a due corporate action and executes it in the
+// background. Calling it again on a partially applied action retries the
+// adjustments that are still pending or failed.
+func (s *CorporateActionService) StartExecution(id string) (models.CorporateAction, error) {
+	action, found, err := s.DB.GetCorporateAction(id)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("error fetching corporate action: %w", err)
+	}
+	if !found {
+		return models.CorporateAction{}, ErrCorporateActionNotFound
+	}
+	if time.Now().Before(action.EffectiveDate) {
+		return models.CorporateAction{}, ErrCorporateActionNotDue
+	}
+	asset, _, err := s.DB.GetAsset(action.AssetID)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if err := checkAssetStatus(asset, true); err != nil {
+		return models.CorporateAction{}, err
+	}
+
+	claimed, err := s.DB.ClaimCorporateAction(id, models.CorporateActionStatusExecuting,
+		models.CorporateActionStatusScheduled, models.CorporateActionStatusPartiallyApplied)
+	if err != nil {
+		return models.CorporateAction{}, fmt.Errorf("failed to claim corporate action: %w", err)
+	}
+	if !claimed {
+		return models.CorporateAction{}, ErrCorporateActionNotExecutable
+	}
+	action.Status = models.CorporateActionStatusExecuting
+
+	go s.execute(action)
+	return action, nil
+}
+
+// Stop signals the scheduler to shut down.
+func (s *CorporateActionService) Stop() {
+	close(s.stopCh)
+}
+
+// StartScheduler executes scheduled actions once their effective date passes.
+// Blocks until Stop() is called.
+func (s *CorporateActionService) StartScheduler() {
+	ticker := time.NewTicker(corporateActionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDueActions()
+		select {
+		case <-s.stopCh:
+			log.Println("Corporate action scheduler stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueActions starts every scheduled action whose effective date has passed.
+func (s *CorporateActionService) runDueActions() {
+	due, err := s.DB.GetDueCorporateActions(time.Now())
+	if err != nil {
+		log.Printf("Corporate action scheduler: failed to load due actions: %v", err)
+		return
+	}
+	for _, action := range due {
+		if _, err := s.StartExecution(action.ID); err != nil && !errors.Is(err, ErrCorporateActionNotExecutable) {
+			log.Printf("Corporate action scheduler: failed to start %s: %v", action.ID, err)
+		}
+	}
+}
+
+// PrepareApproval builds the transaction in which a holder approves the
+// FeePayer to burn the amount a reverse split takes from their ATA. Before
+// execution the amount is computed from the holder's current holding.
+func (s *CorporateActionService) PrepareApproval(actionID, userID string) (string, error) {
+	action, err := s.GetCorporateAction(actionID)
+	if err != nil {
+		return "", err
+	}
+	user, found, err := s.DB.GetUser(userID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found {
+		return "", ErrUserNotFound
+	}
+	asset, found, err := s.DB.GetAsset(action.AssetID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return "", ErrAssetNotFound
+	}
+
+	var burn uint64
+	if action.SnapshotID == nil {
+		holdings, err := s.DB.GetHoldingsByAssetID(action.AssetID)
+		if err != nil {
+			return "", fmt.Errorf("error fetching holdings: %w", err)
+		}
+		for _, h := range holdings {
+			if h.SolanaPubKey == user.SolanaPubKey {
+				before := toAtomic(h.Amount, 9)
+				after, _ := s.adjustedHolding(action, before)
+				if after < before {
+					burn = before - after
+				}
+			}
+		}
+	} else {
+		for _, a := range action.Adjustments {
+			if a.SolanaPubKey == user.SolanaPubKey && a.DeltaAtomic < 0 && a.Status != models.AdjustmentStatusApplied {
+				burn = uint64(-a.DeltaAtomic)
+			}
+		}
+	}
+	if burn == 0 {
+		return "", ErrNoBurnRequired
+	}
+
+	owner, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid user public key: %w", err)
+	}
+	mint, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid asset mint address: %w", err)
+	}
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceCorporateAction, ID: action.ID})
+	return solanaS.PrepareApproveDelegateTransaction(mint, owner, burn)
+}
+
+// adjustedHolding applies the action's ratio to a holding in atomic units. It
+// returns the post-action holding, rounded down to the rounding unit, and the
+// fractional leftover in post-action shares.
+func (s *CorporateActionService) adjustedHolding(action models.CorporateAction, before uint64) (uint64, float64) {
+	exact := new(big.Int).Mul(new(big.Int).SetUint64(before), big.NewInt(action.RatioTo))
+	after := new(big.Int).Quo(exact, big.NewInt(action.RatioFrom))
+	unit := new(big.Int).SetUint64(toAtomic(action.RoundingUnit, 9))
+	after.Sub(after, new(big.Int).Rem(after, unit))
+
+	// leftover = (before*to - after*from) / from, in atomic units
+	leftover := new(big.Rat).SetFrac(
+		new(big.Int).Sub(exact, new(big.Int).Mul(after, big.NewInt(action.RatioFrom))),
+		big.NewInt(action.RatioFrom*1e9),
+	)
+	fractional, _ := leftover.Float64()
+	return after.Uint64(), fractional
+}
+
+// execute computes the adjustments on the first run, reconciles previously
+// sent batches, applies the remaining adjustments and settles the action.
+func (s *CorporateActionService) execute(action models.CorporateAction) {
+	if action.SnapshotID == nil {
+		var err error
+		if action, err = s.computeAdjustments(action); err != nil {
+			log.Printf("Corporate action %s: failed to compute adjustments: %v", action.ID, err)
+			if err := s.DB.UpdateCorporateActionStatus(action.ID, models.CorporateActionStatusScheduled); err != nil {
+				log.Printf("Corporate action %s: failed to reset status: %v", action.ID, err)
+			}
+			return
+		}
+	}
+
+	asset, found, err := s.DB.GetAsset(action.AssetID)
+	if err != nil || !found {
+		log.Printf("Corporate action %s: failed to load asset %s: %v", action.ID, action.AssetID, err)
+		s.settle(action.ID)
+		return
+	}
+	mint, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		log.Printf("Corporate action %s: asset %s has an invalid mint address: %v", action.ID, asset.ID, err)
+		s.settle(action.ID)
+		return
+	}
+
+	if err := s.reconcileSentAdjustments(action.AssetID, action.ID); err != nil {
+		log.Printf("Corporate action %s: failed to reconcile sent adjustments: %v", action.ID, err)
+	}
+
+	adjustments, err := s.DB.GetAdjustments(action.ID, models.AdjustmentStatusPending, models.AdjustmentStatusFailed)
+	if err != nil {
+		log.Printf("Corporate action %s: failed to load adjustments: %v", action.ID, err)
+		s.settle(action.ID)
+		return
+	}
+
+	var mints []models.HoldingAdjustment
+	for _, a := range adjustments {
+		if a.Attempts >= maxPayoutAttempts {
+			continue // Needs manual review
+		}
+		if a.DeltaAtomic < 0 {
+			// Burned one holder at a time, so a missing approval only fails that holder
+			s.applyBatch(action.AssetID, mint, []models.HoldingAdjustment{a})
+			continue
+		}
+		mints = append(mints, a)
+		if len(mints) == payoutBatchSize {
+			s.applyBatch(action.AssetID, mint, mints)
+			mints = nil
+		}
+	}
+	if len(mints) > 0 {
+		s.applyBatch(action.AssetID, mint, mints)
+	}
+
+	s.settle(action.ID)
+}
+
+// computeAdjustments snapshots the holdings at the effective date, computes
+// every holder's adjustment and the new total_shares, and stores them.
+func (s *CorporateActionService) computeAdjustments(action models.CorporateAction) (models.CorporateAction, error) {
+	asset, found, err := s.DB.GetAsset(action.AssetID)
+	if err != nil {
+		return action, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return action, ErrAssetNotFound
+	}
+
+	recordDate := action.EffectiveDate
+	snapshot, err := s.Snapshots.CreateSnapshot(action.AssetID,
+		fmt.Sprintf("corporate-action-%s-%d", action.ID, time.Now().Unix()), &recordDate, nil)
+	if err != nil {
+		return action, err
+	}
+	holdings, err := s.DB.GetSnapshotHoldings(snapshot.ID)
+	if err != nil {
+		return action, fmt.Errorf("error fetching snapshot holdings: %w", err)
+	}
+
+	now := time.Now()
+	adjustments := make([]models.HoldingAdjustment, 0, len(holdings))
+	for _, h := range holdings {
+		before := toAtomic(h.Amount, 9)
+		after, fractional := s.adjustedHolding(action, before)
+		status := models.AdjustmentStatusPending
+		if after == before {
+			status = models.AdjustmentStatusApplied // Only a cash-in-lieu record, nothing to move on chain
+		}
+		adjustments = append(adjustments, models.HoldingAdjustment{
+			ID:               uuid.New().String(),
+			ActionID:         action.ID,
+			OwnerID:          h.OwnerID,
+			SolanaPubKey:     h.SolanaPubKey,
+			HoldingBefore:    h.Amount,
+			HoldingAfter:     fromAtomic(after, 9),
+			DeltaAtomic:      int64(after) - int64(before),
+			FractionalShares: fractional,
+			CashInLieuAmount: fractional * action.CashInLieuPrice,
+			Status:           status,
+			UpdatedAt:        now,
+		})
+	}
+
+	sharesAfterAtomic, _ := s.adjustedHolding(action, toAtomic(asset.TotalShares, 9))
+	sharesBefore := asset.TotalShares
+	sharesAfter := fromAtomic(sharesAfterAtomic, 9)
+	action.SnapshotID = &snapshot.ID
+	action.SharesBefore = &sharesBefore
+	action.SharesAfter = &sharesAfter
+	action.ExecutedAt = &now
+
+	if err := s.DB.StartCorporateAction(action, adjustments); err != nil {
+		return action, fmt.Errorf("failed to store adjustments: %w", err)
+	}
+	return action, nil
+}
+
+// applyBatch signs one batch of mints or burns, records it on the
+// adjustments before sending, and waits for its confirmation.
+func (s *CorporateActionService) applyBatch(assetID string, mint solana.PublicKey, batch []models.HoldingAdjustment) {
+	ids := make([]string, 0, len(batch))
+	moves := make([]TokenTransfer, 0, len(batch))
+	valid := make([]models.HoldingAdjustment, 0, len(batch))
+	for _, a := range batch {
+		owner, err := solana.PublicKeyFromBase58(a.SolanaPubKey)
+		if err != nil {
+			log.Printf("Corporate action on asset %s: adjustment %s has an invalid holder key: %v", assetID, a.ID, err)
+			if err := s.DB.MarkAdjustmentsFailed([]string{a.ID}, "invalid holder public key", true); err != nil {
+				log.Printf("Corporate action on asset %s: failed to record adjustment failure: %v", assetID, err)
+			}
+			continue
+		}
+		amount := a.DeltaAtomic
+		if amount < 0 {
+			amount = -amount
+		}
+		ids = append(ids, a.ID)
+		moves = append(moves, TokenTransfer{Owner: owner, Amount: uint64(amount)})
+		valid = append(valid, a)
+	}
+	if len(valid) == 0 {
+		return
+	}
+	batch = valid
+
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceCorporateAction, ID: batch[0].ActionID})
+	var signedTx string
+	var sig solana.Signature
+	var err error
+	if batch[0].DeltaAtomic < 0 {
+		signedTx, sig, err = solanaS.SignBurnFromAccounts(mint, moves)
+	} else {
+		signedTx, sig, err = solanaS.SignMintToAccounts(mint, moves)
+	}
+	if err != nil {
+		log.Printf("Corporate action on asset %s: failed to sign batch of %d adjustments: %v", assetID, len(batch), err)
+		if err := s.DB.MarkAdjustmentsFailed(ids, err.Error(), true); err != nil {
+			log.Printf("Corporate action on asset %s: failed to record adjustment failure: %v", assetID, err)
+		}
+		return
+	}
+	// Recorded before sending: a crash in between leaves the adjustments sent,
+	// and reconciliation retries them only once the transaction can no longer land
+	if err := s.DB.MarkAdjustmentsSent(ids, sig.String()); err != nil {
+		log.Printf("Corporate action on asset %s: failed to record batch tx %s, not sending it: %v", assetID, sig, err)
+		return
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Corporate action on asset %s: batch tx %s not sent: %v; will reconcile on next execution", assetID, sig, err)
+		return
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		log.Printf("Corporate action on asset %s: batch tx %s failed: %v", assetID, sig, err)
+		if err := s.DB.MarkAdjustmentsFailed(ids, err.Error(), false); err != nil {
+			log.Printf("Corporate action on asset %s: failed to record adjustment failure: %v", assetID, err)
+		}
+	case err != nil:
+		log.Printf("Corporate action on asset %s: could not check batch tx %s: %v; will reconcile on next execution", assetID, sig, err)
+	case confirmed:
+		if err := s.DB.ApplyAdjustments(assetID, batch, sig.String()); err != nil {
+			log.Printf("Corporate action on asset %s: failed to book adjustments for tx %s: %v", assetID, sig, err)
+		}
+	default:
+		log.Printf("Corporate action on asset %s: batch tx %s not confirmed yet; will reconcile on next execution", assetID, sig)
+	}
+}
+
+// reconcileSentAdjustments resolves adjustments whose transaction was sent
+// but whose confirmation was not observed.
+func (s *CorporateActionService) reconcileSentAdjustments(assetID, actionID string) error {
+	sent, err := s.DB.GetAdjustments(actionID, models.AdjustmentStatusSent)
+	if err != nil {
+		return err
+	}
+
+	byTx := make(map[string][]models.HoldingAdjustment)
+	for _, a := range sent {
+		if a.TransactionID != nil {
+			byTx[*a.TransactionID] = append(byTx[*a.TransactionID], a)
+		}
+	}
+	for txID, batch := range byTx {
+		ids := make([]string, len(batch))
+		for i, a := range batch {
+			ids[i] = a.ID
+		}
+		sig, err := solana.SignatureFromBase58(txID)
+		if err != nil {
+			return fmt.Errorf("invalid transaction id %s: %w", txID, err)
+		}
+		confirmed, err := s.SolanaS.GetTransactionConfirmation(sig)
+		switch {
+		case errors.Is(err, ErrTransactionFailed):
+			if err := s.DB.MarkAdjustmentsFailed(ids, err.Error(), false); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case confirmed:
+			if err := s.DB.ApplyAdjustments(assetID, batch, txID); err != nil {
+				return err
+			}
+		case time.Since(batch[0].UpdatedAt) > payoutExpiry:
+			// Never landed and the blockhash has expired: safe to retry
+			if err := s.DB.MarkAdjustmentsFailed(ids, "transaction "+txID+" was not confirmed", false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// settle sets the final status of a corporate action after an execution run.
+func (s *CorporateActionService) settle(actionID string) {
+	status := models.CorporateActionStatusCompleted
+	open, err := s.DB.GetAdjustments(actionID, models.AdjustmentStatusPending, models.AdjustmentStatusSent, models.AdjustmentStatusFailed)
+	if err != nil || len(open) > 0 {
+		status = models.CorporateActionStatusPartiallyApplied
+	}
+	if err := s.DB.UpdateCorporateActionStatus(actionID, status); err != nil {
+		log.Printf("Corporate action %s: failed to update status: %v", actionID, err)
+		return
+	}
+	log.Printf("Corporate action %s execution finished with status %s", actionID, status)
+}
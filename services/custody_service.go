@@ -0,0 +1,433 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCustodyNotConfigured is returned when custodial wallets are used without a master key.
+	ErrCustodyNotConfigured = &ValidationError{Msg: "custodial wallets are not configured"}
+	// ErrWalletNotFound is returned when the user has no custodial wallet.
+	ErrWalletNotFound = fmt.Errorf("custodial wallet %w", ErrNotFound)
+	// ErrWalletExported is returned when using a wallet whose keys were already exported.
+	ErrWalletExported = fmt.Errorf("%w: custodial wallet keys were exported", ErrConflict)
+	// ErrExportNotAuthorized is returned when a wallet export is not signed by the user's authorization key.
+	ErrExportNotAuthorized = fmt.Errorf("%w: wallet export must be signed by the user's authorization key", ErrUnauthorized)
+)
+
+// walletExportChallengeTTL is how long a wallet export challenge can be signed.
+const walletExportChallengeTTL = 5 * time.Minute
+
+// CustodyService holds the keys of custodial users and signs their
+// transfers. Each wallet's keys are encrypted with its own random data key,
+// and the data key with the master key, so the master key never touches
+// wallet keys directly and can live in an HSM or KMS.
+type CustodyService struct {
+	DB          *storage.DB
+	masterKey   []byte // AES-256 key wrapping the data keys; nil when custody is disabled
+	masterKeyID string
+}
+
+// NewCustodyService creates a custody service from a base64-encoded 32-byte
+// master key. Without a key the service is disabled and refuses custodial users.
+func NewCustodyService(db *storage.DB, masterKeyBase64 string) (*CustodyService, error) {
+	s := &CustodyService{DB: db}
+	if masterKeyBase64 == "" {
+		return s, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(masterKeyBase64)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("custody master key must be 32 bytes, base64-encoded")
+	}
+	fingerprint := sha256.Sum256(key)
+	s.masterKey = key
+	s.masterKeyID = hex.EncodeToString(fingerprint[:8])
+	return s, nil
+}
+
+// custodialKeys are a wallet's decrypted private keys.
+type custodialKeys struct {
+	Solana solana.PrivateKey
+	EVM    *ecdsa.PrivateKey
+}
+
+// WalletExport carries a wallet's private keys, handed over to the user when
+// they move to self-custody. The keys are erased once the user signs
+// ConfirmationMessage with the exported Solana key.
+type WalletExport struct {
+	UserID              string `json:"user_id"`
+	SolanaPubKey        string `json:"solana_pub_key"`
+	SolanaPrivateKey    string `json:"solana_private_key"` // Base58, as wallets import it
+	EVMAddress          string `json:"evm_address"`
+	EVMPrivateKey       string `json:"evm_private_key"` // Hex
+	ConfirmationMessage string `json:"confirmation_message"`
+}
+
+// CreateCustodialUser generates a wallet for a new user and saves both. The
+// user's Solana public key and EVM address are the wallet's.
+// authorizationKey is a base58 ed25519 public key the user keeps on their
+// own device, without which the wallet's keys cannot be exported.
+func (s *CustodyService) CreateCustodialUser(user models.User, authorizationKey string) (models.User, error) {
+	if s.masterKey == nil {
+		return models.User{}, ErrCustodyNotConfigured
+	}
+	if _, err := solana.PublicKeyFromBase58(authorizationKey); err != nil {
+		return models.User{}, invalidf("authorization_key must be a base58 ed25519 public key")
+	}
+
+	wallet, err := s.newWallet(user.ID, authorizationKey)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.Custody = models.CustodyCustodial
+	user.SolanaPubKey = wallet.SolanaPubKey
+	user.EVMAddress = &wallet.EVMAddress
+	if err := s.DB.SaveCustodialUser(user, wallet); err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.User{}, ErrUserTaken
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// newWallet generates a user's keys and a random data key, encrypts the keys
+// with the data key and wraps the data key with the master key.
+func (s *CustodyService) newWallet(userID, authorizationKey string) (models.CustodialWallet, error) {
+	solanaKey, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("failed to generate Solana key: %w", err)
+	}
+	evmKey, err := crypto.GenerateKey()
+	if err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("failed to generate EVM key: %w", err)
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wallet := models.CustodialWallet{
+		UserID:           userID,
+		SolanaPubKey:     solanaKey.PublicKey().String(),
+		EVMAddress:       crypto.PubkeyToAddress(evmKey.PublicKey).Hex(),
+		MasterKeyID:      s.masterKeyID,
+		Status:           models.CustodialWalletActive,
+		AuthorizationKey: &authorizationKey,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+	// Each ciphertext is bound to its user and purpose, so ciphertexts cannot
+	// be swapped between rows or columns
+	if wallet.EncryptedDataKey, err = storage.SealGCM(s.masterKey, dataKey, "data-key:"+userID); err != nil {
+		return models.CustodialWallet{}, err
+	}
+	if wallet.EncryptedSolanaKey, err = storage.SealGCM(dataKey, solanaKey, "solana:"+userID); err != nil {
+		return models.CustodialWallet{}, err
+	}
+	if wallet.EncryptedEVMKey, err = storage.SealGCM(dataKey, crypto.FromECDSA(evmKey), "evm:"+userID); err != nil {
+		return models.CustodialWallet{}, err
+	}
+	return wallet, nil
+}
+
+// GetWallet retrieves a user's custodial wallet.
+func (s *CustodyService) GetWallet(userID string) (models.CustodialWallet, error) {
+	wallet, found, err := s.DB.GetCustodialWallet(userID)
+	if err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("error fetching custodial wallet: %w", err)
+	}
+	if !found {
+		return models.CustodialWallet{}, ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+// SetLimits replaces a wallet's transfer limits; nil removes a limit.
+func (s *CustodyService) SetLimits(userID string, maxTransfer, dailyLimit *float64) (models.CustodialWallet, error) {
+	if (maxTransfer != nil && *maxTransfer <= 0) || (dailyLimit != nil && *dailyLimit <= 0) {
+		return models.CustodialWallet{}, invalidf("limits must be positive")
+	}
+	wallet, found, err := s.DB.SetCustodialWalletLimits(userID, maxTransfer, dailyLimit)
+	if err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("error updating custodial wallet: %w", err)
+	}
+	if !found {
+		return models.CustodialWallet{}, ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+// RequestExport issues the message the user must sign with their
+// authorization key to export their wallet's keys. A new request replaces
+// any earlier message.
+func (s *CustodyService) RequestExport(userID string) (models.WalletExportChallenge, error) {
+	wallet, err := s.GetWallet(userID)
+	if err != nil {
+		return models.WalletExportChallenge{}, err
+	}
+	if wallet.Status == models.CustodialWalletExported {
+		return models.WalletExportChallenge{}, ErrWalletExported
+	}
+	if wallet.AuthorizationKey == nil {
+		return models.WalletExportChallenge{}, fmt.Errorf("%w: wallet has no authorization key to approve an export", ErrConflict)
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return models.WalletExportChallenge{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	challenge := models.WalletExportChallenge{UserID: userID, ExpiresAt: time.Now().Add(walletExportChallengeTTL)}
+	challenge.Message = fmt.Sprintf("Export the private keys of custodial wallet %s (Solana) / %s (EVM) of user %s.\nNonce: %s\nExpires: %s",
+		wallet.SolanaPubKey, wallet.EVMAddress, userID, hex.EncodeToString(nonce), challenge.ExpiresAt.UTC().Format(time.RFC3339))
+
+	ok, err := s.DB.SetCustodialWalletExportChallenge(userID, challenge.Message, challenge.ExpiresAt)
+	if err != nil {
+		return models.WalletExportChallenge{}, fmt.Errorf("failed to save export challenge: %w", err)
+	}
+	if !ok {
+		return models.WalletExportChallenge{}, ErrWalletExported
+	}
+	return challenge, nil
+}
+
+// ExportWallet hands a wallet's keys over to its user, given the export
+// challenge signed with their authorization key. The backend stops signing
+// for the wallet, but keeps its keys until ConfirmExport, so an export whose
+// response was lost can be requested again. The addresses do not change, so
+// holdings stay where they are.
+func (s *CustodyService) ExportWallet(userID, signatureBase58 string) (WalletExport, error) {
+	wallet, err := s.GetWallet(userID)
+	if err != nil {
+		return WalletExport{}, err
+	}
+	if err := checkExportAuthorization(wallet, signatureBase58, time.Now()); err != nil {
+		return WalletExport{}, err
+	}
+
+	// Decrypt before anything changes, so a wrong master key cannot lock the wallet
+	keys, err := s.decrypt(wallet)
+	if err != nil {
+		return WalletExport{}, err
+	}
+	ok, err := s.DB.BeginCustodialWalletExport(userID, *wallet.ExportChallenge)
+	if err != nil {
+		return WalletExport{}, fmt.Errorf("failed to start wallet export: %w", err)
+	}
+	if !ok {
+		return WalletExport{}, fmt.Errorf("%w: export challenge was already used or replaced", ErrConflict)
+	}
+	log.Printf("Custodial wallet of user %s exported, awaiting confirmation", userID)
+
+	return WalletExport{
+		UserID:              userID,
+		SolanaPubKey:        wallet.SolanaPubKey,
+		SolanaPrivateKey:    keys.Solana.String(),
+		EVMAddress:          wallet.EVMAddress,
+		EVMPrivateKey:       hex.EncodeToString(crypto.FromECDSA(keys.EVM)),
+		ConfirmationMessage: exportConfirmationMessage(wallet),
+	}, nil
+}
+
+// ConfirmExport erases a wallet's keys and moves its user to self-custody,
+// once they prove they hold the exported keys by signing the export's
+// confirmation message with the Solana key.
+func (s *CustodyService) ConfirmExport(userID, signatureBase58 string) (models.CustodialWallet, error) {
+	wallet, err := s.GetWallet(userID)
+	if err != nil {
+		return models.CustodialWallet{}, err
+	}
+	if wallet.Status != models.CustodialWalletExportPending {
+		return models.CustodialWallet{}, fmt.Errorf("%w: wallet has no pending export", ErrConflict)
+	}
+	if !verifyMessageSignature(wallet.SolanaPubKey, exportConfirmationMessage(wallet), signatureBase58) {
+		return models.CustodialWallet{}, invalidf("signature does not match the confirmation message and the exported key")
+	}
+
+	exported, err := s.DB.MarkCustodialWalletExported(userID)
+	if err != nil {
+		return models.CustodialWallet{}, fmt.Errorf("failed to mark wallet exported: %w", err)
+	}
+	if !exported {
+		return models.CustodialWallet{}, ErrWalletExported
+	}
+	log.Printf("Custodial wallet of user %s erased; user moved to self-custody", userID)
+	return s.GetWallet(userID)
+}
+
+// checkExportAuthorization checks that a wallet's export challenge, still
+// valid at `now`, was signed with the user's authorization key.
+func checkExportAuthorization(wallet models.CustodialWallet, signatureBase58 string, now time.Time) error {
+	if wallet.Status == models.CustodialWalletExported {
+		return ErrWalletExported
+	}
+	if wallet.AuthorizationKey == nil || wallet.ExportChallenge == nil || wallet.ExportChallengeExpiresAt == nil {
+		return ErrExportNotAuthorized
+	}
+	if now.After(*wallet.ExportChallengeExpiresAt) {
+		return invalidf("export challenge expired; request a new one")
+	}
+	if !verifyMessageSignature(*wallet.AuthorizationKey, *wallet.ExportChallenge, signatureBase58) {
+		return ErrExportNotAuthorized
+	}
+	return nil
+}
+
+// exportConfirmationMessage is what the exported Solana key signs to
+// confirm a wallet export.
+func exportConfirmationMessage(wallet models.CustodialWallet) string {
+	return fmt.Sprintf("I hold the private keys of wallet %s (Solana) / %s (EVM) of user %s; erase the custodial copy.",
+		wallet.SolanaPubKey, wallet.EVMAddress, wallet.UserID)
+}
+
+// verifyMessageSignature reports whether a base58 signature of message was
+// made by the base58 ed25519 public key.
+func verifyMessageSignature(publicKeyBase58, message, signatureBase58 string) bool {
+	publicKey, err := solana.PublicKeyFromBase58(publicKeyBase58)
+	if err != nil {
+		return false
+	}
+	signature, err := solana.SignatureFromBase58(signatureBase58)
+	if err != nil {
+		return false
+	}
+	return signature.Verify(publicKey, []byte(message))
+}
+
+// authorize checks a transfer against the wallet's policy, reserves it
+// against the daily limit and returns the decrypted keys with the
+// reservation's ID, which settle closes.
+func (s *CustodyService) authorize(userID, assetID string, amount float64) (custodialKeys, string, error) {
+	if s.masterKey == nil {
+		return custodialKeys{}, "", ErrCustodyNotConfigured
+	}
+	sig := models.CustodialSignature{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		AssetID:   assetID,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	wallet, err := s.DB.ReserveCustodialSignature(sig)
+	switch {
+	case errors.Is(err, storage.ErrWalletNotActive):
+		return custodialKeys{}, "", ErrWalletExported
+	case errors.Is(err, storage.ErrTransferLimitExceeded):
+		return custodialKeys{}, "", violation("custody_limit", "%v", err)
+	case err != nil:
+		return custodialKeys{}, "", err
+	}
+
+	keys, err := s.decrypt(wallet)
+	if err != nil {
+		s.settle(sig.ID, "", err)
+		return custodialKeys{}, "", err
+	}
+	return keys, sig.ID, nil
+}
+
+// settle records the transaction a reserved signature produced, or drops
+// the reservation when the transfer failed before being sent.
+func (s *CustodyService) settle(sigID, txID string, sendErr error) {
+	var err error
+	if sendErr != nil {
+		err = s.DB.ReleaseCustodialSignature(sigID)
+	} else {
+		err = s.DB.SetCustodialSignatureTxID(sigID, txID)
+	}
+	if err != nil {
+		log.Printf("ERROR: failed to settle custodial signature %s: %v", sigID, err)
+	}
+}
+
+// decrypt unwraps a wallet's data key with the master key and decrypts its keys.
+func (s *CustodyService) decrypt(wallet models.CustodialWallet) (custodialKeys, error) {
+	if s.masterKey == nil {
+		return custodialKeys{}, ErrCustodyNotConfigured
+	}
+	if wallet.MasterKeyID != s.masterKeyID {
+		return custodialKeys{}, fmt.Errorf("wallet of user %s is wrapped by master key %s, not the configured %s",
+			wallet.UserID, wallet.MasterKeyID, s.masterKeyID)
+	}
+	dataKey, err := storage.OpenGCM(s.masterKey, wallet.EncryptedDataKey, "data-key:"+wallet.UserID)
+	if err != nil {
+		return custodialKeys{}, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	solanaKey, err := storage.OpenGCM(dataKey, wallet.EncryptedSolanaKey, "solana:"+wallet.UserID)
+	if err != nil {
+		return custodialKeys{}, fmt.Errorf("failed to decrypt Solana key: %w", err)
+	}
+	evmKeyBytes, err := storage.OpenGCM(dataKey, wallet.EncryptedEVMKey, "evm:"+wallet.UserID)
+	if err != nil {
+		return custodialKeys{}, fmt.Errorf("failed to decrypt EVM key: %w", err)
+	}
+	evmKey, err := crypto.ToECDSA(evmKeyBytes)
+	if err != nil {
+		return custodialKeys{}, fmt.Errorf("invalid EVM key: %w", err)
+	}
+	return custodialKeys{Solana: solana.PrivateKey(solanaKey), EVM: evmKey}, nil
+}
+
+// signSolana adds the wallet's signature to a transaction prepared for it,
+// which the FeePayer has already signed.
+func (k custodialKeys) signSolana(preparedBase64 string) (string, error) {
+	tx, err := solana.TransactionFromBase64(preparedBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode prepared transaction: %w", err)
+	}
+	_, err = tx.PartialSign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(k.Solana.PublicKey()) {
+			return &k.Solana
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), nil
+}
+
+// signEVM signs an unsigned EIP-1559 transaction prepared for the wallet.
+func (k custodialKeys) signEVM(rawHex string, chainID *big.Int) (string, error) {
+	raw, err := hexutil.Decode(rawHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid prepared transaction: %w", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return "", fmt.Errorf("invalid prepared transaction: %w", err)
+	}
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), k.EVM)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	raw, err = signed.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	return hexutil.Encode(raw), nil
+}
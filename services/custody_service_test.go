@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+)
+
+func newTestCustody(t *testing.T, fill byte) *CustodyService {
+	t.Helper()
+	s, err := NewCustodyService(nil, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCustodialWalletKeys(t *testing.T) {
+	s := newTestCustody(t, 'm')
+	authorization := solana.NewWallet().PublicKey().String()
+	wallet, err := s.newWallet("user-1", authorization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Status != models.CustodialWalletActive || wallet.MasterKeyID != s.masterKeyID || *wallet.AuthorizationKey != authorization {
+		t.Fatalf("newWallet() = %+v", wallet)
+	}
+
+	keys, err := s.decrypt(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Solana.PublicKey().String() != wallet.SolanaPubKey {
+		t.Errorf("decrypted Solana key is for %s, want %s", keys.Solana.PublicKey(), wallet.SolanaPubKey)
+	}
+	if address := crypto.PubkeyToAddress(keys.EVM.PublicKey).Hex(); address != wallet.EVMAddress {
+		t.Errorf("decrypted EVM key is for %s, want %s", address, wallet.EVMAddress)
+	}
+	for name, sealed := range map[string][]byte{"solana": wallet.EncryptedSolanaKey, "evm": wallet.EncryptedEVMKey} {
+		if bytes.Contains(sealed, keys.Solana) || bytes.Contains(sealed, crypto.FromECDSA(keys.EVM)) {
+			t.Errorf("encrypted %s key contains a plaintext key", name)
+		}
+	}
+
+	other, err := s.newWallet("user-2", authorization)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := wallet
+	tampered.EncryptedEVMKey = bytes.Clone(wallet.EncryptedEVMKey)
+	tampered.EncryptedEVMKey[len(tampered.EncryptedEVMKey)-1] ^= 1
+	swappedKey := wallet
+	swappedKey.EncryptedSolanaKey = other.EncryptedSolanaKey
+	swappedDataKey := wallet
+	swappedDataKey.EncryptedDataKey = other.EncryptedDataKey
+	swappedPurpose := wallet
+	swappedPurpose.EncryptedSolanaKey = wallet.EncryptedEVMKey
+	otherMaster := newTestCustody(t, 'o')
+	rewrapped := wallet
+	rewrapped.MasterKeyID = otherMaster.masterKeyID
+
+	tests := []struct {
+		name    string
+		service *CustodyService
+		wallet  models.CustodialWallet
+	}{
+		{"tampered key", s, tampered},
+		{"key of another user", s, swappedKey},
+		{"data key of another user", s, swappedDataKey},
+		{"key sealed for another purpose", s, swappedPurpose},
+		{"other master key", otherMaster, wallet},
+		{"other master key with a matching ID", otherMaster, rewrapped},
+		{"custody disabled", &CustodyService{}, wallet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.service.decrypt(tt.wallet); err == nil {
+				t.Fatal("decrypt() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCheckExportAuthorization(t *testing.T) {
+	authorization, other := solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey
+	now := time.Now()
+	challenge := "Export the private keys of custodial wallet ..."
+	sign := func(key solana.PrivateKey, message string) string {
+		sig, err := key.Sign([]byte(message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig.String()
+	}
+	walletWith := func(status string, authorizationKey, challenge *string, expiresAt time.Time) models.CustodialWallet {
+		return models.CustodialWallet{
+			Status: status, AuthorizationKey: authorizationKey,
+			ExportChallenge: challenge, ExportChallengeExpiresAt: &expiresAt,
+		}
+	}
+	authorizationKey := authorization.PublicKey().String()
+	pending := walletWith(models.CustodialWalletActive, &authorizationKey, &challenge, now.Add(walletExportChallengeTTL))
+
+	tests := []struct {
+		name      string
+		wallet    models.CustodialWallet
+		signature string
+		wantErr   error // nil when authorized
+	}{
+		{"signed by the authorization key", pending, sign(authorization, challenge), nil},
+		{"export pending again after a lost response", walletWith(models.CustodialWalletExportPending, &authorizationKey, &challenge, now.Add(time.Minute)), sign(authorization, challenge), nil},
+		{"signed by another key", pending, sign(other, challenge), ErrExportNotAuthorized},
+		{"signed another message", pending, sign(authorization, challenge+"!"), ErrExportNotAuthorized},
+		{"malformed signature", pending, "not a signature", ErrExportNotAuthorized},
+		{"no challenge requested", walletWith(models.CustodialWalletActive, &authorizationKey, nil, now), sign(authorization, challenge), ErrExportNotAuthorized},
+		{"no authorization key", walletWith(models.CustodialWalletActive, nil, &challenge, now.Add(time.Minute)), sign(authorization, challenge), ErrExportNotAuthorized},
+		{"already exported", walletWith(models.CustodialWalletExported, &authorizationKey, &challenge, now.Add(time.Minute)), sign(authorization, challenge), ErrWalletExported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExportAuthorization(tt.wallet, tt.signature, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkExportAuthorization() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	expired := walletWith(models.CustodialWalletActive, &authorizationKey, &challenge, now.Add(-time.Second))
+	var validation *ValidationError
+	if err := checkExportAuthorization(expired, sign(authorization, challenge), now); !errors.As(err, &validation) {
+		t.Fatalf("checkExportAuthorization() with an expired challenge error = %v, want a *ValidationError", err)
+	}
+}
+
+func TestExportConfirmation(t *testing.T) {
+	s := newTestCustody(t, 'm')
+	wallet, err := s.newWallet("user-1", solana.NewWallet().PublicKey().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.decrypt(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user proves they hold the exported key by signing the confirmation with it
+	message := exportConfirmationMessage(wallet)
+	sig, err := keys.Solana.Sign([]byte(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifyMessageSignature(wallet.SolanaPubKey, message, sig.String()) {
+		t.Fatal("confirmation signed with the exported key was not accepted")
+	}
+
+	other, err := s.newWallet("user-2", solana.NewWallet().PublicKey().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifyMessageSignature(other.SolanaPubKey, exportConfirmationMessage(other), sig.String()) {
+		t.Fatal("confirmation of another wallet was accepted")
+	}
+	if verifyMessageSignature(wallet.SolanaPubKey, message, solana.Signature{}.String()) {
+		t.Fatal("empty signature was accepted")
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// payoutBatchSize is how many payouts (create-ATA + transfer) fit in one transaction.
+	payoutBatchSize = 6
+	// maxPayoutAttempts is how many times a payout is retried before it needs manual review.
+	maxPayoutAttempts = 5
+	// payoutConfirmationTimeout bounds the wait for each batch to confirm.
+	payoutConfirmationTimeout = 60 * time.Second
+	// payoutExpiry is how long after sending an unconfirmed transaction can no
+	// longer land, its blockhash having expired; only then is it safe to resend.
+	payoutExpiry = 3 * time.Minute
+)
+
+var (
+	// ErrDistributionNotFound is returned when the requested distribution does not exist.
+	ErrDistributionNotFound = fmt.Errorf("distribution %w", ErrNotFound)
+	// ErrDistributionNotExecutable is returned when a distribution is running or already completed.
+	ErrDistributionNotExecutable = fmt.Errorf("%w: distribution is already executing or completed", ErrConflict)
+)
+
+// DistributionService computes and pays pro-rata distributions to asset holders.
+// Payouts are sent from the FeePayer's own token account of the payout mint
+// (the treasury), which must be funded before execution.
+type DistributionService struct {
+	DB        *storage.DB
+	SolanaS   *SolanaIntegrationService
+	Snapshots *SnapshotService
+}
+
+func NewDistributionService(db *storage.DB, solanaS *SolanaIntegrationService, snapshots *SnapshotService) *DistributionService {
+	return &DistributionService{DB: db, SolanaS: solanaS, Snapshots: snapshots}
+}
+
+// CreateDistributionInput describes a new distribution. Either SnapshotID or
+// RecordDate must be given; a record date creates a dedicated snapshot.
+type CreateDistributionInput struct {
+	AssetID     string     `json:"-"`
+	Description string     `json:"description"`
+	PayoutMint  string     `json:"payout_mint"`
+	TotalAmount float64    `json:"total_amount"`
+	SnapshotID  string     `json:"snapshot_id,omitempty"`
+	RecordDate  *time.Time `json:"record_date,omitempty"`
+}
+
+// CreateDistribution computes the entitlement of every holder at the record
+// date and stores them as pending payouts.
+func (s *DistributionService) CreateDistribution(in CreateDistributionInput) (models.Distribution, error) {
+	if in.TotalAmount <= 0 {
+		return models.Distribution{}, invalidf("total_amount must be positive")
+	}
+	payoutMint, err := solana.PublicKeyFromBase58(in.PayoutMint)
+	if err != nil {
+		return models.Distribution{}, invalidf("invalid payout_mint: %v", err)
+	}
+
+	distributionID := uuid.New().String()
+
+	var snapshot models.Snapshot
+	switch {
+	case in.SnapshotID != "":
+		snapshot, err = s.Snapshots.GetSnapshot(in.SnapshotID)
+		if err != nil {
+			return models.Distribution{}, err
+		}
+		if snapshot.AssetID != in.AssetID {
+			return models.Distribution{}, invalidf("snapshot %s does not belong to asset %s", snapshot.ID, in.AssetID)
+		}
+	case in.RecordDate != nil:
+		snapshot, err = s.Snapshots.CreateSnapshot(in.AssetID, "distribution-"+distributionID, in.RecordDate, nil)
+		if err != nil {
+			return models.Distribution{}, err
+		}
+		if snapshot.Holdings, err = s.DB.GetSnapshotHoldings(snapshot.ID); err != nil {
+			return models.Distribution{}, fmt.Errorf("error fetching snapshot holdings: %w", err)
+		}
+	default:
+		return models.Distribution{}, invalidf("snapshot_id or record_date is required")
+	}
+	if len(snapshot.Holdings) == 0 {
+		return models.Distribution{}, invalidf("no holders at the record date")
+	}
+
+	decimals, err := s.SolanaS.GetMintDecimals(payoutMint)
+	if err != nil {
+		return models.Distribution{}, fmt.Errorf("failed to read payout mint: %w", err)
+	}
+
+	now := time.Now()
+	distribution := models.Distribution{
+		ID:             distributionID,
+		AssetID:        in.AssetID,
+		SnapshotID:     snapshot.ID,
+		Description:    in.Description,
+		PayoutMint:     payoutMint.String(),
+		PayoutDecimals: int(decimals),
+		TotalAmount:    in.TotalAmount,
+		Status:         models.DistributionStatusComputed,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	shares := make([]proRataShare, len(snapshot.Holdings))
+	for i, h := range snapshot.Holdings {
+		shares[i] = proRataShare{Key: h.SolanaPubKey, Weight: toAtomic(h.Amount, 9)}
+	}
+	allocations := allocateProRata(toAtomic(in.TotalAmount, decimals), shares)
+
+	payouts := make([]models.Payout, 0, len(allocations))
+	for i, h := range snapshot.Holdings {
+		if allocations[i] == 0 {
+			continue // Holding too small to receive a single atomic unit
+		}
+		payouts = append(payouts, models.Payout{
+			ID:             uuid.New().String(),
+			DistributionID: distribution.ID,
+			OwnerID:        h.OwnerID,
+			SolanaPubKey:   h.SolanaPubKey,
+			HoldingAmount:  h.Amount,
+			AmountAtomic:   int64(allocations[i]),
+			Amount:         fromAtomic(allocations[i], decimals),
+			Status:         models.PayoutStatusPending,
+			UpdatedAt:      now,
+		})
+	}
+
+	if err := s.DB.CreateDistribution(distribution, payouts); err != nil {
+		return models.Distribution{}, fmt.Errorf("failed to save distribution: %w", err)
+	}
+	distribution.Payouts = payouts
+	return distribution, nil
+}
+
+// GetDistribution returns a distribution together with its payouts.
+func (s *DistributionService) GetDistribution(id string) (models.Distribution, error) {
+	distribution, found, err := s.DB.GetDistribution(id)
+	if err != nil {
+		return models.Distribution{}, fmt.Errorf("error fetching distribution: %w", err)
+	}
+	if !found {
+		return models.Distribution{}, ErrDistributionNotFound
+	}
+	distribution.Payouts, err = s.DB.GetPayouts(distribution.ID)
+	if err != nil {
+		return models.Distribution{}, fmt.Errorf("error fetching payouts: %w", err)
+	}
+	return distribution, nil
+}
+
+// StartExecution claims a distribution for execution and pays it in the
+// background. Calling it again on a partially paid distribution retries the
+// payouts that are still pending or failed.
+func (s *DistributionService) StartExecution(id string) (models.Distribution, error) {
+	distribution, found, err := s.DB.GetDistribution(id)
+	if err != nil {
+		return models.Distribution{}, fmt.Errorf("error fetching distribution: %w", err)
+	}
+	if !found {
+		return models.Distribution{}, ErrDistributionNotFound
+	}
+
+	claimed, err := s.DB.ClaimDistribution(id, models.DistributionStatusExecuting,
+		models.DistributionStatusComputed, models.DistributionStatusPartiallyPaid)
+	if err != nil {
+		return models.Distribution{}, fmt.Errorf("failed to claim distribution: %w", err)
+	}
+	if !claimed {
+		return models.Distribution{}, ErrDistributionNotExecutable
+	}
+	distribution.Status = models.DistributionStatusExecuting
+
+	go s.execute(distribution)
+	return distribution, nil
+}
+
+// execute reconciles previously sent batches, pays the remaining payouts in
+// batches and settles the distribution status.
+func (s *DistributionService) execute(distribution models.Distribution) {
+	payoutMint := solana.MustPublicKeyFromBase58(distribution.PayoutMint)
+
+	if err := s.reconcileSentPayouts(distribution.ID); err != nil {
+		log.Printf("Distribution %s: failed to reconcile sent payouts: %v", distribution.ID, err)
+	}
+
+	payouts, err := s.DB.GetPayouts(distribution.ID, models.PayoutStatusPending, models.PayoutStatusFailed)
+	if err != nil {
+		log.Printf("Distribution %s: failed to load payouts: %v", distribution.ID, err)
+		s.settle(distribution.ID)
+		return
+	}
+
+	var batch []models.Payout
+	for _, p := range payouts {
+		if p.Attempts >= maxPayoutAttempts {
+			continue // Needs manual review
+		}
+		batch = append(batch, p)
+		if len(batch) == payoutBatchSize {
+			s.payBatch(distribution.ID, payoutMint, batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		s.payBatch(distribution.ID, payoutMint, batch)
+	}
+
+	s.settle(distribution.ID)
+}
+
+// payBatch signs one batch of payouts, records it on the payouts before
+// sending, and waits for its confirmation.
+func (s *DistributionService) payBatch(distributionID string, payoutMint solana.PublicKey, batch []models.Payout) {
+	ids := make([]string, len(batch))
+	transfers := make([]TokenTransfer, len(batch))
+	for i, p := range batch {
+		ids[i] = p.ID
+		transfers[i] = TokenTransfer{Owner: solana.MustPublicKeyFromBase58(p.SolanaPubKey), Amount: uint64(p.AmountAtomic)}
+	}
+
+	ref := models.TxReference{Type: models.ReferenceDistribution, ID: distributionID}
+	signedTx, sig, err := s.SolanaS.WithReference(ref).SignTokenTransfers(payoutMint, transfers)
+	if err != nil {
+		log.Printf("Distribution %s: failed to sign batch of %d payouts: %v", distributionID, len(batch), err)
+		if err := s.DB.MarkPayoutsFailed(ids, err.Error(), true); err != nil {
+			log.Printf("Distribution %s: failed to record payout failure: %v", distributionID, err)
+		}
+		return
+	}
+	// Recorded before sending: a crash in between leaves the payouts sent, and
+	// reconciliation retries them only once the transaction can no longer land
+	if err := s.DB.MarkPayoutsSent(ids, sig.String()); err != nil {
+		log.Printf("Distribution %s: failed to record batch tx %s, not sending it: %v", distributionID, sig, err)
+		return
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Distribution %s: batch tx %s not sent: %v; will reconcile on next execution", distributionID, sig, err)
+		return
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		log.Printf("Distribution %s: batch tx %s failed: %v", distributionID, sig, err)
+		if err := s.DB.MarkPayoutsFailed(ids, err.Error(), false); err != nil {
+			log.Printf("Distribution %s: failed to record payout failure: %v", distributionID, err)
+		}
+	case err != nil:
+		log.Printf("Distribution %s: could not check batch tx %s: %v; will reconcile on next execution", distributionID, sig, err)
+	case confirmed:
+		if err := s.DB.MarkPayoutsPaid(ids); err != nil {
+			log.Printf("Distribution %s: failed to mark payouts paid for tx %s: %v", distributionID, sig, err)
+		}
+	default:
+		log.Printf("Distribution %s: batch tx %s not confirmed yet; will reconcile on next execution", distributionID, sig)
+	}
+}
+
+// reconcileSentPayouts resolves payouts whose transaction was sent but whose
+// confirmation was not observed.
+func (s *DistributionService) reconcileSentPayouts(distributionID string) error {
+	sent, err := s.DB.GetPayouts(distributionID, models.PayoutStatusSent)
+	if err != nil {
+		return err
+	}
+
+	byTx := make(map[string][]string)
+	sentAt := make(map[string]time.Time)
+	for _, p := range sent {
+		if p.TransactionID != nil {
+			byTx[*p.TransactionID] = append(byTx[*p.TransactionID], p.ID)
+			sentAt[*p.TransactionID] = p.UpdatedAt
+		}
+	}
+	for txID, ids := range byTx {
+		confirmed, err := s.SolanaS.GetTransactionConfirmation(solana.MustSignatureFromBase58(txID))
+		switch {
+		case errors.Is(err, ErrTransactionFailed):
+			if err := s.DB.MarkPayoutsFailed(ids, err.Error(), false); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case confirmed:
+			if err := s.DB.MarkPayoutsPaid(ids); err != nil {
+				return err
+			}
+		case time.Since(sentAt[txID]) > payoutExpiry:
+			// Never landed and the blockhash has expired: safe to retry
+			if err := s.DB.MarkPayoutsFailed(ids, "transaction "+txID+" was not confirmed", false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// settle sets the final status of a distribution after an execution run.
+func (s *DistributionService) settle(distributionID string) {
+	status := models.DistributionStatusCompleted
+	open, err := s.DB.GetPayouts(distributionID, models.PayoutStatusPending, models.PayoutStatusSent, models.PayoutStatusFailed)
+	if err != nil || len(open) > 0 {
+		status = models.DistributionStatusPartiallyPaid
+	}
+	if err := s.DB.UpdateDistributionStatus(distributionID, status); err != nil {
+		log.Printf("Distribution %s: failed to update status: %v", distributionID, err)
+		return
+	}
+	log.Printf("Distribution %s execution finished with status %s", distributionID, status)
+}
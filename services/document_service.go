@@ -0,0 +1,227 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// maxDocumentBytes is the largest document that can be uploaded.
+const maxDocumentBytes = 50 << 20
+
+var (
+	// ErrDocumentNotFound is returned when the requested document does not exist.
+	ErrDocumentNotFound = fmt.Errorf("document %w", ErrNotFound)
+	// ErrDocumentVersionExists is returned when two versions of a document are uploaded at once.
+	ErrDocumentVersionExists = fmt.Errorf("%w: another version of the document was uploaded concurrently", ErrConflict)
+	// ErrDocumentTooLarge is returned when an upload exceeds maxDocumentBytes.
+	ErrDocumentTooLarge = fmt.Errorf("document exceeds %d bytes", maxDocumentBytes)
+)
+
+// DocumentService keeps the registry of asset disclosures. Every uploaded
+// version is hashed and its hash anchored on Solana, so anyone can check a
+// copy of a disclosure against what the issuer published and when.
+type DocumentService struct {
+	DB      *storage.DB
+	SolanaS *SolanaIntegrationService
+	Store   DocumentStore
+}
+
+func NewDocumentService(db *storage.DB, solanaS *SolanaIntegrationService, store DocumentStore) *DocumentService {
+	return &DocumentService{DB: db, SolanaS: solanaS, Store: store}
+}
+
+// UploadDocumentInput describes a document version being uploaded.
+type UploadDocumentInput struct {
+	AssetID     string
+	Name        string // Versions of the same document share a name
+	Kind        string // Defaults to "other"
+	FileName    string
+	ContentType string
+	Content     io.Reader
+}
+
+// Upload stores a new version of a document, registers its hash and anchors
+// the hash on chain. A failed anchor does not fail the upload: the document
+// is returned with last_error set and can be anchored again with Anchor.
+func (s *DocumentService) Upload(in UploadDocumentInput) (models.Document, error) {
+	if in.Name == "" || len(in.Name) > 255 {
+		return models.Document{}, invalidf("name is required and must have at most 255 characters")
+	}
+	switch in.Kind {
+	case "":
+		in.Kind = models.DocumentKindOther
+	case models.DocumentKindProspectus, models.DocumentKindBylaws, models.DocumentKindReport, models.DocumentKindOther:
+	default:
+		return models.Document{}, invalidf("kind must be one of prospectus, bylaws, report or other")
+	}
+	if in.FileName == "" {
+		in.FileName = in.Name
+	}
+	if in.ContentType == "" {
+		in.ContentType = "application/octet-stream"
+	}
+	if _, found, err := s.DB.GetAsset(in.AssetID); err != nil {
+		return models.Document{}, fmt.Errorf("error fetching asset: %w", err)
+	} else if !found {
+		return models.Document{}, ErrAssetNotFound
+	}
+
+	doc := models.Document{
+		ID:          uuid.New().String(),
+		AssetID:     in.AssetID,
+		Name:        in.Name,
+		Kind:        in.Kind,
+		FileName:    path.Base(in.FileName),
+		ContentType: in.ContentType,
+		CreatedAt:   time.Now(),
+	}
+	doc.StorageKey = doc.AssetID + "/" + doc.ID
+
+	hash := sha256.New()
+	content := &limitedReader{r: io.TeeReader(in.Content, hash), n: maxDocumentBytes}
+	if err := s.Store.Put(doc.StorageKey, content); err != nil {
+		if errors.Is(err, ErrDocumentTooLarge) {
+			return models.Document{}, invalidf("%v", err)
+		}
+		return models.Document{}, fmt.Errorf("failed to store document: %w", err)
+	}
+	doc.Size = content.read
+	doc.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	doc, err := s.DB.SaveDocument(doc)
+	if err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.Document{}, ErrDocumentVersionExists
+		}
+		return models.Document{}, fmt.Errorf("failed to save document: %w", err)
+	}
+	log.Printf("Document %s v%d of asset %s registered | SHA-256: %s", doc.Name, doc.Version, doc.AssetID, doc.SHA256)
+
+	return s.anchor(doc)
+}
+
+// Anchor retries anchoring a document whose anchor failed.
+func (s *DocumentService) Anchor(id string) (models.Document, error) {
+	doc, err := s.GetDocument(id)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if doc.AnchorSignature != nil {
+		return doc, nil
+	}
+	return s.anchor(doc)
+}
+
+// anchor records the document's hash on chain with the Memo program.
+func (s *DocumentService) anchor(doc models.Document) (models.Document, error) {
+	sig, err := s.SolanaS.SendMemo(anchorMemo(doc))
+	if err != nil {
+		log.Printf("Failed to anchor document %s: %v", doc.ID, err)
+		reason := err.Error()
+		if err := s.DB.SetDocumentAnchorError(doc.ID, reason); err != nil {
+			return models.Document{}, fmt.Errorf("failed to record anchor error: %w", err)
+		}
+		doc.LastError = &reason
+		return doc, nil
+	}
+	if err := s.DB.SetDocumentAnchor(doc.ID, sig.String()); err != nil {
+		return models.Document{}, fmt.Errorf("failed to record anchor %s: %w", sig, err)
+	}
+	return s.GetDocument(doc.ID)
+}
+
+// anchorMemo is the message anchoring a document version on chain.
+func anchorMemo(doc models.Document) string {
+	return fmt.Sprintf("%s:asset:%s:document:%s:v%d:sha256:%s", ballotDomain, doc.AssetID, doc.ID, doc.Version, doc.SHA256)
+}
+
+// GetDocument returns a document version.
+func (s *DocumentService) GetDocument(id string) (models.Document, error) {
+	doc, found, err := s.DB.GetDocument(id)
+	if err != nil {
+		return models.Document{}, fmt.Errorf("error fetching document: %w", err)
+	}
+	if !found {
+		return models.Document{}, ErrDocumentNotFound
+	}
+	return doc, nil
+}
+
+// Open returns a document version and its content. The caller closes it.
+func (s *DocumentService) Open(id string) (models.Document, io.ReadCloser, error) {
+	doc, err := s.GetDocument(id)
+	if err != nil {
+		return models.Document{}, nil, err
+	}
+	content, err := s.Store.Open(doc.StorageKey)
+	if err != nil {
+		return models.Document{}, nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	return doc, content, nil
+}
+
+// Verify hashes a file and checks it against a registered document version
+// and against the hash anchored on chain.
+func (s *DocumentService) Verify(id string, file io.Reader) (models.DocumentVerification, error) {
+	doc, err := s.GetDocument(id)
+	if err != nil {
+		return models.DocumentVerification{}, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, &limitedReader{r: file, n: maxDocumentBytes}); err != nil {
+		if errors.Is(err, ErrDocumentTooLarge) {
+			return models.DocumentVerification{}, invalidf("%v", err)
+		}
+		return models.DocumentVerification{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	result := models.DocumentVerification{
+		DocumentID:      doc.ID,
+		SHA256:          hex.EncodeToString(hash.Sum(nil)),
+		AnchorSignature: doc.AnchorSignature,
+	}
+	result.Matches = result.SHA256 == doc.SHA256
+
+	if doc.AnchorSignature != nil {
+		// Recheck the chain rather than trusting the database
+		memos, err := s.SolanaS.GetTransactionMemos(solana.MustSignatureFromBase58(*doc.AnchorSignature))
+		if err != nil {
+			return models.DocumentVerification{}, fmt.Errorf("failed to read anchor transaction: %w", err)
+		}
+		for _, memo := range memos {
+			if memo == anchorMemo(doc) {
+				result.AnchorVerified = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// limitedReader reads at most n bytes from r, failing with
+// ErrDocumentTooLarge past that, and counts the bytes read.
+type limitedReader struct {
+	r    io.Reader
+	n    int64
+	read int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.n {
+		return n, ErrDocumentTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestLocalDocumentStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalDocumentStore(filepath.Join(dir, "documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(t *testing.T, key string) string {
+		t.Helper()
+		f, err := store.Open(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	if err := store.Put("a1/d1", strings.NewReader("prospecto v1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, "a1/d1"); got != "prospecto v1" {
+		t.Fatalf("Open() = %q, want the stored content", got)
+	}
+
+	// A failed upload leaves neither the document nor a temporary file behind
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if err := store.Put("a1/d2", failing); err == nil {
+		t.Fatal("Put() succeeded with a failing reader")
+	}
+	if _, err := store.Open("a1/d2"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Open() after a failed upload error = %v, want %v", err, os.ErrNotExist)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "documents", "a1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("document directory holds %d files, want only the stored document", len(entries))
+	}
+
+	for _, key := range []string{"../outside", "a1/../../outside", "..", "/tmp/outside"} {
+		if err := store.Put(key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key", key)
+		}
+		if _, err := store.Open(key); err == nil || errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Open(%q) error = %v, want an invalid key", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); err == nil {
+		t.Fatal("a document was written outside the store")
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	tests := []struct {
+		size    int
+		wantErr error
+	}{
+		{size: 0},
+		{size: 10},
+		{size: 11, wantErr: ErrDocumentTooLarge},
+	}
+	for _, tt := range tests {
+		l := &limitedReader{r: strings.NewReader(strings.Repeat("x", tt.size)), n: 10}
+		_, err := io.Copy(io.Discard, l)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("reading %d bytes with a limit of 10: error = %v, want %v", tt.size, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && l.read != int64(tt.size) {
+			t.Errorf("read %d bytes, want %d", l.read, tt.size)
+		}
+	}
+}
+
+// fakeMemoRPC answers getTransaction with `tx`, failed when txErr is set.
+func fakeMemoRPC(t *testing.T, tx *solana.Transaction, txErr any) *rpc.Client {
+	t.Helper()
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getTransaction" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0", "id": req.ID,
+			"result": map[string]any{
+				"slot": 1, "blockTime": 1_700_000_000,
+				"meta":        map[string]any{"err": txErr, "fee": 5000, "preBalances": []int{}, "postBalances": []int{}},
+				"transaction": []string{base64.StdEncoding.EncodeToString(raw), "base64"},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return rpc.New(server.URL)
+}
+
+func TestAnchorMemo(t *testing.T) {
+	doc := models.Document{ID: "d1", AssetID: "a1", Version: 2, SHA256: strings.Repeat("ab", 32)}
+	want := "tiquin:asset:a1:document:d1:v2:sha256:" + strings.Repeat("ab", 32)
+	if got := anchorMemo(doc); got != want {
+		t.Fatalf("anchorMemo() = %q, want %q", got, want)
+	}
+
+	// The anchor is read back from the chain as it was written
+	feePayer := solana.NewWallet().PrivateKey
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{newMemoInstruction(anchorMemo(doc), feePayer.PublicKey())},
+		solana.Hash{1}, solana.TransactionPayer(feePayer.PublicKey()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Sign(func(solana.PublicKey) *solana.PrivateKey { return &feePayer }); err != nil {
+		t.Fatal(err)
+	}
+	s := &SolanaIntegrationService{RPCClient: fakeMemoRPC(t, tx, nil)}
+	memos, err := s.GetTransactionMemos(tx.Signatures[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memos) != 1 || memos[0] != want {
+		t.Fatalf("GetTransactionMemos() = %q, want [%q]", memos, want)
+	}
+
+	failed := &SolanaIntegrationService{RPCClient: fakeMemoRPC(t, tx, map[string]any{"InstructionError": []any{0, "InvalidInstructionData"}})}
+	if _, err := failed.GetTransactionMemos(tx.Signatures[0]); !errors.Is(err, ErrTransactionFailed) {
+		t.Fatalf("GetTransactionMemos() of a failed transaction error = %v, want %v", err, ErrTransactionFailed)
+	}
+}
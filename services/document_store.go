@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DocumentStore keeps the files of registered documents. Keys are
+// slash-separated relative paths chosen by the DocumentService.
+type DocumentStore interface {
+	// Put stores the content under key. Keys are never reused.
+	Put(key string, content io.Reader) error
+	// Open returns the content stored under key.
+	Open(key string) (io.ReadCloser, error)
+}
+
+// LocalDocumentStore keeps documents in a directory of the local
+// filesystem. An object store can be used instead by implementing
+// DocumentStore over its SDK.
+type LocalDocumentStore struct {
+	Dir string
+}
+
+func NewLocalDocumentStore(dir string) (*LocalDocumentStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create document directory: %w", err)
+	}
+	return &LocalDocumentStore{Dir: dir}, nil
+}
+
+func (s *LocalDocumentStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid document key %q", key)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+// Put writes the content to a temporary file and renames it into place, so
+// a failed upload never leaves a partial document behind.
+func (s *LocalDocumentStore) Put(key string, content io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens the file stored under key.
+func (s *LocalDocumentStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
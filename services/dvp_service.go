@@ -0,0 +1,342 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// dvpSigningWindow is how long both parties have to sign a DvP transaction
+// before its blockhash expires.
+const dvpSigningWindow = 60 * time.Second
+
+var (
+	// ErrDvPNotFound is returned when the requested settlement does not exist.
+	ErrDvPNotFound = fmt.Errorf("DvP settlement %w", ErrNotFound)
+	// ErrDvPNotAwaitingSignatures is returned when signing a settlement that was already submitted or closed.
+	ErrDvPNotAwaitingSignatures = fmt.Errorf("%w: DvP settlement is not awaiting signatures", ErrConflict)
+	// ErrDvPExpired is returned when signing a settlement whose transaction has expired.
+	ErrDvPExpired = fmt.Errorf("%w: DvP transaction expired before it was fully signed", ErrConflict)
+)
+
+// DvPService settles delivery-versus-payment exchanges: one transaction
+// carries the asset leg and the payment leg, the seller and the buyer each
+// sign it, and nothing is booked until it is finalized on chain.
+type DvPService struct {
+	DB           *storage.DB
+	SolanaS      *SolanaIntegrationService
+	Tokenization *TokenizationService // Compliance, AML, vesting and tax checks
+	mu           sync.Mutex           // Serializes signature merges
+}
+
+func NewDvPService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *DvPService {
+	return &DvPService{DB: db, SolanaS: solanaS, Tokenization: tokenization}
+}
+
+// CreateDvPInput describes an exchange of an asset against a payment token.
+type CreateDvPInput struct {
+	AssetID       string  `json:"asset_id"`
+	SellerID      string  `json:"seller_id"`
+	BuyerID       string  `json:"buyer_id"`
+	Quantity      float64 `json:"quantity"`
+	PaymentMint   string  `json:"payment_mint"`
+	PaymentAmount float64 `json:"payment_amount"`
+}
+
+// CreateSettlement checks both parties and legs and builds the DvP
+// transaction, signed by the FeePayer only. Both parties must sign it within
+// the signing window.
+func (s *DvPService) CreateSettlement(in CreateDvPInput) (models.DvPSettlement, error) {
+	if in.Quantity <= 0 || in.PaymentAmount <= 0 {
+		return models.DvPSettlement{}, invalidf("quantity and payment_amount must be positive")
+	}
+	if in.SellerID == in.BuyerID {
+		return models.DvPSettlement{}, invalidf("seller and buyer must differ")
+	}
+	paymentMint, err := solana.PublicKeyFromBase58(in.PaymentMint)
+	if err != nil {
+		return models.DvPSettlement{}, invalidf("invalid payment_mint: %v", err)
+	}
+
+	asset, found, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found || asset.MintAddress == "" {
+		return models.DvPSettlement{}, ErrAssetNotFound
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if asset.MintAddress == in.PaymentMint {
+		return models.DvPSettlement{}, invalidf("payment_mint must differ from the asset mint")
+	}
+	assetMint := solana.MustPublicKeyFromBase58(asset.MintAddress)
+
+	seller, sellerKey, err := s.party(in.SellerID)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	buyer, buyerKey, err := s.party(in.BuyerID)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+
+	now := time.Now()
+	if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, From: &seller, To: buyer, Amount: in.Quantity, Timestamp: now}); err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if err := s.Tokenization.AML.ScreenMovement("dvp", asset.ID, seller, buyer); err != nil {
+		return models.DvPSettlement{}, err
+	}
+
+	assetAtomic := toAtomic(in.Quantity, 9)
+	assetBalance, err := s.SolanaS.GetOwnerTokenBalance(sellerKey, assetMint)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if assetBalance < assetAtomic {
+		return models.DvPSettlement{}, invalidf("insufficient seller balance: have %d, need %d atomic units", assetBalance, assetAtomic)
+	}
+	if err := s.Tokenization.Vesting.CheckTransferable(seller.ID, asset.ID, fromAtomic(assetBalance, 9), in.Quantity, now); err != nil {
+		return models.DvPSettlement{}, err
+	}
+
+	decimals, err := s.SolanaS.GetMintDecimals(paymentMint)
+	if err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("failed to read payment mint: %w", err)
+	}
+	paymentAtomic := toAtomic(in.PaymentAmount, decimals)
+	if paymentAtomic == 0 {
+		return models.DvPSettlement{}, invalidf("payment_amount is below one atomic unit")
+	}
+	paymentBalance, err := s.SolanaS.GetOwnerTokenBalance(buyerKey, paymentMint)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if paymentBalance < paymentAtomic {
+		return models.DvPSettlement{}, invalidf("insufficient buyer payment balance: have %d, need %d atomic units", paymentBalance, paymentAtomic)
+	}
+
+	id := uuid.New().String()
+	serializedTx, err := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceSettlement, ID: id}).PrepareDvPTransaction(assetMint, paymentMint, sellerKey, buyerKey, assetAtomic, paymentAtomic)
+	if err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("failed to prepare DvP transaction: %w", err)
+	}
+
+	dvp := models.DvPSettlement{
+		ID:              id,
+		AssetID:         asset.ID,
+		SellerID:        seller.ID,
+		BuyerID:         buyer.ID,
+		Quantity:        in.Quantity,
+		PaymentMint:     in.PaymentMint,
+		PaymentDecimals: int(decimals),
+		PaymentAmount:   in.PaymentAmount,
+		Status:          models.DvPStatusAwaitingSignatures,
+		Transaction:     serializedTx,
+		ExpiresAt:       now.Add(dvpSigningWindow),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.DB.SaveDvPSettlement(dvp); err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("failed to save DvP settlement: %w", err)
+	}
+	return dvp, nil
+}
+
+// party loads a user taking part in a settlement with their wallet.
+func (s *DvPService) party(userID string) (models.User, solana.PublicKey, error) {
+	user, found, err := s.DB.GetUser(userID)
+	if err != nil {
+		return models.User{}, solana.PublicKey{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found || user.SolanaPubKey == "" {
+		return models.User{}, solana.PublicKey{}, ErrUserNotFound
+	}
+	key, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return models.User{}, solana.PublicKey{}, fmt.Errorf("invalid public key of user %s: %w", userID, err)
+	}
+	return user, key, nil
+}
+
+// GetSettlement returns a DvP settlement.
+func (s *DvPService) GetSettlement(id string) (models.DvPSettlement, error) {
+	dvp, found, err := s.DB.GetDvPSettlement(id)
+	if err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("error fetching DvP settlement: %w", err)
+	}
+	if !found {
+		return models.DvPSettlement{}, ErrDvPNotFound
+	}
+	return dvp, nil
+}
+
+// AddSignature merges a party's signature into the DvP transaction. The
+// parties may sign in any order; once both have, the transaction is sent and
+// booked in the background after it is finalized.
+func (s *DvPService) AddSignature(id, userID, signedTxBase64 string) (models.DvPSettlement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dvp, err := s.GetSettlement(id)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if dvp.Status != models.DvPStatusAwaitingSignatures {
+		return models.DvPSettlement{}, ErrDvPNotAwaitingSignatures
+	}
+	if time.Now().After(dvp.ExpiresAt) {
+		if _, err := s.DB.ClaimDvPSettlement(dvp.ID, models.DvPStatusAwaitingSignatures, models.DvPStatusExpired); err != nil {
+			return models.DvPSettlement{}, fmt.Errorf("failed to expire DvP settlement: %w", err)
+		}
+		return models.DvPSettlement{}, ErrDvPExpired
+	}
+	isSeller := userID == dvp.SellerID
+	if !isSeller && userID != dvp.BuyerID {
+		return models.DvPSettlement{}, invalidf("user %s is not a party to this settlement", userID)
+	}
+
+	_, key, err := s.party(userID)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	merged, err := s.SolanaS.MergeSignature(dvp.Transaction, signedTxBase64, key)
+	if err != nil {
+		if errors.Is(err, ErrTransactionMismatch) {
+			return models.DvPSettlement{}, invalidf("%v", err)
+		}
+		return models.DvPSettlement{}, err
+	}
+	updated, err := s.DB.AddDvPSignature(dvp.ID, merged, isSeller, time.Now())
+	if err != nil {
+		return models.DvPSettlement{}, fmt.Errorf("failed to store signature: %w", err)
+	}
+	if !updated {
+		return models.DvPSettlement{}, ErrDvPNotAwaitingSignatures
+	}
+
+	dvp, err = s.GetSettlement(dvp.ID)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if dvp.SellerSignedAt != nil && dvp.BuyerSignedAt != nil {
+		s.submit(dvp)
+		return s.GetSettlement(dvp.ID)
+	}
+	return dvp, nil
+}
+
+// submit sends the fully signed transaction and waits for its finalization
+// in the background.
+func (s *DvPService) submit(dvp models.DvPSettlement) {
+	sig, err := s.SolanaS.SendSignedTransaction(dvp.Transaction)
+	if err != nil {
+		log.Printf("DvP %s: failed to send transaction: %v", dvp.ID, err)
+		if err := s.DB.MarkDvPFailed(dvp.ID, err.Error()); err != nil {
+			log.Printf("DvP %s: failed to record failure: %v", dvp.ID, err)
+		}
+		return
+	}
+	if err := s.DB.MarkDvPSubmitted(dvp.ID, sig.String()); err != nil {
+		log.Printf("DvP %s: failed to record transaction %s: %v", dvp.ID, sig, err)
+	}
+
+	go func() {
+		finalized, err := s.SolanaS.WaitForFinalization(sig, payoutConfirmationTimeout)
+		switch {
+		case err != nil:
+			log.Printf("DvP %s: transaction %s failed: %v", dvp.ID, sig, err)
+			if err := s.DB.MarkDvPFailed(dvp.ID, err.Error()); err != nil {
+				log.Printf("DvP %s: failed to record failure: %v", dvp.ID, err)
+			}
+		case finalized:
+			s.book(dvp, sig.String())
+		default:
+			log.Printf("DvP %s: transaction %s not finalized yet", dvp.ID, sig)
+		}
+	}()
+}
+
+// book records both legs of a finalized settlement. The payment leg is only
+// journaled when the payment token is itself a registered asset.
+func (s *DvPService) book(dvp models.DvPSettlement, txID string) {
+	seller, sellerKey, err := s.party(dvp.SellerID)
+	if err != nil {
+		log.Printf("DvP %s: %v", dvp.ID, err)
+		return
+	}
+	_, buyerKey, err := s.party(dvp.BuyerID)
+	if err != nil {
+		log.Printf("DvP %s: %v", dvp.ID, err)
+		return
+	}
+	asset, _, err := s.DB.GetAsset(dvp.AssetID)
+	if err != nil {
+		log.Printf("DvP %s: failed to load asset: %v", dvp.ID, err)
+		return
+	}
+
+	buyerATA, _, _ := solana.FindAssociatedTokenAddress(buyerKey, solana.MustPublicKeyFromBase58(asset.MintAddress))
+	if err := s.DB.TransferTokenBalance(dvp.SellerID, dvp.BuyerID, asset.ID, dvp.Quantity, txID, buyerATA.String()); err != nil {
+		log.Printf("ERROR: DvP %s: transaction %s finalized, but the asset leg failed to book: %v", dvp.ID, txID, err)
+	}
+	paymentAsset, found, err := s.DB.GetAssetByMintAddress(dvp.PaymentMint)
+	if err != nil {
+		log.Printf("DvP %s: failed to look up payment asset: %v", dvp.ID, err)
+	} else if found {
+		sellerATA, _, _ := solana.FindAssociatedTokenAddress(sellerKey, solana.MustPublicKeyFromBase58(dvp.PaymentMint))
+		if err := s.DB.TransferTokenBalance(dvp.BuyerID, seller.ID, paymentAsset.ID, dvp.PaymentAmount, txID, sellerATA.String()); err != nil {
+			log.Printf("ERROR: DvP %s: transaction %s finalized, but the payment leg failed to book: %v", dvp.ID, txID, err)
+		}
+	}
+
+	if err := s.DB.MarkDvPSettled(dvp.ID); err != nil {
+		log.Printf("DvP %s: failed to mark settled: %v", dvp.ID, err)
+		return
+	}
+	price := dvp.PaymentAmount / dvp.Quantity
+	if err := s.Tokenization.Tax.RecordTransfer(asset.ID, dvp.SellerID, dvp.BuyerID, dvp.Quantity, &price, txID, time.Now()); err != nil {
+		log.Printf("ERROR: Failed to record tax effects of DvP %s: %v", dvp.ID, err)
+	}
+	log.Printf("DvP %s settled | TxID: %s", dvp.ID, txID)
+}
+
+// Reconcile resolves a submitted settlement whose finalization was not
+// observed: it is booked if the transaction finalized, and failed if it
+// failed or its blockhash expired.
+func (s *DvPService) Reconcile(id string) (models.DvPSettlement, error) {
+	dvp, err := s.GetSettlement(id)
+	if err != nil {
+		return models.DvPSettlement{}, err
+	}
+	if dvp.Status != models.DvPStatusSubmitted || dvp.TransactionID == nil {
+		return dvp, nil
+	}
+
+	finalized, err := s.SolanaS.GetTransactionFinalization(solana.MustSignatureFromBase58(*dvp.TransactionID))
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		if err := s.DB.MarkDvPFailed(dvp.ID, err.Error()); err != nil {
+			return models.DvPSettlement{}, fmt.Errorf("failed to record failure: %w", err)
+		}
+	case err != nil:
+		return models.DvPSettlement{}, err
+	case finalized:
+		s.book(dvp, *dvp.TransactionID)
+	case time.Since(dvp.UpdatedAt) > payoutExpiry:
+		if err := s.DB.MarkDvPFailed(dvp.ID, "transaction "+*dvp.TransactionID+" was not finalized"); err != nil {
+			return models.DvPSettlement{}, fmt.Errorf("failed to record failure: %w", err)
+		}
+	}
+	return s.GetSettlement(dvp.ID)
+}
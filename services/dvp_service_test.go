@@ -0,0 +1,211 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// fakeBlockhashRPC answers getRecentBlockhash, which is all preparing a
+// transaction for users to sign needs.
+func fakeBlockhashRPC(t *testing.T) *rpc.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getRecentBlockhash" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0", "id": req.ID,
+			"result": map[string]any{
+				"context": map[string]any{"slot": 1},
+				"value":   map[string]any{"blockhash": solana.Hash{1}.String(), "feeCalculator": map[string]any{"lamportsPerSignature": 5000}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return rpc.New(server.URL)
+}
+
+// signedBy returns a copy of a prepared transaction signed by `key` alone.
+func signedBy(t *testing.T, preparedBase64 string, key solana.PrivateKey) string {
+	t.Helper()
+	tx, err := solana.TransactionFromBase64(preparedBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Signatures = make([]solana.Signature, len(tx.Signatures))
+	if _, err := tx.PartialSign(func(pub solana.PublicKey) *solana.PrivateKey {
+		if pub.Equals(key.PublicKey()) {
+			return &key
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestPrepareDvPTransaction(t *testing.T) {
+	feePayer, seller, buyer := solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey
+	assetMint, paymentMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	s := &SolanaIntegrationService{RPCClient: fakeBlockhashRPC(t), FeePayer: feePayer}
+
+	prepared, err := s.WithReference(models.TxReference{Type: models.ReferenceSettlement, ID: "d1"}).
+		PrepareDvPTransaction(assetMint, paymentMint, seller.PublicKey(), buyer.PublicKey(), 5_000_000_000, 250_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.TransactionFromBase64(prepared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signers := tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures]
+	if len(signers) != 3 || !signers[0].Equals(feePayer.PublicKey()) {
+		t.Fatalf("signers = %v, want the FeePayer, the seller and the buyer", signers)
+	}
+	for _, party := range []solana.PublicKey{seller.PublicKey(), buyer.PublicKey()} {
+		if !tx.IsSigner(party) {
+			t.Fatalf("%s does not sign the settlement", party)
+		}
+	}
+
+	// Both legs travel in the one transaction, each authorized by the party paying it
+	ata := func(owner, mint solana.PublicKey) solana.PublicKey {
+		a, _, _ := solana.FindAssociatedTokenAddress(owner, mint)
+		return a
+	}
+	type leg struct {
+		amount              uint64
+		source, destination solana.PublicKey
+		authority           solana.PublicKey
+	}
+	var legs []leg
+	memos := 0
+	for _, ix := range tx.Message.Instructions {
+		program, err := tx.Message.Program(ix.ProgramIDIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case program.Equals(solana.MemoProgramID):
+			memos++
+			if string(ix.Data) != "tiquin:ref:dvp:d1" {
+				t.Errorf("memo = %q, want the settlement reference", ix.Data)
+			}
+		case program.Equals(solana.TokenProgramID):
+			accounts, err := ix.ResolveInstructionAccounts(&tx.Message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := token.DecodeInstruction(accounts, ix.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transfer, ok := decoded.Impl.(*token.Transfer)
+			if !ok {
+				t.Fatalf("unexpected token instruction %T", decoded.Impl)
+			}
+			legs = append(legs, leg{*transfer.Amount, transfer.GetSourceAccount().PublicKey, transfer.GetDestinationAccount().PublicKey, transfer.GetOwnerAccount().PublicKey})
+		}
+	}
+	want := []leg{
+		{5_000_000_000, ata(seller.PublicKey(), assetMint), ata(buyer.PublicKey(), assetMint), seller.PublicKey()},
+		{250_000, ata(buyer.PublicKey(), paymentMint), ata(seller.PublicKey(), paymentMint), buyer.PublicKey()},
+	}
+	if len(legs) != 2 || legs[0] != want[0] || legs[1] != want[1] {
+		t.Fatalf("legs = %+v, want %+v", legs, want)
+	}
+	if memos != 1 {
+		t.Fatalf("%d reference memos, want 1", memos)
+	}
+}
+
+func TestMergeSignature(t *testing.T) {
+	feePayer, seller, buyer, outsider := solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey, solana.NewWallet().PrivateKey
+	assetMint, paymentMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	s := &SolanaIntegrationService{RPCClient: fakeBlockhashRPC(t), FeePayer: feePayer}
+	prepare := func(t *testing.T, assetAmount uint64) string {
+		t.Helper()
+		prepared, err := s.PrepareDvPTransaction(assetMint, paymentMint, seller.PublicKey(), buyer.PublicKey(), assetAmount, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prepared
+	}
+	merge := func(t *testing.T, prepared, signed string, signer solana.PublicKey) string {
+		t.Helper()
+		merged, err := s.MergeSignature(prepared, signed, signer)
+		if err != nil {
+			t.Fatalf("MergeSignature() error = %v", err)
+		}
+		return merged
+	}
+	prepared := prepare(t, 10)
+
+	for name, order := range map[string][]solana.PrivateKey{"seller first": {seller, buyer}, "buyer first": {buyer, seller}} {
+		t.Run(name, func(t *testing.T) {
+			merged := prepared
+			for _, party := range order {
+				merged = merge(t, merged, signedBy(t, prepared, party), party.PublicKey())
+			}
+			tx, err := solana.TransactionFromBase64(merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.VerifySignatures(); err != nil {
+				t.Fatalf("merged transaction does not carry every signature: %v", err)
+			}
+		})
+	}
+
+	t.Run("a party signing again keeps the other signature", func(t *testing.T) {
+		merged := merge(t, prepared, signedBy(t, prepared, seller), seller.PublicKey())
+		merged = merge(t, merged, signedBy(t, prepared, buyer), buyer.PublicKey())
+		merged = merge(t, merged, signedBy(t, prepared, seller), seller.PublicKey())
+		tx, err := solana.TransactionFromBase64(merged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.VerifySignatures(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		signed string
+		signer solana.PublicKey
+	}{
+		{"another transaction", signedBy(t, prepare(t, 11), seller), seller.PublicKey()},
+		{"signed by the other party", signedBy(t, prepared, buyer), seller.PublicKey()},
+		{"unsigned copy", prepared, seller.PublicKey()},
+		{"not a party", signedBy(t, prepared, outsider), outsider.PublicKey()},
+		{"not a transaction", base64.StdEncoding.EncodeToString([]byte("garbage")), seller.PublicKey()},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.MergeSignature(prepared, tt.signed, tt.signer); !errors.Is(err, ErrTransactionMismatch) {
+				t.Fatalf("MergeSignature() error = %v, want %v", err, ErrTransactionMismatch)
+			}
+		})
+	}
+}
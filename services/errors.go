@@ -0,0 +1,51 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds that handlers translate into HTTP status codes. Specific errors
+// wrap one of them so callers can match either.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPreconditionFailed reports an update based on a stale version.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ErrAssetNotFound is returned when the requested asset does not exist.
+var ErrAssetNotFound = fmt.Errorf("asset %w", ErrNotFound)
+
+// ErrUserNotFound is returned when the requested user does not exist.
+var ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+
+// ValidationError reports input that a service refuses to act on.
+type ValidationError struct {
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+// invalidf builds a ValidationError from a format string.
+func invalidf(format string, args ...any) error {
+	return &ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ComplianceError reports the asset rule that blocked a transfer or mint.
+type ComplianceError struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+func (e *ComplianceError) Error() string {
+	return fmt.Sprintf("compliance rule %s failed: %s", e.Rule, e.Reason)
+}
+
+// violation builds a ComplianceError for a rule.
+func violation(rule, format string, args ...any) error {
+	return &ComplianceError{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// escrowPollInterval is how often the scheduler evaluates escrow conditions and expiries.
+const escrowPollInterval = time.Minute
+
+var (
+	// ErrEscrowNotFound is returned when the requested escrow does not exist.
+	ErrEscrowNotFound = fmt.Errorf("escrow %w", ErrNotFound)
+	// ErrEscrowNotAwaitingDeposit is returned when depositing into an escrow that was already funded or closed.
+	ErrEscrowNotAwaitingDeposit = fmt.Errorf("%w: escrow is not awaiting its deposit", ErrConflict)
+	// ErrEscrowNotFunded is returned when releasing or refunding an escrow that does not hold the deposit.
+	ErrEscrowNotFunded = fmt.Errorf("%w: escrow is not funded", ErrConflict)
+	// ErrEscrowConditionNotMet is returned when the escrow's condition does not allow the requested settlement yet.
+	ErrEscrowConditionNotMet = fmt.Errorf("%w: escrow condition is not met", ErrConflict)
+)
+
+// EscrowService holds tokens in per-escrow token accounts controlled by the
+// backend. The depositor funds the account; once the condition is met the
+// tokens are released to the beneficiary, and if it fails or the escrow
+// expires they are refunded to the depositor.
+type EscrowService struct {
+	DB           *storage.DB
+	SolanaS      *SolanaIntegrationService
+	Tokenization *TokenizationService // Compliance, AML, vesting and tax
+	stopCh       chan struct{}
+}
+
+func NewEscrowService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *EscrowService {
+	return &EscrowService{DB: db, SolanaS: solanaS, Tokenization: tokenization, stopCh: make(chan struct{})}
+}
+
+// CreateEscrowInput describes a new escrow.
+type CreateEscrowInput struct {
+	Mint               string    `json:"mint"`
+	Amount             float64   `json:"amount"`
+	DepositorID        string    `json:"depositor_id"`
+	BeneficiaryID      string    `json:"beneficiary_id"`
+	ConditionType      string    `json:"condition_type"`
+	ConditionReference string    `json:"condition_reference,omitempty"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// CreateEscrow validates the escrow, creates its token account and prepares
+// the deposit transaction for the depositor to sign.
+func (s *EscrowService) CreateEscrow(in CreateEscrowInput) (models.Escrow, error) {
+	if in.Amount <= 0 {
+		return models.Escrow{}, invalidf("amount must be positive")
+	}
+	if in.DepositorID == in.BeneficiaryID {
+		return models.Escrow{}, invalidf("depositor and beneficiary must differ")
+	}
+	if !in.ExpiresAt.After(time.Now()) {
+		return models.Escrow{}, invalidf("expires_at must be in the future")
+	}
+	mint, err := solana.PublicKeyFromBase58(in.Mint)
+	if err != nil {
+		return models.Escrow{}, invalidf("invalid mint: %v", err)
+	}
+
+	var reference *string
+	switch in.ConditionType {
+	case models.EscrowConditionApproval:
+	case models.EscrowConditionOffering:
+		if _, found, err := s.DB.GetOffering(in.ConditionReference); err != nil {
+			return models.Escrow{}, fmt.Errorf("error fetching offering: %w", err)
+		} else if !found {
+			return models.Escrow{}, ErrOfferingNotFound
+		}
+		reference = &in.ConditionReference
+	default:
+		return models.Escrow{}, invalidf("condition_type must be %q or %q", models.EscrowConditionApproval, models.EscrowConditionOffering)
+	}
+
+	depositor, depositorKey, err := s.party(in.DepositorID)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	beneficiary, _, err := s.party(in.BeneficiaryID)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+
+	decimals, err := s.SolanaS.GetMintDecimals(mint)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to read mint: %w", err)
+	}
+	amountAtomic := toAtomic(in.Amount, decimals)
+	if amountAtomic == 0 {
+		return models.Escrow{}, invalidf("amount is below one atomic unit")
+	}
+	balance, err := s.SolanaS.GetOwnerTokenBalance(depositorKey, mint)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if balance < amountAtomic {
+		return models.Escrow{}, invalidf("insufficient depositor balance: have %d, need %d atomic units", balance, amountAtomic)
+	}
+
+	// Escrowed registered assets are held for the beneficiary, so they must be
+	// eligible to receive them and the depositor free to part with them
+	var assetID *string
+	asset, isAsset, err := s.DB.GetAssetByMintAddress(in.Mint)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	now := time.Now()
+	if isAsset {
+		assetID = &asset.ID
+		if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, From: &depositor, To: beneficiary, Amount: in.Amount, Timestamp: now}); err != nil {
+			return models.Escrow{}, err
+		}
+		if err := s.Tokenization.Vesting.CheckTransferable(depositor.ID, asset.ID, fromAtomic(balance, decimals), in.Amount, now); err != nil {
+			return models.Escrow{}, err
+		}
+	}
+	id := uuid.New().String()
+	if err := s.Tokenization.AML.ScreenMovement("escrow", id, depositor, beneficiary); err != nil {
+		return models.Escrow{}, err
+	}
+
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceEscrow, ID: id})
+	escrowAccount, err := solanaS.CreateEscrowAccount(strings.ReplaceAll(id, "-", ""), mint)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to create escrow account: %w", err)
+	}
+	depositTx, err := s.prepareDeposit(id, mint, depositorKey, escrowAccount, amountAtomic)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+
+	escrow := models.Escrow{
+		ID:                 id,
+		AssetID:            assetID,
+		Mint:               in.Mint,
+		Decimals:           int(decimals),
+		Amount:             in.Amount,
+		DepositorID:        depositor.ID,
+		BeneficiaryID:      beneficiary.ID,
+		EscrowAccount:      escrowAccount.String(),
+		ConditionType:      in.ConditionType,
+		ConditionReference: reference,
+		Status:             models.EscrowStatusAwaitingDeposit,
+		DepositTransaction: depositTx,
+		ExpiresAt:          in.ExpiresAt,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.DB.SaveEscrow(escrow); err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to save escrow: %w", err)
+	}
+	return escrow, nil
+}
+
+// prepareDeposit builds the transfer from the depositor's ATA into the escrow account.
+func (s *EscrowService) prepareDeposit(id string, mint, depositor, escrowAccount solana.PublicKey, amount uint64) (string, error) {
+	depositorATA, _, err := solana.FindAssociatedTokenAddress(depositor, mint)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive depositor ATA: %w", err)
+	}
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceEscrow, ID: id})
+	depositTx, err := solanaS.PrepareTransferTransaction(mint, depositorATA, escrowAccount, depositor, amount)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare deposit: %w", err)
+	}
+	return depositTx, nil
+}
+
+// party loads a party to an escrow with their wallet.
+func (s *EscrowService) party(userID string) (models.User, solana.PublicKey, error) {
+	user, found, err := s.DB.GetUser(userID)
+	if err != nil {
+		return models.User{}, solana.PublicKey{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found || user.SolanaPubKey == "" {
+		return models.User{}, solana.PublicKey{}, ErrUserNotFound
+	}
+	key, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return models.User{}, solana.PublicKey{}, fmt.Errorf("invalid public key of user %s: %w", userID, err)
+	}
+	return user, key, nil
+}
+
+// GetEscrow returns an escrow.
+func (s *EscrowService) GetEscrow(id string) (models.Escrow, error) {
+	escrow, found, err := s.DB.GetEscrow(id)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("error fetching escrow: %w", err)
+	}
+	if !found {
+		return models.Escrow{}, ErrEscrowNotFound
+	}
+	return escrow, nil
+}
+
+// PrepareDeposit rebuilds the deposit transaction with a fresh blockhash, for
+// when the one returned at creation expired before the depositor signed it.
+func (s *EscrowService) PrepareDeposit(id string) (models.Escrow, error) {
+	escrow, err := s.GetEscrow(id)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if escrow.Status != models.EscrowStatusAwaitingDeposit {
+		return models.Escrow{}, ErrEscrowNotAwaitingDeposit
+	}
+	_, depositorKey, err := s.party(escrow.DepositorID)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	mint := solana.MustPublicKeyFromBase58(escrow.Mint)
+	depositTx, err := s.prepareDeposit(escrow.ID, mint, depositorKey, solana.MustPublicKeyFromBase58(escrow.EscrowAccount), toAtomic(escrow.Amount, uint8(escrow.Decimals)))
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if err := s.DB.UpdateEscrowDepositTransaction(escrow.ID, depositTx); err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to store deposit transaction: %w", err)
+	}
+	escrow.DepositTransaction = depositTx
+	return escrow, nil
+}
+
+// Deposit sends the deposit signed by the depositor and marks the escrow
+// funded once it is confirmed. The signed transaction must be the prepared one.
+func (s *EscrowService) Deposit(id, signedTxBase64 string) (models.Escrow, error) {
+	escrow, err := s.GetEscrow(id)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if escrow.Status != models.EscrowStatusAwaitingDeposit {
+		return models.Escrow{}, ErrEscrowNotAwaitingDeposit
+	}
+	if !time.Now().Before(escrow.ExpiresAt) {
+		return models.Escrow{}, fmt.Errorf("%w: escrow expired", ErrEscrowNotAwaitingDeposit)
+	}
+	_, depositorKey, err := s.party(escrow.DepositorID)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+
+	signedTx, err := s.SolanaS.MergeSignature(escrow.DepositTransaction, signedTxBase64, depositorKey)
+	if err != nil {
+		if errors.Is(err, ErrTransactionMismatch) {
+			return models.Escrow{}, invalidf("%v", err)
+		}
+		return models.Escrow{}, err
+	}
+	sig, err := s.SolanaS.SendSignedTransaction(signedTx)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to send deposit: %w", err)
+	}
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("deposit failed: %w", err)
+	}
+	if !confirmed {
+		return models.Escrow{}, fmt.Errorf("deposit %s was not confirmed in time", sig)
+	}
+
+	funded, err := s.DB.FundEscrow(escrow, sig.String())
+	if err != nil {
+		return models.Escrow{}, fmt.Errorf("failed to record deposit %s: %w", sig, err)
+	}
+	if !funded {
+		return models.Escrow{}, ErrEscrowNotAwaitingDeposit
+	}
+	log.Printf("Escrow %s funded | TxID: %s", escrow.ID, sig)
+	return s.GetEscrow(escrow.ID)
+}
+
+// conditionStatus reports whether a funded escrow may be released and
+// whether it must be refunded.
+func (s *EscrowService) conditionStatus(escrow models.Escrow) (release, refund bool, err error) {
+	var offering models.Offering
+	if escrow.ConditionType == models.EscrowConditionOffering {
+		offering, err = s.offeringCondition(escrow)
+		if err != nil {
+			return false, false, err
+		}
+	}
+	release, refund = escrowOutcome(escrow, offering.Status, time.Now())
+	return release, refund, nil
+}
+
+// escrowOutcome decides conditionStatus from the status of the offering the
+// escrow waits on, if any. Offering escrows follow the offering's outcome and
+// are refunded if it is still open when they expire; approval escrows are
+// released on request until they expire, and refunded after.
+func escrowOutcome(escrow models.Escrow, offeringStatus string, now time.Time) (release, refund bool) {
+	expired := !now.Before(escrow.ExpiresAt)
+	if escrow.ConditionType != models.EscrowConditionOffering {
+		return !expired, expired
+	}
+	release = offeringStatus == models.OfferingStatusSucceeded
+	refund = offeringStatus == models.OfferingStatusFailed || expired && !release
+	return release, refund
+}
+
+// offeringCondition returns the offering an escrow waits on.
+func (s *EscrowService) offeringCondition(escrow models.Escrow) (models.Offering, error) {
+	if escrow.ConditionReference == nil {
+		return models.Offering{}, ErrOfferingNotFound
+	}
+	offering, found, err := s.DB.GetOffering(*escrow.ConditionReference)
+	if err != nil {
+		return models.Offering{}, fmt.Errorf("error fetching offering: %w", err)
+	}
+	if !found {
+		return models.Offering{}, ErrOfferingNotFound
+	}
+	return offering, nil
+}
+
+// Release sends the escrowed tokens to the beneficiary. Approval escrows are
+// released by this call until they expire; other conditions must be met.
+func (s *EscrowService) Release(id string) (models.Escrow, error) {
+	return s.settleByRequest(id, true)
+}
+
+// Refund returns the escrowed tokens to the depositor. Approval escrows can
+// be refunded at any time; others only once their condition failed or the
+// escrow expired.
+func (s *EscrowService) Refund(id string) (models.Escrow, error) {
+	return s.settleByRequest(id, false)
+}
+
+func (s *EscrowService) settleByRequest(id string, release bool) (models.Escrow, error) {
+	escrow, err := s.GetEscrow(id)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if escrow.Status != models.EscrowStatusFunded {
+		return models.Escrow{}, ErrEscrowNotFunded
+	}
+	canRelease, mustRefund, err := s.conditionStatus(escrow)
+	if err != nil {
+		return models.Escrow{}, err
+	}
+	if release && !canRelease {
+		return models.Escrow{}, ErrEscrowConditionNotMet
+	}
+	if !release && !mustRefund && escrow.ConditionType != models.EscrowConditionApproval {
+		return models.Escrow{}, ErrEscrowConditionNotMet
+	}
+	if err := s.settle(escrow, release); err != nil {
+		return models.Escrow{}, err
+	}
+	return s.GetEscrow(id)
+}
+
+// settle sends a release or refund from the escrow account and books it once
+// confirmed. Claiming the escrow first keeps concurrent calls from sending
+// twice; a transaction that is not confirmed in time is left for the
+// scheduler to reconcile.
+func (s *EscrowService) settle(escrow models.Escrow, release bool) error {
+	recipientID, status := escrow.DepositorID, models.EscrowStatusRefunding
+	if release {
+		recipientID, status = escrow.BeneficiaryID, models.EscrowStatusReleasing
+	}
+	recipient, recipientKey, err := s.party(recipientID)
+	if err != nil {
+		return err
+	}
+	if release && escrow.AssetID != nil {
+		asset, _, err := s.DB.GetAsset(*escrow.AssetID)
+		if err != nil {
+			return fmt.Errorf("error fetching asset: %w", err)
+		}
+		depositor, _, err := s.party(escrow.DepositorID)
+		if err != nil {
+			return err
+		}
+		if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, From: &depositor, To: recipient, Amount: escrow.Amount, Timestamp: time.Now()}); err != nil {
+			return err
+		}
+	}
+
+	claimed, err := s.DB.ClaimEscrow(escrow.ID, models.EscrowStatusFunded, status)
+	if err != nil {
+		return fmt.Errorf("failed to claim escrow: %w", err)
+	}
+	if !claimed {
+		return ErrEscrowNotFunded
+	}
+	escrow.Status = status
+
+	mint := solana.MustPublicKeyFromBase58(escrow.Mint)
+	escrowAccount := solana.MustPublicKeyFromBase58(escrow.EscrowAccount)
+	amount := toAtomic(escrow.Amount, uint8(escrow.Decimals))
+	// Close the account only if nothing beyond the deposit was sent to it
+	held, err := s.SolanaS.GetTokenAccountBalance(escrowAccount)
+	closeAccount := err == nil && held == amount
+
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceEscrow, ID: escrow.ID})
+	sig, err := solanaS.SendFromEscrow(mint, escrowAccount, recipientKey, amount, closeAccount)
+	if err != nil {
+		if resetErr := s.DB.ResetEscrow(escrow.ID, err.Error()); resetErr != nil {
+			log.Printf("Escrow %s: failed to record failure: %v", escrow.ID, resetErr)
+		}
+		return fmt.Errorf("failed to send escrow transfer: %w", err)
+	}
+	if err := s.DB.MarkEscrowSent(escrow.ID, sig.String()); err != nil {
+		log.Printf("ERROR: escrow %s: tx %s sent but not recorded: %v", escrow.ID, sig, err)
+		return fmt.Errorf("failed to record escrow transfer %s: %w", sig, err)
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		if resetErr := s.DB.ResetEscrow(escrow.ID, err.Error()); resetErr != nil {
+			log.Printf("Escrow %s: failed to record failure: %v", escrow.ID, resetErr)
+		}
+		return fmt.Errorf("escrow transfer failed: %w", err)
+	case err != nil:
+		log.Printf("Escrow %s: could not check tx %s: %v; will reconcile", escrow.ID, sig, err)
+	case confirmed:
+		s.book(escrow, sig.String())
+	default:
+		log.Printf("Escrow %s: tx %s not confirmed yet; will reconcile", escrow.ID, sig)
+	}
+	return nil
+}
+
+// book records a confirmed release or refund. A release transfers ownership
+// from the depositor to the beneficiary for tax purposes.
+func (s *EscrowService) book(escrow models.Escrow, txID string) {
+	if err := s.DB.SettleEscrow(escrow, txID); err != nil {
+		log.Printf("ERROR: escrow %s: transaction %s confirmed, but failed to book: %v", escrow.ID, txID, err)
+		return
+	}
+	if escrow.Status == models.EscrowStatusReleasing && escrow.AssetID != nil {
+		err := s.Tokenization.Tax.RecordTransfer(*escrow.AssetID, escrow.DepositorID, escrow.BeneficiaryID, escrow.Amount, nil, txID, time.Now())
+		if err != nil {
+			log.Printf("ERROR: Failed to record tax effects of escrow %s: %v", escrow.ID, err)
+		}
+	}
+	log.Printf("Escrow %s settled (%s) | TxID: %s", escrow.ID, escrow.Status, txID)
+}
+
+// reconcile resolves a release or refund whose confirmation was not observed.
+func (s *EscrowService) reconcile(escrow models.Escrow) error {
+	if escrow.SettlementTxID == nil {
+		// Claimed but never sent
+		if time.Since(escrow.UpdatedAt) > payoutExpiry {
+			return s.DB.ResetEscrow(escrow.ID, "settlement was not sent")
+		}
+		return nil
+	}
+
+	confirmed, err := s.SolanaS.GetTransactionConfirmation(solana.MustSignatureFromBase58(*escrow.SettlementTxID))
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		return s.DB.ResetEscrow(escrow.ID, err.Error())
+	case err != nil:
+		return err
+	case confirmed:
+		s.book(escrow, *escrow.SettlementTxID)
+	case time.Since(escrow.UpdatedAt) > payoutExpiry:
+		// Never landed and the blockhash has expired: safe to resend
+		return s.DB.ResetEscrow(escrow.ID, "transaction "+*escrow.SettlementTxID+" was not confirmed")
+	}
+	return nil
+}
+
+// Stop signals the scheduler to shut down.
+func (s *EscrowService) Stop() {
+	close(s.stopCh)
+}
+
+// StartScheduler periodically expires unfunded escrows, releases or refunds
+// funded ones whose condition resolved or which expired, and reconciles
+// unconfirmed settlements. Blocks until Stop() is called.
+func (s *EscrowService) StartScheduler() {
+	ticker := time.NewTicker(escrowPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processEscrows()
+		select {
+		case <-s.stopCh:
+			log.Println("Escrow scheduler stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processEscrows runs one pass of the scheduler.
+func (s *EscrowService) processEscrows() {
+	escrows, err := s.DB.GetActiveEscrows()
+	if err != nil {
+		log.Printf("Escrow scheduler: failed to load escrows: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, escrow := range escrows {
+		switch escrow.Status {
+		case models.EscrowStatusAwaitingDeposit:
+			if !now.Before(escrow.ExpiresAt) {
+				if _, err := s.DB.ClaimEscrow(escrow.ID, models.EscrowStatusAwaitingDeposit, models.EscrowStatusExpired); err != nil {
+					log.Printf("Escrow scheduler: failed to expire %s: %v", escrow.ID, err)
+				}
+			}
+		case models.EscrowStatusFunded:
+			release, refund, err := s.conditionStatus(escrow)
+			if err != nil {
+				log.Printf("Escrow scheduler: failed to evaluate %s: %v", escrow.ID, err)
+				continue
+			}
+			// Approval escrows wait for an explicit release until they expire
+			if escrow.ConditionType == models.EscrowConditionApproval {
+				release = false
+			}
+			if release || refund {
+				if err := s.settle(escrow, release); err != nil {
+					log.Printf("Escrow scheduler: failed to settle %s: %v", escrow.ID, err)
+				}
+			}
+		default:
+			if err := s.reconcile(escrow); err != nil {
+				log.Printf("Escrow scheduler: failed to reconcile %s: %v", escrow.ID, err)
+			}
+		}
+	}
+}
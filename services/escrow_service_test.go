@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/google/uuid"
+)
+
+func TestEscrowOutcome(t *testing.T) {
+	now := time.Now()
+	escrowOf := func(condition string, expiresIn time.Duration) models.Escrow {
+		return models.Escrow{ConditionType: condition, ExpiresAt: now.Add(expiresIn)}
+	}
+
+	tests := []struct {
+		name           string
+		escrow         models.Escrow
+		offeringStatus string
+		wantRelease    bool
+		wantRefund     bool
+	}{
+		{"approval before expiry", escrowOf(models.EscrowConditionApproval, time.Hour), "", true, false},
+		{"approval at expiry", escrowOf(models.EscrowConditionApproval, 0), "", false, true},
+		{"approval after expiry", escrowOf(models.EscrowConditionApproval, -time.Hour), "", false, true},
+		{"offering open", escrowOf(models.EscrowConditionOffering, time.Hour), models.OfferingStatusOpen, false, false},
+		{"offering succeeded", escrowOf(models.EscrowConditionOffering, time.Hour), models.OfferingStatusSucceeded, true, false},
+		{"offering failed", escrowOf(models.EscrowConditionOffering, time.Hour), models.OfferingStatusFailed, false, true},
+		{"offering open after expiry", escrowOf(models.EscrowConditionOffering, -time.Hour), models.OfferingStatusOpen, false, true},
+		{"offering succeeded after expiry", escrowOf(models.EscrowConditionOffering, -time.Hour), models.OfferingStatusSucceeded, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, refund := escrowOutcome(tt.escrow, tt.offeringStatus, now)
+			if release != tt.wantRelease || refund != tt.wantRefund {
+				t.Fatalf("escrowOutcome() = release %v, refund %v, want %v, %v", release, refund, tt.wantRelease, tt.wantRefund)
+			}
+		})
+	}
+}
+
+func TestEscrowAccountAddress(t *testing.T) {
+	s := &SolanaIntegrationService{FeePayer: solana.NewWallet().PrivateKey}
+	other := &SolanaIntegrationService{FeePayer: solana.NewWallet().PrivateKey}
+	address := func(t *testing.T, s *SolanaIntegrationService, seed string) solana.PublicKey {
+		t.Helper()
+		a, err := s.EscrowAccountAddress(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	seed := strings.ReplaceAll(uuid.New().String(), "-", "") // As CreateEscrow derives it
+
+	first := address(t, s, seed)
+	if again := address(t, s, seed); again != first {
+		t.Fatalf("EscrowAccountAddress() = %s, then %s", first, again)
+	}
+	if next := address(t, s, strings.ReplaceAll(uuid.New().String(), "-", "")); next == first {
+		t.Fatal("two escrows share a token account")
+	}
+	if theirs := address(t, other, seed); theirs == first {
+		t.Fatal("another FeePayer derived the same token account")
+	}
+	if _, err := s.EscrowAccountAddress(seed + "x"); err == nil {
+		t.Fatal("EscrowAccountAddress() accepted a seed over 32 characters")
+	}
+}
+
+func TestSignTransferFromEscrow(t *testing.T) {
+	feePayer := solana.NewWallet().PrivateKey
+	s := &SolanaIntegrationService{RPCClient: fakeBlockhashRPC(t), FeePayer: feePayer}
+	mint, recipient := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	escrowAccount, err := s.EscrowAccountAddress("0b5f3a0e9a434a7e9d567f8e1f2f6a11")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx, sig, err := s.WithReference(models.TxReference{Type: models.ReferenceEscrow, ID: "e1"}).
+		SignTransferFromEscrow(mint, escrowAccount, recipient, 750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.TransactionFromBase64(signedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Signatures[0] != sig {
+		t.Fatalf("returned signature %s is not the transaction's %s", sig, tx.Signatures[0])
+	}
+	if signers := tx.Message.Header.NumRequiredSignatures; signers != 1 {
+		t.Fatalf("%d signers, want the FeePayer alone", signers)
+	}
+
+	recipientATA, _, _ := solana.FindAssociatedTokenAddress(recipient, mint)
+	var transfers int
+	var memo string
+	for _, ix := range tx.Message.Instructions {
+		program, err := tx.Message.Program(ix.ProgramIDIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case program.Equals(solana.MemoProgramID):
+			memo = string(ix.Data)
+		case program.Equals(solana.TokenProgramID):
+			accounts, err := ix.ResolveInstructionAccounts(&tx.Message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := token.DecodeInstruction(accounts, ix.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transfer, ok := decoded.Impl.(*token.Transfer)
+			if !ok {
+				t.Fatalf("unexpected token instruction %T", decoded.Impl)
+			}
+			transfers++
+			if *transfer.Amount != 750 || transfer.GetSourceAccount().PublicKey != escrowAccount ||
+				transfer.GetDestinationAccount().PublicKey != recipientATA || transfer.GetOwnerAccount().PublicKey != feePayer.PublicKey() {
+				t.Fatalf("transfer of %d from %s to %s by %s, want 750 from the escrow account to the recipient's ATA by the FeePayer",
+					*transfer.Amount, transfer.GetSourceAccount().PublicKey, transfer.GetDestinationAccount().PublicKey, transfer.GetOwnerAccount().PublicKey)
+			}
+		}
+	}
+	if transfers != 1 {
+		t.Fatalf("%d token transfers, want 1", transfers)
+	}
+	if memo != "tiquin:ref:escrow:e1" {
+		t.Fatalf("memo = %q, want the escrow reference", memo)
+	}
+}
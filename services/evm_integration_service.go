@@ -0,0 +1,626 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// EVMSimulatedRPC is the EVM_RPC_URL value that runs an in-process simulated
+// chain instead of connecting to a node, for local development and tests.
+const EVMSimulatedRPC = "simulated"
+
+// evmConfirmationTimeout bounds how long backend transactions are awaited.
+const evmConfirmationTimeout = 60 * time.Second
+
+// permissionedTokenRuntime is the token contract's runtime code in go-ethereum
+// assembly; see the file for its storage layout and functions.
+//
+//go:embed contracts/permissioned_token.easm
+var permissionedTokenRuntime string
+
+// permissionedTokenInit stores the deployer as owner and the packed name and
+// symbol passed after the code, then returns the runtime code. The offsets
+// are filled in once the runtime is assembled.
+const permissionedTokenInit = `
+	CALLER
+	PUSH 0
+	SSTORE
+	PUSH 0x40
+	PUSH %d
+	PUSH 0
+	CODECOPY
+	PUSH 0
+	MLOAD
+	PUSH 5
+	SSTORE
+	PUSH 0x20
+	MLOAD
+	PUSH 6
+	SSTORE
+	PUSH %d
+	DUP1
+	PUSH %d
+	PUSH 0
+	CODECOPY
+	PUSH 0
+	RETURN
+`
+
+// permissionedTokenABI describes the functions of the token contract.
+const permissionedTokenABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"holder","type":"address"}],"outputs":[{"type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"type":"uint256"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"type":"bool"}]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"type":"bool"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"type":"bool"}]},
+	{"type":"function","name":"owner","stateMutability":"view","inputs":[],"outputs":[{"type":"address"}]},
+	{"type":"function","name":"isVerified","stateMutability":"view","inputs":[{"name":"holder","type":"address"}],"outputs":[{"type":"bool"}]},
+	{"type":"function","name":"setVerified","stateMutability":"nonpayable","inputs":[{"name":"holder","type":"address"},{"name":"verified","type":"bool"}],"outputs":[]},
+	{"type":"function","name":"mint","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"burn","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"forcedTransfer","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"type":"bool"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+// ErrSignerMismatch is returned when a signed EVM transaction was not signed
+// by the expected wallet.
+var ErrSignerMismatch = errors.New("transaction was not signed by the expected wallet")
+
+// ErrNonceUsed is returned when a transaction can no longer be mined because
+// another transaction of the same sender took its nonce.
+var ErrNonceUsed = errors.New("transaction nonce was already used")
+
+// transferTopic is the signature of the ERC-20 Transfer event.
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// EVMClient is the part of an Ethereum JSON-RPC client the EVM integration
+// uses. Both ethclient.Client and the simulated backend's client satisfy it.
+type EVMClient interface {
+	ethereum.BlockNumberReader
+	ethereum.ChainIDReader
+	ethereum.ChainReader
+	ethereum.ContractCaller
+	ethereum.GasEstimator
+	ethereum.GasPricer1559
+	ethereum.LogFilterer
+	ethereum.PendingStateReader
+	ethereum.TransactionReader
+	ethereum.TransactionSender
+}
+
+// EVMIntegrationService issues and moves ERC-20 tokens on an EVM chain such as
+// Hyperledger Besu. Its key deploys each asset's token contract and acts as
+// the contract's agent, mirroring the FeePayer's role as mint authority on
+// Solana. Holders sign their own transfers and pay their own gas.
+type EVMIntegrationService struct {
+	Client  EVMClient
+	Key     *ecdsa.PrivateKey
+	ChainID *big.Int
+	token   abi.ABI
+	initBin []byte // Token init code without constructor arguments
+	commit  func() // Seals a block on the simulated chain; nil on real nodes
+}
+
+// NewEVMIntegrationService connects to an EVM node, or starts an in-process
+// simulated chain funding the key when rpcURL is EVMSimulatedRPC. An empty
+// key is only accepted for the simulated chain, which then generates one.
+func NewEVMIntegrationService(rpcURL, privateKeyHex string) (*EVMIntegrationService, error) {
+	var key *ecdsa.PrivateKey
+	var err error
+	if privateKeyHex != "" {
+		key, err = crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVM private key: %w", err)
+		}
+	} else if rpcURL == EVMSimulatedRPC {
+		if key, err = crypto.GenerateKey(); err != nil {
+			return nil, fmt.Errorf("failed to generate EVM key: %w", err)
+		}
+	} else {
+		return nil, errors.New("an EVM private key is required")
+	}
+
+	s := &EVMIntegrationService{Key: key}
+	if rpcURL == EVMSimulatedRPC {
+		funds, _ := new(big.Int).SetString("1000000000000000000000000", 10) // 1M ether
+		backend := simulated.NewBackend(types.GenesisAlloc{s.Address(): {Balance: funds}})
+		s.Client = backend.Client()
+		s.commit = func() { backend.Commit() }
+		log.Printf("Started simulated EVM chain; agent %s", s.Address())
+	} else {
+		client, err := ethclient.Dial(rpcURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to EVM node: %w", err)
+		}
+		s.Client = client
+	}
+
+	if s.ChainID, err = s.Client.ChainID(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to read chain ID: %w", err)
+	}
+	if s.token, err = abi.JSON(strings.NewReader(permissionedTokenABI)); err != nil {
+		return nil, fmt.Errorf("failed to parse token ABI: %w", err)
+	}
+	if s.initBin, err = assemblePermissionedToken(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// assemblePermissionedToken assembles the token's runtime code and the init
+// code that deploys it. Constructor arguments are appended after both.
+func assemblePermissionedToken() ([]byte, error) {
+	runtime, err := assemble(permissionedTokenRuntime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble token runtime: %w", err)
+	}
+	// The init code's length depends on the offsets it pushes, so assemble it
+	// with a guess and again with its real length
+	initLen := 0x80
+	for i := 0; i < 2; i++ {
+		init, err := assemble(fmt.Sprintf(permissionedTokenInit, initLen+len(runtime), len(runtime), initLen))
+		if err != nil {
+			return nil, fmt.Errorf("failed to assemble token init code: %w", err)
+		}
+		if len(init) == initLen {
+			return append(init, runtime...), nil
+		}
+		initLen = len(init)
+	}
+	return nil, errors.New("token init code length did not converge")
+}
+
+// assemble compiles go-ethereum EVM assembly to bytecode.
+func assemble(source string) ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(source), false))
+	code, errs := compiler.Compile()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return hex.DecodeString(code)
+}
+
+// NormalizeEVMAddress validates a hex address and returns it in its EIP-55
+// checksummed form.
+func NormalizeEVMAddress(v string) (string, bool) {
+	if !common.IsHexAddress(v) {
+		return "", false
+	}
+	return common.HexToAddress(v).Hex(), true
+}
+
+// Address returns the agent's address.
+func (s *EVMIntegrationService) Address() common.Address {
+	return crypto.PubkeyToAddress(s.Key.PublicKey)
+}
+
+// shortString packs a string into a storage word as the contract expects:
+// up to 31 bytes left-aligned with the length in the low byte. Longer values
+// are cut at a UTF-8 boundary.
+func shortString(v string) [32]byte {
+	for len(v) > 31 {
+		v = v[:len(v)-1]
+		for len(v) > 0 && v[len(v)-1]&0xC0 == 0x80 {
+			v = v[:len(v)-1]
+		}
+		if len(v) > 0 && v[len(v)-1] >= 0xC0 {
+			v = v[:len(v)-1]
+		}
+	}
+	var word [32]byte
+	copy(word[:], v)
+	word[31] = byte(len(v))
+	return word
+}
+
+// DeployToken deploys a token contract with the agent as owner and waits
+// for it to be mined. Returns the contract address.
+func (s *EVMIntegrationService) DeployToken(name, symbol string) (common.Address, error) {
+	nameWord, symbolWord := shortString(name), shortString(symbol)
+	data := append(append(append([]byte{}, s.initBin...), nameWord[:]...), symbolWord[:]...)
+
+	tx, err := s.sendBackendTransaction(nil, data, "deploy token")
+	if err != nil {
+		return common.Address{}, err
+	}
+	receipt, err := s.waitMined(tx.Hash(), evmConfirmationTimeout)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("token deployment failed: %w", err)
+	}
+	log.Printf("Deployed token %s at %s | TxID: %s", symbol, receipt.ContractAddress, tx.Hash())
+	return receipt.ContractAddress, nil
+}
+
+// SetVerified adds a holder to (or removes one from) the token's registry of
+// verified holders, which receivers of mints and transfers must be in. It
+// does nothing if the holder is already in that state.
+func (s *EVMIntegrationService) SetVerified(tokenAddress, holder common.Address, verified bool) error {
+	var current bool
+	if err := s.call(tokenAddress, &current, "isVerified", holder); err != nil {
+		return err
+	}
+	if current == verified {
+		return nil
+	}
+	data, err := s.token.Pack("setVerified", holder, verified)
+	if err != nil {
+		return err
+	}
+	tx, err := s.sendBackendTransaction(&tokenAddress, data, "set verified")
+	if err != nil {
+		return err
+	}
+	if _, err := s.waitMined(tx.Hash(), evmConfirmationTimeout); err != nil {
+		return fmt.Errorf("failed to update verification of %s: %w", holder, err)
+	}
+	return nil
+}
+
+// MintTokens mints `amount` atomic units to a verified holder and waits for
+// the transaction to be mined.
+func (s *EVMIntegrationService) MintTokens(tokenAddress, to common.Address, amount uint64) (common.Hash, error) {
+	data, err := s.token.Pack("mint", to, new(big.Int).SetUint64(amount))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx, err := s.sendBackendTransaction(&tokenAddress, data, "mint")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := s.waitMined(tx.Hash(), evmConfirmationTimeout); err != nil {
+		return common.Hash{}, fmt.Errorf("mint failed: %w", err)
+	}
+	log.Printf("Minted %d tokens of %s to %s | TxID: %s", amount, tokenAddress, to, tx.Hash())
+	return tx.Hash(), nil
+}
+
+// SignMint builds and signs, but does not send, a mint of `amount` atomic
+// units to a verified holder. Its hash is known before it is sent with
+// SendSignedTransaction, so callers can record it first and never mint twice.
+func (s *EVMIntegrationService) SignMint(tokenAddress, to common.Address, amount uint64) (string, common.Hash, error) {
+	data, err := s.token.Pack("mint", to, new(big.Int).SetUint64(amount))
+	if err != nil {
+		return "", common.Hash{}, err
+	}
+	tx, err := s.signBackendTransaction(&tokenAddress, data, "mint")
+	if err != nil {
+		return "", common.Hash{}, err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", common.Hash{}, fmt.Errorf("failed to encode mint: %w", err)
+	}
+	return hexutil.Encode(raw), tx.Hash(), nil
+}
+
+// BurnTokens destroys `amount` atomic units held by `from` and waits for the
+// transaction to be mined.
+func (s *EVMIntegrationService) BurnTokens(tokenAddress, from common.Address, amount uint64) (common.Hash, error) {
+	data, err := s.token.Pack("burn", from, new(big.Int).SetUint64(amount))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx, err := s.sendBackendTransaction(&tokenAddress, data, "burn")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := s.waitMined(tx.Hash(), evmConfirmationTimeout); err != nil {
+		return common.Hash{}, fmt.Errorf("burn failed: %w", err)
+	}
+	log.Printf("Burned %d tokens of %s from %s | TxID: %s", amount, tokenAddress, from, tx.Hash())
+	return tx.Hash(), nil
+}
+
+// TokenTransferLog is a Transfer event emitted by a token contract.
+type TokenTransferLog struct {
+	TxHash      common.Hash
+	LogIndex    uint
+	BlockNumber uint64
+	From        common.Address
+	To          common.Address
+	Amount      uint64
+}
+
+// BlockNumber returns the number of the latest block.
+func (s *EVMIntegrationService) BlockNumber() (uint64, error) {
+	return s.Client.BlockNumber(context.Background())
+}
+
+// GetTransfersTo returns the token's Transfer events to `to` in the blocks
+// from `fromBlock` to `toBlock` inclusive, in chain order.
+func (s *EVMIntegrationService) GetTransfersTo(tokenAddress, to common.Address, fromBlock, toBlock uint64) ([]TokenTransferLog, error) {
+	logs, err := s.Client.FilterLogs(context.Background(), ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: []common.Address{tokenAddress},
+		Topics:    [][]common.Hash{{transferTopic}, nil, {common.BytesToHash(to.Bytes())}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer logs: %w", err)
+	}
+
+	transfers := make([]TokenTransferLog, 0, len(logs))
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) != 3 || len(l.Data) != 32 {
+			continue
+		}
+		amount := new(big.Int).SetBytes(l.Data)
+		if !amount.IsUint64() {
+			continue
+		}
+		transfers = append(transfers, TokenTransferLog{
+			TxHash:      l.TxHash,
+			LogIndex:    l.Index,
+			BlockNumber: l.BlockNumber,
+			From:        common.BytesToAddress(l.Topics[1].Bytes()),
+			To:          common.BytesToAddress(l.Topics[2].Bytes()),
+			Amount:      amount.Uint64(),
+		})
+	}
+	return transfers, nil
+}
+
+// GetTransactionConfirmation reports whether a transaction has been mined.
+// Like its Solana counterpart it wraps ErrTransactionFailed when the
+// transaction reverted.
+func (s *EVMIntegrationService) GetTransactionConfirmation(hash common.Hash) (bool, error) {
+	receipt, err := s.Client.TransactionReceipt(context.Background(), hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return false, fmt.Errorf("%w: transaction %s reverted", ErrTransactionFailed, hash)
+	}
+	return true, nil
+}
+
+// GetTokenBalance returns a holder's balance in atomic units.
+func (s *EVMIntegrationService) GetTokenBalance(tokenAddress, holder common.Address) (uint64, error) {
+	var balance *big.Int
+	if err := s.call(tokenAddress, &balance, "balanceOf", holder); err != nil {
+		return 0, fmt.Errorf("failed to read balance: %w", err)
+	}
+	if !balance.IsUint64() {
+		return 0, fmt.Errorf("balance of %s exceeds 64 bits", holder)
+	}
+	return balance.Uint64(), nil
+}
+
+// PrepareTransferTransaction builds an unsigned EIP-1559 transfer for the
+// sender's wallet to sign. It is returned RLP-encoded in hex; the sender
+// signs it and submits the signed raw transaction.
+func (s *EVMIntegrationService) PrepareTransferTransaction(tokenAddress, from, to common.Address, amount uint64) (string, error) {
+	ctx := context.Background()
+	data, err := s.token.Pack("transfer", to, new(big.Int).SetUint64(amount))
+	if err != nil {
+		return "", err
+	}
+	nonce, err := s.Client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nonce: %w", err)
+	}
+	gas, err := s.Client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &tokenAddress, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("transfer would fail: %w", err)
+	}
+	tip, feeCap, err := s.fees(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   s.ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &tokenAddress,
+		Data:      data,
+	})
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	return hexutil.Encode(raw), nil
+}
+
+// MatchPreparedTransaction checks that a signed raw transaction is the
+// prepared unsigned one, signed: both must hash to the same signing hash.
+func (s *EVMIntegrationService) MatchPreparedTransaction(preparedHex, signedHex string) error {
+	signer := types.LatestSignerForChainID(s.ChainID)
+	decode := func(rawHex string) (*types.Transaction, error) {
+		raw, err := hexutil.Decode(rawHex)
+		if err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		return tx, tx.UnmarshalBinary(raw)
+	}
+	prepared, err := decode(preparedHex)
+	if err != nil {
+		return fmt.Errorf("failed to decode prepared transaction: %w", err)
+	}
+	signed, err := decode(signedHex)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTransactionMismatch, err)
+	}
+	if signer.Hash(prepared) != signer.Hash(signed) {
+		return ErrTransactionMismatch
+	}
+	return nil
+}
+
+// SendSignedTransaction submits a raw transaction signed by a holder's
+// wallet, after checking that `signer` signed it. Sending a transaction the
+// node already knows succeeds, so it can be used to rebroadcast one.
+func (s *EVMIntegrationService) SendSignedTransaction(rawHex string, signer common.Address) (common.Hash, error) {
+	raw, err := hexutil.Decode(rawHex)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(s.ChainID), tx)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid transaction signature: %w", err)
+	}
+	if from != signer {
+		return common.Hash{}, fmt.Errorf("%w: signed by %s, expected %s", ErrSignerMismatch, from, signer)
+	}
+	if err := s.Client.SendTransaction(context.Background(), tx); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "already known"):
+		case strings.Contains(err.Error(), "nonce too low"):
+			return common.Hash{}, fmt.Errorf("%w: %v", ErrNonceUsed, err)
+		default:
+			return common.Hash{}, fmt.Errorf("failed to send transaction: %w", err)
+		}
+	}
+	if s.commit != nil {
+		s.commit()
+	}
+	return tx.Hash(), nil
+}
+
+// WaitForConfirmation waits for a transaction to be mined. Like its Solana
+// counterpart it returns false when the timeout elapses and wraps
+// ErrTransactionFailed when the transaction reverted.
+func (s *EVMIntegrationService) WaitForConfirmation(hash common.Hash, timeout time.Duration) (bool, error) {
+	_, err := s.waitMined(hash, timeout)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// call runs a read-only contract call and unpacks its single result.
+func (s *EVMIntegrationService) call(tokenAddress common.Address, result any, method string, args ...any) error {
+	data, err := s.token.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	out, err := s.Client.CallContract(context.Background(), ethereum.CallMsg{To: &tokenAddress, Data: data}, nil)
+	if err != nil {
+		return fmt.Errorf("%s call failed: %w", method, err)
+	}
+	return s.token.UnpackIntoInterface(result, method, out)
+}
+
+// fees returns the priority fee and a fee cap leaving room for the base fee
+// to double.
+func (s *EVMIntegrationService) fees(ctx context.Context) (*big.Int, *big.Int, error) {
+	tip, err := s.Client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest gas tip: %w", err)
+	}
+	head, err := s.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read latest block: %w", err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	return tip, feeCap, nil
+}
+
+// sendBackendTransaction signs a transaction with the agent's key and sends
+// it. A nil `to` deploys a contract.
+func (s *EVMIntegrationService) sendBackendTransaction(to *common.Address, data []byte, label string) (*types.Transaction, error) {
+	tx, err := s.signBackendTransaction(to, data, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Client.SendTransaction(context.Background(), tx); err != nil {
+		return nil, fmt.Errorf("failed to send %s transaction: %w", label, err)
+	}
+	if s.commit != nil {
+		s.commit()
+	}
+	return tx, nil
+}
+
+// signBackendTransaction builds a transaction from the agent at its next
+// nonce and signs it with the agent's key, without sending it.
+func (s *EVMIntegrationService) signBackendTransaction(to *common.Address, data []byte, label string) (*types.Transaction, error) {
+	ctx := context.Background()
+	from := s.Address()
+
+	nonce, err := s.Client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nonce: %w", err)
+	}
+	gas, err := s.Client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("%s would fail: %w", label, err)
+	}
+	tip, feeCap, err := s.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := types.SignNewTx(s.Key, types.LatestSignerForChainID(s.ChainID), &types.DynamicFeeTx{
+		ChainID:   s.ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        to,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s transaction: %w", label, err)
+	}
+	return tx, nil
+}
+
+// waitMined polls for a transaction's receipt until it is mined or the
+// timeout elapses.
+func (s *EVMIntegrationService) waitMined(hash common.Hash, timeout time.Duration) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		receipt, err := s.Client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return receipt, fmt.Errorf("%w: transaction %s reverted", ErrTransactionFailed, hash)
+			}
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// holderGas is the gas limit of holder transactions in tests. It is fixed so
+// transactions that revert are mined instead of failing gas estimation.
+const holderGas = 200_000
+
+// newTestEVM starts a simulated chain funding the agent and the given holders
+// and returns the integration service acting as agent.
+func newTestEVM(t *testing.T, holders ...*ecdsa.PrivateKey) *EVMIntegrationService {
+	t.Helper()
+	agent, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funds, _ := new(big.Int).SetString("1000000000000000000000", 10) // 1000 ether
+	alloc := types.GenesisAlloc{crypto.PubkeyToAddress(agent.PublicKey): {Balance: funds}}
+	for _, h := range holders {
+		alloc[crypto.PubkeyToAddress(h.PublicKey)] = types.Account{Balance: funds}
+	}
+	backend := simulated.NewBackend(alloc)
+	t.Cleanup(func() { backend.Close() })
+
+	s := &EVMIntegrationService{Client: backend.Client(), Key: agent, commit: func() { backend.Commit() }}
+	if s.ChainID, err = s.Client.ChainID(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.token, err = abi.JSON(strings.NewReader(permissionedTokenABI)); err != nil {
+		t.Fatal(err)
+	}
+	if s.initBin, err = assemblePermissionedToken(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newHolder(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func addressOf(key *ecdsa.PrivateKey) common.Address {
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// signAs signs a call to a token function from `key`'s wallet at its next
+// nonce, without sending it.
+func signAs(t *testing.T, s *EVMIntegrationService, key *ecdsa.PrivateKey, token common.Address, method string, args ...any) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	data, err := s.token.Pack(method, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := s.Client.PendingNonceAt(ctx, addressOf(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip, feeCap, err := s.fees(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(s.ChainID), &types.DynamicFeeTx{
+		ChainID: s.ChainID, Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap, Gas: holderGas, To: &token, Data: data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// sendAs calls a token function from `key`'s wallet, mines it and reports
+// whether it succeeded.
+func sendAs(t *testing.T, s *EVMIntegrationService, key *ecdsa.PrivateKey, token common.Address, method string, args ...any) bool {
+	t.Helper()
+	tx := signAs(t, s, key, token, method, args...)
+	if err := s.Client.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	s.commit()
+	confirmed, err := s.GetTransactionConfirmation(tx.Hash())
+	if err != nil && !errors.Is(err, ErrTransactionFailed) {
+		t.Fatal(err)
+	}
+	return confirmed
+}
+
+func balanceOf(t *testing.T, s *EVMIntegrationService, token, holder common.Address) uint64 {
+	t.Helper()
+	balance, err := s.GetTokenBalance(token, holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func totalSupply(t *testing.T, s *EVMIntegrationService, token common.Address) uint64 {
+	t.Helper()
+	var supply *big.Int
+	if err := s.call(token, &supply, "totalSupply"); err != nil {
+		t.Fatal(err)
+	}
+	return supply.Uint64()
+}
+
+func allowanceOf(t *testing.T, s *EVMIntegrationService, token, owner, spender common.Address) *big.Int {
+	t.Helper()
+	var allowance *big.Int
+	if err := s.call(token, &allowance, "allowance", owner, spender); err != nil {
+		t.Fatal(err)
+	}
+	return allowance
+}
+
+// deployVerified deploys a token and verifies the given holders.
+func deployVerified(t *testing.T, s *EVMIntegrationService, holders ...*ecdsa.PrivateKey) common.Address {
+	t.Helper()
+	token, err := s.DeployToken("Edifício Aurora", "AUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range holders {
+		if err := s.SetVerified(token, addressOf(h), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return token
+}
+
+func TestShortString(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"AUR", "AUR"},
+		{"", ""},
+		{strings.Repeat("a", 31), strings.Repeat("a", 31)},
+		{strings.Repeat("a", 32), strings.Repeat("a", 31)},
+		{strings.Repeat("a", 30) + "ção", strings.Repeat("a", 30)}, // Cut before a split rune
+	}
+	for _, tt := range tests {
+		word := shortString(tt.value)
+		if got := string(word[:word[31]]); got != tt.want {
+			t.Errorf("shortString(%q) holds %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPermissionedTokenDeploy(t *testing.T) {
+	s := newTestEVM(t)
+	token, err := s.DeployToken("Edifício Aurora", "AUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var name, symbol string
+	var decimals uint8
+	var owner common.Address
+	for method, result := range map[string]any{"name": &name, "symbol": &symbol, "decimals": &decimals, "owner": &owner} {
+		if err := s.call(token, result, method); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name != "Edifício Aurora" || symbol != "AUR" || decimals != 9 {
+		t.Errorf("name, symbol, decimals = %q, %q, %d", name, symbol, decimals)
+	}
+	if owner != s.Address() {
+		t.Errorf("owner = %s, want the agent %s", owner, s.Address())
+	}
+	if supply := totalSupply(t, s, token); supply != 0 {
+		t.Errorf("totalSupply = %d, want 0", supply)
+	}
+}
+
+func TestPermissionedTokenTransfer(t *testing.T) {
+	alice, bob, carol := newHolder(t), newHolder(t), newHolder(t)
+	s := newTestEVM(t, alice, bob, carol)
+	token := deployVerified(t, s, alice, bob)
+	if _, err := s.MintTokens(token, addressOf(alice), 1000); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := s.BlockNumber()
+
+	// The prepared transfer flow: the backend prepares, the holder signs
+	prepared, err := s.PrepareTransferTransaction(token, addressOf(alice), addressOf(bob), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := signPrepared(t, s, prepared, alice)
+	if err := s.MatchPreparedTransaction(prepared, signed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendSignedTransaction(signed, addressOf(bob)); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("SendSignedTransaction() with the wrong signer error = %v, want ErrSignerMismatch", err)
+	}
+	hash, err := s.SendSignedTransaction(signed, addressOf(alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed, err := s.WaitForConfirmation(hash, evmConfirmationTimeout); err != nil || !confirmed {
+		t.Fatalf("WaitForConfirmation() = %v, %v", confirmed, err)
+	}
+	if a, b := balanceOf(t, s, token, addressOf(alice)), balanceOf(t, s, token, addressOf(bob)); a != 700 || b != 300 {
+		t.Fatalf("balances after transfer = %d, %d, want 700, 300", a, b)
+	}
+
+	end, _ := s.BlockNumber()
+	logs, err := s.GetTransfersTo(token, addressOf(bob), start+1, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].From != addressOf(alice) || logs[0].Amount != 300 || logs[0].TxHash != hash {
+		t.Fatalf("Transfer events to bob = %+v", logs)
+	}
+
+	tests := []struct {
+		name   string
+		to     common.Address
+		amount uint64
+	}{
+		{"to an unverified holder", addressOf(carol), 1},
+		{"more than the balance", addressOf(bob), 701},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sendAs(t, s, alice, token, "transfer", tt.to, new(big.Int).SetUint64(tt.amount)) {
+				t.Fatal("transfer succeeded, want a revert")
+			}
+			if a := balanceOf(t, s, token, addressOf(alice)); a != 700 {
+				t.Fatalf("alice's balance = %d after a reverted transfer", a)
+			}
+		})
+	}
+}
+
+func signPrepared(t *testing.T, s *EVMIntegrationService, preparedHex string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	raw := common.FromHex(preparedHex)
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(s.ChainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := signed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "0x" + common.Bytes2Hex(out)
+}
+
+func TestPermissionedTokenAllowance(t *testing.T) {
+	alice, bob := newHolder(t), newHolder(t)
+	s := newTestEVM(t, alice, bob)
+	token := deployVerified(t, s, alice, bob)
+	if _, err := s.MintTokens(token, addressOf(alice), 1000); err != nil {
+		t.Fatal(err)
+	}
+	amount := func(v uint64) *big.Int { return new(big.Int).SetUint64(v) }
+
+	if !sendAs(t, s, alice, token, "approve", addressOf(bob), amount(200)) {
+		t.Fatal("approve reverted")
+	}
+	if got := allowanceOf(t, s, token, addressOf(alice), addressOf(bob)); got.Uint64() != 200 {
+		t.Fatalf("allowance = %s, want 200", got)
+	}
+	if !sendAs(t, s, bob, token, "transferFrom", addressOf(alice), addressOf(bob), amount(150)) {
+		t.Fatal("transferFrom within the allowance reverted")
+	}
+	if got := allowanceOf(t, s, token, addressOf(alice), addressOf(bob)); got.Uint64() != 50 {
+		t.Fatalf("allowance after spending 150 = %s, want 50", got)
+	}
+	if sendAs(t, s, bob, token, "transferFrom", addressOf(alice), addressOf(bob), amount(51)) {
+		t.Fatal("transferFrom over the allowance succeeded")
+	}
+	if a, b := balanceOf(t, s, token, addressOf(alice)), balanceOf(t, s, token, addressOf(bob)); a != 850 || b != 150 {
+		t.Fatalf("balances = %d, %d, want 850, 150", a, b)
+	}
+	if sendAs(t, s, alice, token, "transferFrom", addressOf(alice), addressOf(bob), amount(1)) {
+		t.Fatal("transferFrom without an allowance succeeded")
+	}
+
+	// An unlimited allowance is never decreased
+	unlimited := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	if !sendAs(t, s, alice, token, "approve", addressOf(bob), unlimited) {
+		t.Fatal("approve reverted")
+	}
+	if !sendAs(t, s, bob, token, "transferFrom", addressOf(alice), addressOf(bob), amount(100)) {
+		t.Fatal("transferFrom with an unlimited allowance reverted")
+	}
+	if got := allowanceOf(t, s, token, addressOf(alice), addressOf(bob)); got.Cmp(unlimited) != 0 {
+		t.Fatalf("unlimited allowance decreased to %s", got)
+	}
+}
+
+func TestPermissionedTokenAgentOperations(t *testing.T) {
+	alice, bob, carol := newHolder(t), newHolder(t), newHolder(t)
+	s := newTestEVM(t, alice, bob, carol)
+	token := deployVerified(t, s, alice, bob)
+
+	if _, err := s.MintTokens(token, addressOf(carol), 100); err == nil {
+		t.Fatal("minted to an unverified holder")
+	}
+	if _, err := s.MintTokens(token, addressOf(alice), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if supply := totalSupply(t, s, token); supply != 1000 {
+		t.Fatalf("totalSupply = %d, want 1000", supply)
+	}
+
+	if _, err := s.BurnTokens(token, addressOf(alice), 1001); err == nil {
+		t.Fatal("burned more than the holder's balance")
+	}
+	if _, err := s.BurnTokens(token, addressOf(alice), 400); err != nil {
+		t.Fatal(err)
+	}
+	if a, supply := balanceOf(t, s, token, addressOf(alice)), totalSupply(t, s, token); a != 600 || supply != 600 {
+		t.Fatalf("balance, totalSupply after burning 400 = %d, %d, want 600, 600", a, supply)
+	}
+
+	forced, err := s.token.Pack("forcedTransfer", addressOf(alice), addressOf(bob), big.NewInt(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := s.sendBackendTransaction(&token, forced, "forced transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.waitMined(tx.Hash(), evmConfirmationTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := balanceOf(t, s, token, addressOf(alice)), balanceOf(t, s, token, addressOf(bob)); a != 350 || b != 250 {
+		t.Fatalf("balances after forced transfer = %d, %d, want 350, 250", a, b)
+	}
+	if sendAs(t, s, alice, token, "forcedTransfer", addressOf(bob), addressOf(alice), big.NewInt(250)) {
+		t.Fatal("a holder forced a transfer")
+	}
+	if sendAs(t, s, alice, token, "mint", addressOf(alice), big.NewInt(1)) {
+		t.Fatal("a holder minted")
+	}
+	if sendAs(t, s, alice, token, "setVerified", addressOf(carol), true) {
+		t.Fatal("a holder verified another holder")
+	}
+
+	// Unverifying a holder stops transfers to them
+	if err := s.SetVerified(token, addressOf(bob), false); err != nil {
+		t.Fatal(err)
+	}
+	if sendAs(t, s, alice, token, "transfer", addressOf(bob), big.NewInt(1)) {
+		t.Fatal("transferred to an unverified holder")
+	}
+
+	// Mints that would overflow the total supply revert
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	if sendAs(t, s, s.Key, token, "mint", addressOf(alice), maxUint256) {
+		t.Fatal("mint overflowing the total supply succeeded")
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+// kycValidity is how long a verification lasts when the provider does not
+// set an expiry; after it the user must re-verify.
+const kycValidity = 365 * 24 * time.Hour
+
+// webhookTolerance is how far a callback's timestamp may be from the current
+// time. Older callbacks are refused as replays; newer ones also reach the
+// delivery log, which refuses a delivery ID seen before.
+const webhookTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidWebhookSignature is returned when a provider callback fails authentication.
+	ErrInvalidWebhookSignature = fmt.Errorf("%w: invalid webhook signature", ErrUnauthorized)
+	// ErrKYCVerificationNotFound is returned when a callback refers to an unknown verification.
+	ErrKYCVerificationNotFound = fmt.Errorf("KYC verification %w", ErrNotFound)
+	// ErrKYCVerificationNotPending is returned when a callback refers to a verification that already has an outcome.
+	ErrKYCVerificationNotPending = fmt.Errorf("%w: KYC verification already has an outcome", ErrConflict)
+	// ErrStaleWebhook is returned when a callback's timestamp is outside webhookTolerance.
+	ErrStaleWebhook = fmt.Errorf("%w: webhook timestamp is too old or in the future", ErrUnauthorized)
+	// ErrDuplicateWebhook is returned when a callback's delivery ID was applied before.
+	ErrDuplicateWebhook = fmt.Errorf("%w: webhook delivery was already received", ErrConflict)
+)
+
+// KYCSession is what a provider returns when a verification is started.
+type KYCSession struct {
+	Reference   string
+	RedirectURL string // Empty when the provider needs no user interaction
+}
+
+// KYCEvent is a verification outcome reported by a provider. DeliveryID and
+// Timestamp must be covered by the provider's signature so a captured
+// callback cannot be replayed.
+type KYCEvent struct {
+	DeliveryID string     `json:"delivery_id"` // Unique per callback; retries of one callback may reuse it
+	Timestamp  time.Time  `json:"timestamp"`   // When the provider sent the callback
+	Reference  string     `json:"reference"`
+	Status     string     `json:"status"` // verified or rejected
+	Level      int        `json:"level"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+// KYCProvider is an identity verification provider.
+type KYCProvider interface {
+	// Name identifies the provider in stored verifications and webhook URLs.
+	Name() string
+	// StartVerification opens a verification of the user at the given level.
+	StartVerification(user models.User, level int) (KYCSession, error)
+	// ParseWebhook authenticates a callback and decodes its event.
+	ParseWebhook(body []byte, signature string) (KYCEvent, error)
+}
+
+// StubKYCProvider is a local provider for development. It accepts every
+// verification request and expects callbacks signed with HMAC-SHA256 (hex)
+// of the body using WebhookSecret; the body carries the event's delivery_id
+// and timestamp, so both are signed.
+type StubKYCProvider struct {
+	WebhookSecret string
+}
+
+func (p *StubKYCProvider) Name() string { return "stub" }
+
+func (p *StubKYCProvider) StartVerification(user models.User, level int) (KYCSession, error) {
+	return KYCSession{Reference: "stub-" + uuid.New().String()}, nil
+}
+
+func (p *StubKYCProvider) ParseWebhook(body []byte, signature string) (KYCEvent, error) {
+	if p.WebhookSecret == "" {
+		return KYCEvent{}, ErrInvalidWebhookSignature
+	}
+	mac := hmac.New(sha256.New, []byte(p.WebhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return KYCEvent{}, ErrInvalidWebhookSignature
+	}
+
+	var event KYCEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return KYCEvent{}, invalidf("invalid webhook payload: %v", err)
+	}
+	return event, nil
+}
+
+// KYCService tracks the identity verification of users through a provider.
+type KYCService struct {
+	DB       *storage.DB
+	Provider KYCProvider
+}
+
+func NewKYCService(db *storage.DB, provider KYCProvider) *KYCService {
+	return &KYCService{DB: db, Provider: provider}
+}
+
+// StartVerification opens a verification of a user with the provider.
+func (s *KYCService) StartVerification(userID string, level int) (models.KYCVerification, error) {
+	if level < 1 || level > 2 {
+		return models.KYCVerification{}, invalidf("level must be 1 (identity) or 2 (enhanced)")
+	}
+	user, found, err := s.DB.GetUser(userID)
+	if err != nil {
+		return models.KYCVerification{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found {
+		return models.KYCVerification{}, ErrUserNotFound
+	}
+
+	session, err := s.Provider.StartVerification(user, level)
+	if err != nil {
+		return models.KYCVerification{}, fmt.Errorf("failed to start verification with %s: %w", s.Provider.Name(), err)
+	}
+
+	verification := models.KYCVerification{
+		ID:                uuid.New().String(),
+		UserID:            user.ID,
+		Provider:          s.Provider.Name(),
+		ProviderReference: session.Reference,
+		Level:             level,
+		Status:            models.KYCStatusPending,
+		RequestedAt:       time.Now(),
+	}
+	if session.RedirectURL != "" {
+		verification.RedirectURL = &session.RedirectURL
+	}
+	if err := s.DB.StartKYCVerification(verification); err != nil {
+		return models.KYCVerification{}, fmt.Errorf("failed to save verification: %w", err)
+	}
+	return verification, nil
+}
+
+// HandleWebhook applies a provider callback to the matching verification.
+// Only a pending verification takes an outcome, and each delivery is applied
+// once.
+func (s *KYCService) HandleWebhook(provider string, body []byte, signature string) (models.KYCVerification, error) {
+	if provider != s.Provider.Name() {
+		return models.KYCVerification{}, fmt.Errorf("KYC provider %s %w", provider, ErrNotFound)
+	}
+	event, err := s.Provider.ParseWebhook(body, signature)
+	if err != nil {
+		return models.KYCVerification{}, err
+	}
+	if err := checkWebhookEvent(event, time.Now()); err != nil {
+		return models.KYCVerification{}, err
+	}
+
+	verification, found, err := s.DB.GetKYCVerificationByReference(provider, event.Reference)
+	if err != nil {
+		return models.KYCVerification{}, fmt.Errorf("error fetching verification: %w", err)
+	}
+	if !found {
+		return models.KYCVerification{}, ErrKYCVerificationNotFound
+	}
+
+	verification, err = applyKYCEvent(verification, event, time.Now())
+	if err != nil {
+		return models.KYCVerification{}, err
+	}
+
+	completed, err := s.DB.CompleteKYCVerification(verification, event.DeliveryID)
+	if storage.IsUniqueViolation(err) {
+		return models.KYCVerification{}, ErrDuplicateWebhook
+	}
+	if err != nil {
+		return models.KYCVerification{}, fmt.Errorf("failed to record verification outcome: %w", err)
+	}
+	if !completed {
+		return models.KYCVerification{}, ErrKYCVerificationNotPending
+	}
+	return verification, nil
+}
+
+// checkWebhookEvent validates a decoded callback and refuses one sent outside
+// webhookTolerance of now.
+func checkWebhookEvent(event KYCEvent, now time.Time) error {
+	if event.DeliveryID == "" || event.Timestamp.IsZero() {
+		return invalidf("delivery_id and timestamp are required")
+	}
+	if event.Status != models.KYCStatusVerified && event.Status != models.KYCStatusRejected {
+		return invalidf("unsupported status %q", event.Status)
+	}
+	if age := now.Sub(event.Timestamp); age > webhookTolerance || age < -webhookTolerance {
+		return ErrStaleWebhook
+	}
+	return nil
+}
+
+// applyKYCEvent sets the outcome of a pending verification from a callback.
+func applyKYCEvent(verification models.KYCVerification, event KYCEvent, now time.Time) (models.KYCVerification, error) {
+	if verification.Status != models.KYCStatusPending {
+		return models.KYCVerification{}, ErrKYCVerificationNotPending
+	}
+
+	verification.Status = event.Status
+	verification.CompletedAt = &now
+	if event.Status == models.KYCStatusVerified {
+		if event.Level > 0 {
+			verification.Level = event.Level
+		}
+		expiresAt := now.Add(kycValidity)
+		if event.ExpiresAt != nil {
+			expiresAt = *event.ExpiresAt
+		}
+		verification.ExpiresAt = &expiresAt
+	} else {
+		verification.Level = 0
+		if event.Reason != "" {
+			verification.FailureReason = &event.Reason
+		}
+	}
+	return verification, nil
+}
+
+// CreateKYCDocumentInput references a document supporting a verification.
+type CreateKYCDocumentInput struct {
+	UserID         string  `json:"-"`
+	VerificationID *string `json:"verification_id,omitempty"`
+	DocumentType   string  `json:"document_type"`
+	Reference      string  `json:"reference"`
+	SHA256         *string `json:"sha256,omitempty"`
+}
+
+// AddDocument stores a document reference for a user.
+func (s *KYCService) AddDocument(in CreateKYCDocumentInput) (models.KYCDocument, error) {
+	if in.DocumentType == "" || in.Reference == "" {
+		return models.KYCDocument{}, invalidf("document_type and reference are required")
+	}
+	if in.SHA256 != nil {
+		if b, err := hex.DecodeString(*in.SHA256); err != nil || len(b) != sha256.Size {
+			return models.KYCDocument{}, invalidf("sha256 must be a hex-encoded SHA-256 digest")
+		}
+	}
+	if _, found, err := s.DB.GetUser(in.UserID); err != nil {
+		return models.KYCDocument{}, fmt.Errorf("error fetching user: %w", err)
+	} else if !found {
+		return models.KYCDocument{}, ErrUserNotFound
+	}
+
+	doc := models.KYCDocument{
+		ID:             uuid.New().String(),
+		UserID:         in.UserID,
+		VerificationID: in.VerificationID,
+		DocumentType:   in.DocumentType,
+		Reference:      in.Reference,
+		SHA256:         in.SHA256,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.DB.SaveKYCDocument(doc); err != nil {
+		return models.KYCDocument{}, fmt.Errorf("failed to save document: %w", err)
+	}
+	return doc, nil
+}
+
+// GetProfile returns the KYC state of a user, reporting a lapsed
+// verification as expired.
+func (s *KYCService) GetProfile(userID string) (models.KYCProfile, error) {
+	user, found, err := s.DB.GetUser(userID)
+	if err != nil {
+		return models.KYCProfile{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found {
+		return models.KYCProfile{}, ErrUserNotFound
+	}
+
+	profile := models.KYCProfile{
+		UserID:     user.ID,
+		Status:     user.KYCStatus,
+		Level:      user.KYCLevel,
+		VerifiedAt: user.KYCVerifiedAt,
+		ExpiresAt:  user.KYCExpiresAt,
+	}
+	if user.KYCStatus == models.KYCStatusVerified && !user.IsKYCVerified(0, time.Now()) {
+		profile.Status = models.KYCStatusExpired
+	}
+	if profile.Verifications, err = s.DB.GetKYCVerificationsByUserID(user.ID); err != nil {
+		return models.KYCProfile{}, fmt.Errorf("error fetching verifications: %w", err)
+	}
+	if profile.Documents, err = s.DB.GetKYCDocumentsByUserID(user.ID); err != nil {
+		return models.KYCProfile{}, fmt.Errorf("error fetching documents: %w", err)
+	}
+	return profile, nil
+}
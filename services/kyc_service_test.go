@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func signStub(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestStubKYCProviderParseWebhook(t *testing.T) {
+	p := &StubKYCProvider{WebhookSecret: "secret"}
+	body := []byte(`{"delivery_id":"d-1","timestamp":"2025-03-01T12:00:00Z","reference":"stub-1","status":"verified","level":1}`)
+
+	event, err := p.ParseWebhook(body, signStub("secret", body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.DeliveryID != "d-1" || !event.Timestamp.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ParseWebhook() = %+v, want delivery d-1 sent at 12:00", event)
+	}
+
+	// Moving the timestamp forward to replay the body breaks the signature
+	replayed := []byte(`{"delivery_id":"d-1","timestamp":"2025-03-08T12:00:00Z","reference":"stub-1","status":"verified","level":1}`)
+	if _, err := p.ParseWebhook(replayed, signStub("secret", body)); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("ParseWebhook(re-dated body) error = %v, want ErrInvalidWebhookSignature", err)
+	}
+	if _, err := (&StubKYCProvider{}).ParseWebhook(body, signStub("", body)); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("ParseWebhook() without a secret error = %v, want ErrInvalidWebhookSignature", err)
+	}
+}
+
+func TestCheckWebhookEvent(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := func(sentAt time.Time, status string) KYCEvent {
+		return KYCEvent{DeliveryID: "d-1", Timestamp: sentAt, Reference: "stub-1", Status: status}
+	}
+
+	tests := []struct {
+		name    string
+		event   KYCEvent
+		wantErr error
+		invalid bool // Fails validation rather than with wantErr
+	}{
+		{name: "fresh", event: event(now.Add(-time.Minute), models.KYCStatusVerified)},
+		{name: "at the tolerance", event: event(now.Add(-webhookTolerance), models.KYCStatusRejected)},
+		{name: "replayed after the tolerance", event: event(now.Add(-webhookTolerance-time.Second), models.KYCStatusVerified), wantErr: ErrStaleWebhook},
+		{name: "from the future", event: event(now.Add(webhookTolerance+time.Second), models.KYCStatusVerified), wantErr: ErrStaleWebhook},
+		{name: "unsupported status", event: event(now, models.KYCStatusPending), invalid: true},
+		{name: "missing delivery id", event: KYCEvent{Timestamp: now, Status: models.KYCStatusVerified}, invalid: true},
+		{name: "missing timestamp", event: KYCEvent{DeliveryID: "d-1", Status: models.KYCStatusVerified}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWebhookEvent(tt.event, now)
+			switch {
+			case !tt.invalid && tt.wantErr == nil && err != nil:
+				t.Fatalf("checkWebhookEvent() error = %v", err)
+			case tt.invalid:
+				var invalid *ValidationError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("checkWebhookEvent() error = %v, want a validation error", err)
+				}
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("checkWebhookEvent() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyKYCEvent(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-24 * time.Hour)
+	verification := func(status string) models.KYCVerification {
+		return models.KYCVerification{ID: "v-1", Level: 1, Status: status, ExpiresAt: &expired}
+	}
+	verified := KYCEvent{DeliveryID: "d-1", Timestamp: now, Status: models.KYCStatusVerified, Level: 2}
+
+	got, err := applyKYCEvent(verification(models.KYCStatusPending), verified, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.KYCStatusVerified || got.Level != 2 || !got.ExpiresAt.Equal(now.Add(kycValidity)) {
+		t.Fatalf("applyKYCEvent() = %+v, want verified at level 2 until %v", got, now.Add(kycValidity))
+	}
+
+	rejected := KYCEvent{DeliveryID: "d-2", Timestamp: now, Status: models.KYCStatusRejected, Reason: "document unreadable"}
+	got, err = applyKYCEvent(verification(models.KYCStatusPending), rejected, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.KYCStatusRejected || got.Level != 0 || got.FailureReason == nil || *got.FailureReason != rejected.Reason {
+		t.Fatalf("applyKYCEvent() = %+v, want rejected at level 0", got)
+	}
+
+	// A replayed "verified" callback must not re-verify a rejected or lapsed
+	// verification, nor push back the expiry of a verified one
+	for _, status := range []string{models.KYCStatusRejected, models.KYCStatusExpired, models.KYCStatusVerified} {
+		t.Run("replay on "+status, func(t *testing.T) {
+			if got, err := applyKYCEvent(verification(status), verified, now); !errors.Is(err, ErrKYCVerificationNotPending) {
+				t.Fatalf("applyKYCEvent() = %+v, %v, want ErrKYCVerificationNotPending", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMarketNotFound is returned when an asset has no market configured.
+	ErrMarketNotFound = fmt.Errorf("market %w", ErrNotFound)
+	// ErrMarketHalted is returned when placing an order on a halted market.
+	ErrMarketHalted = fmt.Errorf("%w: market is halted", ErrConflict)
+	// ErrOrderNotFound is returned when the requested order does not exist.
+	ErrOrderNotFound = fmt.Errorf("order %w", ErrNotFound)
+	// ErrOrderNotPending is returned when activating an order that is not waiting for approval.
+	ErrOrderNotPending = fmt.Errorf("%w: order is not waiting for approval", ErrConflict)
+	// ErrOrderNotLive is returned when cancelling a filled or cancelled order.
+	ErrOrderNotLive = fmt.Errorf("%w: order is already filled or cancelled", ErrConflict)
+	// ErrTradeNotFound is returned when the requested trade does not exist.
+	ErrTradeNotFound = fmt.Errorf("trade %w", ErrNotFound)
+)
+
+// MarketService runs the secondary market: a limit order book per asset with
+// price-time priority matching. Trades settle on chain as one swap transaction
+// moving the asset to the buyer and the quote token to the seller. To let the
+// backend send it, each order comes with a delegate approval, signed by its
+// owner, covering everything the owner's live orders on that side commit.
+type MarketService struct {
+	DB           *storage.DB
+	SolanaS      *SolanaIntegrationService
+	Tokenization *TokenizationService // Compliance, AML, vesting and tax checks
+	mu           sync.Mutex           // Serializes matching
+}
+
+func NewMarketService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *MarketService {
+	return &MarketService{DB: db, SolanaS: solanaS, Tokenization: tokenization}
+}
+
+// ConfigureMarketInput sets the quote token and status of an asset's market.
+type ConfigureMarketInput struct {
+	AssetID   string `json:"-"`
+	QuoteMint string `json:"quote_mint"`
+	Status    string `json:"status,omitempty"` // Defaults to open
+}
+
+// ConfigureMarket creates or updates the market of an asset.
+func (s *MarketService) ConfigureMarket(in ConfigureMarketInput) (models.Market, error) {
+	if in.Status == "" {
+		in.Status = models.MarketStatusOpen
+	}
+	if in.Status != models.MarketStatusOpen && in.Status != models.MarketStatusHalted {
+		return models.Market{}, invalidf("status must be %s or %s", models.MarketStatusOpen, models.MarketStatusHalted)
+	}
+	quoteMint, err := solana.PublicKeyFromBase58(in.QuoteMint)
+	if err != nil {
+		return models.Market{}, invalidf("invalid quote_mint: %v", err)
+	}
+	asset, found, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return models.Market{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found || asset.MintAddress == "" {
+		return models.Market{}, ErrAssetNotFound
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.Market{}, err
+	}
+	if asset.MintAddress == in.QuoteMint {
+		return models.Market{}, invalidf("quote_mint must differ from the asset mint")
+	}
+	decimals, err := s.SolanaS.GetMintDecimals(quoteMint)
+	if err != nil {
+		return models.Market{}, fmt.Errorf("failed to read quote mint: %w", err)
+	}
+
+	now := time.Now()
+	market, found, err := s.DB.GetMarket(in.AssetID)
+	if err != nil {
+		return models.Market{}, fmt.Errorf("error fetching market: %w", err)
+	}
+	if !found {
+		market = models.Market{AssetID: in.AssetID, CreatedAt: now}
+	}
+	market.QuoteMint = in.QuoteMint
+	market.QuoteDecimals = int(decimals)
+	market.Status = in.Status
+	market.UpdatedAt = now
+	if err := s.DB.SaveMarket(market); err != nil {
+		return models.Market{}, fmt.Errorf("failed to save market: %w", err)
+	}
+	return market, nil
+}
+
+// GetMarket returns the market of an asset.
+func (s *MarketService) GetMarket(assetID string) (models.Market, error) {
+	market, found, err := s.DB.GetMarket(assetID)
+	if err != nil {
+		return models.Market{}, fmt.Errorf("error fetching market: %w", err)
+	}
+	if !found {
+		return models.Market{}, ErrMarketNotFound
+	}
+	return market, nil
+}
+
+// PlaceOrderInput describes a new limit order.
+type PlaceOrderInput struct {
+	AssetID  string  `json:"-"`
+	UserID   string  `json:"user_id"`
+	Side     string  `json:"side"`
+	Price    float64 `json:"price"`    // Quote token units per asset unit
+	Quantity float64 `json:"quantity"` // Asset units
+}
+
+// PlacedOrder is a new order and the approval transaction its owner signs
+// to activate it.
+type PlacedOrder struct {
+	Order                 models.Order `json:"order"`
+	SerializedTransaction string       `json:"serialized_transaction"`
+}
+
+// PlaceOrder validates a limit order, checks that its owner can fund it and
+// stores it waiting for approval. The returned transaction approves the
+// FeePayer as delegate for the owner's live orders on that side plus this one;
+// it replaces any earlier approval of the same account.
+func (s *MarketService) PlaceOrder(in PlaceOrderInput) (PlacedOrder, error) {
+	if in.Side != models.OrderSideBuy && in.Side != models.OrderSideSell {
+		return PlacedOrder{}, invalidf("side must be %s or %s", models.OrderSideBuy, models.OrderSideSell)
+	}
+	if in.Price <= 0 || in.Quantity <= 0 {
+		return PlacedOrder{}, invalidf("price and quantity must be positive")
+	}
+	if toAtomic(in.Quantity, 9) == 0 {
+		return PlacedOrder{}, invalidf("quantity is below one atomic unit")
+	}
+
+	market, err := s.GetMarket(in.AssetID)
+	if err != nil {
+		return PlacedOrder{}, err
+	}
+	if market.Status != models.MarketStatusOpen {
+		return PlacedOrder{}, ErrMarketHalted
+	}
+	asset, _, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return PlacedOrder{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	user, found, err := s.DB.GetUser(in.UserID)
+	if err != nil {
+		return PlacedOrder{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found || user.SolanaPubKey == "" {
+		return PlacedOrder{}, ErrUserNotFound
+	}
+	owner, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return PlacedOrder{}, fmt.Errorf("invalid user public key: %w", err)
+	}
+
+	live, err := s.DB.GetLiveOrders(user.ID, asset.ID, in.Side)
+	if err != nil {
+		return PlacedOrder{}, fmt.Errorf("error fetching live orders: %w", err)
+	}
+
+	var approveMint solana.PublicKey
+	var approveAtomic uint64
+	now := time.Now()
+	if in.Side == models.OrderSideSell {
+		approveMint = solana.MustPublicKeyFromBase58(asset.MintAddress)
+		committed := in.Quantity
+		for _, o := range live {
+			committed += o.RemainingQuantity
+		}
+		approveAtomic = toAtomic(committed, 9)
+
+		balance, err := s.SolanaS.GetOwnerTokenBalance(owner, approveMint)
+		if err != nil {
+			return PlacedOrder{}, err
+		}
+		if balance < approveAtomic {
+			return PlacedOrder{}, invalidf("insufficient balance: live sell orders would commit %d of %d atomic units", approveAtomic, balance)
+		}
+		if err := s.Tokenization.Vesting.CheckTransferable(user.ID, asset.ID, fromAtomic(balance, 9), committed, now); err != nil {
+			return PlacedOrder{}, err
+		}
+	} else {
+		// A buyer must be an eligible holder of the asset
+		if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, To: user, Amount: in.Quantity, Timestamp: now}); err != nil {
+			return PlacedOrder{}, err
+		}
+		approveMint = solana.MustPublicKeyFromBase58(market.QuoteMint)
+		approveAtomic = quoteAtomic(in.Quantity, in.Price, market.QuoteDecimals)
+		for _, o := range live {
+			approveAtomic += quoteAtomic(o.RemainingQuantity, o.Price, market.QuoteDecimals)
+		}
+
+		balance, err := s.SolanaS.GetOwnerTokenBalance(owner, approveMint)
+		if err != nil {
+			return PlacedOrder{}, err
+		}
+		if balance < approveAtomic {
+			return PlacedOrder{}, invalidf("insufficient quote balance: live buy orders would commit %d of %d atomic units", approveAtomic, balance)
+		}
+	}
+
+	id := uuid.New().String()
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceOrder, ID: id})
+	serializedTx, err := solanaS.PrepareApproveDelegateTransaction(approveMint, owner, approveAtomic)
+	if err != nil {
+		return PlacedOrder{}, fmt.Errorf("failed to prepare approval: %w", err)
+	}
+
+	order := models.Order{
+		ID:                  id,
+		AssetID:             asset.ID,
+		UserID:              user.ID,
+		Side:                in.Side,
+		Price:               in.Price,
+		Quantity:            in.Quantity,
+		RemainingQuantity:   in.Quantity,
+		Status:              models.OrderStatusPendingApproval,
+		ApprovalTransaction: &serializedTx,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if err := s.DB.SaveOrder(order); err != nil {
+		return PlacedOrder{}, fmt.Errorf("failed to save order: %w", err)
+	}
+	return PlacedOrder{Order: order, SerializedTransaction: serializedTx}, nil
+}
+
+// ActivateOrder sends the approval prepared for the order once its owner
+// signed it, opens the order once it is confirmed and matches it against
+// the book. Any other transaction is refused.
+func (s *MarketService) ActivateOrder(orderID, signedTxBase64 string) (models.Order, error) {
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if order.Status != models.OrderStatusPendingApproval {
+		return models.Order{}, ErrOrderNotPending
+	}
+	if order.ApprovalTransaction == nil {
+		return models.Order{}, fmt.Errorf("%w: order %s has no prepared approval; place it again", ErrConflict, order.ID)
+	}
+	owner, found, err := s.DB.GetUser(order.UserID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found || owner.SolanaPubKey == "" {
+		return models.Order{}, ErrUserNotFound
+	}
+
+	signedTx, err := s.SolanaS.MergeSignature(*order.ApprovalTransaction, signedTxBase64, solana.MustPublicKeyFromBase58(owner.SolanaPubKey))
+	if err != nil {
+		if errors.Is(err, ErrTransactionMismatch) {
+			return models.Order{}, invalidf("%v", err)
+		}
+		return models.Order{}, err
+	}
+	sig, err := s.SolanaS.SendSignedTransaction(signedTx)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to send approval: %w", err)
+	}
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("approval failed: %w", err)
+	}
+	if !confirmed {
+		return models.Order{}, fmt.Errorf("approval %s was not confirmed in time", sig)
+	}
+
+	activated, err := s.DB.ActivateOrder(order.ID, sig.String())
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to activate order: %w", err)
+	}
+	if !activated {
+		return models.Order{}, ErrOrderNotPending
+	}
+
+	if err := s.match(order.ID); err != nil {
+		log.Printf("Order %s: matching failed: %v", order.ID, err)
+	}
+	return s.GetOrder(order.ID)
+}
+
+// CancelOrder takes a live order off the book. Its approval is left in place
+// and is replaced by the owner's next order on that side.
+func (s *MarketService) CancelOrder(orderID string) (models.Order, error) {
+	if _, err := s.GetOrder(orderID); err != nil {
+		return models.Order{}, err
+	}
+	cancelled, err := s.DB.CancelOrder(orderID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to cancel order: %w", err)
+	}
+	if !cancelled {
+		return models.Order{}, ErrOrderNotLive
+	}
+	return s.GetOrder(orderID)
+}
+
+// GetOrder returns an order.
+func (s *MarketService) GetOrder(id string) (models.Order, error) {
+	order, found, err := s.DB.GetOrder(id)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("error fetching order: %w", err)
+	}
+	if !found {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// GetOrderBook returns the aggregated book of an asset.
+func (s *MarketService) GetOrderBook(assetID string) (models.OrderBook, error) {
+	if _, err := s.GetMarket(assetID); err != nil {
+		return models.OrderBook{}, err
+	}
+	book, err := s.DB.GetOrderBook(assetID)
+	if err != nil {
+		return models.OrderBook{}, fmt.Errorf("error fetching order book: %w", err)
+	}
+	return book, nil
+}
+
+// match fills an incoming order against the resting orders of the opposite
+// side in price-time priority, at the resting order's price. Counterparties
+// failing compliance or AML for this movement, and the owner's own orders,
+// are skipped. Each fill is settled in the background.
+func (s *MarketService) match(orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != models.OrderStatusOpen && order.Status != models.OrderStatusPartiallyFilled {
+		return nil
+	}
+	market, err := s.GetMarket(order.AssetID)
+	if err != nil {
+		return err
+	}
+	if market.Status != models.MarketStatusOpen {
+		return nil
+	}
+	asset, _, err := s.DB.GetAsset(order.AssetID)
+	if err != nil {
+		return fmt.Errorf("error fetching asset: %w", err)
+	}
+
+	opposite := models.OrderSideSell
+	if order.Side == models.OrderSideSell {
+		opposite = models.OrderSideBuy
+	}
+	candidates, err := s.DB.GetMatchableOrders(order.AssetID, opposite, order.Price)
+	if err != nil {
+		return fmt.Errorf("error fetching resting orders: %w", err)
+	}
+
+	owner, _, err := s.DB.GetUser(order.UserID)
+	if err != nil {
+		return fmt.Errorf("error fetching user: %w", err)
+	}
+	remaining := toAtomic(order.RemainingQuantity, 9)
+	for _, resting := range candidates {
+		if remaining == 0 {
+			break
+		}
+		if resting.UserID == order.UserID {
+			continue // No self-trades
+		}
+		counterparty, found, err := s.DB.GetUser(resting.UserID)
+		if err != nil || !found {
+			log.Printf("Order %s: failed to load counterparty %s: %v", order.ID, resting.UserID, err)
+			continue
+		}
+
+		buyOrder, sellOrder, buyer, seller := order, resting, owner, counterparty
+		if order.Side == models.OrderSideSell {
+			buyOrder, sellOrder, buyer, seller = resting, order, counterparty, owner
+		}
+
+		fill := min(remaining, toAtomic(resting.RemainingQuantity, 9))
+		quantity := fromAtomic(fill, 9)
+		now := time.Now()
+		if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, From: &seller, To: buyer, Amount: quantity, Timestamp: now}); err != nil {
+			log.Printf("Order %s: skipping order %s: %v", order.ID, resting.ID, err)
+			continue
+		}
+		if err := s.Tokenization.AML.ScreenMovement("trade", asset.ID, seller, buyer); err != nil {
+			log.Printf("Order %s: skipping order %s: %v", order.ID, resting.ID, err)
+			continue
+		}
+
+		trade := models.Trade{
+			ID:          uuid.New().String(),
+			AssetID:     asset.ID,
+			BuyOrderID:  buyOrder.ID,
+			SellOrderID: sellOrder.ID,
+			BuyerID:     buyer.ID,
+			SellerID:    seller.ID,
+			Price:       resting.Price,
+			Quantity:    quantity,
+			QuoteAtomic: int64(quoteAtomic(quantity, resting.Price, market.QuoteDecimals)),
+			Status:      models.TradeStatusPending,
+			CreatedAt:   now,
+		}
+		if err := s.DB.RecordTrade(trade); err != nil {
+			if errors.Is(err, storage.ErrOrderNotFillable) {
+				continue // Cancelled meanwhile
+			}
+			return fmt.Errorf("failed to record trade: %w", err)
+		}
+		remaining -= fill
+		log.Printf("Order %s matched order %s: %g @ %g", order.ID, resting.ID, quantity, resting.Price)
+
+		go s.settle(trade, market, asset, buyer, seller)
+	}
+	return nil
+}
+
+// settle signs a trade's swap, records it on the trade before sending, and
+// books it once confirmed. A swap that fails gives the quantity back to both
+// orders; one that may not have been sent, or whose confirmation is not
+// seen, stays sent until ReconcileTrade resolves it from the chain.
+func (s *MarketService) settle(trade models.Trade, market models.Market, asset models.Asset, buyer, seller models.User) {
+	signedTx, sig, err := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceTrade, ID: trade.ID}).SignDelegatedSwap(
+		solana.MustPublicKeyFromBase58(asset.MintAddress),
+		solana.MustPublicKeyFromBase58(market.QuoteMint),
+		solana.MustPublicKeyFromBase58(seller.SolanaPubKey),
+		solana.MustPublicKeyFromBase58(buyer.SolanaPubKey),
+		toAtomic(trade.Quantity, 9),
+		uint64(trade.QuoteAtomic),
+	)
+	if err != nil {
+		log.Printf("Trade %s: failed to sign swap: %v", trade.ID, err)
+		s.failTrade(trade, err.Error())
+		return
+	}
+	// Recorded before sending: a swap is never sent without its trade
+	// pointing at it, so reconciliation cannot fail a trade that landed
+	if err := s.DB.MarkTradeSent(trade.ID, sig.String()); err != nil {
+		log.Printf("Trade %s: failed to record swap %s, not sending it: %v", trade.ID, sig, err)
+		s.failTrade(trade, err.Error())
+		return
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Trade %s: swap %s not sent: %v; ReconcileTrade will resolve it", trade.ID, sig, err)
+		return
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		log.Printf("Trade %s: swap %s failed: %v", trade.ID, sig, err)
+		s.failTrade(trade, err.Error())
+	case err != nil:
+		log.Printf("Trade %s: could not check swap %s: %v; ReconcileTrade will resolve it", trade.ID, sig, err)
+	case confirmed:
+		s.book(trade, sig.String(), buyer)
+	default:
+		log.Printf("Trade %s: swap %s not confirmed yet", trade.ID, sig)
+	}
+}
+
+// failTrade records a trade failed, giving its quantity back to both orders.
+func (s *MarketService) failTrade(trade models.Trade, reason string) {
+	if err := s.DB.FailTrade(trade, reason); err != nil {
+		log.Printf("Trade %s: failed to record failure: %v", trade.ID, err)
+	}
+}
+
+// book records a confirmed swap: the asset leg in the token records and
+// ledger, the trade as settled, and the sale for tax purposes.
+func (s *MarketService) book(trade models.Trade, txID string, buyer models.User) {
+	asset, _, err := s.DB.GetAsset(trade.AssetID)
+	if err != nil {
+		log.Printf("Trade %s: failed to load asset: %v", trade.ID, err)
+		return
+	}
+	buyerATA, _, err := solana.FindAssociatedTokenAddress(
+		solana.MustPublicKeyFromBase58(buyer.SolanaPubKey), solana.MustPublicKeyFromBase58(asset.MintAddress),
+	)
+	if err != nil {
+		log.Printf("Trade %s: failed to derive buyer ATA: %v", trade.ID, err)
+		return
+	}
+	if err := s.DB.TransferTokenBalance(trade.SellerID, trade.BuyerID, trade.AssetID, trade.Quantity, txID, buyerATA.String()); err != nil {
+		log.Printf("ERROR: Trade %s: swap %s confirmed, but DB transfer failed: %v", trade.ID, txID, err)
+	}
+	if err := s.DB.MarkTradeSettled(trade.ID); err != nil {
+		log.Printf("Trade %s: failed to mark settled: %v", trade.ID, err)
+		return
+	}
+	if err := s.Tokenization.Tax.RecordTransfer(trade.AssetID, trade.SellerID, trade.BuyerID, trade.Quantity, &trade.Price, txID, time.Now()); err != nil {
+		log.Printf("ERROR: Failed to record tax effects of trade %s: %v", trade.ID, err)
+	}
+	log.Printf("Trade %s settled | TxID: %s", trade.ID, txID)
+}
+
+// ReconcileTrade resolves a trade whose swap was sent but whose confirmation
+// was not observed: it is booked if the swap landed, and failed if it failed
+// or its blockhash expired.
+func (s *MarketService) ReconcileTrade(id string) (models.Trade, error) {
+	trade, found, err := s.DB.GetTrade(id)
+	if err != nil {
+		return models.Trade{}, fmt.Errorf("error fetching trade: %w", err)
+	}
+	if !found {
+		return models.Trade{}, ErrTradeNotFound
+	}
+	if trade.Status != models.TradeStatusSent || trade.TransactionID == nil {
+		return trade, nil
+	}
+
+	confirmed, err := s.SolanaS.GetTransactionConfirmation(solana.MustSignatureFromBase58(*trade.TransactionID))
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		if err := s.DB.FailTrade(trade, err.Error()); err != nil {
+			return models.Trade{}, fmt.Errorf("failed to record failure: %w", err)
+		}
+	case err != nil:
+		return models.Trade{}, err
+	case confirmed:
+		buyer, _, err := s.DB.GetUser(trade.BuyerID)
+		if err != nil {
+			return models.Trade{}, fmt.Errorf("error fetching buyer: %w", err)
+		}
+		s.book(trade, *trade.TransactionID, buyer)
+	case time.Since(trade.CreatedAt) > payoutExpiry:
+		if err := s.DB.FailTrade(trade, "transaction "+*trade.TransactionID+" was not confirmed"); err != nil {
+			return models.Trade{}, fmt.Errorf("failed to record failure: %w", err)
+		}
+	}
+
+	trade, _, err = s.DB.GetTrade(id)
+	if err != nil {
+		return models.Trade{}, fmt.Errorf("error fetching trade: %w", err)
+	}
+	return trade, nil
+}
+
+// quoteAtomic is the payment for quantity at price, in atomic units of the quote mint.
+func quoteAtomic(quantity, price float64, decimals int) uint64 {
+	return uint64(math.Round(quantity * price * math.Pow10(decimals)))
+}
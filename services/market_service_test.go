@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestQuoteAtomic(t *testing.T) {
+	tests := []struct {
+		name            string
+		quantity, price float64
+		decimals        int
+		want            uint64
+	}{
+		{"whole units", 10, 25, 6, 250_000_000},
+		{"fractional quantity and price", 1.5, 12.34, 6, 18_510_000},
+		{"binary fractions are rounded, not truncated", 3, 0.1, 2, 30},
+		{"below one atomic unit rounds to nearest", 1, 0.004, 2, 0},
+		{"half an atomic unit rounds up", 1, 0.005, 2, 1},
+		{"no decimals", 7, 2.5, 0, 18},
+		{"nothing traded", 0, 25, 6, 0},
+		{"nine decimals", 0.000000001, 1, 9, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteAtomic(tt.quantity, tt.price, tt.decimals); got != tt.want {
+				t.Fatalf("quoteAtomic(%v, %v, %d) = %d, want %d", tt.quantity, tt.price, tt.decimals, got, tt.want)
+			}
+		})
+	}
+}
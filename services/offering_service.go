@@ -0,0 +1,713 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+// offeringPollInterval is how often the scheduler looks for offerings to close.
+const offeringPollInterval = time.Minute
+
+var (
+	// ErrOfferingNotFound is returned when the requested offering does not exist.
+	ErrOfferingNotFound = fmt.Errorf("offering %w", ErrNotFound)
+	// ErrOfferingNotOpen is returned when subscribing outside the subscription period.
+	ErrOfferingNotOpen = fmt.Errorf("%w: offering is not accepting subscriptions", ErrConflict)
+	// ErrOfferingNotClosable is returned when closing an offering before its end date or after it finished.
+	ErrOfferingNotClosable = fmt.Errorf("%w: offering cannot be closed now", ErrConflict)
+	// ErrSubscriptionNotFound is returned when the requested subscription does not exist.
+	ErrSubscriptionNotFound = fmt.Errorf("subscription %w", ErrNotFound)
+	// ErrSubscriptionNotPending is returned when paying or cancelling a subscription that is not awaiting payment.
+	ErrSubscriptionNotPending = fmt.Errorf("%w: subscription is not awaiting payment", ErrConflict)
+)
+
+// OfferingService runs primary offerings. Investors pay their subscription
+// to the FeePayer's treasury account; when the offering closes, a successful
+// raise mints the allocated shares to each subscriber and refunds any excess,
+// and a failed one refunds every payment.
+type OfferingService struct {
+	DB           *storage.DB
+	SolanaS      *SolanaIntegrationService
+	Tokenization *TokenizationService // Compliance, AML and tax
+	stopCh       chan struct{}
+}
+
+func NewOfferingService(db *storage.DB, solanaS *SolanaIntegrationService, tokenization *TokenizationService) *OfferingService {
+	return &OfferingService{DB: db, SolanaS: solanaS, Tokenization: tokenization, stopCh: make(chan struct{})}
+}
+
+// CreateOfferingInput describes a new offering.
+type CreateOfferingInput struct {
+	AssetID        string               `json:"-"`
+	PaymentMint    string               `json:"payment_mint"`
+	Price          float64              `json:"price"`
+	MinRaise       float64              `json:"min_raise"`
+	MaxRaise       float64              `json:"max_raise"`
+	MinPerInvestor float64              `json:"min_per_investor"`
+	MaxPerInvestor float64              `json:"max_per_investor,omitempty"`
+	StartsAt       time.Time            `json:"starts_at"`
+	EndsAt         time.Time            `json:"ends_at"`
+	Vesting        *models.VestingTerms `json:"vesting,omitempty"` // Restricts the shares allocated to each subscriber
+}
+
+// CreateOffering validates and opens an offering on a tokenized asset.
+func (s *OfferingService) CreateOffering(in CreateOfferingInput) (models.Offering, error) {
+	if in.Price <= 0 || in.MaxRaise <= 0 {
+		return models.Offering{}, invalidf("price and max_raise must be positive")
+	}
+	if in.MinRaise < 0 || in.MinRaise > in.MaxRaise {
+		return models.Offering{}, invalidf("min_raise must be between 0 and max_raise")
+	}
+	if in.MinPerInvestor < 0 || in.MaxPerInvestor < 0 || (in.MaxPerInvestor > 0 && in.MinPerInvestor > in.MaxPerInvestor) {
+		return models.Offering{}, invalidf("min_per_investor must not exceed max_per_investor")
+	}
+	if in.StartsAt.IsZero() || !in.EndsAt.After(in.StartsAt) {
+		return models.Offering{}, invalidf("starts_at is required and ends_at must be after it")
+	}
+	if in.Vesting != nil {
+		if err := ValidateVestingTerms(*in.Vesting); err != nil {
+			return models.Offering{}, err
+		}
+	}
+	paymentMint, err := solana.PublicKeyFromBase58(in.PaymentMint)
+	if err != nil {
+		return models.Offering{}, invalidf("invalid payment_mint: %v", err)
+	}
+	asset, found, err := s.DB.GetAsset(in.AssetID)
+	if err != nil {
+		return models.Offering{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found || asset.MintAddress == "" {
+		return models.Offering{}, ErrAssetNotFound
+	}
+	if err := requireSolanaAsset(asset); err != nil {
+		return models.Offering{}, err
+	}
+	if asset.MintAddress == in.PaymentMint {
+		return models.Offering{}, invalidf("payment_mint must differ from the asset mint")
+	}
+	decimals, err := s.SolanaS.GetMintDecimals(paymentMint)
+	if err != nil {
+		return models.Offering{}, fmt.Errorf("failed to read payment mint: %w", err)
+	}
+
+	// Payments land in the treasury, so its account must exist
+	treasury := s.SolanaS.FeePayer.PublicKey()
+	treasuryATA, _, err := solana.FindAssociatedTokenAddress(treasury, paymentMint)
+	if err != nil {
+		return models.Offering{}, fmt.Errorf("failed to derive treasury ATA: %w", err)
+	}
+	id := uuid.New().String()
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceOffering, ID: id})
+	if _, err := solanaS.EnsureATAExists(treasury, paymentMint, treasuryATA); err != nil {
+		return models.Offering{}, fmt.Errorf("failed to ensure treasury ATA exists: %w", err)
+	}
+
+	now := time.Now()
+	offering := models.Offering{
+		ID:              id,
+		AssetID:         asset.ID,
+		PaymentMint:     in.PaymentMint,
+		PaymentDecimals: int(decimals),
+		Price:           in.Price,
+		MinRaise:        in.MinRaise,
+		MaxRaise:        in.MaxRaise,
+		MinPerInvestor:  in.MinPerInvestor,
+		MaxPerInvestor:  in.MaxPerInvestor,
+		StartsAt:        in.StartsAt,
+		EndsAt:          in.EndsAt,
+		Status:          models.OfferingStatusOpen,
+		Vesting:         in.Vesting,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.DB.SaveOffering(offering); err != nil {
+		return models.Offering{}, fmt.Errorf("failed to save offering: %w", err)
+	}
+	return offering, nil
+}
+
+// GetOffering returns an offering.
+func (s *OfferingService) GetOffering(id string) (models.Offering, error) {
+	offering, found, err := s.DB.GetOffering(id)
+	if err != nil {
+		return models.Offering{}, fmt.Errorf("error fetching offering: %w", err)
+	}
+	if !found {
+		return models.Offering{}, ErrOfferingNotFound
+	}
+	return offering, nil
+}
+
+// GetSubscription returns a subscription.
+func (s *OfferingService) GetSubscription(id string) (models.Subscription, error) {
+	subscription, found, err := s.DB.GetSubscription(id)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("error fetching subscription: %w", err)
+	}
+	if !found {
+		return models.Subscription{}, ErrSubscriptionNotFound
+	}
+	return subscription, nil
+}
+
+// SubscribeInput describes an investor's subscription.
+type SubscribeInput struct {
+	OfferingID string  `json:"-"`
+	UserID     string  `json:"user_id"`
+	Amount     float64 `json:"amount"` // Payment token units
+}
+
+// Subscribe checks an investor's eligibility and limits and records the
+// subscription with the payment transaction they must sign.
+func (s *OfferingService) Subscribe(in SubscribeInput) (models.Subscription, error) {
+	if in.Amount <= 0 {
+		return models.Subscription{}, invalidf("amount must be positive")
+	}
+	offering, err := s.GetOffering(in.OfferingID)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	now := time.Now()
+	if offering.Status != models.OfferingStatusOpen || now.Before(offering.StartsAt) || !now.Before(offering.EndsAt) {
+		return models.Subscription{}, ErrOfferingNotOpen
+	}
+
+	user, found, err := s.DB.GetUser(in.UserID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found || user.SolanaPubKey == "" {
+		return models.Subscription{}, ErrUserNotFound
+	}
+	investor, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("invalid user public key: %w", err)
+	}
+
+	subscribed, err := s.DB.GetSubscribedAmount(offering.ID, user.ID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("error fetching subscriptions: %w", err)
+	}
+	total := subscribed + in.Amount
+	if total < offering.MinPerInvestor {
+		return models.Subscription{}, invalidf("the minimum subscription per investor is %g", offering.MinPerInvestor)
+	}
+	if offering.MaxPerInvestor > 0 && total > offering.MaxPerInvestor {
+		return models.Subscription{}, invalidf("the maximum subscription per investor is %g; %g already subscribed", offering.MaxPerInvestor, subscribed)
+	}
+
+	// The investor must be eligible to receive the shares
+	asset, _, err := s.DB.GetAsset(offering.AssetID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if err := s.Tokenization.Compliance.Check(Movement{Asset: asset, To: user, Amount: in.Amount / offering.Price, Timestamp: now}); err != nil {
+		return models.Subscription{}, err
+	}
+	if err := s.Tokenization.AML.ScreenMovement("subscription", offering.ID, user); err != nil {
+		return models.Subscription{}, err
+	}
+
+	paymentMint, err := solana.PublicKeyFromBase58(offering.PaymentMint)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("invalid payment mint: %w", err)
+	}
+	amountAtomic := toAtomic(in.Amount, uint8(offering.PaymentDecimals))
+	balance, err := s.SolanaS.GetOwnerTokenBalance(investor, paymentMint)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	if balance < amountAtomic {
+		return models.Subscription{}, invalidf("insufficient payment balance: have %d, need %d atomic units", balance, amountAtomic)
+	}
+	investorATA, _, err := solana.FindAssociatedTokenAddress(investor, paymentMint)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to derive investor ATA: %w", err)
+	}
+	treasuryATA, _, err := solana.FindAssociatedTokenAddress(s.SolanaS.FeePayer.PublicKey(), paymentMint)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to derive treasury ATA: %w", err)
+	}
+	id := uuid.New().String()
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceSubscription, ID: id})
+	paymentTx, err := solanaS.PrepareTransferTransaction(paymentMint, investorATA, treasuryATA, investor, amountAtomic)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to prepare payment: %w", err)
+	}
+
+	subscription := models.Subscription{
+		ID:                 id,
+		OfferingID:         offering.ID,
+		UserID:             user.ID,
+		Amount:             in.Amount,
+		Status:             models.SubscriptionStatusPendingPayment,
+		PaymentTransaction: paymentTx,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.DB.SaveSubscription(subscription); err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// ConfirmPayment sends the payment signed by the investor and marks the
+// subscription paid once it is confirmed. The signed transaction must be the
+// one prepared at subscription.
+func (s *OfferingService) ConfirmPayment(subscriptionID, signedTxBase64 string) (models.Subscription, error) {
+	subscription, err := s.GetSubscription(subscriptionID)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	if subscription.Status != models.SubscriptionStatusPendingPayment {
+		return models.Subscription{}, ErrSubscriptionNotPending
+	}
+	offering, err := s.GetOffering(subscription.OfferingID)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	if offering.Status != models.OfferingStatusOpen {
+		return models.Subscription{}, ErrOfferingNotOpen
+	}
+	user, _, err := s.DB.GetUser(subscription.UserID)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("error fetching user: %w", err)
+	}
+
+	investor, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("invalid user public key: %w", err)
+	}
+	signedTx, err := s.SolanaS.MergeSignature(subscription.PaymentTransaction, signedTxBase64, investor)
+	if err != nil {
+		if errors.Is(err, ErrTransactionMismatch) {
+			return models.Subscription{}, invalidf("%v", err)
+		}
+		return models.Subscription{}, err
+	}
+	sig, err := s.SolanaS.SendSignedTransaction(signedTx)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to send payment: %w", err)
+	}
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("payment failed: %w", err)
+	}
+	if !confirmed {
+		return models.Subscription{}, fmt.Errorf("payment %s was not confirmed in time", sig)
+	}
+
+	paid, err := s.DB.ConfirmSubscriptionPayment(subscription, sig.String())
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to record payment %s: %w", sig, err)
+	}
+	if !paid {
+		return models.Subscription{}, ErrSubscriptionNotPending
+	}
+	return s.GetSubscription(subscription.ID)
+}
+
+// CancelSubscription withdraws a subscription that was not paid yet.
+func (s *OfferingService) CancelSubscription(id string) (models.Subscription, error) {
+	if _, err := s.GetSubscription(id); err != nil {
+		return models.Subscription{}, err
+	}
+	cancelled, err := s.DB.CancelSubscription(id)
+	if err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	if !cancelled {
+		return models.Subscription{}, ErrSubscriptionNotPending
+	}
+	return s.GetSubscription(id)
+}
+
+// StartClose closes an offering whose subscription period has ended and
+// delivers shares and refunds in the background. Calling it again on an
+// offering still closing retries the deliveries that did not complete.
+func (s *OfferingService) StartClose(id string) (models.Offering, error) {
+	offering, err := s.GetOffering(id)
+	if err != nil {
+		return models.Offering{}, err
+	}
+	if time.Now().Before(offering.EndsAt) {
+		return models.Offering{}, ErrOfferingNotClosable
+	}
+
+	switch offering.Status {
+	case models.OfferingStatusOpen:
+		claimed, err := s.DB.ClaimOffering(id, models.OfferingStatusClosing, models.OfferingStatusOpen)
+		if err != nil {
+			return models.Offering{}, fmt.Errorf("failed to claim offering: %w", err)
+		}
+		if !claimed {
+			return models.Offering{}, ErrOfferingNotClosable
+		}
+		if err := s.allocate(offering); err != nil {
+			if _, err := s.DB.ClaimOffering(id, models.OfferingStatusOpen, models.OfferingStatusClosing); err != nil {
+				log.Printf("Offering %s: failed to reopen: %v", id, err)
+			}
+			return models.Offering{}, err
+		}
+	case models.OfferingStatusClosing:
+	default:
+		return models.Offering{}, ErrOfferingNotClosable
+	}
+
+	go s.deliver(id)
+	return s.GetOffering(id)
+}
+
+// allocate computes every paid subscription's shares and refund. Below the
+// minimum raise nothing is allocated; above the maximum, the accepted amounts
+// are scaled down pro rata with largest-remainder rounding. Shares are rounded
+// down to the atomic unit and whatever they do not cover is refunded.
+func (s *OfferingService) allocate(offering models.Offering) error {
+	paid, err := s.DB.GetSubscriptions(offering.ID, models.SubscriptionStatusPaid)
+	if err != nil {
+		return fmt.Errorf("error fetching subscriptions: %w", err)
+	}
+	decimals := uint8(offering.PaymentDecimals)
+
+	var totalAtomic uint64
+	shares := make([]proRataShare, len(paid))
+	for i, sub := range paid {
+		shares[i] = proRataShare{Key: sub.ID, Weight: toAtomic(sub.Amount, decimals)}
+		totalAtomic += shares[i].Weight
+	}
+
+	accepted := make([]uint64, len(paid))
+	switch {
+	case totalAtomic < toAtomic(offering.MinRaise, decimals):
+		// Failed raise: everything is refunded
+	case totalAtomic > toAtomic(offering.MaxRaise, decimals):
+		accepted = allocateProRata(toAtomic(offering.MaxRaise, decimals), shares)
+	default:
+		for i, sh := range shares {
+			accepted[i] = sh.Weight
+		}
+	}
+
+	var totalAllocated, sharesIssued float64
+	for i := range paid {
+		sharesAtomic := uint64(math.Floor(fromAtomic(accepted[i], decimals) / offering.Price * 1e9))
+		cost := toAtomic(fromAtomic(sharesAtomic, 9)*offering.Price, decimals)
+		if cost > accepted[i] {
+			cost = accepted[i]
+		}
+		paid[i].AllocatedShares = fromAtomic(sharesAtomic, 9)
+		paid[i].AllocatedAmount = fromAtomic(cost, decimals)
+		paid[i].RefundAmount = fromAtomic(shares[i].Weight-cost, decimals)
+		totalAllocated += paid[i].AllocatedAmount
+		sharesIssued += paid[i].AllocatedShares
+	}
+
+	if err := s.DB.AllocateOffering(offering.ID, paid, totalAllocated, sharesIssued); err != nil {
+		return fmt.Errorf("failed to store allocations: %w", err)
+	}
+	log.Printf("Offering %s allocated: %g raised for %g shares across %d subscriptions", offering.ID, totalAllocated, sharesIssued, len(paid))
+	return nil
+}
+
+// deliver mints the allocated shares and sends the refunds in batches, then
+// settles the offering.
+func (s *OfferingService) deliver(offeringID string) {
+	offering, err := s.GetOffering(offeringID)
+	if err != nil {
+		log.Printf("Offering %s: %v", offeringID, err)
+		return
+	}
+	asset, _, err := s.DB.GetAsset(offering.AssetID)
+	if err != nil {
+		log.Printf("Offering %s: failed to load asset: %v", offeringID, err)
+		return
+	}
+
+	assetMint, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		log.Printf("Offering %s: asset %s has an invalid mint address: %v", offeringID, asset.ID, err)
+		return
+	}
+	paymentMint, err := solana.PublicKeyFromBase58(offering.PaymentMint)
+	if err != nil {
+		log.Printf("Offering %s: invalid payment mint: %v", offeringID, err)
+		return
+	}
+
+	if err := s.reconcileSent(offering); err != nil {
+		log.Printf("Offering %s: failed to reconcile sent deliveries: %v", offeringID, err)
+	}
+
+	allocated, err := s.DB.GetSubscriptions(offeringID, models.SubscriptionStatusAllocated)
+	if err != nil {
+		log.Printf("Offering %s: failed to load allocations: %v", offeringID, err)
+		return
+	}
+	// Refunds go out whatever the asset's state; shares wait until it can be minted again
+	canIssue := checkAssetStatus(asset, true) == nil
+	if !canIssue {
+		log.Printf("Offering %s: asset %s is %s; holding back share issuance", offeringID, asset.ID, asset.Status)
+	}
+	var issues, refunds []models.Subscription
+	for _, sub := range allocated {
+		if canIssue && sub.AllocatedShares > 0 && sub.IssueTxID == nil && sub.IssuedAt == nil {
+			issues = append(issues, sub)
+		}
+		if sub.RefundAmount > 0 && sub.RefundTxID == nil && sub.RefundedAt == nil {
+			refunds = append(refunds, sub)
+		}
+	}
+	for start := 0; start < len(issues); start += payoutBatchSize {
+		s.sendBatch(offering, assetMint, issues[start:min(start+payoutBatchSize, len(issues))], storage.SubscriptionLegIssue)
+	}
+	for start := 0; start < len(refunds); start += payoutBatchSize {
+		s.sendBatch(offering, paymentMint, refunds[start:min(start+payoutBatchSize, len(refunds))], storage.SubscriptionLegRefund)
+	}
+
+	s.settle(offering)
+}
+
+// sendBatch signs one leg for a batch of subscriptions, records it on the
+// subscriptions before sending, and books it once confirmed. Issues mint
+// `mint` tokens; refunds transfer them from the treasury.
+func (s *OfferingService) sendBatch(offering models.Offering, mint solana.PublicKey, batch []models.Subscription, leg string) {
+	ids := make([]string, 0, len(batch))
+	moves := make([]TokenTransfer, 0, len(batch))
+	valid := make([]models.Subscription, 0, len(batch))
+	for _, sub := range batch {
+		user, _, err := s.DB.GetUser(sub.UserID)
+		if err != nil {
+			log.Printf("Offering %s: failed to load subscriber %s: %v", offering.ID, sub.UserID, err)
+			return
+		}
+		owner, err := solana.PublicKeyFromBase58(user.SolanaPubKey)
+		if err != nil {
+			log.Printf("Offering %s: subscriber %s has an invalid public key: %v", offering.ID, sub.UserID, err)
+			if err := s.DB.ResetSubscriptionsSent([]string{sub.ID}, leg, "invalid subscriber public key"); err != nil {
+				log.Printf("Offering %s: failed to record %s failure: %v", offering.ID, leg, err)
+			}
+			continue
+		}
+		move := TokenTransfer{Owner: owner}
+		if leg == storage.SubscriptionLegIssue {
+			move.Amount = toAtomic(sub.AllocatedShares, 9)
+		} else {
+			move.Amount = toAtomic(sub.RefundAmount, uint8(offering.PaymentDecimals))
+		}
+		ids = append(ids, sub.ID)
+		moves = append(moves, move)
+		valid = append(valid, sub)
+	}
+	if len(valid) == 0 {
+		return
+	}
+	batch = valid
+
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceOffering, ID: offering.ID})
+	var signedTx string
+	var sig solana.Signature
+	var err error
+	if leg == storage.SubscriptionLegIssue {
+		signedTx, sig, err = solanaS.SignMintToAccounts(mint, moves)
+	} else {
+		signedTx, sig, err = solanaS.SignTokenTransfers(mint, moves)
+	}
+	if err != nil {
+		log.Printf("Offering %s: failed to sign %s batch of %d: %v", offering.ID, leg, len(batch), err)
+		if err := s.DB.ResetSubscriptionsSent(ids, leg, err.Error()); err != nil {
+			log.Printf("Offering %s: failed to record %s failure: %v", offering.ID, leg, err)
+		}
+		return
+	}
+	// Recorded before sending: a crash in between leaves the leg sent, and
+	// reconciliation resends it only once the transaction can no longer land
+	if err := s.DB.MarkSubscriptionsSent(ids, leg, sig.String()); err != nil {
+		log.Printf("Offering %s: failed to record %s tx %s, not sending it: %v", offering.ID, leg, sig, err)
+		return
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		// A send error does not prove the transaction was dropped, so its
+		// status decides whether the leg is reset
+		log.Printf("Offering %s: %s tx %s not sent: %v", offering.ID, leg, sig, err)
+		if err := s.resolveSent(offering, batch, leg, sig.String(), time.Now()); err != nil {
+			log.Printf("Offering %s: could not check %s tx %s: %v; will reconcile on next close", offering.ID, leg, sig, err)
+		}
+		return
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		log.Printf("Offering %s: %s tx %s failed: %v", offering.ID, leg, sig, err)
+		if err := s.DB.ResetSubscriptionsSent(ids, leg, err.Error()); err != nil {
+			log.Printf("Offering %s: failed to record %s failure: %v", offering.ID, leg, err)
+		}
+	case err != nil:
+		log.Printf("Offering %s: could not check %s tx %s: %v; will reconcile on next close", offering.ID, leg, sig, err)
+	case confirmed:
+		s.book(offering, batch, leg, sig.String())
+	default:
+		log.Printf("Offering %s: %s tx %s not confirmed yet; will reconcile on next close", offering.ID, leg, sig)
+	}
+}
+
+// book records a confirmed leg. Issued shares are journaled, credited to the
+// subscribers, restricted by the offering's vesting terms and open a tax lot
+// at their subscription cost.
+func (s *OfferingService) book(offering models.Offering, batch []models.Subscription, leg, txID string) {
+	if leg == storage.SubscriptionLegRefund {
+		ids := make([]string, len(batch))
+		for i, sub := range batch {
+			ids[i] = sub.ID
+		}
+		if err := s.DB.ConfirmSubscriptionRefunds(ids); err != nil {
+			log.Printf("Offering %s: failed to book refunds of tx %s: %v", offering.ID, txID, err)
+		}
+		return
+	}
+
+	var schedules []models.VestingSchedule
+	if offering.Vesting != nil {
+		now := time.Now()
+		for _, sub := range batch {
+			schedules = append(schedules, offering.Vesting.Schedule(uuid.New().String(), offering.AssetID, sub.UserID, sub.AllocatedShares, now))
+		}
+	}
+	if err := s.DB.IssueSubscriptionShares(offering.AssetID, batch, schedules, txID); err != nil {
+		log.Printf("Offering %s: failed to book shares of tx %s: %v", offering.ID, txID, err)
+		return
+	}
+	for _, sub := range batch {
+		err := s.Tokenization.Tax.RecordAcquisition(offering.AssetID, sub.UserID, sub.AllocatedShares, sub.AllocatedAmount, "offering", txID, time.Now())
+		if err != nil {
+			log.Printf("ERROR: Failed to record tax lot of subscription %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// reconcileSent resolves legs whose transaction was sent but whose
+// confirmation was not observed.
+func (s *OfferingService) reconcileSent(offering models.Offering) error {
+	allocated, err := s.DB.GetSubscriptions(offering.ID, models.SubscriptionStatusAllocated)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		leg, txID string
+	}
+	batches := make(map[pending][]models.Subscription)
+	for _, sub := range allocated {
+		if sub.IssueTxID != nil && sub.IssuedAt == nil {
+			key := pending{storage.SubscriptionLegIssue, *sub.IssueTxID}
+			batches[key] = append(batches[key], sub)
+		}
+		if sub.RefundTxID != nil && sub.RefundedAt == nil {
+			key := pending{storage.SubscriptionLegRefund, *sub.RefundTxID}
+			batches[key] = append(batches[key], sub)
+		}
+	}
+
+	for key, batch := range batches {
+		if err := s.resolveSent(offering, batch, key.leg, key.txID, batch[0].UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSent checks the status of a leg's recorded transaction: it books the
+// leg once confirmed and resets it for resending once the transaction failed,
+// or was sent at sentAt and can no longer land.
+func (s *OfferingService) resolveSent(offering models.Offering, batch []models.Subscription, leg, txID string, sentAt time.Time) error {
+	ids := make([]string, len(batch))
+	for i, sub := range batch {
+		ids[i] = sub.ID
+	}
+	sig, err := solana.SignatureFromBase58(txID)
+	if err != nil {
+		return fmt.Errorf("invalid transaction id %s: %w", txID, err)
+	}
+	confirmed, err := s.SolanaS.GetTransactionConfirmation(sig)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		return s.DB.ResetSubscriptionsSent(ids, leg, err.Error())
+	case err != nil:
+		return err
+	case confirmed:
+		s.book(offering, batch, leg, txID)
+	case time.Since(sentAt) > payoutExpiry:
+		// Never landed and the blockhash has expired: safe to resend
+		return s.DB.ResetSubscriptionsSent(ids, leg, "transaction "+txID+" was not confirmed")
+	}
+	return nil
+}
+
+// settle finishes the offering once every allocation is delivered.
+func (s *OfferingService) settle(offering models.Offering) {
+	open, err := s.DB.GetSubscriptions(offering.ID, models.SubscriptionStatusAllocated)
+	if err != nil || len(open) > 0 {
+		log.Printf("Offering %s: %d deliveries outstanding; close again to retry", offering.ID, len(open))
+		return
+	}
+	offering, err = s.GetOffering(offering.ID)
+	if err != nil {
+		log.Printf("Offering %s: %v", offering.ID, err)
+		return
+	}
+	status := models.OfferingStatusSucceeded
+	if offering.SharesIssued == 0 {
+		status = models.OfferingStatusFailed
+	}
+	if err := s.DB.FinishOffering(offering.ID, status); err != nil {
+		log.Printf("Offering %s: failed to update status: %v", offering.ID, err)
+		return
+	}
+	log.Printf("Offering %s closed with status %s", offering.ID, status)
+}
+
+// Stop signals the scheduler to shut down.
+func (s *OfferingService) Stop() {
+	close(s.stopCh)
+}
+
+// StartScheduler closes open offerings once their subscription period ends.
+// Blocks until Stop() is called.
+func (s *OfferingService) StartScheduler() {
+	ticker := time.NewTicker(offeringPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.closeDueOfferings()
+		select {
+		case <-s.stopCh:
+			log.Println("Offering scheduler stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeDueOfferings starts closing every open offering past its end date.
+func (s *OfferingService) closeDueOfferings() {
+	due, err := s.DB.GetDueOfferings(time.Now())
+	if err != nil {
+		log.Printf("Offering scheduler: failed to load due offerings: %v", err)
+		return
+	}
+	for _, offering := range due {
+		if _, err := s.StartClose(offering.ID); err != nil && !errors.Is(err, ErrOfferingNotClosable) {
+			log.Printf("Offering scheduler: failed to close %s: %v", offering.ID, err)
+		}
+	}
+}
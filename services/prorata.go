@@ -0,0 +1,67 @@
+package services
+
+import (
+	"math"
+	"math/big"
+	"sort"
+)
+
+// proRataShare is one participant of a pro-rata allocation.
+type proRataShare struct {
+	Key    string // Stable identifier used to break ties (e.g. wallet address)
+	Weight uint64 // e.g. holding in atomic units
+}
+
+// allocateProRata splits total atomic units across shares proportionally to
+// their weights using the largest remainder method: every share gets the floor
+// of its exact entitlement, and the leftover units go one by one to the
+// largest remainders, ties broken by key. The result is deterministic and
+// always sums to total (when any weight is non-zero).
+func allocateProRata(total uint64, shares []proRataShare) []uint64 {
+	allocations := make([]uint64, len(shares))
+
+	weightSum := new(big.Int)
+	for _, sh := range shares {
+		weightSum.Add(weightSum, new(big.Int).SetUint64(sh.Weight))
+	}
+	if weightSum.Sign() == 0 {
+		return allocations
+	}
+
+	totalInt := new(big.Int).SetUint64(total)
+	remainders := make([]*big.Int, len(shares))
+	distributed := uint64(0)
+	for i, sh := range shares {
+		product := new(big.Int).Mul(totalInt, new(big.Int).SetUint64(sh.Weight))
+		quotient, remainder := new(big.Int).QuoRem(product, weightSum, new(big.Int))
+		allocations[i] = quotient.Uint64()
+		remainders[i] = remainder
+		distributed += allocations[i]
+	}
+
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if c := remainders[order[a]].Cmp(remainders[order[b]]); c != 0 {
+			return c > 0
+		}
+		return shares[order[a]].Key < shares[order[b]].Key
+	})
+	for i := 0; distributed < total; i++ {
+		allocations[order[i%len(order)]]++
+		distributed++
+	}
+	return allocations
+}
+
+// toAtomic converts a decimal amount to atomic units with the given decimals.
+func toAtomic(amount float64, decimals uint8) uint64 {
+	return uint64(math.Round(amount * math.Pow10(int(decimals))))
+}
+
+// fromAtomic converts atomic units back to a decimal amount.
+func fromAtomic(amount uint64, decimals uint8) float64 {
+	return float64(amount) / math.Pow10(int(decimals))
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestAllocateProRata(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  uint64
+		shares []proRataShare
+		want   []uint64
+	}{
+		{
+			name:  "no shares",
+			total: 100,
+			want:  []uint64{},
+		},
+		{
+			name:   "zero weights allocate nothing",
+			total:  100,
+			shares: []proRataShare{{Key: "a"}, {Key: "b"}},
+			want:   []uint64{0, 0},
+		},
+		{
+			name:   "exact split",
+			total:  100,
+			shares: []proRataShare{{Key: "a", Weight: 1}, {Key: "b", Weight: 1}, {Key: "c", Weight: 2}},
+			want:   []uint64{25, 25, 50},
+		},
+		{
+			name:   "leftover goes to the largest remainder",
+			total:  10,
+			shares: []proRataShare{{Key: "x", Weight: 1}, {Key: "y", Weight: 2}},
+			want:   []uint64{3, 7},
+		},
+		{
+			name:   "equal remainders are broken by key",
+			total:  10,
+			shares: []proRataShare{{Key: "c", Weight: 1}, {Key: "a", Weight: 1}, {Key: "b", Weight: 1}},
+			want:   []uint64{3, 4, 3},
+		},
+		{
+			name:   "zero weight gets nothing",
+			total:  5,
+			shares: []proRataShare{{Key: "a", Weight: 0}, {Key: "b", Weight: 3}},
+			want:   []uint64{0, 5},
+		},
+		{
+			name:   "total smaller than the number of shares",
+			total:  2,
+			shares: []proRataShare{{Key: "a", Weight: 5}, {Key: "b", Weight: 5}, {Key: "c", Weight: 5}},
+			want:   []uint64{1, 1, 0},
+		},
+		{
+			name:   "products beyond 64 bits",
+			total:  math.MaxUint64,
+			shares: []proRataShare{{Key: "b", Weight: math.MaxUint64}, {Key: "a", Weight: math.MaxUint64}},
+			want:   []uint64{math.MaxUint64 / 2, math.MaxUint64/2 + 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocateProRata(tt.total, tt.shares)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("allocateProRata(%d) = %v, want %v", tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateProRataSumsToTotal(t *testing.T) {
+	shares := []proRataShare{{Key: "a", Weight: 7}, {Key: "b", Weight: 13}, {Key: "c", Weight: 29}, {Key: "d", Weight: 1}}
+	for _, total := range []uint64{0, 1, 3, 49, 50, 999_999_999} {
+		var sum uint64
+		for _, a := range allocateProRata(total, shares) {
+			sum += a
+		}
+		if sum != total {
+			t.Errorf("allocateProRata(%d) sums to %d", total, sum)
+		}
+	}
+}
+
+func TestToAtomic(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		decimals uint8
+		want     uint64
+	}{
+		{0, 9, 0},
+		{1, 9, 1_000_000_000},
+		{0.1, 6, 100_000},
+		{0.29, 2, 29}, // 28.999... in binary; rounded, not truncated
+		{12.5, 0, 13},
+	}
+	for _, tt := range tests {
+		if got := toAtomic(tt.amount, tt.decimals); got != tt.want {
+			t.Errorf("toAtomic(%v, %d) = %d, want %d", tt.amount, tt.decimals, got, tt.want)
+		}
+		if tt.decimals > 0 {
+			if back := fromAtomic(toAtomic(tt.amount, tt.decimals), tt.decimals); math.Abs(back-tt.amount) > math.Pow10(-int(tt.decimals)) {
+				t.Errorf("fromAtomic(toAtomic(%v)) = %v", tt.amount, back)
+			}
+		}
+	}
+}
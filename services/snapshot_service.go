@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSnapshotNotFound is returned when the requested snapshot does not exist.
+	ErrSnapshotNotFound = fmt.Errorf("snapshot %w", ErrNotFound)
+	// ErrSnapshotExists is returned when an asset already has a snapshot with the same name.
+	ErrSnapshotExists = fmt.Errorf("%w: a snapshot with this name already exists for the asset", ErrConflict)
+)
+
+// SnapshotService materializes point-in-time holdings (record dates) from the ledger.
+type SnapshotService struct {
+	DB *storage.DB
+}
+
+func NewSnapshotService(db *storage.DB) *SnapshotService {
+	return &SnapshotService{DB: db}
+}
+
+// CreateSnapshot stores the holdings of an asset at a record date or slot.
+// Exactly one of recordDate and slot must be given.
+func (s *SnapshotService) CreateSnapshot(assetID, name string, recordDate *time.Time, slot *int64) (models.Snapshot, error) {
+	if name == "" {
+		return models.Snapshot{}, invalidf("snapshot name is required")
+	}
+	if (recordDate == nil) == (slot == nil) {
+		return models.Snapshot{}, invalidf("exactly one of record_date or slot is required")
+	}
+	if recordDate != nil && recordDate.After(time.Now()) {
+		return models.Snapshot{}, invalidf("record_date cannot be in the future")
+	}
+
+	asset, found, err := s.DB.GetAsset(assetID)
+	if err != nil {
+		return models.Snapshot{}, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !found {
+		return models.Snapshot{}, ErrAssetNotFound
+	}
+
+	snapshot, err := s.DB.CreateSnapshot(models.Snapshot{
+		ID:         uuid.New().String(),
+		AssetID:    asset.ID,
+		Name:       name,
+		RecordDate: recordDate,
+		Slot:       slot,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.Snapshot{}, ErrSnapshotExists
+		}
+		return models.Snapshot{}, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// GetSnapshot returns a snapshot together with its holdings.
+func (s *SnapshotService) GetSnapshot(id string) (models.Snapshot, error) {
+	snapshot, found, err := s.DB.GetSnapshot(id)
+	if err != nil {
+		return models.Snapshot{}, fmt.Errorf("error fetching snapshot: %w", err)
+	}
+	if !found {
+		return models.Snapshot{}, ErrSnapshotNotFound
+	}
+	snapshot.Holdings, err = s.DB.GetSnapshotHoldings(snapshot.ID)
+	if err != nil {
+		return models.Snapshot{}, fmt.Errorf("error fetching snapshot holdings: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ExportCSV writes the holdings of a snapshot as CSV, one row per wallet.
+func (s *SnapshotService) ExportCSV(snapshot models.Snapshot, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"owner_id", "solana_pub_key", "amount", "percent"}); err != nil {
+		return err
+	}
+	for _, h := range snapshot.Holdings {
+		ownerID := ""
+		if h.OwnerID != nil {
+			ownerID = *h.OwnerID
+		}
+		percent := 0.0
+		if snapshot.TotalAmount > 0 {
+			percent = h.Amount / snapshot.TotalAmount * 100
+		}
+		row := []string{
+			ownerID,
+			h.SolanaPubKey,
+			strconv.FormatFloat(h.Amount, 'f', 9, 64),
+			strconv.FormatFloat(percent, 'f', 6, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
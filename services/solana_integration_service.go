@@ -3,9 +3,14 @@ package services
 import (
 	"context"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
 
 	"github.com/gagliardetto/solana-go"
 	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
@@ -18,6 +23,7 @@ import (
 type SolanaIntegrationService struct {
 	RPCClient *rpc.Client
 	FeePayer  solana.PrivateKey
+	reference *models.TxReference // Attached as a memo to every transaction built; see WithReference
 }
 
 func NewSolanaIntegrationService(rpcEndpoint, feePayerKeyBase58 string) *SolanaIntegrationService {
@@ -32,11 +38,44 @@ func NewSolanaIntegrationService(rpcEndpoint, feePayerKeyBase58 string) *SolanaI
 	}
 }
 
-// CreateMintAndTokenAccount creates a new SPL Token Mint and the owner's
-// Associated Token Account on Solana. The FeePayer acts as the Mint Authority.
+// WithReference returns a copy of the service that attaches `ref` as an SPL
+// Memo to every transaction it builds, so the listener can tie the
+// transaction back to the business object that originated it.
+func (s *SolanaIntegrationService) WithReference(ref models.TxReference) *SolanaIntegrationService {
+	referenced := *s
+	referenced.reference = &ref
+	return &referenced
+}
+
+// referenced appends the service's reference memo, if any, to a
+// transaction's instructions. The FeePayer signs the memo, so references
+// cannot be forged by third parties.
+func (s *SolanaIntegrationService) referenced(instructions []solana.Instruction) []solana.Instruction {
+	if s.reference == nil {
+		return instructions
+	}
+	memoIx := newMemoInstruction(s.reference.Memo(), s.FeePayer.PublicKey())
+	return append(instructions[:len(instructions):len(instructions)], memoIx)
+}
+
+// newMemoInstruction builds an SPL Memo instruction signed by `signer`. The
+// Memo program takes the message as raw UTF-8, which is also how the memo
+// readers compare it; the memo package's builder prefixes its length.
+func newMemoInstruction(message string, signer solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(
+		solana.MemoProgramID,
+		solana.AccountMetaSlice{solana.Meta(signer).SIGNER()},
+		[]byte(message),
+	)
+}
+
+// CreateMintAndTokenAccount creates a new SPL Token Mint, its Metaplex token
+// metadata (name, symbol and the URI of the off-chain JSON) and the owner's
+// Associated Token Account on Solana. The FeePayer acts as the Mint Authority
+// and metadata update authority.
 // Returns (mintAddress, tokenAccountAddress, error).
 func (s *SolanaIntegrationService) CreateMintAndTokenAccount(
-	ownerPubKey solana.PublicKey, assetSymbol string,
+	ownerPubKey solana.PublicKey, assetName, assetSymbol, metadataURI string,
 ) (solana.PublicKey, solana.PublicKey, error) {
 	ctx := context.Background()
 
@@ -63,7 +102,8 @@ func (s *SolanaIntegrationService) CreateMintAndTokenAccount(
 	// 4. Build instructions:
 	//    a) CreateAccount for the Mint
 	//    b) InitializeMint (9 decimals, FeePayer as mint authority and freeze authority)
-	//    c) CreateAssociatedTokenAccount for the owner
+	//    c) CreateMetadataAccountV3, so wallets display the asset
+	//    d) CreateAssociatedTokenAccount for the owner
 
 	ownerATA, _, err := solana.FindAssociatedTokenAddress(ownerPubKey, mintPubKey)
 	if err != nil {
@@ -88,6 +128,17 @@ func (s *SolanaIntegrationService) CreateMintAndTokenAccount(
 		solana.SysVarRentPubkey,
 	).Build()
 
+	metadataPubKey, err := FindMetadataAddress(mintPubKey)
+	if err != nil {
+		return solana.PublicKey{}, solana.PublicKey{}, fmt.Errorf("failed to derive metadata address: %w", err)
+	}
+	createMetadataIx, err := newCreateMetadataInstruction(
+		metadataPubKey, mintPubKey, feePayerPubKey, assetName, assetSymbol, metadataURI,
+	)
+	if err != nil {
+		return solana.PublicKey{}, solana.PublicKey{}, err
+	}
+
 	createATAIx := associatedtokenaccount.NewCreateInstruction(
 		feePayerPubKey,
 		ownerPubKey,
@@ -95,7 +146,7 @@ func (s *SolanaIntegrationService) CreateMintAndTokenAccount(
 	).Build()
 
 	tx, err := solana.NewTransaction(
-		[]solana.Instruction{createAccountIx, initMintIx, createATAIx},
+		s.referenced([]solana.Instruction{createAccountIx, initMintIx, createMetadataIx, createATAIx}),
 		resp.Value.Blockhash,
 		solana.TransactionPayer(feePayerPubKey),
 	)
@@ -150,7 +201,7 @@ func (s *SolanaIntegrationService) MintTokensToAccount(
 	).Build()
 
 	tx, err := solana.NewTransaction(
-		[]solana.Instruction{mintToIx},
+		s.referenced([]solana.Instruction{mintToIx}),
 		resp.Value.Blockhash,
 		solana.TransactionPayer(s.FeePayer.PublicKey()),
 	)
@@ -188,12 +239,6 @@ func (s *SolanaIntegrationService) PrepareTransferTransaction(
 	fromOwnerPubKey solana.PublicKey, // Public key of the actual sender
 	amount uint64,
 ) (string, error) { // Returns the transaction encoded in Base64
-	resp, err := s.RPCClient.GetRecentBlockhash(context.Background(), rpc.CommitmentFinalized)
-	if err != nil {
-		return "", fmt.Errorf("failed to get blockhash: %w", err)
-	}
-	recentBlockhash := resp.Value.Blockhash
-
 	// Instruction to transfer tokens
 	transferInstruction := token.NewTransferInstruction(
 		amount,
@@ -203,37 +248,8 @@ func (s *SolanaIntegrationService) PrepareTransferTransaction(
 		[]solana.PublicKey{}, // Multisigners (none in this case)
 	).Build()
 
-	// The FeePayer pays the transaction fee
-	tx, err := solana.NewTransaction(
-		[]solana.Instruction{
-			transferInstruction,
-		},
-		recentBlockhash,
-		solana.TransactionPayer(s.FeePayer.PublicKey()),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create transfer transaction: %w", err)
-	}
-
-	// The FeePayer MUST sign, as they are the transaction payer
-	// The fromOwnerPubKey (sender) will sign on the frontend
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if key.Equals(s.FeePayer.PublicKey()) {
-			return &s.FeePayer
-		}
-		return nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction by FeePayer: %w", err)
-	}
-
-	// Serialize the transaction to be sent to the client
-	serializedTx, err := tx.MarshalBinary()
-	if err != nil {
-		return "", fmt.Errorf("failed to serialize transaction: %w", err)
-	}
-
-	return base64.StdEncoding.EncodeToString(serializedTx), nil
+	// The FeePayer pays the fee and signs now; the sender signs on the frontend
+	return s.prepareUserTransaction([]solana.Instruction{transferInstruction}, "transfer")
 }
 
 // EnsureATAExists checks if a token account exists and creates it if not.
@@ -261,7 +277,7 @@ func (s *SolanaIntegrationService) EnsureATAExists(
 	).Build()
 
 	tx, err := solana.NewTransaction(
-		[]solana.Instruction{createATAIx},
+		s.referenced([]solana.Instruction{createATAIx}),
 		resp.Value.Blockhash,
 		solana.TransactionPayer(s.FeePayer.PublicKey()),
 	)
@@ -355,3 +371,758 @@ func (s *SolanaIntegrationService) GetTokenSupply(mintAddress solana.PublicKey)
 	}
 	return amount, nil
 }
+
+// GetOwnerTokenBalance returns the balance of an owner's ATA for a mint in
+// atomic units, zero if the account does not exist.
+func (s *SolanaIntegrationService) GetOwnerTokenBalance(owner, mintAddress solana.PublicKey) (uint64, error) {
+	ata, _, err := solana.FindAssociatedTokenAddress(owner, mintAddress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to derive ATA: %w", err)
+	}
+	balance, err := s.GetTokenAccountBalance(ata)
+	if err != nil {
+		return 0, nil // No token account yet
+	}
+	return balance, nil
+}
+
+// GetTokenHolders lists every SPL token account of a mint and returns the
+// balance (atomic units) held by each wallet owner. Accounts with a zero
+// balance are omitted.
+func (s *SolanaIntegrationService) GetTokenHolders(mintAddress solana.PublicKey) (map[string]uint64, error) {
+	ctx := context.Background()
+
+	accounts, err := s.RPCClient.GetProgramAccountsWithOpts(ctx, solana.TokenProgramID, &rpc.GetProgramAccountsOpts{
+		Commitment: rpc.CommitmentConfirmed,
+		Filters: []rpc.RPCFilter{
+			{DataSize: 165}, // SPL token account size in bytes
+			{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: solana.Base58(mintAddress.Bytes())}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token accounts for %s: %w", mintAddress, err)
+	}
+
+	// Token account layout: mint (32) | owner (32) | amount (u64 LE) | ...
+	holders := make(map[string]uint64)
+	for _, acc := range accounts {
+		if acc == nil || acc.Account == nil {
+			continue
+		}
+		data := acc.Account.Data.GetBinary()
+		if len(data) < 72 {
+			continue
+		}
+		amount := binary.LittleEndian.Uint64(data[64:72])
+		if amount == 0 {
+			continue
+		}
+		owner := solana.PublicKeyFromBytes(data[32:64])
+		holders[owner.String()] += amount
+	}
+	return holders, nil
+}
+
+// GetMintDecimals returns the number of decimals configured on a mint.
+func (s *SolanaIntegrationService) GetMintDecimals(mintAddress solana.PublicKey) (uint8, error) {
+	result, err := s.RPCClient.GetTokenSupply(context.Background(), mintAddress, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token supply for %s: %w", mintAddress, err)
+	}
+	if result == nil || result.Value == nil {
+		return 0, fmt.Errorf("empty token supply response for %s", mintAddress)
+	}
+	return result.Value.Decimals, nil
+}
+
+// TokenTransfer is one recipient of a backend-signed batch transfer.
+type TokenTransfer struct {
+	Owner  solana.PublicKey // Wallet of the recipient; the ATA is derived from it
+	Amount uint64           // Atomic units
+}
+
+// newCreateIdempotentATAInstruction builds the Associated Token Account
+// program's CreateIdempotent instruction, which succeeds even if the account
+// already exists. This lets ATA creation be packed with the transfer itself.
+func newCreateIdempotentATAInstruction(payer, owner, mint, ata solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(
+		solana.SPLAssociatedTokenAccountProgramID,
+		solana.AccountMetaSlice{
+			solana.Meta(payer).WRITE().SIGNER(),
+			solana.Meta(ata).WRITE(),
+			solana.Meta(owner),
+			solana.Meta(mint),
+			solana.Meta(solana.SystemProgramID),
+			solana.Meta(solana.TokenProgramID),
+		},
+		[]byte{1}, // CreateIdempotent
+	)
+}
+
+// SignTokenTransfers builds and signs, but does not send, a transaction
+// transferring `mintAddress` tokens from the FeePayer's own ATA (the
+// treasury) to every recipient, creating recipient ATAs as needed. Like
+// SignTransferFromEscrow it returns the signature first, so callers can
+// record it before sending with SendSignedTransaction and never send the
+// transfers twice.
+func (s *SolanaIntegrationService) SignTokenTransfers(
+	mintAddress solana.PublicKey, transfers []TokenTransfer,
+) (string, solana.Signature, error) {
+	instructions, err := s.tokenTransferInstructions(mintAddress, transfers)
+	if err != nil {
+		return "", solana.Signature{}, err
+	}
+	return s.serializeBackendTransaction(instructions, "batch transfer")
+}
+
+// tokenTransferInstructions builds the transfers of `mintAddress` tokens from
+// the treasury, each preceded by the idempotent creation of its recipient's ATA.
+func (s *SolanaIntegrationService) tokenTransferInstructions(
+	mintAddress solana.PublicKey, transfers []TokenTransfer,
+) ([]solana.Instruction, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	treasuryATA, _, err := solana.FindAssociatedTokenAddress(feePayerPubKey, mintAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive treasury ATA: %w", err)
+	}
+
+	instructions := make([]solana.Instruction, 0, len(transfers)*2)
+	for _, t := range transfers {
+		toATA, _, err := solana.FindAssociatedTokenAddress(t.Owner, mintAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive ATA for %s: %w", t.Owner, err)
+		}
+		instructions = append(instructions,
+			newCreateIdempotentATAInstruction(feePayerPubKey, t.Owner, mintAddress, toATA),
+			token.NewTransferInstruction(t.Amount, treasuryATA, toATA, feePayerPubKey, []solana.PublicKey{}).Build(),
+		)
+	}
+	return instructions, nil
+}
+
+// WaitForConfirmation polls the status of a transaction until it is confirmed,
+// fails or the timeout elapses. It returns true only when the transaction
+// landed without error; a timeout is not an error.
+func (s *SolanaIntegrationService) WaitForConfirmation(sig solana.Signature, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		confirmed, err := s.GetTransactionConfirmation(sig)
+		if err != nil || confirmed {
+			return confirmed, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// ErrTransactionFailed is returned when a transaction landed but its execution failed.
+var ErrTransactionFailed = errors.New("transaction failed on chain")
+
+// GetTransactionConfirmation reports whether a transaction is confirmed. It
+// returns an error wrapping ErrTransactionFailed if the transaction landed but
+// failed on chain.
+func (s *SolanaIntegrationService) GetTransactionConfirmation(sig solana.Signature) (bool, error) {
+	status, err := s.signatureStatus(sig)
+	if err != nil || status == nil {
+		return false, err
+	}
+	return status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed ||
+		status.ConfirmationStatus == rpc.ConfirmationStatusFinalized, nil
+}
+
+// GetTransactionFinalization reports whether a transaction is finalized, i.e.
+// can no longer be rolled back. Failures are reported like GetTransactionConfirmation.
+func (s *SolanaIntegrationService) GetTransactionFinalization(sig solana.Signature) (bool, error) {
+	status, err := s.signatureStatus(sig)
+	if err != nil || status == nil {
+		return false, err
+	}
+	return status.ConfirmationStatus == rpc.ConfirmationStatusFinalized, nil
+}
+
+// WaitForFinalization polls a transaction until it is finalized, fails or the
+// timeout elapses, like WaitForConfirmation.
+func (s *SolanaIntegrationService) WaitForFinalization(sig solana.Signature, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		finalized, err := s.GetTransactionFinalization(sig)
+		if err != nil || finalized {
+			return finalized, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// signatureStatus fetches the status of a transaction, nil if it is unknown.
+func (s *SolanaIntegrationService) signatureStatus(sig solana.Signature) (*rpc.SignatureStatusesResult, error) {
+	out, err := s.RPCClient.GetSignatureStatuses(context.Background(), true, sig)
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get status of %s: %w", sig, err)
+	}
+	if len(out.Value) == 0 || out.Value[0] == nil {
+		return nil, nil
+	}
+	status := out.Value[0]
+	if status.Err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrTransactionFailed, sig, status.Err)
+	}
+	return status, nil
+}
+
+// SendMemo records `message` on chain with the SPL Memo program in a
+// transaction signed by the FeePayer, anchoring it with a verifiable timestamp.
+func (s *SolanaIntegrationService) SendMemo(message string) (solana.Signature, error) {
+	memoIx := newMemoInstruction(message, s.FeePayer.PublicKey())
+	sig, err := s.sendBackendTransaction([]solana.Instruction{memoIx}, "memo")
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	log.Printf("Memo anchored | TxID: %s", sig)
+
+	return sig, nil
+}
+
+// SignMemo builds and signs, but does not send, a memo transaction like
+// SendMemo. The signature is returned so callers can record it before
+// sending and never anchor twice.
+func (s *SolanaIntegrationService) SignMemo(message string) (string, solana.Signature, error) {
+	memoIx := newMemoInstruction(message, s.FeePayer.PublicKey())
+	return s.serializeBackendTransaction([]solana.Instruction{memoIx}, "memo")
+}
+
+// GetTransactionMemos returns the SPL Memo messages of a confirmed
+// transaction. The transaction must have succeeded.
+func (s *SolanaIntegrationService) GetTransactionMemos(sig solana.Signature) ([]string, error) {
+	maxVersion := uint64(0)
+	txResp, err := s.RPCClient.GetTransaction(context.Background(), sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", sig, err)
+	}
+	if txResp.Meta != nil && txResp.Meta.Err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrTransactionFailed, sig, txResp.Meta.Err)
+	}
+	tx, err := txResp.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction %s: %w", sig, err)
+	}
+
+	var memos []string
+	for _, ix := range tx.Message.Instructions {
+		program, err := tx.Message.Program(ix.ProgramIDIndex)
+		if err == nil && program.Equals(solana.MemoProgramID) {
+			memos = append(memos, string(ix.Data))
+		}
+	}
+	return memos, nil
+}
+
+// SignMintToAccounts builds and signs, but does not send, a transaction
+// minting `mintAddress` tokens to every recipient, creating recipient ATAs as
+// needed. The FeePayer must be the Mint Authority. Like SignTokenTransfers,
+// the signature is returned so callers can record it before sending.
+func (s *SolanaIntegrationService) SignMintToAccounts(
+	mintAddress solana.PublicKey, mints []TokenTransfer,
+) (string, solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	instructions := make([]solana.Instruction, 0, len(mints)*2)
+	for _, m := range mints {
+		ata, _, err := solana.FindAssociatedTokenAddress(m.Owner, mintAddress)
+		if err != nil {
+			return "", solana.Signature{}, fmt.Errorf("failed to derive ATA for %s: %w", m.Owner, err)
+		}
+		instructions = append(instructions,
+			newCreateIdempotentATAInstruction(feePayerPubKey, m.Owner, mintAddress, ata),
+			token.NewMintToInstruction(m.Amount, mintAddress, ata, feePayerPubKey, []solana.PublicKey{}).Build(),
+		)
+	}
+	return s.serializeBackendTransaction(instructions, "batch mint-to")
+}
+
+// SignBurnFromAccounts builds and signs, but does not send, a transaction
+// burning `mintAddress` tokens from every holder's ATA. Each holder must
+// previously have approved the FeePayer as delegate of their ATA for at least
+// the burned amount.
+func (s *SolanaIntegrationService) SignBurnFromAccounts(
+	mintAddress solana.PublicKey, burns []TokenTransfer,
+) (string, solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	instructions := make([]solana.Instruction, 0, len(burns))
+	for _, b := range burns {
+		ata, _, err := solana.FindAssociatedTokenAddress(b.Owner, mintAddress)
+		if err != nil {
+			return "", solana.Signature{}, fmt.Errorf("failed to derive ATA for %s: %w", b.Owner, err)
+		}
+		instructions = append(instructions,
+			token.NewBurnInstruction(b.Amount, ata, mintAddress, feePayerPubKey, []solana.PublicKey{}).Build(),
+		)
+	}
+	return s.serializeBackendTransaction(instructions, "batch burn")
+}
+
+// PrepareApproveDelegateTransaction builds a transaction in which the holder
+// approves the FeePayer as delegate of their ATA for `amount` atomic units.
+// Like PrepareTransferTransaction, it is returned for the holder to sign.
+func (s *SolanaIntegrationService) PrepareApproveDelegateTransaction(
+	mintAddress, ownerPubKey solana.PublicKey, amount uint64,
+) (string, error) {
+	ata, _, err := solana.FindAssociatedTokenAddress(ownerPubKey, mintAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive ATA: %w", err)
+	}
+	approveIx := token.NewApproveInstruction(amount, ata, s.FeePayer.PublicKey(), ownerPubKey, []solana.PublicKey{}).Build()
+	return s.prepareUserTransaction([]solana.Instruction{approveIx}, "approve")
+}
+
+// SignDelegatedSwap builds and signs, but does not send, the settlement of a
+// trade in a single transaction: `assetAmount` of the asset moves from the
+// seller to the buyer and `quoteAmount` of the quote token from the buyer to
+// the seller, so either both legs land or neither does. Both parties must
+// have approved the FeePayer as delegate of the ATAs they pay from. Like
+// SignTransferFromEscrow it returns the signature first, so callers can
+// record it before sending with SendSignedTransaction.
+func (s *SolanaIntegrationService) SignDelegatedSwap(
+	assetMint, quoteMint, seller, buyer solana.PublicKey, assetAmount, quoteAmount uint64,
+) (string, solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	sellerAssetATA, _, err := solana.FindAssociatedTokenAddress(seller, assetMint)
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to derive seller asset ATA: %w", err)
+	}
+	buyerAssetATA, _, err := solana.FindAssociatedTokenAddress(buyer, assetMint)
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to derive buyer asset ATA: %w", err)
+	}
+	buyerQuoteATA, _, err := solana.FindAssociatedTokenAddress(buyer, quoteMint)
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to derive buyer quote ATA: %w", err)
+	}
+	sellerQuoteATA, _, err := solana.FindAssociatedTokenAddress(seller, quoteMint)
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to derive seller quote ATA: %w", err)
+	}
+
+	instructions := []solana.Instruction{
+		newCreateIdempotentATAInstruction(feePayerPubKey, buyer, assetMint, buyerAssetATA),
+		newCreateIdempotentATAInstruction(feePayerPubKey, seller, quoteMint, sellerQuoteATA),
+		token.NewTransferInstruction(assetAmount, sellerAssetATA, buyerAssetATA, feePayerPubKey, []solana.PublicKey{}).Build(),
+		token.NewTransferInstruction(quoteAmount, buyerQuoteATA, sellerQuoteATA, feePayerPubKey, []solana.PublicKey{}).Build(),
+	}
+
+	tx, err := s.signBackendTransaction(instructions, "swap")
+	if err != nil {
+		return "", solana.Signature{}, err
+	}
+
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to serialize swap: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), tx.Signatures[0], nil
+}
+
+// PrepareDvPTransaction builds a delivery-versus-payment transaction:
+// `assetAmount` of the asset moves from the seller to the buyer and
+// `paymentAmount` of the payment token from the buyer to the seller. Each
+// party signs its own leg, and since both legs share one transaction, neither
+// settles without the other. It is returned signed by the FeePayer only.
+func (s *SolanaIntegrationService) PrepareDvPTransaction(
+	assetMint, paymentMint, seller, buyer solana.PublicKey, assetAmount, paymentAmount uint64,
+) (string, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	sellerAssetATA, _, err := solana.FindAssociatedTokenAddress(seller, assetMint)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive seller asset ATA: %w", err)
+	}
+	buyerAssetATA, _, err := solana.FindAssociatedTokenAddress(buyer, assetMint)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive buyer asset ATA: %w", err)
+	}
+	buyerPaymentATA, _, err := solana.FindAssociatedTokenAddress(buyer, paymentMint)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive buyer payment ATA: %w", err)
+	}
+	sellerPaymentATA, _, err := solana.FindAssociatedTokenAddress(seller, paymentMint)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive seller payment ATA: %w", err)
+	}
+
+	instructions := []solana.Instruction{
+		newCreateIdempotentATAInstruction(feePayerPubKey, buyer, assetMint, buyerAssetATA),
+		newCreateIdempotentATAInstruction(feePayerPubKey, seller, paymentMint, sellerPaymentATA),
+		token.NewTransferInstruction(assetAmount, sellerAssetATA, buyerAssetATA, seller, []solana.PublicKey{}).Build(),
+		token.NewTransferInstruction(paymentAmount, buyerPaymentATA, sellerPaymentATA, buyer, []solana.PublicKey{}).Build(),
+	}
+	return s.prepareUserTransaction(instructions, "DvP")
+}
+
+// EscrowAccountAddress derives the address of an escrow's token account from
+// the FeePayer and the escrow's seed (at most 32 characters).
+func (s *SolanaIntegrationService) EscrowAccountAddress(seed string) (solana.PublicKey, error) {
+	return solana.CreateWithSeed(s.FeePayer.PublicKey(), seed, solana.TokenProgramID)
+}
+
+// CreateEscrowAccount creates the token account of an escrow at its seed
+// address, with the FeePayer as its owner so only the backend can move the
+// tokens out. It does nothing if the account already exists.
+func (s *SolanaIntegrationService) CreateEscrowAccount(seed string, mintAddress solana.PublicKey) (solana.PublicKey, error) {
+	ctx := context.Background()
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	escrowAccount, err := s.EscrowAccountAddress(seed)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive escrow account: %w", err)
+	}
+	if _, err := s.RPCClient.GetAccountInfo(ctx, escrowAccount); err == nil {
+		return escrowAccount, nil // Already exists
+	}
+
+	tokenAccountSize := uint64(165) // SPL token account size in bytes
+	rentExemption, err := s.RPCClient.GetMinimumBalanceForRentExemption(ctx, tokenAccountSize, rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to get rent exemption: %w", err)
+	}
+
+	instructions := []solana.Instruction{
+		system.NewCreateAccountWithSeedInstruction(
+			feePayerPubKey, seed, rentExemption, tokenAccountSize, solana.TokenProgramID,
+			feePayerPubKey, escrowAccount, feePayerPubKey,
+		).Build(),
+		token.NewInitializeAccount3Instruction(feePayerPubKey, escrowAccount, mintAddress).Build(),
+	}
+	sig, err := s.sendBackendTransaction(instructions, "create escrow account")
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	confirmed, err := s.WaitForConfirmation(sig, 60*time.Second)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("escrow account creation failed: %w", err)
+	}
+	if !confirmed {
+		return solana.PublicKey{}, fmt.Errorf("escrow account creation %s was not confirmed in time", sig)
+	}
+	log.Printf("Created escrow account %s | TxID: %s", escrowAccount, sig)
+
+	return escrowAccount, nil
+}
+
+// SendFromEscrow moves `amount` tokens out of an escrow account to the
+// recipient's ATA, creating it if needed. When `closeAccount` is set the
+// emptied escrow account is closed and its rent returned to the FeePayer.
+func (s *SolanaIntegrationService) SendFromEscrow(
+	mintAddress, escrowAccount, recipient solana.PublicKey, amount uint64, closeAccount bool,
+) (solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	recipientATA, _, err := solana.FindAssociatedTokenAddress(recipient, mintAddress)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to derive recipient ATA: %w", err)
+	}
+	instructions := []solana.Instruction{
+		newCreateIdempotentATAInstruction(feePayerPubKey, recipient, mintAddress, recipientATA),
+		token.NewTransferInstruction(amount, escrowAccount, recipientATA, feePayerPubKey, []solana.PublicKey{}).Build(),
+	}
+	if closeAccount {
+		instructions = append(instructions,
+			token.NewCloseAccountInstruction(escrowAccount, feePayerPubKey, feePayerPubKey, []solana.PublicKey{}).Build(),
+		)
+	}
+
+	sig, err := s.sendBackendTransaction(instructions, "escrow transfer")
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	log.Printf("Sent %d tokens from escrow %s to %s | TxID: %s", amount, escrowAccount, recipient, sig)
+
+	return sig, nil
+}
+
+// SignTransferFromEscrow builds and signs, but does not send, a transfer of
+// `amount` tokens out of an escrow account to the recipient's ATA. The
+// signature is known before the transaction is sent with
+// SendSignedTransaction, so callers can record it first and never send a
+// transfer twice.
+func (s *SolanaIntegrationService) SignTransferFromEscrow(
+	mintAddress, escrowAccount, recipient solana.PublicKey, amount uint64,
+) (string, solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	recipientATA, _, err := solana.FindAssociatedTokenAddress(recipient, mintAddress)
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to derive recipient ATA: %w", err)
+	}
+	tx, err := s.signBackendTransaction([]solana.Instruction{
+		newCreateIdempotentATAInstruction(feePayerPubKey, recipient, mintAddress, recipientATA),
+		token.NewTransferInstruction(amount, escrowAccount, recipientATA, feePayerPubKey, []solana.PublicKey{}).Build(),
+	}, "escrow transfer")
+	if err != nil {
+		return "", solana.Signature{}, err
+	}
+
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to serialize escrow transfer: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), tx.Signatures[0], nil
+}
+
+// TokenDeposit is a finalized transfer into a token account.
+type TokenDeposit struct {
+	Signature solana.Signature
+	Slot      uint64
+	BlockTime time.Time
+	Sender    solana.PublicKey // Owner of the debited token account
+	Amount    uint64
+	Memo      string // SPL Memo attached to the transaction, if any
+}
+
+// GetTokenDeposits returns the finalized transfers into `tokenAccount` made
+// after the transaction `until` (all of them when it is zero), oldest first,
+// along with the newest transaction seen, to resume from next time.
+func (s *SolanaIntegrationService) GetTokenDeposits(tokenAccount solana.PublicKey, until solana.Signature) ([]TokenDeposit, solana.Signature, error) {
+	ctx := context.Background()
+
+	// Signatures come newest first, a page at a time
+	var signatures []*rpc.TransactionSignature
+	limit := 1000
+	var before solana.Signature
+	for {
+		page, err := s.RPCClient.GetSignaturesForAddressWithOpts(ctx, tokenAccount, &rpc.GetSignaturesForAddressOpts{
+			Limit:      &limit,
+			Before:     before,
+			Until:      until,
+			Commitment: rpc.CommitmentFinalized,
+		})
+		if err != nil {
+			return nil, until, fmt.Errorf("failed to list transactions of %s: %w", tokenAccount, err)
+		}
+		signatures = append(signatures, page...)
+		if len(page) < limit {
+			break
+		}
+		before = page[len(page)-1].Signature
+	}
+	if len(signatures) == 0 {
+		return nil, until, nil
+	}
+
+	maxVersion := uint64(0)
+	deposits := make([]TokenDeposit, 0, len(signatures))
+	for i := len(signatures) - 1; i >= 0; i-- {
+		if signatures[i].Err != nil {
+			continue
+		}
+		sig := signatures[i].Signature
+		txResp, err := s.RPCClient.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+			Encoding:                       solana.EncodingBase64,
+			Commitment:                     rpc.CommitmentFinalized,
+			MaxSupportedTransactionVersion: &maxVersion,
+		})
+		if err != nil {
+			return nil, until, fmt.Errorf("failed to get transaction %s: %w", sig, err)
+		}
+		if txResp.Meta == nil || txResp.Transaction == nil {
+			continue
+		}
+		tx, err := txResp.Transaction.GetTransaction()
+		if err != nil {
+			return nil, until, fmt.Errorf("failed to decode transaction %s: %w", sig, err)
+		}
+
+		// Balances are indexed over the static keys followed by the loaded ones
+		keys := append(append(append(solana.PublicKeySlice{}, tx.Message.AccountKeys...),
+			txResp.Meta.LoadedAddresses.Writable...), txResp.Meta.LoadedAddresses.ReadOnly...)
+		changes := make(map[uint16]int64)
+		owners := make(map[uint16]solana.PublicKey)
+		for _, b := range txResp.Meta.PreTokenBalances {
+			amount, _ := strconv.ParseInt(b.UiTokenAmount.Amount, 10, 64)
+			changes[b.AccountIndex] -= amount
+		}
+		for _, b := range txResp.Meta.PostTokenBalances {
+			amount, _ := strconv.ParseInt(b.UiTokenAmount.Amount, 10, 64)
+			changes[b.AccountIndex] += amount
+			if b.Owner != nil {
+				owners[b.AccountIndex] = *b.Owner
+			}
+		}
+
+		deposit := TokenDeposit{Signature: sig, Slot: txResp.Slot, BlockTime: time.Now()}
+		if txResp.BlockTime != nil {
+			deposit.BlockTime = txResp.BlockTime.Time()
+		}
+		for idx, change := range changes {
+			if int(idx) >= len(keys) {
+				continue
+			}
+			if keys[idx].Equals(tokenAccount) && change > 0 {
+				deposit.Amount = uint64(change)
+			} else if change < 0 {
+				deposit.Sender = owners[idx]
+			}
+		}
+		if deposit.Amount == 0 {
+			continue // Not a deposit, e.g. a withdrawal by the FeePayer
+		}
+		for _, ix := range tx.Message.Instructions {
+			program, err := tx.Message.Program(ix.ProgramIDIndex)
+			if err == nil && program.Equals(solana.MemoProgramID) {
+				deposit.Memo = string(ix.Data)
+			}
+		}
+		deposits = append(deposits, deposit)
+	}
+	return deposits, signatures[0].Signature, nil
+}
+
+// ErrTransactionMismatch is returned when a signed transaction is not the one
+// that was prepared, or lacks a valid signature of the expected signer.
+var ErrTransactionMismatch = errors.New("signed transaction does not match the prepared one")
+
+// MergeSignature copies `signer`'s signature from a copy of a prepared
+// transaction into the prepared one, so signatures collected separately from
+// several parties add up. Both must carry the same message.
+func (s *SolanaIntegrationService) MergeSignature(preparedBase64, signedBase64 string, signer solana.PublicKey) (string, error) {
+	prepared, err := solana.TransactionFromBase64(preparedBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode prepared transaction: %w", err)
+	}
+	signed, err := solana.TransactionFromBase64(signedBase64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTransactionMismatch, err)
+	}
+
+	message, err := prepared.Message.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode prepared message: %w", err)
+	}
+	signedMessage, err := signed.Message.MarshalBinary()
+	if err != nil || string(signedMessage) != string(message) {
+		return "", ErrTransactionMismatch
+	}
+
+	signers := prepared.Message.AccountKeys[:prepared.Message.Header.NumRequiredSignatures]
+	for i, key := range signers {
+		if !key.Equals(signer) {
+			continue
+		}
+		if i >= len(signed.Signatures) || !signed.Signatures[i].Verify(signer, message) {
+			return "", fmt.Errorf("%w: missing or invalid signature of %s", ErrTransactionMismatch, signer)
+		}
+		prepared.Signatures[i] = signed.Signatures[i]
+
+		serializedTx, err := prepared.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize transaction: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(serializedTx), nil
+	}
+	return "", fmt.Errorf("%w: %s is not a signer", ErrTransactionMismatch, signer)
+}
+
+// signBackendTransaction builds a transaction paid and signed only by the
+// FeePayer, without sending it.
+func (s *SolanaIntegrationService) signBackendTransaction(instructions []solana.Instruction, label string) (*solana.Transaction, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	resp, err := s.RPCClient.GetRecentBlockhash(context.Background(), rpc.CommitmentFinalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockhash: %w", err)
+	}
+
+	tx, err := solana.NewTransaction(s.referenced(instructions), resp.Value.Blockhash, solana.TransactionPayer(feePayerPubKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s transaction: %w", label, err)
+	}
+
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(feePayerPubKey) {
+			return &s.FeePayer
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s transaction: %w", label, err)
+	}
+	return tx, nil
+}
+
+// serializeBackendTransaction signs a backend transaction and returns it
+// base64-encoded for SendSignedTransaction, along with its signature.
+func (s *SolanaIntegrationService) serializeBackendTransaction(instructions []solana.Instruction, label string) (string, solana.Signature, error) {
+	tx, err := s.signBackendTransaction(instructions, label)
+	if err != nil {
+		return "", solana.Signature{}, err
+	}
+
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to serialize %s: %w", label, err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), tx.Signatures[0], nil
+}
+
+// sendBackendTransaction builds a transaction paid and signed only by the
+// FeePayer, and sends it.
+func (s *SolanaIntegrationService) sendBackendTransaction(instructions []solana.Instruction, label string) (solana.Signature, error) {
+	tx, err := s.signBackendTransaction(instructions, label)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+
+	sig, err := s.RPCClient.SendTransactionWithOpts(context.Background(), tx, rpc.TransactionOpts{
+		SkipPreflight:       false,
+		PreflightCommitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to send %s transaction: %w", label, err)
+	}
+	return sig, nil
+}
+
+// prepareUserTransaction builds a transaction paid by the FeePayer, signs it
+// with the FeePayer only and returns it in Base64 for the remaining signers.
+func (s *SolanaIntegrationService) prepareUserTransaction(instructions []solana.Instruction, label string) (string, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	resp, err := s.RPCClient.GetRecentBlockhash(context.Background(), rpc.CommitmentFinalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to get blockhash: %w", err)
+	}
+
+	tx, err := solana.NewTransaction(s.referenced(instructions), resp.Value.Blockhash, solana.TransactionPayer(feePayerPubKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to build %s transaction: %w", label, err)
+	}
+
+	_, err = tx.PartialSign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(feePayerPubKey) {
+			return &s.FeePayer
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s transaction by FeePayer: %w", label, err)
+	}
+
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize %s transaction: %w", label, err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), nil
+}
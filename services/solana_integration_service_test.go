@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+)
+
+func TestReferenced(t *testing.T) {
+	feePayer := solana.NewWallet().PrivateKey
+	s := &SolanaIntegrationService{FeePayer: feePayer}
+	instructions := make([]solana.Instruction, 1, 2) // Spare capacity the memo must not be written into
+	instructions[0] = system.NewTransferInstruction(1, feePayer.PublicKey(), solana.NewWallet().PublicKey()).Build()
+
+	if got := s.referenced(instructions); len(got) != 1 {
+		t.Fatalf("referenced() without a reference has %d instructions, want 1", len(got))
+	}
+
+	ref := models.TxReference{Type: models.ReferenceTransferIntent, ID: "i1"}
+	got := s.WithReference(ref).referenced(instructions)
+	if len(got) != 2 || got[0] != instructions[0] {
+		t.Fatalf("referenced() = %d instructions, want the transfer followed by the memo", len(got))
+	}
+	if s.reference != nil {
+		t.Fatal("WithReference() changed the original service")
+	}
+	if spare := instructions[:2][1]; spare != nil {
+		t.Fatal("referenced() wrote into the caller's slice")
+	}
+
+	memoIx := got[1]
+	if !memoIx.ProgramID().Equals(solana.MemoProgramID) {
+		t.Fatalf("memo program = %s, want %s", memoIx.ProgramID(), solana.MemoProgramID)
+	}
+	data, err := memoIx.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "tiquin:ref:intent:i1" {
+		t.Fatalf("memo = %q, want the raw reference", data)
+	}
+	if accounts := memoIx.Accounts(); len(accounts) != 1 || !accounts[0].PublicKey.Equals(feePayer.PublicKey()) || !accounts[0].IsSigner {
+		t.Fatal("memo is not signed by the FeePayer")
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+// defaultRateTables apply, by regime, when no rate table is configured. Each
+// holds the rules of its regime only: IRRF of 0.005% on exchange sales, the
+// R$20,000 monthly exemption for share sales by individuals and the 15% rate
+// on exchange gains, or the progressive capital gains rates of Law
+// 13.259/2016.
+var defaultRateTables = map[string]models.TaxRateTable{
+	models.TaxRegimeExchange: {
+		Name:             "Built-in default (exchange)",
+		CostMethod:       models.CostMethodAverage,
+		WithholdingRate:  0.00005,
+		MonthlyExemption: 20000,
+		GainBrackets:     models.GainBrackets{{Rate: 0.15}},
+	},
+	models.TaxRegimeCapitalGains: {
+		Name:       "Built-in default (Law 13.259/2016)",
+		CostMethod: models.CostMethodAverage,
+		GainBrackets: models.GainBrackets{
+			{UpTo: ptr(5_000_000.0), Rate: 0.15},
+			{UpTo: ptr(10_000_000.0), Rate: 0.175},
+			{UpTo: ptr(30_000_000.0), Rate: 0.20},
+			{Rate: 0.225},
+		},
+	},
+}
+
+// IsTaxRegime reports whether v is a tax regime with a built-in rate table.
+func IsTaxRegime(v string) bool {
+	_, ok := defaultRateTables[v]
+	return ok
+}
+
+// TaxService tracks cost basis, computes realized gains and withholding on
+// disposals, and builds monthly income tax reports.
+type TaxService struct {
+	DB *storage.DB
+	// Regime selects the built-in rate table applied while no rate table is
+	// configured. Tokens are not exchange-listed, so it defaults to capital gains.
+	Regime string
+}
+
+func NewTaxService(db *storage.DB) *TaxService {
+	return &TaxService{DB: db, Regime: models.TaxRegimeCapitalGains}
+}
+
+// CreateRateTableInput describes a new rate table.
+type CreateRateTableInput struct {
+	Name             string              `json:"name"`
+	EffectiveFrom    time.Time           `json:"effective_from"`
+	CostMethod       string              `json:"cost_method"`
+	WithholdingRate  float64             `json:"withholding_rate"`
+	MonthlyExemption float64             `json:"monthly_exemption"`
+	GainBrackets     models.GainBrackets `json:"gain_brackets"`
+}
+
+// CreateRateTable validates and stores a rate table.
+func (s *TaxService) CreateRateTable(in CreateRateTableInput) (models.TaxRateTable, error) {
+	if in.Name == "" || in.EffectiveFrom.IsZero() {
+		return models.TaxRateTable{}, invalidf("name and effective_from are required")
+	}
+	if in.CostMethod != models.CostMethodAverage && in.CostMethod != models.CostMethodFIFO {
+		return models.TaxRateTable{}, invalidf("cost_method must be %s or %s", models.CostMethodAverage, models.CostMethodFIFO)
+	}
+	if in.WithholdingRate < 0 || in.WithholdingRate >= 1 || in.MonthlyExemption < 0 {
+		return models.TaxRateTable{}, invalidf("withholding_rate must be in [0, 1) and monthly_exemption cannot be negative")
+	}
+	if len(in.GainBrackets) == 0 {
+		return models.TaxRateTable{}, invalidf("at least one gain bracket is required")
+	}
+	prev := 0.0
+	for i, b := range in.GainBrackets {
+		if b.Rate < 0 || b.Rate >= 1 {
+			return models.TaxRateTable{}, invalidf("gain bracket %d: rate must be in [0, 1)", i+1)
+		}
+		last := i == len(in.GainBrackets)-1
+		if last != (b.UpTo == nil) {
+			return models.TaxRateTable{}, invalidf("only the last gain bracket must omit up_to")
+		}
+		if b.UpTo != nil {
+			if *b.UpTo <= prev {
+				return models.TaxRateTable{}, invalidf("gain bracket %d: up_to must increase", i+1)
+			}
+			prev = *b.UpTo
+		}
+	}
+
+	table := models.TaxRateTable{
+		ID:               uuid.New().String(),
+		Name:             in.Name,
+		EffectiveFrom:    in.EffectiveFrom,
+		CostMethod:       in.CostMethod,
+		WithholdingRate:  in.WithholdingRate,
+		MonthlyExemption: in.MonthlyExemption,
+		GainBrackets:     in.GainBrackets,
+		CreatedAt:        time.Now(),
+	}
+	if err := s.DB.SaveTaxRateTable(table); err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.TaxRateTable{}, fmt.Errorf("%w: a rate table is already effective from %s", ErrConflict, in.EffectiveFrom.Format(time.RFC3339))
+		}
+		return models.TaxRateTable{}, fmt.Errorf("failed to save rate table: %w", err)
+	}
+	return table, nil
+}
+
+// rateTableAt returns the rate table in force at t, or the built-in default
+// of the configured regime.
+func (s *TaxService) rateTableAt(t time.Time) (models.TaxRateTable, error) {
+	table, found, err := s.DB.GetTaxRateTableAt(t)
+	if err != nil {
+		return models.TaxRateTable{}, fmt.Errorf("error fetching rate table: %w", err)
+	}
+	if !found {
+		return defaultRateTables[s.Regime], nil
+	}
+	return table, nil
+}
+
+// AddLotInput registers an acquisition made outside the platform, such as a
+// subscription or an exchange purchase.
+type AddLotInput struct {
+	OwnerID    string    `json:"-"`
+	AssetID    string    `json:"asset_id"`
+	Quantity   float64   `json:"quantity"`
+	TotalCost  float64   `json:"total_cost"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// AddLot stores a manually declared acquisition lot.
+func (s *TaxService) AddLot(in AddLotInput) (models.TaxLot, error) {
+	if in.Quantity <= 0 || in.TotalCost < 0 || in.AcquiredAt.IsZero() {
+		return models.TaxLot{}, invalidf("quantity must be positive, total_cost non-negative and acquired_at set")
+	}
+	if _, found, err := s.DB.GetAsset(in.AssetID); err != nil {
+		return models.TaxLot{}, fmt.Errorf("error fetching asset: %w", err)
+	} else if !found {
+		return models.TaxLot{}, ErrAssetNotFound
+	}
+	if _, found, err := s.DB.GetUser(in.OwnerID); err != nil {
+		return models.TaxLot{}, fmt.Errorf("error fetching user: %w", err)
+	} else if !found {
+		return models.TaxLot{}, ErrUserNotFound
+	}
+
+	lot := models.TaxLot{
+		ID:                uuid.New().String(),
+		AssetID:           in.AssetID,
+		OwnerID:           in.OwnerID,
+		Quantity:          in.Quantity,
+		RemainingQuantity: in.Quantity,
+		TotalCost:         in.TotalCost,
+		RemainingCost:     in.TotalCost,
+		AcquiredAt:        in.AcquiredAt,
+		Source:            "manual",
+		CreatedAt:         time.Now(),
+	}
+	if err := s.DB.SaveTaxLot(lot); err != nil {
+		return models.TaxLot{}, fmt.Errorf("failed to save lot: %w", err)
+	}
+	return lot, nil
+}
+
+// RecordAcquisition opens a lot for shares acquired from the issuer, e.g. in
+// a primary offering, at their subscription cost.
+func (s *TaxService) RecordAcquisition(assetID, ownerID string, quantity, cost float64, source, txID string, at time.Time) error {
+	lot := models.TaxLot{
+		ID:                uuid.New().String(),
+		AssetID:           assetID,
+		OwnerID:           ownerID,
+		Quantity:          quantity,
+		RemainingQuantity: quantity,
+		TotalCost:         cost,
+		RemainingCost:     cost,
+		AcquiredAt:        at,
+		Source:            source,
+		TransactionID:     &txID,
+		CreatedAt:         time.Now(),
+	}
+	if err := s.DB.SaveTaxLot(lot); err != nil {
+		return fmt.Errorf("failed to save lot: %w", err)
+	}
+	return nil
+}
+
+// RecordTransfer books the tax effects of a completed transfer. With a price
+// per unit it is a sale: the sender realizes a gain or loss and has IRRF
+// withheld, and the recipient acquires at the price. Without a price, the
+// sender's cost basis carries over to the recipient.
+func (s *TaxService) RecordTransfer(assetID, fromUserID, toUserID string, quantity float64, pricePerUnit *float64, txID string, at time.Time) error {
+	table, err := s.rateTableAt(at)
+	if err != nil {
+		return err
+	}
+
+	var disposal *models.Disposal
+	if pricePerUnit != nil {
+		proceeds := models.RoundCents(quantity * *pricePerUnit)
+		disposal = &models.Disposal{
+			ID:                uuid.New().String(),
+			AssetID:           assetID,
+			OwnerID:           fromUserID,
+			Quantity:          quantity,
+			Proceeds:          proceeds,
+			CostMethod:        table.CostMethod,
+			WithholdingRate:   table.WithholdingRate,
+			WithholdingAmount: models.RoundCents(proceeds * table.WithholdingRate),
+			TransactionID:     txID,
+			DisposedAt:        at,
+		}
+		if table.ID != "" {
+			disposal.RateTableID = &table.ID
+		}
+	}
+
+	if err := s.DB.RecordTaxMovement(assetID, fromUserID, toUserID, quantity, table.CostMethod, disposal, txID, at); err != nil {
+		return fmt.Errorf("failed to record tax movement: %w", err)
+	}
+	return nil
+}
+
+// GetMonthlyReport builds the income tax report of a user for a month
+// ("YYYY-MM"), carrying forward net losses of earlier taxable months.
+func (s *TaxService) GetMonthlyReport(userID, month string) (models.MonthlyTaxReport, error) {
+	start, err := time.ParseInLocation("2006-01", month, brazilLocation())
+	if err != nil {
+		return models.MonthlyTaxReport{}, invalidf("month must be formatted as YYYY-MM")
+	}
+	end := start.AddDate(0, 1, 0)
+
+	if _, found, err := s.DB.GetUser(userID); err != nil {
+		return models.MonthlyTaxReport{}, fmt.Errorf("error fetching user: %w", err)
+	} else if !found {
+		return models.MonthlyTaxReport{}, ErrUserNotFound
+	}
+	disposals, err := s.DB.GetDisposalsByOwnerID(userID, end)
+	if err != nil {
+		return models.MonthlyTaxReport{}, fmt.Errorf("error fetching disposals: %w", err)
+	}
+
+	// Group by month, in order, to carry losses forward into the requested month
+	var months []string
+	byMonth := make(map[string][]models.Disposal)
+	for _, d := range disposals {
+		key := d.DisposedAt.In(brazilLocation()).Format("2006-01")
+		if _, ok := byMonth[key]; !ok {
+			months = append(months, key)
+		}
+		byMonth[key] = append(byMonth[key], d)
+	}
+	if _, ok := byMonth[month]; !ok {
+		months = append(months, month)
+	}
+
+	var carryforward float64
+	var report models.MonthlyTaxReport
+	for _, m := range months {
+		monthStart, _ := time.ParseInLocation("2006-01", m, brazilLocation())
+		table, err := s.rateTableAt(monthStart.AddDate(0, 1, 0).Add(-time.Nanosecond))
+		if err != nil {
+			return models.MonthlyTaxReport{}, err
+		}
+		report = monthlyReport(userID, m, byMonth[m], table, carryforward)
+		carryforward = report.LossCarryforwardOut
+	}
+	return report, nil
+}
+
+// monthlyReport computes the tax of one month given the losses carried in.
+func monthlyReport(userID, month string, disposals []models.Disposal, table models.TaxRateTable, carryIn float64) models.MonthlyTaxReport {
+	report := models.MonthlyTaxReport{
+		UserID:             userID,
+		Month:              month,
+		LossCarryforwardIn: carryIn,
+		Disposals:          disposals,
+	}
+	if report.Disposals == nil {
+		report.Disposals = []models.Disposal{}
+	}
+	for _, d := range disposals {
+		report.TotalSales += d.Proceeds
+		report.WithheldAtSource += d.WithholdingAmount
+		if d.Gain >= 0 {
+			report.RealizedGains += d.Gain
+		} else {
+			report.RealizedLosses += -d.Gain
+		}
+	}
+	report.NetResult = models.RoundCents(report.RealizedGains - report.RealizedLosses)
+
+	// Exempt months owe nothing and neither use nor add to carried losses
+	report.Exempt = table.MonthlyExemption > 0 && report.TotalSales <= table.MonthlyExemption
+	report.LossCarryforwardOut = carryIn
+	if !report.Exempt {
+		net := report.NetResult - carryIn
+		if net > 0 {
+			report.TaxableGain = models.RoundCents(net)
+			report.LossCarryforwardOut = 0
+		} else {
+			report.LossCarryforwardOut = models.RoundCents(-net)
+		}
+		report.TaxDue = progressiveTax(report.TaxableGain, table.GainBrackets)
+	}
+	report.NetTaxPayable = models.RoundCents(math.Max(report.TaxDue-report.WithheldAtSource, 0))
+	report.TotalSales = models.RoundCents(report.TotalSales)
+	report.RealizedGains = models.RoundCents(report.RealizedGains)
+	report.RealizedLosses = models.RoundCents(report.RealizedLosses)
+	report.WithheldAtSource = models.RoundCents(report.WithheldAtSource)
+	return report
+}
+
+// progressiveTax applies the gain brackets to a taxable gain.
+func progressiveTax(gain float64, brackets models.GainBrackets) float64 {
+	var tax, lower float64
+	for _, b := range brackets {
+		upper := math.Inf(1)
+		if b.UpTo != nil {
+			upper = *b.UpTo
+		}
+		if gain <= lower {
+			break
+		}
+		tax += (math.Min(gain, upper) - lower) * b.Rate
+		lower = upper
+	}
+	return models.RoundCents(tax)
+}
+
+// brazilLocation is the time zone tax months are counted in.
+func brazilLocation() *time.Location {
+	loc, err := time.LoadLocation(defaultTradingTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestProgressiveTax(t *testing.T) {
+	brackets := defaultRateTables[models.TaxRegimeCapitalGains].GainBrackets
+	tests := []struct {
+		gain, want float64
+	}{
+		{0, 0},
+		{-100, 0},
+		{1000, 150},
+		{5_000_000, 750_000},
+		{6_000_000, 750_000 + 175_000},
+		{40_000_000, 750_000 + 875_000 + 4_000_000 + 2_250_000},
+		{100.01, 15}, // 15.0015, rounded to cents
+	}
+	for _, tt := range tests {
+		if got := progressiveTax(tt.gain, brackets); got != tt.want {
+			t.Errorf("progressiveTax(%v) = %v, want %v", tt.gain, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRateTablesKeepRegimesApart(t *testing.T) {
+	capital := defaultRateTables[models.TaxRegimeCapitalGains]
+	if capital.WithholdingRate != 0 || capital.MonthlyExemption != 0 {
+		t.Errorf("capital gains table withholds %v and exempts %v, want neither", capital.WithholdingRate, capital.MonthlyExemption)
+	}
+	exchange := defaultRateTables[models.TaxRegimeExchange]
+	if len(exchange.GainBrackets) != 1 || exchange.GainBrackets[0].UpTo != nil {
+		t.Errorf("exchange table has brackets %+v, want a single flat rate", exchange.GainBrackets)
+	}
+	if NewTaxService(nil).Regime != models.TaxRegimeCapitalGains {
+		t.Errorf("default regime is not capital gains")
+	}
+}
+
+func TestMonthlyReport(t *testing.T) {
+	exchange := defaultRateTables[models.TaxRegimeExchange]
+	capital := defaultRateTables[models.TaxRegimeCapitalGains]
+	sale := func(proceeds, gain, withheld float64) models.Disposal {
+		return models.Disposal{Proceeds: proceeds, Gain: gain, WithholdingAmount: withheld}
+	}
+
+	tests := []struct {
+		name      string
+		table     models.TaxRateTable
+		disposals []models.Disposal
+		carryIn   float64
+		want      models.MonthlyTaxReport
+	}{
+		{
+			name:      "sales under the exchange exemption",
+			table:     exchange,
+			disposals: []models.Disposal{sale(15_000, 3_000, 0.75)},
+			carryIn:   500,
+			want: models.MonthlyTaxReport{
+				TotalSales: 15_000, RealizedGains: 3_000, NetResult: 3_000, Exempt: true, LossCarryforwardIn: 500,
+				WithheldAtSource: 0.75, LossCarryforwardOut: 500,
+			},
+		},
+		{
+			name:      "exchange gain net of carried losses and withholding",
+			table:     exchange,
+			disposals: []models.Disposal{sale(30_000, 5_000, 1.5), sale(10_000, -1_000, 0.5)},
+			carryIn:   1_000,
+			want: models.MonthlyTaxReport{
+				TotalSales: 40_000, RealizedGains: 5_000, RealizedLosses: 1_000, NetResult: 4_000, LossCarryforwardIn: 1_000,
+				TaxableGain: 3_000, TaxDue: 450, WithheldAtSource: 2, NetTaxPayable: 448,
+			},
+		},
+		{
+			name:      "net loss is carried forward",
+			table:     exchange,
+			disposals: []models.Disposal{sale(25_000, -2_000, 1.25)},
+			carryIn:   300,
+			want: models.MonthlyTaxReport{
+				TotalSales: 25_000, RealizedLosses: 2_000, NetResult: -2_000, LossCarryforwardIn: 300,
+				WithheldAtSource: 1.25, LossCarryforwardOut: 2_300,
+			},
+		},
+		{
+			name:      "capital gains have no small sales exemption",
+			table:     capital,
+			disposals: []models.Disposal{sale(15_000, 3_000, 0)},
+			want: models.MonthlyTaxReport{
+				TotalSales: 15_000, RealizedGains: 3_000, NetResult: 3_000, TaxableGain: 3_000, TaxDue: 450, NetTaxPayable: 450,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := monthlyReport("u1", "2025-03", tt.disposals, tt.table, tt.carryIn)
+			got.UserID, got.Month, got.Disposals = "", "", nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("monthlyReport() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// tokenAccountStateFrozen is the state byte of a frozen SPL token account.
+const tokenAccountStateFrozen = 2
+
+// GetTokenAccounts lists every SPL token account of a mint, including empty
+// ones, with whether it is frozen.
+func (s *SolanaIntegrationService) GetTokenAccounts(mintAddress solana.PublicKey) (map[solana.PublicKey]bool, error) {
+	accounts, err := s.RPCClient.GetProgramAccountsWithOpts(context.Background(), solana.TokenProgramID, &rpc.GetProgramAccountsOpts{
+		Commitment: rpc.CommitmentConfirmed,
+		Filters: []rpc.RPCFilter{
+			{DataSize: 165}, // SPL token account size in bytes
+			{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: solana.Base58(mintAddress.Bytes())}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token accounts for %s: %w", mintAddress, err)
+	}
+
+	// Token account layout: mint (32) | owner (32) | amount (8) | delegate (36) | state (1) | ...
+	frozen := make(map[solana.PublicKey]bool, len(accounts))
+	for _, acc := range accounts {
+		if acc == nil || acc.Account == nil {
+			continue
+		}
+		data := acc.Account.Data.GetBinary()
+		if len(data) < 109 {
+			continue
+		}
+		frozen[acc.Pubkey] = data[108] == tokenAccountStateFrozen
+	}
+	return frozen, nil
+}
+
+// SetTokenAccountsFrozen freezes, or thaws, the token accounts of a mint
+// whose freeze authority is the FeePayer. The instructions are packed into
+// as few transactions as fit, and it returns once all are confirmed.
+// Freezing a frozen account fails, so callers pass only accounts in the
+// other state.
+func (s *SolanaIntegrationService) SetTokenAccountsFrozen(
+	mintAddress solana.PublicKey, accounts []solana.PublicKey, frozen bool,
+) ([]solana.Signature, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+	label := "thaw"
+	if frozen {
+		label = "freeze"
+	}
+
+	var groups [][]solana.Instruction
+	var current []solana.Instruction
+	for _, account := range accounts {
+		var ix solana.Instruction
+		if frozen {
+			ix = token.NewFreezeAccountInstruction(account, mintAddress, feePayerPubKey, []solana.PublicKey{}).Build()
+		} else {
+			ix = token.NewThawAccountInstruction(account, mintAddress, feePayerPubKey, []solana.PublicKey{}).Build()
+		}
+		candidate := append(current[:len(current):len(current)], ix)
+		size, err := s.transactionSize(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxTransactionSize {
+			groups = append(groups, current)
+			candidate = []solana.Instruction{ix}
+		}
+		current = candidate
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	sigs := make([]solana.Signature, 0, len(groups))
+	for _, group := range groups {
+		sig, err := s.sendBackendTransaction(group, label)
+		if err != nil {
+			return sigs, err
+		}
+		sigs = append(sigs, sig)
+	}
+	for _, sig := range sigs {
+		confirmed, err := s.WaitForConfirmation(sig, payoutConfirmationTimeout)
+		if err != nil {
+			return sigs, fmt.Errorf("%s transaction %s: %w", label, sig, err)
+		}
+		if !confirmed {
+			return sigs, fmt.Errorf("%s transaction %s was not confirmed in time", label, sig)
+		}
+	}
+	log.Printf("%d token accounts of %s: %s confirmed in %d transactions", len(accounts), mintAddress, label, len(sigs))
+	return sigs, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// TokenMetadataProgramID is the Metaplex Token Metadata program, which wallets
+// and explorers read to display a mint's name, symbol and image.
+var TokenMetadataProgramID = solana.MustPublicKeyFromBase58("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bmuvcix8")
+
+// Metaplex limits on the on-chain metadata fields, in bytes.
+const (
+	maxMetadataNameLength   = 32
+	maxMetadataSymbolLength = 10
+	maxMetadataURILength    = 200
+)
+
+// FindMetadataAddress derives the metadata account of a mint.
+func FindMetadataAddress(mint solana.PublicKey) (solana.PublicKey, error) {
+	addr, _, err := solana.FindProgramAddress(
+		[][]byte{[]byte("metadata"), TokenMetadataProgramID.Bytes(), mint.Bytes()},
+		TokenMetadataProgramID,
+	)
+	return addr, err
+}
+
+// newCreateMetadataInstruction builds the Token Metadata program's
+// CreateMetadataAccountV3 instruction for a fungible mint. The authority is
+// both mint authority and update authority, and the metadata stays mutable so
+// it can follow changes to the asset. Name and symbol are cut to fit.
+func newCreateMetadataInstruction(
+	metadata, mint, authority solana.PublicKey, name, symbol, uri string,
+) (solana.Instruction, error) {
+	if len(uri) > maxMetadataURILength {
+		return nil, fmt.Errorf("metadata URI is longer than %d bytes", maxMetadataURILength)
+	}
+
+	data := []byte{33} // CreateMetadataAccountV3
+	for _, field := range []string{
+		truncateUTF8(name, maxMetadataNameLength), truncateUTF8(symbol, maxMetadataSymbolLength), uri,
+	} {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(field)))
+		data = append(data, field...)
+	}
+	data = append(data,
+		0, 0, // seller_fee_basis_points
+		0, // creators: None
+		0, // collection: None
+		0, // uses: None
+		1, // is_mutable
+		0, // collection_details: None
+	)
+
+	return solana.NewInstruction(
+		TokenMetadataProgramID,
+		solana.AccountMetaSlice{
+			solana.Meta(metadata).WRITE(),
+			solana.Meta(mint),
+			solana.Meta(authority).SIGNER(),         // mint authority
+			solana.Meta(authority).WRITE().SIGNER(), // payer
+			solana.Meta(authority),                  // update authority
+			solana.Meta(solana.SystemProgramID),
+			solana.Meta(solana.SysVarRentPubkey),
+		},
+		data,
+	), nil
+}
+
+// truncateUTF8 cuts v to at most n bytes without splitting a character.
+func truncateUTF8(v string, n int) string {
+	if len(v) <= n {
+		return v
+	}
+	v = v[:n]
+	for len(v) > 0 && !utf8.ValidString(v) {
+		v = v[:len(v)-1]
+	}
+	return v
+}
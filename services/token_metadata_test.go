@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"PETR4", 10, "PETR4"},
+		{"FARIALIMA35", 10, "FARIALIMA3"},
+		{"Ações Preferenciais Séries Ação", 32, "Ações Preferenciais Séries A"}, // The 32nd byte starts "ç"
+		{"Ações Preferenciais Classe Ação", 32, "Ações Preferenciais Classe Aç"},
+		{"ç", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestNewCreateMetadataInstruction(t *testing.T) {
+	metadata, mint, authority := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	uri := "https://api.tiquin.com.br/assets/a1/metadata.json"
+	borshString := func(v string) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(v))), v...)
+	}
+
+	ix, err := newCreateMetadataInstruction(metadata, mint, authority, "Ações Preferenciais Séries Ação", "FARIALIMA35", uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ix.ProgramID().Equals(TokenMetadataProgramID) {
+		t.Fatalf("program = %s, want %s", ix.ProgramID(), TokenMetadataProgramID)
+	}
+	data, err := ix.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{33}
+	want = append(want, borshString("Ações Preferenciais Séries A")...)
+	want = append(want, borshString("FARIALIMA3")...)
+	want = append(want, borshString(uri)...)
+	want = append(want, 0, 0, 0, 0, 0, 1, 0)
+	if !bytes.Equal(data, want) {
+		t.Fatalf("data =\n%v\nwant\n%v", data, want)
+	}
+
+	wantAccounts := []struct {
+		key              solana.PublicKey
+		signer, writable bool
+	}{
+		{metadata, false, true},
+		{mint, false, false},
+		{authority, true, false}, // Mint authority
+		{authority, true, true},  // Payer
+		{authority, false, false},
+		{solana.SystemProgramID, false, false},
+		{solana.SysVarRentPubkey, false, false},
+	}
+	accounts := ix.Accounts()
+	if len(accounts) != len(wantAccounts) {
+		t.Fatalf("%d accounts, want %d", len(accounts), len(wantAccounts))
+	}
+	for i, want := range wantAccounts {
+		if got := accounts[i]; !got.PublicKey.Equals(want.key) || got.IsSigner != want.signer || got.IsWritable != want.writable {
+			t.Errorf("account %d = %s signer=%v writable=%v, want %s signer=%v writable=%v",
+				i, got.PublicKey, got.IsSigner, got.IsWritable, want.key, want.signer, want.writable)
+		}
+	}
+
+	if _, err := newCreateMetadataInstruction(metadata, mint, authority, "n", "S", uri+strings.Repeat("a", maxMetadataURILength)); err == nil {
+		t.Fatal("newCreateMetadataInstruction() accepted a URI over the limit")
+	}
+}
+
+func TestMetadataURI(t *testing.T) {
+	for base, want := range map[string]string{
+		"https://api.tiquin.com.br":  "https://api.tiquin.com.br/assets/a1/metadata.json",
+		"https://api.tiquin.com.br/": "https://api.tiquin.com.br/assets/a1/metadata.json",
+		"":                           "",
+	} {
+		s := &TokenizationService{MetadataBaseURL: base}
+		if got := s.metadataURI("a1"); got != want {
+			t.Errorf("metadataURI() with base %q = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestTokenMetadata(t *testing.T) {
+	issuer, isin, class, description := "Faria Lima Investimentos S.A.", "BRPETRACNPR6", "equity", "Cotas do edifício"
+	asset := models.Asset{Name: "Edifício FL", Symbol: "FL35", TotalShares: 1000, Chain: models.ChainSolana, MintAddress: "mint"}
+
+	bare := tokenMetadata(asset)
+	if bare.Description != "Tokenized shares of Edifício FL" {
+		t.Errorf("description = %q", bare.Description)
+	}
+	if bare.Properties.Documents == nil || len(bare.Attributes) != 1 {
+		t.Errorf("metadata without catalog fields = %+v, want an empty document list and the total shares alone", bare)
+	}
+
+	asset.Issuer, asset.ISIN, asset.AssetClass = &issuer, &isin, &class
+	asset.Links = models.AssetLinks{{Name: "Prospecto", URL: "https://docs.tiquin.com.br/fl35.pdf"}}
+	full := tokenMetadata(asset)
+	if full.Description != "Tokenized shares of Edifício FL, issued by Faria Lima Investimentos S.A." {
+		t.Errorf("description = %q", full.Description)
+	}
+	wantTraits := []string{"Total shares", "Asset class", "ISIN", "Issuer"}
+	if len(full.Attributes) != len(wantTraits) {
+		t.Fatalf("attributes = %+v, want %v", full.Attributes, wantTraits)
+	}
+	for i, trait := range wantTraits {
+		if full.Attributes[i].TraitType != trait {
+			t.Errorf("attribute %d = %q, want %q", i, full.Attributes[i].TraitType, trait)
+		}
+	}
+	if p := full.Properties; *p.ISIN != isin || *p.Issuer != issuer || p.Address != "mint" || len(p.Documents) != 1 {
+		t.Errorf("properties = %+v", p)
+	}
+
+	asset.Description = &description
+	if got := tokenMetadata(asset).Description; got != description {
+		t.Errorf("description = %q, want the asset's own", got)
+	}
+}
+
+func TestNormalizeISIN(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"BRPETRACNPR6", "BRPETRACNPR6", true},
+		{" brvaleacnor0 ", "BRVALEACNOR0", true},
+		{"US0378331005", "US0378331005", true},
+		{"BRPETRACNPR7", "", false}, // Wrong check digit
+		{"US0378331006", "", false},
+		{"BRPETRACNPR", "", false},
+		{"12PETRACNPR6", "", false}, // Country code must be letters
+		{"BRPETRACNPRX", "", false}, // Check digit must be a digit
+		{"BR-ETRACNPR6", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeISIN(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("NormalizeISIN(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// custody returns the custody service, or an error when custodial wallets
+// are not configured.
+func (s *TokenizationService) custody() (*CustodyService, error) {
+	if s.Custody == nil || s.Custody.masterKey == nil {
+		return nil, ErrCustodyNotConfigured
+	}
+	return s.Custody, nil
+}
+
+// signCustodialTransfer prepares a transfer from a custodial user and signs
+// it with their wallet. Preparing runs the same compliance, AML, vesting and
+// balance checks as for self-custodial senders; the wallet's policy is
+// checked on top. It returns the prepared transfer, with its intent claimed,
+// the signed transaction and the custodial signature to settle once the
+// transaction is sent.
+func (s *TokenizationService) signCustodialTransfer(
+	asset models.Asset, fromUser, toUser models.User, amount float64,
+) (PreparedTransfer, string, string, error) {
+	custody, err := s.custody()
+	if err != nil {
+		return PreparedTransfer{}, "", "", err
+	}
+
+	prepared, err := s.PrepareTransferTokenFromUser(asset.ID, fromUser.ID, toUser.ID, amount, nil)
+	if err != nil {
+		return PreparedTransfer{}, "", "", err
+	}
+	if err := s.claimTransferIntent(prepared.IntentID); err != nil {
+		return PreparedTransfer{}, "", "", err
+	}
+
+	keys, sigID, err := custody.authorize(fromUser.ID, asset.ID, amount)
+	if err != nil {
+		s.failTransferIntent(prepared.IntentID, err)
+		return PreparedTransfer{}, "", "", err
+	}
+
+	var signedTx string
+	if asset.Chain == models.ChainEVM {
+		signedTx, err = keys.signEVM(prepared.SerializedTransaction, s.EVM.ChainID)
+	} else {
+		signedTx, err = keys.signSolana(prepared.SerializedTransaction)
+	}
+	if err != nil {
+		custody.settle(sigID, "", err)
+		s.failTransferIntent(prepared.IntentID, err)
+		return PreparedTransfer{}, "", "", fmt.Errorf("failed to sign with custodial wallet: %w", err)
+	}
+	return prepared, signedTx, sigID, nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/google/uuid"
+)
+
+// evm returns the EVM integration, or an error when no EVM chain is configured.
+func (s *TokenizationService) evm() (*EVMIntegrationService, error) {
+	if s.EVM == nil {
+		return nil, invalidf("no EVM chain is configured")
+	}
+	return s.EVM, nil
+}
+
+// evmHolder returns a user's EVM wallet address.
+func evmHolder(user models.User) (common.Address, error) {
+	if user.EVMAddress == nil {
+		return common.Address{}, invalidf("user %s has no evm_address", user.ID)
+	}
+	return common.HexToAddress(*user.EVMAddress), nil
+}
+
+// createEVMAsset deploys the asset's token contract and verifies the owner in
+// its holder registry, so the supply can be minted to them.
+func (s *TokenizationService) createEVMAsset(asset models.Asset, owner string) (models.Asset, error) {
+	evm, err := s.evm()
+	if err != nil {
+		return models.Asset{}, err
+	}
+	ownerAddress, ok := NormalizeEVMAddress(owner)
+	if !ok {
+		return models.Asset{}, invalidf("invalid owner EVM address %q", owner)
+	}
+
+	tokenAddress, err := evm.DeployToken(asset.Name, asset.Symbol)
+	if err != nil {
+		return models.Asset{}, fmt.Errorf("failed to deploy token contract: %w", err)
+	}
+	if err := evm.SetVerified(tokenAddress, common.HexToAddress(ownerAddress), true); err != nil {
+		return models.Asset{}, fmt.Errorf("failed to verify owner: %w", err)
+	}
+
+	asset.Chain = models.ChainEVM
+	asset.MintAddress = tokenAddress.Hex()
+	err = s.saveAsset(asset)
+	return asset, err
+}
+
+// prepareEVMTransfer builds the unsigned EIP-1559 transfer for the sender's
+// wallet, once the transfer's checks have cleared it. The recipient is
+// verified in the contract's registry first, as the contract rejects
+// transfers to anyone else.
+func (s *TokenizationService) prepareEVMTransfer(asset models.Asset, fromUser, toUser models.User, amount float64) (string, string, error) {
+	evm, err := s.evm()
+	if err != nil {
+		return "", "", err
+	}
+	from, err := evmHolder(fromUser)
+	if err != nil {
+		return "", "", err
+	}
+	to, err := evmHolder(toUser)
+	if err != nil {
+		return "", "", err
+	}
+	tokenAddress := common.HexToAddress(asset.MintAddress)
+
+	if err := evm.SetVerified(tokenAddress, to, true); err != nil {
+		return "", "", fmt.Errorf("failed to verify recipient: %w", err)
+	}
+
+	rawTx, err := evm.PrepareTransferTransaction(tokenAddress, from, to, toAtomic(amount, 9))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to prepare transfer transaction: %w", err)
+	}
+	return rawTx, to.Hex(), nil
+}
+
+// evmTokenBalance reads a holder's balance of an EVM asset, in atomic units.
+func (s *TokenizationService) evmTokenBalance(asset models.Asset, holder models.User) (uint64, error) {
+	evm, err := s.evm()
+	if err != nil {
+		return 0, err
+	}
+	address, err := evmHolder(holder)
+	if err != nil {
+		return 0, err
+	}
+	return evm.GetTokenBalance(common.HexToAddress(asset.MintAddress), address)
+}
+
+// sendEVMTransfer submits a transfer signed by the sender's wallet and waits
+// for it to be mined, so a reverted transfer is never booked.
+func (s *TokenizationService) sendEVMTransfer(fromUser models.User, signedTx string) (string, error) {
+	evm, err := s.evm()
+	if err != nil {
+		return "", err
+	}
+	from, err := evmHolder(fromUser)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := evm.SendSignedTransaction(signedTx, from)
+	if errors.Is(err, ErrSignerMismatch) {
+		return "", invalidf("%v", err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to send signed transaction: %w", err)
+	}
+	confirmed, err := evm.WaitForConfirmation(hash, evmConfirmationTimeout)
+	if err != nil {
+		return "", fmt.Errorf("transfer %s failed: %w", hash, err)
+	}
+	if !confirmed {
+		log.Printf("WARNING: transfer %s not yet mined; booking it as sent", hash)
+	}
+	return hash.Hex(), nil
+}
+
+// mintInitialEVMTokens mints the asset's total supply to the owner's address.
+func (s *TokenizationService) mintInitialEVMTokens(asset models.Asset, ownerAddress string, vesting *models.VestingTerms) (models.Token, error) {
+	evm, err := s.evm()
+	if err != nil {
+		return models.Token{}, err
+	}
+	address, ok := NormalizeEVMAddress(ownerAddress)
+	if !ok {
+		return models.Token{}, invalidf("invalid owner EVM address %q", ownerAddress)
+	}
+
+	recipient, found, err := s.DB.GetUserByEVMAddress(address)
+	if err != nil {
+		return models.Token{}, fmt.Errorf("error fetching recipient user: %w", err)
+	}
+	if !found {
+		if vesting != nil {
+			return models.Token{}, invalidf("vesting requires the owner to be a registered user")
+		}
+		recipient = models.User{EVMAddress: &address}
+	}
+	if err := s.Compliance.Check(Movement{Asset: asset, To: recipient, Amount: asset.TotalShares, Timestamp: time.Now()}); err != nil {
+		return models.Token{}, err
+	}
+	if err := s.AML.ScreenMovement("mint", asset.ID, recipient); err != nil {
+		return models.Token{}, err
+	}
+
+	tokenAddress := common.HexToAddress(asset.MintAddress)
+	owner := common.HexToAddress(address)
+	if err := evm.SetVerified(tokenAddress, owner, true); err != nil {
+		return models.Token{}, fmt.Errorf("failed to verify owner: %w", err)
+	}
+	amountAtomic := toAtomic(asset.TotalShares, 9)
+	hash, err := evm.MintTokens(tokenAddress, owner, amountAtomic)
+	if err != nil {
+		return models.Token{}, fmt.Errorf("failed to mint tokens: %w", err)
+	}
+
+	tokenRecord := models.Token{
+		ID:                  uuid.New().String(),
+		AssetID:             asset.ID,
+		OwnerID:             address, // Store address as owner reference before user lookup
+		Amount:              asset.TotalShares,
+		SmartContractRules:  asset.Name + " rules",
+		IsTradable:          true,
+		MintAddress:         asset.MintAddress,
+		TokenAccountAddress: address,
+		TransactionID:       hash.Hex(),
+		CreatedAt:           time.Now(),
+	}
+
+	if err := s.DB.SaveToken(tokenRecord); err != nil {
+		log.Printf("WARNING: minted %d tokens (tx %s) but failed to save record: %v", amountAtomic, hash, err)
+	}
+	if err := s.saveMintVesting(asset, recipient, vesting, hash.Hex()); err != nil {
+		return tokenRecord, err
+	}
+
+	return tokenRecord, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/ferreirogomes/tiquin/models"
@@ -15,164 +16,372 @@ import (
 )
 
 type TokenizationService struct {
-	DB      *storage.DB
-	SolanaS *SolanaIntegrationService
+	DB         *storage.DB
+	SolanaS    *SolanaIntegrationService
+	Compliance *ComplianceService
+	AML        *AMLService
+	Vesting    *VestingService
+	Tax        *TaxService
+	EVM        *EVMIntegrationService // Nil unless an EVM chain is configured
+	Custody    *CustodyService        // Holds the keys of custodial users
+	// MetadataBaseURL is the public URL of this API, under which token
+	// metadata JSON is served to wallets
+	MetadataBaseURL string
+
+	freezeMu sync.Mutex // Serializes freezing and thawing of holder accounts
 }
 
 func NewTokenizationService(db *storage.DB, solanaS *SolanaIntegrationService) *TokenizationService {
 	return &TokenizationService{
-		DB:      db,
-		SolanaS: solanaS,
+		DB:         db,
+		SolanaS:    solanaS,
+		Compliance: NewComplianceService(db),
+		AML:        NewAMLService(db),
+		Vesting:    NewVestingService(db),
+		Tax:        NewTaxService(db),
 	}
 }
 
-// CreateAsset creates an asset record in the DB AND mints it on Solana.
-func (s *TokenizationService) CreateAsset(symbol, name string, totalShares float64, ownerPubKey string) (models.Asset, error) {
-	ownerKey, err := solana.PublicKeyFromBase58(ownerPubKey)
+// CreateAssetInput describes a new asset and the initial owner of its token.
+type CreateAssetInput struct {
+	Symbol      string            `json:"symbol"`
+	Name        string            `json:"name"`
+	TotalShares float64           `json:"total_shares"`
+	Chain       string            `json:"chain"` // "solana" (default) or "evm"
+	Owner       string            `json:"-"`     // Solana public key or EVM address, per chain
+	Issuer      *string           `json:"issuer,omitempty"`
+	IssuerCNPJ  *string           `json:"issuer_cnpj,omitempty"`
+	ISIN        *string           `json:"isin,omitempty"`
+	AssetClass  *string           `json:"asset_class,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Links       models.AssetLinks `json:"links,omitempty"`
+}
+
+// CreateAsset creates an asset record in the DB AND its token on the chosen
+// chain: an SPL mint with token metadata on Solana, or a token contract on
+// the EVM chain. The owner is a Solana public key or an EVM address
+// accordingly. The symbol is checked before anything is created on chain.
+func (s *TokenizationService) CreateAsset(in CreateAssetInput) (models.Asset, error) {
+	if in.Symbol == "" || len(in.Symbol) > maxAssetSymbolLength || in.Name == "" {
+		return models.Asset{}, invalidf("a name and a symbol of up to %d characters are required", maxAssetSymbolLength)
+	}
+	now := time.Now()
+	asset := models.Asset{
+		ID:          uuid.New().String(),
+		Symbol:      in.Symbol,
+		Name:        in.Name,
+		TotalShares: in.TotalShares,
+		Issuer:      in.Issuer,
+		IssuerCNPJ:  in.IssuerCNPJ,
+		ISIN:        in.ISIN,
+		AssetClass:  in.AssetClass,
+		Description: in.Description,
+		Links:       in.Links,
+		Status:      models.AssetStatusDraft,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := normalizeAssetDetails(&asset); err != nil {
+		return models.Asset{}, err
+	}
+	if _, taken, err := s.DB.GetAssetBySymbol(asset.Symbol); err != nil {
+		return models.Asset{}, fmt.Errorf("error checking asset symbol: %w", err)
+	} else if taken {
+		return models.Asset{}, ErrAssetSymbolTaken
+	}
+
+	switch in.Chain {
+	case "", models.ChainSolana:
+	case models.ChainEVM:
+		return s.createEVMAsset(asset, in.Owner)
+	default:
+		return models.Asset{}, invalidf("chain must be %q or %q", models.ChainSolana, models.ChainEVM)
+	}
+
+	ownerKey, err := solana.PublicKeyFromBase58(in.Owner)
 	if err != nil {
 		return models.Asset{}, fmt.Errorf("invalid owner public key: %w", err)
 	}
 
-	mintAddress, _, err := s.SolanaS.CreateMintAndTokenAccount(ownerKey, symbol)
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceAsset, ID: asset.ID})
+	mintAddress, _, err := solanaS.CreateMintAndTokenAccount(ownerKey, asset.Name, asset.Symbol, s.metadataURI(asset.ID))
 	if err != nil {
 		return models.Asset{}, fmt.Errorf("failed to create mint on Solana: %w", err)
 	}
 
-	asset := models.Asset{
-		ID:          uuid.New().String(),
-		Symbol:      symbol,
-		Name:        name,
-		TotalShares: totalShares,
-		MintAddress: mintAddress.String(),
-	}
-	err = s.DB.SaveAsset(asset)
+	asset.Chain = models.ChainSolana
+	asset.MintAddress = mintAddress.String()
+	err = s.saveAsset(asset)
 	return asset, err
 }
 
-// PrepareTransferTokenFromUser builds a transaction to be signed by the user.
-// Returns the transaction serialized in Base64 and the destination TokenAccountAddress.
+// requireSolanaAsset rejects assets issued on another chain from flows that
+// are only implemented on Solana.
+func requireSolanaAsset(asset models.Asset) error {
+	if asset.Chain == models.ChainEVM {
+		return invalidf("asset %s is issued on %s; this operation is only supported for Solana assets", asset.Symbol, asset.Chain)
+	}
+	return nil
+}
+
+// transferSigningWindow is how long a sender has to sign a prepared transfer
+// before completing it is refused; on Solana its blockhash expires about then.
+const transferSigningWindow = 60 * time.Second
+
+var (
+	// ErrTransferIntentNotFound is returned when completing a transfer that was never prepared.
+	ErrTransferIntentNotFound = fmt.Errorf("transfer intent %w", ErrNotFound)
+	// ErrTransferIntentUsed is returned when completing a transfer whose intent was already used or expired.
+	ErrTransferIntentUsed = fmt.Errorf("%w: transfer intent was already used or has expired; prepare the transfer again", ErrConflict)
+)
+
+// PreparedTransfer is a transfer transaction awaiting the sender's signature.
+type PreparedTransfer struct {
+	SerializedTransaction string // Base64 on Solana, unsigned hex EIP-1559 transaction on EVM
+	Destination           string // Destination TokenAccountAddress, or the recipient's address on EVM
+	IntentID              string // Passed back to complete the transfer; referenced in the transaction's memo on Solana
+}
+
+// PrepareTransferTokenFromUser builds a transaction to be signed by the user
+// and records it as a transfer intent, which completing the transfer is bound
+// to. On Solana the transaction carries a memo referencing the intent and the
+// caller's optional external reference, so the listener can link it back once
+// it lands.
 func (s *TokenizationService) PrepareTransferTokenFromUser(
-	assetID, fromUserID, toUserID string, amount float64,
-) (string, solana.PublicKey, error) { // Returns Base64 string and toATA
+	assetID, fromUserID, toUserID string, amount float64, reference *string,
+) (PreparedTransfer, error) {
+	if reference != nil && !models.ValidExternalReference(*reference) {
+		return PreparedTransfer{}, invalidf("reference must be 1 to 64 printable ASCII characters")
+	}
+	asset, fromUser, toUser, err := s.transferParties(assetID, fromUserID, toUserID)
+	if err != nil {
+		return PreparedTransfer{}, err
+	}
+
+	// Enforce the asset's compliance rules before anything is built or signed
+	if err := s.checkTransfer(asset, fromUser, toUser, amount); err != nil {
+		return PreparedTransfer{}, err
+	}
+
+	intent := models.TransferIntent{
+		ID:         uuid.New().String(),
+		AssetID:    asset.ID,
+		FromUserID: fromUser.ID,
+		ToUserID:   toUser.ID,
+		Amount:     amount,
+		Status:     models.TransferIntentStatusPrepared,
+	}
+	if asset.Chain == models.ChainEVM {
+		intent.Transaction, intent.Destination, err = s.prepareEVMTransfer(asset, fromUser, toUser, amount)
+	} else {
+		intent.Transaction, intent.Destination, err = s.prepareSolanaTransfer(asset, fromUser, toUser, amount, intent.ID, reference)
+	}
+	if err != nil {
+		return PreparedTransfer{}, err
+	}
+
+	now := time.Now()
+	intent.ExpiresAt = now.Add(transferSigningWindow)
+	intent.CreatedAt = now
+	intent.UpdatedAt = now
+	if err := s.DB.SaveTransferIntent(intent); err != nil {
+		return PreparedTransfer{}, fmt.Errorf("failed to save transfer intent: %w", err)
+	}
+	return PreparedTransfer{SerializedTransaction: intent.Transaction, Destination: intent.Destination, IntentID: intent.ID}, nil
+}
+
+// transferParties fetches the asset and the users of a transfer.
+func (s *TokenizationService) transferParties(assetID, fromUserID, toUserID string) (models.Asset, models.User, models.User, error) {
 	fromUser, foundFrom, err := s.DB.GetUser(fromUserID)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("error fetching sender user: %w", err)
+		return models.Asset{}, models.User{}, models.User{}, fmt.Errorf("error fetching sender user: %w", err)
 	}
 	if !foundFrom || fromUser.SolanaPubKey == "" {
-		return "", solana.PublicKey{}, errors.New("sender user not found or missing Solana public key")
+		return models.Asset{}, models.User{}, models.User{}, errors.New("sender user not found or missing Solana public key")
 	}
 	toUser, foundTo, err := s.DB.GetUser(toUserID)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("error fetching recipient user: %w", err)
+		return models.Asset{}, models.User{}, models.User{}, fmt.Errorf("error fetching recipient user: %w", err)
 	}
 	if !foundTo || toUser.SolanaPubKey == "" {
-		return "", solana.PublicKey{}, errors.New("recipient user not found or missing Solana public key")
+		return models.Asset{}, models.User{}, models.User{}, errors.New("recipient user not found or missing Solana public key")
 	}
 
 	asset, foundAsset, err := s.DB.GetAsset(assetID)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("error fetching asset: %w", err)
+		return models.Asset{}, models.User{}, models.User{}, fmt.Errorf("error fetching asset: %w", err)
 	}
 	if !foundAsset || asset.MintAddress == "" {
-		return "", solana.PublicKey{}, errors.New("asset not found or not tokenized")
+		return models.Asset{}, models.User{}, models.User{}, errors.New("asset not found or not tokenized")
+	}
+	return asset, fromUser, toUser, nil
+}
+
+// checkTransfer runs the compliance, AML, balance and vesting checks of a
+// transfer. They run when it is prepared and again when it is completed, as
+// a party may have lost eligibility or the asset been suspended in between.
+func (s *TokenizationService) checkTransfer(asset models.Asset, fromUser, toUser models.User, amount float64) error {
+	now := time.Now()
+	if err := s.Compliance.Check(Movement{Asset: asset, From: &fromUser, To: toUser, Amount: amount, Timestamp: now}); err != nil {
+		return err
+	}
+	if err := s.AML.ScreenMovement("transfer", asset.ID, fromUser, toUser); err != nil {
+		return err
+	}
+
+	// P1 fix: real balance check from the chain
+	var currentBalance uint64
+	var err error
+	if asset.Chain == models.ChainEVM {
+		currentBalance, err = s.evmTokenBalance(asset, fromUser)
+	} else {
+		currentBalance, err = s.solanaTokenBalance(asset, fromUser)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check sender balance: %w", err)
+	}
+	amountAtomic := toAtomic(amount, 9)
+	if currentBalance < amountAtomic {
+		return fmt.Errorf("insufficient balance: have %d, need %d atomic units", currentBalance, amountAtomic)
+	}
+	return s.Vesting.CheckTransferable(fromUser.ID, asset.ID, fromAtomic(currentBalance, 9), amount, now)
+}
+
+// solanaTokenBalance reads a holder's balance of a Solana asset, in atomic units.
+func (s *TokenizationService) solanaTokenBalance(asset models.Asset, holder models.User) (uint64, error) {
+	mintAddress, err := solana.PublicKeyFromBase58(asset.MintAddress)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Mint address: %w", err)
+	}
+	holderKey, err := solana.PublicKeyFromBase58(holder.SolanaPubKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid holder public key: %w", err)
 	}
+	holderATA, _, err := solana.FindAssociatedTokenAddress(holderKey, mintAddress)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find holder ATA: %w", err)
+	}
+	return s.SolanaS.GetTokenAccountBalance(holderATA)
+}
 
+// prepareSolanaTransfer builds the transfer for the sender's wallet to sign,
+// creating the recipient's ATA when missing. It returns the transaction and
+// the destination ATA.
+func (s *TokenizationService) prepareSolanaTransfer(
+	asset models.Asset, fromUser, toUser models.User, amount float64, intentID string, reference *string,
+) (string, string, error) {
 	mintAddress, err := solana.PublicKeyFromBase58(asset.MintAddress)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("invalid Mint address: %w", err)
+		return "", "", fmt.Errorf("invalid Mint address: %w", err)
 	}
 
 	fromUserPubKey, err := solana.PublicKeyFromBase58(fromUser.SolanaPubKey)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("invalid sender public key: %w", err)
+		return "", "", fmt.Errorf("invalid sender public key: %w", err)
 	}
 	toUserPubKey, err := solana.PublicKeyFromBase58(toUser.SolanaPubKey)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("invalid recipient public key: %w", err)
+		return "", "", fmt.Errorf("invalid recipient public key: %w", err)
 	}
 
 	fromATA, _, err := solana.FindAssociatedTokenAddress(fromUserPubKey, mintAddress)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("failed to find sender ATA: %w", err)
+		return "", "", fmt.Errorf("failed to find sender ATA: %w", err)
 	}
 
 	toATA, _, err := solana.FindAssociatedTokenAddress(toUserPubKey, mintAddress)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("failed to find recipient ATA: %w", err)
+		return "", "", fmt.Errorf("failed to find recipient ATA: %w", err)
 	}
 
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceTransferIntent, ID: intentID, External: reference})
+
 	// Ensure destination ATA exists; create it if not (FeePayer covers the cost)
-	created, err := s.SolanaS.EnsureATAExists(toUserPubKey, mintAddress, toATA)
+	created, err := solanaS.EnsureATAExists(toUserPubKey, mintAddress, toATA)
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("failed to ensure destination ATA exists: %w", err)
+		return "", "", fmt.Errorf("failed to ensure destination ATA exists: %w", err)
 	}
 	if created {
-		log.Printf("Created destination ATA %s for user %s", toATA, toUserID)
-	}
-
-	// P1 fix: real balance check from Solana
-	currentBalance, err := s.SolanaS.GetTokenAccountBalance(fromATA)
-	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("failed to check sender balance on Solana: %w", err)
-	}
-	amountAtomic := uint64(amount * 1e9)
-	if currentBalance < amountAtomic {
-		return "", solana.PublicKey{}, fmt.Errorf("insufficient balance: have %d, need %d atomic units", currentBalance, amountAtomic)
+		log.Printf("Created destination ATA %s for user %s", toATA, toUser.ID)
 	}
 
 	// Prepare the transaction, but do not sign with the user's key
-	serializedTx, err := s.SolanaS.PrepareTransferTransaction(mintAddress, fromATA, toATA, fromUserPubKey, amountAtomic)
+	serializedTx, err := solanaS.PrepareTransferTransaction(mintAddress, fromATA, toATA, fromUserPubKey, uint64(amount*1e9))
 	if err != nil {
-		return "", solana.PublicKey{}, fmt.Errorf("failed to prepare transfer transaction: %w", err)
+		return "", "", fmt.Errorf("failed to prepare transfer transaction: %w", err)
 	}
-
-	return serializedTx, toATA, nil
+	return serializedTx, toATA.String(), nil
 }
 
-// CompleteTransferTokenFromUser receives the signed transaction, sends it to Solana,
-// and updates the internal DB: debits the sender and credits the recipient.
+// CompleteTransferTokenFromUser sends the transaction of a prepared transfer
+// once its sender signed it, and updates the internal DB: debits the sender
+// and credits the recipient. The transfer must match its intent, and its
+// checks run again before it is sent.
+// A price per unit makes the transfer a sale for cost basis and withholding.
+// Custodial senders pass no intent nor signed transaction: the backend
+// prepares and signs the transfer for them.
 func (s *TokenizationService) CompleteTransferTokenFromUser(
-	assetID, fromUserID, toUserID string, amount float64, signedTxBase64 string,
-	destinationATA solana.PublicKey, // Receives the destination ATA back from the handler
+	assetID, fromUserID, toUserID string, amount float64,
+	intentID string, // Intent returned by PrepareTransferTokenFromUser
+	signedTx string,
+	pricePerUnit *float64,
 ) (models.Token, error) {
-	fromUser, foundFrom, err := s.DB.GetUser(fromUserID)
-	if err != nil {
-		return models.Token{}, fmt.Errorf("error fetching sender user: %w", err)
+	if pricePerUnit != nil && *pricePerUnit < 0 {
+		return models.Token{}, invalidf("price_per_unit cannot be negative")
 	}
-	if !foundFrom {
-		return models.Token{}, errors.New("sender user not found")
-	}
-	toUser, foundTo, err := s.DB.GetUser(toUserID)
+	asset, fromUser, toUser, err := s.transferParties(assetID, fromUserID, toUserID)
 	if err != nil {
-		return models.Token{}, fmt.Errorf("error fetching recipient user: %w", err)
-	}
-	if !foundTo {
-		return models.Token{}, errors.New("recipient user not found")
+		return models.Token{}, err
+	}
+
+	// Custodial senders hold no keys: the backend prepares, checks and signs for them
+	var destination, custodialSigID string
+	if fromUser.Custody == models.CustodyCustodial {
+		if intentID != "" || signedTx != "" {
+			return models.Token{}, invalidf("transfers from custodial users are prepared and signed by the backend; omit intent_id and signed_transaction")
+		}
+		var prepared PreparedTransfer
+		prepared, signedTx, custodialSigID, err = s.signCustodialTransfer(asset, fromUser, toUser, amount)
+		if err != nil {
+			return models.Token{}, err
+		}
+		intentID, destination = prepared.IntentID, prepared.Destination
+	} else {
+		var intent models.TransferIntent
+		intent, signedTx, err = s.verifyTransferIntent(intentID, signedTx, asset, fromUser, toUser, amount)
+		if err != nil {
+			return models.Token{}, err
+		}
+		destination = intent.Destination
+	}
+
+	txID, err := s.sendTransfer(asset, fromUser, signedTx, destination)
+	if custodialSigID != "" {
+		s.Custody.settle(custodialSigID, txID, err)
 	}
-
-	asset, foundAsset, err := s.DB.GetAsset(assetID)
 	if err != nil {
-		return models.Token{}, fmt.Errorf("error fetching asset: %w", err)
+		s.failTransferIntent(intentID, err)
+		return models.Token{}, err
 	}
-	if !foundAsset || asset.MintAddress == "" {
-		return models.Token{}, errors.New("asset not found or not tokenized")
-	}
-
-	// Send the signed transaction to Solana
-	txID, err := s.SolanaS.SendSignedTransaction(signedTxBase64)
-	if err != nil {
-		return models.Token{}, fmt.Errorf("failed to send signed transaction to Solana: %w", err)
+	if err := s.DB.MarkTransferIntentSent(intentID, txID); err != nil {
+		log.Printf("ERROR: tx %s sent, but failed to record it on transfer intent %s: %v", txID, intentID, err)
 	}
 
 	// P3 fix: Debit sender and credit recipient in the DB
 	// Use a DB transaction to keep both operations atomic
-	if err := s.DB.TransferTokenBalance(fromUser.ID, toUser.ID, asset.ID, amount, txID.String(), destinationATA.String()); err != nil {
+	if err := s.DB.TransferTokenBalance(fromUser.ID, toUser.ID, asset.ID, amount, txID, destination); err != nil {
 		// This is a serious error: the transaction went to the blockchain, but the internal DB failed.
 		// The blockchain listener will eventually reconcile via backfill.
-		log.Printf("ERROR: Solana tx %s sent, but DB transfer failed: %v", txID, err)
+		log.Printf("ERROR: tx %s sent, but DB transfer failed: %v", txID, err)
 		return models.Token{}, fmt.Errorf("transaction sent but failed to update internal records: %w", err)
 	}
 
+	// Tax lots are bookkeeping; the transfer itself has already settled
+	if err := s.Tax.RecordTransfer(asset.ID, fromUser.ID, toUser.ID, amount, pricePerUnit, txID, time.Now()); err != nil {
+		log.Printf("ERROR: Failed to record tax effects of transfer %s: %v", txID, err)
+	}
+
 	// Return the new recipient's token record
 	recipientToken := models.Token{
 		ID:                  uuid.New().String(),
@@ -183,20 +392,137 @@ func (s *TokenizationService) CompleteTransferTokenFromUser(
 		IsTradable:          true,
 		CreatedAt:           time.Now(),
 		MintAddress:         asset.MintAddress,
-		TokenAccountAddress: destinationATA.String(),
-		TransactionID:       txID.String(),
+		TokenAccountAddress: destination,
+		TransactionID:       txID,
 	}
 
 	return recipientToken, nil
 }
 
-// MintInitialTokens mints the initial total supply of an asset to the owner's ATA.
-func (s *TokenizationService) MintInitialTokens(asset models.Asset, ownerPubKey string) (models.Token, error) {
+// verifyTransferIntent binds a self-custodial transfer to the intent it was
+// prepared as: the asset, parties and amount must be the intent's, and the
+// signed transaction must be the prepared one, signed by the sender. The
+// transfer's checks then run again and the intent is claimed, so it is sent
+// once. It returns the intent and the transaction to send.
+func (s *TokenizationService) verifyTransferIntent(
+	id, signedTx string, asset models.Asset, fromUser, toUser models.User, amount float64,
+) (models.TransferIntent, string, error) {
+	if id == "" || signedTx == "" {
+		return models.TransferIntent{}, "", invalidf("intent_id and signed_transaction are required")
+	}
+	intent, found, err := s.DB.GetTransferIntent(id)
+	if err != nil {
+		return models.TransferIntent{}, "", fmt.Errorf("error fetching transfer intent: %w", err)
+	}
+	if !found {
+		return models.TransferIntent{}, "", ErrTransferIntentNotFound
+	}
+	if intent.AssetID != asset.ID || intent.FromUserID != fromUser.ID || intent.ToUserID != toUser.ID ||
+		toAtomic(intent.Amount, 9) != toAtomic(amount, 9) {
+		return models.TransferIntent{}, "", invalidf("transfer does not match intent %s", intent.ID)
+	}
+	if intent.Status != models.TransferIntentStatusPrepared || time.Now().After(intent.ExpiresAt) {
+		return models.TransferIntent{}, "", ErrTransferIntentUsed
+	}
+
+	if asset.Chain == models.ChainEVM {
+		var evm *EVMIntegrationService
+		if evm, err = s.evm(); err == nil {
+			err = evm.MatchPreparedTransaction(intent.Transaction, signedTx)
+		}
+	} else {
+		var senderKey solana.PublicKey
+		if senderKey, err = solana.PublicKeyFromBase58(fromUser.SolanaPubKey); err == nil {
+			signedTx, err = s.SolanaS.MergeSignature(intent.Transaction, signedTx, senderKey)
+		}
+	}
+	if errors.Is(err, ErrTransactionMismatch) {
+		return models.TransferIntent{}, "", invalidf("%v", err)
+	}
+	if err != nil {
+		return models.TransferIntent{}, "", err
+	}
+
+	if err := s.checkTransfer(asset, fromUser, toUser, amount); err != nil {
+		return models.TransferIntent{}, "", err
+	}
+	if err := s.claimTransferIntent(intent.ID); err != nil {
+		return models.TransferIntent{}, "", err
+	}
+	return intent, signedTx, nil
+}
+
+// claimTransferIntent claims a prepared intent for sending.
+func (s *TokenizationService) claimTransferIntent(id string) error {
+	claimed, err := s.DB.ClaimTransferIntent(id)
+	if err != nil {
+		return fmt.Errorf("failed to claim transfer intent: %w", err)
+	}
+	if !claimed {
+		return ErrTransferIntentUsed
+	}
+	return nil
+}
+
+// failTransferIntent records why a claimed intent was not sent.
+func (s *TokenizationService) failTransferIntent(id string, cause error) {
+	if err := s.DB.MarkTransferIntentFailed(id, cause.Error()); err != nil {
+		log.Printf("ERROR: Failed to record failure of transfer intent %s: %v", id, err)
+	}
+}
+
+// sendTransfer sends a signed transfer to the asset's chain and returns its transaction ID.
+func (s *TokenizationService) sendTransfer(asset models.Asset, fromUser models.User, signedTx, destination string) (string, error) {
+	if asset.Chain == models.ChainEVM {
+		return s.sendEVMTransfer(fromUser, signedTx)
+	}
+	if _, err := solana.PublicKeyFromBase58(destination); err != nil {
+		return "", invalidf("invalid destination ATA address: %v", err)
+	}
+	// Send the signed transaction to Solana
+	sig, err := s.SolanaS.SendSignedTransaction(signedTx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send signed transaction to Solana: %w", err)
+	}
+	return sig.String(), nil
+}
+
+// MintInitialTokens mints the initial total supply of an asset to the owner's ATA,
+// or to the owner's address for EVM assets. Optional vesting terms restrict
+// the minted supply with a schedule created along with it; the owner must
+// then be a registered user.
+func (s *TokenizationService) MintInitialTokens(asset models.Asset, ownerPubKey string, vesting *models.VestingTerms) (models.Token, error) {
+	if vesting != nil {
+		if err := ValidateVestingTerms(*vesting); err != nil {
+			return models.Token{}, err
+		}
+	}
+	if asset.Chain == models.ChainEVM {
+		return s.mintInitialEVMTokens(asset, ownerPubKey, vesting)
+	}
+
 	ownerKey, err := solana.PublicKeyFromBase58(ownerPubKey)
 	if err != nil {
 		return models.Token{}, fmt.Errorf("invalid owner public key: %w", err)
 	}
 
+	recipient, found, err := s.DB.GetUserBySolanaPubKey(ownerPubKey)
+	if err != nil {
+		return models.Token{}, fmt.Errorf("error fetching recipient user: %w", err)
+	}
+	if !found {
+		if vesting != nil {
+			return models.Token{}, invalidf("vesting requires the owner to be a registered user")
+		}
+		recipient = models.User{SolanaPubKey: ownerPubKey}
+	}
+	if err := s.Compliance.Check(Movement{Asset: asset, To: recipient, Amount: asset.TotalShares, Timestamp: time.Now()}); err != nil {
+		return models.Token{}, err
+	}
+	if err := s.AML.ScreenMovement("mint", asset.ID, recipient); err != nil {
+		return models.Token{}, err
+	}
+
 	mintAddress, err := solana.PublicKeyFromBase58(asset.MintAddress)
 	if err != nil {
 		return models.Token{}, fmt.Errorf("invalid mint address: %w", err)
@@ -208,7 +534,8 @@ func (s *TokenizationService) MintInitialTokens(asset models.Asset, ownerPubKey
 	}
 
 	amountAtomic := uint64(asset.TotalShares * 1e9)
-	sig, err := s.SolanaS.MintTokensToAccount(mintAddress, ownerATA, amountAtomic)
+	solanaS := s.SolanaS.WithReference(models.TxReference{Type: models.ReferenceAsset, ID: asset.ID})
+	sig, err := solanaS.MintTokensToAccount(mintAddress, ownerATA, amountAtomic)
 	if err != nil {
 		return models.Token{}, fmt.Errorf("failed to mint tokens: %w", err)
 	}
@@ -232,10 +559,25 @@ func (s *TokenizationService) MintInitialTokens(asset models.Asset, ownerPubKey
 	if err := s.DB.SaveToken(tokenRecord); err != nil {
 		log.Printf("WARNING: minted %d tokens (tx %s) but failed to save record: %v", amountAtomic, sig, err)
 	}
+	if err := s.saveMintVesting(asset, recipient, vesting, sig.String()); err != nil {
+		return tokenRecord, err
+	}
 
 	return tokenRecord, nil
 }
 
+// saveMintVesting creates the schedule restricting an asset's minted supply.
+func (s *TokenizationService) saveMintVesting(asset models.Asset, owner models.User, vesting *models.VestingTerms, txID string) error {
+	if vesting == nil {
+		return nil
+	}
+	schedule := vesting.Schedule(uuid.New().String(), asset.ID, owner.ID, asset.TotalShares, time.Now())
+	if err := s.DB.SaveVestingSchedule(schedule); err != nil {
+		return fmt.Errorf("minted tokens (tx %s) but failed to save vesting schedule: %w", txID, err)
+	}
+	return nil
+}
+
 func (s *TokenizationService) GetUserTokensFromSolana(userID string) ([]models.Token, error) {
 	return s.DB.GetTokensByOwnerID(userID)
 }
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+const (
+	// maxTransactionSize is the largest serialized transaction Solana accepts.
+	maxTransactionSize = 1232
+	// maxAccountsPerLookup is how many accounts one getMultipleAccounts call accepts.
+	maxAccountsPerLookup = 100
+)
+
+// PackedTransfers is a group of transfers that fits in a single transaction.
+type PackedTransfers struct {
+	Indices      []int // Positions of the grouped transfers in the packed slice
+	instructions []solana.Instruction
+}
+
+// PackTokenTransfers groups transfers of `mintAddress` tokens from the
+// FeePayer's treasury ATA, or mints when mintTo is set, into as few
+// transactions as fit Solana's size limit. Recipient ATAs that do not exist
+// yet are created in the transaction of their first transfer; existing ones
+// cost nothing extra, so they pack densely. The reference memo of s is
+// accounted for: groups must be signed with SignPackedTransfers on the same s.
+func (s *SolanaIntegrationService) PackTokenTransfers(
+	mintAddress solana.PublicKey, transfers []TokenTransfer, mintTo bool,
+) ([]PackedTransfers, error) {
+	feePayerPubKey := s.FeePayer.PublicKey()
+
+	treasuryATA, _, err := solana.FindAssociatedTokenAddress(feePayerPubKey, mintAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive treasury ATA: %w", err)
+	}
+	atas := make([]solana.PublicKey, len(transfers))
+	for i, t := range transfers {
+		if atas[i], _, err = solana.FindAssociatedTokenAddress(t.Owner, mintAddress); err != nil {
+			return nil, fmt.Errorf("failed to derive ATA for %s: %w", t.Owner, err)
+		}
+	}
+	existing, err := s.existingAccounts(atas)
+	if err != nil {
+		return nil, err
+	}
+
+	var packs []PackedTransfers
+	var current PackedTransfers
+	created := make(map[solana.PublicKey]bool) // ATAs created within the current group
+	for i, t := range transfers {
+		instructions := func() []solana.Instruction {
+			var ixs []solana.Instruction
+			if !existing[atas[i]] && !created[atas[i]] {
+				ixs = append(ixs, newCreateIdempotentATAInstruction(feePayerPubKey, t.Owner, mintAddress, atas[i]))
+			}
+			if mintTo {
+				ixs = append(ixs, token.NewMintToInstruction(t.Amount, mintAddress, atas[i], feePayerPubKey, []solana.PublicKey{}).Build())
+			} else {
+				ixs = append(ixs, token.NewTransferInstruction(t.Amount, treasuryATA, atas[i], feePayerPubKey, []solana.PublicKey{}).Build())
+			}
+			return ixs
+		}
+
+		candidate := append(current.instructions[:len(current.instructions):len(current.instructions)], instructions()...)
+		size, err := s.transactionSize(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxTransactionSize && len(current.Indices) > 0 {
+			packs = append(packs, current)
+			current = PackedTransfers{}
+			created = make(map[solana.PublicKey]bool)
+			candidate = instructions()
+			size, err = s.transactionSize(candidate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if size > maxTransactionSize {
+			return nil, fmt.Errorf("transfer to %s does not fit in a transaction", t.Owner)
+		}
+
+		current.Indices = append(current.Indices, i)
+		current.instructions = candidate
+		created[atas[i]] = true
+	}
+	if len(current.Indices) > 0 {
+		packs = append(packs, current)
+	}
+	return packs, nil
+}
+
+// SignPackedTransfers builds and signs, but does not send, the transaction
+// of one group built by PackTokenTransfers. Like SignTransferFromEscrow it
+// returns the signature first, so callers can record it before sending with
+// SendSignedTransaction and never send a group twice.
+func (s *SolanaIntegrationService) SignPackedTransfers(pack PackedTransfers) (string, solana.Signature, error) {
+	tx, err := s.signBackendTransaction(pack.instructions, "batch transfer")
+	if err != nil {
+		return "", solana.Signature{}, err
+	}
+
+	serializedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return "", solana.Signature{}, fmt.Errorf("failed to serialize batch transfer: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(serializedTx), tx.Signatures[0], nil
+}
+
+// transactionSize is the serialized size of a transaction carrying
+// instructions, paid by the FeePayer and signed by all its signers.
+func (s *SolanaIntegrationService) transactionSize(instructions []solana.Instruction) (int, error) {
+	tx, err := solana.NewTransaction(s.referenced(instructions), solana.Hash{}, solana.TransactionPayer(s.FeePayer.PublicKey()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode message: %w", err)
+	}
+	signatures := int(tx.Message.Header.NumRequiredSignatures)
+	return 1 + signatures*64 + len(message), nil // Signature count fits in one byte below 128
+}
+
+// existingAccounts reports which of the accounts exist on chain.
+func (s *SolanaIntegrationService) existingAccounts(accounts []solana.PublicKey) (map[solana.PublicKey]bool, error) {
+	existing := make(map[solana.PublicKey]bool, len(accounts))
+	for start := 0; start < len(accounts); start += maxAccountsPerLookup {
+		chunk := accounts[start:min(start+maxAccountsPerLookup, len(accounts))]
+		resp, err := s.RPCClient.GetMultipleAccounts(context.Background(), chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up accounts: %w", err)
+		}
+		for i, account := range resp.Value {
+			if account != nil {
+				existing[chunk[i]] = true
+			}
+		}
+	}
+	return existing, nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// fakeAccountsRPC answers getMultipleAccounts, reporting the accounts in
+// existing as present and every other account as missing.
+func fakeAccountsRPC(t *testing.T, existing map[solana.PublicKey]bool) *rpc.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getMultipleAccounts" || len(req.Params) == 0 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var keys []solana.PublicKey
+		if err := json.Unmarshal(req.Params[0], &keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value := make([]any, len(keys))
+		for i, k := range keys {
+			if existing[k] {
+				value[i] = map[string]any{
+					"lamports": 2039280, "owner": solana.TokenProgramID.String(), "data": []string{"", "base64"},
+					"executable": false, "rentEpoch": 0, "space": 0,
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0", "id": req.ID,
+			"result": map[string]any{"context": map[string]any{"slot": 1}, "value": value},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return rpc.New(server.URL)
+}
+
+func newPackingService(t *testing.T, existing map[solana.PublicKey]bool) *SolanaIntegrationService {
+	t.Helper()
+	feePayer, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SolanaIntegrationService{RPCClient: fakeAccountsRPC(t, existing), FeePayer: feePayer}
+}
+
+func newRecipients(t *testing.T, n int) []solana.PublicKey {
+	t.Helper()
+	owners := make([]solana.PublicKey, n)
+	for i := range owners {
+		key, err := solana.NewRandomPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		owners[i] = key.PublicKey()
+	}
+	return owners
+}
+
+func TestTransactionSizeMatchesSignedTransaction(t *testing.T) {
+	s := newPackingService(t, nil)
+	mint := solana.NewWallet().PublicKey()
+	owners := newRecipients(t, 3)
+	var ixs []solana.Instruction
+	for _, owner := range owners {
+		ata, _, _ := solana.FindAssociatedTokenAddress(owner, mint)
+		ixs = append(ixs,
+			newCreateIdempotentATAInstruction(s.FeePayer.PublicKey(), owner, mint, ata),
+			token.NewMintToInstruction(1, mint, ata, s.FeePayer.PublicKey(), []solana.PublicKey{}).Build(),
+		)
+	}
+
+	for name, svc := range map[string]*SolanaIntegrationService{
+		"without reference": s,
+		"with reference":    s.WithReference(models.TxReference{Type: models.ReferenceTransferBatch, ID: "0b5f3a0e-9a43-4a7e-9d56-7f8e1f2f6a11"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			size, err := svc.transactionSize(ixs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err := solana.NewTransaction(svc.referenced(ixs), solana.Hash{}, solana.TransactionPayer(svc.FeePayer.PublicKey()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.Sign(func(solana.PublicKey) *solana.PrivateKey { return &svc.FeePayer }); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := tx.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != len(raw) {
+				t.Fatalf("transactionSize = %d, signed transaction is %d bytes", size, len(raw))
+			}
+		})
+	}
+}
+
+func TestPackTokenTransfers(t *testing.T) {
+	mint := solana.NewWallet().PublicKey()
+	owners := newRecipients(t, 60)
+	ataOf := func(owner solana.PublicKey) solana.PublicKey {
+		ata, _, _ := solana.FindAssociatedTokenAddress(owner, mint)
+		return ata
+	}
+	allExisting := make(map[solana.PublicKey]bool)
+	for _, o := range owners {
+		allExisting[ataOf(o)] = true
+	}
+	transfersTo := func(owners ...solana.PublicKey) []TokenTransfer {
+		transfers := make([]TokenTransfer, len(owners))
+		for i, o := range owners {
+			transfers[i] = TokenTransfer{Owner: o, Amount: uint64(i + 1)}
+		}
+		return transfers
+	}
+
+	tests := []struct {
+		name        string
+		existing    map[solana.PublicKey]bool
+		transfers   []TokenTransfer
+		mintTo      bool
+		reference   bool
+		wantCreates int // ATA creations across all packs
+	}{
+		{name: "no transfers", transfers: nil},
+		{name: "existing accounts", existing: allExisting, transfers: transfersTo(owners...)},
+		{name: "new accounts", transfers: transfersTo(owners...), wantCreates: len(owners)},
+		{name: "new accounts minted", transfers: transfersTo(owners...), mintTo: true, wantCreates: len(owners)},
+		{name: "with reference memo", transfers: transfersTo(owners...), reference: true, wantCreates: len(owners)},
+		{
+			name:        "repeated recipient is created once per pack",
+			transfers:   transfersTo(owners[0], owners[1], owners[0], owners[0]),
+			wantCreates: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPackingService(t, tt.existing)
+			if tt.reference {
+				s = s.WithReference(models.TxReference{Type: models.ReferenceTransferBatch, ID: "0b5f3a0e-9a43-4a7e-9d56-7f8e1f2f6a11"})
+			}
+			packs, err := s.PackTokenTransfers(mint, tt.transfers, tt.mintTo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, creates := 0, 0
+			for p, pack := range packs {
+				if len(pack.Indices) == 0 {
+					t.Fatalf("pack %d is empty", p)
+				}
+				for _, i := range pack.Indices {
+					if i != next {
+						t.Fatalf("pack %d holds transfer %d, want %d", p, i, next)
+					}
+					next++
+				}
+				size, err := s.transactionSize(pack.instructions)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if size > maxTransactionSize {
+					t.Fatalf("pack %d is %d bytes, over the %d limit", p, size, maxTransactionSize)
+				}
+				transfers := 0
+				for _, ix := range pack.instructions {
+					switch ix.ProgramID() {
+					case solana.SPLAssociatedTokenAccountProgramID:
+						creates++
+					case solana.TokenProgramID:
+						data, _ := ix.Data()
+						wantKind := token.Instruction_Transfer
+						if tt.mintTo {
+							wantKind = token.Instruction_MintTo
+						}
+						if data[0] != wantKind {
+							t.Errorf("pack %d has token instruction %d, want %d", p, data[0], wantKind)
+						}
+						transfers++
+					}
+				}
+				if transfers != len(pack.Indices) {
+					t.Errorf("pack %d has %d token instructions for %d transfers", p, transfers, len(pack.Indices))
+				}
+			}
+			if next != len(tt.transfers) {
+				t.Fatalf("packs hold %d transfers, want %d", next, len(tt.transfers))
+			}
+			if creates != tt.wantCreates {
+				t.Errorf("packs create %d accounts, want %d", creates, tt.wantCreates)
+			}
+		})
+	}
+}
+
+func TestPackTokenTransfersExistingAccountsPackDenser(t *testing.T) {
+	mint := solana.NewWallet().PublicKey()
+	owners := newRecipients(t, 60)
+	transfers := make([]TokenTransfer, len(owners))
+	existing := make(map[solana.PublicKey]bool)
+	for i, o := range owners {
+		transfers[i] = TokenTransfer{Owner: o, Amount: 1}
+		ata, _, _ := solana.FindAssociatedTokenAddress(o, mint)
+		existing[ata] = true
+	}
+
+	dense, err := newPackingService(t, existing).PackTokenTransfers(mint, transfers, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse, err := newPackingService(t, nil).PackTokenTransfers(mint, transfers, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dense) >= len(sparse) {
+		t.Fatalf("existing accounts took %d transactions, new accounts %d", len(dense), len(sparse))
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+// maxUserFieldLength bounds a user's name and email, which are stored encrypted.
+const maxUserFieldLength = 255
+
+var (
+	// ErrUserTaken is returned when the wallet, EVM address or email already belongs to another user.
+	ErrUserTaken = fmt.Errorf("%w: wallet, evm_address or email already belongs to another user", ErrConflict)
+	// ErrUserErased is returned when changing a user whose personal data was erased.
+	ErrUserErased = fmt.Errorf("%w: user's personal data was erased", ErrConflict)
+)
+
+// UserService manages user profiles and the rights LGPD grants users over
+// their personal data: access, correction and erasure.
+type UserService struct {
+	DB  *storage.DB
+	AML *AMLService
+}
+
+// NewUserService creates a new user service.
+func NewUserService(db *storage.DB, aml *AMLService) *UserService {
+	return &UserService{DB: db, AML: aml}
+}
+
+// UpdateUserInput holds the profile fields a user can correct. Omitted
+// fields are left as they are, and an empty string clears a field.
+type UpdateUserInput struct {
+	Name             *string `json:"name"`
+	Email            *string `json:"email"`
+	EVMAddress       *string `json:"evm_address"` // Self-custody only; custodial addresses belong to the backend's keys
+	TaxID            *string `json:"tax_id"`
+	Jurisdiction     *string `json:"jurisdiction"`
+	InvestorCategory *string `json:"investor_category"`
+}
+
+// NormalizeUserProfile validates the profile fields of a user and puts its
+// identifiers in canonical form.
+func NormalizeUserProfile(user *models.User) error {
+	if (user.Name != nil && len(*user.Name) > maxUserFieldLength) || (user.Email != nil && len(*user.Email) > maxUserFieldLength) {
+		return invalidf("name and email are limited to %d characters", maxUserFieldLength)
+	}
+	if user.TaxID != nil {
+		taxID, ok := NormalizeTaxID(*user.TaxID)
+		if !ok {
+			return invalidf("tax_id must be a CPF (11 digits) or CNPJ (14 digits)")
+		}
+		user.TaxID = &taxID
+	}
+	if user.EVMAddress != nil {
+		address, ok := NormalizeEVMAddress(*user.EVMAddress)
+		if !ok {
+			return invalidf("evm_address must be a 20-byte hex address")
+		}
+		user.EVMAddress = &address
+	}
+	if user.Jurisdiction != nil {
+		if len(*user.Jurisdiction) != 2 {
+			return invalidf("jurisdiction must be an ISO 3166-1 alpha-2 code")
+		}
+		upper := strings.ToUpper(*user.Jurisdiction)
+		user.Jurisdiction = &upper
+	}
+	if user.InvestorCategory != nil && !IsInvestorCategory(*user.InvestorCategory) {
+		return invalidf("investor_category must be retail, qualified or professional")
+	}
+	return nil
+}
+
+// UpdateUser corrects a user's profile. A changed name or tax ID is screened
+// again, like a new user, before it is stored. Changing the identity a KYC
+// verification vouched for, or the jurisdiction and investor category
+// compliance rules rely on, sends a verified or pending user back to
+// pending: they must verify again.
+func (s *UserService) UpdateUser(id string, in UpdateUserInput) (models.User, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.ErasedAt != nil {
+		return models.User{}, ErrUserErased
+	}
+	if in.EVMAddress != nil && user.Custody == models.CustodyCustodial {
+		return models.User{}, invalidf("custodial users get their evm_address from the backend")
+	}
+
+	before := user
+	patchString(&user.Name, in.Name)
+	patchString(&user.Email, in.Email)
+	patchString(&user.EVMAddress, in.EVMAddress)
+	patchString(&user.TaxID, in.TaxID)
+	patchString(&user.Jurisdiction, in.Jurisdiction)
+	patchString(&user.InvestorCategory, in.InvestorCategory)
+	if err := NormalizeUserProfile(&user); err != nil {
+		return models.User{}, err
+	}
+
+	if !equalStrings(before.Name, user.Name) || !equalStrings(before.TaxID, user.TaxID) {
+		if _, err := s.AML.ScreenParty("user_updated", nil, user); err != nil {
+			return models.User{}, fmt.Errorf("failed to screen updated user: %w", err)
+		}
+	}
+
+	updated, ok, err := s.DB.UpdateUserProfile(user, kycInvalidated(before, user))
+	if err != nil {
+		if storage.IsUniqueViolation(err) {
+			return models.User{}, ErrUserTaken
+		}
+		return models.User{}, fmt.Errorf("failed to update user: %w", err)
+	}
+	if !ok {
+		return models.User{}, ErrUserErased
+	}
+	return updated, nil
+}
+
+// kycInvalidated reports whether a profile change voids the user's KYC
+// verification, finished or in progress.
+func kycInvalidated(before, after models.User) bool {
+	if before.KYCStatus != models.KYCStatusVerified && before.KYCStatus != models.KYCStatusPending {
+		return false
+	}
+	return !equalStrings(before.Name, after.Name) || !equalStrings(before.TaxID, after.TaxID) ||
+		!equalStrings(before.Jurisdiction, after.Jurisdiction) || !equalStrings(before.InvestorCategory, after.InvestorCategory)
+}
+
+// ExportUserData returns everything held about a user, except AML
+// screenings, which must stay confidential, and custodial keys. The export
+// is recorded.
+func (s *UserService) ExportUserData(id string) (models.UserDataExport, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return models.UserDataExport{}, err
+	}
+
+	now := time.Now()
+	req := models.DataSubjectRequest{ID: uuid.New().String(), UserID: user.ID, Kind: models.DataSubjectRequestExport, CreatedAt: now}
+	if err := s.DB.SaveDataSubjectRequest(req); err != nil {
+		return models.UserDataExport{}, fmt.Errorf("failed to record export request: %w", err)
+	}
+	records, err := s.DB.GetUserRecords(user.ID)
+	if err != nil {
+		return models.UserDataExport{}, err
+	}
+	return models.UserDataExport{User: user, Records: records, Retention: storage.RecordRetentions, GeneratedAt: now}, nil
+}
+
+// EraseUser pseudonymizes a user, removing their name, email and tax ID, and
+// the parts of their KYC verifications not under a retention obligation.
+// The user's ID and wallet addresses are kept, as are the ledger, tax, KYC
+// and AML records referring to them, which regulation requires to retain
+// (see storage.RecordRetentions).
+// Users who still hold assets, have unfinished operations or keep an active
+// custodial wallet cannot be erased: the registry must identify holders,
+// and erasing custodial keys would lose the user's assets.
+func (s *UserService) EraseUser(id string) (models.User, error) {
+	user, err := s.getUser(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.ErasedAt != nil {
+		return models.User{}, ErrUserErased
+	}
+
+	holdings, err := s.DB.GetOwnerHoldings(user.ID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error fetching holdings: %w", err)
+	}
+	if len(holdings) > 0 {
+		return models.User{}, fmt.Errorf("%w: user still holds %s; holders cannot be erased from the registry", ErrConflict, holdings[0].Symbol)
+	}
+	open, err := s.DB.CountUserOpenCommitments(user.ID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if len(open) > 0 {
+		return models.User{}, fmt.Errorf("%w: user has unfinished %s", ErrConflict, strings.Join(slices.Sorted(maps.Keys(open)), ", "))
+	}
+	if user.Custody == models.CustodyCustodial {
+		return models.User{}, fmt.Errorf("%w: export the user's custodial wallet before erasing them", ErrConflict)
+	}
+
+	req := models.DataSubjectRequest{ID: uuid.New().String(), UserID: user.ID, Kind: models.DataSubjectRequestErasure, CreatedAt: time.Now()}
+	erased, ok, err := s.DB.EraseUser(req)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !ok {
+		return models.User{}, ErrUserErased
+	}
+	log.Printf("User %s erased on request %s", erased.ID, req.ID)
+	return erased, nil
+}
+
+func (s *UserService) getUser(id string) (models.User, error) {
+	user, found, err := s.DB.GetUser(id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error fetching user: %w", err)
+	}
+	if !found {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// equalStrings reports whether two optional strings hold the same value.
+func equalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
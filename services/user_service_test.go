@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestKYCInvalidated(t *testing.T) {
+	str := func(s string) *string { return &s }
+	profile := func(status string) models.User {
+		return models.User{
+			Name: str("Maria Silva"), Email: str("maria@example.com"), TaxID: str("12345678909"),
+			Jurisdiction: str("BR"), InvestorCategory: str(models.InvestorCategoryRetail), KYCStatus: status,
+		}
+	}
+	with := func(u models.User, change func(*models.User)) models.User {
+		change(&u)
+		return u
+	}
+
+	tests := []struct {
+		name          string
+		before, after models.User
+		want          bool
+	}{
+		{"verified, nothing changed", profile(models.KYCStatusVerified), profile(models.KYCStatusVerified), false},
+		{"verified, email changed", profile(models.KYCStatusVerified), with(profile(models.KYCStatusVerified), func(u *models.User) { u.Email = str("maria@example.org") }), false},
+		{"verified, name changed", profile(models.KYCStatusVerified), with(profile(models.KYCStatusVerified), func(u *models.User) { u.Name = str("Maria Souza") }), true},
+		{"verified, tax id cleared", profile(models.KYCStatusVerified), with(profile(models.KYCStatusVerified), func(u *models.User) { u.TaxID = nil }), true},
+		{"verified, jurisdiction changed", profile(models.KYCStatusVerified), with(profile(models.KYCStatusVerified), func(u *models.User) { u.Jurisdiction = str("PT") }), true},
+		{"verified, category changed", profile(models.KYCStatusVerified), with(profile(models.KYCStatusVerified), func(u *models.User) {
+			u.InvestorCategory = str(models.InvestorCategoryQualified)
+		}), true},
+		{"pending, name changed", profile(models.KYCStatusPending), with(profile(models.KYCStatusPending), func(u *models.User) { u.Name = str("Maria Souza") }), true},
+		{"unverified, name changed", profile(models.KYCStatusUnverified), with(profile(models.KYCStatusUnverified), func(u *models.User) { u.Name = str("Maria Souza") }), false},
+		{"rejected, tax id changed", profile(models.KYCStatusRejected), with(profile(models.KYCStatusRejected), func(u *models.User) { u.TaxID = str("98765432100") }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kycInvalidated(tt.before, tt.after); got != tt.want {
+				t.Fatalf("kycInvalidated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/google/uuid"
+)
+
+// RuleLockup is the rule reported when a transfer exceeds the unlocked part of a holding.
+const RuleLockup = "lockup"
+
+var (
+	// ErrVestingScheduleNotFound is returned when the requested schedule does not exist.
+	ErrVestingScheduleNotFound = fmt.Errorf("vesting schedule %w", ErrNotFound)
+	// ErrVestingScheduleRevoked is returned when revoking an already revoked schedule.
+	ErrVestingScheduleRevoked = fmt.Errorf("%w: vesting schedule is already revoked", ErrConflict)
+)
+
+// VestingService manages lock-up and vesting schedules and computes the
+// transferable part of holdings.
+type VestingService struct {
+	DB *storage.DB
+}
+
+func NewVestingService(db *storage.DB) *VestingService {
+	return &VestingService{DB: db}
+}
+
+// CreateVestingScheduleInput describes a restriction on a holder's allocation.
+type CreateVestingScheduleInput struct {
+	AssetID        string    `json:"-"`
+	OwnerID        string    `json:"owner_id"`
+	TotalAmount    float64   `json:"total_amount"`
+	ScheduleType   string    `json:"schedule_type"`
+	StartDate      time.Time `json:"start_date"`
+	CliffMonths    int       `json:"cliff_months"`
+	DurationMonths int       `json:"duration_months"`
+	Description    *string   `json:"description,omitempty"`
+}
+
+// CreateSchedule validates and stores a vesting schedule.
+func (s *VestingService) CreateSchedule(in CreateVestingScheduleInput) (models.VestingSchedule, error) {
+	if err := ValidateVestingTerms(models.VestingTerms{
+		ScheduleType: in.ScheduleType, CliffMonths: in.CliffMonths, DurationMonths: in.DurationMonths,
+	}); err != nil {
+		return models.VestingSchedule{}, err
+	}
+	if in.TotalAmount <= 0 {
+		return models.VestingSchedule{}, invalidf("total_amount must be positive")
+	}
+	if in.StartDate.IsZero() {
+		return models.VestingSchedule{}, invalidf("start_date is required")
+	}
+
+	if _, found, err := s.DB.GetAsset(in.AssetID); err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("error fetching asset: %w", err)
+	} else if !found {
+		return models.VestingSchedule{}, ErrAssetNotFound
+	}
+	if _, found, err := s.DB.GetUser(in.OwnerID); err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("error fetching user: %w", err)
+	} else if !found {
+		return models.VestingSchedule{}, ErrUserNotFound
+	}
+
+	schedule := models.VestingSchedule{
+		ID:             uuid.New().String(),
+		AssetID:        in.AssetID,
+		OwnerID:        in.OwnerID,
+		TotalAmount:    in.TotalAmount,
+		ScheduleType:   in.ScheduleType,
+		StartDate:      in.StartDate,
+		CliffMonths:    in.CliffMonths,
+		DurationMonths: in.DurationMonths,
+		Description:    in.Description,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.DB.SaveVestingSchedule(schedule); err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("failed to save vesting schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// ValidateVestingTerms checks the schedule type and periods of vesting terms.
+func ValidateVestingTerms(v models.VestingTerms) error {
+	switch v.ScheduleType {
+	case models.VestingLockup, models.VestingLinear, models.VestingMonthly:
+	default:
+		return invalidf("schedule_type must be %s, %s or %s", models.VestingLockup, models.VestingLinear, models.VestingMonthly)
+	}
+	if v.StartDate != nil && v.StartDate.IsZero() {
+		return invalidf("start_date must be a valid date")
+	}
+	if v.DurationMonths <= 0 {
+		return invalidf("duration_months must be positive")
+	}
+	if v.CliffMonths < 0 || v.CliffMonths > v.DurationMonths {
+		return invalidf("cliff_months must be between 0 and duration_months")
+	}
+	return nil
+}
+
+// RevokeSchedule lifts a schedule, releasing whatever it still locks.
+func (s *VestingService) RevokeSchedule(id string) (models.VestingSchedule, error) {
+	if _, found, err := s.DB.GetVestingSchedule(id); err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("error fetching vesting schedule: %w", err)
+	} else if !found {
+		return models.VestingSchedule{}, ErrVestingScheduleNotFound
+	}
+	revoked, err := s.DB.RevokeVestingSchedule(id)
+	if err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("failed to revoke vesting schedule: %w", err)
+	}
+	if !revoked {
+		return models.VestingSchedule{}, ErrVestingScheduleRevoked
+	}
+	schedule, _, err := s.DB.GetVestingSchedule(id)
+	if err != nil {
+		return models.VestingSchedule{}, fmt.Errorf("error fetching vesting schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// GetBalances returns every holding of a user split into locked and available at t.
+func (s *VestingService) GetBalances(userID string, t time.Time) ([]models.HoldingBalance, error) {
+	if _, found, err := s.DB.GetUser(userID); err != nil {
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	} else if !found {
+		return nil, ErrUserNotFound
+	}
+
+	balances, err := s.DB.GetOwnerHoldings(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching holdings: %w", err)
+	}
+	schedules, err := s.DB.GetActiveVestingSchedules(userID, "")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching vesting schedules: %w", err)
+	}
+
+	for i := range balances {
+		b := &balances[i]
+		var locked uint64
+		for _, sch := range schedules {
+			if sch.AssetID == b.AssetID {
+				locked += lockedAtomic(sch, t)
+				b.Schedules = append(b.Schedules, sch)
+			}
+		}
+		b.AsOf = t
+		b.Locked, b.Available = splitHolding(toAtomic(b.Total, 9), locked)
+	}
+	if balances == nil {
+		balances = []models.HoldingBalance{}
+	}
+	return balances, nil
+}
+
+// CheckTransferable returns a *ComplianceError when moving amount out of a
+// holding of balance would dip into the part still locked at t.
+func (s *VestingService) CheckTransferable(ownerID, assetID string, balance, amount float64, t time.Time) error {
+	schedules, err := s.DB.GetActiveVestingSchedules(ownerID, assetID)
+	if err != nil {
+		return fmt.Errorf("error fetching vesting schedules: %w", err)
+	}
+	var locked uint64
+	for _, sch := range schedules {
+		locked += lockedAtomic(sch, t)
+	}
+	if locked == 0 {
+		return nil
+	}
+	lockedAmount, available := splitHolding(toAtomic(balance, 9), locked)
+	if toAtomic(amount, 9) > toAtomic(available, 9) {
+		return violation(RuleLockup, "only %g of the holding is transferable; %g is locked", available, lockedAmount)
+	}
+	return nil
+}
+
+// splitHolding returns the locked and available parts of a holding, both in
+// whole units. Locks never exceed the holding itself.
+func splitHolding(totalAtomic, lockedAtomic uint64) (float64, float64) {
+	lockedAtomic = min(lockedAtomic, totalAtomic)
+	return fromAtomic(lockedAtomic, 9), fromAtomic(totalAtomic-lockedAtomic, 9)
+}
+
+// lockedAtomic returns how much of a schedule is still locked at t, in atomic units.
+func lockedAtomic(sch models.VestingSchedule, t time.Time) uint64 {
+	total := toAtomic(sch.TotalAmount, 9)
+	end := sch.StartDate.AddDate(0, sch.DurationMonths, 0)
+	if !t.Before(end) {
+		return 0
+	}
+	if sch.ScheduleType == models.VestingLockup || t.Before(sch.StartDate.AddDate(0, sch.CliffMonths, 0)) {
+		return total
+	}
+
+	var num, den int64
+	switch sch.ScheduleType {
+	case models.VestingLinear:
+		num, den = int64(t.Sub(sch.StartDate)), int64(end.Sub(sch.StartDate))
+	case models.VestingMonthly:
+		months := 0
+		for !t.Before(sch.StartDate.AddDate(0, months+1, 0)) {
+			months++
+		}
+		num, den = int64(months), int64(sch.DurationMonths)
+	default:
+		return total
+	}
+
+	vested := new(big.Int).Mul(new(big.Int).SetUint64(total), big.NewInt(num))
+	vested.Quo(vested, big.NewInt(den))
+	return total - vested.Uint64()
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestLockedAtomic(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(months, days int) time.Time { return start.AddDate(0, months, days) }
+	schedule := func(kind string, cliff, duration int) models.VestingSchedule {
+		return models.VestingSchedule{TotalAmount: 120, ScheduleType: kind, StartDate: start, CliffMonths: cliff, DurationMonths: duration}
+	}
+	const total = 120_000_000_000
+
+	tests := []struct {
+		name     string
+		schedule models.VestingSchedule
+		t        time.Time
+		want     uint64
+	}{
+		{"lockup before start", schedule(models.VestingLockup, 0, 12), at(0, -1), total},
+		{"lockup before end", schedule(models.VestingLockup, 0, 12), at(12, -1), total},
+		{"lockup at end", schedule(models.VestingLockup, 0, 12), at(12, 0), 0},
+		{"lockup after end", schedule(models.VestingLockup, 0, 12), at(24, 0), 0},
+		{"linear at start", schedule(models.VestingLinear, 0, 12), start, total},
+		{"linear before cliff", schedule(models.VestingLinear, 3, 12), at(3, -1), total},
+		{"linear after cliff", schedule(models.VestingLinear, 3, 12), at(6, 0), total - total*181/365}, // 181 of 365 days elapsed
+		{"linear at end", schedule(models.VestingLinear, 3, 12), at(12, 0), 0},
+		{"monthly within first month", schedule(models.VestingMonthly, 0, 12), at(0, 20), total},
+		{"monthly counts whole months", schedule(models.VestingMonthly, 0, 12), at(2, 14), 100_000_000_000},
+		{"monthly before cliff", schedule(models.VestingMonthly, 6, 12), at(6, -1), total},
+		{"monthly releases accrued tranches at cliff", schedule(models.VestingMonthly, 6, 12), at(6, 0), 60_000_000_000},
+		{"monthly last tranche", schedule(models.VestingMonthly, 6, 12), at(11, 0), 10_000_000_000},
+		{"unknown type stays locked", schedule("cliff", 0, 12), at(6, 0), total},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockedAtomic(tt.schedule, tt.t); got != tt.want {
+				t.Fatalf("lockedAtomic at %s = %d, want %d", tt.t.Format(time.DateOnly), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitHolding(t *testing.T) {
+	tests := []struct {
+		name                      string
+		total, locked             uint64
+		wantLocked, wantAvailable float64
+	}{
+		{"nothing locked", 5_000_000_000, 0, 0, 5},
+		{"partly locked", 5_000_000_000, 1_500_000_000, 1.5, 3.5},
+		{"fully locked", 5_000_000_000, 5_000_000_000, 5, 0},
+		{"lock above holding is capped", 5_000_000_000, 8_000_000_000, 5, 0},
+		{"empty holding", 0, 1_000_000_000, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locked, available := splitHolding(tt.total, tt.locked)
+			if locked != tt.wantLocked || available != tt.wantAvailable {
+				t.Fatalf("splitHolding(%d, %d) = (%v, %v), want (%v, %v)", tt.total, tt.locked, locked, available, tt.wantLocked, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestValidateVestingTerms(t *testing.T) {
+	zero := time.Time{}
+	tests := []struct {
+		name  string
+		terms models.VestingTerms
+		valid bool
+	}{
+		{"lockup", models.VestingTerms{ScheduleType: models.VestingLockup, DurationMonths: 12}, true},
+		{"linear with cliff", models.VestingTerms{ScheduleType: models.VestingLinear, CliffMonths: 12, DurationMonths: 48}, true},
+		{"cliff equal to duration", models.VestingTerms{ScheduleType: models.VestingMonthly, CliffMonths: 6, DurationMonths: 6}, true},
+		{"unknown type", models.VestingTerms{ScheduleType: "cliff", DurationMonths: 12}, false},
+		{"no duration", models.VestingTerms{ScheduleType: models.VestingLinear}, false},
+		{"negative cliff", models.VestingTerms{ScheduleType: models.VestingLinear, CliffMonths: -1, DurationMonths: 12}, false},
+		{"cliff beyond duration", models.VestingTerms{ScheduleType: models.VestingLinear, CliffMonths: 13, DurationMonths: 12}, false},
+		{"zero start date", models.VestingTerms{ScheduleType: models.VestingLockup, StartDate: &zero, DurationMonths: 12}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVestingTerms(tt.terms)
+			if tt.valid && err != nil {
+				t.Fatalf("ValidateVestingTerms() = %v, want nil", err)
+			}
+			var invalid *ValidationError
+			if !tt.valid && !errors.As(err, &invalid) {
+				t.Fatalf("ValidateVestingTerms() = %v, want an invalid input error", err)
+			}
+		})
+	}
+}
+
+func TestVestingTermsSchedule(t *testing.T) {
+	issued := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	terms := models.VestingTerms{ScheduleType: models.VestingLinear, CliffMonths: 3, DurationMonths: 24}
+	sch := terms.Schedule("s1", "asset", "owner", 42, issued)
+	if !sch.StartDate.Equal(issued) {
+		t.Errorf("start date = %s, want the issue date %s", sch.StartDate, issued)
+	}
+	if sch.TotalAmount != 42 || sch.AssetID != "asset" || sch.OwnerID != "owner" || sch.CliffMonths != 3 || sch.DurationMonths != 24 {
+		t.Errorf("schedule = %+v does not carry the terms", sch)
+	}
+
+	terms.StartDate = &start
+	if sch := terms.Schedule("s2", "asset", "owner", 42, issued); !sch.StartDate.Equal(start) {
+		t.Errorf("start date = %s, want %s", sch.StartDate, start)
+	}
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+	"github.com/ferreirogomes/tiquin/storage"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// ballotDomain identifies this platform in the messages voters sign.
+	ballotDomain = "tiquin"
+	// ballotChallengeTTL is how long a voter has to sign an issued ballot.
+	ballotChallengeTTL = 10 * time.Minute
+)
+
+var (
+	// ErrProposalNotFound is returned when the requested proposal does not exist.
+	ErrProposalNotFound = fmt.Errorf("proposal %w", ErrNotFound)
+	// ErrAlreadyVoted is returned when a holder votes twice on the same proposal.
+	ErrAlreadyVoted = fmt.Errorf("%w: voter has already voted on this proposal", ErrConflict)
+	// ErrProposalFinalized is returned when finalizing a proposal twice.
+	ErrProposalFinalized = fmt.Errorf("%w: proposal is already finalized", ErrConflict)
+	// ErrProposalFinalizing is returned when a proposal is being finalized concurrently.
+	ErrProposalFinalizing = fmt.Errorf("%w: proposal is being finalized", ErrConflict)
+)
+
+// VotingService runs shareholder proposals. Voters authenticate by signing a
+// server-issued ballot message (Sign-In With Solana style) with the wallet
+// registered on their user; their weight is their holding at the record date.
+type VotingService struct {
+	DB        *storage.DB
+	SolanaS   *SolanaIntegrationService
+	Snapshots *SnapshotService
+}
+
+func NewVotingService(db *storage.DB, solanaS *SolanaIntegrationService, snapshots *SnapshotService) *VotingService {
+	return &VotingService{DB: db, SolanaS: solanaS, Snapshots: snapshots}
+}
+
+// CreateProposalInput describes a new proposal. Either SnapshotID or
+// RecordDate must be given; a record date creates a dedicated snapshot.
+type CreateProposalInput struct {
+	AssetID       string     `json:"-"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Options       []string   `json:"options"`
+	QuorumPercent float64    `json:"quorum_percent"`
+	OpensAt       time.Time  `json:"opens_at"`
+	ClosesAt      time.Time  `json:"closes_at"`
+	SnapshotID    string     `json:"snapshot_id,omitempty"`
+	RecordDate    *time.Time `json:"record_date,omitempty"`
+}
+
+// CreateProposal validates and stores a new proposal.
+func (s *VotingService) CreateProposal(in CreateProposalInput) (models.Proposal, error) {
+	if strings.TrimSpace(in.Title) == "" {
+		return models.Proposal{}, invalidf("title is required")
+	}
+	if len(in.Options) < 2 {
+		return models.Proposal{}, invalidf("at least two options are required")
+	}
+	seen := make(map[string]bool, len(in.Options))
+	for _, o := range in.Options {
+		if strings.TrimSpace(o) == "" || seen[o] {
+			return models.Proposal{}, invalidf("options must be non-empty and unique")
+		}
+		seen[o] = true
+	}
+	if !in.ClosesAt.After(in.OpensAt) {
+		return models.Proposal{}, invalidf("closes_at must be after opens_at")
+	}
+	if in.QuorumPercent < 0 || in.QuorumPercent > 100 {
+		return models.Proposal{}, invalidf("quorum_percent must be between 0 and 100")
+	}
+
+	proposalID := uuid.New().String()
+
+	var snapshot models.Snapshot
+	var err error
+	switch {
+	case in.SnapshotID != "":
+		snapshot, err = s.Snapshots.GetSnapshot(in.SnapshotID)
+		if err != nil {
+			return models.Proposal{}, err
+		}
+		if snapshot.AssetID != in.AssetID {
+			return models.Proposal{}, invalidf("snapshot %s does not belong to asset %s", snapshot.ID, in.AssetID)
+		}
+	case in.RecordDate != nil:
+		snapshot, err = s.Snapshots.CreateSnapshot(in.AssetID, "proposal-"+proposalID, in.RecordDate, nil)
+		if err != nil {
+			return models.Proposal{}, err
+		}
+	default:
+		return models.Proposal{}, invalidf("snapshot_id or record_date is required")
+	}
+
+	proposal := models.Proposal{
+		ID:            proposalID,
+		AssetID:       in.AssetID,
+		SnapshotID:    snapshot.ID,
+		Title:         in.Title,
+		Description:   in.Description,
+		Options:       in.Options,
+		QuorumPercent: in.QuorumPercent,
+		OpensAt:       in.OpensAt,
+		ClosesAt:      in.ClosesAt,
+		Status:        models.ProposalStatusOpen,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.DB.SaveProposal(proposal); err != nil {
+		return models.Proposal{}, fmt.Errorf("failed to save proposal: %w", err)
+	}
+	return proposal, nil
+}
+
+// GetProposal retrieves a proposal by ID.
+func (s *VotingService) GetProposal(id string) (models.Proposal, error) {
+	proposal, found, err := s.DB.GetProposal(id)
+	if err != nil {
+		return models.Proposal{}, fmt.Errorf("error fetching proposal: %w", err)
+	}
+	if !found {
+		return models.Proposal{}, ErrProposalNotFound
+	}
+	return proposal, nil
+}
+
+// IssueBallot returns the message the voter must sign with their wallet to
+// vote for `option`. The message is single use and expires shortly.
+func (s *VotingService) IssueBallot(proposalID, voterID, option string) (models.BallotChallenge, error) {
+	proposal, err := s.GetProposal(proposalID)
+	if err != nil {
+		return models.BallotChallenge{}, err
+	}
+	if err := checkVotingOpen(proposal, time.Now()); err != nil {
+		return models.BallotChallenge{}, err
+	}
+	if !containsOption(proposal.Options, option) {
+		return models.BallotChallenge{}, invalidf("option %q is not valid for this proposal", option)
+	}
+
+	voter, found, err := s.DB.GetUser(voterID)
+	if err != nil {
+		return models.BallotChallenge{}, fmt.Errorf("error fetching voter: %w", err)
+	}
+	if !found {
+		return models.BallotChallenge{}, fmt.Errorf("voter %w", ErrNotFound)
+	}
+	weight, err := s.DB.GetSnapshotHolding(proposal.SnapshotID, voter.SolanaPubKey)
+	if err != nil {
+		return models.BallotChallenge{}, fmt.Errorf("error fetching voting weight: %w", err)
+	}
+	if weight <= 0 {
+		return models.BallotChallenge{}, invalidf("voter held no tokens of this asset at the record date")
+	}
+
+	now := time.Now()
+	challenge := models.BallotChallenge{
+		ID:         uuid.New().String(),
+		ProposalID: proposal.ID,
+		VoterID:    voter.ID,
+		Option:     option,
+		ExpiresAt:  now.Add(ballotChallengeTTL),
+		CreatedAt:  now,
+	}
+	challenge.Message = ballotMessage(proposal, voter.SolanaPubKey, option, weight, challenge.ID, now, challenge.ExpiresAt)
+
+	if err := s.DB.SaveBallotChallenge(challenge); err != nil {
+		return models.BallotChallenge{}, fmt.Errorf("failed to save ballot: %w", err)
+	}
+	return challenge, nil
+}
+
+// CastVote verifies the wallet signature over an issued ballot and records the vote.
+func (s *VotingService) CastVote(proposalID, challengeID, signatureBase58 string) (models.Vote, error) {
+	proposal, err := s.GetProposal(proposalID)
+	if err != nil {
+		return models.Vote{}, err
+	}
+
+	challenge, found, err := s.DB.GetBallotChallenge(challengeID)
+	if err != nil {
+		return models.Vote{}, fmt.Errorf("error fetching ballot: %w", err)
+	}
+	if !found || challenge.ProposalID != proposal.ID {
+		return models.Vote{}, fmt.Errorf("ballot %w", ErrNotFound)
+	}
+	now := time.Now()
+	if now.After(challenge.ExpiresAt) {
+		return models.Vote{}, invalidf("ballot expired; request a new one")
+	}
+	if err := checkVotingOpen(proposal, now); err != nil {
+		return models.Vote{}, err
+	}
+
+	voter, found, err := s.DB.GetUser(challenge.VoterID)
+	if err != nil {
+		return models.Vote{}, fmt.Errorf("error fetching voter: %w", err)
+	}
+	if !found {
+		return models.Vote{}, fmt.Errorf("voter %w", ErrNotFound)
+	}
+	voterKey, err := solana.PublicKeyFromBase58(voter.SolanaPubKey)
+	if err != nil {
+		return models.Vote{}, fmt.Errorf("invalid voter public key: %w", err)
+	}
+	signature, err := solana.SignatureFromBase58(signatureBase58)
+	if err != nil {
+		return models.Vote{}, invalidf("invalid signature encoding: %v", err)
+	}
+	if !signature.Verify(voterKey, []byte(challenge.Message)) {
+		return models.Vote{}, invalidf("signature does not match the ballot and voter wallet")
+	}
+
+	weight, err := s.DB.GetSnapshotHolding(proposal.SnapshotID, voter.SolanaPubKey)
+	if err != nil {
+		return models.Vote{}, fmt.Errorf("error fetching voting weight: %w", err)
+	}
+
+	vote := models.Vote{
+		ID:           uuid.New().String(),
+		ProposalID:   proposal.ID,
+		VoterID:      voter.ID,
+		SolanaPubKey: voter.SolanaPubKey,
+		Option:       challenge.Option,
+		Weight:       weight,
+		Message:      challenge.Message,
+		Signature:    signature.String(),
+		CreatedAt:    now,
+	}
+	if err := s.DB.CastVote(challenge.ID, vote); err != nil {
+		if errors.Is(err, storage.ErrChallengeUsed) {
+			return models.Vote{}, fmt.Errorf("%w: ballot already used", ErrConflict)
+		}
+		if storage.IsUniqueViolation(err) {
+			return models.Vote{}, ErrAlreadyVoted
+		}
+		return models.Vote{}, err
+	}
+	return vote, nil
+}
+
+// GetResults tallies the votes of a proposal. Results of an open proposal are provisional.
+func (s *VotingService) GetResults(proposalID string) (models.ProposalResult, error) {
+	proposal, err := s.GetProposal(proposalID)
+	if err != nil {
+		return models.ProposalResult{}, err
+	}
+	result, _, err := s.tally(proposal)
+	if err != nil {
+		return models.ProposalResult{}, err
+	}
+	if proposal.ResultHash != nil {
+		result.ResultHash = *proposal.ResultHash
+	}
+	if proposal.AnchorTxID != nil {
+		result.AnchorTxID = *proposal.AnchorTxID
+	}
+	return result, nil
+}
+
+// FinalizeProposal tallies a closed proposal, hashes the canonical result
+// (including every signed vote) and anchors the hash on Solana in a memo.
+// The proposal is claimed before the memo is signed, and the memo recorded
+// before it is sent, so a result is anchored once even when finalized
+// concurrently. Until the memo is confirmed the result is returned with
+// Final false; finalizing again rebroadcasts it, or signs a new one once it
+// can no longer land.
+func (s *VotingService) FinalizeProposal(proposalID string) (models.ProposalResult, error) {
+	proposal, err := s.GetProposal(proposalID)
+	if err != nil {
+		return models.ProposalResult{}, err
+	}
+	switch {
+	case proposal.Status == models.ProposalStatusFinalized:
+		return models.ProposalResult{}, ErrProposalFinalized
+	case time.Now().Before(proposal.ClosesAt):
+		return models.ProposalResult{}, invalidf("voting closes at %s", proposal.ClosesAt.Format(time.RFC3339))
+	}
+
+	result, votes, err := s.tally(proposal)
+	if err != nil {
+		return models.ProposalResult{}, err
+	}
+	hash, err := resultHash(proposal, result, votes)
+	if err != nil {
+		return models.ProposalResult{}, fmt.Errorf("failed to hash result: %w", err)
+	}
+	if proposal.Status == models.ProposalStatusOpen {
+		claimed, err := s.DB.ClaimProposalFinalization(proposal.ID, hash)
+		if err != nil {
+			return models.ProposalResult{}, fmt.Errorf("failed to claim proposal: %w", err)
+		}
+		if !claimed {
+			return models.ProposalResult{}, ErrProposalFinalizing
+		}
+	} else if proposal.ResultHash == nil || *proposal.ResultHash != hash {
+		// Votes cannot change once voting closed
+		return models.ProposalResult{}, fmt.Errorf("result hash %s does not match the claimed one", hash)
+	}
+
+	anchorTxID, confirmed, err := s.anchorResult(proposal, hash)
+	if err != nil {
+		return models.ProposalResult{}, err
+	}
+	result.Final = confirmed
+	result.ResultHash = hash
+	result.AnchorTxID = anchorTxID
+	return result, nil
+}
+
+// anchorResult sends the memo anchoring a finalizing proposal's result hash
+// and finalizes the proposal once it is confirmed. A memo already recorded
+// is rebroadcast while it can still land; a new one is signed, recorded and
+// only then sent when there is none or it can no longer land.
+func (s *VotingService) anchorResult(proposal models.Proposal, hash string) (string, bool, error) {
+	if proposal.AnchorTxID != nil {
+		sig, err := solana.SignatureFromBase58(*proposal.AnchorTxID)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid anchor signature %s: %w", *proposal.AnchorTxID, err)
+		}
+		confirmed, err := s.SolanaS.GetTransactionConfirmation(sig)
+		switch {
+		case errors.Is(err, ErrTransactionFailed):
+			log.Printf("Proposal %s: result anchor %s failed, signing a new one: %v", proposal.ID, sig, err)
+		case err != nil:
+			return "", false, err
+		case confirmed:
+			return sig.String(), true, s.complete(proposal.ID, sig)
+		case proposal.AnchoredAt != nil && time.Since(*proposal.AnchoredAt) > payoutExpiry:
+			// Never landed and the blockhash has expired: safe to sign a new one
+			log.Printf("Proposal %s: result anchor %s was not confirmed, signing a new one", proposal.ID, sig)
+		default:
+			if _, err := s.SolanaS.SendSignedTransaction(*proposal.AnchorTransaction); err != nil {
+				log.Printf("Proposal %s: result anchor %s not sent yet: %v", proposal.ID, sig, err)
+			}
+			return sig.String(), false, nil
+		}
+	}
+
+	signedTx, sig, err := s.SolanaS.SignMemo(fmt.Sprintf("%s:proposal:%s:result:%s", ballotDomain, proposal.ID, hash))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to sign result anchor: %w", err)
+	}
+	recorded, err := s.DB.RecordProposalAnchor(proposal.ID, proposal.AnchorTxID, signedTx, sig.String())
+	if err != nil {
+		log.Printf("Proposal %s: failed to record result anchor %s, not sending it: %v", proposal.ID, sig, err)
+		return "", false, fmt.Errorf("failed to record result anchor: %w", err)
+	}
+	if !recorded {
+		return "", false, ErrProposalFinalizing // Anchored concurrently
+	}
+	if _, err := s.SolanaS.SendSignedTransaction(signedTx); err != nil {
+		log.Printf("Proposal %s: result anchor %s not sent: %v; will reconcile when finalized again", proposal.ID, sig, err)
+		return sig.String(), false, nil
+	}
+
+	confirmed, err := s.SolanaS.WaitForConfirmation(sig, payoutConfirmationTimeout)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		return "", false, fmt.Errorf("result anchor %s failed: %w", sig, err)
+	case err != nil:
+		log.Printf("Proposal %s: result anchor %s not confirmed yet: %v", proposal.ID, sig, err)
+		return sig.String(), false, nil
+	case !confirmed:
+		return sig.String(), false, nil
+	}
+	return sig.String(), true, s.complete(proposal.ID, sig)
+}
+
+func (s *VotingService) complete(proposalID string, sig solana.Signature) error {
+	if err := s.DB.CompleteProposalFinalization(proposalID); err != nil {
+		return fmt.Errorf("result anchored in %s but not saved: %w", sig, err)
+	}
+	return nil
+}
+
+// tally sums vote weights per option and checks quorum against the
+// record-date supply.
+func (s *VotingService) tally(proposal models.Proposal) (models.ProposalResult, []models.Vote, error) {
+	snapshot, found, err := s.DB.GetSnapshot(proposal.SnapshotID)
+	if err != nil {
+		return models.ProposalResult{}, nil, fmt.Errorf("error fetching snapshot: %w", err)
+	}
+	if !found {
+		return models.ProposalResult{}, nil, ErrSnapshotNotFound
+	}
+	votes, err := s.DB.GetVotes(proposal.ID)
+	if err != nil {
+		return models.ProposalResult{}, nil, fmt.Errorf("error fetching votes: %w", err)
+	}
+	return tallyVotes(proposal, snapshot.TotalAmount, votes), votes, nil
+}
+
+// tallyVotes sums vote weights per option out of `eligible` and picks the
+// winner when quorum is reached and there is no tie.
+func tallyVotes(proposal models.Proposal, eligible float64, votes []models.Vote) models.ProposalResult {
+	result := models.ProposalResult{
+		ProposalID:    proposal.ID,
+		EligibleTotal: eligible,
+		Tally:         make(map[string]float64, len(proposal.Options)),
+		VoteCount:     len(votes),
+		Final:         proposal.Status == models.ProposalStatusFinalized,
+	}
+	for _, o := range proposal.Options {
+		result.Tally[o] = 0
+	}
+	for _, v := range votes {
+		result.Tally[v.Option] += v.Weight
+		result.VotedTotal += v.Weight
+	}
+	if result.EligibleTotal > 0 {
+		result.TurnoutPct = result.VotedTotal / result.EligibleTotal * 100
+	}
+	result.QuorumReached = result.VotedTotal > 0 && result.TurnoutPct >= proposal.QuorumPercent
+
+	if result.QuorumReached {
+		best, tie := "", false
+		for _, o := range proposal.Options {
+			switch {
+			case best == "" || result.Tally[o] > result.Tally[best]:
+				best, tie = o, false
+			case result.Tally[o] == result.Tally[best]:
+				tie = true
+			}
+		}
+		if !tie {
+			result.Winner = best
+		}
+	}
+	return result
+}
+
+// checkVotingOpen rejects ballots outside the voting window.
+func checkVotingOpen(proposal models.Proposal, now time.Time) error {
+	if proposal.Status != models.ProposalStatusOpen || now.Before(proposal.OpensAt) || !now.Before(proposal.ClosesAt) {
+		return invalidf("proposal is not open for voting (window %s to %s)",
+			proposal.OpensAt.Format(time.RFC3339), proposal.ClosesAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func containsOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// ballotMessage renders the human-readable message a voter signs, following
+// the Sign-In With Solana layout so wallets display it legibly.
+func ballotMessage(proposal models.Proposal, pubKey, option string, weight float64, nonce string, issuedAt, expiresAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s wants you to cast a ballot with your Solana account:\n%s\n\n", ballotDomain, pubKey)
+	fmt.Fprintf(&b, "Proposal: %s\n", proposal.Title)
+	fmt.Fprintf(&b, "Proposal ID: %s\n", proposal.ID)
+	fmt.Fprintf(&b, "Option: %s\n", option)
+	fmt.Fprintf(&b, "Weight: %s\n", strconv.FormatFloat(weight, 'f', -1, 64))
+	fmt.Fprintf(&b, "Nonce: %s\n", nonce)
+	fmt.Fprintf(&b, "Issued At: %s\n", issuedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Expiration Time: %s", expiresAt.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// resultHash returns the SHA-256 of the canonical JSON of the final result.
+// Votes are included with their signatures so anyone holding the export can
+// recompute the hash and verify each ballot independently.
+func resultHash(proposal models.Proposal, result models.ProposalResult, votes []models.Vote) (string, error) {
+	type signedVote struct {
+		SolanaPubKey string  `json:"solana_pub_key"`
+		Option       string  `json:"option"`
+		Weight       float64 `json:"weight"`
+		Message      string  `json:"message"`
+		Signature    string  `json:"signature"`
+	}
+	canonical := struct {
+		ProposalID    string             `json:"proposal_id"`
+		SnapshotID    string             `json:"snapshot_id"`
+		Options       []string           `json:"options"`
+		EligibleTotal float64            `json:"eligible_total"`
+		VotedTotal    float64            `json:"voted_total"`
+		QuorumReached bool               `json:"quorum_reached"`
+		Tally         map[string]float64 `json:"tally"` // encoding/json sorts map keys
+		Winner        string             `json:"winner"`
+		Votes         []signedVote       `json:"votes"`
+	}{
+		ProposalID:    proposal.ID,
+		SnapshotID:    proposal.SnapshotID,
+		Options:       proposal.Options,
+		EligibleTotal: result.EligibleTotal,
+		VotedTotal:    result.VotedTotal,
+		QuorumReached: result.QuorumReached,
+		Tally:         result.Tally,
+		Winner:        result.Winner,
+		Votes:         make([]signedVote, len(votes)),
+	}
+	for i, v := range votes {
+		canonical.Votes[i] = signedVote{v.SolanaPubKey, v.Option, v.Weight, v.Message, v.Signature}
+	}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestTallyVotes(t *testing.T) {
+	proposal := models.Proposal{ID: "p1", Options: []string{"approve", "reject", "abstain"}, QuorumPercent: 50}
+	vote := func(option string, weight float64) models.Vote {
+		return models.Vote{Option: option, Weight: weight}
+	}
+
+	tests := []struct {
+		name        string
+		eligible    float64
+		votes       []models.Vote
+		wantTally   map[string]float64
+		wantTurnout float64
+		wantQuorum  bool
+		wantWinner  string
+	}{
+		{
+			name:      "no votes",
+			eligible:  1000,
+			wantTally: map[string]float64{"approve": 0, "reject": 0, "abstain": 0},
+		},
+		{
+			name:        "below quorum has no winner",
+			eligible:    1000,
+			votes:       []models.Vote{vote("approve", 300), vote("reject", 100)},
+			wantTally:   map[string]float64{"approve": 300, "reject": 100, "abstain": 0},
+			wantTurnout: 40,
+		},
+		{
+			name:        "quorum reached exactly",
+			eligible:    1000,
+			votes:       []models.Vote{vote("approve", 300), vote("reject", 200)},
+			wantTally:   map[string]float64{"approve": 300, "reject": 200, "abstain": 0},
+			wantTurnout: 50,
+			wantQuorum:  true,
+			wantWinner:  "approve",
+		},
+		{
+			name:        "weights of the same option add up",
+			eligible:    1000,
+			votes:       []models.Vote{vote("reject", 250), vote("approve", 400), vote("reject", 250)},
+			wantTally:   map[string]float64{"approve": 400, "reject": 500, "abstain": 0},
+			wantTurnout: 90,
+			wantQuorum:  true,
+			wantWinner:  "reject",
+		},
+		{
+			name:        "tie for first has no winner",
+			eligible:    1000,
+			votes:       []models.Vote{vote("approve", 300), vote("reject", 300)},
+			wantTally:   map[string]float64{"approve": 300, "reject": 300, "abstain": 0},
+			wantTurnout: 60,
+			wantQuorum:  true,
+		},
+		{
+			name:        "tie below the lead does not matter",
+			eligible:    1000,
+			votes:       []models.Vote{vote("approve", 100), vote("abstain", 100), vote("reject", 400)},
+			wantTally:   map[string]float64{"approve": 100, "reject": 400, "abstain": 100},
+			wantTurnout: 60,
+			wantQuorum:  true,
+			wantWinner:  "reject",
+		},
+		{
+			name:      "no eligible supply",
+			eligible:  0,
+			votes:     []models.Vote{vote("approve", 10)},
+			wantTally: map[string]float64{"approve": 10, "reject": 0, "abstain": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tallyVotes(proposal, tt.eligible, tt.votes)
+			if !reflect.DeepEqual(got.Tally, tt.wantTally) {
+				t.Errorf("tally = %v, want %v", got.Tally, tt.wantTally)
+			}
+			if got.TurnoutPct != tt.wantTurnout || got.QuorumReached != tt.wantQuorum || got.Winner != tt.wantWinner {
+				t.Errorf("turnout, quorum, winner = %v, %v, %q, want %v, %v, %q",
+					got.TurnoutPct, got.QuorumReached, got.Winner, tt.wantTurnout, tt.wantQuorum, tt.wantWinner)
+			}
+			if got.VoteCount != len(tt.votes) || got.Final {
+				t.Errorf("vote count, final = %d, %v, want %d, false", got.VoteCount, got.Final, len(tt.votes))
+			}
+		})
+	}
+}
+
+func TestBallotMessage(t *testing.T) {
+	proposal := models.Proposal{ID: "0b5f3a0e-9a43-4a7e-9d56-7f8e1f2f6a11", Title: "Reforma da fachada"}
+	issuedAt := time.Date(2025, 3, 10, 9, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+	got := ballotMessage(proposal, "So11111111111111111111111111111111111111112", "approve", 1500.25, "n0nce", issuedAt, issuedAt.Add(ballotChallengeTTL))
+	want := "tiquin wants you to cast a ballot with your Solana account:\n" +
+		"So11111111111111111111111111111111111111112\n\n" +
+		"Proposal: Reforma da fachada\n" +
+		"Proposal ID: 0b5f3a0e-9a43-4a7e-9d56-7f8e1f2f6a11\n" +
+		"Option: approve\n" +
+		"Weight: 1500.25\n" +
+		"Nonce: n0nce\n" +
+		"Issued At: 2025-03-10T12:30:00Z\n" +
+		"Expiration Time: 2025-03-10T12:40:00Z"
+	if got != want {
+		t.Fatalf("ballotMessage() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResultHash(t *testing.T) {
+	proposal := models.Proposal{ID: "p1", SnapshotID: "s1", Options: []string{"approve", "reject"}}
+	votes := []models.Vote{
+		{SolanaPubKey: "voter1", Option: "approve", Weight: 600, Message: "m1", Signature: "sig1"},
+		{SolanaPubKey: "voter2", Option: "reject", Weight: 100, Message: "m2", Signature: "sig2"},
+	}
+	result := tallyVotes(proposal, 1000, votes)
+	hashOf := func(t *testing.T, proposal models.Proposal, result models.ProposalResult, votes []models.Vote) string {
+		t.Helper()
+		hash, err := resultHash(proposal, result, votes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	base := hashOf(t, proposal, result, votes)
+	if len(base) != 64 {
+		t.Fatalf("resultHash() = %q, want a hex SHA-256", base)
+	}
+
+	// Fields outside the canonical result do not change the hash
+	anchored := result
+	anchored.Final, anchored.ResultHash, anchored.AnchorTxID, anchored.VoteCount = true, base, "sig", 99
+	reordered := result
+	reordered.Tally = map[string]float64{"reject": 100, "approve": 600}
+	for name, r := range map[string]models.ProposalResult{"anchored": anchored, "tally built in another order": reordered} {
+		if got := hashOf(t, proposal, r, votes); got != base {
+			t.Errorf("%s: resultHash() = %s, want %s", name, got, base)
+		}
+	}
+
+	forged := append([]models.Vote{}, votes...)
+	forged[1].Signature = "forged"
+	otherSnapshot := proposal
+	otherSnapshot.SnapshotID = "s2"
+	noWinner := result
+	noWinner.Winner = ""
+	tests := []struct {
+		name     string
+		proposal models.Proposal
+		result   models.ProposalResult
+		votes    []models.Vote
+	}{
+		{"vote signature", proposal, result, forged},
+		{"vote order", proposal, result, []models.Vote{votes[1], votes[0]}},
+		{"missing vote", proposal, result, votes[:1]},
+		{"snapshot", otherSnapshot, result, votes},
+		{"winner", proposal, noWinner, votes},
+	}
+	for _, tt := range tests {
+		if got := hashOf(t, tt.proposal, tt.result, tt.votes); got == base {
+			t.Errorf("changing the %s kept the hash %s", tt.name, got)
+		}
+	}
+}
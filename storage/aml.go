@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// ImportWatchlist upserts the entries of a list. With replace, entries of the
+// list missing from this import are removed along with their undecided hits.
+func (d *DB) ImportWatchlist(listName string, entries []models.WatchlistEntry, replace bool) (removed int64, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	fingerprints := make([]string, len(entries))
+	for i, e := range entries {
+		fingerprints[i] = e.Fingerprint
+		_, err = tx.NamedExec(
+			`INSERT INTO watchlist_entries (id, list_name, list_type, name, tax_id, wallet_address, source_ref, fingerprint, imported_at)
+			 VALUES (:id, :list_name, :list_type, :name, :tax_id, :wallet_address, :source_ref, :fingerprint, :imported_at)
+			 ON CONFLICT (list_name, fingerprint) DO UPDATE
+			 SET list_type = EXCLUDED.list_type, source_ref = EXCLUDED.source_ref, imported_at = EXCLUDED.imported_at`,
+			e,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import entry %d: %w", i+1, err)
+		}
+	}
+
+	if replace {
+		_, err = tx.Exec(
+			`DELETE FROM screening_hits
+			 WHERE status = $1 AND entry_id IN (
+			     SELECT id FROM watchlist_entries WHERE list_name = $2 AND NOT (fingerprint = ANY($3)))`,
+			models.HitStatusOpen, listName, pq.Array(fingerprints),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to remove hits of dropped entries: %w", err)
+		}
+		var result sql.Result
+		result, err = tx.Exec(
+			`DELETE FROM watchlist_entries WHERE list_name = $1 AND NOT (fingerprint = ANY($2))`,
+			listName, pq.Array(fingerprints),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to remove dropped entries: %w", err)
+		}
+		removed, _ = result.RowsAffected()
+	}
+
+	return removed, tx.Commit()
+}
+
+// GetWatchlistEntries lists every entry of every list.
+func (d *DB) GetWatchlistEntries() ([]models.WatchlistEntry, error) {
+	var entries []models.WatchlistEntry
+	err := d.Select(&entries, "SELECT * FROM watchlist_entries")
+	return entries, err
+}
+
+// GetWatchlistEntriesByIDs retrieves the given entries.
+func (d *DB) GetWatchlistEntriesByIDs(ids []string) ([]models.WatchlistEntry, error) {
+	var entries []models.WatchlistEntry
+	err := d.Select(&entries, "SELECT * FROM watchlist_entries WHERE id = ANY($1)", pq.Array(ids))
+	return entries, err
+}
+
+// RecordScreening stores new hits for a party (existing hits, whatever their
+// status, are kept as they are) and the screening audit record, whose result
+// reflects every open or confirmed hit of the party.
+func (d *DB) RecordScreening(screening models.Screening, hits []models.ScreeningHit) (models.Screening, error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return screening, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, h := range hits {
+		_, err = tx.NamedExec(
+			`INSERT INTO screening_hits (id, entry_id, user_id, solana_pub_key, match_type, score, status, created_at)
+			 VALUES (:id, :entry_id, :user_id, :solana_pub_key, :match_type, :score, :status, :created_at)
+			 ON CONFLICT (entry_id, solana_pub_key) DO NOTHING`,
+			h,
+		)
+		if err != nil {
+			return screening, fmt.Errorf("failed to record hit: %w", err)
+		}
+	}
+
+	err = tx.Get(&screening.HitCount,
+		`SELECT COUNT(*) FROM screening_hits WHERE solana_pub_key = $1 AND status = ANY($2)`,
+		screening.SolanaPubKey, pq.Array([]string{models.HitStatusOpen, models.HitStatusConfirmed}),
+	)
+	if err != nil {
+		return screening, fmt.Errorf("failed to count hits: %w", err)
+	}
+	screening.Result = models.ScreeningResultClear
+	if screening.HitCount > 0 {
+		screening.Result = models.ScreeningResultBlocked
+	}
+
+	_, err = tx.NamedExec(
+		`INSERT INTO screenings (id, context, reference, user_id, solana_pub_key, result, hit_count, created_at)
+		 VALUES (:id, :context, :reference, :user_id, :solana_pub_key, :result, :hit_count, :created_at)`,
+		screening,
+	)
+	if err != nil {
+		return screening, fmt.Errorf("failed to record screening: %w", err)
+	}
+
+	return screening, tx.Commit()
+}
+
+// GetScreeningsByPubKey lists the screenings of a wallet, most recent first.
+func (d *DB) GetScreeningsByPubKey(solanaPubKey string) ([]models.Screening, error) {
+	var screenings []models.Screening
+	err := d.Select(&screenings,
+		"SELECT * FROM screenings WHERE solana_pub_key = $1 ORDER BY created_at DESC", solanaPubKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if screenings == nil {
+		screenings = []models.Screening{}
+	}
+	return screenings, nil
+}
+
+// GetScreeningHits lists hits with the given status, oldest first.
+func (d *DB) GetScreeningHits(status string) ([]models.ScreeningHit, error) {
+	var hits []models.ScreeningHit
+	err := d.Select(&hits, "SELECT * FROM screening_hits WHERE status = $1 ORDER BY created_at", status)
+	if err != nil {
+		return nil, err
+	}
+	if hits == nil {
+		hits = []models.ScreeningHit{}
+	}
+	for i, hit := range hits {
+		if hits[i].DecisionNote, err = d.Fields.Decrypt(hit.DecisionNote, fieldAAD("screening_hits", "decision_note", hit.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// GetScreeningHit retrieves a hit by ID.
+func (d *DB) GetScreeningHit(id string) (models.ScreeningHit, bool, error) {
+	var hit models.ScreeningHit
+	err := d.Get(&hit, "SELECT * FROM screening_hits WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return hit, false, nil
+		}
+		return hit, false, err
+	}
+	if hit.DecisionNote, err = d.Fields.Decrypt(hit.DecisionNote, fieldAAD("screening_hits", "decision_note", hit.ID)); err != nil {
+		return hit, false, err
+	}
+	return hit, true, nil
+}
+
+// DecideScreeningHit records an analyst decision on an open hit. It returns
+// false when the hit was already decided.
+func (d *DB) DecideScreeningHit(id, status, decidedBy string, note *string) (bool, error) {
+	note, err := d.Fields.Encrypt(note, fieldAAD("screening_hits", "decision_note", id))
+	if err != nil {
+		return false, err
+	}
+	result, err := d.Exec(
+		`UPDATE screening_hits SET status = $1, decided_by = $2, decision_note = $3, decided_at = NOW()
+		 WHERE id = $4 AND status = $5`,
+		status, decidedBy, note, id, models.HitStatusOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
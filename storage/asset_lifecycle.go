@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// TransitionAssetStatus moves an asset from change.FromStatus to
+// change.ToStatus and records the change, atomically. It returns false when
+// the asset was no longer in change.FromStatus.
+func (d *DB) TransitionAssetStatus(change models.AssetStatusChange) (ok bool, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !ok {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(`UPDATE assets SET status = $1, version = version + 1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		change.ToStatus, change.AssetID, change.FromStatus)
+	if err != nil {
+		return false, fmt.Errorf("failed to update asset status: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, nil
+	}
+
+	_, err = tx.NamedExec(
+		`INSERT INTO asset_status_changes (id, asset_id, from_status, to_status, reason, ordered_by, freeze_status,
+		                                   created_at, updated_at)
+		 VALUES (:id, :asset_id, :from_status, :to_status, :reason, :ordered_by, :freeze_status, :created_at, :updated_at)`,
+		change,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record status change: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAssetStatusChanges lists the lifecycle transitions of an asset, oldest first.
+func (d *DB) GetAssetStatusChanges(assetID string) ([]models.AssetStatusChange, error) {
+	var changes []models.AssetStatusChange
+	err := d.Select(&changes, "SELECT * FROM asset_status_changes WHERE asset_id = $1 ORDER BY created_at", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []models.AssetStatusChange{}
+	}
+	return changes, nil
+}
+
+// UpdateAssetStatusChangeFreeze records the outcome of freezing or thawing
+// holder accounts after a status change.
+func (d *DB) UpdateAssetStatusChangeFreeze(id, freezeStatus string, accountsAffected int, lastError *string) error {
+	_, err := d.Exec(
+		`UPDATE asset_status_changes SET freeze_status = $1, accounts_affected = $2, last_error = $3, updated_at = NOW()
+		 WHERE id = $4`,
+		freezeStatus, accountsAffected, lastError, id,
+	)
+	return err
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// SaveBridge creates a bridge.
+func (d *DB) SaveBridge(bridge models.Bridge) error {
+	query := `
+		INSERT INTO bridges (id, asset_id, mint, custody_account, evm_token, evm_custody_address,
+		                     last_solana_signature, last_evm_block, created_at)
+		VALUES (:id, :asset_id, :mint, :custody_account, :evm_token, :evm_custody_address,
+		        :last_solana_signature, :last_evm_block, :created_at)
+	`
+	_, err := d.NamedExec(query, bridge)
+	return err
+}
+
+// GetBridgeByAssetID retrieves the bridge of an asset.
+func (d *DB) GetBridgeByAssetID(assetID string) (models.Bridge, bool, error) {
+	var bridge models.Bridge
+	err := d.Get(&bridge, "SELECT * FROM bridges WHERE asset_id = $1", assetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return bridge, false, nil
+		}
+		return bridge, false, err
+	}
+	return bridge, true, nil
+}
+
+// GetBridges lists all bridges.
+func (d *DB) GetBridges() ([]models.Bridge, error) {
+	var bridges []models.Bridge
+	if err := d.Select(&bridges, "SELECT * FROM bridges ORDER BY created_at"); err != nil {
+		return nil, err
+	}
+	if bridges == nil {
+		bridges = []models.Bridge{}
+	}
+	return bridges, nil
+}
+
+// AdvanceBridgeSolanaCursor records the newest custody transaction scanned.
+func (d *DB) AdvanceBridgeSolanaCursor(id, signature string) error {
+	_, err := d.Exec(`UPDATE bridges SET last_solana_signature = $1 WHERE id = $2`, signature, id)
+	return err
+}
+
+// AdvanceBridgeEVMCursor records the newest EVM block scanned.
+func (d *DB) AdvanceBridgeEVMCursor(id string, block int64) error {
+	_, err := d.Exec(`UPDATE bridges SET last_evm_block = $1 WHERE id = $2 AND last_evm_block < $1`, block, id)
+	return err
+}
+
+// RecordBridgeTransfer records an observed lock. It returns false when the
+// lock was already recorded, so each one is mirrored once.
+func (d *DB) RecordBridgeTransfer(transfer models.BridgeTransfer) (bool, error) {
+	result, err := d.NamedExec(`
+		INSERT INTO bridge_transfers (id, bridge_id, asset_id, source_chain, source_tx_id, source_index, sender,
+		                              recipient, user_id, amount, status, last_error, created_at, updated_at)
+		VALUES (:id, :bridge_id, :asset_id, :source_chain, :source_tx_id, :source_index, :sender,
+		        :recipient, :user_id, :amount, :status, :last_error, :created_at, :updated_at)
+		ON CONFLICT (source_chain, source_tx_id, source_index) DO NOTHING
+	`, transfer)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// GetBridgeTransfer retrieves a bridge transfer by ID.
+func (d *DB) GetBridgeTransfer(id string) (models.BridgeTransfer, bool, error) {
+	var transfer models.BridgeTransfer
+	err := d.Get(&transfer, "SELECT * FROM bridge_transfers WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return transfer, false, nil
+		}
+		return transfer, false, err
+	}
+	return transfer, true, nil
+}
+
+// GetBridgeTransfersByAssetID lists the bridge transfers of an asset, newest first.
+func (d *DB) GetBridgeTransfersByAssetID(assetID string) ([]models.BridgeTransfer, error) {
+	var transfers []models.BridgeTransfer
+	err := d.Select(&transfers,
+		`SELECT * FROM bridge_transfers WHERE asset_id = $1 ORDER BY created_at DESC`,
+		assetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if transfers == nil {
+		transfers = []models.BridgeTransfer{}
+	}
+	return transfers, nil
+}
+
+// GetBridgeTransfersBySourceTxID lists the bridge transfers started by a
+// source transaction.
+func (d *DB) GetBridgeTransfersBySourceTxID(txID string) ([]models.BridgeTransfer, error) {
+	var transfers []models.BridgeTransfer
+	err := d.Select(&transfers,
+		`SELECT * FROM bridge_transfers WHERE source_tx_id = $1 ORDER BY source_index`,
+		txID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if transfers == nil {
+		transfers = []models.BridgeTransfer{}
+	}
+	return transfers, nil
+}
+
+// GetActiveBridgeTransfers lists the bridge transfers whose mirror is not
+// confirmed yet, oldest first.
+func (d *DB) GetActiveBridgeTransfers() ([]models.BridgeTransfer, error) {
+	var transfers []models.BridgeTransfer
+	err := d.Select(&transfers,
+		`SELECT * FROM bridge_transfers WHERE status = ANY($1) ORDER BY created_at`,
+		pq.Array([]string{models.BridgeTransferStatusPending, models.BridgeTransferStatusSending}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if transfers == nil {
+		transfers = []models.BridgeTransfer{}
+	}
+	return transfers, nil
+}
+
+// MarkBridgeTransferSigned records the signed mirrored transfer before it is
+// sent, moving a pending transfer to sending. It returns false when the
+// transfer was not pending.
+func (d *DB) MarkBridgeTransferSigned(id, signedTx, txID string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE bridge_transfers SET status = $1, destination_transaction = $2, destination_tx_id = $3,
+		        last_error = NULL, updated_at = NOW()
+		 WHERE id = $4 AND status = $5`,
+		models.BridgeTransferStatusSending, signedTx, txID, id, models.BridgeTransferStatusPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// CompleteBridgeTransfer marks a transfer whose mirror was confirmed, as
+// refunded when it was a refund.
+func (d *DB) CompleteBridgeTransfer(id string) error {
+	_, err := d.Exec(
+		`UPDATE bridge_transfers SET status = CASE WHEN refund THEN $1 ELSE $2 END, completed_at = NOW(), updated_at = NOW()
+		 WHERE id = $3 AND status = $4`,
+		models.BridgeTransferStatusRefunded, models.BridgeTransferStatusCompleted, id, models.BridgeTransferStatusSending,
+	)
+	return err
+}
+
+// RefundBridgeTransfer returns a rejected Solana lock to pending as a refund
+// to its sender. It returns false when the transfer is not a rejected Solana
+// lock.
+func (d *DB) RefundBridgeTransfer(id string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE bridge_transfers SET status = $1, refund = TRUE, recipient = sender, last_error = NULL, updated_at = NOW()
+		 WHERE id = $2 AND status = $3 AND source_chain = $4`,
+		models.BridgeTransferStatusPending, id, models.BridgeTransferStatusRejected, models.ChainSolana,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// ResetBridgeTransfer returns a transfer whose mirror failed or can no
+// longer land to pending, so a new one is signed.
+func (d *DB) ResetBridgeTransfer(id, reason string) error {
+	_, err := d.Exec(
+		`UPDATE bridge_transfers SET status = $1, destination_transaction = NULL, destination_tx_id = NULL,
+		        last_error = $2, updated_at = NOW()
+		 WHERE id = $3 AND status = $4`,
+		models.BridgeTransferStatusPending, reason, id, models.BridgeTransferStatusSending,
+	)
+	return err
+}
+
+// FailBridgeTransfer records why a pending transfer could not be mirrored yet.
+func (d *DB) FailBridgeTransfer(id, reason string) error {
+	_, err := d.Exec(
+		`UPDATE bridge_transfers SET last_error = $1, updated_at = NOW() WHERE id = $2`,
+		reason, id,
+	)
+	return err
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// GetHoldingsByAssetID aggregates the current token records of an asset per owner.
+func (d *DB) GetHoldingsByAssetID(assetID string) ([]models.CapTableEntry, error) {
+	query := `
+		SELECT u.id AS holder_id, u.solana_pub_key, u.name, SUM(t.amount) AS amount, true AS registered
+		FROM tokens t
+		JOIN users u ON u.id = t.owner_id
+		WHERE t.asset_id = $1
+		GROUP BY u.id, u.solana_pub_key, u.name
+		HAVING SUM(t.amount) > 0
+		ORDER BY amount DESC
+	`
+	return d.selectHoldings(query, assetID)
+}
+
+// GetHoldingsAt aggregates the ledger of an asset per wallet up to an
+// instant. Token records are updated in place and cannot be read in the
+// past; the ledger only grows, and its entries carry their block time.
+func (d *DB) GetHoldingsAt(assetID string, asOf time.Time) ([]models.CapTableEntry, error) {
+	query := `
+		SELECT COALESCE(u.id::text, '') AS holder_id, l.solana_pub_key, u.name, l.amount, u.id IS NOT NULL AS registered
+		FROM (SELECT solana_pub_key, (ARRAY_AGG(owner_id) FILTER (WHERE owner_id IS NOT NULL))[1] AS owner_id, SUM(amount) AS amount
+		      FROM ledger_entries
+		      WHERE asset_id = $1 AND block_time <= $2
+		      GROUP BY solana_pub_key
+		      HAVING SUM(amount) > 0) l
+		LEFT JOIN users u ON u.id = l.owner_id
+		ORDER BY l.amount DESC
+	`
+	return d.selectHoldings(query, assetID, asOf)
+}
+
+// selectHoldings runs a holdings query and decrypts the holders' names.
+func (d *DB) selectHoldings(query string, args ...any) ([]models.CapTableEntry, error) {
+	var holdings []models.CapTableEntry
+	if err := d.Select(&holdings, query, args...); err != nil {
+		return nil, err
+	}
+	for i, h := range holdings {
+		if h.HolderID == "" {
+			continue
+		}
+		name, err := d.Fields.Decrypt(h.Name, fieldAAD("users", "name", h.HolderID))
+		if err != nil {
+			return nil, err
+		}
+		holdings[i].Name = name
+	}
+	if holdings == nil {
+		holdings = []models.CapTableEntry{}
+	}
+	return holdings, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// SaveRuleSet creates or replaces the rule set of an asset, bumping its version.
+func (d *DB) SaveRuleSet(assetID string, rules models.ComplianceRules) (models.AssetRuleSet, error) {
+	var ruleSet models.AssetRuleSet
+	err := d.Get(&ruleSet,
+		`INSERT INTO asset_rule_sets (asset_id, rules, version, updated_at)
+		 VALUES ($1, $2, 1, NOW())
+		 ON CONFLICT (asset_id) DO UPDATE
+		 SET rules = EXCLUDED.rules, version = asset_rule_sets.version + 1, updated_at = NOW()
+		 RETURNING *`,
+		assetID, rules,
+	)
+	return ruleSet, err
+}
+
+// GetRuleSet retrieves the rule set of an asset.
+func (d *DB) GetRuleSet(assetID string) (models.AssetRuleSet, bool, error) {
+	var ruleSet models.AssetRuleSet
+	err := d.Get(&ruleSet, "SELECT * FROM asset_rule_sets WHERE asset_id = $1", assetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ruleSet, false, nil
+		}
+		return ruleSet, false, err
+	}
+	return ruleSet, true, nil
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// SaveCorporateAction creates a scheduled corporate action.
+func (d *DB) SaveCorporateAction(action models.CorporateAction) error {
+	query := `
+		INSERT INTO corporate_actions (id, asset_id, action_type, ratio_from, ratio_to, effective_date, rounding_unit, cash_in_lieu_price, status, created_at)
+		VALUES (:id, :asset_id, :action_type, :ratio_from, :ratio_to, :effective_date, :rounding_unit, :cash_in_lieu_price, :status, :created_at)
+	`
+	_, err := d.NamedExec(query, action)
+	return err
+}
+
+// GetCorporateAction retrieves a corporate action by ID, without its adjustments.
+func (d *DB) GetCorporateAction(id string) (models.CorporateAction, bool, error) {
+	var action models.CorporateAction
+	err := d.Get(&action, "SELECT * FROM corporate_actions WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return action, false, nil
+		}
+		return action, false, err
+	}
+	return action, true, nil
+}
+
+// GetCorporateActionsByAssetID lists the corporate actions of an asset, latest effective first.
+func (d *DB) GetCorporateActionsByAssetID(assetID string) ([]models.CorporateAction, error) {
+	var actions []models.CorporateAction
+	err := d.Select(&actions, "SELECT * FROM corporate_actions WHERE asset_id = $1 ORDER BY effective_date DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if actions == nil {
+		actions = []models.CorporateAction{}
+	}
+	return actions, nil
+}
+
+// GetDueCorporateActions lists scheduled actions whose effective date has passed.
+func (d *DB) GetDueCorporateActions(now time.Time) ([]models.CorporateAction, error) {
+	var actions []models.CorporateAction
+	err := d.Select(&actions,
+		"SELECT * FROM corporate_actions WHERE status = $1 AND effective_date <= $2 ORDER BY effective_date",
+		models.CorporateActionStatusScheduled, now,
+	)
+	return actions, err
+}
+
+// ClaimCorporateAction moves an action to `to` only if it is currently in one
+// of the `from` statuses. It returns false when another caller got there first.
+func (d *DB) ClaimCorporateAction(id, to string, from ...string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE corporate_actions SET status = $1 WHERE id = $2 AND status = ANY($3)`,
+		to, id, pq.Array(from),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// UpdateCorporateActionStatus sets the status of a corporate action.
+func (d *DB) UpdateCorporateActionStatus(id, status string) error {
+	_, err := d.Exec(`UPDATE corporate_actions SET status = $1 WHERE id = $2`, status, id)
+	return err
+}
+
+// StartCorporateAction stores the computed adjustments of an action and
+// changes the asset's total_shares, all in one transaction.
+func (d *DB) StartCorporateAction(action models.CorporateAction, adjustments []models.HoldingAdjustment) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, a := range adjustments {
+		_, err = tx.NamedExec(
+			`INSERT INTO holding_adjustments (id, action_id, owner_id, solana_pub_key, holding_before, holding_after, delta_atomic,
+			                                  fractional_shares, cash_in_lieu_amount, status, updated_at)
+			 VALUES (:id, :action_id, :owner_id, :solana_pub_key, :holding_before, :holding_after, :delta_atomic,
+			         :fractional_shares, :cash_in_lieu_amount, :status, :updated_at)`,
+			a,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert adjustment for %s: %w", a.SolanaPubKey, err)
+		}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE corporate_actions SET snapshot_id = $1, shares_before = $2, shares_after = $3, executed_at = $4 WHERE id = $5`,
+		action.SnapshotID, action.SharesBefore, action.SharesAfter, action.ExecutedAt, action.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update corporate action: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE assets SET total_shares = $1 WHERE id = $2`, action.SharesAfter, action.AssetID)
+	if err != nil {
+		return fmt.Errorf("failed to update total shares: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetAdjustments lists the adjustments of an action, optionally restricted
+// to the given statuses.
+func (d *DB) GetAdjustments(actionID string, statuses ...string) ([]models.HoldingAdjustment, error) {
+	var adjustments []models.HoldingAdjustment
+	err := d.Select(&adjustments,
+		`SELECT * FROM holding_adjustments
+		 WHERE action_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2))
+		 ORDER BY solana_pub_key`,
+		actionID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if adjustments == nil {
+		adjustments = []models.HoldingAdjustment{}
+	}
+	return adjustments, nil
+}
+
+// MarkAdjustmentsSent records the transaction carrying a batch of adjustments.
+func (d *DB) MarkAdjustmentsSent(ids []string, txID string) error {
+	_, err := d.Exec(
+		`UPDATE holding_adjustments SET status = $1, transaction_id = $2, attempts = attempts + 1, last_error = NULL, updated_at = NOW()
+		 WHERE id = ANY($3)`,
+		models.AdjustmentStatusSent, txID, pq.Array(ids),
+	)
+	return err
+}
+
+// MarkAdjustmentsFailed records a failed attempt for a batch of adjustments.
+// countAttempt is false when the attempt was already counted on send.
+func (d *DB) MarkAdjustmentsFailed(ids []string, reason string, countAttempt bool) error {
+	increment := 0
+	if countAttempt {
+		increment = 1
+	}
+	_, err := d.Exec(
+		`UPDATE holding_adjustments SET status = $1, last_error = $2, attempts = attempts + $3, updated_at = NOW()
+		 WHERE id = ANY($4)`,
+		models.AdjustmentStatusFailed, reason, increment, pq.Array(ids),
+	)
+	return err
+}
+
+// ApplyAdjustments books confirmed adjustments: marks them applied, journals
+// each one in the ledger and updates the holders' token records.
+func (d *DB) ApplyAdjustments(assetID string, adjustments []models.HoldingAdjustment, txID string) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, a := range adjustments {
+		delta := float64(a.DeltaAtomic) / 1e9
+
+		_, err = tx.Exec(
+			`UPDATE holding_adjustments SET status = $1, updated_at = NOW() WHERE id = $2`,
+			models.AdjustmentStatusApplied, a.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to mark adjustment applied: %w", err)
+		}
+
+		err = recordLedgerEntry(tx, models.LedgerEntry{
+			AssetID:       assetID,
+			OwnerID:       a.OwnerID,
+			SolanaPubKey:  a.SolanaPubKey,
+			Amount:        delta,
+			EntryType:     models.LedgerEntrySplit,
+			TransactionID: txID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to journal adjustment for %s: %w", a.SolanaPubKey, err)
+		}
+
+		if a.OwnerID != nil {
+			if err = adjustOwnerTokens(tx, assetID, *a.OwnerID, delta, txID); err != nil {
+				return fmt.Errorf("failed to adjust token records for %s: %w", a.SolanaPubKey, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// adjustOwnerTokens credits (positive delta) a new token record to an owner,
+// or debits (negative delta) their existing records, newest first.
+func adjustOwnerTokens(tx *sqlx.Tx, assetID, ownerID string, delta float64, txID string) error {
+	if delta > 0 {
+		_, err := tx.Exec(
+			`INSERT INTO tokens (id, asset_id, owner_id, amount, smart_contract_rules, is_tradable,
+			                     mint_address, token_account_address, transaction_id, created_at)
+			 SELECT gen_random_uuid(), a.id, $2, $3, a.name || ' rules', true,
+			        a.mint_address, '', $4 || ':' || $2, NOW()
+			 FROM assets a WHERE a.id = $1`,
+			assetID, ownerID, delta, txID,
+		)
+		return err
+	}
+
+	var records []models.Token
+	err := tx.Select(&records,
+		`SELECT * FROM tokens WHERE asset_id = $1 AND owner_id = $2 AND amount > 0 ORDER BY created_at DESC FOR UPDATE`,
+		assetID, ownerID,
+	)
+	if err != nil {
+		return err
+	}
+	remaining := -delta
+	for _, r := range records {
+		if remaining <= 0 {
+			break
+		}
+		debit := r.Amount
+		if debit > remaining {
+			debit = remaining
+		}
+		if _, err := tx.Exec(`UPDATE tokens SET amount = amount - $1 WHERE id = $2`, debit, r.ID); err != nil {
+			return err
+		}
+		remaining -= debit
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+var (
+	// ErrWalletNotActive is returned by ReserveCustodialSignature when the
+	// wallet's keys were exported.
+	ErrWalletNotActive = errors.New("custodial wallet is not active")
+	// ErrTransferLimitExceeded is returned by ReserveCustodialSignature when
+	// the transfer exceeds one of the wallet's limits.
+	ErrTransferLimitExceeded = errors.New("transfer exceeds the wallet's limits")
+)
+
+// custodialSignatureWindow is the period the daily transfer limit applies to.
+const custodialSignatureWindow = 24 * time.Hour
+
+// SaveCustodialUser creates a user together with their custodial wallet, so
+// no custodial user exists without keys.
+func (d *DB) SaveCustodialUser(user models.User, wallet models.CustodialWallet) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	row, err := d.sealUser(user)
+	if err != nil {
+		return err
+	}
+	_, err = tx.NamedExec(`
+		INSERT INTO users (id, name, email, email_index, solana_pub_key, evm_address, custody, tax_id, tax_id_index,
+		                   jurisdiction, investor_category, created_at, updated_at)
+		VALUES (:id, :name, :email, :email_index, :solana_pub_key, :evm_address, :custody, :tax_id, :tax_id_index,
+		        :jurisdiction, :investor_category, :created_at, :updated_at)
+	`, row)
+	if err != nil {
+		return fmt.Errorf("failed to save user: %w", err)
+	}
+	_, err = tx.NamedExec(`
+		INSERT INTO custodial_wallets (user_id, solana_pub_key, evm_address, master_key_id, encrypted_data_key,
+		                               encrypted_solana_key, encrypted_evm_key, status, authorization_key, created_at, updated_at)
+		VALUES (:user_id, :solana_pub_key, :evm_address, :master_key_id, :encrypted_data_key,
+		        :encrypted_solana_key, :encrypted_evm_key, :status, :authorization_key, :created_at, :updated_at)
+	`, wallet)
+	if err != nil {
+		return fmt.Errorf("failed to save custodial wallet: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetCustodialWallet retrieves a user's custodial wallet.
+func (d *DB) GetCustodialWallet(userID string) (models.CustodialWallet, bool, error) {
+	var wallet models.CustodialWallet
+	err := d.Get(&wallet, "SELECT * FROM custodial_wallets WHERE user_id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return wallet, false, nil
+		}
+		return wallet, false, err
+	}
+	return wallet, true, nil
+}
+
+// SetCustodialWalletLimits replaces a wallet's transfer limits; nil removes a limit.
+func (d *DB) SetCustodialWalletLimits(userID string, maxTransfer, dailyLimit *float64) (models.CustodialWallet, bool, error) {
+	var wallet models.CustodialWallet
+	err := d.Get(&wallet,
+		`UPDATE custodial_wallets
+		 SET max_transfer_amount = $1, daily_transfer_limit = $2, updated_at = NOW()
+		 WHERE user_id = $3
+		 RETURNING *`,
+		maxTransfer, dailyLimit, userID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return wallet, false, nil
+		}
+		return wallet, false, err
+	}
+	return wallet, true, nil
+}
+
+// ReserveCustodialSignature checks a transfer against the wallet's status
+// and limits and records it, atomically: concurrent transfers from the same
+// wallet are serialized on the wallet's row, so together they cannot exceed
+// the daily limit. It returns the wallet the transfer will be signed with.
+func (d *DB) ReserveCustodialSignature(sig models.CustodialSignature) (wallet models.CustodialWallet, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return wallet, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = tx.Get(&wallet, `SELECT * FROM custodial_wallets WHERE user_id = $1 FOR UPDATE`, sig.UserID); err != nil {
+		return wallet, fmt.Errorf("failed to lock custodial wallet: %w", err)
+	}
+	var signed float64
+	if wallet.DailyTransferLimit != nil {
+		err = tx.Get(&signed,
+			`SELECT COALESCE(SUM(amount), 0) FROM custodial_signatures
+			 WHERE user_id = $1 AND asset_id = $2 AND created_at > $3`,
+			sig.UserID, sig.AssetID, sig.CreatedAt.Add(-custodialSignatureWindow),
+		)
+		if err != nil {
+			return wallet, fmt.Errorf("failed to sum recent transfers: %w", err)
+		}
+	}
+	if err = checkTransferLimits(wallet, sig.Amount, signed); err != nil {
+		return wallet, err
+	}
+
+	_, err = tx.NamedExec(
+		`INSERT INTO custodial_signatures (id, user_id, asset_id, amount, created_at)
+		 VALUES (:id, :user_id, :asset_id, :amount, :created_at)`,
+		sig,
+	)
+	if err != nil {
+		return wallet, fmt.Errorf("failed to record signature: %w", err)
+	}
+
+	err = tx.Commit()
+	return wallet, err
+}
+
+// checkTransferLimits checks a transfer of `amount` against the wallet's
+// status and limits, given the amount `signed` in the last 24 hours.
+func checkTransferLimits(wallet models.CustodialWallet, amount, signed float64) error {
+	if wallet.Status != models.CustodialWalletActive {
+		return ErrWalletNotActive
+	}
+	if wallet.MaxTransferAmount != nil && amount > *wallet.MaxTransferAmount {
+		return fmt.Errorf("%w: %g is above the %g per-transfer limit", ErrTransferLimitExceeded, amount, *wallet.MaxTransferAmount)
+	}
+	if wallet.DailyTransferLimit != nil && signed+amount > *wallet.DailyTransferLimit {
+		return fmt.Errorf("%w: %g already transferred in the last 24 hours, limit is %g",
+			ErrTransferLimitExceeded, signed, *wallet.DailyTransferLimit)
+	}
+	return nil
+}
+
+// SetCustodialSignatureTxID records the transaction a reserved signature produced.
+func (d *DB) SetCustodialSignatureTxID(id, txID string) error {
+	_, err := d.Exec(`UPDATE custodial_signatures SET transaction_id = $1 WHERE id = $2`, txID, id)
+	return err
+}
+
+// ReleaseCustodialSignature drops a reserved signature whose transfer was
+// never sent, so it no longer counts towards the daily limit.
+func (d *DB) ReleaseCustodialSignature(id string) error {
+	_, err := d.Exec(`DELETE FROM custodial_signatures WHERE id = $1 AND transaction_id IS NULL`, id)
+	return err
+}
+
+// SetCustodialWalletExportChallenge stores the message the user must sign
+// to export a wallet, replacing any earlier one. It returns false when the
+// wallet's keys were already erased.
+func (d *DB) SetCustodialWalletExportChallenge(userID, message string, expiresAt time.Time) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE custodial_wallets SET export_challenge = $1, export_challenge_expires_at = $2, updated_at = NOW()
+		 WHERE user_id = $3 AND status IN ($4, $5)`,
+		message, expiresAt, userID, models.CustodialWalletActive, models.CustodialWalletExportPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// BeginCustodialWalletExport redeems an export challenge and stops the
+// backend from signing with the wallet, whose keys are being handed over.
+// The keys stay stored until the export is confirmed, so a lost response
+// can be retried. It returns false when the challenge was already redeemed,
+// replaced or expired, or the keys were erased.
+func (d *DB) BeginCustodialWalletExport(userID, challenge string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE custodial_wallets
+		 SET status = $1, export_challenge = NULL, export_challenge_expires_at = NULL, updated_at = NOW()
+		 WHERE user_id = $2 AND status IN ($3, $1) AND export_challenge = $4 AND export_challenge_expires_at > NOW()`,
+		models.CustodialWalletExportPending, userID, models.CustodialWalletActive, challenge,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// MarkCustodialWalletExported erases the encrypted keys of a wallet whose
+// export is pending and hands the user over to self-custody, atomically. It
+// returns false when no export was pending, so keys are erased only once.
+func (d *DB) MarkCustodialWalletExported(userID string) (exported bool, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !exported {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(
+		`UPDATE custodial_wallets
+		 SET status = $1, encrypted_data_key = NULL, encrypted_solana_key = NULL, encrypted_evm_key = NULL,
+		     exported_at = NOW(), updated_at = NOW()
+		 WHERE user_id = $2 AND status = $3`,
+		models.CustodialWalletExported, userID, models.CustodialWalletExportPending,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to erase custodial keys: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, nil
+	}
+	if _, err = tx.Exec(`UPDATE users SET custody = $1 WHERE id = $2`, models.CustodySelf, userID); err != nil {
+		return false, fmt.Errorf("failed to update user custody: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
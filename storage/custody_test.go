@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestCheckTransferLimits(t *testing.T) {
+	limit := func(v float64) *float64 { return &v }
+	active := models.CustodialWallet{Status: models.CustodialWalletActive}
+	limited := models.CustodialWallet{Status: models.CustodialWalletActive, MaxTransferAmount: limit(100), DailyTransferLimit: limit(250)}
+
+	tests := []struct {
+		name    string
+		wallet  models.CustodialWallet
+		amount  float64
+		signed  float64
+		wantErr error
+	}{
+		{name: "no limits", wallet: active, amount: 1_000_000},
+		{name: "up to the per-transfer limit", wallet: limited, amount: 100},
+		{name: "over the per-transfer limit", wallet: limited, amount: 100.5, wantErr: ErrTransferLimitExceeded},
+		{name: "up to the daily limit", wallet: limited, amount: 50, signed: 200},
+		{name: "over the daily limit", wallet: limited, amount: 50.5, signed: 200, wantErr: ErrTransferLimitExceeded},
+		{name: "daily limit already used", wallet: limited, amount: 1, signed: 250, wantErr: ErrTransferLimitExceeded},
+		{name: "export pending", wallet: models.CustodialWallet{Status: models.CustodialWalletExportPending}, amount: 1, wantErr: ErrWalletNotActive},
+		{name: "exported", wallet: models.CustodialWallet{Status: models.CustodialWalletExported}, amount: 1, wantErr: ErrWalletNotActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransferLimits(tt.wallet, tt.amount, tt.signed)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("checkTransferLimits() error = %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkTransferLimits() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
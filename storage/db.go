@@ -4,10 +4,12 @@ import (
 	"database/sql" // Import base sql
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/ferreirogomes/tiquin/models"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 	_ "github.com/lib/pq"
 	migrate "github.com/rubenv/sql-migrate" // Import sql-migrate
 )
@@ -15,10 +17,17 @@ import (
 // DB represents the PostgreSQL database connection.
 type DB struct {
 	*sqlx.DB
+	Fields *FieldCipher // Encrypts personal data at rest
 }
 
-// NewDB connects to PostgreSQL and runs migrations.
-func NewDB(dataSourceName string) (*DB, error) {
+// NewDB connects to PostgreSQL and runs migrations. Personal data is
+// encrypted with the keys of the given provider.
+func NewDB(dataSourceName string, keys KeyProvider) (*DB, error) {
+	fields, err := NewFieldCipher(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load field encryption keys: %w", err)
+	}
+
 	db, err := sqlx.Connect("postgres", dataSourceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -29,12 +38,15 @@ func NewDB(dataSourceName string) (*DB, error) {
 	}
 	log.Println("PostgreSQL connection established successfully.")
 
+	// Models only carry json tags, which already match the column names.
+	db.Mapper = reflectx.NewMapperFunc("json", strings.ToLower)
+
 	// Executar migrações
 	if err := runMigrations(db.DB); err != nil { // Pass *sql.DB
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, Fields: fields}, nil
 }
 
 // runMigrations runs the migrations using sql-migrate.
@@ -55,51 +67,47 @@ func runMigrations(db *sql.DB) error {
 	return nil
 }
 
-// SaveUser creates or updates a user based on their ID.
+// SaveUser creates a new user, encrypting their personal data. It fails
+// with a unique violation when the wallet, EVM address or email already
+// belongs to another user.
 func (d *DB) SaveUser(user models.User) error {
+	row, err := d.sealUser(user)
+	if err != nil {
+		return err
+	}
 	query := `
-		INSERT INTO users (id, name, email, solana_pub_key, created_at)
-		VALUES (:id, :name, :email, :solana_pub_key, :created_at)
-		ON CONFLICT (solana_pub_key) DO UPDATE 
-		SET name = EXCLUDED.name, email = EXCLUDED.email
+		INSERT INTO users (id, name, email, email_index, solana_pub_key, evm_address, custody, tax_id, tax_id_index,
+		                   jurisdiction, investor_category, created_at, updated_at)
+		VALUES (:id, :name, :email, :email_index, :solana_pub_key, :evm_address, :custody, :tax_id, :tax_id_index,
+		        :jurisdiction, :investor_category, :created_at, :updated_at)
 	`
-	_, err := d.NamedExec(query, user)
+	_, err = d.NamedExec(query, row)
 	return err
 }
 
 // GetUser retrieves a user by ID.
 func (d *DB) GetUser(id string) (models.User, bool, error) {
-	var user models.User
-	err := d.Get(&user, "SELECT * FROM users WHERE id = $1", id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return user, false, nil
-		}
-		return user, false, err
-	}
-	return user, true, nil
+	return d.getUser("SELECT * FROM users WHERE id = $1", id)
 }
 
 // GetUserBySolanaPubKey retrieves a user by their Solana public key.
 func (d *DB) GetUserBySolanaPubKey(pubKey string) (models.User, bool, error) {
-	var user models.User
-	err := d.Get(&user, "SELECT * FROM users WHERE solana_pub_key = $1", pubKey)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return user, false, nil
-		}
-		return user, false, err
-	}
-	return user, true, nil
+	return d.getUser("SELECT * FROM users WHERE solana_pub_key = $1", pubKey)
 }
 
-// SaveAsset creates or updates an asset.
+// GetUserByEVMAddress retrieves a user by their checksummed EVM address.
+func (d *DB) GetUserByEVMAddress(address string) (models.User, bool, error) {
+	return d.getUser("SELECT * FROM users WHERE evm_address = $1", address)
+}
+
+// SaveAsset creates an asset. A symbol or ISIN already in use is reported
+// as a unique violation.
 func (d *DB) SaveAsset(asset models.Asset) error {
 	query := `
-		INSERT INTO assets (id, symbol, name, total_shares, mint_address, created_at)
-		VALUES (:id, :symbol, :name, :total_shares, :mint_address, :created_at)
-		ON CONFLICT (symbol) DO UPDATE 
-		SET mint_address = EXCLUDED.mint_address
+		INSERT INTO assets (id, symbol, name, total_shares, chain, mint_address, issuer, issuer_cnpj, isin, asset_class,
+		                    description, links, status, version, created_at, updated_at)
+		VALUES (:id, :symbol, :name, :total_shares, :chain, :mint_address, :issuer, :issuer_cnpj, :isin, :asset_class,
+		        :description, :links, :status, :version, :created_at, :updated_at)
 	`
 	_, err := d.NamedExec(query, asset)
 	return err
@@ -131,6 +139,82 @@ func (d *DB) GetAssetByMintAddress(mintAddress string) (models.Asset, bool, erro
 	return asset, true, nil
 }
 
+// GetAssetBySymbol retrieves an asset by its symbol.
+func (d *DB) GetAssetBySymbol(symbol string) (models.Asset, bool, error) {
+	var asset models.Asset
+	err := d.Get(&asset, "SELECT * FROM assets WHERE symbol = $1", symbol)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return asset, false, nil
+		}
+		return asset, false, err
+	}
+	return asset, true, nil
+}
+
+// AssetFilter narrows a listing of assets. Empty fields match every asset.
+type AssetFilter struct {
+	Query      string // Case-insensitive substring of the symbol, name or issuer
+	Status     string
+	Chain      string
+	AssetClass string
+	IssuerCNPJ string
+	Limit      int
+	Offset     int
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListAssets lists the assets matching a filter, ordered by symbol.
+func (d *DB) ListAssets(f AssetFilter) ([]models.Asset, error) {
+	var pattern string
+	if f.Query != "" {
+		pattern = "%" + likeEscaper.Replace(f.Query) + "%"
+	}
+	var assets []models.Asset
+	err := d.Select(&assets,
+		`SELECT * FROM assets
+		 WHERE ($1 = '' OR symbol ILIKE $1 OR name ILIKE $1 OR issuer ILIKE $1)
+		   AND ($2 = '' OR status = $2)
+		   AND ($3 = '' OR chain = $3)
+		   AND ($4 = '' OR asset_class = $4)
+		   AND ($5 = '' OR issuer_cnpj = $5)
+		 ORDER BY symbol
+		 LIMIT $6 OFFSET $7`,
+		pattern, f.Status, f.Chain, f.AssetClass, f.IssuerCNPJ, f.Limit, f.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if assets == nil {
+		assets = []models.Asset{}
+	}
+	return assets, nil
+}
+
+// UpdateAsset stores the mutable fields of an asset if it is still at
+// asset.Version, and returns it with its new version. It returns false when
+// the asset changed since that version, or does not exist.
+func (d *DB) UpdateAsset(asset models.Asset) (models.Asset, bool, error) {
+	var updated models.Asset
+	err := d.Get(&updated,
+		`UPDATE assets
+		 SET issuer = $1, issuer_cnpj = $2, isin = $3, asset_class = $4, description = $5, links = $6,
+		     version = version + 1, updated_at = NOW()
+		 WHERE id = $7 AND version = $8
+		 RETURNING *`,
+		asset.Issuer, asset.IssuerCNPJ, asset.ISIN, asset.AssetClass, asset.Description, asset.Links, asset.ID, asset.Version,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return updated, false, nil
+		}
+		return updated, false, err
+	}
+	return updated, true, nil
+}
+
 // SaveToken creates or updates a token record.
 func (d *DB) SaveToken(token models.Token) error {
 	query := `
@@ -176,10 +260,13 @@ func (d *DB) GetTokensByAssetID(assetID string) ([]models.Token, error) {
 	return tokens, nil
 }
 
-// TransactionExists checks if a transaction ID has already been processed and saved.
+// TransactionExists checks if a transaction ID has already been processed and saved,
+// either as a token record or as a ledger movement (batched operations journal
+// several holders under one signature).
 func (d *DB) TransactionExists(txID string) (bool, error) {
 	var exists bool
-	query := "SELECT EXISTS(SELECT 1 FROM tokens WHERE transaction_id = $1)"
+	query := `SELECT EXISTS(SELECT 1 FROM tokens WHERE transaction_id = $1)
+	          OR EXISTS(SELECT 1 FROM ledger_entries WHERE transaction_id = $1)`
 	err := d.Get(&exists, query, txID)
 	return exists, err
 }
@@ -210,8 +297,9 @@ func (d *DB) TransferTokenBalance(fromOwnerID, toOwnerID, assetID string, amount
 	// Debit sender: subtract amount from their existing token record for this asset
 	debitResult, err := tx.Exec(
 		`UPDATE tokens SET amount = amount - $1
-		 WHERE owner_id = $2 AND asset_id = $3 AND is_tradable = true AND amount >= $1
-		 LIMIT 1`,
+		 WHERE id = (SELECT id FROM tokens
+		             WHERE owner_id = $2 AND asset_id = $3 AND is_tradable = true AND amount >= $1
+		             LIMIT 1)`,
 		amount, fromOwnerID, assetID,
 	)
 	if err != nil {
@@ -237,6 +325,14 @@ func (d *DB) TransferTokenBalance(fromOwnerID, toOwnerID, assetID string, amount
 		return fmt.Errorf("failed to credit recipient: %w", err)
 	}
 
+	// Journal both legs so holdings can be rebuilt at any past instant
+	if err = journalOwnerMovement(tx, assetID, fromOwnerID, -amount, models.LedgerEntryTransferOut, txID); err != nil {
+		return fmt.Errorf("failed to journal sender debit: %w", err)
+	}
+	if err = journalOwnerMovement(tx, assetID, toOwnerID, amount, models.LedgerEntryTransferIn, txID); err != nil {
+		return fmt.Errorf("failed to journal recipient credit: %w", err)
+	}
+
 	return tx.Commit()
 }
 
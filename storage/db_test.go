@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB opens an in-memory SQLite database with the given schema. It
+// stands in for PostgreSQL in the queries both dialects share; tests are
+// skipped where SQLite is unavailable, e.g., when built without cgo.
+func newTestDB(t *testing.T, schema string) *DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		t.Skipf("SQLite unavailable: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1) // Every connection to :memory: opens its own database
+	db.Mapper = reflectx.NewMapperFunc("json", strings.ToLower)
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	return &DB{DB: db}
+}
+
+func TestTransactionExists(t *testing.T) {
+	db := newTestDB(t, `
+		CREATE TABLE tokens (id TEXT PRIMARY KEY, transaction_id TEXT);
+		CREATE TABLE ledger_entries (id TEXT PRIMARY KEY, transaction_id TEXT);
+	`)
+	exists := func(txID string) bool {
+		t.Helper()
+		ok, err := db.TransactionExists(txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if exists("sig-mint") {
+		t.Fatal("TransactionExists() = true on an empty database")
+	}
+	db.MustExec(`INSERT INTO tokens (id, transaction_id) VALUES ('t1', 'sig-mint')`)
+	if !exists("sig-mint") {
+		t.Error("TransactionExists() = false for a transaction booked as a token")
+	}
+
+	// Batched operations only journal ledger movements under their signature
+	db.MustExec(`INSERT INTO ledger_entries (id, transaction_id) VALUES ('l1', 'sig-batch'), ('l2', 'sig-batch')`)
+	if !exists("sig-batch") {
+		t.Error("TransactionExists() = false for a transaction journaled in the ledger")
+	}
+	if exists("sig-other") {
+		t.Error("TransactionExists() = true for an unknown transaction")
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// CreateDistribution saves a distribution together with its computed payouts.
+func (d *DB) CreateDistribution(distribution models.Distribution, payouts []models.Payout) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NamedExec(
+		`INSERT INTO distributions (id, asset_id, snapshot_id, description, payout_mint, payout_decimals, total_amount, status, created_at, updated_at)
+		 VALUES (:id, :asset_id, :snapshot_id, :description, :payout_mint, :payout_decimals, :total_amount, :status, :created_at, :updated_at)`,
+		distribution,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert distribution: %w", err)
+	}
+
+	for _, p := range payouts {
+		_, err = tx.NamedExec(
+			`INSERT INTO payouts (id, distribution_id, owner_id, solana_pub_key, holding_amount, amount_atomic, amount, status, updated_at)
+			 VALUES (:id, :distribution_id, :owner_id, :solana_pub_key, :holding_amount, :amount_atomic, :amount, :status, :updated_at)`,
+			p,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert payout for %s: %w", p.SolanaPubKey, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetDistribution retrieves a distribution by ID, without its payouts.
+func (d *DB) GetDistribution(id string) (models.Distribution, bool, error) {
+	var distribution models.Distribution
+	err := d.Get(&distribution, "SELECT * FROM distributions WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return distribution, false, nil
+		}
+		return distribution, false, err
+	}
+	return distribution, true, nil
+}
+
+// GetDistributionsByAssetID lists the distributions of an asset, most recent first.
+func (d *DB) GetDistributionsByAssetID(assetID string) ([]models.Distribution, error) {
+	var distributions []models.Distribution
+	err := d.Select(&distributions, "SELECT * FROM distributions WHERE asset_id = $1 ORDER BY created_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if distributions == nil {
+		distributions = []models.Distribution{}
+	}
+	return distributions, nil
+}
+
+// GetPayouts lists the payouts of a distribution, optionally restricted to
+// the given statuses. Order is deterministic so batches are reproducible.
+func (d *DB) GetPayouts(distributionID string, statuses ...string) ([]models.Payout, error) {
+	var payouts []models.Payout
+	err := d.Select(&payouts,
+		`SELECT * FROM payouts
+		 WHERE distribution_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2))
+		 ORDER BY solana_pub_key`,
+		distributionID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if payouts == nil {
+		payouts = []models.Payout{}
+	}
+	return payouts, nil
+}
+
+// ClaimDistribution moves a distribution to `to` only if it is currently in
+// one of the `from` statuses. It returns false when another caller got there first.
+func (d *DB) ClaimDistribution(id, to string, from ...string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE distributions SET status = $1, updated_at = NOW() WHERE id = $2 AND status = ANY($3)`,
+		to, id, pq.Array(from),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// UpdateDistributionStatus sets the status of a distribution.
+func (d *DB) UpdateDistributionStatus(id, status string) error {
+	_, err := d.Exec(`UPDATE distributions SET status = $1, updated_at = NOW() WHERE id = $2`, status, id)
+	return err
+}
+
+// MarkPayoutsSent records the transaction carrying a batch of payouts.
+func (d *DB) MarkPayoutsSent(ids []string, txID string) error {
+	_, err := d.Exec(
+		`UPDATE payouts SET status = $1, transaction_id = $2, attempts = attempts + 1, last_error = NULL, updated_at = NOW()
+		 WHERE id = ANY($3)`,
+		models.PayoutStatusSent, txID, pq.Array(ids),
+	)
+	return err
+}
+
+// MarkPayoutsPaid records that the transaction carrying the payouts is confirmed.
+func (d *DB) MarkPayoutsPaid(ids []string) error {
+	_, err := d.Exec(
+		`UPDATE payouts SET status = $1, paid_at = NOW(), updated_at = NOW() WHERE id = ANY($2)`,
+		models.PayoutStatusPaid, pq.Array(ids),
+	)
+	return err
+}
+
+// MarkPayoutsFailed records a failed attempt for a batch of payouts.
+// countAttempt is false when the attempt was already counted on send.
+func (d *DB) MarkPayoutsFailed(ids []string, reason string, countAttempt bool) error {
+	increment := 0
+	if countAttempt {
+		increment = 1
+	}
+	_, err := d.Exec(
+		`UPDATE payouts SET status = $1, last_error = $2, attempts = attempts + $3, updated_at = NOW()
+		 WHERE id = ANY($4)`,
+		models.PayoutStatusFailed, reason, increment, pq.Array(ids),
+	)
+	return err
+}
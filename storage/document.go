@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// SaveDocument records a new version of a document, numbering it after the
+// latest version with the same name. Returns the document as saved.
+func (d *DB) SaveDocument(doc models.Document) (models.Document, error) {
+	var saved models.Document
+	err := d.Get(&saved,
+		`INSERT INTO documents (id, asset_id, name, kind, version, file_name, content_type, size, sha256, storage_key, created_at)
+		 SELECT $1, $2, $3, $4, COALESCE(MAX(version), 0) + 1, $5, $6, $7, $8, $9, $10
+		 FROM documents WHERE asset_id = $2 AND name = $3
+		 RETURNING *`,
+		doc.ID, doc.AssetID, doc.Name, doc.Kind, doc.FileName, doc.ContentType, doc.Size, doc.SHA256, doc.StorageKey, doc.CreatedAt,
+	)
+	return saved, err
+}
+
+// GetDocument retrieves a document version by ID.
+func (d *DB) GetDocument(id string) (models.Document, bool, error) {
+	var doc models.Document
+	err := d.Get(&doc, "SELECT * FROM documents WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return doc, false, nil
+		}
+		return doc, false, err
+	}
+	return doc, true, nil
+}
+
+// GetDocumentsByAssetID lists every version of an asset's documents, by name
+// and newest version first.
+func (d *DB) GetDocumentsByAssetID(assetID string) ([]models.Document, error) {
+	var docs []models.Document
+	err := d.Select(&docs, `SELECT * FROM documents WHERE asset_id = $1 ORDER BY name, version DESC`, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if docs == nil {
+		docs = []models.Document{}
+	}
+	return docs, nil
+}
+
+// GetLatestDocumentsByAssetID lists the latest version of each of an asset's documents.
+func (d *DB) GetLatestDocumentsByAssetID(assetID string) ([]models.Document, error) {
+	var docs []models.Document
+	err := d.Select(&docs, `
+		SELECT DISTINCT ON (name) * FROM documents WHERE asset_id = $1 ORDER BY name, version DESC
+	`, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if docs == nil {
+		docs = []models.Document{}
+	}
+	return docs, nil
+}
+
+// SetDocumentAnchor records the transaction that anchored a document's hash.
+func (d *DB) SetDocumentAnchor(id, signature string) error {
+	_, err := d.Exec(`
+		UPDATE documents SET anchor_signature = $2, anchored_at = $3, last_error = NULL
+		WHERE id = $1 AND anchor_signature IS NULL
+	`, id, signature, time.Now())
+	return err
+}
+
+// SetDocumentAnchorError records why anchoring a document failed.
+func (d *DB) SetDocumentAnchorError(id, reason string) error {
+	_, err := d.Exec(`UPDATE documents SET last_error = $2 WHERE id = $1`, id, reason)
+	return err
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// SaveDvPSettlement creates a DvP settlement.
+func (d *DB) SaveDvPSettlement(dvp models.DvPSettlement) error {
+	query := `
+		INSERT INTO dvp_settlements (id, asset_id, seller_id, buyer_id, quantity, payment_mint, payment_decimals, payment_amount,
+		                             status, transaction, expires_at, created_at, updated_at)
+		VALUES (:id, :asset_id, :seller_id, :buyer_id, :quantity, :payment_mint, :payment_decimals, :payment_amount,
+		        :status, :transaction, :expires_at, :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, dvp)
+	return err
+}
+
+// GetDvPSettlement retrieves a DvP settlement by ID.
+func (d *DB) GetDvPSettlement(id string) (models.DvPSettlement, bool, error) {
+	var dvp models.DvPSettlement
+	err := d.Get(&dvp, "SELECT * FROM dvp_settlements WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return dvp, false, nil
+		}
+		return dvp, false, err
+	}
+	return dvp, true, nil
+}
+
+// GetDvPSettlementsByAssetID lists the DvP settlements of an asset, newest first.
+func (d *DB) GetDvPSettlementsByAssetID(assetID string) ([]models.DvPSettlement, error) {
+	var settlements []models.DvPSettlement
+	err := d.Select(&settlements, "SELECT * FROM dvp_settlements WHERE asset_id = $1 ORDER BY created_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if settlements == nil {
+		settlements = []models.DvPSettlement{}
+	}
+	return settlements, nil
+}
+
+// AddDvPSignature stores the transaction with one more party's signature. It
+// only applies while signatures are awaited and returns false otherwise.
+func (d *DB) AddDvPSignature(id, transaction string, seller bool, at time.Time) (bool, error) {
+	column := "buyer_signed_at"
+	if seller {
+		column = "seller_signed_at"
+	}
+	result, err := d.Exec(
+		fmt.Sprintf(`UPDATE dvp_settlements SET transaction = $1, %s = $2, updated_at = NOW() WHERE id = $3 AND status = $4`, column),
+		transaction, at, id, models.DvPStatusAwaitingSignatures,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// ClaimDvPSettlement moves a settlement from one status to another. It
+// returns false when the settlement was not in the `from` status.
+func (d *DB) ClaimDvPSettlement(id, from, to string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE dvp_settlements SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		to, id, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// MarkDvPSubmitted records the signature of the submitted transaction.
+func (d *DB) MarkDvPSubmitted(id, txID string) error {
+	_, err := d.Exec(
+		`UPDATE dvp_settlements SET status = $1, transaction_id = $2, last_error = NULL, updated_at = NOW() WHERE id = $3`,
+		models.DvPStatusSubmitted, txID, id,
+	)
+	return err
+}
+
+// MarkDvPFailed records why a settlement failed.
+func (d *DB) MarkDvPFailed(id, reason string) error {
+	_, err := d.Exec(
+		`UPDATE dvp_settlements SET status = $1, last_error = $2, updated_at = NOW() WHERE id = $3`,
+		models.DvPStatusFailed, reason, id,
+	)
+	return err
+}
+
+// MarkDvPSettled marks a settlement settled once its transaction is finalized.
+func (d *DB) MarkDvPSettled(id string) error {
+	_, err := d.Exec(
+		`UPDATE dvp_settlements SET status = $1, settled_at = NOW(), updated_at = NOW() WHERE id = $2`,
+		models.DvPStatusSettled, id,
+	)
+	return err
+}
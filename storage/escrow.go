@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// activeEscrowStatuses are the statuses an escrow can still leave.
+var activeEscrowStatuses = []string{
+	models.EscrowStatusAwaitingDeposit,
+	models.EscrowStatusFunded,
+	models.EscrowStatusReleasing,
+	models.EscrowStatusRefunding,
+}
+
+// SaveEscrow creates an escrow.
+func (d *DB) SaveEscrow(escrow models.Escrow) error {
+	query := `
+		INSERT INTO escrows (id, asset_id, mint, decimals, amount, depositor_id, beneficiary_id, escrow_account,
+		                     condition_type, condition_reference, status, deposit_transaction, expires_at, created_at, updated_at)
+		VALUES (:id, :asset_id, :mint, :decimals, :amount, :depositor_id, :beneficiary_id, :escrow_account,
+		        :condition_type, :condition_reference, :status, :deposit_transaction, :expires_at, :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, escrow)
+	return err
+}
+
+// GetEscrow retrieves an escrow by ID.
+func (d *DB) GetEscrow(id string) (models.Escrow, bool, error) {
+	var escrow models.Escrow
+	err := d.Get(&escrow, "SELECT * FROM escrows WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return escrow, false, nil
+		}
+		return escrow, false, err
+	}
+	return escrow, true, nil
+}
+
+// GetEscrowsByUserID lists the escrows a user deposits into or benefits from, newest first.
+func (d *DB) GetEscrowsByUserID(userID string) ([]models.Escrow, error) {
+	var escrows []models.Escrow
+	err := d.Select(&escrows,
+		`SELECT * FROM escrows WHERE depositor_id = $1 OR beneficiary_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if escrows == nil {
+		escrows = []models.Escrow{}
+	}
+	return escrows, nil
+}
+
+// GetActiveEscrows lists the escrows that are not settled or expired yet.
+func (d *DB) GetActiveEscrows() ([]models.Escrow, error) {
+	var escrows []models.Escrow
+	err := d.Select(&escrows,
+		`SELECT * FROM escrows WHERE status = ANY($1) ORDER BY created_at`,
+		pq.Array(activeEscrowStatuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if escrows == nil {
+		escrows = []models.Escrow{}
+	}
+	return escrows, nil
+}
+
+// ClaimEscrow moves an escrow from one status to another. It returns false
+// when the escrow was not in the `from` status.
+func (d *DB) ClaimEscrow(id, from, to string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE escrows SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		to, id, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// FundEscrow marks an escrow funded by its confirmed deposit. For registered
+// assets the tokens move in the ledger from the depositor to the escrow
+// account. It returns false when the escrow was not awaiting its deposit.
+func (d *DB) FundEscrow(escrow models.Escrow, txID string) (funded bool, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(
+		`UPDATE escrows SET status = $1, deposit_tx_id = $2, funded_at = NOW(), updated_at = NOW() WHERE id = $3 AND status = $4`,
+		models.EscrowStatusFunded, txID, escrow.ID, models.EscrowStatusAwaitingDeposit,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, tx.Rollback()
+	}
+
+	if escrow.AssetID != nil {
+		assetID := *escrow.AssetID
+		if err = journalOwnerMovement(tx, assetID, escrow.DepositorID, -escrow.Amount, models.LedgerEntryEscrowDeposit, txID); err != nil {
+			return false, fmt.Errorf("failed to journal deposit: %w", err)
+		}
+		if err = adjustOwnerTokens(tx, assetID, escrow.DepositorID, -escrow.Amount, txID); err != nil {
+			return false, fmt.Errorf("failed to debit depositor: %w", err)
+		}
+		err = recordLedgerEntry(tx, models.LedgerEntry{
+			AssetID:       assetID,
+			SolanaPubKey:  escrow.EscrowAccount,
+			Amount:        escrow.Amount,
+			EntryType:     models.LedgerEntryEscrowDeposit,
+			TransactionID: txID,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to journal escrow account: %w", err)
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// MarkEscrowSent records the transaction carrying a release or refund.
+func (d *DB) MarkEscrowSent(id, txID string) error {
+	_, err := d.Exec(
+		`UPDATE escrows SET settlement_tx_id = $1, last_error = NULL, updated_at = NOW() WHERE id = $2`,
+		txID, id,
+	)
+	return err
+}
+
+// ResetEscrow returns an escrow whose release or refund failed or expired
+// to funded, so it can be settled again.
+func (d *DB) ResetEscrow(id, reason string) error {
+	_, err := d.Exec(
+		`UPDATE escrows SET status = $1, settlement_tx_id = NULL, last_error = $2, updated_at = NOW()
+		 WHERE id = $3 AND status IN ($4, $5)`,
+		models.EscrowStatusFunded, reason, id, models.EscrowStatusReleasing, models.EscrowStatusRefunding,
+	)
+	return err
+}
+
+// SettleEscrow books a confirmed release or refund: the escrow reaches its
+// final status and, for registered assets, the tokens move in the ledger from
+// the escrow account to the recipient.
+func (d *DB) SettleEscrow(escrow models.Escrow, txID string) (err error) {
+	status, entryType, recipientID := models.EscrowStatusReleased, models.LedgerEntryEscrowRelease, escrow.BeneficiaryID
+	if escrow.Status == models.EscrowStatusRefunding {
+		status, entryType, recipientID = models.EscrowStatusRefunded, models.LedgerEntryEscrowRefund, escrow.DepositorID
+	}
+
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(
+		`UPDATE escrows SET status = $1, settlement_tx_id = $2, settled_at = NOW(), updated_at = NOW() WHERE id = $3 AND status = $4`,
+		status, txID, escrow.ID, escrow.Status,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return tx.Rollback() // Already settled
+	}
+
+	if escrow.AssetID != nil {
+		assetID := *escrow.AssetID
+		err = recordLedgerEntry(tx, models.LedgerEntry{
+			AssetID:       assetID,
+			SolanaPubKey:  escrow.EscrowAccount,
+			Amount:        -escrow.Amount,
+			EntryType:     entryType,
+			TransactionID: txID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to journal escrow account: %w", err)
+		}
+		if err = journalOwnerMovement(tx, assetID, recipientID, escrow.Amount, entryType, txID); err != nil {
+			return fmt.Errorf("failed to journal recipient: %w", err)
+		}
+		if err = adjustOwnerTokens(tx, assetID, recipientID, escrow.Amount, txID); err != nil {
+			return fmt.Errorf("failed to credit recipient: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEscrowDepositTransaction replaces the deposit transaction of an
+// escrow still awaiting its deposit.
+func (d *DB) UpdateEscrowDepositTransaction(id, transaction string) error {
+	_, err := d.Exec(
+		`UPDATE escrows SET deposit_transaction = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		transaction, id, models.EscrowStatusAwaitingDeposit,
+	)
+	return err
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ciphertextPrefix marks an encrypted field value: "enc:<key version>:<base64
+// of nonce and ciphertext>". Values without it are plaintext written before
+// encryption was enabled, which key rotation encrypts.
+const ciphertextPrefix = "enc:"
+
+// KeyProvider supplies the keys personal data is encrypted with. Every
+// encryption key has a version, stored with each ciphertext, so values
+// sealed with an older key stay readable after rotating to a new one until
+// they are re-encrypted. Implementations may fetch the keys from a KMS or HSM.
+type KeyProvider interface {
+	// CurrentKeyVersion names the key new values are encrypted with.
+	CurrentKeyVersion() string
+	// EncryptionKey returns the 32-byte AES-256 key of a version.
+	EncryptionKey(version string) ([]byte, error)
+	// IndexKey returns the HMAC key of blind indexes. It does not rotate
+	// with the encryption keys, so indexes stay comparable.
+	IndexKey() ([]byte, error)
+}
+
+// StaticKeyProvider serves keys given in configuration.
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+	index   []byte
+}
+
+// NewStaticKeyProvider parses a keyring of base64-encoded 32-byte keys,
+// "<version>:<key>" separated by commas and current key first (e.g.
+// "2:...,1:..."), and the base64-encoded 32-byte blind index key.
+func NewStaticKeyProvider(keyring, indexKey string) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(keyring, ",") {
+		version, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || version == "" {
+			return nil, errors.New("field encryption keys must be <version>:<base64 key> pairs separated by commas")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("field encryption key %s must be 32 bytes, base64-encoded", version)
+		}
+		if _, dup := p.keys[version]; dup {
+			return nil, fmt.Errorf("field encryption key %s is given twice", version)
+		}
+		if p.current == "" {
+			p.current = version
+		}
+		p.keys[version] = key
+	}
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || len(index) != 32 {
+		return nil, errors.New("blind index key must be 32 bytes, base64-encoded")
+	}
+	p.index = index
+	return p, nil
+}
+
+// CurrentKeyVersion implements KeyProvider.
+func (p *StaticKeyProvider) CurrentKeyVersion() string { return p.current }
+
+// EncryptionKey implements KeyProvider.
+func (p *StaticKeyProvider) EncryptionKey(version string) ([]byte, error) {
+	key, ok := p.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown field encryption key version %q", version)
+	}
+	return key, nil
+}
+
+// IndexKey implements KeyProvider.
+func (p *StaticKeyProvider) IndexKey() ([]byte, error) { return p.index, nil }
+
+// FieldCipher encrypts individual column values with AES-256-GCM. Each
+// ciphertext is bound to its table, column and row, so ciphertexts cannot be
+// swapped between rows or columns.
+type FieldCipher struct {
+	Keys KeyProvider
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD // By key version, so the provider is asked once per key
+}
+
+// NewFieldCipher creates a cipher, checking that the provider serves its
+// current key and the blind index key.
+func NewFieldCipher(keys KeyProvider) (*FieldCipher, error) {
+	c := &FieldCipher{Keys: keys, aeads: make(map[string]cipher.AEAD)}
+	if _, err := c.aead(keys.CurrentKeyVersion()); err != nil {
+		return nil, err
+	}
+	if _, err := keys.IndexKey(); err != nil {
+		return nil, fmt.Errorf("failed to load blind index key: %w", err)
+	}
+	return c, nil
+}
+
+// Encrypt seals a value with the current key. Nil stays nil.
+func (c *FieldCipher) Encrypt(value *string, aad string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	version := c.Keys.CurrentKeyVersion()
+	gcm, err := c.aead(version)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(gcm, []byte(*value), aad)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := ciphertextPrefix + version + ":" + base64.StdEncoding.EncodeToString(sealed)
+	return &encrypted, nil
+}
+
+// Decrypt opens a value sealed by Encrypt with any version of the key.
+// Nil and plaintext values are returned as they are.
+func (c *FieldCipher) Decrypt(value *string, aad string) (*string, error) {
+	version, encoded, encrypted := splitCiphertext(value)
+	if !encrypted {
+		return value, nil
+	}
+	gcm, err := c.aead(version)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ciphertext for %s", aad)
+	}
+	plaintext, err := open(gcm, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", aad, err)
+	}
+	decrypted := string(plaintext)
+	return &decrypted, nil
+}
+
+// IsCurrent reports whether a value is nil or sealed with the current key.
+func (c *FieldCipher) IsCurrent(value *string) bool {
+	version, _, encrypted := splitCiphertext(value)
+	return value == nil || (encrypted && version == c.Keys.CurrentKeyVersion())
+}
+
+// BlindIndex derives the value a column is looked up by instead of its
+// plaintext: an HMAC of the case-folded value, keyed per column so equal
+// values in different columns do not match. Nil stays nil.
+func (c *FieldCipher) BlindIndex(column string, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	key, err := c.Keys.IndexKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blind index key: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(column + ":" + strings.ToLower(strings.TrimSpace(*value))))
+	index := hex.EncodeToString(mac.Sum(nil))
+	return &index, nil
+}
+
+func (c *FieldCipher) aead(version string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gcm, ok := c.aeads[version]; ok {
+		return gcm, nil
+	}
+	key, err := c.Keys.EncryptionKey(version)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field encryption key %s: %w", version, err)
+	}
+	c.aeads[version] = gcm
+	return gcm, nil
+}
+
+// SealGCM encrypts plaintext with AES-256-GCM under a 32-byte key, bound to
+// aad. The random nonce is prepended to the ciphertext.
+func SealGCM(key, plaintext []byte, aad string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return seal(gcm, plaintext, aad)
+}
+
+// OpenGCM decrypts a ciphertext produced by SealGCM.
+func OpenGCM(key, sealed []byte, aad string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return open(gcm, sealed, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext bound to aad under a fresh random nonce, which it
+// prepends to the ciphertext.
+func seal(gcm cipher.AEAD, plaintext []byte, aad string) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(aad)), nil
+}
+
+// open decrypts a ciphertext produced by seal.
+func open(gcm cipher.AEAD, sealed []byte, aad string) ([]byte, error) {
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(aad))
+}
+
+// splitCiphertext returns the key version and payload of an encrypted value.
+func splitCiphertext(value *string) (version, encoded string, encrypted bool) {
+	if value == nil || !strings.HasPrefix(*value, ciphertextPrefix) {
+		return "", "", false
+	}
+	version, encoded, encrypted = strings.Cut(strings.TrimPrefix(*value, ciphertextPrefix), ":")
+	return version, encoded, encrypted
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64-encoded 32-byte key filled with b.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), 32)))
+}
+
+func newTestCipher(t *testing.T, keyring string) *FieldCipher {
+	t.Helper()
+	keys, err := NewStaticKeyProvider(keyring, testKey('i'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewFieldCipher(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewStaticKeyProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyring     string
+		indexKey    string
+		wantCurrent string
+		wantErr     bool
+	}{
+		{name: "single key", keyring: "1:" + testKey('a'), indexKey: testKey('i'), wantCurrent: "1"},
+		{name: "current key first", keyring: "2:" + testKey('b') + ", 1:" + testKey('a'), indexKey: testKey('i'), wantCurrent: "2"},
+		{name: "empty keyring", keyring: "", indexKey: testKey('i'), wantErr: true},
+		{name: "missing version", keyring: testKey('a'), indexKey: testKey('i'), wantErr: true},
+		{name: "short key", keyring: "1:" + base64.StdEncoding.EncodeToString([]byte("short")), indexKey: testKey('i'), wantErr: true},
+		{name: "duplicate version", keyring: "1:" + testKey('a') + ",1:" + testKey('b'), indexKey: testKey('i'), wantErr: true},
+		{name: "missing index key", keyring: "1:" + testKey('a'), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewStaticKeyProvider(tt.keyring, tt.indexKey)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewStaticKeyProvider() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.CurrentKeyVersion() != tt.wantCurrent {
+				t.Fatalf("current key = %q, want %q", p.CurrentKeyVersion(), tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestFieldCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, "1:"+testKey('a'))
+	empty, name, accented := "", "Maria Silva", "João Conceição"
+	tests := []struct {
+		name  string
+		value *string
+	}{
+		{"nil", nil},
+		{"empty", &empty},
+		{"ascii", &name},
+		{"accented", &accented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aad := fieldAAD("users", "name", "id-1")
+			sealed, err := c.Encrypt(tt.value, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.value == nil {
+				if sealed != nil {
+					t.Fatalf("Encrypt(nil) = %q, want nil", *sealed)
+				}
+				return
+			}
+			if !strings.HasPrefix(*sealed, ciphertextPrefix+"1:") || (*tt.value != "" && strings.Contains(*sealed, *tt.value)) {
+				t.Fatalf("Encrypt() = %q, not sealed with key 1", *sealed)
+			}
+			if !c.IsCurrent(sealed) {
+				t.Errorf("IsCurrent(%q) = false", *sealed)
+			}
+			opened, err := c.Decrypt(sealed, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *opened != *tt.value {
+				t.Fatalf("Decrypt() = %q, want %q", *opened, *tt.value)
+			}
+		})
+	}
+}
+
+func TestFieldCipherEncryptUsesFreshNonces(t *testing.T) {
+	c := newTestCipher(t, "1:"+testKey('a'))
+	value := "maria@example.com"
+	a, _ := c.Encrypt(&value, "users.email:id-1")
+	b, _ := c.Encrypt(&value, "users.email:id-1")
+	if *a == *b {
+		t.Fatal("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestFieldCipherDecryptRejects(t *testing.T) {
+	c := newTestCipher(t, "1:"+testKey('a'))
+	value := "12345678909"
+	aad := fieldAAD("users", "tax_id", "id-1")
+	sealed, err := c.Encrypt(&value, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *sealed
+	payload, _ := base64.StdEncoding.DecodeString(tampered[len(ciphertextPrefix+"1:"):])
+	payload[len(payload)-1] ^= 1
+	tampered = ciphertextPrefix + "1:" + base64.StdEncoding.EncodeToString(payload)
+	unknownKey := strings.Replace(*sealed, ciphertextPrefix+"1:", ciphertextPrefix+"9:", 1)
+	malformed := ciphertextPrefix + "1:not base64!"
+
+	tests := []struct {
+		name  string
+		value *string
+		aad   string
+	}{
+		{"other row", sealed, fieldAAD("users", "tax_id", "id-2")},
+		{"other column", sealed, fieldAAD("users", "email", "id-1")},
+		{"tampered", &tampered, aad},
+		{"unknown key version", &unknownKey, aad},
+		{"malformed", &malformed, aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := c.Decrypt(tt.value, tt.aad); err == nil {
+				t.Fatalf("Decrypt() = %q, want an error", *opened)
+			}
+		})
+	}
+}
+
+func TestSealGCM(t *testing.T) {
+	key := bytes.Repeat([]byte{'k'}, 32)
+	plaintext := []byte("private key bytes")
+	sealed, err := SealGCM(key, plaintext, "solana:id-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("SealGCM() output contains the plaintext")
+	}
+	opened, err := OpenGCM(key, sealed, "solana:id-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("OpenGCM() = %q, want %q", opened, plaintext)
+	}
+	if again, _ := SealGCM(key, plaintext, "solana:id-1"); bytes.Equal(again, sealed) {
+		t.Fatal("sealing the same plaintext twice gave the same ciphertext")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed []byte
+		aad    string
+	}{
+		{"tampered", key, tampered, "solana:id-1"},
+		{"other purpose", key, sealed, "evm:id-1"},
+		{"other user", key, sealed, "solana:id-2"},
+		{"other key", bytes.Repeat([]byte{'o'}, 32), sealed, "solana:id-1"},
+		{"truncated", key, sealed[:8], "solana:id-1"},
+		{"invalid key", key[:10], sealed, "solana:id-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := OpenGCM(tt.key, tt.sealed, tt.aad); err == nil {
+				t.Fatalf("OpenGCM() = %q, want an error", opened)
+			}
+		})
+	}
+}
+
+func TestFieldCipherPlaintextPassesThrough(t *testing.T) {
+	c := newTestCipher(t, "1:"+testKey('a'))
+	legacy := "Maria Silva"
+	opened, err := c.Decrypt(&legacy, fieldAAD("users", "name", "id-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *opened != legacy {
+		t.Fatalf("Decrypt(plaintext) = %q, want %q", *opened, legacy)
+	}
+	if c.IsCurrent(&legacy) {
+		t.Fatal("IsCurrent(plaintext) = true, want false so rotation seals it")
+	}
+	if !c.IsCurrent(nil) {
+		t.Fatal("IsCurrent(nil) = false")
+	}
+}
+
+func TestFieldCipherRotation(t *testing.T) {
+	value := "maria@example.com"
+	aad := fieldAAD("users", "email", "id-1")
+	old := newTestCipher(t, "1:"+testKey('a'))
+	sealedOld, err := old.Encrypt(&value, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestCipher(t, "2:"+testKey('b')+",1:"+testKey('a'))
+	if rotated.IsCurrent(sealedOld) {
+		t.Fatal("a value sealed with key 1 is current after rotating to key 2")
+	}
+	opened, err := rotated.Decrypt(sealedOld, aad)
+	if err != nil {
+		t.Fatalf("values sealed with an older key must stay readable: %v", err)
+	}
+	resealed, err := rotated.Encrypt(opened, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(*resealed, ciphertextPrefix+"2:") || !rotated.IsCurrent(resealed) {
+		t.Fatalf("re-encrypted value %q is not sealed with key 2", *resealed)
+	}
+
+	dropped := newTestCipher(t, "2:"+testKey('b'))
+	if _, err := dropped.Decrypt(sealedOld, aad); err == nil {
+		t.Fatal("decrypted a value whose key left the keyring")
+	}
+	if reopened, err := dropped.Decrypt(resealed, aad); err != nil || *reopened != value {
+		t.Fatalf("Decrypt(resealed) = %v, %v", reopened, err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	c := newTestCipher(t, "1:"+testKey('a'))
+	index := func(column, value string) string {
+		t.Helper()
+		i, err := c.BlindIndex(column, &value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *i
+	}
+
+	if index("users.email", "Maria@Example.com ") != index("users.email", "maria@example.com") {
+		t.Error("blind index is not case-folded and trimmed")
+	}
+	if index("users.email", "maria@example.com") == index("users.email", "ana@example.com") {
+		t.Error("different values share a blind index")
+	}
+	if index("users.email", "12345678909") == index("users.tax_id", "12345678909") {
+		t.Error("equal values in different columns share a blind index")
+	}
+	if nilIndex, err := c.BlindIndex("users.email", nil); err != nil || nilIndex != nil {
+		t.Errorf("BlindIndex(nil) = %v, %v, want nil", nilIndex, err)
+	}
+
+	// Indexes do not depend on the encryption key, so they survive rotation
+	rotated := newTestCipher(t, "2:"+testKey('b')+",1:"+testKey('a'))
+	value := "maria@example.com"
+	after, _ := rotated.BlindIndex("users.email", &value)
+	if *after != index("users.email", value) {
+		t.Error("blind index changed with the encryption key")
+	}
+}
+
+func TestOpenRecord(t *testing.T) {
+	d := &DB{Fields: newTestCipher(t, "1:"+testKey('a'))}
+	reference := "s3://kyc/doc-1.pdf"
+	sealed, err := d.Fields.Encrypt(&reference, fieldAAD("kyc_documents", "reference", "id-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedJSON, _ := json.Marshal(*sealed)
+
+	tests := []struct {
+		name, table, record, want string
+		wantErr                   bool
+	}{
+		{
+			name:   "encrypted column",
+			table:  "kyc_documents",
+			record: `{"id":"id-1","reference":` + string(sealedJSON) + `,"sha256":null}`,
+			want:   `{"id":"id-1","reference":"s3://kyc/doc-1.pdf","sha256":null}`,
+		},
+		{
+			name:   "null encrypted column",
+			table:  "kyc_verifications",
+			record: `{"failure_reason":null,"id":"id-1","level":1}`,
+			want:   `{"failure_reason":null,"id":"id-1","level":1}`,
+		},
+		{
+			name:   "table without encrypted columns",
+			table:  "tokens",
+			record: `{"id":"id-1","amount":1.5}`,
+			want:   `{"id":"id-1","amount":1.5}`,
+		},
+		{
+			name:    "value bound to another row",
+			table:   "kyc_documents",
+			record:  `{"id":"id-2","reference":` + string(sealedJSON) + `}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.openRecord(tt.table, json.RawMessage(tt.record))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("openRecord() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("openRecord() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// StartKYCVerification stores a new verification request and marks the user
+// as pending, unless they are still verified.
+func (d *DB) StartKYCVerification(v models.KYCVerification) error {
+	redirectURL, err := d.Fields.Encrypt(v.RedirectURL, fieldAAD("kyc_verifications", "redirect_url", v.ID))
+	if err != nil {
+		return err
+	}
+	v.RedirectURL = redirectURL
+
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NamedExec(
+		`INSERT INTO kyc_verifications (id, user_id, provider, provider_reference, level, status, redirect_url, requested_at)
+		 VALUES (:id, :user_id, :provider, :provider_reference, :level, :status, :redirect_url, :requested_at)`,
+		v,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert verification: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE users SET kyc_status = $1
+		 WHERE id = $2 AND NOT (kyc_status = $3 AND (kyc_expires_at IS NULL OR kyc_expires_at > NOW()))`,
+		models.KYCStatusPending, v.UserID, models.KYCStatusVerified,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user KYC status: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetKYCVerificationByReference retrieves a verification by its provider reference.
+func (d *DB) GetKYCVerificationByReference(provider, reference string) (models.KYCVerification, bool, error) {
+	var v models.KYCVerification
+	err := d.Get(&v,
+		"SELECT * FROM kyc_verifications WHERE provider = $1 AND provider_reference = $2",
+		provider, reference,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, false, nil
+		}
+		return v, false, err
+	}
+	if v, err = d.openKYCVerification(v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+// GetKYCVerificationsByUserID lists the verifications of a user, most recent first.
+func (d *DB) GetKYCVerificationsByUserID(userID string) ([]models.KYCVerification, error) {
+	var verifications []models.KYCVerification
+	err := d.Select(&verifications, "SELECT * FROM kyc_verifications WHERE user_id = $1 ORDER BY requested_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	if verifications == nil {
+		verifications = []models.KYCVerification{}
+	}
+	for i, v := range verifications {
+		if verifications[i], err = d.openKYCVerification(v); err != nil {
+			return nil, err
+		}
+	}
+	return verifications, nil
+}
+
+// openKYCVerification decrypts the encrypted columns of a stored verification.
+func (d *DB) openKYCVerification(v models.KYCVerification) (_ models.KYCVerification, err error) {
+	if v.RedirectURL, err = d.Fields.Decrypt(v.RedirectURL, fieldAAD("kyc_verifications", "redirect_url", v.ID)); err != nil {
+		return v, err
+	}
+	if v.FailureReason, err = d.Fields.Decrypt(v.FailureReason, fieldAAD("kyc_verifications", "failure_reason", v.ID)); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// CompleteKYCVerification records the outcome of a pending verification
+// delivered by webhook deliveryID and, for the user's latest verification,
+// copies it onto the user. It returns false when the verification is no
+// longer pending; a delivery recorded before fails with a unique violation.
+func (d *DB) CompleteKYCVerification(v models.KYCVerification, deliveryID string) (completed bool, err error) {
+	failureReason, err := d.Fields.Encrypt(v.FailureReason, fieldAAD("kyc_verifications", "failure_reason", v.ID))
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := d.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(
+		`INSERT INTO kyc_webhook_deliveries (provider, delivery_id, verification_id) VALUES ($1, $2, $3)`,
+		v.Provider, deliveryID, v.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	result, err := tx.Exec(
+		`UPDATE kyc_verifications SET status = $1, level = $2, failure_reason = $3, completed_at = $4, expires_at = $5
+		 WHERE id = $6 AND status = $7`,
+		v.Status, v.Level, failureReason, v.CompletedAt, v.ExpiresAt, v.ID, models.KYCStatusPending,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update verification: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, tx.Rollback()
+	}
+
+	// A late callback for an older verification must not override a newer one
+	_, err = tx.Exec(
+		`UPDATE users u SET kyc_status = $1, kyc_level = $2, kyc_verified_at = $3, kyc_expires_at = $4
+		 WHERE u.id = $5
+		   AND NOT EXISTS (SELECT 1 FROM kyc_verifications k
+		                   WHERE k.user_id = u.id AND k.requested_at > (SELECT requested_at FROM kyc_verifications WHERE id = $6))`,
+		v.Status, v.Level, v.CompletedAt, v.ExpiresAt, v.UserID, v.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update user KYC status: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// SaveKYCDocument stores a document reference.
+func (d *DB) SaveKYCDocument(doc models.KYCDocument) error {
+	reference, err := d.Fields.Encrypt(&doc.Reference, fieldAAD("kyc_documents", "reference", doc.ID))
+	if err != nil {
+		return err
+	}
+	doc.Reference = *reference
+
+	query := `
+		INSERT INTO kyc_documents (id, user_id, verification_id, document_type, reference, sha256, created_at)
+		VALUES (:id, :user_id, :verification_id, :document_type, :reference, :sha256, :created_at)
+	`
+	_, err = d.NamedExec(query, doc)
+	return err
+}
+
+// GetKYCDocumentsByUserID lists the document references of a user.
+func (d *DB) GetKYCDocumentsByUserID(userID string) ([]models.KYCDocument, error) {
+	var docs []models.KYCDocument
+	err := d.Select(&docs, "SELECT * FROM kyc_documents WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	if docs == nil {
+		docs = []models.KYCDocument{}
+	}
+	for i, doc := range docs {
+		reference, err := d.Fields.Decrypt(&doc.Reference, fieldAAD("kyc_documents", "reference", doc.ID))
+		if err != nil {
+			return nil, err
+		}
+		docs[i].Reference = *reference
+	}
+	return docs, nil
+}
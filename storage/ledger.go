@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const insertLedgerEntryQuery = `
+	INSERT INTO ledger_entries (asset_id, owner_id, solana_pub_key, amount, entry_type, transaction_id, slot, block_time)
+	VALUES (:asset_id, :owner_id, :solana_pub_key, :amount, :entry_type, :transaction_id, :slot, :block_time)
+	ON CONFLICT (transaction_id, solana_pub_key, entry_type) DO NOTHING
+`
+
+// RecordLedgerEntry appends a movement to the ledger. Re-recording the same
+// (transaction, wallet, type) is a no-op.
+func (d *DB) RecordLedgerEntry(entry models.LedgerEntry) error {
+	return recordLedgerEntry(d.DB, entry)
+}
+
+// recordLedgerEntry appends a movement using either the DB or an open transaction.
+func recordLedgerEntry(e sqlx.Ext, entry models.LedgerEntry) error {
+	if entry.BlockTime.IsZero() {
+		entry.BlockTime = time.Now()
+	}
+	_, err := sqlx.NamedExec(e, insertLedgerEntryQuery, entry)
+	return err
+}
+
+// ConfirmLedgerEntries attaches the finalized slot and block time to the
+// entries of a transaction that were booked before it reached the chain.
+func (d *DB) ConfirmLedgerEntries(txID string, slot int64, blockTime time.Time) error {
+	_, err := d.Exec(
+		`UPDATE ledger_entries SET slot = $1, block_time = $2 WHERE transaction_id = $3 AND slot IS NULL`,
+		slot, blockTime, txID,
+	)
+	return err
+}
+
+// journalOwnerMovement appends a movement for a registered user, resolving
+// their wallet inside the same transaction.
+func journalOwnerMovement(e sqlx.Execer, assetID, ownerID string, amount float64, entryType, txID string) error {
+	_, err := e.Exec(
+		`INSERT INTO ledger_entries (asset_id, owner_id, solana_pub_key, amount, entry_type, transaction_id, block_time)
+		 SELECT $1, id, solana_pub_key, $3, $4, $5, NOW() FROM users WHERE id = $2
+		 ON CONFLICT (transaction_id, solana_pub_key, entry_type) DO NOTHING`,
+		assetID, ownerID, amount, entryType, txID,
+	)
+	return err
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrOrderNotFillable is returned by RecordTrade when one of the orders was
+// cancelled or filled in the meantime.
+var ErrOrderNotFillable = errors.New("order is no longer fillable")
+
+// liveOrderStatuses are the statuses of orders that still commit funds.
+var liveOrderStatuses = []string{models.OrderStatusPendingApproval, models.OrderStatusOpen, models.OrderStatusPartiallyFilled}
+
+// SaveMarket creates or updates the market of an asset.
+func (d *DB) SaveMarket(market models.Market) error {
+	query := `
+		INSERT INTO markets (asset_id, quote_mint, quote_decimals, status, created_at, updated_at)
+		VALUES (:asset_id, :quote_mint, :quote_decimals, :status, :created_at, :updated_at)
+		ON CONFLICT (asset_id) DO UPDATE
+		SET quote_mint = EXCLUDED.quote_mint, quote_decimals = EXCLUDED.quote_decimals,
+		    status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+	`
+	_, err := d.NamedExec(query, market)
+	return err
+}
+
+// GetMarket retrieves the market of an asset.
+func (d *DB) GetMarket(assetID string) (models.Market, bool, error) {
+	var market models.Market
+	err := d.Get(&market, "SELECT * FROM markets WHERE asset_id = $1", assetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return market, false, nil
+		}
+		return market, false, err
+	}
+	return market, true, nil
+}
+
+// SaveOrder creates an order.
+func (d *DB) SaveOrder(order models.Order) error {
+	query := `
+		INSERT INTO orders (id, asset_id, user_id, side, price, quantity, remaining_quantity, status, approval_transaction,
+		                    created_at, updated_at)
+		VALUES (:id, :asset_id, :user_id, :side, :price, :quantity, :remaining_quantity, :status, :approval_transaction,
+		        :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, order)
+	return err
+}
+
+// GetOrder retrieves an order by ID.
+func (d *DB) GetOrder(id string) (models.Order, bool, error) {
+	var order models.Order
+	err := d.Get(&order, "SELECT * FROM orders WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return order, false, nil
+		}
+		return order, false, err
+	}
+	return order, true, nil
+}
+
+// GetOrdersByAssetID lists the orders of an asset, newest first, optionally
+// restricted to one user and to the given statuses.
+func (d *DB) GetOrdersByAssetID(assetID, userID string, statuses ...string) ([]models.Order, error) {
+	var orders []models.Order
+	err := d.Select(&orders,
+		`SELECT * FROM orders
+		 WHERE asset_id = $1 AND ($2 = '' OR user_id::text = $2) AND (cardinality($3::text[]) = 0 OR status = ANY($3))
+		 ORDER BY created_at DESC`,
+		assetID, userID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+	return orders, nil
+}
+
+// GetLiveOrders lists the orders of a user on one side of an asset's book
+// that still commit funds, including those waiting for approval.
+func (d *DB) GetLiveOrders(userID, assetID, side string) ([]models.Order, error) {
+	var orders []models.Order
+	err := d.Select(&orders,
+		`SELECT * FROM orders WHERE user_id = $1 AND asset_id = $2 AND side = $3 AND status = ANY($4)`,
+		userID, assetID, side, pq.Array(liveOrderStatuses),
+	)
+	return orders, err
+}
+
+// GetMatchableOrders lists the resting orders on one side of an asset's book
+// that cross limitPrice, in price-time priority: lowest asks or highest bids
+// first, oldest first within a price.
+func (d *DB) GetMatchableOrders(assetID, side string, limitPrice float64) ([]models.Order, error) {
+	query := `SELECT * FROM orders WHERE asset_id = $1 AND side = $2 AND status = ANY($3) AND price <= $4
+	          ORDER BY price ASC, created_at ASC`
+	if side == models.OrderSideBuy {
+		query = `SELECT * FROM orders WHERE asset_id = $1 AND side = $2 AND status = ANY($3) AND price >= $4
+		         ORDER BY price DESC, created_at ASC`
+	}
+	var orders []models.Order
+	err := d.Select(&orders, query,
+		assetID, side, pq.Array([]string{models.OrderStatusOpen, models.OrderStatusPartiallyFilled}), limitPrice,
+	)
+	return orders, err
+}
+
+// ActivateOrder opens an order once its delegate approval landed. It returns
+// false when the order was not waiting for approval.
+func (d *DB) ActivateOrder(id, approvalTxID string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE orders SET status = $1, approval_tx_id = $2, updated_at = NOW() WHERE id = $3 AND status = $4`,
+		models.OrderStatusOpen, approvalTxID, id, models.OrderStatusPendingApproval,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// CancelOrder cancels an order that is still live. It returns false when the
+// order was already filled or cancelled.
+func (d *DB) CancelOrder(id string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = ANY($3)`,
+		models.OrderStatusCancelled, id, pq.Array(liveOrderStatuses),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// fillOrder takes quantity out of a resting order within tx.
+func fillOrder(tx *sqlx.Tx, orderID string, quantity float64) error {
+	result, err := tx.Exec(
+		`UPDATE orders
+		 SET remaining_quantity = remaining_quantity - $1,
+		     status = CASE WHEN remaining_quantity - $1 = 0 THEN $2 ELSE $3 END,
+		     updated_at = NOW()
+		 WHERE id = $4 AND status = ANY($5) AND remaining_quantity >= $1`,
+		quantity, models.OrderStatusFilled, models.OrderStatusPartiallyFilled, orderID,
+		pq.Array([]string{models.OrderStatusOpen, models.OrderStatusPartiallyFilled}),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrOrderNotFillable
+	}
+	return nil
+}
+
+// RecordTrade fills both orders of a trade and stores it, atomically. It
+// returns ErrOrderNotFillable when either order can no longer take the fill.
+func (d *DB) RecordTrade(trade models.Trade) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fillOrder(tx, trade.BuyOrderID, trade.Quantity); err != nil {
+		return err
+	}
+	if err = fillOrder(tx, trade.SellOrderID, trade.Quantity); err != nil {
+		return err
+	}
+	_, err = tx.NamedExec(
+		`INSERT INTO trades (id, asset_id, buy_order_id, sell_order_id, buyer_id, seller_id, price, quantity, quote_atomic, status, created_at)
+		 VALUES (:id, :asset_id, :buy_order_id, :sell_order_id, :buyer_id, :seller_id, :price, :quantity, :quote_atomic, :status, :created_at)`,
+		trade,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save trade: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetTrade retrieves a trade by ID.
+func (d *DB) GetTrade(id string) (models.Trade, bool, error) {
+	var trade models.Trade
+	err := d.Get(&trade, "SELECT * FROM trades WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return trade, false, nil
+		}
+		return trade, false, err
+	}
+	return trade, true, nil
+}
+
+// GetTradesByAssetID lists the trades of an asset, newest first.
+func (d *DB) GetTradesByAssetID(assetID string) ([]models.Trade, error) {
+	var trades []models.Trade
+	err := d.Select(&trades, "SELECT * FROM trades WHERE asset_id = $1 ORDER BY created_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if trades == nil {
+		trades = []models.Trade{}
+	}
+	return trades, nil
+}
+
+// MarkTradeSent records the swap transaction carrying a trade.
+func (d *DB) MarkTradeSent(id, txID string) error {
+	_, err := d.Exec(
+		`UPDATE trades SET status = $1, transaction_id = $2, last_error = NULL WHERE id = $3`,
+		models.TradeStatusSent, txID, id,
+	)
+	return err
+}
+
+// MarkTradeSettled marks a trade settled once its swap is confirmed.
+func (d *DB) MarkTradeSettled(id string) error {
+	_, err := d.Exec(
+		`UPDATE trades SET status = $1, settled_at = NOW() WHERE id = $2`,
+		models.TradeStatusSettled, id,
+	)
+	return err
+}
+
+// FailTrade marks a trade failed and gives its quantity back to both orders,
+// unless they were cancelled meanwhile.
+func (d *DB) FailTrade(trade models.Trade, reason string) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(`UPDATE trades SET status = $1, last_error = $2 WHERE id = $3`, models.TradeStatusFailed, reason, trade.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark trade failed: %w", err)
+	}
+	_, err = tx.Exec(
+		`UPDATE orders
+		 SET remaining_quantity = remaining_quantity + $1,
+		     status = CASE WHEN remaining_quantity + $1 >= quantity THEN $2 ELSE $3 END,
+		     updated_at = NOW()
+		 WHERE id = ANY($4) AND status <> $5`,
+		trade.Quantity, models.OrderStatusOpen, models.OrderStatusPartiallyFilled,
+		pq.Array([]string{trade.BuyOrderID, trade.SellOrderID}), models.OrderStatusCancelled,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore orders: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetOrderBook aggregates the resting orders of an asset per side and price.
+func (d *DB) GetOrderBook(assetID string) (models.OrderBook, error) {
+	var levels []struct {
+		Side     string  `json:"side"`
+		Price    float64 `json:"price"`
+		Quantity float64 `json:"quantity"`
+		Orders   int     `json:"orders"`
+	}
+	err := d.Select(&levels,
+		`SELECT side, price, SUM(remaining_quantity) AS quantity, COUNT(*) AS orders
+		 FROM orders
+		 WHERE asset_id = $1 AND status = ANY($2)
+		 GROUP BY side, price
+		 ORDER BY price`,
+		assetID, pq.Array([]string{models.OrderStatusOpen, models.OrderStatusPartiallyFilled}),
+	)
+	if err != nil {
+		return models.OrderBook{}, err
+	}
+
+	book := models.OrderBook{AssetID: assetID, Bids: []models.PriceLevel{}, Asks: []models.PriceLevel{}}
+	for _, l := range levels {
+		level := models.PriceLevel{Price: l.Price, Quantity: l.Quantity, Orders: l.Orders}
+		if l.Side == models.OrderSideBuy {
+			// Bids come out ascending; best (highest) first
+			book.Bids = append([]models.PriceLevel{level}, book.Bids...)
+		} else {
+			book.Asks = append(book.Asks, level)
+		}
+	}
+	return book, nil
+}
@@ -0,0 +1,18 @@
+-- V10__vesting_schedules.sql
+-- Lock-up and vesting schedules restricting the transferable part of holdings
+
+CREATE TABLE IF NOT EXISTS vesting_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    owner_id UUID NOT NULL REFERENCES users(id),
+    total_amount NUMERIC(20, 9) NOT NULL CHECK (total_amount > 0),
+    schedule_type VARCHAR(20) NOT NULL,
+    start_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    cliff_months INTEGER NOT NULL DEFAULT 0 CHECK (cliff_months >= 0),
+    duration_months INTEGER NOT NULL CHECK (duration_months > 0),
+    description TEXT,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_vesting_schedules_owner_asset ON vesting_schedules (owner_id, asset_id);
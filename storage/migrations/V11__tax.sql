@@ -0,0 +1,49 @@
+-- V11__tax.sql
+-- Cost-basis lots, realized gains with withholding, and configurable rate tables
+
+CREATE TABLE IF NOT EXISTS tax_lots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    owner_id UUID NOT NULL REFERENCES users(id),
+    quantity NUMERIC(20, 9) NOT NULL CHECK (quantity > 0),
+    remaining_quantity NUMERIC(20, 9) NOT NULL CHECK (remaining_quantity >= 0),
+    total_cost NUMERIC(20, 2) NOT NULL CHECK (total_cost >= 0),
+    remaining_cost NUMERIC(20, 2) NOT NULL CHECK (remaining_cost >= 0),
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    transaction_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_lots_owner_asset ON tax_lots (owner_id, asset_id, acquired_at);
+
+CREATE TABLE IF NOT EXISTS tax_rate_tables (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL UNIQUE,
+    cost_method VARCHAR(20) NOT NULL,
+    withholding_rate NUMERIC(10, 8) NOT NULL,
+    monthly_exemption NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    gain_brackets JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS disposals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    owner_id UUID NOT NULL REFERENCES users(id),
+    quantity NUMERIC(20, 9) NOT NULL,
+    uncovered_quantity NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    proceeds NUMERIC(20, 2) NOT NULL,
+    cost_basis NUMERIC(20, 2) NOT NULL,
+    gain NUMERIC(20, 2) NOT NULL,
+    cost_method VARCHAR(20) NOT NULL,
+    rate_table_id UUID REFERENCES tax_rate_tables(id),
+    withholding_rate NUMERIC(10, 8) NOT NULL,
+    withholding_amount NUMERIC(20, 2) NOT NULL,
+    transaction_id VARCHAR(100) NOT NULL,
+    disposed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT disposals_transaction_owner_unique UNIQUE (transaction_id, owner_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_disposals_owner_date ON disposals (owner_id, disposed_at);
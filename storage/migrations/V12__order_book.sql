@@ -0,0 +1,47 @@
+-- V12__order_book.sql
+-- Secondary market: per-asset markets, limit orders and the trades matching them
+
+CREATE TABLE IF NOT EXISTS markets (
+    asset_id UUID PRIMARY KEY REFERENCES assets(id),
+    quote_mint VARCHAR(64) NOT NULL,
+    quote_decimals INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    side VARCHAR(4) NOT NULL,
+    price NUMERIC(20, 9) NOT NULL CHECK (price > 0),
+    quantity NUMERIC(20, 9) NOT NULL CHECK (quantity > 0),
+    remaining_quantity NUMERIC(20, 9) NOT NULL CHECK (remaining_quantity >= 0),
+    status VARCHAR(20) NOT NULL,
+    approval_tx_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_book ON orders (asset_id, side, status, price, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user ON orders (user_id, asset_id);
+
+CREATE TABLE IF NOT EXISTS trades (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    buy_order_id UUID NOT NULL REFERENCES orders(id),
+    sell_order_id UUID NOT NULL REFERENCES orders(id),
+    buyer_id UUID NOT NULL REFERENCES users(id),
+    seller_id UUID NOT NULL REFERENCES users(id),
+    price NUMERIC(20, 9) NOT NULL,
+    quantity NUMERIC(20, 9) NOT NULL CHECK (quantity > 0),
+    quote_atomic BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    last_error TEXT,
+    transaction_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_trades_asset ON trades (asset_id, created_at);
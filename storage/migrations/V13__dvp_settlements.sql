@@ -0,0 +1,26 @@
+-- V13__dvp_settlements.sql
+-- Delivery-versus-payment settlements and the signatures collected for them
+
+CREATE TABLE IF NOT EXISTS dvp_settlements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    seller_id UUID NOT NULL REFERENCES users(id),
+    buyer_id UUID NOT NULL REFERENCES users(id),
+    quantity NUMERIC(20, 9) NOT NULL CHECK (quantity > 0),
+    payment_mint VARCHAR(64) NOT NULL,
+    payment_decimals INTEGER NOT NULL,
+    payment_amount NUMERIC(20, 9) NOT NULL CHECK (payment_amount > 0),
+    status VARCHAR(20) NOT NULL,
+    transaction TEXT NOT NULL,
+    seller_signed_at TIMESTAMP WITH TIME ZONE,
+    buyer_signed_at TIMESTAMP WITH TIME ZONE,
+    transaction_id VARCHAR(100),
+    last_error TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_dvp_settlements_asset ON dvp_settlements (asset_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_dvp_settlements_status ON dvp_settlements (status);
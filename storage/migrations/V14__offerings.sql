@@ -0,0 +1,50 @@
+-- V14__offerings.sql
+-- Primary offerings and the investor subscriptions they collect
+
+CREATE TABLE IF NOT EXISTS offerings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    payment_mint VARCHAR(64) NOT NULL,
+    payment_decimals INTEGER NOT NULL,
+    price NUMERIC(20, 9) NOT NULL CHECK (price > 0),
+    min_raise NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    max_raise NUMERIC(20, 9) NOT NULL CHECK (max_raise > 0),
+    min_per_investor NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    max_per_investor NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    total_paid NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    total_allocated NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    shares_issued NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (min_raise <= max_raise),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_offerings_due ON offerings (status, ends_at);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    offering_id UUID NOT NULL REFERENCES offerings(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount NUMERIC(20, 9) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL,
+    payment_transaction TEXT NOT NULL,
+    payment_tx_id VARCHAR(100) UNIQUE,
+    allocated_amount NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    allocated_shares NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    refund_amount NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    issue_tx_id VARCHAR(100),
+    issued_at TIMESTAMP WITH TIME ZONE,
+    refund_tx_id VARCHAR(100),
+    refunded_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_offering ON subscriptions (offering_id, status);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user ON subscriptions (user_id);
@@ -0,0 +1,30 @@
+-- V15__escrows.sql
+-- Escrows holding tokens in backend-controlled accounts until a condition is met
+
+CREATE TABLE IF NOT EXISTS escrows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID REFERENCES assets(id),
+    mint VARCHAR(64) NOT NULL,
+    decimals INTEGER NOT NULL,
+    amount NUMERIC(20, 9) NOT NULL CHECK (amount > 0),
+    depositor_id UUID NOT NULL REFERENCES users(id),
+    beneficiary_id UUID NOT NULL REFERENCES users(id),
+    escrow_account VARCHAR(64) NOT NULL UNIQUE,
+    condition_type VARCHAR(20) NOT NULL,
+    condition_reference VARCHAR(100),
+    status VARCHAR(20) NOT NULL,
+    deposit_transaction TEXT NOT NULL,
+    deposit_tx_id VARCHAR(100) UNIQUE,
+    settlement_tx_id VARCHAR(100),
+    last_error TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    funded_at TIMESTAMP WITH TIME ZONE,
+    settled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (depositor_id <> beneficiary_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_escrows_depositor ON escrows (depositor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_escrows_beneficiary ON escrows (beneficiary_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_escrows_status ON escrows (status);
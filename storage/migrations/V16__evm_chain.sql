@@ -0,0 +1,6 @@
+-- V16__evm_chain.sql
+-- Assets issued as ERC-20 contracts on EVM chains, and holder EVM wallets
+
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS chain VARCHAR(16) NOT NULL DEFAULT 'solana';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS evm_address VARCHAR(42) UNIQUE;
@@ -0,0 +1,38 @@
+-- V17__bridges.sql
+-- Solana <-> EVM bridges and the transfers they mirror
+
+CREATE TABLE IF NOT EXISTS bridges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL UNIQUE REFERENCES assets(id),
+    mint VARCHAR(64) NOT NULL,
+    custody_account VARCHAR(64) NOT NULL UNIQUE,
+    evm_token VARCHAR(42) NOT NULL UNIQUE,
+    evm_custody_address VARCHAR(42) NOT NULL,
+    last_solana_signature VARCHAR(100),
+    last_evm_block BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS bridge_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bridge_id UUID NOT NULL REFERENCES bridges(id),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    source_chain VARCHAR(16) NOT NULL,
+    source_tx_id VARCHAR(100) NOT NULL,
+    source_index INTEGER NOT NULL,
+    sender VARCHAR(64) NOT NULL,
+    recipient VARCHAR(64),
+    user_id UUID REFERENCES users(id),
+    amount NUMERIC(20, 9) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL,
+    destination_transaction TEXT,
+    destination_tx_id VARCHAR(100) UNIQUE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (source_chain, source_tx_id, source_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bridge_transfers_asset ON bridge_transfers (asset_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_bridge_transfers_status ON bridge_transfers (status);
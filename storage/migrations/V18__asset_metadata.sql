@@ -0,0 +1,6 @@
+-- V18__asset_metadata.sql
+-- Issuer details and public documents published in the token metadata
+
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS issuer VARCHAR(255);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS isin CHAR(12) UNIQUE;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS links JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,22 @@
+-- V19__asset_documents.sql
+-- Versioned asset disclosures with their hashes anchored on Solana
+
+CREATE TABLE IF NOT EXISTS documents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    version INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    anchor_signature VARCHAR(100),
+    anchored_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (asset_id, name, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_unanchored ON documents (created_at) WHERE anchor_signature IS NULL;
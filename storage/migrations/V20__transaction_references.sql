@@ -0,0 +1,16 @@
+-- V20__transaction_references.sql
+-- Business objects referenced by the memos of finalized backend transactions
+
+CREATE TABLE IF NOT EXISTS transaction_references (
+    transaction_id VARCHAR(100) NOT NULL,
+    reference_type VARCHAR(32) NOT NULL,
+    reference_id VARCHAR(100) NOT NULL,
+    external_reference VARCHAR(64),
+    slot BIGINT NOT NULL,
+    block_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (transaction_id, reference_type, reference_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_references_object ON transaction_references (reference_type, reference_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_references_external ON transaction_references (external_reference) WHERE external_reference IS NOT NULL;
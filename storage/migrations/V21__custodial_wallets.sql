@@ -0,0 +1,31 @@
+-- V21__custodial_wallets.sql
+-- Backend-held wallets for users who do not manage their own keys
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS custody VARCHAR(16) NOT NULL DEFAULT 'self';
+
+CREATE TABLE IF NOT EXISTS custodial_wallets (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    solana_pub_key VARCHAR(44) NOT NULL UNIQUE,
+    evm_address VARCHAR(42) NOT NULL UNIQUE,
+    master_key_id VARCHAR(16) NOT NULL,
+    encrypted_data_key BYTEA,
+    encrypted_solana_key BYTEA,
+    encrypted_evm_key BYTEA,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    max_transfer_amount NUMERIC(20, 9),
+    daily_transfer_limit NUMERIC(20, 9),
+    exported_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS custodial_signatures (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES custodial_wallets(user_id),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    amount NUMERIC(20, 9) NOT NULL,
+    transaction_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_custodial_signatures_window ON custodial_signatures (user_id, asset_id, created_at);
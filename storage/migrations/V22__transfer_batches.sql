@@ -0,0 +1,37 @@
+-- V22__transfer_batches.sql
+-- Batch transfers and airdrops to many recipients, with per-row status
+
+CREATE TABLE IF NOT EXISTS transfer_batches (
+    id UUID PRIMARY KEY,
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    kind VARCHAR(16) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    total_amount NUMERIC(30, 9) NOT NULL,
+    row_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_batches_asset_id ON transfer_batches (asset_id);
+CREATE INDEX IF NOT EXISTS idx_transfer_batches_status ON transfer_batches (status);
+
+CREATE TABLE IF NOT EXISTS batch_transfers (
+    id UUID PRIMARY KEY,
+    batch_id UUID NOT NULL REFERENCES transfer_batches(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    recipient_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    amount_atomic BIGINT NOT NULL,
+    amount NUMERIC(30, 9) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    transaction_id VARCHAR(100),
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT batch_transfers_row_unique UNIQUE (batch_id, row_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_batch_transfers_batch_status ON batch_transfers (batch_id, status);
+CREATE INDEX IF NOT EXISTS idx_batch_transfers_transaction_id ON batch_transfers (transaction_id);
@@ -0,0 +1,21 @@
+-- V23__asset_lifecycle.sql
+-- Asset lifecycle states and the history of their transitions
+
+-- Assets created before lifecycle states were introduced stay tradable
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+CREATE TABLE IF NOT EXISTS asset_status_changes (
+    id UUID PRIMARY KEY,
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    ordered_by VARCHAR(255),
+    freeze_status VARCHAR(20),
+    accounts_affected INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_status_changes_asset_id ON asset_status_changes (asset_id, created_at);
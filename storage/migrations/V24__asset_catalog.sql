@@ -0,0 +1,17 @@
+-- V24__asset_catalog.sql
+-- Catalog fields, optimistic concurrency and search indexes for assets
+
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS issuer_cnpj CHAR(14);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS asset_class VARCHAR(20);
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Trigram indexes serve the catalog's substring search on symbol, name and issuer
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_assets_symbol_trgm ON assets USING GIN (symbol gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_assets_name_trgm ON assets USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_assets_issuer_trgm ON assets USING GIN (issuer gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_assets_issuer_cnpj ON assets (issuer_cnpj);
+CREATE INDEX IF NOT EXISTS idx_assets_status_class ON assets (status, asset_class);
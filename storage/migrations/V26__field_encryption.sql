@@ -0,0 +1,15 @@
+-- V26__field_encryption.sql
+-- Personal data encrypted at rest, with blind indexes for equality lookups
+
+-- Ciphertexts are longer than the plaintext limits of these columns
+ALTER TABLE users ALTER COLUMN name TYPE TEXT;
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
+ALTER TABLE users ALTER COLUMN tax_id TYPE TEXT;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_index CHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tax_id_index CHAR(64);
+
+-- Ciphertexts are randomized, so email uniqueness moves to its blind index
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_index ON users (email_index);
+CREATE INDEX IF NOT EXISTS idx_users_tax_id_index ON users (tax_id_index);
@@ -0,0 +1,7 @@
+-- V27__custodial_wallet_export_confirmation.sql
+-- Two-step custodial wallet export authorized by a key the user holds
+
+-- Public key of the user's own device or app, which authorizes exports
+ALTER TABLE custodial_wallets ADD COLUMN IF NOT EXISTS authorization_key VARCHAR(44);
+ALTER TABLE custodial_wallets ADD COLUMN IF NOT EXISTS export_challenge TEXT;
+ALTER TABLE custodial_wallets ADD COLUMN IF NOT EXISTS export_challenge_expires_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,20 @@
+-- V28__transfer_intents.sql
+-- Transfers prepared for a holder to sign, which completion is bound to
+
+CREATE TABLE IF NOT EXISTS transfer_intents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    from_user_id UUID NOT NULL REFERENCES users(id),
+    to_user_id UUID NOT NULL REFERENCES users(id),
+    amount NUMERIC(20, 9) NOT NULL CHECK (amount > 0),
+    destination VARCHAR(64) NOT NULL,
+    transaction TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transaction_id VARCHAR(100),
+    last_error TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_intents_sender ON transfer_intents (from_user_id, created_at);
@@ -0,0 +1,4 @@
+-- V29__order_approval_transaction.sql
+-- Approval transaction prepared for an order, which activation is bound to
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS approval_transaction TEXT;
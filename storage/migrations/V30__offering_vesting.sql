@@ -0,0 +1,4 @@
+-- V30__offering_vesting.sql
+-- Vesting terms restricting the shares an offering allocates
+
+ALTER TABLE offerings ADD COLUMN IF NOT EXISTS vesting JSONB;
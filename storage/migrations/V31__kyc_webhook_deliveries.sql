@@ -0,0 +1,10 @@
+-- V31__kyc_webhook_deliveries.sql
+-- KYC webhook deliveries already applied, so a replayed callback is refused
+
+CREATE TABLE IF NOT EXISTS kyc_webhook_deliveries (
+    provider VARCHAR(50) NOT NULL,
+    delivery_id VARCHAR(255) NOT NULL,
+    verification_id UUID NOT NULL REFERENCES kyc_verifications(id),
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, delivery_id)
+);
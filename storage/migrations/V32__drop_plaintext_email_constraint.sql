@@ -0,0 +1,5 @@
+-- V32__drop_plaintext_email_constraint.sql
+-- Drops the unique constraint on plaintext emails where V26 left it in place
+
+-- Email uniqueness is enforced by idx_users_email_index since V26
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
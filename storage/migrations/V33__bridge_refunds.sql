@@ -0,0 +1,4 @@
+-- V33__bridge_refunds.sql
+-- Rejected Solana bridge locks can be released back to their sender
+
+ALTER TABLE bridge_transfers ADD COLUMN IF NOT EXISTS refund BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- V34__proposal_anchor_claims.sql
+-- Proposals are claimed before their result is anchored, and the signed memo is kept to rebroadcast it
+
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS anchor_transaction TEXT;
+ALTER TABLE proposals ADD COLUMN IF NOT EXISTS anchored_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,52 @@
+-- V3__ledger_and_snapshots.sql
+-- Append-only ledger of balance movements and point-in-time holdings snapshots
+
+-- Every credit/debit of a holding, never updated except to attach the
+-- confirming slot once the listener sees the transaction finalized
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    owner_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    amount NUMERIC(20, 9) NOT NULL, -- Positive for credits, negative for debits
+    entry_type VARCHAR(32) NOT NULL,
+    transaction_id VARCHAR(100) NOT NULL,
+    slot BIGINT, -- NULL until the transaction is seen finalized
+    block_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ledger_entries_tx_wallet_type_unique UNIQUE (transaction_id, solana_pub_key, entry_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_asset_time ON ledger_entries (asset_id, block_time);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_asset_slot ON ledger_entries (asset_id, slot);
+
+-- Seed the ledger with the current token records. Past debits were applied in
+-- place, so history before this migration is only as good as those records.
+INSERT INTO ledger_entries (asset_id, owner_id, solana_pub_key, amount, entry_type, transaction_id, block_time)
+SELECT t.asset_id, t.owner_id, u.solana_pub_key, t.amount, 'opening_balance', t.transaction_id, t.created_at
+FROM tokens t
+JOIN users u ON u.id = t.owner_id
+WHERE t.amount > 0
+ON CONFLICT DO NOTHING;
+
+-- Named snapshots (record dates) of an asset's holdings
+CREATE TABLE IF NOT EXISTS snapshots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    name VARCHAR(255) NOT NULL,
+    record_date TIMESTAMP WITH TIME ZONE,
+    slot BIGINT,
+    total_amount NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    holder_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT snapshots_asset_name_unique UNIQUE (asset_id, name),
+    CONSTRAINT snapshots_cutoff_check CHECK (record_date IS NOT NULL OR slot IS NOT NULL)
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_holdings (
+    snapshot_id UUID NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    owner_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    amount NUMERIC(20, 9) NOT NULL,
+    PRIMARY KEY (snapshot_id, solana_pub_key)
+);
@@ -0,0 +1,36 @@
+-- V4__distributions.sql
+-- Pro-rata dividend / income distributions and per-holder payouts
+
+CREATE TABLE IF NOT EXISTS distributions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    snapshot_id UUID NOT NULL REFERENCES snapshots(id),
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    payout_mint VARCHAR(64) NOT NULL,
+    payout_decimals SMALLINT NOT NULL,
+    total_amount NUMERIC(30, 9) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_distributions_asset_id ON distributions (asset_id);
+
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    distribution_id UUID NOT NULL REFERENCES distributions(id) ON DELETE CASCADE,
+    owner_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    holding_amount NUMERIC(20, 9) NOT NULL,
+    amount_atomic BIGINT NOT NULL,
+    amount NUMERIC(30, 9) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    transaction_id VARCHAR(100),
+    paid_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT payouts_distribution_wallet_unique UNIQUE (distribution_id, solana_pub_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_distribution_status ON payouts (distribution_id, status);
@@ -0,0 +1,46 @@
+-- V5__proposals_and_votes.sql
+-- Shareholder proposals with wallet-signed, holdings-weighted votes
+
+CREATE TABLE IF NOT EXISTS proposals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    snapshot_id UUID NOT NULL REFERENCES snapshots(id),
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    options TEXT[] NOT NULL,
+    quorum_percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    opens_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    closes_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    result_hash VARCHAR(64),
+    anchor_tx_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT proposals_window_check CHECK (closes_at > opens_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_proposals_asset_id ON proposals (asset_id);
+
+-- Server-issued messages a voter signs with their wallet (single use)
+CREATE TABLE IF NOT EXISTS ballot_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    proposal_id UUID NOT NULL REFERENCES proposals(id) ON DELETE CASCADE,
+    voter_id UUID NOT NULL REFERENCES users(id),
+    option TEXT NOT NULL,
+    message TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS votes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    proposal_id UUID NOT NULL REFERENCES proposals(id) ON DELETE CASCADE,
+    voter_id UUID NOT NULL REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    option TEXT NOT NULL,
+    weight NUMERIC(20, 9) NOT NULL,
+    message TEXT NOT NULL,
+    signature VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT votes_proposal_voter_unique UNIQUE (proposal_id, voter_id)
+);
@@ -0,0 +1,39 @@
+-- V6__corporate_actions.sql
+-- Splits / reverse splits and the per-holder adjustments they produce
+
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    asset_id UUID NOT NULL REFERENCES assets(id),
+    action_type VARCHAR(20) NOT NULL,
+    ratio_from BIGINT NOT NULL CHECK (ratio_from > 0),
+    ratio_to BIGINT NOT NULL CHECK (ratio_to > 0),
+    effective_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    rounding_unit NUMERIC(20, 9) NOT NULL,
+    cash_in_lieu_price NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    snapshot_id UUID REFERENCES snapshots(id),
+    shares_before NUMERIC(20, 9),
+    shares_after NUMERIC(20, 9),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    executed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_corporate_actions_due ON corporate_actions (status, effective_date);
+
+CREATE TABLE IF NOT EXISTS holding_adjustments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    action_id UUID NOT NULL REFERENCES corporate_actions(id) ON DELETE CASCADE,
+    owner_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    holding_before NUMERIC(20, 9) NOT NULL,
+    holding_after NUMERIC(20, 9) NOT NULL,
+    delta_atomic BIGINT NOT NULL,
+    fractional_shares NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    cash_in_lieu_amount NUMERIC(20, 9) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    transaction_id VARCHAR(100),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT holding_adjustments_action_wallet_unique UNIQUE (action_id, solana_pub_key)
+);
@@ -0,0 +1,12 @@
+-- V7__compliance_rules.sql
+-- Structured per-asset compliance rules and the investor attributes they check
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS jurisdiction VARCHAR(2);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS investor_category VARCHAR(20);
+
+CREATE TABLE IF NOT EXISTS asset_rule_sets (
+    asset_id UUID PRIMARY KEY REFERENCES assets(id),
+    rules JSONB NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
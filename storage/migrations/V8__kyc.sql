@@ -0,0 +1,36 @@
+-- V8__kyc.sql
+-- KYC status on users, provider verifications and document references
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_status VARCHAR(20) NOT NULL DEFAULT 'unverified';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_level INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS kyc_verifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    provider VARCHAR(50) NOT NULL,
+    provider_reference VARCHAR(255) NOT NULL,
+    level INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    redirect_url TEXT,
+    failure_reason TEXT,
+    requested_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT kyc_verifications_provider_reference_unique UNIQUE (provider, provider_reference)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kyc_verifications_user ON kyc_verifications (user_id, requested_at DESC);
+
+CREATE TABLE IF NOT EXISTS kyc_documents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    verification_id UUID REFERENCES kyc_verifications(id),
+    document_type VARCHAR(50) NOT NULL,
+    reference TEXT NOT NULL,
+    sha256 VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_user ON kyc_documents (user_id);
@@ -0,0 +1,53 @@
+-- V9__aml_screening.sql
+-- Watchlists (sanctions, PEP, blocked wallets), screening audit trail and hits review queue
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tax_id VARCHAR(14); -- CPF (11) or CNPJ (14) digits
+
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    list_name VARCHAR(100) NOT NULL,
+    list_type VARCHAR(20) NOT NULL,
+    name VARCHAR(500),
+    tax_id VARCHAR(14),
+    wallet_address VARCHAR(100),
+    source_ref VARCHAR(255),
+    fingerprint VARCHAR(64) NOT NULL,
+    imported_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT watchlist_entries_list_fingerprint_unique UNIQUE (list_name, fingerprint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_entries_list ON watchlist_entries (list_name);
+CREATE INDEX IF NOT EXISTS idx_watchlist_entries_tax_id ON watchlist_entries (tax_id) WHERE tax_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_watchlist_entries_wallet ON watchlist_entries (wallet_address) WHERE wallet_address IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS screenings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    context VARCHAR(50) NOT NULL,
+    reference VARCHAR(255),
+    user_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    result VARCHAR(20) NOT NULL,
+    hit_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_screenings_wallet ON screenings (solana_pub_key, created_at DESC);
+
+-- Entries keep their ID across re-imports (by fingerprint), so decided hits stay decided
+CREATE TABLE IF NOT EXISTS screening_hits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entry_id UUID NOT NULL,
+    user_id UUID REFERENCES users(id),
+    solana_pub_key VARCHAR(64) NOT NULL,
+    match_type VARCHAR(20) NOT NULL,
+    score NUMERIC(5, 4) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    decided_by VARCHAR(255),
+    decision_note TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT screening_hits_entry_wallet_unique UNIQUE (entry_id, solana_pub_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_screening_hits_status ON screening_hits (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_screening_hits_wallet ON screening_hits (solana_pub_key);
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Delivery legs of a subscription, each sent and confirmed on its own.
+const (
+	SubscriptionLegIssue  = "issue"  // Minting the allocated shares
+	SubscriptionLegRefund = "refund" // Returning the unallocated payment
+)
+
+// SaveOffering creates an offering.
+func (d *DB) SaveOffering(offering models.Offering) error {
+	query := `
+		INSERT INTO offerings (id, asset_id, payment_mint, payment_decimals, price, min_raise, max_raise, min_per_investor,
+		                       max_per_investor, starts_at, ends_at, status, vesting, created_at, updated_at)
+		VALUES (:id, :asset_id, :payment_mint, :payment_decimals, :price, :min_raise, :max_raise, :min_per_investor,
+		        :max_per_investor, :starts_at, :ends_at, :status, :vesting, :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, offering)
+	return err
+}
+
+// GetOffering retrieves an offering by ID.
+func (d *DB) GetOffering(id string) (models.Offering, bool, error) {
+	var offering models.Offering
+	err := d.Get(&offering, "SELECT * FROM offerings WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return offering, false, nil
+		}
+		return offering, false, err
+	}
+	return offering, true, nil
+}
+
+// GetOfferingsByAssetID lists the offerings of an asset, newest first.
+func (d *DB) GetOfferingsByAssetID(assetID string) ([]models.Offering, error) {
+	var offerings []models.Offering
+	err := d.Select(&offerings, "SELECT * FROM offerings WHERE asset_id = $1 ORDER BY starts_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if offerings == nil {
+		offerings = []models.Offering{}
+	}
+	return offerings, nil
+}
+
+// GetDueOfferings lists the open offerings whose subscription period has ended.
+func (d *DB) GetDueOfferings(now time.Time) ([]models.Offering, error) {
+	var offerings []models.Offering
+	err := d.Select(&offerings,
+		`SELECT * FROM offerings WHERE status = $1 AND ends_at <= $2 ORDER BY ends_at`,
+		models.OfferingStatusOpen, now,
+	)
+	return offerings, err
+}
+
+// ClaimOffering moves an offering to `to` only if it is currently in one of
+// the `from` statuses. It returns false when another caller got there first.
+func (d *DB) ClaimOffering(id, to string, from ...string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE offerings SET status = $1, updated_at = NOW() WHERE id = $2 AND status = ANY($3)`,
+		to, id, pq.Array(from),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// FinishOffering sets the final status of an offering.
+func (d *DB) FinishOffering(id, status string) error {
+	_, err := d.Exec(
+		`UPDATE offerings SET status = $1, closed_at = NOW(), updated_at = NOW() WHERE id = $2`,
+		status, id,
+	)
+	return err
+}
+
+// SaveSubscription creates a subscription.
+func (d *DB) SaveSubscription(subscription models.Subscription) error {
+	query := `
+		INSERT INTO subscriptions (id, offering_id, user_id, amount, status, payment_transaction, created_at, updated_at)
+		VALUES (:id, :offering_id, :user_id, :amount, :status, :payment_transaction, :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, subscription)
+	return err
+}
+
+// GetSubscription retrieves a subscription by ID.
+func (d *DB) GetSubscription(id string) (models.Subscription, bool, error) {
+	var subscription models.Subscription
+	err := d.Get(&subscription, "SELECT * FROM subscriptions WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return subscription, false, nil
+		}
+		return subscription, false, err
+	}
+	return subscription, true, nil
+}
+
+// GetSubscriptions lists the subscriptions of an offering, oldest first,
+// optionally restricted to the given statuses.
+func (d *DB) GetSubscriptions(offeringID string, statuses ...string) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := d.Select(&subscriptions,
+		`SELECT * FROM subscriptions
+		 WHERE offering_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2))
+		 ORDER BY created_at`,
+		offeringID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if subscriptions == nil {
+		subscriptions = []models.Subscription{}
+	}
+	return subscriptions, nil
+}
+
+// GetSubscribedAmount sums what a user has subscribed to an offering,
+// paid or awaiting payment.
+func (d *DB) GetSubscribedAmount(offeringID, userID string) (float64, error) {
+	var total float64
+	err := d.Get(&total,
+		`SELECT COALESCE(SUM(amount), 0) FROM subscriptions WHERE offering_id = $1 AND user_id = $2 AND status = ANY($3)`,
+		offeringID, userID, pq.Array([]string{models.SubscriptionStatusPendingPayment, models.SubscriptionStatusPaid}),
+	)
+	return total, err
+}
+
+// CancelSubscription cancels a subscription still awaiting payment. It
+// returns false otherwise.
+func (d *DB) CancelSubscription(id string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		models.SubscriptionStatusCancelled, id, models.SubscriptionStatusPendingPayment,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// ConfirmSubscriptionPayment marks a subscription paid and adds it to the
+// offering's total, atomically. It returns false when the subscription was
+// not awaiting payment.
+func (d *DB) ConfirmSubscriptionPayment(subscription models.Subscription, txID string) (confirmed bool, err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(
+		`UPDATE subscriptions SET status = $1, payment_tx_id = $2, updated_at = NOW() WHERE id = $3 AND status = $4`,
+		models.SubscriptionStatusPaid, txID, subscription.ID, models.SubscriptionStatusPendingPayment,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, tx.Rollback()
+	}
+	_, err = tx.Exec(
+		`UPDATE offerings SET total_paid = total_paid + $1, updated_at = NOW() WHERE id = $2`,
+		subscription.Amount, subscription.OfferingID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// AllocateOffering cancels the unpaid subscriptions of a closing offering and
+// stores the allocation of every paid one together with the offering totals.
+func (d *DB) AllocateOffering(offeringID string, allocations []models.Subscription, totalAllocated, sharesIssued float64) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(
+		`UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE offering_id = $2 AND status = $3`,
+		models.SubscriptionStatusCancelled, offeringID, models.SubscriptionStatusPendingPayment,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel unpaid subscriptions: %w", err)
+	}
+	for _, a := range allocations {
+		_, err = tx.Exec(
+			`UPDATE subscriptions SET status = $1, allocated_amount = $2, allocated_shares = $3, refund_amount = $4, updated_at = NOW()
+			 WHERE id = $5 AND status = $6`,
+			models.SubscriptionStatusAllocated, a.AllocatedAmount, a.AllocatedShares, a.RefundAmount, a.ID, models.SubscriptionStatusPaid,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to allocate subscription %s: %w", a.ID, err)
+		}
+	}
+	_, err = tx.Exec(
+		`UPDATE offerings SET total_allocated = $1, shares_issued = $2, updated_at = NOW() WHERE id = $3`,
+		totalAllocated, sharesIssued, offeringID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update offering totals: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// legColumns returns the sent and confirmed columns of a delivery leg.
+func legColumns(leg string) (string, string) {
+	if leg == SubscriptionLegIssue {
+		return "issue_tx_id", "issued_at"
+	}
+	return "refund_tx_id", "refunded_at"
+}
+
+// MarkSubscriptionsSent records the transaction carrying one leg of a batch.
+func (d *DB) MarkSubscriptionsSent(ids []string, leg, txID string) error {
+	sentColumn, _ := legColumns(leg)
+	_, err := d.Exec(
+		fmt.Sprintf(`UPDATE subscriptions SET %s = $1, last_error = NULL, updated_at = NOW() WHERE id = ANY($2)`, sentColumn),
+		txID, pq.Array(ids),
+	)
+	return err
+}
+
+// ResetSubscriptionsSent clears a leg whose transaction failed or expired so
+// it is sent again.
+func (d *DB) ResetSubscriptionsSent(ids []string, leg, reason string) error {
+	sentColumn, _ := legColumns(leg)
+	_, err := d.Exec(
+		fmt.Sprintf(`UPDATE subscriptions SET %s = NULL, last_error = $1, updated_at = NOW() WHERE id = ANY($2)`, sentColumn),
+		reason, pq.Array(ids),
+	)
+	return err
+}
+
+// ConfirmSubscriptionRefunds marks the refund leg of a batch confirmed.
+func (d *DB) ConfirmSubscriptionRefunds(ids []string) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`UPDATE subscriptions SET refunded_at = NOW(), updated_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return err
+	}
+	if err = completeSubscriptions(tx, ids); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IssueSubscriptionShares books the confirmed mint of a batch: marks the
+// issue leg confirmed, journals each holder's new shares, credits their token
+// records, saves the vesting schedules restricting them and raises the
+// asset's total shares.
+func (d *DB) IssueSubscriptionShares(assetID string, subscriptions []models.Subscription, schedules []models.VestingSchedule, txID string) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	ids := make([]string, len(subscriptions))
+	var issued float64
+	for i, s := range subscriptions {
+		ids[i] = s.ID
+		issued += s.AllocatedShares
+		if err = journalOwnerMovement(tx, assetID, s.UserID, s.AllocatedShares, models.LedgerEntryOfferingIssue, txID); err != nil {
+			return fmt.Errorf("failed to journal shares of subscription %s: %w", s.ID, err)
+		}
+		if err = adjustOwnerTokens(tx, assetID, s.UserID, s.AllocatedShares, txID); err != nil {
+			return fmt.Errorf("failed to credit shares of subscription %s: %w", s.ID, err)
+		}
+	}
+	for _, schedule := range schedules {
+		if _, err = tx.NamedExec(insertVestingScheduleQuery, schedule); err != nil {
+			return fmt.Errorf("failed to save vesting schedule of %s: %w", schedule.OwnerID, err)
+		}
+	}
+	if _, err = tx.Exec(`UPDATE subscriptions SET issued_at = NOW(), updated_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE assets SET total_shares = total_shares + $1 WHERE id = $2`, issued, assetID); err != nil {
+		return fmt.Errorf("failed to update total shares: %w", err)
+	}
+	if err = completeSubscriptions(tx, ids); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// completeSubscriptions moves allocated subscriptions whose legs are all
+// confirmed to their final status.
+func completeSubscriptions(tx *sqlx.Tx, ids []string) error {
+	_, err := tx.Exec(
+		`UPDATE subscriptions SET status = CASE WHEN allocated_shares > 0 THEN $1 ELSE $2 END
+		 WHERE id = ANY($3) AND status = $4
+		   AND (allocated_shares = 0 OR issued_at IS NOT NULL)
+		   AND (refund_amount = 0 OR refunded_at IS NOT NULL)`,
+		models.SubscriptionStatusIssued, models.SubscriptionStatusRefunded, pq.Array(ids), models.SubscriptionStatusAllocated,
+	)
+	return err
+}
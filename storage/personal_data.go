@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// rotationPageSize is the number of rows key rotation reads at a time.
+const rotationPageSize = 500
+
+// encryptedColumn is a column holding personal data, encrypted at rest.
+type encryptedColumn struct {
+	Name  string
+	Index string // Blind index column, for the columns looked up by equality
+}
+
+// encryptedTables lists the tables whose columns are encrypted at rest.
+// Tables are keyed by a UUID id column, which every ciphertext is bound to.
+// KYC provider references and the identifiers linking screening hits to
+// watchlist entries stay in plaintext: they are looked up and deduplicated
+// by equality, and name no one without the provider's or the list's records.
+var encryptedTables = []struct {
+	Table   string
+	Columns []encryptedColumn
+}{
+	{"users", []encryptedColumn{{Name: "name"}, {Name: "email", Index: "email_index"}, {Name: "tax_id", Index: "tax_id_index"}}},
+	{"kyc_verifications", []encryptedColumn{{Name: "redirect_url"}, {Name: "failure_reason"}}},
+	{"kyc_documents", []encryptedColumn{{Name: "reference"}}},
+	{"screening_hits", []encryptedColumn{{Name: "decision_note"}}},
+}
+
+// fieldAAD binds a ciphertext to its table, column and row.
+func fieldAAD(table, column, id string) string {
+	return table + "." + column + ":" + id
+}
+
+// openRecord decrypts the encrypted columns of a row exported as JSON.
+// Rows of tables without encrypted columns are returned as they are.
+func (d *DB) openRecord(table string, record json.RawMessage) (json.RawMessage, error) {
+	for _, t := range encryptedTables {
+		if t.Table != table {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(record, &fields); err != nil {
+			return nil, err
+		}
+		var id string
+		if err := json.Unmarshal(fields["id"], &id); err != nil {
+			return nil, fmt.Errorf("record without id: %w", err)
+		}
+		for _, c := range t.Columns {
+			raw, ok := fields[c.Name]
+			if !ok {
+				continue
+			}
+			var value *string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+			opened, err := d.Fields.Decrypt(value, fieldAAD(table, c.Name, id))
+			if err != nil {
+				return nil, fmt.Errorf("%s of %s: %w", c.Name, id, err)
+			}
+			if fields[c.Name], err = json.Marshal(opened); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(fields)
+	}
+	return record, nil
+}
+
+// userRow is a users row as stored: its personal data encrypted, along
+// with the blind indexes it is looked up by.
+type userRow struct {
+	models.User
+	EmailIndex *string `json:"email_index"`
+	TaxIDIndex *string `json:"tax_id_index"`
+}
+
+// sealUser encrypts a user's personal data and derives its blind indexes.
+func (d *DB) sealUser(user models.User) (row userRow, err error) {
+	row.User = user
+	if row.EmailIndex, err = d.Fields.BlindIndex("users.email", user.Email); err != nil {
+		return row, err
+	}
+	if row.TaxIDIndex, err = d.Fields.BlindIndex("users.tax_id", user.TaxID); err != nil {
+		return row, err
+	}
+	if row.Name, err = d.Fields.Encrypt(user.Name, fieldAAD("users", "name", user.ID)); err != nil {
+		return row, err
+	}
+	if row.Email, err = d.Fields.Encrypt(user.Email, fieldAAD("users", "email", user.ID)); err != nil {
+		return row, err
+	}
+	if row.TaxID, err = d.Fields.Encrypt(user.TaxID, fieldAAD("users", "tax_id", user.ID)); err != nil {
+		return row, err
+	}
+	return row, nil
+}
+
+// openUser decrypts the personal data of a stored user.
+func (d *DB) openUser(row userRow) (user models.User, err error) {
+	user = row.User
+	if user.Name, err = d.Fields.Decrypt(row.Name, fieldAAD("users", "name", user.ID)); err != nil {
+		return user, err
+	}
+	if user.Email, err = d.Fields.Decrypt(row.Email, fieldAAD("users", "email", user.ID)); err != nil {
+		return user, err
+	}
+	if user.TaxID, err = d.Fields.Decrypt(row.TaxID, fieldAAD("users", "tax_id", user.ID)); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// getUser runs a query returning a single users row and decrypts it.
+func (d *DB) getUser(query string, args ...any) (models.User, bool, error) {
+	var row userRow
+	err := d.Get(&row, query, args...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.User{}, false, nil
+		}
+		return models.User{}, false, err
+	}
+	user, err := d.openUser(row)
+	if err != nil {
+		return models.User{}, false, err
+	}
+	return user, true, nil
+}
+
+// RotateFieldKeys re-encrypts with the current key every encrypted value
+// sealed with an older key, or still in plaintext, and fills in missing
+// blind indexes. A row changed while it is rotated is skipped, as the
+// change already wrote it with the current key. It returns the number of
+// rows rewritten.
+func (d *DB) RotateFieldKeys() (int, error) {
+	rotated := 0
+	for _, t := range encryptedTables {
+		n, err := d.rotateTable(t.Table, t.Columns)
+		rotated += n
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rotate %s: %w", t.Table, err)
+		}
+	}
+	return rotated, nil
+}
+
+// SealLegacyFields encrypts the personal data still stored in plaintext and
+// fills in missing blind indexes. The server runs it before serving traffic,
+// so lookups by blind index never miss a legacy row. It returns the number of
+// rows rewritten.
+func (d *DB) SealLegacyFields() (int, error) {
+	sealed := 0
+	for _, t := range encryptedTables {
+		legacy, err := d.countLegacyRows(t.Table, t.Columns)
+		if err != nil {
+			return sealed, fmt.Errorf("failed to count legacy rows of %s: %w", t.Table, err)
+		}
+		if legacy == 0 {
+			continue
+		}
+		n, err := d.rotateTable(t.Table, t.Columns)
+		sealed += n
+		if err != nil {
+			return sealed, fmt.Errorf("failed to seal %s: %w", t.Table, err)
+		}
+		if legacy, err = d.countLegacyRows(t.Table, t.Columns); err != nil {
+			return sealed, fmt.Errorf("failed to count legacy rows of %s: %w", t.Table, err)
+		}
+		if legacy > 0 {
+			return sealed, fmt.Errorf("%d rows of %s are still in plaintext or unindexed", legacy, t.Table)
+		}
+	}
+	return sealed, nil
+}
+
+// countLegacyRows counts the rows of a table with a value in plaintext or
+// without its blind index.
+func (d *DB) countLegacyRows(table string, columns []encryptedColumn) (int, error) {
+	var conditions []string
+	for _, c := range columns {
+		conditions = append(conditions, fmt.Sprintf("(%s IS NOT NULL AND %s NOT LIKE '%s%%')", c.Name, c.Name, ciphertextPrefix))
+		if c.Index != "" {
+			conditions = append(conditions, fmt.Sprintf("(%s IS NOT NULL AND %s IS NULL)", c.Name, c.Index))
+		}
+	}
+	var n int
+	err := d.Get(&n, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, strings.Join(conditions, " OR ")))
+	return n, err
+}
+
+func (d *DB) rotateTable(table string, columns []encryptedColumn) (int, error) {
+	selected := []string{"id"}
+	for _, c := range columns {
+		selected = append(selected, c.Name)
+		if c.Index != "" {
+			selected = append(selected, c.Index)
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id > $1 ORDER BY id LIMIT %d", strings.Join(selected, ", "), table, rotationPageSize)
+
+	rotated := 0
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for {
+		var page [][]*string
+		rows, err := d.Query(query, lastID)
+		if err != nil {
+			return rotated, err
+		}
+		for rows.Next() {
+			values := make([]*string, len(selected))
+			dest := make([]any, len(values))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return rotated, err
+			}
+			page = append(page, values)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rotated, err
+		}
+
+		for _, values := range page {
+			ok, err := d.rotateRow(table, columns, values)
+			if err != nil {
+				return rotated, fmt.Errorf("row %s: %w", *values[0], err)
+			}
+			if ok {
+				rotated++
+			}
+		}
+		if len(page) < rotationPageSize {
+			return rotated, nil
+		}
+		lastID = *page[len(page)-1][0]
+	}
+}
+
+// rotateRow rewrites the encrypted columns of a row that are not sealed with
+// the current key and the blind indexes that are stale, guarded on the
+// values read. It returns false when the row needed nothing or had changed.
+func (d *DB) rotateRow(table string, columns []encryptedColumn, values []*string) (bool, error) {
+	id := *values[0]
+	var sets, guards []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	i := 1
+	for _, c := range columns {
+		stored := values[i]
+		i++
+		aad := fieldAAD(table, c.Name, id)
+		plaintext, err := d.Fields.Decrypt(stored, aad)
+		if err != nil {
+			return false, err
+		}
+		guards = append(guards, fmt.Sprintf("%s IS NOT DISTINCT FROM %s", c.Name, arg(stored)))
+		if !d.Fields.IsCurrent(stored) {
+			sealed, err := d.Fields.Encrypt(plaintext, aad)
+			if err != nil {
+				return false, err
+			}
+			sets = append(sets, fmt.Sprintf("%s = %s", c.Name, arg(sealed)))
+		}
+		if c.Index == "" {
+			continue
+		}
+		storedIndex := values[i]
+		i++
+		index, err := d.Fields.BlindIndex(table+"."+c.Name, plaintext)
+		if err != nil {
+			return false, err
+		}
+		if (index == nil) != (storedIndex == nil) || (index != nil && *index != *storedIndex) {
+			sets = append(sets, fmt.Sprintf("%s = %s", c.Index, arg(index)))
+		}
+	}
+	if len(sets) == 0 {
+		return false, nil
+	}
+
+	result, err := d.Exec(
+		fmt.Sprintf("UPDATE %s SET %s WHERE id = %s AND %s", table, strings.Join(sets, ", "), arg(id), strings.Join(guards, " AND ")),
+		args...,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// userRecordSources lists the tables holding records about a user, with the
+// condition matching them and the columns exported. Custodial keys are never
+// exported, and AML screenings are withheld: disclosing them would tip off
+// the user about a suspicion the law requires to stay confidential.
+var userRecordSources = []struct {
+	Table, Columns, Where string
+}{
+	{"custodial_wallets", "user_id, solana_pub_key, evm_address, status, max_transfer_amount, daily_transfer_limit, exported_at, created_at, updated_at", "user_id = $1"},
+	{"custodial_signatures", "*", "user_id = $1"},
+	{"kyc_verifications", "*", "user_id = $1"},
+	{"kyc_documents", "*", "user_id = $1"},
+	{"tokens", "*", "owner_id = $1"},
+	{"transfer_intents", "*", "from_user_id = $1 OR to_user_id = $1"},
+	{"ledger_entries", "*", "owner_id = $1"},
+	{"snapshot_holdings", "*", "owner_id = $1"},
+	{"payouts", "*", "owner_id = $1"},
+	{"votes", "*", "voter_id = $1"},
+	{"ballot_challenges", "*", "voter_id = $1"},
+	{"holding_adjustments", "*", "owner_id = $1"},
+	{"vesting_schedules", "*", "owner_id = $1"},
+	{"tax_lots", "*", "owner_id = $1"},
+	{"disposals", "*", "owner_id = $1"},
+	{"orders", "*", "user_id = $1"},
+	{"trades", "*", "buyer_id = $1 OR seller_id = $1"},
+	{"dvp_settlements", "*", "buyer_id = $1 OR seller_id = $1"},
+	{"subscriptions", "*", "user_id = $1"},
+	{"escrows", "*", "depositor_id = $1 OR beneficiary_id = $1"},
+	{"bridge_transfers", "*", "user_id = $1"},
+	{"batch_transfers", "*", "recipient_id = $1"},
+	{"data_subject_requests", "*", "user_id = $1"},
+}
+
+// RecordRetentions lists the records kept after a user is erased. Erasure
+// is lawful under LGPD art. 16, I, only for data no legal obligation requires
+// to keep: KYC records identify the customer for anti-money laundering
+// purposes, and the ledger and tax records back the asset registry and the
+// taxes withheld. KYC document files are held by the provider; only their
+// references and digests are kept here, so they can be produced on request.
+var RecordRetentions = []models.RecordRetention{
+	{
+		Tables:     []string{"kyc_verifications", "kyc_documents"},
+		LegalBasis: "Lei 9.613/1998, art. 10, I and § 2º: customer identification records",
+		Period:     "5 years after the end of the relationship",
+	},
+	{
+		Tables:     []string{"tokens", "ledger_entries", "tax_lots", "disposals", "payouts"},
+		LegalBasis: "Código Tributário Nacional, art. 195, sole paragraph: records backing taxes withheld and reported",
+		Period:     "until the tax claims they refer to are time-barred (5 years)",
+	},
+}
+
+// userCommitmentSources lists the tables holding a user's unfinished
+// operations, with the statuses that are not final.
+var userCommitmentSources = []struct {
+	Table, Where string
+	Statuses     []string
+}{
+	{"orders", "user_id = $1", []string{models.OrderStatusPendingApproval, models.OrderStatusOpen, models.OrderStatusPartiallyFilled}},
+	{"trades", "(buyer_id = $1 OR seller_id = $1)", []string{models.TradeStatusPending, models.TradeStatusSent}},
+	{"dvp_settlements", "(buyer_id = $1 OR seller_id = $1)", []string{models.DvPStatusAwaitingSignatures, models.DvPStatusSubmitted}},
+	{"subscriptions", "user_id = $1", []string{models.SubscriptionStatusPendingPayment, models.SubscriptionStatusPaid, models.SubscriptionStatusAllocated}},
+	{"escrows", "(depositor_id = $1 OR beneficiary_id = $1)", []string{
+		models.EscrowStatusAwaitingDeposit, models.EscrowStatusFunded, models.EscrowStatusReleasing, models.EscrowStatusRefunding,
+	}},
+	{"bridge_transfers", "user_id = $1", []string{models.BridgeTransferStatusPending, models.BridgeTransferStatusSending}},
+}
+
+// GetUserRecords returns, by table, every row referring to a user as JSON.
+// Tables without rows for the user are left out.
+func (d *DB) GetUserRecords(userID string) (map[string][]json.RawMessage, error) {
+	records := make(map[string][]json.RawMessage)
+	for _, src := range userRecordSources {
+		var rows []json.RawMessage
+		query := fmt.Sprintf("SELECT row_to_json(r) FROM (SELECT %s FROM %s WHERE %s) r", src.Columns, src.Table, src.Where)
+		if err := d.Select(&rows, query, userID); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", src.Table, err)
+		}
+		for i, row := range rows {
+			opened, err := d.openRecord(src.Table, row)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", src.Table, err)
+			}
+			rows[i] = opened
+		}
+		if len(rows) > 0 {
+			records[src.Table] = rows
+		}
+	}
+	return records, nil
+}
+
+// CountUserOpenCommitments counts, by table, the operations of a user that
+// have not reached a final status. Tables without any are left out.
+func (d *DB) CountUserOpenCommitments(userID string) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, src := range userCommitmentSources {
+		var n int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s AND status = ANY($2)", src.Table, src.Where)
+		if err := d.Get(&n, query, userID, pq.Array(src.Statuses)); err != nil {
+			return nil, fmt.Errorf("failed to count open %s: %w", src.Table, err)
+		}
+		if n > 0 {
+			counts[src.Table] = n
+		}
+	}
+	return counts, nil
+}
+
+// UpdateUserProfile replaces the profile fields of a user that was not
+// erased. With resetKYC, the user's KYC verification is invalidated
+// atomically: the user goes back to pending with no level, and the
+// verifications still pending are expired so they cannot verify the
+// previous profile. It returns false when the user does not exist or was
+// erased.
+func (d *DB) UpdateUserProfile(user models.User, resetKYC bool) (updated models.User, ok bool, err error) {
+	row, err := d.sealUser(user)
+	if err != nil {
+		return models.User{}, false, err
+	}
+
+	tx, err := d.Beginx()
+	if err != nil {
+		return models.User{}, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !ok {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var stored userRow
+	err = tx.Get(&stored,
+		`UPDATE users
+		 SET name = $1, email = $2, email_index = $3, evm_address = $4, tax_id = $5, tax_id_index = $6, jurisdiction = $7,
+		     investor_category = $8, updated_at = NOW(),
+		     kyc_status = CASE WHEN $9 THEN $10 ELSE kyc_status END,
+		     kyc_level = CASE WHEN $9 THEN 0 ELSE kyc_level END,
+		     kyc_verified_at = CASE WHEN $9 THEN NULL ELSE kyc_verified_at END,
+		     kyc_expires_at = CASE WHEN $9 THEN NULL ELSE kyc_expires_at END
+		 WHERE id = $11 AND erased_at IS NULL
+		 RETURNING *`,
+		row.Name, row.Email, row.EmailIndex, row.EVMAddress, row.TaxID, row.TaxIDIndex, row.Jurisdiction,
+		row.InvestorCategory, resetKYC, models.KYCStatusPending, row.ID,
+	)
+	if err == sql.ErrNoRows {
+		return models.User{}, false, nil
+	}
+	if err != nil {
+		return models.User{}, false, err
+	}
+
+	if resetKYC {
+		_, err = tx.Exec(`UPDATE kyc_verifications SET status = $1 WHERE user_id = $2 AND status = $3`,
+			models.KYCStatusExpired, row.ID, models.KYCStatusPending)
+		if err != nil {
+			return models.User{}, false, fmt.Errorf("failed to expire pending KYC verifications: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.User{}, false, err
+	}
+	if updated, err = d.openUser(stored); err != nil {
+		return models.User{}, false, err
+	}
+	return updated, true, nil
+}
+
+// SaveDataSubjectRequest records a request a user made about their personal data.
+func (d *DB) SaveDataSubjectRequest(req models.DataSubjectRequest) error {
+	_, err := d.NamedExec(
+		`INSERT INTO data_subject_requests (id, user_id, kind, created_at) VALUES (:id, :user_id, :kind, :created_at)`,
+		req,
+	)
+	return err
+}
+
+// EraseUser pseudonymizes a user: their name, email and tax ID are removed,
+// their KYC status is reset so they must verify again to hold restricted
+// assets, the parts of their KYC verifications no retention obligation
+// covers (the provider's redirect URL and free-text failure reason) are
+// removed, and the erasure is recorded, atomically. The row itself, with its
+// wallet addresses, stays so the ledger and the records listed in
+// RecordRetentions still resolve. It returns false when the user was already
+// erased.
+func (d *DB) EraseUser(req models.DataSubjectRequest) (user models.User, ok bool, err error) {
+	var row userRow
+	tx, err := d.Beginx()
+	if err != nil {
+		return user, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !ok {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.Get(&row,
+		`UPDATE users
+		 SET name = NULL, email = NULL, email_index = NULL, tax_id = NULL, tax_id_index = NULL, kyc_status = $1, kyc_level = 0, kyc_verified_at = NULL,
+		     kyc_expires_at = NULL, erased_at = $2, updated_at = $2
+		 WHERE id = $3 AND erased_at IS NULL
+		 RETURNING *`,
+		models.KYCStatusUnverified, req.CreatedAt, req.UserID,
+	)
+	if err == sql.ErrNoRows {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE kyc_verifications SET redirect_url = NULL, failure_reason = NULL WHERE user_id = $1`, req.UserID)
+	if err != nil {
+		return user, false, fmt.Errorf("failed to erase KYC verifications: %w", err)
+	}
+
+	_, err = tx.NamedExec(
+		`INSERT INTO data_subject_requests (id, user_id, kind, created_at) VALUES (:id, :user_id, :kind, :created_at)`,
+		req,
+	)
+	if err != nil {
+		return user, false, fmt.Errorf("failed to record erasure: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return user, false, err
+	}
+	return row.User, true, nil
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// ErrChallengeUsed is returned when a ballot challenge was already redeemed.
+var ErrChallengeUsed = errors.New("ballot challenge already used")
+
+// SaveProposal creates a proposal.
+func (d *DB) SaveProposal(proposal models.Proposal) error {
+	query := `
+		INSERT INTO proposals (id, asset_id, snapshot_id, title, description, options, quorum_percent, opens_at, closes_at, status, created_at)
+		VALUES (:id, :asset_id, :snapshot_id, :title, :description, :options, :quorum_percent, :opens_at, :closes_at, :status, :created_at)
+	`
+	_, err := d.NamedExec(query, proposal)
+	return err
+}
+
+// GetProposal retrieves a proposal by ID.
+func (d *DB) GetProposal(id string) (models.Proposal, bool, error) {
+	var proposal models.Proposal
+	err := d.Get(&proposal, "SELECT * FROM proposals WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return proposal, false, nil
+		}
+		return proposal, false, err
+	}
+	return proposal, true, nil
+}
+
+// GetProposalsByAssetID lists the proposals of an asset, most recent first.
+func (d *DB) GetProposalsByAssetID(assetID string) ([]models.Proposal, error) {
+	var proposals []models.Proposal
+	err := d.Select(&proposals, "SELECT * FROM proposals WHERE asset_id = $1 ORDER BY opens_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if proposals == nil {
+		proposals = []models.Proposal{}
+	}
+	return proposals, nil
+}
+
+// ClaimProposalFinalization moves a proposal from open to finalizing with
+// its result hash, before the result is anchored. It returns false when the
+// proposal was not open.
+func (d *DB) ClaimProposalFinalization(id, resultHash string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE proposals SET status = $1, result_hash = $2 WHERE id = $3 AND status = $4`,
+		models.ProposalStatusFinalizing, resultHash, id, models.ProposalStatusOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// RecordProposalAnchor records the signed memo anchoring a finalizing
+// proposal's result before it is sent, replacing `previousTxID`. It returns
+// false when another anchor was recorded concurrently.
+func (d *DB) RecordProposalAnchor(id string, previousTxID *string, signedTx, txID string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE proposals SET anchor_transaction = $1, anchor_tx_id = $2, anchored_at = NOW()
+		 WHERE id = $3 AND status = $4 AND anchor_tx_id IS NOT DISTINCT FROM $5`,
+		signedTx, txID, id, models.ProposalStatusFinalizing, previousTxID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// CompleteProposalFinalization marks a proposal whose anchor was confirmed
+// as finalized.
+func (d *DB) CompleteProposalFinalization(id string) error {
+	_, err := d.Exec(
+		`UPDATE proposals SET status = $1 WHERE id = $2 AND status = $3`,
+		models.ProposalStatusFinalized, id, models.ProposalStatusFinalizing,
+	)
+	return err
+}
+
+// SaveBallotChallenge stores a message issued to a voter for signing.
+func (d *DB) SaveBallotChallenge(challenge models.BallotChallenge) error {
+	query := `
+		INSERT INTO ballot_challenges (id, proposal_id, voter_id, option, message, expires_at, created_at)
+		VALUES (:id, :proposal_id, :voter_id, :option, :message, :expires_at, :created_at)
+	`
+	_, err := d.NamedExec(query, challenge)
+	return err
+}
+
+// GetBallotChallenge retrieves a ballot challenge by ID.
+func (d *DB) GetBallotChallenge(id string) (models.BallotChallenge, bool, error) {
+	var challenge models.BallotChallenge
+	err := d.Get(&challenge, "SELECT * FROM ballot_challenges WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return challenge, false, nil
+		}
+		return challenge, false, err
+	}
+	return challenge, true, nil
+}
+
+// CastVote redeems a ballot challenge and records the vote atomically, so a
+// signed message can only ever be counted once.
+func (d *DB) CastVote(challengeID string, vote models.Vote) error {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(
+		`UPDATE ballot_challenges SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`,
+		challengeID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeem ballot challenge: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		err = ErrChallengeUsed
+		return err
+	}
+
+	_, err = tx.NamedExec(
+		`INSERT INTO votes (id, proposal_id, voter_id, solana_pub_key, option, weight, message, signature, created_at)
+		 VALUES (:id, :proposal_id, :voter_id, :solana_pub_key, :option, :weight, :message, :signature, :created_at)`,
+		vote,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record vote: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetVotes lists the votes of a proposal in the order they were cast.
+func (d *DB) GetVotes(proposalID string) ([]models.Vote, error) {
+	var votes []models.Vote
+	err := d.Select(&votes, "SELECT * FROM votes WHERE proposal_id = $1 ORDER BY created_at, id", proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if votes == nil {
+		votes = []models.Vote{}
+	}
+	return votes, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// RecordTransactionReference records a reference found on a transaction. It
+// does nothing if the transaction was already processed.
+func (d *DB) RecordTransactionReference(ref models.TransactionReference) error {
+	_, err := d.NamedExec(`
+		INSERT INTO transaction_references (transaction_id, reference_type, reference_id, external_reference, slot, block_time, created_at)
+		VALUES (:transaction_id, :reference_type, :reference_id, :external_reference, :slot, :block_time, :created_at)
+		ON CONFLICT DO NOTHING
+	`, ref)
+	return err
+}
+
+// GetTransactionReferences lists the references found on a transaction.
+func (d *DB) GetTransactionReferences(transactionID string) ([]models.TransactionReference, error) {
+	var refs []models.TransactionReference
+	err := d.Select(&refs, `SELECT * FROM transaction_references WHERE transaction_id = $1`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if refs == nil {
+		refs = []models.TransactionReference{}
+	}
+	return refs, nil
+}
+
+// GetTransactionsByReference lists the transactions that reference a
+// business object, oldest first.
+func (d *DB) GetTransactionsByReference(referenceType, referenceID string) ([]models.TransactionReference, error) {
+	var refs []models.TransactionReference
+	err := d.Select(&refs,
+		`SELECT * FROM transaction_references WHERE reference_type = $1 AND reference_id = $2 ORDER BY slot`,
+		referenceType, referenceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if refs == nil {
+		refs = []models.TransactionReference{}
+	}
+	return refs, nil
+}
+
+// GetTransactionsByExternalReference lists the transactions carrying a
+// caller-supplied reference, oldest first.
+func (d *DB) GetTransactionsByExternalReference(external string) ([]models.TransactionReference, error) {
+	var refs []models.TransactionReference
+	err := d.Select(&refs,
+		`SELECT * FROM transaction_references WHERE external_reference = $1 ORDER BY slot`,
+		external,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if refs == nil {
+		refs = []models.TransactionReference{}
+	}
+	return refs, nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestRecordTransactionReference(t *testing.T) {
+	db := newTestDB(t, `
+		CREATE TABLE transaction_references (
+			transaction_id TEXT NOT NULL,
+			reference_type TEXT NOT NULL,
+			reference_id TEXT NOT NULL,
+			external_reference TEXT,
+			slot INTEGER NOT NULL,
+			block_time TIMESTAMP NOT NULL,
+			created_at TIMESTAMP,
+			PRIMARY KEY (transaction_id, reference_type, reference_id)
+		);
+	`)
+	invoice := "NF-2025-0042"
+	ref := func(txID, refType, refID string, slot int64) models.TransactionReference {
+		return models.TransactionReference{
+			TransactionID: txID, ReferenceType: refType, ReferenceID: refID, ExternalReference: &invoice,
+			Slot: slot, BlockTime: time.Unix(1_700_000_000+slot, 0).UTC(), CreatedAt: time.Now().UTC(),
+		}
+	}
+
+	// The listener may see a transaction again, e.g., on backfill after a reconnect
+	for _, r := range []models.TransactionReference{
+		ref("sig2", models.ReferenceOrder, "o1", 20),
+		ref("sig1", models.ReferenceOrder, "o1", 10),
+		ref("sig1", models.ReferenceOrder, "o1", 10),
+		ref("sig1", models.ReferenceTrade, "t1", 10),
+	} {
+		if err := db.RecordTransactionReference(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	onTx, err := db.GetTransactionReferences("sig1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onTx) != 2 {
+		t.Fatalf("GetTransactionReferences() = %d references, want 2", len(onTx))
+	}
+	byOrder, err := db.GetTransactionsByReference(models.ReferenceOrder, "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byOrder) != 2 || byOrder[0].TransactionID != "sig1" || byOrder[1].TransactionID != "sig2" {
+		t.Fatalf("GetTransactionsByReference() = %+v, want sig1 then sig2", byOrder)
+	}
+	byInvoice, err := db.GetTransactionsByExternalReference(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byInvoice) != 3 {
+		t.Fatalf("GetTransactionsByExternalReference() = %d references, want 3", len(byInvoice))
+	}
+	none, err := db.GetTransactionReferences("unknown")
+	if err != nil || none == nil || len(none) != 0 {
+		t.Fatalf("GetTransactionReferences(unknown) = %v, %v, want an empty list", none, err)
+	}
+}
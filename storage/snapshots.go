@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// CreateSnapshot materializes the holdings of an asset from the ledger at the
+// snapshot's cutoff and stores them under the snapshot. When a slot is given,
+// only movements already confirmed at or before that slot are counted;
+// otherwise movements up to the record date are.
+func (d *DB) CreateSnapshot(snapshot models.Snapshot) (models.Snapshot, error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NamedExec(
+		`INSERT INTO snapshots (id, asset_id, name, record_date, slot, created_at)
+		 VALUES (:id, :asset_id, :name, :record_date, :slot, :created_at)`,
+		snapshot,
+	)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO snapshot_holdings (snapshot_id, owner_id, solana_pub_key, amount)
+		 SELECT $1, (ARRAY_AGG(owner_id) FILTER (WHERE owner_id IS NOT NULL))[1], solana_pub_key, SUM(amount)
+		 FROM ledger_entries
+		 WHERE asset_id = $2
+		   AND (($3::bigint IS NOT NULL AND slot IS NOT NULL AND slot <= $3)
+		        OR ($3::bigint IS NULL AND block_time <= $4))
+		 GROUP BY solana_pub_key
+		 HAVING SUM(amount) > 0`,
+		snapshot.ID, snapshot.AssetID, snapshot.Slot, snapshot.RecordDate,
+	)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to materialize holdings: %w", err)
+	}
+
+	err = tx.Get(&snapshot,
+		`UPDATE snapshots s
+		 SET total_amount = COALESCE(h.total, 0), holder_count = COALESCE(h.holders, 0)
+		 FROM (SELECT SUM(amount) AS total, COUNT(*) AS holders FROM snapshot_holdings WHERE snapshot_id = $1) h
+		 WHERE s.id = $1
+		 RETURNING s.*`,
+		snapshot.ID,
+	)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to update snapshot totals: %w", err)
+	}
+
+	err = tx.Commit()
+	return snapshot, err
+}
+
+// GetSnapshot retrieves a snapshot by ID, without its holdings.
+func (d *DB) GetSnapshot(id string) (models.Snapshot, bool, error) {
+	var snapshot models.Snapshot
+	err := d.Get(&snapshot, "SELECT * FROM snapshots WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return snapshot, false, nil
+		}
+		return snapshot, false, err
+	}
+	return snapshot, true, nil
+}
+
+// GetSnapshotsByAssetID lists the snapshots of an asset, most recent first.
+func (d *DB) GetSnapshotsByAssetID(assetID string) ([]models.Snapshot, error) {
+	var snapshots []models.Snapshot
+	err := d.Select(&snapshots, "SELECT * FROM snapshots WHERE asset_id = $1 ORDER BY created_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshots == nil {
+		snapshots = []models.Snapshot{}
+	}
+	return snapshots, nil
+}
+
+// GetSnapshotHoldings lists the holdings of a snapshot, largest first.
+func (d *DB) GetSnapshotHoldings(snapshotID string) ([]models.SnapshotHolding, error) {
+	var holdings []models.SnapshotHolding
+	err := d.Select(&holdings,
+		"SELECT * FROM snapshot_holdings WHERE snapshot_id = $1 ORDER BY amount DESC, solana_pub_key",
+		snapshotID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if holdings == nil {
+		holdings = []models.SnapshotHolding{}
+	}
+	return holdings, nil
+}
+
+// GetSnapshotHolding returns the holding of a wallet in a snapshot, zero if none.
+func (d *DB) GetSnapshotHolding(snapshotID, solanaPubKey string) (float64, error) {
+	var amount float64
+	err := d.Get(&amount,
+		`SELECT COALESCE(SUM(amount), 0) FROM snapshot_holdings WHERE snapshot_id = $1 AND solana_pub_key = $2`,
+		snapshotID, solanaPubKey,
+	)
+	return amount, err
+}
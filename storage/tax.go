@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// SaveTaxLot stores an acquisition lot.
+func (d *DB) SaveTaxLot(lot models.TaxLot) error {
+	return saveTaxLot(d.DB, lot)
+}
+
+func saveTaxLot(e sqlx.Ext, lot models.TaxLot) error {
+	_, err := sqlx.NamedExec(e,
+		`INSERT INTO tax_lots (id, asset_id, owner_id, quantity, remaining_quantity, total_cost, remaining_cost,
+		                       acquired_at, source, transaction_id, created_at)
+		 VALUES (:id, :asset_id, :owner_id, :quantity, :remaining_quantity, :total_cost, :remaining_cost,
+		         :acquired_at, :source, :transaction_id, :created_at)`,
+		lot,
+	)
+	return err
+}
+
+// GetTaxLotsByOwnerID lists the lots of a holder, oldest first.
+func (d *DB) GetTaxLotsByOwnerID(ownerID string) ([]models.TaxLot, error) {
+	var lots []models.TaxLot
+	err := d.Select(&lots, "SELECT * FROM tax_lots WHERE owner_id = $1 ORDER BY acquired_at, created_at", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if lots == nil {
+		lots = []models.TaxLot{}
+	}
+	return lots, nil
+}
+
+// RecordTaxMovement moves quantity of an asset between holders for tax
+// purposes, in one transaction:
+//   - the sender's open lots are consumed by the given cost method;
+//   - when disposal is given (a priced transfer), it is stored with the
+//     consumed cost basis and realized gain, and the recipient acquires a lot
+//     at the proceeds;
+//   - otherwise the consumed cost carries over to the recipient's lot.
+func (d *DB) RecordTaxMovement(assetID, fromOwnerID, toOwnerID string, quantity float64, method string,
+	disposal *models.Disposal, txID string, at time.Time,
+) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	cost, uncovered, err := consumeTaxLots(tx, fromOwnerID, assetID, quantity, method)
+	if err != nil {
+		return fmt.Errorf("failed to consume lots: %w", err)
+	}
+
+	lotCost := cost
+	if disposal != nil {
+		disposal.CostBasis = cost
+		disposal.UncoveredQuantity = uncovered
+		disposal.Gain = models.RoundCents(disposal.Proceeds - cost)
+		_, err = tx.NamedExec(
+			`INSERT INTO disposals (id, asset_id, owner_id, quantity, uncovered_quantity, proceeds, cost_basis, gain, cost_method,
+			                        rate_table_id, withholding_rate, withholding_amount, transaction_id, disposed_at)
+			 VALUES (:id, :asset_id, :owner_id, :quantity, :uncovered_quantity, :proceeds, :cost_basis, :gain, :cost_method,
+			         :rate_table_id, :withholding_rate, :withholding_amount, :transaction_id, :disposed_at)`,
+			disposal,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record disposal: %w", err)
+		}
+		lotCost = disposal.Proceeds
+	}
+
+	err = saveTaxLot(tx, models.TaxLot{
+		ID:                uuid.New().String(),
+		AssetID:           assetID,
+		OwnerID:           toOwnerID,
+		Quantity:          quantity,
+		RemainingQuantity: quantity,
+		TotalCost:         lotCost,
+		RemainingCost:     lotCost,
+		AcquiredAt:        at,
+		Source:            "transfer",
+		TransactionID:     &txID,
+		CreatedAt:         time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record recipient lot: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// consumeTaxLots takes quantity out of a holder's open lots and returns the
+// cost taken and the quantity no lot covered.
+func consumeTaxLots(tx *sqlx.Tx, ownerID, assetID string, quantity float64, method string) (float64, float64, error) {
+	var lots []models.TaxLot
+	err := tx.Select(&lots,
+		`SELECT * FROM tax_lots WHERE owner_id = $1 AND asset_id = $2 AND remaining_quantity > 0
+		 ORDER BY acquired_at, created_at FOR UPDATE`,
+		ownerID, assetID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	takes, cost, uncovered := planLotConsumption(lots, quantity, method)
+	for _, t := range takes {
+		_, err = tx.Exec(
+			`UPDATE tax_lots SET remaining_quantity = remaining_quantity - $1, remaining_cost = GREATEST(remaining_cost - $2, 0)
+			 WHERE id = $3`,
+			t.Quantity, t.Cost, t.LotID,
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return cost, uncovered, nil
+}
+
+// lotTake is the quantity and cost taken out of one lot.
+type lotTake struct {
+	LotID    string
+	Quantity float64
+	Cost     float64
+}
+
+// planLotConsumption works out what taking quantity out of open lots, oldest
+// first, takes from each, the total cost taken and the quantity no lot
+// covered. FIFO drains the oldest lots first; average cost takes the same
+// fraction of every lot. A lot taken whole gives up all its remaining cost.
+func planLotConsumption(lots []models.TaxLot, quantity float64, method string) ([]lotTake, float64, float64) {
+	var open float64
+	for _, l := range lots {
+		open += l.RemainingQuantity
+	}
+	take := math.Min(quantity, open)
+	uncovered := roundQuantity(quantity - take)
+	if take <= 0 {
+		return nil, 0, uncovered
+	}
+
+	var takes []lotTake
+	var cost float64
+	remaining := take
+	for _, l := range lots {
+		var q float64
+		switch method {
+		case models.CostMethodFIFO:
+			q = math.Min(l.RemainingQuantity, remaining)
+		default:
+			q = l.RemainingQuantity * take / open
+		}
+		q = roundQuantity(q)
+		if q <= 0 {
+			continue
+		}
+		c := models.RoundCents(l.RemainingCost * q / l.RemainingQuantity)
+		if q >= l.RemainingQuantity {
+			q, c = l.RemainingQuantity, l.RemainingCost
+		}
+		takes = append(takes, lotTake{LotID: l.ID, Quantity: q, Cost: c})
+		cost += c
+		remaining -= q
+		if method == models.CostMethodFIFO && remaining <= 0 {
+			break
+		}
+	}
+	return takes, models.RoundCents(cost), uncovered
+}
+
+// SaveTaxRateTable stores a rate table.
+func (d *DB) SaveTaxRateTable(table models.TaxRateTable) error {
+	query := `
+		INSERT INTO tax_rate_tables (id, name, effective_from, cost_method, withholding_rate, monthly_exemption, gain_brackets, created_at)
+		VALUES (:id, :name, :effective_from, :cost_method, :withholding_rate, :monthly_exemption, :gain_brackets, :created_at)
+	`
+	_, err := d.NamedExec(query, table)
+	return err
+}
+
+// GetTaxRateTables lists the rate tables, most recent first.
+func (d *DB) GetTaxRateTables() ([]models.TaxRateTable, error) {
+	var tables []models.TaxRateTable
+	err := d.Select(&tables, "SELECT * FROM tax_rate_tables ORDER BY effective_from DESC")
+	if err != nil {
+		return nil, err
+	}
+	if tables == nil {
+		tables = []models.TaxRateTable{}
+	}
+	return tables, nil
+}
+
+// GetTaxRateTableAt retrieves the rate table in force at t.
+func (d *DB) GetTaxRateTableAt(t time.Time) (models.TaxRateTable, bool, error) {
+	var table models.TaxRateTable
+	err := d.Get(&table,
+		"SELECT * FROM tax_rate_tables WHERE effective_from <= $1 ORDER BY effective_from DESC LIMIT 1", t,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return table, false, nil
+		}
+		return table, false, err
+	}
+	return table, true, nil
+}
+
+// GetDisposalsByOwnerID lists the disposals of a holder before a cutoff, oldest first.
+func (d *DB) GetDisposalsByOwnerID(ownerID string, before time.Time) ([]models.Disposal, error) {
+	var disposals []models.Disposal
+	err := d.Select(&disposals,
+		"SELECT * FROM disposals WHERE owner_id = $1 AND disposed_at < $2 ORDER BY disposed_at",
+		ownerID, before,
+	)
+	return disposals, err
+}
+
+func roundQuantity(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+func TestPlanLotConsumption(t *testing.T) {
+	lot := func(id string, quantity, cost float64) models.TaxLot {
+		return models.TaxLot{ID: id, Quantity: quantity, RemainingQuantity: quantity, TotalCost: cost, RemainingCost: cost}
+	}
+	twoLots := []models.TaxLot{lot("a", 10, 100), lot("b", 10, 300)}
+
+	tests := []struct {
+		name          string
+		lots          []models.TaxLot
+		quantity      float64
+		method        string
+		wantTakes     []lotTake
+		wantCost      float64
+		wantUncovered float64
+	}{
+		{
+			name:     "fifo within the oldest lot",
+			lots:     twoLots,
+			quantity: 5,
+			method:   models.CostMethodFIFO,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 5, Cost: 50},
+			},
+			wantCost: 50,
+		},
+		{
+			name:     "fifo drains the oldest lot first",
+			lots:     twoLots,
+			quantity: 15,
+			method:   models.CostMethodFIFO,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 10, Cost: 100},
+				{LotID: "b", Quantity: 5, Cost: 150},
+			},
+			wantCost: 250,
+		},
+		{
+			name:     "fifo beyond the open lots",
+			lots:     twoLots,
+			quantity: 25,
+			method:   models.CostMethodFIFO,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 10, Cost: 100},
+				{LotID: "b", Quantity: 10, Cost: 300},
+			},
+			wantCost:      400,
+			wantUncovered: 5,
+		},
+		{
+			name:     "average takes the same fraction of every lot",
+			lots:     twoLots,
+			quantity: 10,
+			method:   models.CostMethodAverage,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 5, Cost: 50},
+				{LotID: "b", Quantity: 5, Cost: 150},
+			},
+			wantCost: 200,
+		},
+		{
+			name:     "average of everything",
+			lots:     twoLots,
+			quantity: 20,
+			method:   models.CostMethodAverage,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 10, Cost: 100},
+				{LotID: "b", Quantity: 10, Cost: 300},
+			},
+			wantCost: 400,
+		},
+		{
+			name:     "partial cost is rounded to cents",
+			lots:     []models.TaxLot{lot("a", 3, 10)},
+			quantity: 1,
+			method:   models.CostMethodFIFO,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 1, Cost: 3.33},
+			},
+			wantCost: 3.33,
+		},
+		{
+			name:     "a lot taken whole gives up its remaining cost",
+			lots:     []models.TaxLot{{ID: "a", Quantity: 3, RemainingQuantity: 2, TotalCost: 10, RemainingCost: 6.67}},
+			quantity: 2,
+			method:   models.CostMethodAverage,
+			wantTakes: []lotTake{
+				{LotID: "a", Quantity: 2, Cost: 6.67},
+			},
+			wantCost: 6.67,
+		},
+		{
+			name:          "no lots",
+			quantity:      5,
+			method:        models.CostMethodAverage,
+			wantUncovered: 5,
+		},
+		{
+			name:     "nothing taken",
+			lots:     twoLots,
+			quantity: 0,
+			method:   models.CostMethodFIFO,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			takes, cost, uncovered := planLotConsumption(tt.lots, tt.quantity, tt.method)
+			if !slices.Equal(takes, tt.wantTakes) {
+				t.Errorf("takes = %+v, want %+v", takes, tt.wantTakes)
+			}
+			if cost != tt.wantCost || uncovered != tt.wantUncovered {
+				t.Errorf("cost, uncovered = %v, %v, want %v, %v", cost, uncovered, tt.wantCost, tt.wantUncovered)
+			}
+		})
+	}
+}
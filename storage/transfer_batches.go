@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ferreirogomes/tiquin/models"
+
+	"github.com/lib/pq"
+)
+
+// batchTransferInsertChunk is how many rows go in one multi-row INSERT, well
+// under PostgreSQL's limit of 65535 parameters per statement.
+const batchTransferInsertChunk = 1000
+
+// CreateTransferBatch saves a transfer batch together with its rows.
+func (d *DB) CreateTransferBatch(batch models.TransferBatch, rows []models.BatchTransfer) (err error) {
+	tx, err := d.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NamedExec(
+		`INSERT INTO transfer_batches (id, asset_id, kind, description, status, total_amount, row_count, created_at, updated_at)
+		 VALUES (:id, :asset_id, :kind, :description, :status, :total_amount, :row_count, :created_at, :updated_at)`,
+		batch,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert transfer batch: %w", err)
+	}
+
+	for start := 0; start < len(rows); start += batchTransferInsertChunk {
+		_, err = tx.NamedExec(
+			`INSERT INTO batch_transfers (id, batch_id, row_number, recipient_id, solana_pub_key, amount_atomic, amount,
+			                              status, last_error, updated_at)
+			 VALUES (:id, :batch_id, :row_number, :recipient_id, :solana_pub_key, :amount_atomic, :amount,
+			         :status, :last_error, :updated_at)`,
+			rows[start:min(start+batchTransferInsertChunk, len(rows))],
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert batch rows: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTransferBatch retrieves a transfer batch by ID, with its number of rows per status.
+func (d *DB) GetTransferBatch(id string) (models.TransferBatch, bool, error) {
+	var batch models.TransferBatch
+	err := d.Get(&batch, "SELECT * FROM transfer_batches WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return batch, false, nil
+		}
+		return batch, false, err
+	}
+
+	var counts []struct {
+		Status string `json:"status"`
+		Total  int    `json:"total"`
+	}
+	err = d.Select(&counts, `SELECT status, COUNT(*) AS total FROM batch_transfers WHERE batch_id = $1 GROUP BY status`, id)
+	if err != nil {
+		return batch, false, err
+	}
+	batch.RowStatuses = make(map[string]int, len(counts))
+	for _, c := range counts {
+		batch.RowStatuses[c.Status] = c.Total
+	}
+	return batch, true, nil
+}
+
+// GetTransferBatchesByAssetID lists the transfer batches of an asset, most recent first.
+func (d *DB) GetTransferBatchesByAssetID(assetID string) ([]models.TransferBatch, error) {
+	var batches []models.TransferBatch
+	err := d.Select(&batches, "SELECT * FROM transfer_batches WHERE asset_id = $1 ORDER BY created_at DESC", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if batches == nil {
+		batches = []models.TransferBatch{}
+	}
+	return batches, nil
+}
+
+// GetTransferBatchesByStatus lists the transfer batches in a status, oldest first.
+func (d *DB) GetTransferBatchesByStatus(status string) ([]models.TransferBatch, error) {
+	var batches []models.TransferBatch
+	err := d.Select(&batches, "SELECT * FROM transfer_batches WHERE status = $1 ORDER BY created_at", status)
+	if err != nil {
+		return nil, err
+	}
+	if batches == nil {
+		batches = []models.TransferBatch{}
+	}
+	return batches, nil
+}
+
+// GetBatchTransfers lists the rows of a transfer batch in submission order,
+// optionally restricted to the given statuses.
+func (d *DB) GetBatchTransfers(batchID string, statuses ...string) ([]models.BatchTransfer, error) {
+	var rows []models.BatchTransfer
+	err := d.Select(&rows,
+		`SELECT * FROM batch_transfers
+		 WHERE batch_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2))
+		 ORDER BY row_number`,
+		batchID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = []models.BatchTransfer{}
+	}
+	return rows, nil
+}
+
+// ClaimTransferBatch moves a transfer batch to `to` only if it is currently
+// in one of the `from` statuses. It returns false when another caller got there first.
+func (d *DB) ClaimTransferBatch(id, to string, from ...string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE transfer_batches SET status = $1, updated_at = NOW() WHERE id = $2 AND status = ANY($3)`,
+		to, id, pq.Array(from),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// UpdateTransferBatchStatus sets the status of a transfer batch.
+func (d *DB) UpdateTransferBatchStatus(id, status string) error {
+	_, err := d.Exec(`UPDATE transfer_batches SET status = $1, updated_at = NOW() WHERE id = $2`, status, id)
+	return err
+}
+
+// MarkBatchTransfersSent records the transaction carrying a group of rows.
+func (d *DB) MarkBatchTransfersSent(ids []string, txID string) error {
+	_, err := d.Exec(
+		`UPDATE batch_transfers SET status = $1, transaction_id = $2, attempts = attempts + 1, last_error = NULL, updated_at = NOW()
+		 WHERE id = ANY($3)`,
+		models.BatchTransferStatusSent, txID, pq.Array(ids),
+	)
+	return err
+}
+
+// MarkBatchTransfersConfirmed records that the transaction carrying the rows is confirmed.
+func (d *DB) MarkBatchTransfersConfirmed(ids []string) error {
+	_, err := d.Exec(
+		`UPDATE batch_transfers SET status = $1, confirmed_at = NOW(), updated_at = NOW() WHERE id = ANY($2)`,
+		models.BatchTransferStatusConfirmed, pq.Array(ids),
+	)
+	return err
+}
+
+// MarkBatchTransfersFailed records a failed attempt for a group of rows.
+// countAttempt is false when the attempt was already counted on send.
+func (d *DB) MarkBatchTransfersFailed(ids []string, reason string, countAttempt bool) error {
+	increment := 0
+	if countAttempt {
+		increment = 1
+	}
+	_, err := d.Exec(
+		`UPDATE batch_transfers SET status = $1, last_error = $2, attempts = attempts + $3, updated_at = NOW()
+		 WHERE id = ANY($4)`,
+		models.BatchTransferStatusFailed, reason, increment, pq.Array(ids),
+	)
+	return err
+}
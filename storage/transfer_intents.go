@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+// SaveTransferIntent records a transfer prepared for its sender to sign.
+func (d *DB) SaveTransferIntent(intent models.TransferIntent) error {
+	query := `
+		INSERT INTO transfer_intents (id, asset_id, from_user_id, to_user_id, amount, destination, transaction, status,
+		                              expires_at, created_at, updated_at)
+		VALUES (:id, :asset_id, :from_user_id, :to_user_id, :amount, :destination, :transaction, :status,
+		        :expires_at, :created_at, :updated_at)
+	`
+	_, err := d.NamedExec(query, intent)
+	return err
+}
+
+// GetTransferIntent retrieves a transfer intent by ID.
+func (d *DB) GetTransferIntent(id string) (models.TransferIntent, bool, error) {
+	var intent models.TransferIntent
+	err := d.Get(&intent, "SELECT * FROM transfer_intents WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return intent, false, nil
+		}
+		return intent, false, err
+	}
+	return intent, true, nil
+}
+
+// ClaimTransferIntent moves a prepared, unexpired intent to sending, so its
+// transaction is sent once. It returns false when the intent was already
+// claimed or has expired.
+func (d *DB) ClaimTransferIntent(id string) (bool, error) {
+	result, err := d.Exec(
+		`UPDATE transfer_intents SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3 AND expires_at > NOW()`,
+		models.TransferIntentStatusSending, id, models.TransferIntentStatusPrepared,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// MarkTransferIntentSent records the transaction an intent was sent as.
+func (d *DB) MarkTransferIntentSent(id, txID string) error {
+	_, err := d.Exec(
+		`UPDATE transfer_intents SET status = $1, transaction_id = $2, last_error = NULL, updated_at = NOW() WHERE id = $3`,
+		models.TransferIntentStatusSent, txID, id,
+	)
+	return err
+}
+
+// MarkTransferIntentFailed records why sending an intent failed.
+func (d *DB) MarkTransferIntentFailed(id, reason string) error {
+	_, err := d.Exec(
+		`UPDATE transfer_intents SET status = $1, last_error = $2, updated_at = NOW() WHERE id = $3`,
+		models.TransferIntentStatusFailed, reason, id,
+	)
+	return err
+}
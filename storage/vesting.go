@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/ferreirogomes/tiquin/models"
+)
+
+const insertVestingScheduleQuery = `
+	INSERT INTO vesting_schedules (id, asset_id, owner_id, total_amount, schedule_type, start_date, cliff_months, duration_months, description, created_at)
+	VALUES (:id, :asset_id, :owner_id, :total_amount, :schedule_type, :start_date, :cliff_months, :duration_months, :description, :created_at)
+`
+
+// SaveVestingSchedule creates a vesting schedule.
+func (d *DB) SaveVestingSchedule(schedule models.VestingSchedule) error {
+	_, err := d.NamedExec(insertVestingScheduleQuery, schedule)
+	return err
+}
+
+// GetVestingSchedule retrieves a vesting schedule by ID.
+func (d *DB) GetVestingSchedule(id string) (models.VestingSchedule, bool, error) {
+	var schedule models.VestingSchedule
+	err := d.Get(&schedule, "SELECT * FROM vesting_schedules WHERE id = $1", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return schedule, false, nil
+		}
+		return schedule, false, err
+	}
+	return schedule, true, nil
+}
+
+// GetVestingSchedulesByAssetID lists the schedules of an asset.
+func (d *DB) GetVestingSchedulesByAssetID(assetID string) ([]models.VestingSchedule, error) {
+	var schedules []models.VestingSchedule
+	err := d.Select(&schedules, "SELECT * FROM vesting_schedules WHERE asset_id = $1 ORDER BY start_date", assetID)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []models.VestingSchedule{}
+	}
+	return schedules, nil
+}
+
+// GetActiveVestingSchedules lists the non-revoked schedules of an owner,
+// optionally restricted to one asset.
+func (d *DB) GetActiveVestingSchedules(ownerID, assetID string) ([]models.VestingSchedule, error) {
+	var schedules []models.VestingSchedule
+	err := d.Select(&schedules,
+		`SELECT * FROM vesting_schedules
+		 WHERE owner_id = $1 AND ($2 = '' OR asset_id::text = $2) AND revoked_at IS NULL
+		 ORDER BY start_date`,
+		ownerID, assetID,
+	)
+	return schedules, err
+}
+
+// RevokeVestingSchedule marks a schedule revoked. It returns false when it
+// was already revoked.
+func (d *DB) RevokeVestingSchedule(id string) (bool, error) {
+	result, err := d.Exec(`UPDATE vesting_schedules SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// GetOwnerHoldings aggregates the token records of an owner per asset. Only
+// the totals are filled in.
+func (d *DB) GetOwnerHoldings(ownerID string) ([]models.HoldingBalance, error) {
+	var holdings []models.HoldingBalance
+	err := d.Select(&holdings,
+		`SELECT a.id AS asset_id, a.symbol, SUM(t.amount) AS total
+		 FROM tokens t
+		 JOIN assets a ON a.id = t.asset_id
+		 WHERE t.owner_id = $1
+		 GROUP BY a.id, a.symbol
+		 HAVING SUM(t.amount) > 0
+		 ORDER BY a.symbol`,
+		ownerID,
+	)
+	return holdings, err
+}